@@ -15,6 +15,25 @@ func CurrentBlockQuery() string {
 }
 
 func TransactionDetailQuery(hash types.Hash) string {
+	return transactionDetailQuery(hash, "")
+}
+
+// TypedTransactionDetailQuery also requests the fields of typed transactions (EIP-2718), which
+// nodes that predate them do not provide
+func TypedTransactionDetailQuery(hash types.Hash) string {
+	return transactionDetailQuery(hash, `
+		type
+		v
+		maxFeePerGas
+		maxPriorityFeePerGas
+		effectiveGasPrice
+		accessList {
+			address
+			storageKeys
+		}`)
+}
+
+func transactionDetailQuery(hash types.Hash, typedFields string) string {
 	return `query { transaction(hash:"` + hash.Hex() + `") {
         hash
         status
@@ -30,7 +49,7 @@ func TransactionDetailQuery(hash types.Hash) string {
         createdContract { address }
 		inputData
 		privateInputData
-		isPrivate
+		isPrivate` + typedFields + `
 		logs {
 			index
 			account { address }
@@ -23,8 +23,8 @@ type Transaction struct {
 	Nonce             types.HexNumber
 	From              Address
 	To                Address
-	Value             types.HexNumber
-	GasPrice          types.HexNumber
+	Value             *types.BigInt
+	GasPrice          *types.BigInt
 	Gas               types.HexNumber
 	GasUsed           types.HexNumber
 	CumulativeGasUsed types.HexNumber
@@ -33,6 +33,15 @@ type Transaction struct {
 	PrivateInputData  types.HexData
 	IsPrivate         bool
 	Logs              []Event
+
+	// only set by TypedTransactionDetailQuery; the type is a number or a hex string depending
+	// on the version of the node, so is decoded as a BigInt
+	Type                 *types.BigInt
+	V                    *types.BigInt
+	MaxFeePerGas         *types.BigInt
+	MaxPriorityFeePerGas *types.BigInt
+	EffectiveGasPrice    *types.BigInt
+	AccessList           []types.AccessTuple
 }
 
 type Event struct {
@@ -47,8 +47,8 @@ func NewQuorumClient(rawUrl, qgUrl string) (*QuorumClient, error) {
 	log.Debug("Connected to GraphQL endpoint")
 
 	// Start websocket receiver.
+	quorumClient.shutdownWg.Add(1)
 	go func() {
-		quorumClient.shutdownWg.Add(1)
 		quorumClient.wsClient.listen(quorumClient.shutdownChan)
 		quorumClient.shutdownWg.Done()
 	}()
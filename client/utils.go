@@ -4,6 +4,8 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"math/big"
+	"strings"
 
 	"quorumengineering/quorum-report/log"
 	"quorumengineering/quorum-report/types"
@@ -17,6 +19,10 @@ const (
 	getCode          = "eth_getCode"
 	getBlockByNumber = "eth_getBlockByNumber"
 	ethStorageRoot   = "eth_storageRoot"
+	getStorageAt     = "eth_getStorageAt"
+	getBalance       = "eth_getBalance"
+	getTransaction   = "eth_getTransactionByHash"
+	chainID          = "eth_chainId"
 	protocolKey      = "protocols"
 	istanbulKey      = "istanbul"
 	consensusKey     = "consensus"
@@ -42,6 +48,15 @@ func fmtBlockNum(blockNumber uint64) string {
 	return fmt.Sprintf("0x%x", blockNumber)
 }
 
+// TransactionByHash fetches the fields of a typed transaction that GraphQL does not provide
+func TransactionByHash(c Client, txHash types.Hash) (types.RawTransaction, error) {
+	var resp types.RawTransaction
+	if err := c.RPCCall(&resp, getTransaction, txHash.String()); err != nil {
+		return types.RawTransaction{}, err
+	}
+	return resp, nil
+}
+
 func TraceTransaction(c Client, txHash types.Hash) (types.RawOuterCall, error) {
 	log.Debug("Tracing transaction", "tx", txHash.String())
 
@@ -148,6 +163,32 @@ func TransactionWithReceipt(c Client, transactionHash types.Hash) (Transaction,
 	return txResult.Transaction, nil
 }
 
+// TypedTransactionWithReceipt fetches a transaction along with the fields of typed transactions,
+// failing if the node does not support them
+func TypedTransactionWithReceipt(c Client, transactionHash types.Hash) (Transaction, error) {
+	var txResult TransactionResult
+	if err := c.ExecuteGraphQLQuery(&txResult, TypedTransactionDetailQuery(transactionHash)); err != nil {
+		return Transaction{}, err
+	}
+	return txResult.Transaction, nil
+}
+
+// IsUnknownFieldError reports whether a GraphQL query failed validation because it asked
+// for a field the node's schema does not have, such as the typed transaction fields on a
+// node that predates them. Errors reaching the node, or from executing the query, are not.
+func IsUnknownFieldError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "Cannot query field")
+}
+
+// ChainID fetches the chain ID the node signs transactions for
+func ChainID(c Client) (*types.BigInt, error) {
+	var resp *types.BigInt
+	if err := c.RPCCall(&resp, chainID); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
 func CallBalanceOfERC20(c Client, contract types.Address, holder types.Address, blockNum uint64) (types.HexData, error) {
 	// 70a08231 is the 4byte function sig for `balanceOf(address)`
 	// "000000000000000000000000" + string(holder) is the token holders address, padded to 32 bytes
@@ -163,6 +204,19 @@ func CallBalanceOfERC20(c Client, contract types.Address, holder types.Address,
 	return res, err
 }
 
+// CallWithoutArgs calls a contract function that takes no arguments, such as `totalSupply()`,
+// given the 4byte function sig as hex
+func CallWithoutArgs(c Client, contract types.Address, functionSig string, blockNum uint64) (types.HexData, error) {
+	msg := types.EIP165Call{
+		To:   contract,
+		Data: types.NewHexData(functionSig),
+	}
+
+	var res types.HexData
+	err := c.RPCCall(&res, ethCall, msg, fmtBlockNum(blockNum))
+	return res, err
+}
+
 func StorageRoot(c Client, account types.Address, blockNum uint64) (types.Hash, error) {
 	var res types.Hash
 	err := c.RPCCall(&res, ethStorageRoot, account.String(), fmt.Sprintf("0x%x", blockNum))
@@ -171,3 +225,22 @@ func StorageRoot(c Client, account types.Address, blockNum uint64) (types.Hash,
 	}
 	return res, err
 }
+
+func StorageAt(c Client, account types.Address, slot types.Hash, blockNum uint64) (types.Hash, error) {
+	var res types.Hash
+	err := c.RPCCall(&res, getStorageAt, account.String(), slot.String(), fmtBlockNum(blockNum))
+	return res, err
+}
+
+// GetBalance returns the native currency balance of an account at the given block
+func GetBalance(c Client, account types.Address, blockNum uint64) (*big.Int, error) {
+	var res string
+	if err := c.RPCCall(&res, getBalance, account.String(), fmtBlockNum(blockNum)); err != nil {
+		return nil, err
+	}
+	balance, ok := new(big.Int).SetString(strings.TrimPrefix(res, "0x"), 16)
+	if !ok {
+		return nil, errors.New("invalid balance returned: " + res)
+	}
+	return balance, nil
+}
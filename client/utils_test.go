@@ -1,6 +1,7 @@
 package client
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -75,6 +76,30 @@ func TestTraceTransaction(t *testing.T) {
 	assert.Len(t, trace.Calls, 1)
 }
 
+func TestTransactionByHash_WithError(t *testing.T) {
+	mockRPC := map[string]interface{}{}
+	stubClient := NewStubQuorumClient(nil, mockRPC)
+
+	tx, err := TransactionByHash(stubClient, types.NewHash("0x0000000000000000000000000000000000000000000000000000000000000000"))
+	assert.EqualError(t, err, "not found")
+	assert.Equal(t, types.RawTransaction{}, tx)
+}
+
+func TestTransactionByHash(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_getTransactionByHash0x0000000000000000000000000000000000000000000000000000000000000000": types.RawTransaction{
+			Type:         types.DynamicFeeTxType,
+			MaxFeePerGas: types.NewBigIntFromUint64(100),
+		},
+	}
+	stubClient := NewStubQuorumClient(nil, mockRPC)
+
+	tx, err := TransactionByHash(stubClient, types.NewHash("0x0000000000000000000000000000000000000000000000000000000000000000"))
+	assert.Nil(t, err)
+	assert.EqualValues(t, types.DynamicFeeTxType, tx.Type)
+	assert.Equal(t, "100", tx.MaxFeePerGas.String())
+}
+
 func TestDumpAddress_WithError(t *testing.T) {
 	mockRPC := map[string]interface{}{}
 	stubClient := NewStubQuorumClient(nil, mockRPC)
@@ -259,8 +284,8 @@ func TestTransactionWithReceipt(t *testing.T) {
 		Nonce:             types.HexNumber(1),
 		From:              Address{Address: "ed9d02e382b34818e88b88a309c7fe71e65f419d"},
 		To:                Address{},
-		Value:             types.HexNumber(0),
-		GasPrice:          types.HexNumber(0),
+		Value:             types.NewBigIntFromUint64(0),
+		GasPrice:          types.NewBigIntFromUint64(0),
 		Gas:               types.HexNumber(4700000),
 		GasUsed:           types.HexNumber(164007),
 		CumulativeGasUsed: types.HexNumber(164007),
@@ -291,6 +316,58 @@ func TestTransactionWithReceipt_WithError(t *testing.T) {
 	assert.Equal(t, Transaction{}, result)
 }
 
+func TestTypedTransactionWithReceipt(t *testing.T) {
+	testTransactionHash := types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8")
+	mockGraphQL := map[string]map[string]interface{}{
+		TypedTransactionDetailQuery(testTransactionHash): {
+			"transaction": map[string]interface{}{
+				"hash":              "0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8",
+				"type":              2,
+				"maxFeePerGas":      "0x64",
+				"effectiveGasPrice": "0x32",
+				"accessList": []map[string]interface{}{
+					{"address": "0x1349f3e1b8d71effb47b840594ff27da7e603d17", "storageKeys": []string{}},
+				},
+			},
+		},
+	}
+	stubClient := NewStubQuorumClient(mockGraphQL, nil)
+
+	result, err := TypedTransactionWithReceipt(stubClient, testTransactionHash)
+	assert.Nil(t, err)
+	assert.Equal(t, "2", result.Type.String())
+	assert.Equal(t, "100", result.MaxFeePerGas.String())
+	assert.Nil(t, result.MaxPriorityFeePerGas)
+	assert.Equal(t, "50", result.EffectiveGasPrice.String())
+	assert.Equal(t, []types.AccessTuple{{Address: "1349f3e1b8d71effb47b840594ff27da7e603d17", StorageKeys: []types.Hash{}}}, result.AccessList)
+
+	// the query without the typed fields is not answered by the typed one
+	_, err = TransactionWithReceipt(stubClient, testTransactionHash)
+	assert.EqualError(t, err, "not found")
+}
+
+func TestIsUnknownFieldError(t *testing.T) {
+	assert.True(t, IsUnknownFieldError(errors.New(`graphql: Cannot query field "maxFeePerGas" on type "Transaction".`)))
+	assert.False(t, IsUnknownFieldError(errors.New("Post http://localhost:8547/graphql: dial tcp 127.0.0.1:8547: connect: connection refused")))
+	assert.False(t, IsUnknownFieldError(nil))
+}
+
+func TestChainID(t *testing.T) {
+	stubClient := NewStubQuorumClient(nil, map[string]interface{}{"eth_chainId": types.NewBigIntFromUint64(10)})
+
+	chainID, err := ChainID(stubClient)
+	assert.Nil(t, err)
+	assert.Equal(t, "10", chainID.String())
+}
+
+func TestChainID_WithError(t *testing.T) {
+	stubClient := NewStubQuorumClient(nil, nil)
+
+	chainID, err := ChainID(stubClient)
+	assert.EqualError(t, err, "not found")
+	assert.Nil(t, chainID)
+}
+
 func TestCallBalanceOfERC20_WithError(t *testing.T) {
 	stubClient := NewStubQuorumClient(nil, nil)
 
@@ -318,6 +395,20 @@ func TestCallBalanceOfERC20(t *testing.T) {
 	assert.Equal(t, types.HexData("12345"), contractCallResult)
 }
 
+func TestCallWithoutArgs(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_call<types.EIP165Call Value>0x1": types.NewHexData("0x3e8"),
+	}
+
+	stubClient := NewStubQuorumClient(nil, mockRPC)
+
+	tokenContract := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+
+	contractCallResult, err := CallWithoutArgs(stubClient, tokenContract, "0x18160ddd", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, types.HexData("3e8"), contractCallResult)
+}
+
 func TestStorageRoot_WithError(t *testing.T) {
 	stubClient := NewStubQuorumClient(nil, nil)
 
@@ -338,3 +429,24 @@ func TestStorageRoot(t *testing.T) {
 	assert.Nil(t, err)
 	assert.EqualValues(t, "0000000000000000000000000000000000000000000000000000000000000001", result)
 }
+
+func TestGetBalance(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_getBalance0x00000000000000000000000000000000000000010x5": "0x1bc16d674ec80000",
+	}
+	stubClient := NewStubQuorumClient(nil, mockRPC)
+
+	result, err := GetBalance(stubClient, types.NewAddress("1"), 5)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "2000000000000000000", result.String())
+}
+
+func TestGetBalance_WithError(t *testing.T) {
+	stubClient := NewStubQuorumClient(nil, nil)
+
+	result, err := GetBalance(stubClient, types.NewAddress("1"), 5)
+
+	assert.EqualError(t, err, "not found")
+	assert.Nil(t, result)
+}
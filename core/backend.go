@@ -2,12 +2,14 @@ package core
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"quorumengineering/quorum-report/client"
 	"quorumengineering/quorum-report/core/filter"
 	"quorumengineering/quorum-report/core/monitor"
 	"quorumengineering/quorum-report/core/rpc"
+	"quorumengineering/quorum-report/core/subscription"
 	"quorumengineering/quorum-report/database"
 	"quorumengineering/quorum-report/database/factory"
 	"quorumengineering/quorum-report/log"
@@ -19,6 +21,7 @@ type Backend struct {
 	monitor      *monitor.MonitorService
 	filter       *filter.FilterService
 	rpc          *rpc.RPCService
+	hub          *subscription.Hub
 	db           database.Database
 	quorumClient client.Client
 
@@ -91,16 +94,24 @@ func New(config types.ReportingConfig) (*Backend, error) {
 		}
 	}
 
-	monitorService, err := monitor.NewMonitorService(db, quorumClient, consensus, config)
+	// a rollback of a chain reorg waits for the batch being filtered, and pauses filtering
+	rollbackLock := &sync.RWMutex{}
+	monitorService, err := monitor.NewMonitorService(db, quorumClient, consensus, config, rollbackLock)
 	if err != nil {
 		return nil, err
 	}
 
+	hub := subscription.NewHub(db)
+	filterService, err := filter.NewFilterService(db, quorumClient, hub, config, rollbackLock)
+	if err != nil {
+		return nil, err
+	}
 	backendErrorChan := make(chan error)
 	return &Backend{
 		monitor:          monitorService,
-		filter:           filter.NewFilterService(db, quorumClient),
-		rpc:              rpc.NewRPCService(db, config, backendErrorChan),
+		filter:           filterService,
+		rpc:              rpc.NewRPCService(db, config, hub, backendErrorChan),
+		hub:              hub,
 		db:               db,
 		quorumClient:     quorumClient,
 		backendErrorChan: backendErrorChan,
@@ -128,6 +139,8 @@ func (b *Backend) Stop() {
 	// stop services
 	b.rpc.Stop()
 	b.filter.Stop()
+	// close subscriptions still open after the RPC server stopped
+	b.hub.Stop()
 	b.monitor.Stop()
 	// stop db connection
 	b.db.Stop()
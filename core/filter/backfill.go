@@ -0,0 +1,132 @@
+package filter
+
+import (
+	"quorumengineering/quorum-report/core/proxy"
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// backfillBatchSize is how many blocks each token backfill job processes in a tick
+var backfillBatchSize uint64 = 1000
+
+// backfillLockedBlocks is how many blocks are backfilled while the rollback lock is held
+var backfillLockedBlocks uint64 = 10
+
+// backfillTokenConfig returns the token configuration of the backfill processors. ERC20
+// balances are derived from the events alone, as the state of old blocks may have been
+// pruned from the node.
+func backfillTokenConfig(config types.TokenConfig) types.TokenConfig {
+	config.ERC20BalanceSource = types.ERC20EventsSource
+	config.ERC20ReconcileInterval = 0
+	return config
+}
+
+// runningBackfills returns the contracts with a running token backfill job
+func (fs *FilterService) runningBackfills() (map[types.Address]bool, error) {
+	jobs, err := fs.db.GetBackfillJobs()
+	if err != nil {
+		return nil, err
+	}
+	running := make(map[types.Address]bool)
+	for _, job := range jobs {
+		if job.Status == types.BackfillRunning {
+			running[job.Contract] = true
+		}
+	}
+	return running, nil
+}
+
+// backfill advances every running token backfill job by a batch of blocks. A job that
+// fails is retried from where it stopped on the next tick.
+func (fs *FilterService) backfill() {
+	running, err := fs.runningBackfills()
+	if err != nil {
+		log.Warn("Fetching token backfill jobs failed", "err", err)
+		return
+	}
+	for contract := range running {
+		if err := fs.backfillBatch(contract); err != nil {
+			log.Warn("Token backfill failed", "contract", contract.String(), "err", err)
+		}
+	}
+}
+
+// backfillBatch advances the job of the contract by up to a batch of blocks, a few blocks
+// at a time, so that a chain reorg is not held up for the whole batch.
+func (fs *FilterService) backfillBatch(contract types.Address) error {
+	for processed := uint64(0); processed < backfillBatchSize; processed += backfillLockedBlocks {
+		count := backfillLockedBlocks
+		if remaining := backfillBatchSize - processed; remaining < count {
+			count = remaining
+		}
+		completed, err := fs.backfillBlocks(contract, count)
+		if err != nil || completed {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillBlocks rebuilds the token records of the contract for the next count blocks of
+// its job, up to the block the contract was filtered to before its filtering was paused,
+// and returns whether the job has completed. A chain reorg may roll back the blocks and
+// the progress of the job, so both are read with the rollback lock held.
+// The records of the blocks are first removed, whether recorded when the contract was
+// filtered or by a run that was interrupted before its progress was stored. The records
+// of later blocks are kept until they are rebuilt in turn.
+func (fs *FilterService) backfillBlocks(contract types.Address, count uint64) (bool, error) {
+	fs.rollbackLock.RLock()
+	defer fs.rollbackLock.RUnlock()
+
+	job, err := fs.db.GetBackfillJob(contract)
+	if err != nil {
+		return false, err
+	}
+	if job.Status != types.BackfillRunning {
+		return true, nil
+	}
+	lastFiltered, err := fs.db.GetLastFiltered(job.Contract)
+	if err != nil {
+		return false, err
+	}
+	job.ToBlock = lastFiltered
+
+	if job.NextBlock <= job.ToBlock {
+		endBlock := job.NextBlock + count - 1
+		if endBlock > job.ToBlock {
+			endBlock = job.ToBlock
+		}
+		log.Debug("Backfilling tokens", "contract", job.Contract.String(), "start", job.NextBlock, "end", endBlock, "to", job.ToBlock)
+
+		if err := fs.db.RollbackTokenRange(job.Contract, job.NextBlock, endBlock); err != nil {
+			return false, err
+		}
+		resolver := proxy.NewResolver(fs.db)
+		for blockNumber := job.NextBlock; blockNumber <= endBlock; blockNumber++ {
+			block, err := fs.db.ReadBlock(blockNumber)
+			if err != nil {
+				return false, err
+			}
+			blockWithTxns, err := fs.makeBlockWithTransactions(block)
+			if err != nil {
+				return false, err
+			}
+			abi, err := resolver.ABI(job.Contract, blockNumber)
+			if err != nil {
+				return false, err
+			}
+			for _, processor := range fs.backfillProcessors {
+				if err := processor.ProcessBlock(map[types.Address]string{job.Contract: abi}, blockWithTxns); err != nil {
+					return false, err
+				}
+			}
+		}
+		job.NextBlock = endBlock + 1
+	}
+
+	if job.NextBlock > job.ToBlock {
+		job.Status = types.BackfillCompleted
+		log.Info("Completed token backfill", "contract", job.Contract.String(), "from", job.FromBlock, "to", job.ToBlock)
+	}
+	return job.Status == types.BackfillCompleted, fs.db.RecordBackfillJob(job)
+}
@@ -0,0 +1,167 @@
+package filter
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/types"
+)
+
+const pointsAbiString = `[{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"member","type":"address"},{"indexed":false,"internalType":"uint256","name":"points","type":"uint256"}],"name":"Awarded","type":"event"}]`
+
+var (
+	tokenAddress = types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	otherAddress = types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+	holder       = types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+)
+
+// newBackfillTest stores three blocks awarding the holder points, with block 1 already backfilled and
+// blocks 2 and 3 recorded when the contract was filtered
+func newBackfillTest(t *testing.T) (*memory.MemoryDB, *FilterService) {
+	awarded := types.Hash("cd8ae3717c1e5ff5eae19e78399c4db942424faf048bca1b2774ac2a7c3d70a5")
+
+	// each block awards the holder points
+	db := memory.NewMemoryDB()
+	for i, points := range []string{"03e8", "0064", "000a"} {
+		blockNumber := uint64(i + 1)
+		tx := &types.Transaction{
+			Hash:        types.NewHash(fmt.Sprintf("0x%x", blockNumber)),
+			BlockNumber: blockNumber,
+			Events: []*types.Event{{
+				Address: tokenAddress,
+				Topics:  []types.Hash{awarded, types.Hash("000000000000000000000000" + string(holder))},
+				Data:    types.NewHexData("0x000000000000000000000000000000000000000000000000000000000000" + points),
+			}},
+		}
+		assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx}))
+		assert.Nil(t, db.WriteBlocks([]*types.Block{{Number: blockNumber, Transactions: []types.Hash{tx.Hash}}}))
+	}
+	assert.Nil(t, db.AddTemplate("points", pointsAbiString, ""))
+	assert.Nil(t, db.AddAddressFrom(tokenAddress, 4))
+	assert.Nil(t, db.AddAddressFrom(otherAddress, 3))
+	assert.Nil(t, db.AssignTemplate(tokenAddress, "points"))
+
+	// block 1 was backfilled before a restart, and blocks 2 and 3 were recorded when the contract was filtered
+	assert.Nil(t, db.RecordTokenBalance("points", tokenAddress, holder, 1, big.NewInt(1000)))
+	assert.Nil(t, db.RecordTokenBalance("points", tokenAddress, holder, 2, big.NewInt(100)))
+	assert.Nil(t, db.RecordTokenBalance("points", tokenAddress, holder, 3, big.NewInt(10)))
+	assert.Nil(t, db.RecordBackfillJob(&types.BackfillJob{Contract: tokenAddress, FromBlock: 1, ToBlock: 3, NextBlock: 2, Status: types.BackfillRunning}))
+
+	config := types.ReportingConfig{Tokens: types.TokenConfig{
+		Standards: []string{"points"},
+		Custom: []*types.CustomTokenConfig{{Name: "points", Events: []*types.TokenEventConfig{
+			{Event: "Awarded(address indexed member, uint256 points)", To: "member", Amount: "points"},
+		}}},
+	}}
+	fs, err := NewFilterService(db, client.NewStubQuorumClient(nil, nil), nil, config, &sync.RWMutex{})
+	assert.Nil(t, err)
+	return db, fs
+}
+
+func TestBackfill(t *testing.T) {
+	db, fs := newBackfillTest(t)
+
+	// the filtering of the contract is paused while it is backfilled
+	lastFilteredAll, lastFiltered, err := fs.getLastFiltered(3)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, lastFiltered)
+	assert.Equal(t, map[types.Address]uint64{otherAddress: 2}, lastFilteredAll)
+
+	fs.backfill()
+
+	job, err := db.GetBackfillJob(tokenAddress)
+	assert.Nil(t, err)
+	assert.Equal(t, &types.BackfillJob{Contract: tokenAddress, FromBlock: 1, ToBlock: 3, NextBlock: 4, Status: types.BackfillCompleted}, job)
+	for blockNumber, expected := range map[uint64]int64{1: 1000, 2: 1100, 3: 1110} {
+		balance, err := db.TokenBalanceAtBlock("points", tokenAddress, holder, blockNumber)
+		assert.Nil(t, err)
+		assert.Equal(t, big.NewInt(expected), balance)
+	}
+
+	// and resumed once the backfill has completed
+	lastFilteredAll, _, err = fs.getLastFiltered(3)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, lastFilteredAll[tokenAddress])
+}
+
+func TestBackfill_KeepsRecordsAfterBatch(t *testing.T) {
+	defer func(size uint64) { backfillBatchSize = size }(backfillBatchSize)
+	backfillBatchSize = 1
+	db, fs := newBackfillTest(t)
+
+	fs.backfill()
+
+	// only block 2 is rebuilt, while the record of block 3 is kept until its own batch
+	job, err := db.GetBackfillJob(tokenAddress)
+	assert.Nil(t, err)
+	assert.Equal(t, &types.BackfillJob{Contract: tokenAddress, FromBlock: 1, ToBlock: 3, NextBlock: 3, Status: types.BackfillRunning}, job)
+	for blockNumber, expected := range map[uint64]int64{1: 1000, 2: 1100, 3: 10} {
+		balance, err := db.TokenBalanceAtBlock("points", tokenAddress, holder, blockNumber)
+		assert.Nil(t, err)
+		assert.Equal(t, big.NewInt(expected), balance)
+	}
+
+	fs.backfill()
+
+	job, err = db.GetBackfillJob(tokenAddress)
+	assert.Nil(t, err)
+	assert.Equal(t, types.BackfillCompleted, job.Status)
+	balance, err := db.TokenBalanceAtBlock("points", tokenAddress, holder, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(1110), balance)
+}
+
+func TestBackfill_RollbackBetweenBlocks(t *testing.T) {
+	defer func(size uint64) { backfillLockedBlocks = size }(backfillLockedBlocks)
+	backfillLockedBlocks = 1
+	db, fs := newBackfillTest(t)
+
+	// a chain reorg to block 2 is detected while block 2 is backfilled
+	processor := &ReorgTokenProcessor{db: db, lock: fs.rollbackLock, reorgAt: 2, done: make(chan struct{})}
+	fs.backfillProcessors = append(fs.backfillProcessors, processor)
+
+	fs.backfill()
+	<-processor.done
+
+	// the rollback is applied before block 3, so the job stops at the common ancestor
+	assert.Equal(t, []uint64{2}, processor.processed)
+	job, err := db.GetBackfillJob(tokenAddress)
+	assert.Nil(t, err)
+	assert.Equal(t, &types.BackfillJob{Contract: tokenAddress, FromBlock: 1, ToBlock: 2, NextBlock: 3, Status: types.BackfillCompleted}, job)
+	balance, err := db.TokenBalanceAtBlock("points", tokenAddress, holder, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(1100), balance)
+}
+
+// ReorgTokenProcessor records the blocks it processes, and rolls back the chain to the
+// given block once it has processed it, as the monitor service does when it detects a
+// chain reorg.
+type ReorgTokenProcessor struct {
+	db        *memory.MemoryDB
+	lock      *sync.RWMutex
+	reorgAt   uint64
+	processed []uint64
+	done      chan struct{}
+}
+
+func (p *ReorgTokenProcessor) ProcessBlock(lastFilteredWithAbi map[types.Address]string, block *types.BlockWithTransactions) error {
+	p.processed = append(p.processed, block.Number)
+	if block.Number == p.reorgAt {
+		go func() {
+			defer close(p.done)
+			p.lock.Lock()
+			defer p.lock.Unlock()
+			p.db.RollbackBlocks(&types.ChainReorg{CommonAncestor: p.reorgAt})
+		}()
+		// wait for the rollback to be pending on the lock
+		time.Sleep(100 * time.Millisecond)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package filter
+
+import (
+	"quorumengineering/quorum-report/core/proxy"
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// TransactionFailureFilter records the failed transactions sent to registered addresses,
+// decoding what they reverted with using the ABI of the address at the time.
+type TransactionFailureFilter struct {
+	db FilterServiceDB
+}
+
+func NewTransactionFailureFilter(db FilterServiceDB) *TransactionFailureFilter {
+	return &TransactionFailureFilter{db: db}
+}
+
+// ProcessBlocks records a failure for every failed transaction sent directly to one of the
+// addresses. Failures that did not revert with any data, such as running out of gas, are
+// recorded without a reason.
+func (tf *TransactionFailureFilter) ProcessBlocks(addresses []types.Address, blocks []*types.BlockWithTransactions) error {
+	log.Debug("Filtering for transaction failures", "start", blocks[0].Number, "end", blocks[len(blocks)-1].Number)
+	defer func() { log.Debug("Finished filtering for transaction failures") }()
+
+	registered := make(map[types.Address]bool, len(addresses))
+	for _, address := range addresses {
+		registered[address] = true
+	}
+
+	resolver := proxy.NewResolver(tf.db)
+	var failures []types.TransactionFailure
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			if tx.Status || !registered[tx.To] {
+				continue
+			}
+			failure, err := tf.decodeFailure(resolver, tx)
+			if err != nil {
+				return err
+			}
+			failures = append(failures, failure)
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return tf.db.RecordTransactionFailures(failures)
+}
+
+func (tf *TransactionFailureFilter) decodeFailure(resolver *proxy.Resolver, tx *types.Transaction) (types.TransactionFailure, error) {
+	failure := types.TransactionFailure{
+		Address:         tx.To,
+		TransactionHash: tx.Hash,
+		BlockNumber:     tx.BlockNumber,
+		Index:           tx.Index,
+		Timestamp:       tx.Timestamp,
+		Error:           tx.Error,
+		RevertData:      tx.RevertData,
+	}
+
+	contractABI, err := resolver.ABI(tx.To, tx.BlockNumber)
+	if err != nil {
+		return failure, err
+	}
+	var customErrors []types.ContractABIFunction
+	if contractABI != "" {
+		structure, err := types.NewABIStructureFromJSON(contractABI)
+		if err != nil {
+			return failure, err
+		}
+		customErrors = structure.ToInternalABI().Errors
+	}
+
+	if revertError := types.DecodeRevert(tx.RevertData.AsBytes(), customErrors); revertError != nil {
+		failure.ErrorSig = revertError.Sig
+		failure.Reason = revertError.Reason
+	}
+	return failure, nil
+}
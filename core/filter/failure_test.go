@@ -0,0 +1,63 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/types"
+)
+
+const failureTestABI = `[{"inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}],"name":"InsufficientBalance","type":"error"}]`
+
+func TestTransactionFailureFilter_ProcessBlocks(t *testing.T) {
+	contract := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+	other := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+
+	// InsufficientBalance(5, 10)
+	customError := types.NewHexData("0xcf479181" +
+		"0000000000000000000000000000000000000000000000000000000000000005" +
+		"000000000000000000000000000000000000000000000000000000000000000a")
+	// Error("not owner")
+	errorString := types.NewHexData("0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000009" +
+		"6e6f74206f776e65720000000000000000000000000000000000000000000000")
+
+	blocks := []*types.BlockWithTransactions{
+		{Number: 10, Transactions: []*types.Transaction{
+			{Hash: types.NewHash("0x1"), BlockNumber: 10, Index: 0, To: contract, Status: true},
+			{Hash: types.NewHash("0x2"), BlockNumber: 10, Index: 1, To: contract, Error: "execution reverted", RevertData: customError},
+			// failures of unregistered addresses are not recorded
+			{Hash: types.NewHash("0x3"), BlockNumber: 10, Index: 2, To: other, Error: "execution reverted", RevertData: errorString},
+		}},
+		{Number: 11, Timestamp: 1100, Transactions: []*types.Transaction{
+			{Hash: types.NewHash("0x4"), BlockNumber: 11, Index: 0, Timestamp: 1100, To: contract, Error: "execution reverted", RevertData: errorString},
+			{Hash: types.NewHash("0x5"), BlockNumber: 11, Index: 1, Timestamp: 1100, To: contract, Error: "out of gas"},
+		}},
+	}
+
+	db := memory.NewMemoryDB()
+	assert.Nil(t, db.AddAddresses([]types.Address{contract}))
+	assert.Nil(t, db.AddTemplate("failing", failureTestABI, ""))
+	assert.Nil(t, db.AssignTemplate(contract, "failing"))
+
+	tf := NewTransactionFailureFilter(db)
+	assert.Nil(t, tf.ProcessBlocks([]types.Address{contract}, blocks))
+
+	options := &types.PageOptions{}
+	options.SetDefaults()
+	failures, err := db.GetTransactionFailures(contract, "", options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TransactionFailure{
+		{Address: contract, TransactionHash: types.NewHash("0x5"), BlockNumber: 11, Index: 1, Timestamp: 1100, Error: "out of gas"},
+		{Address: contract, TransactionHash: types.NewHash("0x4"), BlockNumber: 11, Index: 0, Timestamp: 1100, Error: "execution reverted", ErrorSig: "Error(string)", Reason: "not owner", RevertData: errorString},
+		{Address: contract, TransactionHash: types.NewHash("0x2"), BlockNumber: 10, Index: 1, Error: "execution reverted", ErrorSig: "InsufficientBalance(uint256 available,uint256 required)", Reason: "InsufficientBalance", RevertData: customError},
+	}, failures)
+
+	failures, err = db.GetTransactionFailures(contract, "InsufficientBalance", options)
+	assert.Nil(t, err)
+	assert.Len(t, failures, 1)
+	assert.Equal(t, types.NewHash("0x2"), failures[0].TransactionHash)
+}
@@ -0,0 +1,121 @@
+package filter
+
+import (
+	"math/big"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// NativeBalanceFilter records the native currency balance of registered addresses at each
+// block it may have changed in, so that balance history can be read without the node.
+type NativeBalanceFilter struct {
+	db           FilterServiceDB
+	quorumClient client.Client
+}
+
+func NewNativeBalanceFilter(db FilterServiceDB, quorumClient client.Client) *NativeBalanceFilter {
+	return &NativeBalanceFilter{
+		db:           db,
+		quorumClient: quorumClient,
+	}
+}
+
+// ProcessBlocks reads the balance of each address at the first block if none has been recorded
+// before it, and at every block where the address sent a transaction, moved native currency or
+// mined a block whose transactions paid fees. A balance is only recorded if it differs from the
+// last recorded balance.
+func (nf *NativeBalanceFilter) ProcessBlocks(addresses []types.Address, blocks []*types.BlockWithTransactions) error {
+	log.Debug("Filtering for native balances", "start", blocks[0].Number, "end", blocks[len(blocks)-1].Number)
+	defer func() { log.Debug("Finished filtering for native balances") }()
+
+	miners, err := nf.feeRecipients(blocks)
+	if err != nil {
+		return err
+	}
+	for _, address := range addresses {
+		if err := nf.processAddress(address, blocks, miners); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// feeRecipients returns the miner of each block that has a transaction paying fees. The miner
+// is read from the node, as it is not stored with the block, and only for these blocks, as
+// networks without gas prices never pay the miner.
+func (nf *NativeBalanceFilter) feeRecipients(blocks []*types.BlockWithTransactions) (map[uint64]types.Address, error) {
+	miners := make(map[uint64]types.Address)
+	for _, block := range blocks {
+		if !paysFees(block.Transactions) {
+			continue
+		}
+		header, err := client.BlockByNumber(nf.quorumClient, block.Number)
+		if err != nil {
+			return nil, err
+		}
+		miners[block.Number] = header.Miner
+	}
+	return miners, nil
+}
+
+func (nf *NativeBalanceFilter) processAddress(address types.Address, blocks []*types.BlockWithTransactions, miners map[uint64]types.Address) error {
+	var current *big.Int
+	if blocks[0].Number > 0 {
+		recorded, err := nf.db.NativeBalanceAtBlock(address, blocks[0].Number-1)
+		if err != nil && err != database.ErrNotFound {
+			return err
+		}
+		current = recorded
+	}
+
+	for _, block := range blocks {
+		if current != nil && !touchesBalance(address, block.Transactions) && miners[block.Number] != address {
+			continue
+		}
+		balance, err := client.GetBalance(nf.quorumClient, address, block.Number)
+		if err != nil {
+			return err
+		}
+		if current != nil && current.Cmp(balance) == 0 {
+			continue
+		}
+		if err := nf.db.RecordNativeBalance(address, block.Number, balance); err != nil {
+			return err
+		}
+		current = balance
+	}
+	return nil
+}
+
+// touchesBalance checks if any of the transactions may have changed the balance of the address,
+// either by paying for gas or by moving native currency to or from it
+func touchesBalance(address types.Address, txs []*types.Transaction) bool {
+	for _, tx := range txs {
+		if tx.From == address {
+			return true
+		}
+		if tx.Value.Sign() > 0 && (tx.To == address || tx.CreatedContract == address) {
+			return true
+		}
+		for _, call := range tx.InternalCalls {
+			if call.Value.Sign() > 0 && (call.From == address || call.To == address) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// paysFees checks if any of the transactions has a gas price, so that its fees are paid to the
+// miner of the block
+func paysFees(txs []*types.Transaction) bool {
+	for _, tx := range txs {
+		if tx.GasPrice.Sign() > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package filter
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/types"
+)
+
+func TestNativeBalanceFilter_ProcessBlocks(t *testing.T) {
+	account := types.NewAddress("0x0000000000000000000000000000000000000001")
+	other := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+
+	mockRPC := map[string]interface{}{
+		"eth_getBalance0x00000000000000000000000000000000000000010xa": "0x64",
+		"eth_getBalance0x00000000000000000000000000000000000000010xc": "0x5a",
+		"eth_getBalance0x00000000000000000000000000000000000000010xd": "0x5a",
+		"eth_getBalance0x00000000000000000000000000000000000000010xe": "0x64",
+	}
+	blocks := []*types.BlockWithTransactions{
+		{Number: 10},
+		{Number: 11, Transactions: []*types.Transaction{
+			// unrelated transactions do not change the balance
+			{From: other, To: other, Value: types.NewBigIntFromUint64(5)},
+		}},
+		{Number: 12, Transactions: []*types.Transaction{
+			{From: other, To: other, InternalCalls: []*types.InternalCall{{From: account, To: other, Value: types.NewBigIntFromUint64(10), Type: "CALL"}}},
+		}},
+		{Number: 13, Transactions: []*types.Transaction{
+			// sending a transaction without gas costs leaves the balance as it was
+			{From: account, To: other},
+		}},
+	}
+
+	db := memory.NewMemoryDB()
+	nf := NewNativeBalanceFilter(db, client.NewStubQuorumClient(nil, mockRPC))
+	assert.Nil(t, nf.ProcessBlocks([]types.Address{account}, blocks))
+	// the last recorded balance carries over to the next batch
+	assert.Nil(t, nf.ProcessBlocks([]types.Address{account}, []*types.BlockWithTransactions{
+		{Number: 14, Transactions: []*types.Transaction{{From: other, To: account, Value: types.NewBigIntFromUint64(10)}}},
+	}))
+
+	balances, err := db.GetNativeBalance(account, &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(-1), PageSize: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{10: big.NewInt(100), 12: big.NewInt(90), 14: big.NewInt(100)}, balances)
+}
+
+func TestNativeBalanceFilter_ProcessBlocks_Miner(t *testing.T) {
+	account := types.NewAddress("0x0000000000000000000000000000000000000001")
+	other := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+
+	// only the miners of blocks with transactions paying fees are read
+	mockRPC := map[string]interface{}{
+		"eth_getBalance0x00000000000000000000000000000000000000010xa": "0x64",
+		"eth_getBalance0x00000000000000000000000000000000000000010xb": "0x6e",
+		"eth_getBlockByNumber0xb<bool Value>":                         types.RawBlock{Number: 11, Miner: account},
+		"eth_getBlockByNumber0xd<bool Value>":                         types.RawBlock{Number: 13, Miner: other},
+	}
+	blocks := []*types.BlockWithTransactions{
+		{Number: 10},
+		{Number: 11, Transactions: []*types.Transaction{
+			// the fees of the transaction are paid to the account that mined the block
+			{From: other, To: other, GasPrice: types.NewBigIntFromUint64(1)},
+		}},
+		{Number: 12, Transactions: []*types.Transaction{
+			{From: other, To: other, GasPrice: types.NewBigIntFromUint64(0)},
+		}},
+		{Number: 13, Transactions: []*types.Transaction{
+			{From: other, To: other, GasPrice: types.NewBigIntFromUint64(1)},
+		}},
+	}
+
+	db := memory.NewMemoryDB()
+	nf := NewNativeBalanceFilter(db, client.NewStubQuorumClient(nil, mockRPC))
+	assert.Nil(t, nf.ProcessBlocks([]types.Address{account}, blocks))
+
+	balances, err := db.GetNativeBalance(account, &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(-1), PageSize: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{10: big.NewInt(100), 11: big.NewInt(110)}, balances)
+}
@@ -0,0 +1,105 @@
+package filter
+
+import (
+	"math/big"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/core/proxy"
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// ProxyFilter detects registered contracts that are upgradeable proxies, and records each
+// change of the implementation they delegate to.
+type ProxyFilter struct {
+	db           FilterServiceDB
+	quorumClient client.Client
+}
+
+func NewProxyFilter(db FilterServiceDB, quorumClient client.Client) *ProxyFilter {
+	return &ProxyFilter{
+		db:           db,
+		quorumClient: quorumClient,
+	}
+}
+
+// ProcessBlocks checks the implementation slots of each address at the start block, and at
+// every later block up to the end block where its storage changed. Storage must have been
+// indexed for the blocks beforehand.
+func (pf *ProxyFilter) ProcessBlocks(addresses []types.Address, startBlock, endBlock uint64) error {
+	log.Debug("Filtering for proxy implementations", "start", startBlock, "end", endBlock)
+	defer func() { log.Debug("Finished filtering for proxy implementations") }()
+
+	for _, address := range addresses {
+		if err := pf.processAddress(address, startBlock, endBlock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pf *ProxyFilter) processAddress(address types.Address, startBlock, endBlock uint64) error {
+	implementations, err := pf.db.GetProxyImplementations(address)
+	if err != nil {
+		return err
+	}
+	var current types.Address
+	for _, implementation := range implementations {
+		if implementation.BlockNumber < startBlock {
+			current = implementation.Implementation
+		}
+	}
+
+	record := func(found *types.ProxyImplementation, blockNumber uint64) error {
+		if found == nil {
+			if current.IsEmpty() {
+				return nil
+			}
+			// the implementation slot has been cleared, so the contract is no longer a proxy
+			found = &types.ProxyImplementation{Implementation: types.NewAddress("")}
+		} else if found.Implementation == current {
+			return nil
+		}
+		found.BlockNumber = blockNumber
+		current = found.Implementation
+		log.Info("Proxy implementation changed", "proxy", address.String(), "implementation", found.Implementation.String(), "standard", found.Standard, "block number", blockNumber)
+		return pf.db.RecordProxyImplementation(address, found)
+	}
+
+	// storage is only indexed at blocks where it changed, so the starting state is read
+	// from the node
+	found, err := proxy.FindImplementation(func(slot types.Hash) (string, error) {
+		value, err := client.StorageAt(pf.quorumClient, address, slot, startBlock)
+		return string(value), err
+	})
+	if err != nil {
+		return err
+	}
+	if err := record(found, startBlock); err != nil {
+		return err
+	}
+	if endBlock <= startBlock {
+		return nil
+	}
+
+	options := &types.PageOptions{
+		BeginBlockNumber: new(big.Int).SetUint64(startBlock + 1),
+		EndBlockNumber:   new(big.Int).SetUint64(endBlock),
+		PageSize:         int(endBlock - startBlock),
+	}
+	results, err := pf.db.GetStorageWithOptions(address, options)
+	if err != nil {
+		return err
+	}
+	// results are ordered from the latest block
+	for i := len(results) - 1; i >= 0; i-- {
+		storage := results[i].Storage
+		found, _ := proxy.FindImplementation(func(slot types.Hash) (string, error) {
+			return storage[slot], nil
+		})
+		if err := record(found, results[i].BlockNumber); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package filter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/core/proxy"
+	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/types"
+)
+
+func TestProxyFilter_ProcessBlocks(t *testing.T) {
+	proxyAddress := types.NewAddress("0x0000000000000000000000000000000000000001")
+	first := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+	second := types.NewAddress("0x9d13c6d3afe1721beef56b55d303b09e021e27ab")
+	eip1967Slot := proxy.ImplementationSlots[0].Slot
+
+	mockRPC := map[string]interface{}{}
+	for _, implementationSlot := range proxy.ImplementationSlots {
+		mockRPC["eth_getStorageAt0x0000000000000000000000000000000000000001"+implementationSlot.Slot.String()+"0xa"] = types.NewHash("")
+		mockRPC["eth_getStorageAt0x0000000000000000000000000000000000000001"+implementationSlot.Slot.String()+"0x10"] = types.NewHash("")
+	}
+	mockRPC["eth_getStorageAt0x0000000000000000000000000000000000000001"+eip1967Slot.String()+"0xa"] = types.NewHash(string(first))
+
+	db := memory.NewMemoryDB()
+	assert.Nil(t, db.AddAddresses([]types.Address{proxyAddress}))
+	storageAt := func(blockNumber uint64, storage map[types.Hash]string) {
+		assert.Nil(t, db.IndexStorage(map[types.Address]*types.AccountState{
+			proxyAddress: {Root: types.NewHash(fmt.Sprintf("0x%x", blockNumber)), Storage: storage},
+		}, blockNumber))
+	}
+	storageAt(12, map[types.Hash]string{eip1967Slot: string(second)})
+	// an unrelated change does not record the same implementation again
+	storageAt(14, map[types.Hash]string{eip1967Slot: string(second), types.NewHash("0x1"): "2a"})
+	storageAt(15, map[types.Hash]string{types.NewHash("0x1"): "2a"})
+
+	pf := NewProxyFilter(db, client.NewStubQuorumClient(nil, mockRPC))
+	assert.Nil(t, pf.ProcessBlocks([]types.Address{proxyAddress}, 10, 15))
+	// the implementation is only recorded when it changes
+	assert.Nil(t, pf.ProcessBlocks([]types.Address{proxyAddress}, 16, 16))
+
+	implementations, err := db.GetProxyImplementations(proxyAddress)
+	assert.Nil(t, err)
+	assert.Equal(t, []*types.ProxyImplementation{
+		{Implementation: first, Standard: proxy.StandardEIP1967, BlockNumber: 10},
+		{Implementation: second, Standard: proxy.StandardEIP1967, BlockNumber: 12},
+		{Implementation: types.NewAddress(""), BlockNumber: 15},
+	}, implementations)
+}
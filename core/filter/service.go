@@ -7,6 +7,7 @@ import (
 
 	"quorumengineering/quorum-report/client"
 	"quorumengineering/quorum-report/core/filter/token"
+	"quorumengineering/quorum-report/core/proxy"
 	"quorumengineering/quorum-report/log"
 	"quorumengineering/quorum-report/types"
 )
@@ -14,7 +15,20 @@ import (
 //TODO: clean this type up, find a better way to pass specific methods to needed pieces
 type FilterServiceDB interface {
 	RecordNewERC20Balance(contract types.Address, holder types.Address, block uint64, amount *big.Int) error
+	GetERC20Balance(contract types.Address, holder types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error)
+	GetAllTokenHolders(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error)
+	RecordERC20Allowance(contract types.Address, owner types.Address, spender types.Address, block uint64, amount *big.Int) error
 	RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error
+	RecordTokenTransfers(transfers []types.TokenTransfer) error
+	RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error
+	ERC1155BalanceAtBlock(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) (*big.Int, error)
+	RecordTokenBalance(standard string, contract types.Address, holder types.Address, block uint64, amount *big.Int) error
+	TokenBalanceAtBlock(standard string, contract types.Address, holder types.Address, block uint64) (*big.Int, error)
+	RecordTokenInfo(info *types.TokenInfo) error
+	GetTokenInfo(contract types.Address) (*types.TokenInfo, error)
+	RecordTotalSupply(contract types.Address, block uint64, supply *big.Int) error
+	GetTotalSupply(contract types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error)
+	RollbackTokenRange(contract types.Address, fromBlock uint64, toBlock uint64) error
 
 	ReadTransaction(types.Hash) (*types.Transaction, error)
 	ReadBlock(uint64) (*types.Block, error)
@@ -23,35 +37,75 @@ type FilterServiceDB interface {
 
 	GetAddresses() ([]types.Address, error)
 	GetContractABI(types.Address) (string, error)
+	GetStorageLayout(types.Address) (string, error)
+	GetStorageWithOptions(types.Address, *types.PageOptions) ([]*types.StorageResult, error)
+
+	RecordProxyImplementation(types.Address, *types.ProxyImplementation) error
+	GetProxyImplementations(types.Address) ([]*types.ProxyImplementation, error)
+
+	RecordBackfillJob(*types.BackfillJob) error
+	GetBackfillJob(types.Address) (*types.BackfillJob, error)
+	GetBackfillJobs() ([]*types.BackfillJob, error)
+
+	RecordNativeBalance(address types.Address, block uint64, balance *big.Int) error
+	NativeBalanceAtBlock(address types.Address, block uint64) (*big.Int, error)
+
+	RecordTransactionFailures(failures []types.TransactionFailure) error
 
 	IndexBlocks([]types.Address, []*types.BlockWithTransactions) error
 	IndexStorage(map[types.Address]*types.AccountState, uint64) error
 	SetContractCreationTransaction(map[types.Hash][]types.Address) error
 }
 
+// BatchPublisher is notified of the blocks and addresses of each batch once it has been indexed
+type BatchPublisher interface {
+	Publish(addresses []types.Address, blocks []*types.BlockWithTransactions)
+}
+
 // FilterService filters transactions and storage based on registered address list.
 type FilterService struct {
 	db FilterServiceDB
 
 	storageFilter          *StorageFilter
 	contractCreationFilter *ContractCreationFilter
-	erc20processor         *token.ERC20Processor
-	erc721processor        *token.ERC721Processor
+	proxyFilter            *ProxyFilter
+	nativeBalanceFilter    *NativeBalanceFilter
+	failureFilter          *TransactionFailureFilter
+	tokenProcessors        []token.TokenProcessor
+	backfillProcessors     []token.TokenProcessor
+	publisher              BatchPublisher
+
+	// held while a batch is filtered, so that a chain reorg is not rolled back until the
+	// batch has finished, and no batch reads orphaned blocks
+	rollbackLock *sync.RWMutex
 
 	// To check we have actually shut down before returning
 	shutdownChan chan struct{}
 	shutdownWg   sync.WaitGroup
 }
 
-func NewFilterService(db FilterServiceDB, client client.Client) *FilterService {
+func NewFilterService(db FilterServiceDB, client client.Client, publisher BatchPublisher, config types.ReportingConfig, rollbackLock *sync.RWMutex) (*FilterService, error) {
+	tokenProcessors, err := token.NewProcessors(db, client, config.Tokens)
+	if err != nil {
+		return nil, err
+	}
+	backfillProcessors, err := token.NewProcessors(db, client, backfillTokenConfig(config.Tokens))
+	if err != nil {
+		return nil, err
+	}
 	return &FilterService{
 		db:                     db,
 		storageFilter:          NewStorageFilter(db, client),
 		contractCreationFilter: NewContractCreationFilter(db, client),
+		proxyFilter:            NewProxyFilter(db, client),
+		nativeBalanceFilter:    NewNativeBalanceFilter(db, client),
+		failureFilter:          NewTransactionFailureFilter(db),
 		shutdownChan:           make(chan struct{}),
-		erc20processor:         token.NewERC20Processor(db, client),
-		erc721processor:        token.NewERC721Processor(db),
-	}
+		tokenProcessors:        tokenProcessors,
+		backfillProcessors:     backfillProcessors,
+		publisher:              publisher,
+		rollbackLock:           rollbackLock,
+	}, nil
 }
 
 func (fs *FilterService) Start() error {
@@ -67,37 +121,15 @@ func (fs *FilterService) Start() error {
 		for {
 			select {
 			case <-ticker.C:
-				current, err := fs.db.GetLastPersistedBlockNumber()
-				if err != nil {
-					log.Warn("Fetching last persisted block number failed", "err", err)
-					continue
-				}
-				log.Debug("Last persisted block number found", "block number", current)
-				lastFilteredAll, lastFiltered, err := fs.getLastFiltered(current)
-				if err != nil {
-					log.Warn("Fetching last filtered failed", "err", err)
-					continue
-				}
-				for current > lastFiltered {
+				for fs.indexNextRange() {
 					//check if we are shutting down before next round
 					select {
 					case <-fs.shutdownChan:
 						return
 					default:
 					}
-					//index 1000 blocks at a time
-					//TODO: make configurable
-					endBlock := lastFiltered + 1000
-					if endBlock > current {
-						endBlock = current
-					}
-					err := fs.index(lastFilteredAll, lastFiltered+1, endBlock)
-					if err != nil {
-						log.Warn("Index block failed", "lastFiltered", lastFiltered, "err", err)
-						break
-					}
-					lastFiltered = endBlock
 				}
+				fs.backfill()
 			case <-fs.shutdownChan:
 				return
 			}
@@ -113,15 +145,58 @@ func (fs *FilterService) Stop() {
 	log.Info("Filter service stopped")
 }
 
-// getLastFiltered finds the minimum value of "lastFiltered" across all addresses
+// indexNextRange indexes the next range of blocks not yet filtered for all addresses, returning
+// whether there are more blocks left to index. The progress of each address is read again for
+// every range, with the rollback lock held, since a chain reorg may have rolled it back.
+func (fs *FilterService) indexNextRange() bool {
+	fs.rollbackLock.RLock()
+	defer fs.rollbackLock.RUnlock()
+
+	current, err := fs.db.GetLastPersistedBlockNumber()
+	if err != nil {
+		log.Warn("Fetching last persisted block number failed", "err", err)
+		return false
+	}
+	log.Debug("Last persisted block number found", "block number", current)
+	lastFilteredAll, lastFiltered, err := fs.getLastFiltered(current)
+	if err != nil {
+		log.Warn("Fetching last filtered failed", "err", err)
+		return false
+	}
+	if current <= lastFiltered {
+		return false
+	}
+
+	//index 1000 blocks at a time
+	//TODO: make configurable
+	endBlock := lastFiltered + 1000
+	if endBlock > current {
+		endBlock = current
+	}
+	if err := fs.index(lastFilteredAll, lastFiltered+1, endBlock); err != nil {
+		log.Warn("Index block failed", "lastFiltered", lastFiltered, "err", err)
+		return false
+	}
+	return endBlock < current
+}
+
+// getLastFiltered finds the minimum value of "lastFiltered" across all addresses, leaving
+// out those whose filtering is paused while their tokens are backfilled
 func (fs *FilterService) getLastFiltered(current uint64) (map[types.Address]uint64, uint64, error) {
 	addresses, err := fs.db.GetAddresses()
 	if err != nil {
 		return nil, current, err
 	}
+	backfilling, err := fs.runningBackfills()
+	if err != nil {
+		return nil, current, err
+	}
 
 	lastFiltered := make(map[types.Address]uint64)
 	for _, address := range addresses {
+		if backfilling[address] {
+			continue
+		}
 		curLastFiltered, err := fs.db.GetLastFiltered(address)
 		if err != nil {
 			return nil, current, err
@@ -200,33 +275,54 @@ func (fs *FilterService) processBatch(batch IndexBatch) error {
 		return err
 	}
 
-	// if IndexStorage has an error, IndexBlocks is never called, last filtered will not be updated
-	if err := fs.db.IndexBlocks(batch.addresses, batch.blocks); err != nil {
+	// proxies are detected from the indexed storage, and must be known before tokens are processed
+	if err := fs.proxyFilter.ProcessBlocks(batch.addresses, batch.blocks[0].Number, batch.blocks[len(batch.blocks)-1].Number); err != nil {
 		return err
 	}
 
-	if err := fs.contractCreationFilter.ProcessBlocks(batch.addresses, batch.blocks); err != nil {
+	if err := fs.nativeBalanceFilter.ProcessBlocks(batch.addresses, batch.blocks); err != nil {
 		return err
 	}
 
-	addressesWithAbi := make(map[types.Address]string)
-	for _, address := range batch.addresses {
-		abi, err := fs.db.GetContractABI(address)
-		if err != nil {
-			return err
-		}
-		addressesWithAbi[address] = abi
+	// failures are decoded with the ABI of the implementation, so proxies must be known first
+	if err := fs.failureFilter.ProcessBlocks(batch.addresses, batch.blocks); err != nil {
+		return err
+	}
+
+	if err := fs.contractCreationFilter.ProcessBlocks(batch.addresses, batch.blocks); err != nil {
+		return err
 	}
+
+	// proxies are processed with the ABI of their implementation at each block
+	resolver := proxy.NewResolver(fs.db)
 	for _, b := range batch.blocks {
-		if err := fs.erc20processor.ProcessBlock(addressesWithAbi, b); err != nil {
-			return err
+		addressesWithAbi := make(map[types.Address]string)
+		for _, address := range batch.addresses {
+			abi, err := resolver.ABI(address, b.Number)
+			if err != nil {
+				return err
+			}
+			addressesWithAbi[address] = abi
 		}
-		if err := fs.erc721processor.ProcessBlock(addressesWithAbi, b); err != nil {
-			return err
+		for _, processor := range fs.tokenProcessors {
+			if err := processor.ProcessBlock(addressesWithAbi, b); err != nil {
+				return err
+			}
 		}
 	}
 
+	// the blocks are marked as filtered last, so that if any step fails last filtered is not
+	// updated and the whole batch is processed again. Token balances derived from events are
+	// built on the balance before each block, and each block's writes replace those of an
+	// earlier attempt, so processing a block twice does not count its transfers twice.
+	if err := fs.db.IndexBlocks(batch.addresses, batch.blocks); err != nil {
+		return err
+	}
+
 	log.Info("Processed batch", "start", batch.blocks[0].Number, "end", batch.blocks[len(batch.blocks)-1].Number)
+	if fs.publisher != nil {
+		fs.publisher.Publish(batch.addresses, batch.blocks)
+	}
 	return nil
 }
 
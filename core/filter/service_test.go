@@ -2,12 +2,16 @@ package filter
 
 import (
 	"errors"
+	"fmt"
 	"math/big"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/core/proxy"
+	"quorumengineering/quorum-report/database/memory"
 	"quorumengineering/quorum-report/types"
 )
 
@@ -21,11 +25,21 @@ func TestIndexBlock(t *testing.T) {
 		"eth_storageRoot0x00000000000000000000000000000000000000020x5": types.NewHash("1"),
 		"eth_storageRoot0x00000000000000000000000000000000000000020x6": types.NewHash("1"),
 	}
+	// neither contract is a proxy
+	for _, address := range []types.Address{types.NewAddress("1"), types.NewAddress("2")} {
+		for blockNumber := 4; blockNumber <= 6; blockNumber++ {
+			for _, implementationSlot := range proxy.ImplementationSlots {
+				mockRPC[fmt.Sprintf("eth_getStorageAt%s%s0x%x", address.String(), implementationSlot.Slot.String(), blockNumber)] = types.NewHash("")
+			}
+		}
+	}
 	db := &FakeDB{
 		[]types.Address{types.NewAddress("1"), types.NewAddress("2")},
 		map[types.Address]uint64{types.NewAddress("1"): 3, types.NewAddress("2"): 5},
 	}
-	fs := NewFilterService(db, client.NewStubQuorumClient(nil, mockRPC))
+	publisher := &FakePublisher{}
+	fs, err := NewFilterService(db, client.NewStubQuorumClient(nil, mockRPC), publisher, types.ReportingConfig{}, &sync.RWMutex{})
+	assert.Nil(t, err)
 
 	// test fs.getLastFiltered
 	lastFilteredAll, lastFiltered, err := fs.getLastFiltered(6)
@@ -39,12 +53,93 @@ func TestIndexBlock(t *testing.T) {
 	assert.Nil(t, err)
 	assert.EqualValues(t, 4, db.lastFiltered[types.NewAddress("1")])
 	assert.EqualValues(t, 5, db.lastFiltered[types.NewAddress("2")])
+	assert.Len(t, publisher.batches, 1)
+	assert.EqualValues(t, 4, publisher.batches[0][0].Number)
 
 	// index multiple blocks
 	err = fs.index(lastFilteredAll, 5, 6)
 	assert.Nil(t, err)
 	assert.EqualValues(t, 6, db.lastFiltered[types.NewAddress("1")])
 	assert.EqualValues(t, 6, db.lastFiltered[types.NewAddress("2")])
+	assert.Len(t, publisher.batches, 3)
+}
+
+func TestIndexBlock_TokenProcessorFailsMidBatch(t *testing.T) {
+	contract := types.NewAddress("1")
+	mockRPC := map[string]interface{}{
+		fmt.Sprintf("eth_storageRoot%s0x3", contract.String()): types.NewHash("1"),
+	}
+	for blockNumber := 4; blockNumber <= 6; blockNumber++ {
+		mockRPC[fmt.Sprintf("eth_storageRoot%s0x%x", contract.String(), blockNumber)] = types.NewHash("1")
+		for _, implementationSlot := range proxy.ImplementationSlots {
+			mockRPC[fmt.Sprintf("eth_getStorageAt%s%s0x%x", contract.String(), implementationSlot.Slot.String(), blockNumber)] = types.NewHash("")
+		}
+	}
+	db := &FakeDB{
+		[]types.Address{contract},
+		map[types.Address]uint64{contract: 3},
+	}
+	publisher := &FakePublisher{}
+	fs, err := NewFilterService(db, client.NewStubQuorumClient(nil, mockRPC), publisher, types.ReportingConfig{}, &sync.RWMutex{})
+	assert.Nil(t, err)
+	tokenDB := memory.NewMemoryDB()
+	processor := &FakeEventTokenProcessor{db: tokenDB, holder: types.NewAddress("2"), failAt: 5}
+	fs.tokenProcessors = append(fs.tokenProcessors, processor)
+
+	// the processor fails at block 5 after recording its balance, so none of the batch is filtered
+	lastFilteredAll, _, err := fs.getLastFiltered(6)
+	assert.Nil(t, err)
+	err = fs.index(lastFilteredAll, 4, 6)
+	assert.EqualError(t, err, "processor failed")
+	assert.EqualValues(t, 3, db.lastFiltered[contract])
+	assert.Empty(t, publisher.batches)
+
+	// the whole batch is processed again, without counting block 4 or 5 twice
+	lastFilteredAll, _, err = fs.getLastFiltered(6)
+	assert.Nil(t, err)
+	err = fs.index(lastFilteredAll, 4, 6)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 6, db.lastFiltered[contract])
+	for blockNumber, expected := range map[uint64]int64{4: 1, 5: 2, 6: 3} {
+		balance, err := tokenDB.TokenBalanceAtBlock("fake", contract, processor.holder, blockNumber)
+		assert.Nil(t, err)
+		assert.Equal(t, big.NewInt(expected), balance, "block %d", blockNumber)
+	}
+}
+
+// FakeEventTokenProcessor derives a balance from the one before each block, like balances
+// derived from events, adding one for every block. It fails once at the given block, after
+// recording the balance of that block.
+type FakeEventTokenProcessor struct {
+	db     *memory.MemoryDB
+	holder types.Address
+	failAt uint64
+	failed bool
+}
+
+func (p *FakeEventTokenProcessor) ProcessBlock(lastFilteredWithAbi map[types.Address]string, block *types.BlockWithTransactions) error {
+	for contract := range lastFilteredWithAbi {
+		previous, err := p.db.TokenBalanceAtBlock("fake", contract, p.holder, block.Number-1)
+		if err != nil {
+			return err
+		}
+		if err := p.db.RecordTokenBalance("fake", contract, p.holder, block.Number, new(big.Int).Add(previous, big.NewInt(1))); err != nil {
+			return err
+		}
+	}
+	if block.Number == p.failAt && !p.failed {
+		p.failed = true
+		return errors.New("processor failed")
+	}
+	return nil
+}
+
+type FakePublisher struct {
+	batches [][]*types.BlockWithTransactions
+}
+
+func (f *FakePublisher) Publish(addresses []types.Address, blocks []*types.BlockWithTransactions) {
+	f.batches = append(f.batches, blocks)
 }
 
 type FakeDB struct {
@@ -96,14 +191,106 @@ func (f *FakeDB) RecordNewERC20Balance(contract types.Address, holder types.Addr
 	return errors.New("not implemented")
 }
 
+func (f *FakeDB) GetERC20Balance(contract types.Address, holder types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *FakeDB) GetAllTokenHolders(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *FakeDB) RecordERC20Allowance(contract types.Address, owner types.Address, spender types.Address, block uint64, amount *big.Int) error {
+	return errors.New("not implemented")
+}
+
 func (f *FakeDB) RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error {
 	return errors.New("not implemented")
 }
 
+func (f *FakeDB) RecordTokenTransfers(transfers []types.TokenTransfer) error {
+	return errors.New("not implemented")
+}
+
+func (f *FakeDB) RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error {
+	return errors.New("not implemented")
+}
+
+func (f *FakeDB) ERC1155BalanceAtBlock(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) (*big.Int, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *FakeDB) RecordTokenInfo(info *types.TokenInfo) error {
+	return errors.New("not implemented")
+}
+
+func (f *FakeDB) GetTokenInfo(contract types.Address) (*types.TokenInfo, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *FakeDB) RecordTotalSupply(contract types.Address, block uint64, supply *big.Int) error {
+	return errors.New("not implemented")
+}
+
+func (f *FakeDB) RecordTokenBalance(standard string, contract types.Address, holder types.Address, block uint64, amount *big.Int) error {
+	return errors.New("not implemented")
+}
+
+func (f *FakeDB) TokenBalanceAtBlock(standard string, contract types.Address, holder types.Address, block uint64) (*big.Int, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *FakeDB) GetTotalSupply(contract types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	return nil, errors.New("not implemented")
+}
+
 func (f *FakeDB) GetContractABI(types.Address) (string, error) {
 	return "{}", nil
 }
 
+func (f *FakeDB) GetStorageLayout(types.Address) (string, error) {
+	return "", nil
+}
+
+func (f *FakeDB) GetStorageWithOptions(types.Address, *types.PageOptions) ([]*types.StorageResult, error) {
+	return nil, nil
+}
+
+func (f *FakeDB) RecordProxyImplementation(types.Address, *types.ProxyImplementation) error {
+	return errors.New("not implemented")
+}
+
+func (f *FakeDB) GetProxyImplementations(types.Address) ([]*types.ProxyImplementation, error) {
+	return nil, nil
+}
+
 func (f *FakeDB) SetContractCreationTransaction(creationTxns map[types.Hash][]types.Address) error {
 	return nil
 }
+
+func (f *FakeDB) RollbackTokenRange(contract types.Address, fromBlock uint64, toBlock uint64) error {
+	return errors.New("not implemented")
+}
+
+func (f *FakeDB) RecordBackfillJob(*types.BackfillJob) error {
+	return errors.New("not implemented")
+}
+
+func (f *FakeDB) GetBackfillJob(types.Address) (*types.BackfillJob, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *FakeDB) GetBackfillJobs() ([]*types.BackfillJob, error) {
+	return nil, nil
+}
+
+func (f *FakeDB) RecordNativeBalance(address types.Address, block uint64, balance *big.Int) error {
+	return errors.New("not implemented")
+}
+
+func (f *FakeDB) NativeBalanceAtBlock(address types.Address, block uint64) (*big.Int, error) {
+	return big.NewInt(0), nil
+}
+
+func (f *FakeDB) RecordTransactionFailures(failures []types.TransactionFailure) error {
+	return errors.New("not implemented")
+}
@@ -0,0 +1,203 @@
+package token
+
+import (
+	"math/big"
+	"sort"
+
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+const erc1155AbiString = `[{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"_owner","type":"address"},{"indexed":true,"internalType":"address","name":"_operator","type":"address"},{"indexed":false,"internalType":"bool","name":"_approved","type":"bool"}],"name":"ApprovalForAll","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"_operator","type":"address"},{"indexed":true,"internalType":"address","name":"_from","type":"address"},{"indexed":true,"internalType":"address","name":"_to","type":"address"},{"indexed":false,"internalType":"uint256[]","name":"_ids","type":"uint256[]"},{"indexed":false,"internalType":"uint256[]","name":"_values","type":"uint256[]"}],"name":"TransferBatch","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"_operator","type":"address"},{"indexed":true,"internalType":"address","name":"_from","type":"address"},{"indexed":true,"internalType":"address","name":"_to","type":"address"},{"indexed":false,"internalType":"uint256","name":"_id","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"_value","type":"uint256"}],"name":"TransferSingle","type":"event"},{"anonymous":false,"inputs":[{"indexed":false,"internalType":"string","name":"_value","type":"string"},{"indexed":true,"internalType":"uint256","name":"_id","type":"uint256"}],"name":"URI","type":"event"},{"inputs":[{"internalType":"address","name":"_owner","type":"address"},{"internalType":"uint256","name":"_id","type":"uint256"}],"name":"balanceOf","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address[]","name":"_owners","type":"address[]"},{"internalType":"uint256[]","name":"_ids","type":"uint256[]"}],"name":"balanceOfBatch","outputs":[{"internalType":"uint256[]","name":"","type":"uint256[]"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"_owner","type":"address"},{"internalType":"address","name":"_operator","type":"address"}],"name":"isApprovedForAll","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"_from","type":"address"},{"internalType":"address","name":"_to","type":"address"},{"internalType":"uint256[]","name":"_ids","type":"uint256[]"},{"internalType":"uint256[]","name":"_values","type":"uint256[]"},{"internalType":"bytes","name":"_data","type":"bytes"}],"name":"safeBatchTransferFrom","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"_from","type":"address"},{"internalType":"address","name":"_to","type":"address"},{"internalType":"uint256","name":"_id","type":"uint256"},{"internalType":"uint256","name":"_value","type":"uint256"},{"internalType":"bytes","name":"_data","type":"bytes"}],"name":"safeTransferFrom","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"_operator","type":"address"},{"internalType":"bool","name":"_approved","type":"bool"}],"name":"setApprovalForAll","outputs":[],"stateMutability":"nonpayable","type":"function"}]`
+
+var (
+	// erc1155TransferSingleTopicHash is the topic hash for an ERC1155 TransferSingle event
+	erc1155TransferSingleTopicHash = types.NewHash("0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62")
+	// erc1155TransferBatchTopicHash is the topic hash for an ERC1155 TransferBatch event
+	erc1155TransferBatchTopicHash = types.NewHash("0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb")
+	erc1155Abi, _                 = types.NewABIStructureFromJSON(erc1155AbiString)
+)
+
+// erc1155Transfer is a single token ID moved by a TransferSingle or TransferBatch event
+type erc1155Transfer struct {
+	from    types.Address
+	to      types.Address
+	tokenId *big.Int
+	value   *big.Int
+}
+
+type ERC1155Processor struct {
+	db TokenFilterDatabase
+}
+
+func NewERC1155Processor(database TokenFilterDatabase) *ERC1155Processor {
+	return &ERC1155Processor{db: database}
+}
+
+// ProcessBlock records the new balance of every token ID that changed hands in the block.
+// The standard requires an event for every balance change, including mints and burns, so
+// balances are derived from the balance before the block and the transfers in it.
+func (p *ERC1155Processor) ProcessBlock(lastFilteredWithAbi map[types.Address]string, block *types.BlockWithTransactions) error {
+	erc1155Contracts := p.filterForErc1155Contracts(lastFilteredWithAbi)
+
+	events := make([]*types.Event, 0)
+	for _, tx := range block.Transactions {
+		events = append(events, tx.Events...)
+	}
+	erc1155Events := p.filterForErc1155Events(erc1155Contracts, events)
+
+	// changes are keyed by contract, holder and then token ID
+	changes := make(map[types.Address]map[types.Address]map[string]*big.Int)
+	addChange := func(contract types.Address, holder types.Address, tokenId *big.Int, value *big.Int) {
+		if changes[contract] == nil {
+			changes[contract] = make(map[types.Address]map[string]*big.Int)
+		}
+		if changes[contract][holder] == nil {
+			changes[contract][holder] = make(map[string]*big.Int)
+		}
+		if changes[contract][holder][tokenId.String()] == nil {
+			changes[contract][holder][tokenId.String()] = new(big.Int)
+		}
+		changes[contract][holder][tokenId.String()].Add(changes[contract][holder][tokenId.String()], value)
+	}
+	for _, event := range erc1155Events {
+		for _, transfer := range erc1155Transfers(event) {
+			addChange(event.Address, transfer.from, transfer.tokenId, new(big.Int).Neg(transfer.value))
+			addChange(event.Address, transfer.to, transfer.tokenId, transfer.value)
+		}
+	}
+
+	for contract, holders := range changes {
+		for holder, tokens := range holders {
+			// transfers from and to the zero address mint and burn tokens
+			if holder.IsEmpty() {
+				continue
+			}
+			for token, change := range tokens {
+				tokenId, _ := new(big.Int).SetString(token, 10)
+				balance := new(big.Int)
+				if block.Number > 0 {
+					previous, err := p.db.ERC1155BalanceAtBlock(contract, holder, block.Number-1, tokenId)
+					if err != nil {
+						return err
+					}
+					balance.Set(previous)
+				}
+				balance.Add(balance, change)
+				if balance.Sign() < 0 {
+					// the token was not indexed from its creation
+					log.Warn("ERC1155 balance derived from events is negative", "contract", contract.String(), "holder", holder.String(), "token", token, "block number", block.Number, "balance", balance)
+				}
+				if err := p.db.RecordERC1155Balance(contract, holder, block.Number, tokenId, balance); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// erc1155Transfers returns every token ID moved by a TransferSingle or TransferBatch event.
+// The token IDs and values are not indexed, so are read from the event data.
+func erc1155Transfers(event *types.Event) []erc1155Transfer {
+	from := types.NewAddress(string(event.Topics[2])[24:64])
+	to := types.NewAddress(string(event.Topics[3])[24:64])
+	data := event.Data.AsBytes()
+
+	if event.Topics[0] == erc1155TransferSingleTopicHash {
+		if len(data) < 64 {
+			return nil
+		}
+		return []erc1155Transfer{{from: from, to: to, tokenId: new(big.Int).SetBytes(data[:32]), value: new(big.Int).SetBytes(data[32:64])}}
+	}
+
+	if len(data) < 64 {
+		return nil
+	}
+	ids := decodeUint256Array(data, new(big.Int).SetBytes(data[:32]))
+	values := decodeUint256Array(data, new(big.Int).SetBytes(data[32:64]))
+	if len(ids) != len(values) {
+		return nil
+	}
+	transfers := make([]erc1155Transfer, 0, len(ids))
+	for i := range ids {
+		transfers = append(transfers, erc1155Transfer{from: from, to: to, tokenId: ids[i], value: values[i]})
+	}
+	return transfers
+}
+
+// decodeUint256Array reads the ABI encoded uint256[] found at the given offset of the data,
+// returning nil if the data is too short to hold it
+func decodeUint256Array(data []byte, offset *big.Int) []*big.Int {
+	if !offset.IsUint64() || offset.Uint64()+32 > uint64(len(data)) {
+		return nil
+	}
+	start := offset.Uint64()
+	length := new(big.Int).SetBytes(data[start : start+32])
+	if !length.IsUint64() || length.Uint64() > (uint64(len(data))-start-32)/32 {
+		return nil
+	}
+	result := make([]*big.Int, 0, length.Uint64())
+	for i := uint64(0); i < length.Uint64(); i++ {
+		elementStart := start + 32 + i*32
+		result = append(result, new(big.Int).SetBytes(data[elementStart:elementStart+32]))
+	}
+	return result
+}
+
+// filterForErc1155Events filters out all non-ERC1155 transfer events, returning
+// on the events we are interested in processing further
+func (p *ERC1155Processor) filterForErc1155Events(lastFiltered map[types.Address]bool, events []*types.Event) []*types.Event {
+	sortFunc := func(i, j int) bool { return events[i].Index < events[j].Index }
+	sort.Slice(events, sortFunc)
+
+	erc1155TransferEvents := make([]*types.Event, 0, len(events))
+	for _, event := range events {
+		isErc1155Transfer := (len(event.Topics) == 4) && (event.Topics[0] == erc1155TransferSingleTopicHash || event.Topics[0] == erc1155TransferBatchTopicHash)
+		if lastFiltered[event.Address] && isErc1155Transfer {
+			erc1155TransferEvents = append(erc1155TransferEvents, event)
+		}
+	}
+	return erc1155TransferEvents
+}
+
+func (p *ERC1155Processor) filterForErc1155Contracts(contractsWithAbi map[types.Address]string) map[types.Address]bool {
+	erc1155Contracts := make(map[types.Address]bool)
+
+	for address, abi := range contractsWithAbi {
+		contractAbi, _ := types.NewABIStructureFromJSON(abi)
+		if isErc1155(contractAbi) {
+			erc1155Contracts[address] = true
+		}
+	}
+
+	return erc1155Contracts
+}
+
+func isErc1155(contractAbi types.ABIStructure) bool {
+	for _, erc1155Event := range erc1155Abi.ToInternalABI().Events {
+		found := false
+		for _, contractEvent := range contractAbi.ToInternalABI().Events {
+			if erc1155Event.Signature() == contractEvent.Signature() {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, erc1155Method := range erc1155Abi.ToInternalABI().Functions {
+		found := false
+		for _, contractMethod := range contractAbi.ToInternalABI().Functions {
+			if erc1155Method.Signature() == contractMethod.Signature() {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,98 @@
+package token
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/types"
+)
+
+func TestIsErc1155(t *testing.T) {
+	assert.True(t, isErc1155(erc1155Abi))
+	assert.False(t, isErc1155(erc721Abi))
+	assert.False(t, isErc1155(erc20Abi))
+}
+
+func TestERC1155Processor_ProcessBlock(t *testing.T) {
+	tokenAddress := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	operator := types.NewAddress("0x9d13c6d3afe1721beef56b55d303b09e021e27ab")
+	holderA := types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+	holderB := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+	zero := types.NewAddress("")
+	transfer := func(topic types.Hash, from, to types.Address, index uint64, data string) *types.Event {
+		return &types.Event{
+			Data:    types.NewHexData(data),
+			Address: tokenAddress,
+			Index:   index,
+			Topics: []types.Hash{
+				topic,
+				types.Hash("000000000000000000000000" + operator),
+				types.Hash("000000000000000000000000" + from),
+				types.Hash("000000000000000000000000" + to),
+			},
+		}
+	}
+	blocks := []*types.BlockWithTransactions{
+		{
+			Number: 1,
+			Transactions: []*types.Transaction{
+				// mint 10 of token 1 and 5 of token 2
+				{Events: []*types.Event{transfer(erc1155TransferBatchTopicHash, zero, holderA, 0,
+					"0x0000000000000000000000000000000000000000000000000000000000000040"+
+						"00000000000000000000000000000000000000000000000000000000000000a0"+
+						"0000000000000000000000000000000000000000000000000000000000000002"+
+						"0000000000000000000000000000000000000000000000000000000000000001"+
+						"0000000000000000000000000000000000000000000000000000000000000002"+
+						"0000000000000000000000000000000000000000000000000000000000000002"+
+						"000000000000000000000000000000000000000000000000000000000000000a"+
+						"0000000000000000000000000000000000000000000000000000000000000005")}},
+			},
+		},
+		{
+			Number: 2,
+			Transactions: []*types.Transaction{
+				{Events: []*types.Event{
+					// move 3 of token 1 to B, then burn the rest of token 2
+					transfer(erc1155TransferSingleTopicHash, holderA, holderB, 0,
+						"0x0000000000000000000000000000000000000000000000000000000000000001"+
+							"0000000000000000000000000000000000000000000000000000000000000003"),
+					transfer(erc1155TransferSingleTopicHash, holderA, zero, 1,
+						"0x0000000000000000000000000000000000000000000000000000000000000002"+
+							"0000000000000000000000000000000000000000000000000000000000000005"),
+				}},
+			},
+		},
+	}
+
+	db := memory.NewMemoryDB()
+	processor := NewERC1155Processor(db)
+	for _, block := range blocks {
+		assert.Nil(t, processor.ProcessBlock(map[types.Address]string{tokenAddress: erc1155AbiString}, block))
+	}
+
+	balance, err := db.ERC1155BalanceAtBlock(tokenAddress, holderA, 1, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(10), balance)
+	balance, err = db.ERC1155BalanceAtBlock(tokenAddress, holderA, 2, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(7), balance)
+	balance, err = db.ERC1155BalanceAtBlock(tokenAddress, holderB, 2, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(3), balance)
+
+	options := &types.TokenQueryOptions{PageSize: 10}
+	tokens, err := db.ERC1155TokensForAccountAtBlock(tokenAddress, holderA, 1, options)
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 2)
+	tokens, err = db.ERC1155TokensForAccountAtBlock(tokenAddress, holderA, 2, options)
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 1)
+	assert.Equal(t, "7", tokens[0].Amount)
+
+	holders, err := db.ERC1155HoldersAtBlock(tokenAddress, 2, big.NewInt(2), options)
+	assert.Nil(t, err)
+	assert.Empty(t, holders)
+}
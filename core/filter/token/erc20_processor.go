@@ -4,29 +4,67 @@ import (
 	"math/big"
 
 	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/log"
 	"quorumengineering/quorum-report/types"
 )
 
+// reconcilePageSize is how many token holders are read at a time when reconciling balances
+const reconcilePageSize = 1000
+
 const erc20AbiString = `[{"constant":false,"inputs":[{"name":"_spender","type":"address"},{"name":"_value","type":"uint256"}],"name":"approve","outputs":[{"name":"success","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},{"constant":true,"inputs":[],"name":"totalSupply","outputs":[{"name":"supply","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":false,"inputs":[{"name":"_from","type":"address"},{"name":"_to","type":"address"},{"name":"_value","type":"uint256"}],"name":"transferFrom","outputs":[{"name":"success","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},{"constant":true,"inputs":[{"name":"_owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"balance","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":false,"inputs":[{"name":"_to","type":"address"},{"name":"_value","type":"uint256"}],"name":"transfer","outputs":[{"name":"success","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},{"constant":true,"inputs":[{"name":"_owner","type":"address"},{"name":"_spender","type":"address"}],"name":"allowance","outputs":[{"name":"remaining","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},{"anonymous":false,"inputs":[{"indexed":true,"name":"_from","type":"address"},{"indexed":true,"name":"_to","type":"address"},{"indexed":false,"name":"_value","type":"uint256"}],"name":"Transfer","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"name":"_owner","type":"address"},{"indexed":true,"name":"_spender","type":"address"},{"indexed":false,"name":"_value","type":"uint256"}],"name":"Approval","type":"event"}]`
 
 var (
 	// erc20TransferTopicHash is the topic hash for an ERC20 Transfer event
 	erc20TransferTopicHash = types.NewHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	// erc20ApprovalTopicHash is the topic hash for an ERC20 Approval event
+	erc20ApprovalTopicHash = types.NewHash("0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925")
 	erc20Abi, _            = types.NewABIStructureFromJSON(erc20AbiString)
 )
 
 type ERC20Processor struct {
-	db     TokenFilterDatabase
-	client client.Client
+	db           TokenFilterDatabase
+	client       client.Client
+	config       types.TokenConfig
+	infoRecorder *TokenInfoRecorder
 }
 
-func NewERC20Processor(database TokenFilterDatabase, client client.Client) *ERC20Processor {
-	return &ERC20Processor{db: database, client: client}
+func NewERC20Processor(database TokenFilterDatabase, client client.Client, config types.TokenConfig) *ERC20Processor {
+	return &ERC20Processor{
+		db:           database,
+		client:       client,
+		config:       config,
+		infoRecorder: NewTokenInfoRecorder(database, client, types.TokenStandardERC20),
+	}
 }
 
 func (p *ERC20Processor) ProcessBlock(lastFilteredWithAbi map[types.Address]string, block *types.BlockWithTransactions) error {
-	addressesWithChangedBalances := make(map[types.Address]map[types.Address]bool)
 	erc20Contracts := p.filterForErc20Contracts(lastFilteredWithAbi)
+	transferringContracts := make(map[types.Address]bool)
+	for _, tx := range block.Transactions {
+		for _, event := range p.filterForErc20Events(erc20Contracts, tx.Events) {
+			transferringContracts[event.Address] = true
+		}
+	}
+	if err := p.infoRecorder.RecordTokenInfo(transferringContracts, block.Number); err != nil {
+		return err
+	}
+	if err := p.RecordTransfers(erc20Contracts, block); err != nil {
+		return err
+	}
+	if err := p.RecordApprovals(erc20Contracts, block); err != nil {
+		return err
+	}
+	if err := p.UpdateTotalSupply(erc20Contracts, block); err != nil {
+		return err
+	}
+	if p.config.ERC20BalanceSource == types.ERC20EventsSource {
+		if err := p.UpdateBalancesFromEvents(erc20Contracts, block); err != nil {
+			return err
+		}
+		return p.reconcileBalances(erc20Contracts, block.Number)
+	}
+
+	addressesWithChangedBalances := make(map[types.Address]map[types.Address]bool)
 
 	for _, tx := range block.Transactions {
 		thisTxTokenChanges := p.ChangedTokenHolders(erc20Contracts, tx)
@@ -76,6 +114,225 @@ func (p *ERC20Processor) UpdateBalances(addressesWithChangedBalances map[types.A
 	return nil
 }
 
+// RecordTransfers stores every ERC20 transfer in the block
+func (p *ERC20Processor) RecordTransfers(erc20Contracts map[types.Address]bool, block *types.BlockWithTransactions) error {
+	var transfers []types.TokenTransfer
+	for _, tx := range block.Transactions {
+		for _, event := range p.filterForErc20Events(erc20Contracts, tx.Events) {
+			transfers = append(transfers, types.TokenTransfer{
+				Contract:        event.Address,
+				From:            types.NewAddress(string(event.Topics[1])[24:64]),
+				To:              types.NewAddress(string(event.Topics[2])[24:64]),
+				Amount:          transferValue(event).String(),
+				BlockNumber:     block.Number,
+				TransactionHash: event.TransactionHash,
+				LogIndex:        event.Index,
+				Timestamp:       block.Timestamp,
+			})
+		}
+	}
+	if len(transfers) == 0 {
+		return nil
+	}
+	return p.db.RecordTokenTransfers(transfers)
+}
+
+// UpdateTotalSupply records the total supply of each token that minted or burned tokens in
+// the block, which are transfers from and to the zero address. The supply is derived from
+// the events if the balances are, and otherwise read by calling totalSupply on the token.
+func (p *ERC20Processor) UpdateTotalSupply(erc20Contracts map[types.Address]bool, block *types.BlockWithTransactions) error {
+	changes := make(map[types.Address]*big.Int)
+	for _, tx := range block.Transactions {
+		for _, event := range p.filterForErc20Events(erc20Contracts, tx.Events) {
+			from := types.NewAddress(string(event.Topics[1])[24:64])
+			to := types.NewAddress(string(event.Topics[2])[24:64])
+			if !from.IsEmpty() && !to.IsEmpty() {
+				continue
+			}
+			if changes[event.Address] == nil {
+				changes[event.Address] = new(big.Int)
+			}
+			if from.IsEmpty() {
+				changes[event.Address].Add(changes[event.Address], transferValue(event))
+			}
+			if to.IsEmpty() {
+				changes[event.Address].Sub(changes[event.Address], transferValue(event))
+			}
+		}
+	}
+
+	for contract, change := range changes {
+		supply := new(big.Int)
+		if p.config.ERC20BalanceSource == types.ERC20EventsSource {
+			if change.Sign() == 0 {
+				continue
+			}
+			if block.Number > 0 {
+				previous, err := supplyAt(p.db, contract, block.Number-1)
+				if err != nil {
+					return err
+				}
+				supply.Set(previous)
+			}
+			supply.Add(supply, change)
+		} else {
+			res, err := client.CallWithoutArgs(p.client, contract, totalSupplyFunctionSig, block.Number)
+			if err != nil {
+				return err
+			}
+			supply.SetBytes(res.AsBytes())
+		}
+		if err := p.db.RecordTotalSupply(contract, block.Number, supply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// erc20Approval identifies the allowance an owner has given a spender of a token
+type erc20Approval struct {
+	contract types.Address
+	owner    types.Address
+	spender  types.Address
+}
+
+// RecordApprovals stores the allowances set by Approval events in the block. If an
+// allowance is set more than once in the block, only the last value is kept.
+func (p *ERC20Processor) RecordApprovals(erc20Contracts map[types.Address]bool, block *types.BlockWithTransactions) error {
+	allowances := make(map[erc20Approval]*big.Int)
+	for _, tx := range block.Transactions {
+		for _, event := range tx.Events {
+			isApproval := (len(event.Topics) == 3) && (event.Topics[0] == erc20ApprovalTopicHash)
+			if !erc20Contracts[event.Address] || !isApproval {
+				continue
+			}
+			approval := erc20Approval{
+				contract: event.Address,
+				owner:    types.NewAddress(string(event.Topics[1])[24:64]),
+				spender:  types.NewAddress(string(event.Topics[2])[24:64]),
+			}
+			allowances[approval] = transferValue(event)
+		}
+	}
+
+	for approval, amount := range allowances {
+		if err := p.db.RecordERC20Allowance(approval.contract, approval.owner, approval.spender, block.Number, amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateBalancesFromEvents derives the new balance of each token holder from their balance
+// before the block and the Transfer events in it. Transfers from and to the zero address
+// mint and burn tokens, so no balance is kept for it.
+func (p *ERC20Processor) UpdateBalancesFromEvents(erc20Contracts map[types.Address]bool, block *types.BlockWithTransactions) error {
+	changes := make(map[types.Address]map[types.Address]*big.Int)
+	addChange := func(contract types.Address, holder types.Address, value *big.Int) {
+		if changes[contract] == nil {
+			changes[contract] = make(map[types.Address]*big.Int)
+		}
+		if changes[contract][holder] == nil {
+			changes[contract][holder] = new(big.Int)
+		}
+		changes[contract][holder].Add(changes[contract][holder], value)
+	}
+
+	for _, tx := range block.Transactions {
+		for _, event := range p.filterForErc20Events(erc20Contracts, tx.Events) {
+			from := types.NewAddress(string(event.Topics[1])[24:64])
+			to := types.NewAddress(string(event.Topics[2])[24:64])
+			value := transferValue(event)
+			addChange(event.Address, from, new(big.Int).Neg(value))
+			addChange(event.Address, to, value)
+		}
+	}
+
+	for contract, holders := range changes {
+		for holder, change := range holders {
+			if holder.IsEmpty() {
+				continue
+			}
+			balance := new(big.Int)
+			if block.Number > 0 {
+				previous, err := p.balanceAt(contract, holder, block.Number-1)
+				if err != nil {
+					return err
+				}
+				balance.Set(previous)
+			}
+			balance.Add(balance, change)
+			if balance.Sign() < 0 {
+				// the token was not indexed from its creation, or moved tokens without an event
+				log.Warn("ERC20 balance derived from events is negative", "contract", contract.String(), "holder", holder.String(), "block number", block.Number, "balance", balance)
+			}
+			if err := p.db.RecordNewERC20Balance(contract, holder, block.Number, balance); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reconcileBalances checks the balances derived from events against balanceOf every
+// configured number of blocks, logging any that differ. A balance that cannot be read is
+// skipped, since the node may no longer have the state of the block.
+func (p *ERC20Processor) reconcileBalances(erc20Contracts map[types.Address]bool, blockNum uint64) error {
+	interval := p.config.ERC20ReconcileInterval
+	if interval == 0 || blockNum%interval != 0 {
+		return nil
+	}
+
+	for contract := range erc20Contracts {
+		options := &types.TokenQueryOptions{PageSize: reconcilePageSize}
+		options.SetDefaults()
+		for {
+			holders, err := p.db.GetAllTokenHolders(contract, blockNum, options)
+			if err != nil {
+				return err
+			}
+			for _, holder := range holders {
+				res, err := client.CallBalanceOfERC20(p.client, contract, holder, blockNum)
+				if err != nil {
+					log.Warn("Unable to reconcile ERC20 balance", "contract", contract.String(), "holder", holder.String(), "block number", blockNum, "err", err)
+					continue
+				}
+				actual := new(big.Int).SetBytes(res.AsBytes())
+				derived, err := p.balanceAt(contract, holder, blockNum)
+				if err != nil {
+					return err
+				}
+				if derived.Cmp(actual) != 0 {
+					log.Warn("ERC20 balance derived from events differs from balanceOf", "contract", contract.String(), "holder", holder.String(), "block number", blockNum, "derived", derived, "balanceOf", actual)
+				}
+			}
+			if len(holders) < options.PageSize {
+				break
+			}
+			options.After = holders[len(holders)-1].String()
+		}
+	}
+	return nil
+}
+
+// balanceAt returns the recorded balance of the token holder at the given block, which is
+// zero if they have never held the token
+func (p *ERC20Processor) balanceAt(contract types.Address, holder types.Address, block uint64) (*big.Int, error) {
+	blockNum := new(big.Int).SetUint64(block)
+	balances, err := p.db.GetERC20Balance(contract, holder, &types.TokenQueryOptions{
+		BeginBlockNumber: blockNum,
+		EndBlockNumber:   blockNum,
+		PageSize:         1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if balance, ok := balances[block]; ok {
+		return balance, nil
+	}
+	return big.NewInt(0), nil
+}
+
 // ChangedTokenHolders filters through all events in the transaction and
 // returns a list of all the token holders who have had a balance change
 func (p *ERC20Processor) ChangedTokenHolders(lastFilteredWithAbi map[types.Address]bool, tx *types.Transaction) map[types.Address]map[types.Address]bool {
@@ -118,6 +375,16 @@ func (p *ERC20Processor) filterForErc20Events(lastFiltered map[types.Address]boo
 	return erc20TransferEvents
 }
 
+// transferValue is the number of tokens moved by an ERC20 Transfer event, or approved by an
+// Approval event, which is the only non-indexed argument
+func transferValue(event *types.Event) *big.Int {
+	data := event.Data.AsBytes()
+	if len(data) > 32 {
+		data = data[:32]
+	}
+	return new(big.Int).SetBytes(data)
+}
+
 func isErc20(contractAbi types.ABIStructure) bool {
 	for _, erc20Event := range erc20Abi.ToInternalABI().Events {
 		found := false
@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/database/memory"
 	"quorumengineering/quorum-report/types"
 )
 
@@ -25,7 +26,7 @@ func TestERC20Processor_ProcessBlock_NoEventsDoesNothing(t *testing.T) {
 	}
 
 	db := NewFakeTestTokenDatabase(nil)
-	processor := NewERC20Processor(db, nil)
+	processor := NewERC20Processor(db, nil, types.TokenConfig{})
 
 	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc20AbiString}, block)
 
@@ -53,7 +54,7 @@ func TestERC20Processor_ProcessBlock_NoErc20Events(t *testing.T) {
 	}
 
 	db := NewFakeTestTokenDatabase(nil)
-	processor := NewERC20Processor(db, nil)
+	processor := NewERC20Processor(db, nil, types.TokenConfig{})
 
 	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc20AbiString}, block)
 
@@ -85,7 +86,7 @@ func TestERC20Processor_ProcessBlock_Erc20EventForNonTrackedAddress(t *testing.T
 	}
 
 	db := NewFakeTestTokenDatabase(nil)
-	processor := NewERC20Processor(db, nil)
+	processor := NewERC20Processor(db, nil, types.TokenConfig{})
 
 	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc20AbiString}, block)
 
@@ -121,7 +122,7 @@ func TestERC20Processor_ProcessBlock_SingleErc20Event(t *testing.T) {
 	stubClient := client.NewStubQuorumClient(nil, map[string]interface{}{
 		"eth_call<types.EIP165Call Value>0x1": types.NewHexData("0x12345"),
 	})
-	processor := NewERC20Processor(db, stubClient)
+	processor := NewERC20Processor(db, stubClient, types.TokenConfig{})
 
 	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc20AbiString}, block)
 
@@ -164,7 +165,7 @@ func TestERC20Processor_ProcessBlock_SingleErc20EventOnNonErc20Contract(t *testi
 	stubClient := client.NewStubQuorumClient(nil, map[string]interface{}{
 		"eth_call<types.EIP165Call Value>0x1": types.NewHexData("0x12345"),
 	})
-	processor := NewERC20Processor(db, stubClient)
+	processor := NewERC20Processor(db, stubClient, types.TokenConfig{})
 
 	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: `{}`}, block)
 
@@ -201,7 +202,7 @@ func TestERC20Processor_ProcessBlock_SingleErc20Event_WithClientError(t *testing
 
 	db := NewFakeTestTokenDatabase(nil)
 	stubClient := client.NewStubQuorumClient(nil, nil)
-	processor := NewERC20Processor(db, stubClient)
+	processor := NewERC20Processor(db, stubClient, types.TokenConfig{})
 
 	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc20AbiString}, block)
 
@@ -237,7 +238,7 @@ func TestERC20Processor_ProcessBlock_SingleErc20Event_WithDatabaseError(t *testi
 	stubClient := client.NewStubQuorumClient(nil, map[string]interface{}{
 		"eth_call<types.EIP165Call Value>0x1": types.NewHexData("0x12345"),
 	})
-	processor := NewERC20Processor(db, stubClient)
+	processor := NewERC20Processor(db, stubClient, types.TokenConfig{})
 
 	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc20AbiString}, block)
 
@@ -281,7 +282,7 @@ func TestERC20Processor_ProcessBlock_MultipleErc20Events(t *testing.T) {
 	stubClient := client.NewStubQuorumClient(nil, map[string]interface{}{
 		"eth_call<types.EIP165Call Value>0x1": types.NewHexData("0x12345"),
 	})
-	processor := NewERC20Processor(db, stubClient)
+	processor := NewERC20Processor(db, stubClient, types.TokenConfig{})
 
 	err := processor.ProcessBlock(map[types.Address]string{
 		types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34"): erc20AbiString,
@@ -303,3 +304,209 @@ func TestERC20Processor_ProcessBlock_MultipleErc20Events(t *testing.T) {
 	assert.EqualValues(t, db.RecordedToken[2], big.NewInt(4660)) //TODO: improve stub client to return different value for second account
 	assert.EqualValues(t, db.RecordedToken[3], big.NewInt(4660)) //TODO: improve stub client to return different value for second account
 }
+
+func TestERC20Processor_ProcessBlock_BalancesFromEvents(t *testing.T) {
+	tokenAddress := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	holderA := types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+	holderB := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+	holderC := types.NewAddress("0x9d13c6d3afe1721beef56b55d303b09e021e27ab")
+	transfer := func(from, to types.Address, value string) *types.Event {
+		return &types.Event{
+			Data:    types.NewHexData(value),
+			Address: tokenAddress,
+			Topics: []types.Hash{
+				"ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+				types.Hash("000000000000000000000000" + from),
+				types.Hash("000000000000000000000000" + to),
+			},
+		}
+	}
+	zero := types.NewAddress("")
+	blocks := []*types.BlockWithTransactions{
+		{
+			Number: 1,
+			Transactions: []*types.Transaction{
+				// mint
+				{Events: []*types.Event{transfer(zero, holderA, "0x00000000000000000000000000000000000000000000000000000000000003e8")}},
+			},
+		},
+		{
+			Number: 2,
+			Transactions: []*types.Transaction{
+				{Events: []*types.Event{transfer(holderA, holderB, "0x000000000000000000000000000000000000000000000000000000000000012c")}},
+				{Events: []*types.Event{
+					transfer(holderB, holderC, "0x0000000000000000000000000000000000000000000000000000000000000064"),
+					// burn
+					transfer(holderA, zero, "0x0000000000000000000000000000000000000000000000000000000000000032"),
+				}},
+			},
+		},
+	}
+
+	db := memory.NewMemoryDB()
+	// balanceOf is not available, so reconciliation is skipped
+	stubClient := client.NewStubQuorumClient(nil, map[string]interface{}{})
+	processor := NewERC20Processor(db, stubClient, types.TokenConfig{ERC20BalanceSource: types.ERC20EventsSource, ERC20ReconcileInterval: 2})
+	for _, block := range blocks {
+		assert.Nil(t, processor.ProcessBlock(map[types.Address]string{tokenAddress: erc20AbiString}, block))
+	}
+
+	options := &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(1), EndBlockNumber: big.NewInt(2)}
+	balances, err := db.GetERC20Balance(tokenAddress, holderA, options)
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(1000), 2: big.NewInt(650)}, balances)
+	balances, err = db.GetERC20Balance(tokenAddress, holderB, options)
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{2: big.NewInt(200)}, balances)
+	balances, err = db.GetERC20Balance(tokenAddress, holderC, options)
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{2: big.NewInt(100)}, balances)
+	balances, err = db.GetERC20Balance(tokenAddress, zero, options)
+	assert.Nil(t, err)
+	assert.Len(t, balances, 0)
+}
+
+func TestERC20Processor_ProcessBlock_RecordsTransfers(t *testing.T) {
+	tokenAddress := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	txHash := types.NewHash("0xf4f803b8d6c6b38e0b15d6cfe80fd1dcea4270ad24e93385fca36512bb9c2c59")
+	testBlock := &types.BlockWithTransactions{
+		Number:    1,
+		Timestamp: 1000,
+		Transactions: []*types.Transaction{
+			{
+				Hash: txHash,
+				Events: []*types.Event{
+					{
+						Index:           2,
+						Data:            types.NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8"),
+						Address:         tokenAddress,
+						TransactionHash: txHash,
+						Topics: []types.Hash{
+							"ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+							"000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d",
+							"0000000000000000000000001349f3e1b8d71effb47b840594ff27da7e603d17",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	db := memory.NewMemoryDB()
+	stubClient := client.NewStubQuorumClient(nil, map[string]interface{}{})
+	processor := NewERC20Processor(db, stubClient, types.TokenConfig{ERC20BalanceSource: types.ERC20EventsSource})
+
+	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc20AbiString}, testBlock)
+
+	assert.Nil(t, err)
+	options := &types.TokenTransferQueryOptions{}
+	options.SetDefaults()
+	transfers, err := db.GetTokenTransfers(&tokenAddress, nil, "", options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TokenTransfer{
+		{
+			Contract:        tokenAddress,
+			From:            types.NewAddress("ed9d02e382b34818e88b88a309c7fe71e65f419d"),
+			To:              types.NewAddress("1349f3e1b8d71effb47b840594ff27da7e603d17"),
+			Amount:          "1000",
+			BlockNumber:     1,
+			TransactionHash: txHash,
+			LogIndex:        2,
+			Timestamp:       1000,
+		},
+	}, transfers)
+}
+
+func TestERC20Processor_ProcessBlock_RecordsApprovals(t *testing.T) {
+	tokenAddress := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	owner := types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+	spender := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+	approvalTopics := []types.Hash{
+		"8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925",
+		"000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d",
+		"0000000000000000000000001349f3e1b8d71effb47b840594ff27da7e603d17",
+	}
+	// the allowance is set twice in the block, and only the last value is kept
+	testBlock := &types.BlockWithTransactions{
+		Number: 1,
+		Transactions: []*types.Transaction{
+			{
+				Events: []*types.Event{
+					{
+						Index:   1,
+						Data:    types.NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8"),
+						Address: tokenAddress,
+						Topics:  approvalTopics,
+					},
+				},
+			},
+			{
+				Events: []*types.Event{
+					{
+						Index:   3,
+						Data:    types.NewHexData("0x0000000000000000000000000000000000000000000000000000000000000032"),
+						Address: tokenAddress,
+						Topics:  approvalTopics,
+					},
+				},
+			},
+		},
+	}
+
+	db := memory.NewMemoryDB()
+	stubClient := client.NewStubQuorumClient(nil, map[string]interface{}{})
+	processor := NewERC20Processor(db, stubClient, types.TokenConfig{ERC20BalanceSource: types.ERC20EventsSource})
+
+	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc20AbiString}, testBlock)
+
+	assert.Nil(t, err)
+	allowance, err := db.ERC20AllowanceAtBlock(tokenAddress, owner, spender, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(50), allowance)
+	allowance, err = db.ERC20AllowanceAtBlock(tokenAddress, spender, owner, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(0), allowance)
+}
+
+func TestERC20Processor_ProcessBlock_TotalSupplyFromEvents(t *testing.T) {
+	tokenAddress := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	transferTopics := func(from string, to string) []types.Hash {
+		return []types.Hash{
+			"ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+			types.Hash("000000000000000000000000" + from),
+			types.Hash("000000000000000000000000" + to),
+		}
+	}
+	holder := "ed9d02e382b34818e88b88a309c7fe71e65f419d"
+	zero := "0000000000000000000000000000000000000000"
+	// 1000 tokens are minted, then 400 burned and another 100 moved between holders
+	blocks := []*types.BlockWithTransactions{
+		{
+			Number: 1,
+			Transactions: []*types.Transaction{{Events: []*types.Event{
+				{Index: 0, Address: tokenAddress, Topics: transferTopics(zero, holder), Data: types.NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8")},
+			}}},
+		},
+		{
+			Number: 2,
+			Transactions: []*types.Transaction{{Events: []*types.Event{
+				{Index: 0, Address: tokenAddress, Topics: transferTopics(holder, zero), Data: types.NewHexData("0x0000000000000000000000000000000000000000000000000000000000000190")},
+				{Index: 1, Address: tokenAddress, Topics: transferTopics(holder, "1349f3e1b8d71effb47b840594ff27da7e603d17"), Data: types.NewHexData("0x0000000000000000000000000000000000000000000000000000000000000064")},
+			}}},
+		},
+	}
+
+	db := memory.NewMemoryDB()
+	stubClient := client.NewStubQuorumClient(nil, map[string]interface{}{})
+	processor := NewERC20Processor(db, stubClient, types.TokenConfig{ERC20BalanceSource: types.ERC20EventsSource})
+
+	for _, block := range blocks {
+		assert.Nil(t, processor.ProcessBlock(map[types.Address]string{tokenAddress: erc20AbiString}, block))
+	}
+
+	options := &types.TokenQueryOptions{}
+	options.SetDefaults()
+	supplies, err := db.GetTotalSupply(tokenAddress, options)
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(1000), 2: big.NewInt(600)}, supplies)
+}
@@ -4,6 +4,7 @@ import (
 	"math/big"
 	"sort"
 
+	"quorumengineering/quorum-report/client"
 	"quorumengineering/quorum-report/types"
 )
 
@@ -16,11 +17,12 @@ var (
 )
 
 type ERC721Processor struct {
-	db TokenFilterDatabase
+	db           TokenFilterDatabase
+	infoRecorder *TokenInfoRecorder
 }
 
-func NewERC721Processor(database TokenFilterDatabase) *ERC721Processor {
-	return &ERC721Processor{db: database}
+func NewERC721Processor(database TokenFilterDatabase, client client.Client) *ERC721Processor {
+	return &ERC721Processor{db: database, infoRecorder: NewTokenInfoRecorder(database, client, types.TokenStandardERC721)}
 }
 
 func (p *ERC721Processor) ProcessBlock(lastFilteredWithAbi map[types.Address]string, block *types.BlockWithTransactions) error {
@@ -31,10 +33,82 @@ func (p *ERC721Processor) ProcessBlock(lastFilteredWithAbi map[types.Address]str
 		events = append(events, tx.Events...)
 	}
 	erc721Events := p.filterForErc721Events(erc721Contracts, events)
+	transferringContracts := make(map[types.Address]bool)
+	for _, erc721Event := range erc721Events {
+		transferringContracts[erc721Event.Address] = true
+	}
+	if err := p.infoRecorder.RecordTokenInfo(transferringContracts, block.Number); err != nil {
+		return err
+	}
+	if err := p.RecordTransfers(erc721Events, block); err != nil {
+		return err
+	}
+	if err := p.UpdateTotalSupply(erc721Events, block.Number); err != nil {
+		return err
+	}
 	mappedTokens := p.MapEventsToHolders(erc721Events)
 	return p.SaveTokenTransfers(mappedTokens, block.Number)
 }
 
+// RecordTransfers stores every transfer in the block, so that the owners a token had
+// within a single block are kept, even though ownership is only resolved per block
+func (p *ERC721Processor) RecordTransfers(erc721TransferEvents []*types.Event, block *types.BlockWithTransactions) error {
+	if len(erc721TransferEvents) == 0 {
+		return nil
+	}
+	transfers := make([]types.TokenTransfer, 0, len(erc721TransferEvents))
+	for _, erc721Event := range erc721TransferEvents {
+		convertedToken := types.NewHexData(erc721Event.Topics[3].String())
+		tokenId := new(big.Int).SetBytes(convertedToken.AsBytes())
+		transfers = append(transfers, types.TokenTransfer{
+			Contract:        erc721Event.Address,
+			From:            types.NewAddress(string(erc721Event.Topics[1])[24:64]),
+			To:              types.NewAddress(string(erc721Event.Topics[2])[24:64]),
+			TokenId:         tokenId.String(),
+			BlockNumber:     block.Number,
+			TransactionHash: erc721Event.TransactionHash,
+			LogIndex:        erc721Event.Index,
+			Timestamp:       block.Timestamp,
+		})
+	}
+	return p.db.RecordTokenTransfers(transfers)
+}
+
+// UpdateTotalSupply records the number of tokens in existence for each contract that minted
+// or burned tokens in the block, which are transfers from and to the zero address
+func (p *ERC721Processor) UpdateTotalSupply(erc721TransferEvents []*types.Event, blockNum uint64) error {
+	changes := make(map[types.Address]int64)
+	for _, erc721Event := range erc721TransferEvents {
+		from := types.NewAddress(string(erc721Event.Topics[1])[24:64])
+		to := types.NewAddress(string(erc721Event.Topics[2])[24:64])
+		if from.IsEmpty() {
+			changes[erc721Event.Address]++
+		}
+		if to.IsEmpty() {
+			changes[erc721Event.Address]--
+		}
+	}
+
+	for contract, change := range changes {
+		if change == 0 {
+			continue
+		}
+		supply := new(big.Int)
+		if blockNum > 0 {
+			previous, err := supplyAt(p.db, contract, blockNum-1)
+			if err != nil {
+				return err
+			}
+			supply.Set(previous)
+		}
+		supply.Add(supply, big.NewInt(change))
+		if err := p.db.RecordTotalSupply(contract, blockNum, supply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (p *ERC721Processor) SaveTokenTransfers(tokenTransfers map[types.Address]map[string]types.Address, blockNum uint64) error {
 	for contract, holderMap := range tokenTransfers {
 		for token, holder := range holderMap {
@@ -67,7 +141,8 @@ func (p *ERC721Processor) MapEventsToHolders(erc721TransferEvents []*types.Event
 
 		//this will overwrite the previous token holder, if there was another receiver
 		//of this token in this block
-		//this means the resolution of owning tokens is at the block level
+		//this means the resolution of owning tokens is at the block level, with the
+		//intermediate holders only kept in the transfer history
 		mappedTransfers[erc721Event.Address][tokenId] = recipientAddress
 	}
 	return mappedTransfers
@@ -7,6 +7,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"quorumengineering/quorum-report/client"
 	"quorumengineering/quorum-report/types"
 )
 
@@ -24,7 +25,7 @@ func TestERC721Processor_ProcessTransaction_NoEventsDoesNothing(t *testing.T) {
 	}
 
 	db := NewFakeTestTokenDatabase(nil)
-	processor := NewERC721Processor(db)
+	processor := NewERC721Processor(db, client.NewStubQuorumClient(nil, nil))
 
 	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc721AbiString}, testBlock)
 
@@ -52,7 +53,7 @@ func TestERC721Processor_ProcessTransaction_NoErc721Events(t *testing.T) {
 	}
 
 	db := NewFakeTestTokenDatabase(nil)
-	processor := NewERC721Processor(db)
+	processor := NewERC721Processor(db, client.NewStubQuorumClient(nil, nil))
 
 	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc721AbiString}, testBlock)
 
@@ -85,7 +86,7 @@ func TestERC721Processor_ProcessTransaction_Erc721EventForNonTrackedAddress(t *t
 	}
 
 	db := NewFakeTestTokenDatabase(nil)
-	processor := NewERC721Processor(db)
+	processor := NewERC721Processor(db, client.NewStubQuorumClient(nil, nil))
 
 	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc721AbiString}, testBlock)
 
@@ -119,7 +120,7 @@ func TestERC721Processor_ProcessTransaction_SingleErc721Event(t *testing.T) {
 	}
 
 	db := NewFakeTestTokenDatabase(nil)
-	processor := NewERC721Processor(db)
+	processor := NewERC721Processor(db, client.NewStubQuorumClient(nil, nil))
 
 	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc721AbiString}, testBlock)
 
@@ -158,7 +159,7 @@ func TestERC721Processor_ProcessTransaction_SingleErc721EventForNonErc721Contrac
 	}
 
 	db := NewFakeTestTokenDatabase(nil)
-	processor := NewERC721Processor(db)
+	processor := NewERC721Processor(db, client.NewStubQuorumClient(nil, nil))
 
 	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc20AbiString}, testBlock)
 
@@ -195,7 +196,7 @@ func TestERC721Processor_ProcessTransaction_SingleErc721Event_WithDatabaseError(
 	}
 
 	db := NewFakeTestTokenDatabase(errors.New("test error - database"))
-	processor := NewERC721Processor(db)
+	processor := NewERC721Processor(db, client.NewStubQuorumClient(nil, nil))
 
 	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc721AbiString}, testBlock)
 
@@ -237,7 +238,7 @@ func TestERC721Processor_ProcessTransaction_MultipleErc721Events(t *testing.T) {
 	}
 
 	db := NewFakeTestTokenDatabase(nil)
-	processor := NewERC721Processor(db)
+	processor := NewERC721Processor(db, client.NewStubQuorumClient(nil, nil))
 
 	err := processor.ProcessBlock(map[types.Address]string{
 		types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34"): erc721AbiString,
@@ -255,3 +256,115 @@ func TestERC721Processor_ProcessTransaction_MultipleErc721Events(t *testing.T) {
 	assert.EqualValues(t, big.NewInt(1), db.RecordedToken[0])
 	assert.EqualValues(t, big.NewInt(2), db.RecordedToken[1])
 }
+
+func TestERC721Processor_ProcessTransaction_TokenMovedTwiceInBlock(t *testing.T) {
+	tokenAddress := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	firstTxHash := types.NewHash("0xf4f803b8d6c6b38e0b15d6cfe80fd1dcea4270ad24e93385fca36512bb9c2c59")
+	secondTxHash := types.NewHash("0x4b4ec2ecac6f5b2a5fd6e4d5c1fc9b9b8bdf6ea8e2a5c2c35ea4ff3f8a9e4a33")
+	testBlock := &types.BlockWithTransactions{
+		Number:    1,
+		Timestamp: 1000,
+		Hash:      types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"),
+		Transactions: []*types.Transaction{
+			{
+				Hash: firstTxHash,
+				Events: []*types.Event{
+					{
+						Index:           0,
+						Address:         tokenAddress,
+						TransactionHash: firstTxHash,
+						Topics: []types.Hash{
+							"ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+							"000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d",
+							"0000000000000000000000001349f3e1b8d71effb47b840594ff27da7e603d17",
+							"0000000000000000000000000000000000000000000000000000000000000001",
+						},
+					},
+				},
+			},
+			{
+				Hash: secondTxHash,
+				Events: []*types.Event{
+					{
+						Index:           1,
+						Address:         tokenAddress,
+						TransactionHash: secondTxHash,
+						Topics: []types.Hash{
+							"ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+							"0000000000000000000000001349f3e1b8d71effb47b840594ff27da7e603d17",
+							"0000000000000000000000009d13c6d3afe1721beef56b55d303b09e021e27ab",
+							"0000000000000000000000000000000000000000000000000000000000000001",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	db := NewFakeTestTokenDatabase(nil)
+	processor := NewERC721Processor(db, client.NewStubQuorumClient(nil, nil))
+
+	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc721AbiString}, testBlock)
+
+	assert.Nil(t, err)
+	// ownership is resolved at the block level
+	assert.Equal(t, []types.Address{types.NewAddress("9d13c6d3afe1721beef56b55d303b09e021e27ab")}, db.RecordedHolder)
+	// but the intermediate holder is kept in the transfers
+	assert.Equal(t, []types.TokenTransfer{
+		{
+			Contract:        tokenAddress,
+			From:            types.NewAddress("ed9d02e382b34818e88b88a309c7fe71e65f419d"),
+			To:              types.NewAddress("1349f3e1b8d71effb47b840594ff27da7e603d17"),
+			TokenId:         "1",
+			BlockNumber:     1,
+			TransactionHash: firstTxHash,
+			LogIndex:        0,
+			Timestamp:       1000,
+		},
+		{
+			Contract:        tokenAddress,
+			From:            types.NewAddress("1349f3e1b8d71effb47b840594ff27da7e603d17"),
+			To:              types.NewAddress("9d13c6d3afe1721beef56b55d303b09e021e27ab"),
+			TokenId:         "1",
+			BlockNumber:     1,
+			TransactionHash: secondTxHash,
+			LogIndex:        1,
+			Timestamp:       1000,
+		},
+	}, db.RecordedTransfers)
+}
+
+func TestERC721Processor_ProcessBlock_TotalSupplyFromMintsAndBurns(t *testing.T) {
+	tokenAddress := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	transferEvent := func(from string, to string, tokenId string) *types.Event {
+		return &types.Event{
+			Address: tokenAddress,
+			Topics: []types.Hash{
+				"ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+				types.Hash("000000000000000000000000" + from),
+				types.Hash("000000000000000000000000" + to),
+				types.Hash(tokenId),
+			},
+		}
+	}
+	holder := "ed9d02e382b34818e88b88a309c7fe71e65f419d"
+	zero := "0000000000000000000000000000000000000000"
+	// three tokens are minted and one burned
+	testBlock := &types.BlockWithTransactions{
+		Number: 1,
+		Transactions: []*types.Transaction{{BlockNumber: 1, Events: []*types.Event{
+			transferEvent(zero, holder, "0000000000000000000000000000000000000000000000000000000000000001"),
+			transferEvent(zero, holder, "0000000000000000000000000000000000000000000000000000000000000002"),
+			transferEvent(zero, holder, "0000000000000000000000000000000000000000000000000000000000000003"),
+			transferEvent(holder, zero, "0000000000000000000000000000000000000000000000000000000000000002"),
+		}}},
+	}
+
+	db := NewFakeTestTokenDatabase(nil)
+	processor := NewERC721Processor(db, client.NewStubQuorumClient(nil, nil))
+
+	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc721AbiString}, testBlock)
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[types.Address]*big.Int{tokenAddress: big.NewInt(2)}, db.RecordedSupply)
+}
@@ -0,0 +1,277 @@
+package token
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// builtinEventStandards are the token standards that are processed from their events
+// alone. Only the events that move tokens are listed, so that ERC1400 tokens, which may
+// also emit the ERC20 and ERC1594 events for the same move, are not counted twice.
+var builtinEventStandards = map[string][]*types.TokenEventConfig{
+	"erc777": {
+		{Event: "Sent(address indexed operator, address indexed from, address indexed to, uint256 amount, bytes data, bytes operatorData)", From: "from", To: "to", Amount: "amount"},
+		{Event: "Minted(address indexed operator, address indexed to, uint256 amount, bytes data, bytes operatorData)", To: "to", Amount: "amount"},
+		{Event: "Burned(address indexed operator, address indexed from, uint256 amount, bytes data, bytes operatorData)", From: "from", Amount: "amount"},
+	},
+	"erc1400": {
+		{Event: "TransferByPartition(bytes32 indexed fromPartition, address operator, address indexed from, address indexed to, uint256 value, bytes data, bytes operatorData)", From: "from", To: "to", Amount: "value"},
+		{Event: "IssuedByPartition(bytes32 indexed partition, address indexed operator, address indexed to, uint256 amount, bytes data, bytes operatorData)", To: "to", Amount: "amount"},
+		{Event: "RedeemedByPartition(bytes32 indexed partition, address indexed operator, address indexed from, uint256 amount, bytes operatorData)", From: "from", Amount: "amount"},
+	},
+}
+
+// tokenEvent is an event that moves tokens, and the parameters holding who they are
+// moved from and to and the amount moved
+type tokenEvent struct {
+	abi          types.ContractABIEvent
+	from         string
+	to           string
+	amount       string
+	indexedCount int
+}
+
+// EventProcessor derives the balances of a token standard from the events that move its
+// tokens, storing them apart from the balances of every other standard
+type EventProcessor struct {
+	db       TokenFilterDatabase
+	standard string
+	events   map[types.Hash]*tokenEvent
+}
+
+func NewEventProcessor(database TokenFilterDatabase, standard string, events []*types.TokenEventConfig) (*EventProcessor, error) {
+	parsed := make(map[types.Hash]*tokenEvent)
+	for _, event := range events {
+		tokenEvent, err := newTokenEvent(event)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid event of token standard %v: %v", standard, err))
+		}
+		parsed[types.NewHash(tokenEvent.abi.Signature())] = tokenEvent
+	}
+	return &EventProcessor{db: database, standard: standard, events: parsed}, nil
+}
+
+// ProcessBlock records the new balance of every holder whose tokens were moved in the
+// block, derived from the balance before the block and the moves in it. Moves from and
+// to the zero address mint and burn tokens.
+func (p *EventProcessor) ProcessBlock(lastFilteredWithAbi map[types.Address]string, block *types.BlockWithTransactions) error {
+	contracts := p.filterForContracts(lastFilteredWithAbi)
+
+	events := make([]*types.Event, 0)
+	for _, tx := range block.Transactions {
+		events = append(events, tx.Events...)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Index < events[j].Index })
+
+	changes := make(map[types.Address]map[types.Address]*big.Int)
+	addChange := func(contract types.Address, holder types.Address, value *big.Int) {
+		if holder.IsEmpty() {
+			return
+		}
+		if changes[contract] == nil {
+			changes[contract] = make(map[types.Address]*big.Int)
+		}
+		if changes[contract][holder] == nil {
+			changes[contract][holder] = new(big.Int)
+		}
+		changes[contract][holder].Add(changes[contract][holder], value)
+	}
+	for _, event := range events {
+		if !contracts[event.Address] || len(event.Topics) == 0 {
+			continue
+		}
+		tokenEvent, ok := p.events[event.Topics[0]]
+		if !ok || len(event.Topics) != tokenEvent.indexedCount+1 {
+			continue
+		}
+		from, to, amount, ok := tokenEvent.decode(event)
+		if !ok {
+			log.Warn("Unable to decode token event", "standard", p.standard, "contract", event.Address.String(), "block number", block.Number)
+			continue
+		}
+		addChange(event.Address, from, new(big.Int).Neg(amount))
+		addChange(event.Address, to, amount)
+	}
+
+	for contract, holders := range changes {
+		for holder, change := range holders {
+			balance := new(big.Int)
+			if block.Number > 0 {
+				previous, err := p.db.TokenBalanceAtBlock(p.standard, contract, holder, block.Number-1)
+				if err != nil {
+					return err
+				}
+				balance.Set(previous)
+			}
+			balance.Add(balance, change)
+			if balance.Sign() < 0 {
+				// the token was not indexed from its creation
+				log.Warn("Token balance derived from events is negative", "standard", p.standard, "contract", contract.String(), "holder", holder.String(), "block number", block.Number, "balance", balance)
+			}
+			if err := p.db.RecordTokenBalance(p.standard, contract, holder, block.Number, balance); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// filterForContracts returns the contracts whose ABI declares every event of the standard
+// that moves tokens between two accounts, and any of its other events. Events that only mint
+// or burn tokens may be left out, as a token need not support minting or burning.
+func (p *EventProcessor) filterForContracts(contractsWithAbi map[types.Address]string) map[types.Address]bool {
+	contracts := make(map[types.Address]bool)
+	for address, abi := range contractsWithAbi {
+		contractAbi, _ := types.NewABIStructureFromJSON(abi)
+		declared := make(map[types.Hash]bool)
+		for _, event := range contractAbi.ToInternalABI().Events {
+			declared[types.NewHash(event.Signature())] = true
+		}
+		hasTransfers, hasAny := true, false
+		for signature, event := range p.events {
+			if event.isTransfer() {
+				hasTransfers = hasTransfers && declared[signature]
+			}
+			hasAny = hasAny || declared[signature]
+		}
+		if hasTransfers && hasAny {
+			contracts[address] = true
+		}
+	}
+	return contracts
+}
+
+func newTokenEvent(config *types.TokenEventConfig) (*tokenEvent, error) {
+	abi, err := parseEventDeclaration(config.Event)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &tokenEvent{abi: abi, from: config.From, to: config.To, amount: config.Amount}
+	paramTypes := make(map[string]string)
+	for _, input := range abi.Inputs {
+		if input.Indexed {
+			event.indexedCount++
+		}
+		if input.Name != "" {
+			paramTypes[input.Name] = input.Type
+		}
+	}
+
+	for _, param := range []string{config.From, config.To} {
+		if param != "" && paramTypes[param] != "address" {
+			return nil, errors.New(fmt.Sprintf("%v is not an address parameter of %v", param, abi.Name))
+		}
+	}
+	if !strings.HasPrefix(paramTypes[config.Amount], "uint") {
+		return nil, errors.New(fmt.Sprintf("%v is not an unsigned integer parameter of %v", config.Amount, abi.Name))
+	}
+	if config.From == "" && config.To == "" {
+		return nil, errors.New(fmt.Sprintf("%v moves tokens neither from nor to an account", abi.Name))
+	}
+	return event, nil
+}
+
+// isTransfer checks if the event moves tokens from one account to another, rather than
+// minting or burning them
+func (e *tokenEvent) isTransfer() bool {
+	return e.from != "" && e.to != ""
+}
+
+// decode reads who the event moves tokens from and to, and the amount moved, returning
+// false if the event does not hold them
+func (e *tokenEvent) decode(event *types.Event) (types.Address, types.Address, *big.Int, bool) {
+	data := event.Data.AsBytes()
+	words := make(map[string][]byte)
+	nextTopic := 1
+	offset := 0
+	for _, input := range e.abi.Inputs {
+		var word []byte
+		if input.Indexed {
+			word, _ = hex.DecodeString(string(event.Topics[nextTopic]))
+			nextTopic++
+		} else {
+			if offset+32 <= len(data) {
+				word = data[offset : offset+32]
+			}
+			size, err := input.HeadSize()
+			if err != nil {
+				return "", "", nil, false
+			}
+			offset += size
+		}
+		words[input.Name] = word
+	}
+
+	readAddress := func(param string) (types.Address, bool) {
+		if param == "" {
+			return "", true
+		}
+		word := words[param]
+		if len(word) != 32 {
+			return "", false
+		}
+		return types.NewAddress(hex.EncodeToString(word[12:])), true
+	}
+	from, fromOk := readAddress(e.from)
+	to, toOk := readAddress(e.to)
+	if !fromOk || !toOk || len(words[e.amount]) != 32 {
+		return "", "", nil, false
+	}
+	return from, to, new(big.Int).SetBytes(words[e.amount]), true
+}
+
+// parseEventDeclaration parses a Solidity event declaration, such as
+// "Transfer(address indexed from, address indexed to, uint256 value)". Tuple parameters
+// are not supported.
+func parseEventDeclaration(declaration string) (types.ContractABIEvent, error) {
+	declaration = strings.TrimSpace(declaration)
+	open := strings.Index(declaration, "(")
+	if open < 1 || !strings.HasSuffix(declaration, ")") {
+		return types.ContractABIEvent{}, errors.New(fmt.Sprintf("invalid event declaration: %v", declaration))
+	}
+	event := types.ContractABIEvent{Type: "event", Name: strings.TrimSpace(declaration[:open])}
+
+	params := strings.TrimSpace(declaration[open+1 : len(declaration)-1])
+	if strings.ContainsAny(params, "()") {
+		return types.ContractABIEvent{}, errors.New(fmt.Sprintf("tuple parameters are not supported: %v", declaration))
+	}
+	if params == "" {
+		return event, nil
+	}
+	for _, param := range strings.Split(params, ",") {
+		fields := strings.Fields(param)
+		if len(fields) == 0 || len(fields) > 3 || (len(fields) == 3 && fields[1] != "indexed") {
+			return types.ContractABIEvent{}, errors.New(fmt.Sprintf("invalid event parameter %q: %v", param, declaration))
+		}
+		input := types.ContractABIEventArgument{ContractABIArgument: types.ContractABIArgument{Type: canonicalType(fields[0])}}
+		switch {
+		case len(fields) == 3:
+			input.Indexed = true
+			input.Name = fields[2]
+		case len(fields) == 2 && fields[1] == "indexed":
+			input.Indexed = true
+		case len(fields) == 2:
+			input.Name = fields[1]
+		}
+		event.Inputs = append(event.Inputs, input)
+	}
+	return event, nil
+}
+
+// canonicalType expands the aliases uint and int to the types used in event signatures
+func canonicalType(paramType string) string {
+	for _, alias := range []string{"uint", "int"} {
+		if paramType == alias || strings.HasPrefix(paramType, alias+"[") {
+			return alias + "256" + paramType[len(alias):]
+		}
+	}
+	return paramType
+}
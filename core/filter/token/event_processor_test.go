@@ -0,0 +1,163 @@
+package token
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/types"
+)
+
+const pointsAbiString = `[{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"member","type":"address"},{"indexed":false,"internalType":"uint256","name":"points","type":"uint256"}],"name":"Awarded","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"from","type":"address"},{"indexed":true,"internalType":"address","name":"to","type":"address"},{"indexed":false,"internalType":"uint256","name":"points","type":"uint256"}],"name":"Moved","type":"event"}]`
+
+var pointsEvents = []*types.TokenEventConfig{
+	{Event: "Awarded(address indexed member, uint256 points)", To: "member", Amount: "points"},
+	{Event: "Moved(address indexed from, address indexed to, uint256 points)", From: "from", To: "to", Amount: "points"},
+}
+
+func TestParseEventDeclaration(t *testing.T) {
+	event, err := parseEventDeclaration("Moved(address indexed from, address indexed, uint amount, bytes)")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Moved", event.Name)
+	assert.Equal(t, []types.ContractABIEventArgument{
+		{ContractABIArgument: types.ContractABIArgument{Name: "from", Type: "address"}, Indexed: true},
+		{ContractABIArgument: types.ContractABIArgument{Type: "address"}, Indexed: true},
+		{ContractABIArgument: types.ContractABIArgument{Name: "amount", Type: "uint256"}},
+		{ContractABIArgument: types.ContractABIArgument{Type: "bytes"}},
+	}, event.Inputs)
+	assert.Equal(t, "Moved(address,address,uint256,bytes)", event.StringNoName())
+
+	for _, invalid := range []string{"", "Moved", "(address from)", "Moved(address from", "Moved(address from to)", "Moved((address,uint256) pair)"} {
+		_, err := parseEventDeclaration(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestNewEventProcessor_InvalidEvents(t *testing.T) {
+	invalid := []*types.TokenEventConfig{
+		{Event: "Awarded(address indexed member, uint256 points)", To: "recipient", Amount: "points"},
+		{Event: "Awarded(address indexed member, uint256 points)", To: "points", Amount: "points"},
+		{Event: "Awarded(address indexed member, int256 points)", To: "member", Amount: "points"},
+		{Event: "Awarded(address indexed member, uint256 points)", Amount: "points"},
+	}
+	for _, event := range invalid {
+		_, err := NewEventProcessor(nil, "points", []*types.TokenEventConfig{event})
+		assert.Error(t, err, event.Event)
+	}
+}
+
+func TestEventProcessor_ProcessBlock(t *testing.T) {
+	tokenAddress := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	otherAddress := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+	holder0 := types.Hash("000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d")
+	holder1 := types.Hash("000000000000000000000000ca843569e3427144cead5e4d5999a3d0ccf92b8e")
+	awarded := types.Hash("cd8ae3717c1e5ff5eae19e78399c4db942424faf048bca1b2774ac2a7c3d70a5")
+	moved := types.Hash("000e6e1d52477156459d942cdb0bc0970a404c1ea857ab5b75b5ad32f334dfc1")
+
+	blocks := []*types.BlockWithTransactions{
+		{
+			Number: 1,
+			Transactions: []*types.Transaction{{Events: []*types.Event{
+				{Index: 0, Address: tokenAddress, Topics: []types.Hash{awarded, holder0}, Data: types.NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8")},
+				// the same event from a contract that is not filtered is ignored
+				{Index: 1, Address: otherAddress, Topics: []types.Hash{awarded, holder0}, Data: types.NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8")},
+			}}},
+		},
+		{
+			Number: 2,
+			Transactions: []*types.Transaction{{Events: []*types.Event{
+				{Index: 0, Address: tokenAddress, Topics: []types.Hash{moved, holder0, holder1}, Data: types.NewHexData("0x0000000000000000000000000000000000000000000000000000000000000064")},
+				// malformed events are skipped
+				{Index: 1, Address: tokenAddress, Topics: []types.Hash{moved, holder0, holder1}, Data: types.NewHexData("0x64")},
+				{Index: 2, Address: tokenAddress, Topics: []types.Hash{moved, holder0}, Data: types.NewHexData("0x0000000000000000000000000000000000000000000000000000000000000064")},
+			}}},
+		},
+	}
+
+	db := memory.NewMemoryDB()
+	processor, err := NewEventProcessor(db, "points", pointsEvents)
+	assert.Nil(t, err)
+
+	for _, block := range blocks {
+		err := processor.ProcessBlock(map[types.Address]string{tokenAddress: pointsAbiString, otherAddress: erc20AbiString}, block)
+		assert.Nil(t, err)
+	}
+
+	balance, err := db.TokenBalanceAtBlock("points", tokenAddress, types.NewAddress(string(holder0)[24:]), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(1000), balance)
+	balance, err = db.TokenBalanceAtBlock("points", tokenAddress, types.NewAddress(string(holder0)[24:]), 2)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(900), balance)
+	balance, err = db.TokenBalanceAtBlock("points", tokenAddress, types.NewAddress(string(holder1)[24:]), 2)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(100), balance)
+	balance, err = db.TokenBalanceAtBlock("points", otherAddress, types.NewAddress(string(holder0)[24:]), 2)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(0), balance)
+}
+
+func TestEventProcessor_ProcessBlock_ERC777(t *testing.T) {
+	tokenAddress := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	operator := types.Hash("0000000000000000000000001349f3e1b8d71effb47b840594ff27da7e603d17")
+	holder := types.Hash("000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d")
+	minted := types.Hash("2fe5be0146f74c5bce36c0b80911af6c7d86ff27e89d5cfa61fc681327954e5d")
+	burned := types.Hash("a78a9be3a7b862d26933ad85fb11d80ef66b8f972d7cbba06621d583943a4098")
+	// the amount is followed by the offsets and contents of two empty byte arrays
+	mintData := types.NewHexData("0x" +
+		"00000000000000000000000000000000000000000000000000000000000003e8" +
+		"0000000000000000000000000000000000000000000000000000000000000060" +
+		"0000000000000000000000000000000000000000000000000000000000000080" +
+		"0000000000000000000000000000000000000000000000000000000000000000" +
+		"0000000000000000000000000000000000000000000000000000000000000000")
+	burnData := types.NewHexData("0x" +
+		"0000000000000000000000000000000000000000000000000000000000000190" +
+		"0000000000000000000000000000000000000000000000000000000000000060" +
+		"0000000000000000000000000000000000000000000000000000000000000080" +
+		"0000000000000000000000000000000000000000000000000000000000000000" +
+		"0000000000000000000000000000000000000000000000000000000000000000")
+	block := &types.BlockWithTransactions{
+		Number: 1,
+		Transactions: []*types.Transaction{{Events: []*types.Event{
+			{Index: 0, Address: tokenAddress, Topics: []types.Hash{minted, operator, holder}, Data: mintData},
+			{Index: 1, Address: tokenAddress, Topics: []types.Hash{burned, operator, holder}, Data: burnData},
+		}}},
+	}
+	erc777Abi := `[
+		{"anonymous":false,"inputs":[{"indexed":true,"name":"operator","type":"address"},{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"amount","type":"uint256"},{"indexed":false,"name":"data","type":"bytes"},{"indexed":false,"name":"operatorData","type":"bytes"}],"name":"Sent","type":"event"},
+		{"anonymous":false,"inputs":[{"indexed":true,"name":"operator","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"amount","type":"uint256"},{"indexed":false,"name":"data","type":"bytes"},{"indexed":false,"name":"operatorData","type":"bytes"}],"name":"Minted","type":"event"},
+		{"anonymous":false,"inputs":[{"indexed":true,"name":"operator","type":"address"},{"indexed":true,"name":"from","type":"address"},{"indexed":false,"name":"amount","type":"uint256"},{"indexed":false,"name":"data","type":"bytes"},{"indexed":false,"name":"operatorData","type":"bytes"}],"name":"Burned","type":"event"}
+	]`
+
+	db := memory.NewMemoryDB()
+	processors, err := NewProcessors(db, nil, types.TokenConfig{Standards: []string{"erc777"}})
+	assert.Nil(t, err)
+	assert.Len(t, processors, 1)
+
+	err = processors[0].ProcessBlock(map[types.Address]string{tokenAddress: erc777Abi}, block)
+
+	assert.Nil(t, err)
+	balance, err := db.TokenBalanceAtBlock("erc777", tokenAddress, types.NewAddress(string(holder)[24:]), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(600), balance)
+}
+
+func TestEventProcessor_FilterForContracts(t *testing.T) {
+	awardedOnly := `[{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"member","type":"address"},{"indexed":false,"internalType":"uint256","name":"points","type":"uint256"}],"name":"Awarded","type":"event"}]`
+	movedOnly := `[{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"from","type":"address"},{"indexed":true,"internalType":"address","name":"to","type":"address"},{"indexed":false,"internalType":"uint256","name":"points","type":"uint256"}],"name":"Moved","type":"event"}]`
+	contracts := map[types.Address]string{
+		types.NewAddress("0x1"): pointsAbiString,
+		types.NewAddress("0x2"): movedOnly,
+		types.NewAddress("0x3"): awardedOnly,
+		types.NewAddress("0x4"): erc20AbiString,
+	}
+
+	processor, err := NewEventProcessor(memory.NewMemoryDB(), "points", pointsEvents)
+	assert.Nil(t, err)
+
+	// a token that cannot be minted is still filtered, but one that cannot be moved is not
+	assert.Equal(t, map[types.Address]bool{types.NewAddress("0x1"): true, types.NewAddress("0x2"): true}, processor.filterForContracts(contracts))
+}
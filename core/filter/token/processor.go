@@ -0,0 +1,124 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/types"
+)
+
+// TokenProcessor records the tokens of a single token standard that were moved in each block
+type TokenProcessor interface {
+	ProcessBlock(lastFilteredWithAbi map[types.Address]string, block *types.BlockWithTransactions) error
+}
+
+// ProcessorFactory creates the processor of a token standard
+type ProcessorFactory func(database TokenFilterDatabase, client client.Client, config types.TokenConfig) (TokenProcessor, error)
+
+// DefaultStandards are the token standards processed if none are configured
+var DefaultStandards = []string{"erc20", "erc721", "erc1155"}
+
+var (
+	registryMux sync.RWMutex
+	registry    = map[string]ProcessorFactory{
+		"erc20": func(database TokenFilterDatabase, client client.Client, config types.TokenConfig) (TokenProcessor, error) {
+			return NewERC20Processor(database, client, config), nil
+		},
+		"erc721": func(database TokenFilterDatabase, client client.Client, config types.TokenConfig) (TokenProcessor, error) {
+			return NewERC721Processor(database, client), nil
+		},
+		"erc1155": func(database TokenFilterDatabase, client client.Client, config types.TokenConfig) (TokenProcessor, error) {
+			return NewERC1155Processor(database), nil
+		},
+	}
+)
+
+func init() {
+	for name, events := range builtinEventStandards {
+		name, events := name, events
+		registry[name] = func(database TokenFilterDatabase, client client.Client, config types.TokenConfig) (TokenProcessor, error) {
+			return NewEventProcessor(database, name, events)
+		}
+	}
+}
+
+// RegisterProcessor makes a token standard available to be enabled in the configuration.
+// It is an error to register a standard twice.
+func RegisterProcessor(name string, factory ProcessorFactory) error {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	if _, ok := registry[name]; ok {
+		return errors.New(fmt.Sprintf("token standard already registered: %v", name))
+	}
+	registry[name] = factory
+	return nil
+}
+
+// NewProcessors creates the processors of the configured token standards, in the order
+// they are configured. Custom standards defined in the configuration are processed from
+// their events.
+func NewProcessors(database TokenFilterDatabase, client client.Client, config types.TokenConfig) ([]TokenProcessor, error) {
+	custom := make(map[string]*types.CustomTokenConfig)
+	for _, standard := range config.Custom {
+		custom[standard.Name] = standard
+	}
+
+	standards := config.Standards
+	if len(standards) == 0 {
+		standards = DefaultStandards
+	}
+
+	registryMux.RLock()
+	defer registryMux.RUnlock()
+	processors := make([]TokenProcessor, 0, len(standards))
+	enabled := make(map[string]bool)
+	for _, name := range standards {
+		if enabled[name] {
+			return nil, errors.New(fmt.Sprintf("token standard enabled twice: %v", name))
+		}
+		enabled[name] = true
+
+		factory, isRegistered := registry[name]
+		customStandard, isCustom := custom[name]
+		if isRegistered && isCustom {
+			return nil, errors.New(fmt.Sprintf("custom token standard has the name of a built in standard: %v", name))
+		}
+
+		var (
+			processor TokenProcessor
+			err       error
+		)
+		switch {
+		case isCustom:
+			processor, err = NewEventProcessor(database, name, customStandard.Events)
+		case isRegistered:
+			processor, err = factory(database, client, config)
+		default:
+			return nil, errors.New(fmt.Sprintf("unknown token standard: %v", name))
+		}
+		if err != nil {
+			return nil, err
+		}
+		processors = append(processors, processor)
+	}
+	return processors, nil
+}
+
+// EventStandards returns the configured token standards that are processed from their
+// events, either built in or custom, which are each served under their own RPC namespace
+func EventStandards(config types.TokenConfig) []string {
+	custom := make(map[string]bool)
+	for _, standard := range config.Custom {
+		custom[standard.Name] = true
+	}
+
+	var standards []string
+	for _, name := range config.Standards {
+		if _, ok := builtinEventStandards[name]; ok || custom[name] {
+			standards = append(standards, name)
+		}
+	}
+	return standards
+}
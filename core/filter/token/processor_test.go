@@ -0,0 +1,74 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/types"
+)
+
+func TestNewProcessors_Defaults(t *testing.T) {
+	processors, err := NewProcessors(NewFakeTestTokenDatabase(nil), nil, types.TokenConfig{})
+
+	assert.Nil(t, err)
+	assert.Len(t, processors, 3)
+	assert.IsType(t, &ERC20Processor{}, processors[0])
+	assert.IsType(t, &ERC721Processor{}, processors[1])
+	assert.IsType(t, &ERC1155Processor{}, processors[2])
+}
+
+func TestNewProcessors_ConfiguredStandards(t *testing.T) {
+	config := types.TokenConfig{
+		Standards: []string{"points", "erc1155", "erc1400"},
+		Custom:    []*types.CustomTokenConfig{{Name: "points", Events: pointsEvents}},
+	}
+
+	processors, err := NewProcessors(NewFakeTestTokenDatabase(nil), nil, config)
+
+	assert.Nil(t, err)
+	assert.Len(t, processors, 3)
+	assert.Equal(t, "points", processors[0].(*EventProcessor).standard)
+	assert.IsType(t, &ERC1155Processor{}, processors[1])
+	assert.Equal(t, "erc1400", processors[2].(*EventProcessor).standard)
+	assert.Equal(t, []string{"points", "erc1400"}, EventStandards(config))
+}
+
+func TestNewProcessors_InvalidStandards(t *testing.T) {
+	configs := []types.TokenConfig{
+		{Standards: []string{"erc20", "unknown"}},
+		{Standards: []string{"erc20", "erc20"}},
+		{Standards: []string{"erc777"}, Custom: []*types.CustomTokenConfig{{Name: "erc777", Events: pointsEvents}}},
+		{Standards: []string{"points"}, Custom: []*types.CustomTokenConfig{{Name: "points", Events: []*types.TokenEventConfig{{Event: "Awarded", To: "member", Amount: "points"}}}}},
+	}
+	for _, config := range configs {
+		_, err := NewProcessors(NewFakeTestTokenDatabase(nil), nil, config)
+		assert.Error(t, err, "%v", config.Standards)
+	}
+}
+
+type fakeProcessor struct{}
+
+func (p *fakeProcessor) ProcessBlock(lastFilteredWithAbi map[types.Address]string, block *types.BlockWithTransactions) error {
+	return nil
+}
+
+func TestRegisterProcessor(t *testing.T) {
+	factory := func(database TokenFilterDatabase, client client.Client, config types.TokenConfig) (TokenProcessor, error) {
+		return &fakeProcessor{}, nil
+	}
+
+	assert.Nil(t, RegisterProcessor("fake", factory))
+	defer func() {
+		registryMux.Lock()
+		delete(registry, "fake")
+		registryMux.Unlock()
+	}()
+	assert.Error(t, RegisterProcessor("fake", factory))
+	assert.Error(t, RegisterProcessor("erc20", factory))
+
+	processors, err := NewProcessors(NewFakeTestTokenDatabase(nil), nil, types.TokenConfig{Standards: []string{"fake"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []TokenProcessor{&fakeProcessor{}}, processors)
+}
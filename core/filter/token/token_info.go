@@ -0,0 +1,129 @@
+package token
+
+import (
+	"bytes"
+	"math/big"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// 4byte function sigs of the token metadata functions
+const (
+	nameFunctionSig        = "0x06fdde03"
+	symbolFunctionSig      = "0x95d89b41"
+	decimalsFunctionSig    = "0x313ce567"
+	totalSupplyFunctionSig = "0x18160ddd"
+)
+
+// TokenInfoRecorder reads the metadata of each token the first time it transfers tokens,
+// and stores it if not already recorded
+type TokenInfoRecorder struct {
+	db       TokenFilterDatabase
+	client   client.Client
+	standard string
+	seen     map[types.Address]bool
+}
+
+func NewTokenInfoRecorder(database TokenFilterDatabase, client client.Client, standard string) *TokenInfoRecorder {
+	return &TokenInfoRecorder{db: database, client: client, standard: standard, seen: make(map[types.Address]bool)}
+}
+
+// RecordTokenInfo reads the name, symbol and decimals of each token not yet recorded. The
+// metadata functions are optional, so a field is left empty if its call fails; if every
+// call fails, nothing is stored, so that it is tried again when the service restarts.
+func (r *TokenInfoRecorder) RecordTokenInfo(contracts map[types.Address]bool, blockNum uint64) error {
+	for contract := range contracts {
+		if r.seen[contract] {
+			continue
+		}
+		if _, err := r.db.GetTokenInfo(contract); err != database.ErrNotFound {
+			if err != nil {
+				return err
+			}
+			r.seen[contract] = true
+			continue
+		}
+		r.seen[contract] = true
+
+		info := &types.TokenInfo{Contract: contract, Standard: r.standard}
+		read := false
+		if name, err := client.CallWithoutArgs(r.client, contract, nameFunctionSig, blockNum); err == nil {
+			info.Name = decodeString(name.AsBytes())
+			read = true
+		}
+		if symbol, err := client.CallWithoutArgs(r.client, contract, symbolFunctionSig, blockNum); err == nil {
+			info.Symbol = decodeString(symbol.AsBytes())
+			read = true
+		}
+		if r.standard == types.TokenStandardERC20 {
+			if decimals, err := client.CallWithoutArgs(r.client, contract, decimalsFunctionSig, blockNum); err == nil {
+				info.Decimals = decodeDecimals(decimals.AsBytes())
+				read = true
+			}
+		}
+		if !read {
+			log.Warn("Unable to read token metadata", "contract", contract.String(), "block number", blockNum)
+			continue
+		}
+
+		if err := r.db.RecordTokenInfo(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// supplyAt returns the recorded total supply of the token at the given block, which is
+// zero if none has been recorded
+func supplyAt(db TokenFilterDatabase, contract types.Address, block uint64) (*big.Int, error) {
+	blockNum := new(big.Int).SetUint64(block)
+	supplies, err := db.GetTotalSupply(contract, &types.TokenQueryOptions{
+		BeginBlockNumber: blockNum,
+		EndBlockNumber:   blockNum,
+		PageSize:         1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if supply, ok := supplies[block]; ok {
+		return supply, nil
+	}
+	return big.NewInt(0), nil
+}
+
+// decodeString decodes the result of a call returning a string. Some older tokens return a
+// bytes32 instead, which is trimmed of its trailing zero bytes.
+func decodeString(data []byte) string {
+	if len(data) == 32 {
+		return string(bytes.TrimRight(data, "\x00"))
+	}
+	if len(data) < 64 {
+		return ""
+	}
+	offset := new(big.Int).SetBytes(data[:32])
+	if !offset.IsUint64() || offset.Uint64() > uint64(len(data)-32) {
+		return ""
+	}
+	start := offset.Uint64() + 32
+	length := new(big.Int).SetBytes(data[offset.Uint64():start])
+	if !length.IsUint64() || length.Uint64() > uint64(len(data))-start {
+		return ""
+	}
+	return string(data[start : start+length.Uint64()])
+}
+
+// decodeDecimals decodes the result of a call to `decimals()`, which must fit in a uint8
+func decodeDecimals(data []byte) *uint8 {
+	if len(data) != 32 {
+		return nil
+	}
+	value := new(big.Int).SetBytes(data)
+	if !value.IsUint64() || value.Uint64() > 255 {
+		return nil
+	}
+	decimals := uint8(value.Uint64())
+	return &decimals
+}
@@ -0,0 +1,71 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/types"
+)
+
+func TestDecodeString(t *testing.T) {
+	encoded := types.NewHexData("0x" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000005" +
+		"546f6b656e000000000000000000000000000000000000000000000000000000")
+	assert.Equal(t, "Token", decodeString(encoded.AsBytes()))
+
+	bytes32 := types.NewHexData("0x544b4e0000000000000000000000000000000000000000000000000000000000")
+	assert.Equal(t, "TKN", decodeString(bytes32.AsBytes()))
+
+	// the length runs past the end of the data
+	malformed := types.NewHexData("0x" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000040" +
+		"546f6b656e000000000000000000000000000000000000000000000000000000")
+	assert.Equal(t, "", decodeString(malformed.AsBytes()))
+	assert.Equal(t, "", decodeString(nil))
+}
+
+func TestDecodeDecimals(t *testing.T) {
+	encoded := types.NewHexData("0x0000000000000000000000000000000000000000000000000000000000000012")
+	decimals := decodeDecimals(encoded.AsBytes())
+	assert.NotNil(t, decimals)
+	assert.EqualValues(t, 18, *decimals)
+
+	tooLarge := types.NewHexData("0x0000000000000000000000000000000000000000000000000000000000000100")
+	assert.Nil(t, decodeDecimals(tooLarge.AsBytes()))
+	assert.Nil(t, decodeDecimals(nil))
+}
+
+func TestTokenInfoRecorder_RecordTokenInfo(t *testing.T) {
+	tokenAddress := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	// the stub answers every call with the same result
+	mockRPC := map[string]interface{}{
+		"eth_call<types.EIP165Call Value>0x1": types.NewHexData("0x544b4e0000000000000000000000000000000000000000000000000000000000"),
+	}
+	db := memory.NewMemoryDB()
+	recorder := NewTokenInfoRecorder(db, client.NewStubQuorumClient(nil, mockRPC), types.TokenStandardERC721)
+
+	err := recorder.RecordTokenInfo(map[types.Address]bool{tokenAddress: true}, 1)
+
+	assert.Nil(t, err)
+	info, err := db.GetTokenInfo(tokenAddress)
+	assert.Nil(t, err)
+	assert.Equal(t, &types.TokenInfo{Contract: tokenAddress, Standard: types.TokenStandardERC721, Name: "TKN", Symbol: "TKN"}, info)
+}
+
+func TestTokenInfoRecorder_RecordTokenInfo_AllCallsFail(t *testing.T) {
+	tokenAddress := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	db := memory.NewMemoryDB()
+	recorder := NewTokenInfoRecorder(db, client.NewStubQuorumClient(nil, nil), types.TokenStandardERC20)
+
+	err := recorder.RecordTokenInfo(map[types.Address]bool{tokenAddress: true}, 1)
+
+	assert.Nil(t, err)
+	_, err = db.GetTokenInfo(tokenAddress)
+	assert.Equal(t, database.ErrNotFound, err)
+}
@@ -8,5 +8,17 @@ import (
 
 type TokenFilterDatabase interface {
 	RecordNewERC20Balance(contract types.Address, holder types.Address, block uint64, amount *big.Int) error
+	GetERC20Balance(contract types.Address, holder types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error)
+	GetAllTokenHolders(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error)
+	RecordERC20Allowance(contract types.Address, owner types.Address, spender types.Address, block uint64, amount *big.Int) error
 	RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error
+	RecordTokenTransfers(transfers []types.TokenTransfer) error
+	RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error
+	ERC1155BalanceAtBlock(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) (*big.Int, error)
+	RecordTokenBalance(standard string, contract types.Address, holder types.Address, block uint64, amount *big.Int) error
+	TokenBalanceAtBlock(standard string, contract types.Address, holder types.Address, block uint64) (*big.Int, error)
+	RecordTokenInfo(info *types.TokenInfo) error
+	GetTokenInfo(contract types.Address) (*types.TokenInfo, error)
+	RecordTotalSupply(contract types.Address, block uint64, supply *big.Int) error
+	GetTotalSupply(contract types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error)
 }
@@ -1,7 +1,10 @@
 package token
 
 import (
+	"errors"
 	"math/big"
+
+	"quorumengineering/quorum-report/database"
 	"quorumengineering/quorum-report/types"
 )
 
@@ -18,6 +21,10 @@ type FakeTestTokenDatabase struct {
 	RecordedHolder   []types.Address
 	RecordedBlock    uint64
 	RecordedToken    []*big.Int
+
+	RecordedTransfers []types.TokenTransfer
+	RecordedInfo      []types.TokenInfo
+	RecordedSupply    map[types.Address]*big.Int
 }
 
 func (db *FakeTestTokenDatabase) RecordNewERC20Balance(contract types.Address, holder types.Address, block uint64, amount *big.Int) error {
@@ -31,6 +38,18 @@ func (db *FakeTestTokenDatabase) RecordNewERC20Balance(contract types.Address, h
 	return nil
 }
 
+func (db *FakeTestTokenDatabase) GetERC20Balance(contract types.Address, holder types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (db *FakeTestTokenDatabase) GetAllTokenHolders(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (db *FakeTestTokenDatabase) RecordERC20Allowance(contract types.Address, owner types.Address, spender types.Address, block uint64, amount *big.Int) error {
+	return errors.New("not implemented")
+}
+
 func (db *FakeTestTokenDatabase) RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error {
 	if db.testErr != nil {
 		return db.testErr
@@ -41,3 +60,54 @@ func (db *FakeTestTokenDatabase) RecordERC721Token(contract types.Address, holde
 	db.RecordedToken = append(db.RecordedToken, tokenId)
 	return nil
 }
+
+func (db *FakeTestTokenDatabase) RecordTokenTransfers(transfers []types.TokenTransfer) error {
+	if db.testErr != nil {
+		return db.testErr
+	}
+	db.RecordedTransfers = append(db.RecordedTransfers, transfers...)
+	return nil
+}
+
+func (db *FakeTestTokenDatabase) RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error {
+	return errors.New("not implemented")
+}
+
+func (db *FakeTestTokenDatabase) ERC1155BalanceAtBlock(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) (*big.Int, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (db *FakeTestTokenDatabase) RecordTokenBalance(standard string, contract types.Address, holder types.Address, block uint64, amount *big.Int) error {
+	return errors.New("not implemented")
+}
+
+func (db *FakeTestTokenDatabase) TokenBalanceAtBlock(standard string, contract types.Address, holder types.Address, block uint64) (*big.Int, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (db *FakeTestTokenDatabase) RecordTokenInfo(info *types.TokenInfo) error {
+	if db.testErr != nil {
+		return db.testErr
+	}
+	db.RecordedInfo = append(db.RecordedInfo, *info)
+	return nil
+}
+
+func (db *FakeTestTokenDatabase) GetTokenInfo(contract types.Address) (*types.TokenInfo, error) {
+	return nil, database.ErrNotFound
+}
+
+func (db *FakeTestTokenDatabase) RecordTotalSupply(contract types.Address, block uint64, supply *big.Int) error {
+	if db.testErr != nil {
+		return db.testErr
+	}
+	if db.RecordedSupply == nil {
+		db.RecordedSupply = make(map[types.Address]*big.Int)
+	}
+	db.RecordedSupply[contract] = supply
+	return nil
+}
+
+func (db *FakeTestTokenDatabase) GetTotalSupply(contract types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	return map[uint64]*big.Int{}, nil
+}
@@ -1,6 +1,7 @@
 package monitor
 
 import (
+	"sync"
 	"time"
 
 	"quorumengineering/quorum-report/database"
@@ -26,9 +27,11 @@ type BatchWriter struct {
 
 	BatchWorkChan chan *BlockAndTransactions
 	db            database.Database
+	// pendingBlocks is marked done for every block once it is written
+	pendingBlocks *sync.WaitGroup
 }
 
-func NewBatchWriter(db database.Database, batchWorkChan chan *BlockAndTransactions, flushPeriod int) *BatchWriter {
+func NewBatchWriter(db database.Database, batchWorkChan chan *BlockAndTransactions, flushPeriod int, pendingBlocks *sync.WaitGroup) *BatchWriter {
 	return &BatchWriter{
 		maxBlocks:               cap(batchWorkChan),
 		maxTransactions:         maxTransactionMultiplier * cap(batchWorkChan),
@@ -37,6 +40,7 @@ func NewBatchWriter(db database.Database, batchWorkChan chan *BlockAndTransactio
 		currentTransactionCount: 0,
 		BatchWorkChan:           batchWorkChan,
 		db:                      db,
+		pendingBlocks:           pendingBlocks,
 	}
 }
 
@@ -82,9 +86,11 @@ func (bw *BatchWriter) BatchWrite() error {
 
 	allTxns := make([]*types.Transaction, 0, bw.currentTransactionCount)
 	allBlocks := make([]*types.Block, 0, len(bw.currentWorkUnits))
+	var allNativeTransfers []types.NativeTransfer
 	for _, workUnit := range bw.currentWorkUnits {
 		allTxns = append(allTxns, workUnit.txs...)
 		allBlocks = append(allBlocks, workUnit.block)
+		allNativeTransfers = append(allNativeTransfers, nativeTransfers(workUnit.txs)...)
 	}
 
 	log.Info("Batch writing blocks and transactions", "block count", len(allBlocks), "tx count", len(allTxns))
@@ -92,10 +98,17 @@ func (bw *BatchWriter) BatchWrite() error {
 	if err := bw.db.WriteTransactions(allTxns); err != nil {
 		return err
 	}
+	if err := bw.db.RecordNativeTransfers(allNativeTransfers); err != nil {
+		return err
+	}
 	if err := bw.db.WriteBlocks(allBlocks); err != nil {
 		return err
 	}
 
+	for range bw.currentWorkUnits {
+		bw.pendingBlocks.Done()
+	}
+
 	// reset
 	bw.currentTransactionCount = 0
 	bw.currentWorkUnits = make([]*BlockAndTransactions, 0, bw.maxBlocks)
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/database"
 	"quorumengineering/quorum-report/log"
 	"quorumengineering/quorum-report/types"
 )
@@ -14,17 +15,32 @@ type BlockMonitor interface {
 	SyncHistoricBlocks(lastPersisted uint64, cancelChan chan bool, wg *sync.WaitGroup) error
 }
 
+// recentHashWindow is the number of most recently sent block hashes kept to detect reorgs
+// without querying the database
+const recentHashWindow = 128
+
 type DefaultBlockMonitor struct {
 	quorumClient client.Client
+	db           database.BlockDB
 	newBlockChan chan *types.Block
 	consensus    string
+
+	// reorg detection
+	pendingBlocks *sync.WaitGroup
+	rollback      func(*types.ChainReorg) error
+	recentHashes  map[uint64]types.Hash
+	reorgMux      sync.Mutex
 }
 
-func NewDefaultBlockMonitor(quorumClient client.Client, newBlockChan chan *types.Block, consensus string) *DefaultBlockMonitor {
+func NewDefaultBlockMonitor(quorumClient client.Client, db database.BlockDB, newBlockChan chan *types.Block, consensus string, pendingBlocks *sync.WaitGroup, rollback func(*types.ChainReorg) error) *DefaultBlockMonitor {
 	return &DefaultBlockMonitor{
-		quorumClient: quorumClient,
-		newBlockChan: newBlockChan,
-		consensus:    consensus,
+		quorumClient:  quorumClient,
+		db:            db,
+		newBlockChan:  newBlockChan,
+		consensus:     consensus,
+		pendingBlocks: pendingBlocks,
+		rollback:      rollback,
+		recentHashes:  make(map[uint64]types.Hash),
 	}
 }
 
@@ -81,7 +97,14 @@ func (bm *DefaultBlockMonitor) processChainHead(header types.RawHeader) {
 		log.Error("Error - fetching block from Quorum failed", "block hash", header.Hash, "block number", header.Number, "err", err)
 		return
 	}
-	bm.newBlockChan <- bm.createBlock(blockOrigin)
+
+	bm.reorgMux.Lock()
+	defer bm.reorgMux.Unlock()
+	if err := bm.checkForReorg(blockOrigin, nil); err != nil {
+		log.Error("Error - handling chain reorg failed", "block hash", header.Hash, "block number", header.Number, "err", err)
+		return
+	}
+	bm.sendBlock(blockOrigin, nil)
 }
 
 func (bm *DefaultBlockMonitor) createBlock(block *types.RawBlock) *types.Block {
@@ -123,10 +146,15 @@ func (bm *DefaultBlockMonitor) syncBlocks(start, end uint64, stopChan chan bool)
 			return NewSyncError(err.Error(), i)
 		}
 
-		select {
-		case <-stopChan:
+		bm.reorgMux.Lock()
+		if err := bm.checkForReorg(blockOrigin, stopChan); err != nil {
+			bm.reorgMux.Unlock()
+			return NewSyncError(err.Error(), i)
+		}
+		sent := bm.sendBlock(blockOrigin, stopChan)
+		bm.reorgMux.Unlock()
+		if !sent {
 			return nil
-		case bm.newBlockChan <- bm.createBlock(blockOrigin):
 		}
 	}
 
@@ -134,6 +162,109 @@ func (bm *DefaultBlockMonitor) syncBlocks(start, end uint64, stopChan chan bool)
 	return nil
 }
 
+// sendBlock passes the block on for processing, returning false if it was cancelled by the stop channel.
+// The reorg lock must be held by the caller.
+func (bm *DefaultBlockMonitor) sendBlock(blockOrigin *types.RawBlock, stopChan chan bool) bool {
+	block := bm.createBlock(blockOrigin)
+	bm.pendingBlocks.Add(1)
+	select {
+	case <-stopChan:
+		bm.pendingBlocks.Done()
+		return false
+	case bm.newBlockChan <- block:
+	}
+
+	bm.recentHashes[block.Number] = block.Hash
+	if block.Number >= recentHashWindow {
+		delete(bm.recentHashes, block.Number-recentHashWindow)
+	}
+	return true
+}
+
+// knownHash returns the hash of the block at the given height as last seen by the monitor,
+// or false if the block has not been seen yet.
+func (bm *DefaultBlockMonitor) knownHash(number uint64) (types.Hash, bool) {
+	if hash, ok := bm.recentHashes[number]; ok {
+		return hash, true
+	}
+	block, err := bm.db.ReadBlock(number)
+	if err != nil || block == nil {
+		return "", false
+	}
+	return block.Hash, true
+}
+
+// checkForReorg verifies that the given block and its parent match the blocks that have been
+// processed at those heights. If not, a reorg has happened; all data after the common
+// ancestor is rolled back and the canonical blocks up to the given block are re-imported.
+// The reorg lock must be held by the caller.
+func (bm *DefaultBlockMonitor) checkForReorg(blockOrigin *types.RawBlock, stopChan chan bool) error {
+	number := blockOrigin.Number.ToUint64()
+	if number == 0 {
+		return nil
+	}
+	// either the block itself replaces a processed block, or its parent does
+	reorged := false
+	if hash, ok := bm.knownHash(number); ok && hash != blockOrigin.Hash {
+		reorged = true
+	}
+	if parentHash, ok := bm.knownHash(number - 1); ok && parentHash != blockOrigin.ParentHash {
+		reorged = true
+	}
+	if !reorged {
+		return nil
+	}
+
+	ancestor, err := bm.findCommonAncestor(number-1, blockOrigin.ParentHash)
+	if err != nil {
+		return err
+	}
+	reorg := &types.ChainReorg{
+		CommonAncestor: ancestor,
+		NewHead:        number,
+		NewHeadHash:    blockOrigin.Hash,
+		DetectedAt:     uint64(time.Now().Unix()),
+	}
+	log.Warn("Chain reorg detected, rolling back", "common ancestor", ancestor, "new head", number, "new head hash", blockOrigin.Hash.String())
+	if err := bm.rollback(reorg); err != nil {
+		return err
+	}
+	for height := range bm.recentHashes {
+		if height > ancestor {
+			delete(bm.recentHashes, height)
+		}
+	}
+
+	// re-import the blocks between the common ancestor and the new block
+	for i := ancestor + 1; i < number; i++ {
+		canonicalBlock, err := bm.tryFetchingBlock(i, 10)
+		if err != nil {
+			return err
+		}
+		if !bm.sendBlock(canonicalBlock, stopChan) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// findCommonAncestor walks back from the given height until the canonical block matches
+// the block that has been processed, or no processed block is known.
+func (bm *DefaultBlockMonitor) findCommonAncestor(number uint64, canonicalHash types.Hash) (uint64, error) {
+	for number > 0 {
+		if knownHash, ok := bm.knownHash(number); !ok || knownHash == canonicalHash {
+			return number, nil
+		}
+		canonicalBlock, err := bm.tryFetchingBlock(number, 10)
+		if err != nil {
+			return 0, err
+		}
+		canonicalHash = canonicalBlock.ParentHash
+		number--
+	}
+	return 0, nil
+}
+
 func (bm *DefaultBlockMonitor) tryFetchingBlock(number uint64, tryCount int) (*types.RawBlock, error) {
 	var err error
 	var block types.RawBlock
@@ -1,11 +1,13 @@
 package monitor
 
 import (
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/database/memory"
 	"quorumengineering/quorum-report/types"
 )
 
@@ -84,7 +86,7 @@ func TestCreateBlock(t *testing.T) {
 	}
 
 	for _, tc := range cases {
-		bm := NewDefaultBlockMonitor(client.NewStubQuorumClient(nil, nil), nil, tc.consensus)
+		bm := NewDefaultBlockMonitor(client.NewStubQuorumClient(nil, nil), nil, nil, tc.consensus, nil, nil)
 
 		actual := bm.createBlock(tc.originalBlock)
 
@@ -95,3 +97,71 @@ func TestCreateBlock(t *testing.T) {
 		assert.EqualValues(t, len(tc.expectedBlock.Transactions), len(actual.Transactions))
 	}
 }
+
+func TestSyncBlocks_ChainReorg(t *testing.T) {
+	db := memory.NewMemoryDB()
+	_ = db.WriteBlocks([]*types.Block{
+		{Number: 1, Hash: types.NewHash("0xa1")},
+		{Number: 2, Hash: types.NewHash("0xa2"), ParentHash: types.NewHash("0xa1")},
+		{Number: 3, Hash: types.NewHash("0xa3"), ParentHash: types.NewHash("0xa2")},
+	})
+
+	// blocks 2 and 3 have been replaced on the canonical chain
+	mockRPC := map[string]interface{}{
+		"eth_getBlockByNumber0x2<bool Value>": types.RawBlock{Number: 2, Hash: types.NewHash("0xb2"), ParentHash: types.NewHash("0xa1")},
+		"eth_getBlockByNumber0x3<bool Value>": types.RawBlock{Number: 3, Hash: types.NewHash("0xb3"), ParentHash: types.NewHash("0xb2")},
+		"eth_getBlockByNumber0x4<bool Value>": types.RawBlock{Number: 4, Hash: types.NewHash("0xb4"), ParentHash: types.NewHash("0xb3")},
+	}
+
+	var (
+		pendingBlocks sync.WaitGroup
+		reorgs        []*types.ChainReorg
+	)
+	rollback := func(reorg *types.ChainReorg) error {
+		reorgs = append(reorgs, reorg)
+		return db.RollbackBlocks(reorg)
+	}
+	newBlockChan := make(chan *types.Block, 10)
+	bm := NewDefaultBlockMonitor(client.NewStubQuorumClient(nil, mockRPC), db, newBlockChan, "istanbul", &pendingBlocks, rollback)
+
+	err := bm.syncBlocks(4, 4, make(chan bool))
+	assert.Nil(t, err)
+
+	assert.Len(t, reorgs, 1)
+	assert.EqualValues(t, 1, reorgs[0].CommonAncestor)
+	assert.EqualValues(t, 4, reorgs[0].NewHead)
+	assert.Equal(t, types.NewHash("0xb4"), reorgs[0].NewHeadHash)
+
+	lastPersisted, _ := db.GetLastPersistedBlockNumber()
+	assert.EqualValues(t, 1, lastPersisted)
+
+	// the canonical blocks after the common ancestor are re-imported
+	close(newBlockChan)
+	var sent []types.Hash
+	for block := range newBlockChan {
+		sent = append(sent, block.Hash)
+		pendingBlocks.Done()
+	}
+	assert.Equal(t, []types.Hash{types.NewHash("0xb2"), types.NewHash("0xb3"), types.NewHash("0xb4")}, sent)
+}
+
+func TestSyncBlocks_NoReorg(t *testing.T) {
+	db := memory.NewMemoryDB()
+	_ = db.WriteBlocks([]*types.Block{{Number: 1, Hash: types.NewHash("0xa1")}})
+
+	mockRPC := map[string]interface{}{
+		"eth_getBlockByNumber0x2<bool Value>": types.RawBlock{Number: 2, Hash: types.NewHash("0xa2"), ParentHash: types.NewHash("0xa1")},
+	}
+
+	var pendingBlocks sync.WaitGroup
+	rollback := func(reorg *types.ChainReorg) error {
+		t.Fatal("unexpected rollback")
+		return nil
+	}
+	newBlockChan := make(chan *types.Block, 10)
+	bm := NewDefaultBlockMonitor(client.NewStubQuorumClient(nil, mockRPC), db, newBlockChan, "istanbul", &pendingBlocks, rollback)
+
+	err := bm.syncBlocks(2, 2, make(chan bool))
+	assert.Nil(t, err)
+	assert.Len(t, newBlockChan, 1)
+}
@@ -0,0 +1,59 @@
+package monitor
+
+import (
+	"sort"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// valueCallTypes are the internal call types that move native currency from the caller to
+// the callee. Delegate and static calls never move value, and the value of a CALLCODE
+// stays with the caller.
+var valueCallTypes = map[string]bool{
+	"CALL":         true,
+	"CREATE":       true,
+	"CREATE2":      true,
+	"SELFDESTRUCT": true,
+}
+
+// nativeTransfers derives the native currency transfers of a block from the value of its
+// successful transactions and their internal calls, indexed in the order they took place.
+// Calls that were reverted, even if the transaction itself succeeded, move nothing.
+func nativeTransfers(txs []*types.Transaction) []types.NativeTransfer {
+	ordered := make([]*types.Transaction, len(txs))
+	copy(ordered, txs)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Index < ordered[j].Index })
+
+	var transfers []types.NativeTransfer
+	for _, tx := range ordered {
+		if !tx.Status {
+			continue
+		}
+		newTransfer := func(from types.Address, to types.Address, value *types.BigInt, callType string) types.NativeTransfer {
+			return types.NativeTransfer{
+				From:            from,
+				To:              to,
+				Value:           value.String(),
+				Type:            callType,
+				BlockNumber:     tx.BlockNumber,
+				TransactionHash: tx.Hash,
+				Index:           uint64(len(transfers)),
+				Timestamp:       tx.Timestamp,
+			}
+		}
+
+		if tx.Value.Sign() > 0 {
+			if tx.CreatedContract.IsEmpty() {
+				transfers = append(transfers, newTransfer(tx.From, tx.To, tx.Value, "CALL"))
+			} else {
+				transfers = append(transfers, newTransfer(tx.From, tx.CreatedContract, tx.Value, "CREATE"))
+			}
+		}
+		for _, call := range tx.InternalCalls {
+			if call.Value.Sign() > 0 && valueCallTypes[call.Type] && !call.Reverted(tx.InternalCalls) {
+				transfers = append(transfers, newTransfer(call.From, call.To, call.Value, call.Type))
+			}
+		}
+	}
+	return transfers
+}
@@ -0,0 +1,93 @@
+package monitor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/types"
+)
+
+func TestNativeTransfers(t *testing.T) {
+	sender := types.NewAddress("0x1")
+	recipient := types.NewAddress("0x2")
+	contract := types.NewAddress("0x3")
+	child := types.NewAddress("0x4")
+
+	creation := &types.Transaction{
+		Hash:            types.NewHash("0xa"),
+		Status:          true,
+		BlockNumber:     5,
+		Index:           0,
+		From:            sender,
+		Value:           types.NewBigIntFromUint64(100),
+		CreatedContract: contract,
+		Timestamp:       5000,
+		InternalCalls: []*types.InternalCall{
+			{From: contract, To: child, Value: types.NewBigIntFromUint64(40), Type: "CREATE2"},
+		},
+	}
+	call := &types.Transaction{
+		Hash:        types.NewHash("0xb"),
+		Status:      true,
+		BlockNumber: 5,
+		Index:       1,
+		From:        sender,
+		To:          contract,
+		Timestamp:   5000,
+		InternalCalls: []*types.InternalCall{
+			{From: contract, To: recipient, Value: types.NewBigIntFromUint64(10), Type: "CALL"},
+			{From: contract, To: child, Value: types.NewBigIntFromUint64(0), Type: "CALL"},
+			{From: contract, To: child, Value: types.NewBigIntFromUint64(5), Type: "DELEGATECALL"},
+			{From: child, To: recipient, Value: types.NewBigIntFromUint64(40), Type: "SELFDESTRUCT"},
+		},
+	}
+	failed := &types.Transaction{
+		Hash:        types.NewHash("0xc"),
+		Status:      false,
+		BlockNumber: 5,
+		Index:       2,
+		From:        sender,
+		To:          recipient,
+		Value:       types.NewBigIntFromUint64(1),
+		Timestamp:   5000,
+	}
+
+	// transactions are ordered by their index in the block
+	transfers := nativeTransfers([]*types.Transaction{failed, call, creation})
+
+	assert.Equal(t, []types.NativeTransfer{
+		{From: sender, To: contract, Value: "100", Type: "CREATE", BlockNumber: 5, TransactionHash: creation.Hash, Index: 0, Timestamp: 5000},
+		{From: contract, To: child, Value: "40", Type: "CREATE2", BlockNumber: 5, TransactionHash: creation.Hash, Index: 1, Timestamp: 5000},
+		{From: contract, To: recipient, Value: "10", Type: "CALL", BlockNumber: 5, TransactionHash: call.Hash, Index: 2, Timestamp: 5000},
+		{From: child, To: recipient, Value: "40", Type: "SELFDESTRUCT", BlockNumber: 5, TransactionHash: call.Hash, Index: 3, Timestamp: 5000},
+	}, transfers)
+}
+
+func TestNativeTransfers_RevertedCalls(t *testing.T) {
+	sender := types.NewAddress("0x1")
+	recipient := types.NewAddress("0x2")
+	contract := types.NewAddress("0x3")
+	child := types.NewAddress("0x4")
+
+	tx := &types.Transaction{
+		Hash:        types.NewHash("0xa"),
+		Status:      true,
+		BlockNumber: 5,
+		From:        sender,
+		To:          contract,
+		Timestamp:   5000,
+		InternalCalls: []*types.InternalCall{
+			{From: contract, To: child, Value: types.NewBigIntFromUint64(10), Type: "CALL", Depth: 1, Parent: -1, Path: []int{0}, Error: "execution reverted"},
+			{From: child, To: recipient, Value: types.NewBigIntFromUint64(5), Type: "CALL", Depth: 2, Parent: 0, Path: []int{0, 0}},
+			{From: contract, To: recipient, Value: types.NewBigIntFromUint64(20), Type: "CALL", Depth: 1, Parent: -1, Path: []int{1}},
+		},
+	}
+
+	// the failed call and the call it made are both undone
+	transfers := nativeTransfers([]*types.Transaction{tx})
+
+	assert.Equal(t, []types.NativeTransfer{
+		{From: contract, To: recipient, Value: "20", Type: "CALL", BlockNumber: 5, TransactionHash: tx.Hash, Index: 0, Timestamp: 5000},
+	}, transfers)
+}
@@ -1,6 +1,7 @@
 package monitor
 
 import (
+	"errors"
 	"fmt"
 	"runtime"
 	"sync"
@@ -26,13 +27,17 @@ type MonitorService struct {
 	batchWriteChan chan *BlockAndTransactions
 	batchWriter    *BatchWriter
 	totalWorkers   int
+	// blocks sent for processing that are not yet written to the database
+	pendingBlocks *sync.WaitGroup
+	// held by the filter service while it filters a batch, so a rollback waits for it
+	rollbackLock sync.Locker
 
 	// To check we have actually shut down before returning
 	shutdownChan chan struct{}
 	shutdownWg   sync.WaitGroup
 }
 
-func NewMonitorService(db database.Database, quorumClient client.Client, consensus string, config types.ReportingConfig) (*MonitorService, error) {
+func NewMonitorService(db database.Database, quorumClient client.Client, consensus string, config types.ReportingConfig, rollbackLock sync.Locker) (*MonitorService, error) {
 	// rules are only parsed once during monitor service initialization
 	var rules []TokenRule
 	for _, rule := range config.Rules {
@@ -53,17 +58,21 @@ func NewMonitorService(db database.Database, quorumClient client.Client, consens
 	}
 	newBlockChan := make(chan *types.Block)
 	batchWriteChan := make(chan *BlockAndTransactions, config.Tuning.BlockProcessingQueueSize)
-	return &MonitorService{
+	var pendingBlocks sync.WaitGroup
+	m := &MonitorService{
 		db:                 db,
-		blockMonitor:       NewDefaultBlockMonitor(quorumClient, newBlockChan, consensus),
 		transactionMonitor: NewDefaultTransactionMonitor(quorumClient),
 		tokenMonitor:       NewDefaultTokenMonitor(quorumClient, rules),
 		newBlockChan:       newBlockChan,
 		batchWriteChan:     batchWriteChan,
-		batchWriter:        NewBatchWriter(db, batchWriteChan, config.Tuning.BlockProcessingFlushPeriod),
+		batchWriter:        NewBatchWriter(db, batchWriteChan, config.Tuning.BlockProcessingFlushPeriod, &pendingBlocks),
 		totalWorkers:       3 * runtime.NumCPU(),
+		pendingBlocks:      &pendingBlocks,
+		rollbackLock:       rollbackLock,
 		shutdownChan:       make(chan struct{}),
-	}, nil
+	}
+	m.blockMonitor = NewDefaultBlockMonitor(quorumClient, db, newBlockChan, consensus, &pendingBlocks, m.rollback)
+	return m, nil
 }
 
 func (m *MonitorService) Start() error {
@@ -73,6 +82,7 @@ func (m *MonitorService) Start() error {
 	m.startBatchWriter()
 	m.startWorkers()
 
+	m.shutdownWg.Add(1)
 	go m.run()
 
 	return nil
@@ -86,8 +96,8 @@ func (m *MonitorService) Stop() {
 
 func (m *MonitorService) startBatchWriter() {
 	log.Info("Starting batch writer")
+	m.shutdownWg.Add(1)
 	go func() {
-		m.shutdownWg.Add(1)
 		m.batchWriter.Run(m.shutdownChan)
 		m.shutdownWg.Done()
 	}()
@@ -96,8 +106,8 @@ func (m *MonitorService) startBatchWriter() {
 func (m *MonitorService) startWorkers() {
 	log.Info("Starting block processor workers")
 	for i := 0; i < m.totalWorkers; i++ {
+		m.shutdownWg.Add(1)
 		go func() {
-			m.shutdownWg.Add(1)
 			m.startWorker(m.shutdownChan)
 			m.shutdownWg.Done()
 		}()
@@ -143,7 +153,6 @@ func (m *MonitorService) run() {
 	*/
 
 	log.Info("Start to sync blocks...")
-	m.shutdownWg.Add(1)
 
 	for {
 		chStopChan := make(chan bool)
@@ -191,6 +200,32 @@ func (m *MonitorService) run() {
 	}
 }
 
+// rollback waits for all blocks already sent for processing to be written, and for the filter
+// service to finish the batch it is filtering, so that no orphaned data is written after the
+// rollback, then removes all data above the common ancestor. Filtering is paused until the
+// rollback has finished.
+func (m *MonitorService) rollback(reorg *types.ChainReorg) error {
+	ready := make(chan struct{})
+	go func() {
+		m.pendingBlocks.Wait()
+		m.rollbackLock.Lock()
+		close(ready)
+	}()
+
+	select {
+	case <-ready:
+	case <-m.shutdownChan:
+		// the lock may still be acquired once the batch finishes, so it is released then
+		go func() {
+			<-ready
+			m.rollbackLock.Unlock()
+		}()
+		return errors.New("monitor service is shutting down")
+	}
+	defer m.rollbackLock.Unlock()
+	return m.db.RollbackBlocks(reorg)
+}
+
 func (m *MonitorService) processBlock(block *types.Block) error {
 	// Transaction monitor pulls all transactions for the given block.
 	fetchedTxns, err := m.transactionMonitor.PullTransactions(block)
@@ -0,0 +1,92 @@
+package monitor
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/core/filter"
+	"quorumengineering/quorum-report/core/proxy"
+	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/types"
+)
+
+// blockingIndexDB pauses the first IndexBlocks call until released, so that a rollback can
+// be started while a filter batch is in progress
+type blockingIndexDB struct {
+	*memory.MemoryDB
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (db *blockingIndexDB) IndexBlocks(addresses []types.Address, blocks []*types.BlockWithTransactions) error {
+	db.once.Do(func() {
+		close(db.started)
+		<-db.release
+	})
+	return db.MemoryDB.IndexBlocks(addresses, blocks)
+}
+
+func TestRollback_WaitsForFilterBatch(t *testing.T) {
+	address := types.NewAddress("0x0000000000000000000000000000000000000001")
+	// the storage of the address never changes
+	mockRPC := map[string]interface{}{
+		fmt.Sprintf("eth_storageRoot%s0x0", address.String()): types.NewHash("1"),
+	}
+	for blockNumber := 1; blockNumber <= 3; blockNumber++ {
+		mockRPC[fmt.Sprintf("eth_storageRoot%s0x%x", address.String(), blockNumber)] = types.NewHash("1")
+		mockRPC[fmt.Sprintf("eth_getBalance%s0x%x", address.String(), blockNumber)] = "0x64"
+		for _, implementationSlot := range proxy.ImplementationSlots {
+			mockRPC[fmt.Sprintf("eth_getStorageAt%s%s0x%x", address.String(), implementationSlot.Slot.String(), blockNumber)] = types.NewHash("")
+		}
+	}
+	quorumClient := client.NewStubQuorumClient(nil, mockRPC)
+
+	db := &blockingIndexDB{MemoryDB: memory.NewMemoryDB(), started: make(chan struct{}), release: make(chan struct{})}
+	assert.Nil(t, db.WriteBlocks([]*types.Block{{Number: 1}, {Number: 2}, {Number: 3}}))
+	assert.Nil(t, db.AddAddresses([]types.Address{address}))
+
+	rollbackLock := &sync.RWMutex{}
+	m, err := NewMonitorService(db, quorumClient, "istanbul", types.ReportingConfig{}, rollbackLock)
+	assert.Nil(t, err)
+	fs, err := filter.NewFilterService(db, quorumClient, nil, types.ReportingConfig{}, rollbackLock)
+	assert.Nil(t, err)
+	assert.Nil(t, fs.Start())
+	defer fs.Stop()
+	var releaseOnce sync.Once
+	release := func() { releaseOnce.Do(func() { close(db.release) }) }
+	// the batch must be released for the filter service to stop, even if the test fails
+	defer release()
+
+	select {
+	case <-db.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("filter batch did not start")
+	}
+
+	rolledBack := make(chan error)
+	go func() {
+		rolledBack <- m.rollback(&types.ChainReorg{CommonAncestor: 1, NewHead: 3})
+	}()
+
+	// the rollback waits for the batch that is filtering the orphaned blocks
+	select {
+	case <-rolledBack:
+		t.Fatal("rollback did not wait for the filter batch")
+	case <-time.After(100 * time.Millisecond):
+	}
+	release()
+	assert.Nil(t, <-rolledBack)
+
+	// the batch finished before the rollback, so its progress is rolled back too
+	lastFiltered, err := db.GetLastFiltered(address)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, lastFiltered)
+	_, err = db.ReadBlock(2)
+	assert.NotNil(t, err)
+}
@@ -1,6 +1,10 @@
 package monitor
 
 import (
+	"math/big"
+	"sync"
+	"sync/atomic"
+
 	"quorumengineering/quorum-report/client"
 	"quorumengineering/quorum-report/log"
 	"quorumengineering/quorum-report/types"
@@ -12,6 +16,13 @@ type TransactionMonitor interface {
 
 type DefaultTransactionMonitor struct {
 	quorumClient client.Client
+
+	// set once the node has rejected the fields of typed transactions over GraphQL, after which
+	// they are read with eth_getTransactionByHash instead
+	typedFieldsUnsupported int32
+
+	chainIDMux sync.Mutex
+	chainID    *types.BigInt
 }
 
 func NewDefaultTransactionMonitor(quorumClient client.Client) *DefaultTransactionMonitor {
@@ -38,7 +49,7 @@ func (tm *DefaultTransactionMonitor) PullTransactions(block *types.Block) ([]*ty
 func (tm *DefaultTransactionMonitor) fetchTransaction(block *types.Block, hash types.Hash) (*types.Transaction, error) {
 	log.Debug("Processing transaction", "hash", hash.String())
 
-	txOrigin, err := client.TransactionWithReceipt(tm.quorumClient, hash)
+	txOrigin, typed, err := tm.transactionWithReceipt(hash)
 	if err != nil {
 		return nil, err
 	}
@@ -52,10 +63,10 @@ func (tm *DefaultTransactionMonitor) fetchTransaction(block *types.Block, hash t
 		Nonce:             txOrigin.Nonce.ToUint64(),
 		From:              txOrigin.From.Address,
 		To:                txOrigin.To.Address,
-		Value:             txOrigin.Value.ToUint64(),
+		Value:             types.NewBigInt(txOrigin.Value.ToBigInt()),
 		Gas:               txOrigin.Gas.ToUint64(),
 		GasUsed:           txOrigin.GasUsed.ToUint64(),
-		GasPrice:          txOrigin.GasPrice.ToUint64(),
+		GasPrice:          types.NewBigInt(txOrigin.GasPrice.ToBigInt()),
 		CumulativeGasUsed: txOrigin.CumulativeGasUsed.ToUint64(),
 		CreatedContract:   txOrigin.CreatedContract.Address,
 		Data:              txOrigin.InputData,
@@ -64,6 +75,15 @@ func (tm *DefaultTransactionMonitor) fetchTransaction(block *types.Block, hash t
 		Timestamp:         block.Timestamp,
 	}
 
+	if typed {
+		err = tm.setTypedFields(tx, txOrigin)
+	} else {
+		err = tm.setTypedFieldsFromRPC(tx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	tx.Events = make([]*types.Event, len(txOrigin.Logs))
 	for i, l := range txOrigin.Logs {
 		tx.Events[i] = &types.Event{
@@ -84,34 +104,145 @@ func (tm *DefaultTransactionMonitor) fetchTransaction(block *types.Block, hash t
 		return nil, err
 	}
 
-	calls := flattenCalls(traceResp.Calls)
-	tx.InternalCalls = make([]*types.InternalCall, len(calls))
-	for i, respCall := range calls {
-		tx.InternalCalls[i] = &types.InternalCall{
-			From:    respCall.From,
-			To:      respCall.To,
-			Gas:     respCall.Gas.ToUint64(),
-			GasUsed: respCall.GasUsed.ToUint64(),
-			Value:   respCall.Value.ToUint64(),
-			Input:   respCall.Input,
-			Output:  respCall.Output,
-			Type:    respCall.Type,
-		}
+	tx.InternalCalls = flattenCalls(traceResp.Calls, -1, nil, []*types.InternalCall{})
+	if !tx.Status {
+		tx.Error = traceResp.Error
+		tx.RevertData = traceResp.Output
 	}
 	return tx, nil
 }
 
-//flattens the list of internal calls to a single list
-//e.g [1 [2 3 [4 5] 6 [7]]] -> [1 2 3 4 5 6 7]
-func flattenCalls(calls []types.RawInnerCall) []types.RawInnerCall {
-	if len(calls) == 0 {
-		return []types.RawInnerCall{}
+// transactionWithReceipt fetches a transaction over GraphQL along with the fields of typed
+// transactions, falling back to the fields without them if the node's schema does not have
+// them. Any other error is returned, so that the block is fetched again. It reports whether
+// the typed fields were fetched.
+func (tm *DefaultTransactionMonitor) transactionWithReceipt(hash types.Hash) (client.Transaction, bool, error) {
+	if atomic.LoadInt32(&tm.typedFieldsUnsupported) == 0 {
+		txOrigin, typedErr := client.TypedTransactionWithReceipt(tm.quorumClient, hash)
+		if typedErr == nil {
+			return txOrigin, true, nil
+		}
+		if !client.IsUnknownFieldError(typedErr) {
+			return client.Transaction{}, false, typedErr
+		}
+		txOrigin, err := client.TransactionWithReceipt(tm.quorumClient, hash)
+		if err != nil {
+			return client.Transaction{}, false, err
+		}
+		log.Info("Typed transaction fields not available over GraphQL, falling back to eth_getTransactionByHash", "err", typedErr)
+		atomic.StoreInt32(&tm.typedFieldsUnsupported, 1)
+		return txOrigin, false, nil
+	}
+
+	txOrigin, err := client.TransactionWithReceipt(tm.quorumClient, hash)
+	return txOrigin, false, err
+}
+
+// setTypedFields sets the fields of typed transactions from the GraphQL response
+func (tm *DefaultTransactionMonitor) setTypedFields(tx *types.Transaction, txOrigin client.Transaction) error {
+	tx.Type = txOrigin.Type.ToBigInt().Uint64()
+	tx.AccessList = txOrigin.AccessList
+	tx.MaxFeePerGas = txOrigin.MaxFeePerGas
+	tx.MaxPriorityFeePerGas = txOrigin.MaxPriorityFeePerGas
+	tx.EffectiveGasPrice = tx.GasPrice
+	if txOrigin.EffectiveGasPrice != nil {
+		tx.EffectiveGasPrice = txOrigin.EffectiveGasPrice
+	}
+
+	// GraphQL does not give the chain ID of a transaction. Typed transactions are always signed
+	// for the chain of the node, and legacy ones carry it in their signature if replay protected.
+	if tx.Type == types.LegacyTxType {
+		tx.ChainID = legacyChainID(txOrigin.V, tx.IsPrivate)
+		return nil
 	}
+	chainID, err := tm.nodeChainID()
+	if err != nil {
+		return err
+	}
+	tx.ChainID = chainID
+	return nil
+}
+
+// setTypedFieldsFromRPC sets the fields of typed transactions with eth_getTransactionByHash, for
+// nodes that do not provide them over GraphQL
+func (tm *DefaultTransactionMonitor) setTypedFieldsFromRPC(tx *types.Transaction) error {
+	rawTx, err := client.TransactionByHash(tm.quorumClient, tx.Hash)
+	if err != nil {
+		return err
+	}
+	tx.Type = rawTx.Type.ToUint64()
+	tx.ChainID = rawTx.ChainID
+	tx.AccessList = rawTx.AccessList
+	tx.MaxFeePerGas = rawTx.MaxFeePerGas
+	tx.MaxPriorityFeePerGas = rawTx.MaxPriorityFeePerGas
+	// the node gives the price a mined dynamic fee transaction actually paid as its gas price,
+	// and other transactions pay the gas price they set
+	tx.EffectiveGasPrice = tx.GasPrice
+	if rawTx.GasPrice != nil {
+		tx.EffectiveGasPrice = rawTx.GasPrice
+	}
+	return nil
+}
+
+// nodeChainID fetches the chain ID of the node the first time it is needed
+func (tm *DefaultTransactionMonitor) nodeChainID() (*types.BigInt, error) {
+	tm.chainIDMux.Lock()
+	defer tm.chainIDMux.Unlock()
 
-	var results []types.RawInnerCall
-	for _, c := range calls {
-		results = append(results, c)
-		results = append(results, flattenCalls(c.Calls)...)
+	if tm.chainID == nil {
+		chainID, err := client.ChainID(tm.quorumClient)
+		if err != nil {
+			return nil, err
+		}
+		tm.chainID = chainID
+	}
+	return tm.chainID, nil
+}
+
+// legacyChainID recovers the chain ID of a legacy transaction from the V value of its signature,
+// which is chainID*2+35 or chainID*2+36 for replay protected transactions (EIP-155). Quorum
+// private transactions without replay protection use 37 or 38 instead, so have no chain ID.
+func legacyChainID(v *types.BigInt, isPrivate bool) *types.BigInt {
+	value := v.ToBigInt()
+	if value.Cmp(big.NewInt(35)) < 0 {
+		return nil
+	}
+	if isPrivate && value.IsUint64() && (value.Uint64() == 37 || value.Uint64() == 38) {
+		return nil
+	}
+	chainID := value.Sub(value, big.NewInt(35))
+	return types.NewBigInt(chainID.Rsh(chainID, 1))
+}
+
+//flattens the tree of internal calls to a single list in the order they were made,
+//recording the position of each call in the tree
+//e.g [1 [2 3 [4 5] 6 [7]]] -> [1 2 3 4 5 6 7]
+func flattenCalls(calls []types.RawInnerCall, parent int, path []int, results []*types.InternalCall) []*types.InternalCall {
+	for i, respCall := range calls {
+		callPath := make([]int, len(path)+1)
+		copy(callPath, path)
+		callPath[len(path)] = i
+
+		revertReason := respCall.RevertReason
+		if revertReason == "" && respCall.Error != "" {
+			revertReason = types.DecodeRevertReason(respCall.Output.AsBytes())
+		}
+		results = append(results, &types.InternalCall{
+			From:         respCall.From,
+			To:           respCall.To,
+			Gas:          respCall.Gas.ToUint64(),
+			GasUsed:      respCall.GasUsed.ToUint64(),
+			Value:        types.NewBigInt(respCall.Value.ToBigInt()),
+			Input:        respCall.Input,
+			Output:       respCall.Output,
+			Type:         respCall.Type,
+			Depth:        uint64(len(callPath)),
+			Parent:       parent,
+			Path:         callPath,
+			Error:        respCall.Error,
+			RevertReason: revertReason,
+		})
+		results = flattenCalls(respCall.Calls, len(results)-1, callPath, results)
 	}
 	return results
 }
@@ -1,6 +1,7 @@
 package monitor
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,8 +18,8 @@ var graphqlResp = map[string]interface{}{
 	"nonce":             "0x1",
 	"from":              map[string]interface{}{"address": "0xed9d02e382b34818e88b88a309c7fe71e65f419d"},
 	"to":                nil,
-	"value":             "0x0",
-	"gasPrice":          "0x0",
+	"value":             "0x3635c9adc5dea00000",
+	"gasPrice":          "0x4a817c800",
 	"gas":               "0x47b760",
 	"gasUsed":           "0x280a7",
 	"cumulativeGasUsed": "0x280a7",
@@ -36,6 +37,30 @@ var graphqlResp = map[string]interface{}{
 	},
 }
 
+// GraphQLErrorStubClient fails the given GraphQL queries with an error
+type GraphQLErrorStubClient struct {
+	*client.StubQuorumClient
+	queryErrors map[string]error
+}
+
+func (stub *GraphQLErrorStubClient) ExecuteGraphQLQuery(result interface{}, query string) error {
+	if err, ok := stub.queryErrors[query]; ok {
+		return err
+	}
+	return stub.StubQuorumClient.ExecuteGraphQLQuery(result, query)
+}
+
+// newLegacyNodeStubClient returns a stub of a node that predates typed transactions, so its
+// GraphQL schema has none of their fields
+func newLegacyNodeStubClient(mockGraphQL map[string]map[string]interface{}, mockRPC map[string]interface{}) *GraphQLErrorStubClient {
+	return &GraphQLErrorStubClient{
+		client.NewStubQuorumClient(mockGraphQL, mockRPC),
+		map[string]error{
+			client.TypedTransactionDetailQuery(types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8")): errors.New(`graphql: Cannot query field "type" on type "Transaction".`),
+		},
+	}
+}
+
 func TestCreateTransaction(t *testing.T) {
 	testBlock := &types.Block{
 		Number:    2,
@@ -47,6 +72,19 @@ func TestCreateTransaction(t *testing.T) {
 		},
 	}
 	mockRPC := map[string]interface{}{
+		"eth_getTransactionByHash0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8": types.RawTransaction{
+			Type:                 types.DynamicFeeTxType,
+			ChainID:              types.NewBigIntFromUint64(10),
+			GasPrice:             types.NewBigIntFromUint64(1500000000),
+			MaxFeePerGas:         types.NewBigIntFromUint64(2000000000),
+			MaxPriorityFeePerGas: types.NewBigIntFromUint64(500000000),
+			AccessList: []types.AccessTuple{
+				{
+					Address:     types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34"),
+					StorageKeys: []types.Hash{types.NewHash("0x0000000000000000000000000000000000000000000000000000000000000000")},
+				},
+			},
+		},
 		"debug_traceTransaction0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8<*client.TraceConfig Value>": types.RawOuterCall{
 			Calls: []types.RawInnerCall{
 				{
@@ -57,13 +95,34 @@ func TestCreateTransaction(t *testing.T) {
 					Output:  "",
 					To:      "1932c48b2bf8102ba33b4a6b545c32236e342f34",
 					Type:    "CALL",
-					Value:   types.HexNumber(0),
+					Value:   types.NewBigIntFromUint64(0),
+					Calls: []types.RawInnerCall{
+						{
+							From:    "1932c48b2bf8102ba33b4a6b545c32236e342f34",
+							Gas:     types.HexNumber(5000),
+							GasUsed: types.HexNumber(5000),
+							Input:   "2a1afcd9",
+							Output:  "08c379a0000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000000096e6f74206f776e65720000000000000000000000000000000000000000000000",
+							To:      "9d13c6d3afe1721beef56b55d303b09e021e27ab",
+							Type:    "STATICCALL",
+							Value:   types.NewBigIntFromUint64(0),
+							Error:   "execution reverted",
+						},
+					},
+				},
+				{
+					From:    "9d13c6d3afe1721beef56b55d303b09e021e27ab",
+					Gas:     types.HexNumber(2300),
+					GasUsed: types.HexNumber(0),
+					To:      "ed9d02e382b34818e88b88a309c7fe71e65f419d",
+					Type:    "CALL",
+					Value:   types.NewBigIntFromUint64(1),
 				},
 			},
 		},
 	}
 
-	tm := NewDefaultTransactionMonitor(client.NewStubQuorumClient(mockGraphQL, mockRPC))
+	tm := NewDefaultTransactionMonitor(newLegacyNodeStubClient(mockGraphQL, mockRPC))
 	tx, err := tm.fetchTransaction(testBlock, types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"))
 	assert.Nil(t, err)
 	assert.EqualValues(t, types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"), tx.Hash)
@@ -72,13 +131,141 @@ func TestCreateTransaction(t *testing.T) {
 	assert.EqualValues(t, 0, tx.Index)
 	assert.EqualValues(t, types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d"), tx.From)
 	assert.EqualValues(t, 4700000, tx.Gas)
+	assert.Equal(t, "1000000000000000000000", tx.Value.String())
+	assert.Equal(t, "20000000000", tx.GasPrice.String())
+	assert.EqualValues(t, types.DynamicFeeTxType, tx.Type)
+	assert.Equal(t, "10", tx.ChainID.String())
+	assert.Equal(t, "2000000000", tx.MaxFeePerGas.String())
+	assert.Equal(t, "500000000", tx.MaxPriorityFeePerGas.String())
+	assert.Equal(t, "1500000000", tx.EffectiveGasPrice.String())
+	assert.Len(t, tx.AccessList, 1)
 	assert.EqualValues(t, "0x608060405234801561001057600080fd5b506040516020806101a18339810180604052602081101561003057600080fd5b81019080805190602001909291905050508060008190555050610149806100586000396000f3fe608060405234801561001057600080fd5b506004361061005e576000357c0100000000000000000000000000000000000000000000000000000000900480632a1afcd91461006357806360fe47b1146100815780636d4ce63c146100af575b600080fd5b61006b6100cd565b6040518082815260200191505060405180910390f35b6100ad6004803603602081101561009757600080fd5b81019080803590602001909291905050506100d3565b005b6100b7610114565b6040518082815260200191505060405180910390f35b60005481565b806000819055507fefe5cb8d23d632b5d2cdd9f0a151c4b1a84ccb7afa1c57331009aa922d5e4f36816040518082815260200191505060405180910390a150565b6000805490509056fea165627a7a7230582061f6956b053dbf99873b363ab3ba7bca70853ba5efbaff898cd840d71c54fc1d0029000000000000000000000000000000000000000000000000000000000000002a", tx.Data.String())
 	assert.EqualValues(t, "0x", tx.PrivateData.String())
 	assert.False(t, tx.IsPrivate)
 
 	assert.Len(t, tx.Events, 1)
 	assert.EqualValues(t, types.NewHash("0xefe5cb8d23d632b5d2cdd9f0a151c4b1a84ccb7afa1c57331009aa922d5e4f36"), tx.Events[0].Topics[0])
-	assert.Len(t, tx.InternalCalls, 1)
+	assert.Len(t, tx.InternalCalls, 3)
+	assert.EqualValues(t, 1, tx.InternalCalls[0].Depth)
+	assert.Equal(t, -1, tx.InternalCalls[0].Parent)
+	assert.Equal(t, []int{0}, tx.InternalCalls[0].Path)
+	assert.Equal(t, "", tx.InternalCalls[0].Error)
+	assert.EqualValues(t, 2, tx.InternalCalls[1].Depth)
+	assert.Equal(t, 0, tx.InternalCalls[1].Parent)
+	assert.Equal(t, []int{0, 0}, tx.InternalCalls[1].Path)
+	assert.Equal(t, "execution reverted", tx.InternalCalls[1].Error)
+	assert.Equal(t, "not owner", tx.InternalCalls[1].RevertReason)
+	assert.EqualValues(t, 1, tx.InternalCalls[2].Depth)
+	assert.Equal(t, -1, tx.InternalCalls[2].Parent)
+	assert.Equal(t, []int{1}, tx.InternalCalls[2].Path)
+}
+
+func TestCreateTransaction_TypedFieldsOverGraphQL(t *testing.T) {
+	typedResp := make(map[string]interface{}, len(graphqlResp))
+	for k, v := range graphqlResp {
+		typedResp[k] = v
+	}
+	typedResp["type"] = "0x2"
+	typedResp["v"] = "0x1"
+	typedResp["maxFeePerGas"] = "0x77359400"
+	typedResp["maxPriorityFeePerGas"] = "0x1dcd6500"
+	typedResp["effectiveGasPrice"] = "0x59682f00"
+	typedResp["accessList"] = []map[string]interface{}{
+		{
+			"address":     "0x1932c48b2bf8102ba33b4a6b545c32236e342f34",
+			"storageKeys": []string{"0x0000000000000000000000000000000000000000000000000000000000000000"},
+		},
+	}
+
+	mockGraphQL := map[string]map[string]interface{}{
+		client.TypedTransactionDetailQuery(types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8")): {
+			"transaction": interface{}(typedResp),
+		},
+	}
+	// eth_getTransactionByHash is not mocked, so must not be called
+	mockRPC := map[string]interface{}{
+		"eth_chainId": types.NewBigIntFromUint64(10),
+		"debug_traceTransaction0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8<*client.TraceConfig Value>": types.RawOuterCall{},
+	}
+
+	tm := NewDefaultTransactionMonitor(client.NewStubQuorumClient(mockGraphQL, mockRPC))
+	tx, err := tm.fetchTransaction(&types.Block{Number: 2}, types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"))
+	assert.Nil(t, err)
+	assert.EqualValues(t, types.DynamicFeeTxType, tx.Type)
+	assert.Equal(t, "10", tx.ChainID.String())
+	assert.Equal(t, "2000000000", tx.MaxFeePerGas.String())
+	assert.Equal(t, "500000000", tx.MaxPriorityFeePerGas.String())
+	assert.Equal(t, "1500000000", tx.EffectiveGasPrice.String())
+	assert.Equal(t, []types.AccessTuple{
+		{
+			Address:     types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34"),
+			StorageKeys: []types.Hash{types.NewHash("0x0000000000000000000000000000000000000000000000000000000000000000")},
+		},
+	}, tx.AccessList)
+	assert.EqualValues(t, 0, tm.typedFieldsUnsupported)
+}
+
+func TestCreateTransaction_FallsBackOnce(t *testing.T) {
+	mockGraphQL := map[string]map[string]interface{}{
+		client.TransactionDetailQuery(types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8")): {
+			"transaction": interface{}(graphqlResp),
+		},
+	}
+	mockRPC := map[string]interface{}{
+		"eth_getTransactionByHash0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8":                          types.RawTransaction{},
+		"debug_traceTransaction0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8<*client.TraceConfig Value>": types.RawOuterCall{},
+	}
+	stubClient := newLegacyNodeStubClient(mockGraphQL, mockRPC)
+
+	tm := NewDefaultTransactionMonitor(stubClient)
+	_, err := tm.fetchTransaction(&types.Block{Number: 2}, types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"))
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, tm.typedFieldsUnsupported)
+
+	// the typed query is no longer tried, even once the node would answer it
+	stubClient.queryErrors = nil
+	mockGraphQL[client.TypedTransactionDetailQuery(types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"))] = map[string]interface{}{
+		"transaction": interface{}(map[string]interface{}{"type": "0x2"}),
+	}
+	tx, err := tm.fetchTransaction(&types.Block{Number: 2}, types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"))
+	assert.Nil(t, err)
+	assert.EqualValues(t, types.LegacyTxType, tx.Type)
+}
+
+func TestCreateTransaction_TypedQueryTransportError(t *testing.T) {
+	mockGraphQL := map[string]map[string]interface{}{
+		client.TransactionDetailQuery(types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8")): {
+			"transaction": interface{}(graphqlResp),
+		},
+	}
+	stubClient := &GraphQLErrorStubClient{
+		client.NewStubQuorumClient(mockGraphQL, nil),
+		map[string]error{
+			client.TypedTransactionDetailQuery(types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8")): errors.New("Post http://localhost:8547/graphql: dial tcp 127.0.0.1:8547: connect: connection refused"),
+		},
+	}
+
+	// the node may support the typed fields, so the error is returned for the block to be retried
+	tm := NewDefaultTransactionMonitor(stubClient)
+	_, err := tm.fetchTransaction(&types.Block{Number: 2}, types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"))
+	assert.EqualError(t, err, "Post http://localhost:8547/graphql: dial tcp 127.0.0.1:8547: connect: connection refused")
+	assert.EqualValues(t, 0, tm.typedFieldsUnsupported)
+}
+
+func TestCreateTransaction_WithError(t *testing.T) {
+	tm := NewDefaultTransactionMonitor(client.NewStubQuorumClient(nil, nil))
+	_, err := tm.fetchTransaction(&types.Block{Number: 2}, types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"))
+	assert.EqualError(t, err, "not found")
+	assert.EqualValues(t, 0, tm.typedFieldsUnsupported)
+}
+
+func TestLegacyChainID(t *testing.T) {
+	assert.Nil(t, legacyChainID(nil, false))
+	assert.Nil(t, legacyChainID(types.NewBigIntFromUint64(27), false))
+	assert.Nil(t, legacyChainID(types.NewBigIntFromUint64(37), true))
+	assert.Equal(t, "1", legacyChainID(types.NewBigIntFromUint64(37), false).String())
+	assert.Equal(t, "10", legacyChainID(types.NewBigIntFromUint64(56), true).String())
+	assert.Equal(t, "1337", legacyChainID(types.NewBigIntFromUint64(2709), false).String())
 }
 
 func TestTransactionMonitor_PullTransactions(t *testing.T) {
@@ -88,6 +275,7 @@ func TestTransactionMonitor_PullTransactions(t *testing.T) {
 		},
 	}
 	mockRPC := map[string]interface{}{
+		"eth_getTransactionByHash0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8": types.RawTransaction{},
 		"debug_traceTransaction0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8<*client.TraceConfig Value>": types.RawOuterCall{
 			Calls: []types.RawInnerCall{
 				{
@@ -98,7 +286,7 @@ func TestTransactionMonitor_PullTransactions(t *testing.T) {
 					Output:  "",
 					To:      "1932c48b2bf8102ba33b4a6b545c32236e342f34",
 					Type:    "CALL",
-					Value:   types.HexNumber(0),
+					Value:   types.NewBigIntFromUint64(0),
 				},
 			},
 		},
@@ -111,7 +299,7 @@ func TestTransactionMonitor_PullTransactions(t *testing.T) {
 		},
 	}
 
-	tm := NewDefaultTransactionMonitor(client.NewStubQuorumClient(mockGraphQL, mockRPC))
+	tm := NewDefaultTransactionMonitor(newLegacyNodeStubClient(mockGraphQL, mockRPC))
 
 	txs, err := tm.PullTransactions(block)
 	assert.Nil(t, err, "unexpected error")
@@ -131,4 +319,37 @@ func TestTransactionMonitor_PullTransactions(t *testing.T) {
 	assert.Len(t, tx.Events, 1)
 	assert.EqualValues(t, types.NewHash("0xefe5cb8d23d632b5d2cdd9f0a151c4b1a84ccb7afa1c57331009aa922d5e4f36"), tx.Events[0].Topics[0])
 	assert.Len(t, tx.InternalCalls, 1)
+	assert.EqualValues(t, types.LegacyTxType, tx.Type)
+	assert.Nil(t, tx.MaxFeePerGas)
+	assert.Equal(t, tx.GasPrice, tx.EffectiveGasPrice)
+}
+
+func TestCreateTransaction_Failed(t *testing.T) {
+	failedResp := make(map[string]interface{}, len(graphqlResp))
+	for k, v := range graphqlResp {
+		failedResp[k] = v
+	}
+	failedResp["status"] = "0x0"
+	failedResp["logs"] = []map[string]interface{}{}
+
+	mockGraphQL := map[string]map[string]interface{}{
+		client.TransactionDetailQuery(types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8")): {
+			"transaction": interface{}(failedResp),
+		},
+	}
+	mockRPC := map[string]interface{}{
+		"eth_getTransactionByHash0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8": types.RawTransaction{},
+		"debug_traceTransaction0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8<*client.TraceConfig Value>": types.RawOuterCall{
+			Calls:  []types.RawInnerCall{},
+			Output: "cf4791810000000000000000000000000000000000000000000000000000000000000005000000000000000000000000000000000000000000000000000000000000000a",
+			Error:  "execution reverted",
+		},
+	}
+
+	tm := NewDefaultTransactionMonitor(newLegacyNodeStubClient(mockGraphQL, mockRPC))
+	tx, err := tm.fetchTransaction(&types.Block{Number: 2}, types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"))
+	assert.Nil(t, err)
+	assert.False(t, tx.Status)
+	assert.Equal(t, "execution reverted", tx.Error)
+	assert.Equal(t, types.NewHexData("0xcf4791810000000000000000000000000000000000000000000000000000000000000005000000000000000000000000000000000000000000000000000000000000000a"), tx.RevertData)
 }
@@ -0,0 +1,55 @@
+// Package proxy follows upgradeable proxy contracts to the implementation contracts they
+// delegate to, so that their transactions, events and storage can be parsed with the
+// template of the implementation that was active at the time.
+package proxy
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"quorumengineering/quorum-report/types"
+)
+
+const (
+	StandardEIP1967    = "eip1967"
+	StandardEIP1822    = "eip1822"
+	StandardZeppelinOS = "zeppelinos"
+)
+
+// ImplementationSlot is the storage slot a proxy standard keeps the implementation address in
+type ImplementationSlot struct {
+	Standard string
+	Slot     types.Hash
+}
+
+// ImplementationSlots are checked in order, the first holding an address being used
+var ImplementationSlots = []ImplementationSlot{
+	// bytes32(uint256(keccak256("eip1967.proxy.implementation")) - 1), also used by transparent proxies
+	{StandardEIP1967, types.NewHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")},
+	// keccak256("PROXIABLE")
+	{StandardEIP1822, types.NewHash("0xc5f16f0fcc639fa48a6947836d9850f504798523bf8c9a3a87d5876cf622bcf7")},
+	// keccak256("org.zeppelinos.proxy.implementation"), used by older transparent proxies
+	{StandardZeppelinOS, types.NewHash("0x7050c9e0f4ca769c69bd3a8ef740bc37934f8e2c036e5a723fd8ee048ed3f8c3")},
+}
+
+// FindImplementation reads the implementation slots of a contract using the given function,
+// which returns the hex encoded value of a slot. It returns nil if no slot holds an address,
+// meaning the contract is not a proxy.
+func FindImplementation(readSlot func(slot types.Hash) (string, error)) (*types.ProxyImplementation, error) {
+	for _, implementationSlot := range ImplementationSlots {
+		value, err := readSlot(implementationSlot.Slot)
+		if err != nil {
+			return nil, err
+		}
+		address, ok := new(big.Int).SetString(strings.TrimPrefix(value, "0x"), 16)
+		if !ok || address.Sign() == 0 || address.BitLen() > 160 {
+			continue
+		}
+		return &types.ProxyImplementation{
+			Implementation: types.NewAddress(fmt.Sprintf("%040x", address)),
+			Standard:       implementationSlot.Standard,
+		}, nil
+	}
+	return nil, nil
+}
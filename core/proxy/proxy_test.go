@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/types"
+)
+
+func TestFindImplementation(t *testing.T) {
+	testMatrix := []struct {
+		storage  map[types.Hash]string
+		expected *types.ProxyImplementation
+	}{
+		{map[types.Hash]string{}, nil},
+		{
+			map[types.Hash]string{ImplementationSlots[0].Slot: "0x0000000000000000000000001349f3e1b8d71effb47b840594ff27da7e603d17"},
+			&types.ProxyImplementation{Implementation: types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17"), Standard: StandardEIP1967},
+		},
+		{
+			map[types.Hash]string{ImplementationSlots[1].Slot: "1349f3e1b8d71effb47b840594ff27da7e603d17"},
+			&types.ProxyImplementation{Implementation: types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17"), Standard: StandardEIP1822},
+		},
+		{
+			map[types.Hash]string{ImplementationSlots[2].Slot: "1"},
+			&types.ProxyImplementation{Implementation: types.NewAddress("0x0000000000000000000000000000000000000001"), Standard: StandardZeppelinOS},
+		},
+		// values that are not addresses are ignored
+		{map[types.Hash]string{ImplementationSlots[0].Slot: "0x0100000000000000000000001349f3e1b8d71effb47b840594ff27da7e603d17"}, nil},
+		{map[types.Hash]string{ImplementationSlots[0].Slot: "0x0000000000000000000000000000000000000000000000000000000000000000"}, nil},
+	}
+
+	for idx, test := range testMatrix {
+		found, err := FindImplementation(func(slot types.Hash) (string, error) {
+			return test.storage[slot], nil
+		})
+		assert.Nil(t, err, "Test index %d failed", idx)
+		assert.Equal(t, test.expected, found, "Test index %d failed", idx)
+	}
+
+	_, err := FindImplementation(func(slot types.Hash) (string, error) {
+		return "", errors.New("not found")
+	})
+	assert.EqualError(t, err, "not found")
+}
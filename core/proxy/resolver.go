@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"encoding/json"
+	"strings"
+
+	"quorumengineering/quorum-report/types"
+)
+
+type Database interface {
+	GetContractABI(types.Address) (string, error)
+	GetStorageLayout(types.Address) (string, error)
+	GetProxyImplementations(types.Address) ([]*types.ProxyImplementation, error)
+}
+
+// Resolver finds the ABI and storage layout to use for a contract at a given block, which
+// for a proxy are those of the implementation it delegated to at the time. It caches all
+// that it reads, so should only be used for a single request or batch.
+type Resolver struct {
+	db Database
+
+	implementations map[types.Address][]*types.ProxyImplementation
+	abis            map[types.Address]string
+	layouts         map[types.Address]string
+}
+
+func NewResolver(db Database) *Resolver {
+	return &Resolver{
+		db:              db,
+		implementations: make(map[types.Address][]*types.ProxyImplementation),
+		abis:            make(map[types.Address]string),
+		layouts:         make(map[types.Address]string),
+	}
+}
+
+// ImplementationAt returns the implementation the contract delegated to at the given block,
+// or nil if it was not a proxy at the time
+func (r *Resolver) ImplementationAt(address types.Address, block uint64) (*types.ProxyImplementation, error) {
+	implementations, ok := r.implementations[address]
+	if !ok {
+		var err error
+		if implementations, err = r.db.GetProxyImplementations(address); err != nil {
+			return nil, err
+		}
+		r.implementations[address] = implementations
+	}
+
+	var active *types.ProxyImplementation
+	for _, implementation := range implementations {
+		if implementation.BlockNumber > block {
+			break
+		}
+		active = implementation
+	}
+	if active == nil || active.Implementation.IsEmpty() {
+		return nil, nil
+	}
+	return active, nil
+}
+
+// ABI returns the ABI of the contract at the given block. For a proxy, this is the ABI of
+// its implementation followed by the proxy's own ABI, so that calls and events of both
+// can be parsed.
+func (r *Resolver) ABI(address types.Address, block uint64) (string, error) {
+	contractABI, err := r.abi(address)
+	if err != nil {
+		return "", err
+	}
+	implementation, err := r.ImplementationAt(address, block)
+	if err != nil || implementation == nil {
+		return contractABI, err
+	}
+	implementationABI, err := r.abi(implementation.Implementation)
+	if err != nil {
+		return "", err
+	}
+	return mergeABIs(implementationABI, contractABI), nil
+}
+
+// StorageLayout returns the storage layout of the contract at the given block. For a proxy,
+// this is the layout of its implementation if it has one, since the implementation code
+// runs against the storage of the proxy.
+func (r *Resolver) StorageLayout(address types.Address, block uint64) (string, error) {
+	implementation, err := r.ImplementationAt(address, block)
+	if err != nil {
+		return "", err
+	}
+	if implementation != nil {
+		layout, err := r.storageLayout(implementation.Implementation)
+		if err != nil || layout != "" {
+			return layout, err
+		}
+	}
+	return r.storageLayout(address)
+}
+
+func (r *Resolver) abi(address types.Address) (string, error) {
+	if abi, ok := r.abis[address]; ok {
+		return abi, nil
+	}
+	abi, err := r.db.GetContractABI(address)
+	if err != nil {
+		return "", err
+	}
+	r.abis[address] = abi
+	return abi, nil
+}
+
+func (r *Resolver) storageLayout(address types.Address) (string, error) {
+	if layout, ok := r.layouts[address]; ok {
+		return layout, nil
+	}
+	layout, err := r.db.GetStorageLayout(address)
+	if err != nil {
+		return "", err
+	}
+	r.layouts[address] = layout
+	return layout, nil
+}
+
+// mergeABIs concatenates the entries of the given JSON ABIs, skipping any that are empty
+func mergeABIs(abis ...string) string {
+	nonEmpty := make([]string, 0, len(abis))
+	for _, abi := range abis {
+		if abi != "" {
+			nonEmpty = append(nonEmpty, abi)
+		}
+	}
+	if len(nonEmpty) <= 1 {
+		return strings.Join(nonEmpty, "")
+	}
+
+	merged := make([]json.RawMessage, 0)
+	for _, abi := range nonEmpty {
+		var entries []json.RawMessage
+		if err := json.Unmarshal([]byte(abi), &entries); err != nil {
+			continue
+		}
+		merged = append(merged, entries...)
+	}
+	out, _ := json.Marshal(merged)
+	return string(out)
+}
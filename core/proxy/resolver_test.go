@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/types"
+)
+
+const (
+	proxyABI          = `[{"inputs":[{"name":"newImplementation","type":"address"}],"name":"upgradeTo","outputs":[],"stateMutability":"nonpayable","type":"function"}]`
+	implementationABI = `[{"anonymous":false,"inputs":[{"indexed":false,"name":"_value","type":"uint256"}],"name":"valueSet","type":"event"}]`
+)
+
+var (
+	proxyAddress                    = types.NewAddress("0x0000000000000000000000000000000000000001")
+	implementationAddress           = types.NewAddress("0x0000000000000000000000000000000000000002")
+	noTemplateImplementationAddress = types.NewAddress("0x0000000000000000000000000000000000000003")
+)
+
+func setupResolverDB(t *testing.T) *memory.MemoryDB {
+	db := memory.NewMemoryDB()
+	assert.Nil(t, db.AddAddresses([]types.Address{proxyAddress, implementationAddress}))
+	assert.Nil(t, db.AddTemplate("Proxy", proxyABI, "proxy layout"))
+	assert.Nil(t, db.AddTemplate("Implementation", implementationABI, "implementation layout"))
+	assert.Nil(t, db.AssignTemplate(proxyAddress, "Proxy"))
+	assert.Nil(t, db.AssignTemplate(implementationAddress, "Implementation"))
+
+	for _, implementation := range []*types.ProxyImplementation{
+		{Implementation: implementationAddress, Standard: StandardEIP1967, BlockNumber: 10},
+		{Implementation: noTemplateImplementationAddress, Standard: StandardEIP1967, BlockNumber: 20},
+		{Implementation: types.NewAddress(""), BlockNumber: 30},
+	} {
+		assert.Nil(t, db.RecordProxyImplementation(proxyAddress, implementation))
+	}
+	return db
+}
+
+func TestResolver_ImplementationAt(t *testing.T) {
+	resolver := NewResolver(setupResolverDB(t))
+
+	testMatrix := []struct {
+		block    uint64
+		expected types.Address
+	}{
+		{9, ""},
+		{10, implementationAddress},
+		{19, implementationAddress},
+		{20, noTemplateImplementationAddress},
+		{30, ""},
+	}
+	for _, test := range testMatrix {
+		implementation, err := resolver.ImplementationAt(proxyAddress, test.block)
+		assert.Nil(t, err)
+		if test.expected == "" {
+			assert.Nil(t, implementation, "block %d", test.block)
+			continue
+		}
+		assert.Equal(t, test.expected, implementation.Implementation, "block %d", test.block)
+	}
+
+	implementation, err := resolver.ImplementationAt(implementationAddress, 10)
+	assert.Nil(t, err)
+	assert.Nil(t, implementation)
+}
+
+func TestResolver_ABI(t *testing.T) {
+	resolver := NewResolver(setupResolverDB(t))
+
+	abi, err := resolver.ABI(proxyAddress, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, proxyABI, abi)
+
+	// the implementation ABI comes first, followed by the proxy's own
+	abi, err = resolver.ABI(proxyAddress, 15)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `[`+implementationABI[1:len(implementationABI)-1]+`,`+proxyABI[1:], abi)
+
+	abi, err = resolver.ABI(proxyAddress, 25)
+	assert.Nil(t, err)
+	assert.Equal(t, proxyABI, abi)
+}
+
+func TestResolver_StorageLayout(t *testing.T) {
+	resolver := NewResolver(setupResolverDB(t))
+
+	testMatrix := []struct {
+		block    uint64
+		expected string
+	}{
+		{5, "proxy layout"},
+		{15, "implementation layout"},
+		// implementations without a layout fall back to the proxy's own
+		{25, "proxy layout"},
+		{35, "proxy layout"},
+	}
+	for _, test := range testMatrix {
+		layout, err := resolver.StorageLayout(proxyAddress, test.block)
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, layout, "block %d", test.block)
+	}
+}
+
+func TestMergeABIs(t *testing.T) {
+	assert.Equal(t, "", mergeABIs("", ""))
+	assert.Equal(t, proxyABI, mergeABIs("", proxyABI))
+	assert.Equal(t, `[{"a":1},{"b":2},{"c":3}]`, mergeABIs(`[{"a":1}]`, `[{"b":2}, {"c":3}]`))
+}
@@ -3,19 +3,40 @@ package rpc
 import (
 	"encoding/json"
 	"errors"
+	"math"
+	"math/big"
 	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/bluele/gcache"
+
+	"quorumengineering/quorum-report/core/proxy"
 	"quorumengineering/quorum-report/core/storageparsing"
 	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/log"
 	"quorumengineering/quorum-report/types"
 )
 
 type RPCAPIs struct {
 	db                      database.Database
 	contractTemplateManager ContractTemplateManager
+
+	// the events and functions of all stored templates, built when first needed and dropped
+	// whenever a template is added or assigned through the APIs
+	registryMux sync.Mutex
+	registries  *templateRegistries
+
+	// the candidate mapping keys observed for each contract, see observedMappingKeys
+	mappingKeys gcache.Cache
 }
 
 func NewRPCAPIs(db database.Database, contractTemplateManager ContractTemplateManager) *RPCAPIs {
-	return &RPCAPIs{db, contractTemplateManager}
+	return &RPCAPIs{
+		db:                      db,
+		contractTemplateManager: contractTemplateManager,
+		mappingKeys:             gcache.New(mappingKeyCacheSize).LRU().Build(),
+	}
 }
 
 func (r *RPCAPIs) GetLastPersistedBlockNumber(req *http.Request, args *NullArgs, reply *uint64) error {
@@ -27,6 +48,15 @@ func (r *RPCAPIs) GetLastPersistedBlockNumber(req *http.Request, args *NullArgs,
 	return nil
 }
 
+func (r *RPCAPIs) GetChainReorgs(req *http.Request, args *NullArgs, reply *[]*types.ChainReorg) error {
+	reorgs, err := r.db.GetChainReorgs()
+	if err != nil {
+		return err
+	}
+	*reply = reorgs
+	return nil
+}
+
 func (r *RPCAPIs) GetLastFiltered(req *http.Request, args *types.Address, reply *uint64) error {
 	val, err := r.db.GetLastFiltered(*args)
 	if err != nil {
@@ -45,19 +75,23 @@ func (r *RPCAPIs) GetBlock(req *http.Request, blockNumber *uint64, reply *types.
 	return nil
 }
 
-func (r *RPCAPIs) GetTransaction(req *http.Request, hash *types.Hash, reply *types.ParsedTransaction) error {
-	if hash.IsEmpty() {
+func (r *RPCAPIs) GetTransaction(req *http.Request, args *TransactionArgs, reply *types.ParsedTransaction) error {
+	if args.Hash == nil || args.Hash.IsEmpty() {
 		return errors.New("no transaction hash given")
 	}
-	tx, err := r.db.ReadTransaction(*hash)
+	tx, err := r.db.ReadTransaction(*args.Hash)
 	if err != nil {
 		return err
 	}
-	address := tx.To
-	if address.IsEmpty() {
-		address = tx.CreatedContract
+	resolver := proxy.NewResolver(r.db)
+	var contractABI string
+	if tx.To.IsEmpty() {
+		// a creation is parsed with the constructor of the created contract itself, even if
+		// it goes on to be a proxy
+		contractABI, err = r.db.GetContractABI(tx.CreatedContract)
+	} else {
+		contractABI, err = resolver.ABI(tx.To, tx.BlockNumber)
 	}
-	contractABI, err := r.db.GetContractABI(address)
 	if err != nil {
 		return err
 	}
@@ -69,12 +103,17 @@ func (r *RPCAPIs) GetTransaction(req *http.Request, hash *types.Hash, reply *typ
 			return err
 		}
 	}
+	if !tx.Status && parsedTx.RevertError == nil {
+		// reasons and panics are decoded without an ABI
+		parsedTx.RevertError = types.DecodeRevert(tx.RevertData.AsBytes(), nil)
+	}
+	var registry *types.EventRegistry
 	parsedTx.ParsedEvents = make([]*types.ParsedEvent, len(parsedTx.RawTransaction.Events))
 	for i, e := range parsedTx.RawTransaction.Events {
 		parsedTx.ParsedEvents[i] = &types.ParsedEvent{
 			RawEvent: e,
 		}
-		contractABI, err := r.db.GetContractABI(e.Address)
+		contractABI, err := resolver.ABI(e.Address, tx.BlockNumber)
 		if err != nil {
 			return err
 		}
@@ -83,11 +122,165 @@ func (r *RPCAPIs) GetTransaction(req *http.Request, hash *types.Hash, reply *typ
 				return err
 			}
 		}
+		if parsedTx.ParsedEvents[i].Sig != "" {
+			continue
+		}
+		// the emitting contract doesn't know the event, so try all known templates
+		if registry == nil {
+			if registry, err = r.eventRegistry(); err != nil {
+				return err
+			}
+		}
+		// an event that can't be decoded is still returned raw, rather than failing the transaction
+		if err := parsedTx.ParsedEvents[i].ParseEventWithRegistry(registry); err != nil {
+			log.Warn("Could not decode event", "address", e.Address.String(), "err", err)
+		}
+	}
+	if args.DecodeCalls {
+		parsedTx.ParsedCalls = types.NewCallTree(tx.InternalCalls)
+		if err := r.parseCalls(resolver, tx.BlockNumber, parsedTx.ParsedCalls); err != nil {
+			return err
+		}
 	}
 	*reply = *parsedTx
 	return nil
 }
 
+// GetTransactionCallTree returns the tree of internal calls made by the transaction, with
+// each call decoded using the ABI of the contract it called
+func (r *RPCAPIs) GetTransactionCallTree(req *http.Request, hash *types.Hash, reply *[]*types.ParsedCall) error {
+	if hash.IsEmpty() {
+		return errors.New("no transaction hash given")
+	}
+	tx, err := r.db.ReadTransaction(*hash)
+	if err != nil {
+		return err
+	}
+	tree := types.NewCallTree(tx.InternalCalls)
+	if err := r.parseCalls(proxy.NewResolver(r.db), tx.BlockNumber, tree); err != nil {
+		return err
+	}
+	*reply = tree
+	return nil
+}
+
+// parseCalls decodes each call of the tree with the ABI of the contract it called, falling
+// back to the functions of all stored templates if that contract doesn't know the function
+func (r *RPCAPIs) parseCalls(resolver *proxy.Resolver, blockNumber uint64, calls []*types.ParsedCall) error {
+	var registry *types.FunctionRegistry
+	var parse func(calls []*types.ParsedCall) error
+	parse = func(calls []*types.ParsedCall) error {
+		for _, call := range calls {
+			// the input of a creation is the code of the new contract rather than a function call
+			if call.RawCall.Type != "CREATE" && call.RawCall.Type != "CREATE2" {
+				contractABI, err := resolver.ABI(call.RawCall.To, blockNumber)
+				if err != nil {
+					return err
+				}
+				// a call that can't be decoded is still returned raw, rather than failing the tree
+				if contractABI != "" {
+					if err := call.ParseCall(contractABI); err != nil {
+						log.Warn("Could not decode internal call", "to", call.RawCall.To.Hex(), "err", err)
+					}
+				}
+				if call.Sig == "" && len(call.RawCall.Input.AsBytes()) >= 4 {
+					if registry == nil {
+						if registry, err = r.functionRegistry(); err != nil {
+							return err
+						}
+					}
+					if err := call.ParseCallWithRegistry(registry); err != nil {
+						log.Warn("Could not decode internal call", "to", call.RawCall.To.Hex(), "err", err)
+					}
+				}
+			}
+			if err := parse(call.Calls); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return parse(calls)
+}
+
+// templateRegistries are the events and functions of all stored templates, used to decode
+// events and calls whose contract doesn't declare them
+type templateRegistries struct {
+	events    *types.EventRegistry
+	functions *types.FunctionRegistry
+}
+
+// eventRegistry returns the registry of the events in all stored templates
+func (r *RPCAPIs) eventRegistry() (*types.EventRegistry, error) {
+	registries, err := r.templateRegistries()
+	if err != nil {
+		return nil, err
+	}
+	return registries.events, nil
+}
+
+// functionRegistry returns the registry of the functions in all stored templates
+func (r *RPCAPIs) functionRegistry() (*types.FunctionRegistry, error) {
+	registries, err := r.templateRegistries()
+	if err != nil {
+		return nil, err
+	}
+	return registries.functions, nil
+}
+
+// templateRegistries returns the registries of all stored templates, building them if the
+// templates changed since they were last built
+func (r *RPCAPIs) templateRegistries() (*templateRegistries, error) {
+	r.registryMux.Lock()
+	defer r.registryMux.Unlock()
+	if r.registries == nil {
+		registries := &templateRegistries{
+			events:    types.NewEventRegistry(),
+			functions: types.NewFunctionRegistry(),
+		}
+		if err := r.addTemplateABIs(registries.events, registries.functions); err != nil {
+			return nil, err
+		}
+		r.registries = registries
+	}
+	return r.registries, nil
+}
+
+// invalidateRegistries drops the registries built from the stored templates, after a
+// template has been added or assigned
+func (r *RPCAPIs) invalidateRegistries() {
+	r.registryMux.Lock()
+	defer r.registryMux.Unlock()
+	r.registries = nil
+}
+
+// addTemplateABIs adds the ABIs of all stored templates to the registries, in order of
+// template name so that the same definition is chosen when several templates match
+func (r *RPCAPIs) addTemplateABIs(registries ...interface{ AddABI(string) error }) error {
+	names, err := r.db.GetTemplates()
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		template, err := r.db.GetTemplateDetails(name)
+		if err != nil {
+			return err
+		}
+		if template.ABI == "" {
+			continue
+		}
+		for _, registry := range registries {
+			if err := registry.AddABI(template.ABI); err != nil {
+				log.Warn("Skipping template with invalid ABI", "template", name, "err", err)
+				break
+			}
+		}
+	}
+	return nil
+}
+
 func (r *RPCAPIs) GetContractCreationTransaction(req *http.Request, address *types.Address, reply *types.Hash) error {
 	txHash, err := r.db.GetContractCreationTransaction(*address)
 	if err != nil {
@@ -108,6 +301,9 @@ func (r *RPCAPIs) GetAllTransactionsToAddress(req *http.Request, args *AddressWi
 		args.Options = &types.QueryOptions{}
 	}
 	args.Options.SetDefaults()
+	if err := args.Options.ValidateValueRange(); err != nil {
+		return err
+	}
 
 	total, err := r.db.GetTransactionsToAddressTotal(*args.Address, args.Options)
 	if err != nil {
@@ -134,6 +330,9 @@ func (r *RPCAPIs) GetAllTransactionsInternalToAddress(req *http.Request, args *A
 		args.Options = &types.QueryOptions{}
 	}
 	args.Options.SetDefaults()
+	if err := args.Options.ValidateValueRange(); err != nil {
+		return err
+	}
 
 	total, err := r.db.GetTransactionsInternalToAddressTotal(*args.Address, args.Options)
 	if err != nil {
@@ -169,15 +368,17 @@ func (r *RPCAPIs) GetAllEventsFromAddress(req *http.Request, args *AddressWithOp
 	if err != nil {
 		return err
 	}
-	contractABI, err := r.db.GetContractABI(*args.Address)
-	if err != nil {
-		return err
-	}
+	resolver := proxy.NewResolver(r.db)
 	parsedEvents := make([]*types.ParsedEvent, len(events))
 	for i, e := range events {
 		parsedEvents[i] = &types.ParsedEvent{
 			RawEvent: e,
 		}
+		// a proxy may have changed implementation between events
+		contractABI, err := resolver.ABI(*args.Address, e.BlockNumber)
+		if err != nil {
+			return err
+		}
 		if contractABI != "" {
 			if err = parsedEvents[i].ParseEvent(contractABI); err != nil {
 				return err
@@ -234,7 +435,7 @@ func (r *RPCAPIs) GetStorageHistoryCount(req *http.Request, args *AddressWithBlo
 	return nil
 }
 
-func (r *RPCAPIs) GetStorageHistory(req *http.Request, args *AddressWithBlockRange, reply *types.ReportingResponseTemplate) error {
+func (r *RPCAPIs) GetStorageHistory(req *http.Request, args *StorageHistoryArgs, reply *types.ReportingResponseTemplate) error {
 	if args.Address == nil {
 		return ErrNoAddress
 	}
@@ -244,16 +445,31 @@ func (r *RPCAPIs) GetStorageHistory(req *http.Request, args *AddressWithBlockRan
 	}
 	args.Options.SetDefaults()
 
-	rawAbi, err := r.db.GetStorageLayout(*args.Address)
-	if err != nil {
-		return err
-	}
-	if rawAbi == "" {
-		return errors.New("no Storage Layout present to parse with")
+	resolver := proxy.NewResolver(r.db)
+	// a proxy may have changed implementation during the history, so the layout is
+	// resolved for each block, and parsed once for each distinct layout
+	parsedLayouts := make(map[string]types.SolidityStorageDocument)
+	parseLayout := func(blockNumber uint64) (types.SolidityStorageDocument, error) {
+		rawAbi, err := resolver.StorageLayout(*args.Address, blockNumber)
+		if err != nil {
+			return types.SolidityStorageDocument{}, err
+		}
+		if rawAbi == "" {
+			return types.SolidityStorageDocument{}, errors.New("no Storage Layout present to parse with")
+		}
+		if parsedAbi, ok := parsedLayouts[rawAbi]; ok {
+			return parsedAbi, nil
+		}
+		var parsedAbi types.SolidityStorageDocument
+		if err := json.Unmarshal([]byte(rawAbi), &parsedAbi); err != nil {
+			return types.SolidityStorageDocument{}, errors.New("unable to decode Storage Layout: " + err.Error())
+		}
+		parsedLayouts[rawAbi] = parsedAbi
+		return parsedAbi, nil
 	}
-	var parsedAbi types.SolidityStorageDocument
-	if err = json.Unmarshal([]byte(rawAbi), &parsedAbi); err != nil {
-		return errors.New("unable to decode Storage Layout: " + err.Error())
+	// fail early if the contract currently has no layout
+	if _, err := parseLayout(math.MaxUint64); err != nil {
+		return err
 	}
 
 	total, err := r.db.GetStorageTotal(*args.Address, args.Options)
@@ -262,6 +478,9 @@ func (r *RPCAPIs) GetStorageHistory(req *http.Request, args *AddressWithBlockRan
 		return err
 	}
 
+	var observedKeys []string
+	observedKeysFound := false
+
 	historicStates := []*types.ParsedState{}
 	results, err := r.db.GetStorageWithOptions(*args.Address, args.Options)
 	if err != nil {
@@ -273,7 +492,19 @@ func (r *RPCAPIs) GetStorageHistory(req *http.Request, args *AddressWithBlockRan
 			continue
 		}
 
-		historicStorage, err := storageparsing.ParseRawStorage(rawStorage.Storage, parsedAbi)
+		parsedAbi, err := parseLayout(rawStorage.BlockNumber)
+		if err != nil {
+			return err
+		}
+		if !observedKeysFound && hasMapping(parsedAbi) {
+			observedKeys, err = r.observedMappingKeys(*args.Address)
+			if err != nil {
+				return err
+			}
+			observedKeysFound = true
+		}
+
+		historicStorage, err := storageparsing.ParseRawStorage(rawStorage.Storage, parsedAbi, args.MappingKeys, observedKeys)
 		if err != nil {
 			return err
 		}
@@ -291,6 +522,311 @@ func (r *RPCAPIs) GetStorageHistory(req *http.Request, args *AddressWithBlockRan
 	return nil
 }
 
+// observedKeys are the candidate mapping keys found for a contract, from the sources in
+// blocks up to toBlock
+type observedKeys struct {
+	keys    []string
+	toBlock uint64
+}
+
+// observedMappingKeys finds candidate mapping keys from the transactions and events of the
+// contract. The keys are cached for each contract, so that only the blocks it has been
+// filtered to since are read again, and at most maxMappingKeySources sources are read for
+// each call, newest first.
+func (r *RPCAPIs) observedMappingKeys(address types.Address) ([]string, error) {
+	lastFiltered, err := r.db.GetLastFiltered(address)
+	if err != nil {
+		return nil, err
+	}
+	var cached *observedKeys
+	if value, err := r.mappingKeys.Get(address); err == nil {
+		cached = value.(*observedKeys)
+	}
+	if cached != nil && cached.toBlock == lastFiltered {
+		return cached.keys, nil
+	}
+	// a chain reorg may have removed the sources of the cached keys
+	fromBlock := uint64(0)
+	if cached != nil && cached.toBlock < lastFiltered {
+		fromBlock = cached.toBlock + 1
+	} else {
+		cached = nil
+	}
+
+	remaining := maxMappingKeySources
+	nextPage := func(page int) *types.QueryOptions {
+		pageSize := mappingKeySourcePageSize
+		if remaining < pageSize {
+			pageSize = remaining
+		}
+		options := &types.QueryOptions{
+			BeginBlockNumber: new(big.Int).SetUint64(fromBlock),
+			EndBlockNumber:   new(big.Int).SetUint64(lastFiltered),
+			PageSize:         pageSize,
+			PageNumber:       page,
+		}
+		options.SetDefaults()
+		return options
+	}
+
+	txs := make([]*types.Transaction, 0)
+	for _, query := range []func(types.Address, *types.QueryOptions) ([]types.Hash, error){
+		r.db.GetAllTransactionsToAddress,
+		r.db.GetAllTransactionsInternalToAddress,
+	} {
+		for page := 0; remaining > 0; page++ {
+			options := nextPage(page)
+			hashes, err := query(address, options)
+			if err != nil {
+				return nil, err
+			}
+			for _, hash := range hashes {
+				tx, err := r.db.ReadTransaction(hash)
+				if err != nil {
+					return nil, err
+				}
+				txs = append(txs, tx)
+			}
+			remaining -= len(hashes)
+			if len(hashes) < options.PageSize {
+				break
+			}
+		}
+	}
+
+	events := make([]*types.Event, 0)
+	for page := 0; remaining > 0; page++ {
+		options := nextPage(page)
+		pageEvents, err := r.db.GetAllEventsFromAddress(address, options)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, pageEvents...)
+		remaining -= len(pageEvents)
+		if len(pageEvents) < options.PageSize {
+			break
+		}
+	}
+	if remaining <= 0 {
+		log.Warn("Mapping key source limit reached, so mappings may be incomplete", "address", address.String(), "limit", maxMappingKeySources)
+	}
+
+	// keys from the blocks read now come before the cached ones, and only maxMappingKeys are tried
+	keys := storageparsing.CandidateMappingKeys(address, txs, events)
+	if cached != nil {
+		seen := make(map[string]bool, len(keys))
+		for _, key := range keys {
+			seen[key] = true
+		}
+		for _, key := range cached.keys {
+			if !seen[key] {
+				keys = append(keys, key)
+			}
+		}
+	}
+	if len(keys) > maxMappingKeys {
+		keys = keys[:maxMappingKeys]
+	}
+
+	if err := r.mappingKeys.Set(address, &observedKeys{keys: keys, toBlock: lastFiltered}); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func hasMapping(layout types.SolidityStorageDocument) bool {
+	for _, namedType := range layout.Types {
+		if namedType.Encoding == "mapping" {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RPCAPIs) GetProxyImplementations(req *http.Request, address *types.Address, reply *[]*types.ProxyImplementation) error {
+	if address == nil {
+		return ErrNoAddress
+	}
+	result, err := r.db.GetProxyImplementations(*address)
+	if err != nil {
+		return err
+	}
+	*reply = result
+	return nil
+}
+
+// StartTokenBackfill rebuilds the token records of a registered contract from the given block, or from the block it was
+// deployed in if known
+func (r *RPCAPIs) StartTokenBackfill(req *http.Request, args *AddressWithOptionalBlock, reply *types.BackfillJob) error {
+	if args.Address == nil {
+		return ErrNoAddress
+	}
+	addresses, err := r.db.GetAddresses()
+	if err != nil {
+		return err
+	}
+	registered := false
+	for _, address := range addresses {
+		registered = registered || address == *args.Address
+	}
+	if !registered {
+		return errors.New("address is not registered")
+	}
+
+	existing, err := r.db.GetBackfillJob(*args.Address)
+	if err != nil && err != database.ErrNotFound {
+		return err
+	}
+	if existing != nil && existing.Status == types.BackfillRunning {
+		return errors.New("token backfill already running")
+	}
+
+	fromBlock := uint64(1)
+	if args.BlockNumber != nil && *args.BlockNumber > 0 {
+		fromBlock = *args.BlockNumber
+	} else {
+		creationTx, err := r.db.GetContractCreationTransaction(*args.Address)
+		if err != nil {
+			return err
+		}
+		if !creationTx.IsEmpty() {
+			tx, err := r.db.ReadTransaction(creationTx)
+			if err != nil {
+				return err
+			}
+			if tx.BlockNumber > fromBlock {
+				fromBlock = tx.BlockNumber
+			}
+		}
+	}
+	lastFiltered, err := r.db.GetLastFiltered(*args.Address)
+	if err != nil {
+		return err
+	}
+
+	job := &types.BackfillJob{
+		Contract:  *args.Address,
+		FromBlock: fromBlock,
+		ToBlock:   lastFiltered,
+		NextBlock: fromBlock,
+		Status:    types.BackfillRunning,
+	}
+	if err := r.db.RecordBackfillJob(job); err != nil {
+		return err
+	}
+	*reply = *job
+	return nil
+}
+
+func (r *RPCAPIs) GetTokenBackfill(req *http.Request, address *types.Address, reply *types.BackfillJob) error {
+	if address == nil {
+		return ErrNoAddress
+	}
+	job, err := r.db.GetBackfillJob(*address)
+	if err == database.ErrNotFound {
+		return errors.New("no token backfill started for address")
+	}
+	if err != nil {
+		return err
+	}
+	*reply = *job
+	return nil
+}
+
+func (r *RPCAPIs) GetTokenBackfills(req *http.Request, args *NullArgs, reply *[]*types.BackfillJob) error {
+	jobs, err := r.db.GetBackfillJobs()
+	if err != nil {
+		return err
+	}
+	*reply = jobs
+	return nil
+}
+
+// GetNativeTransfers returns a page of the native currency transfers to or from any address, including those made by
+// internal calls, in the order they took place
+func (r *RPCAPIs) GetNativeTransfers(req *http.Request, query *NativeTransfersQuery, reply *NativeTransfersResp) error {
+	if query.Address == nil {
+		return ErrNoAddress
+	}
+	switch query.Direction {
+	case "", types.TransferDirectionIn, types.TransferDirectionOut:
+	default:
+		return errors.New(`direction must be "in" or "out"`)
+	}
+	if query.Options == nil {
+		query.Options = &types.TokenTransferQueryOptions{}
+	}
+	query.Options.SetDefaults()
+
+	transfers, err := r.db.GetNativeTransfers(*query.Address, query.Direction, query.Options)
+	if err != nil {
+		return err
+	}
+
+	next := ""
+	if len(transfers) == query.Options.PageSize {
+		next = types.NativeTransferCursor(transfers[len(transfers)-1])
+	}
+	*reply = NativeTransfersResp{Transfers: transfers, Next: next}
+	return nil
+}
+
+// GetNativeBalance returns the native currency balance of a registered address at each block it changed
+func (r *RPCAPIs) GetNativeBalance(req *http.Request, query *NativeBalanceQuery, reply *map[uint64]*big.Int) error {
+	if query.Address == nil {
+		return ErrNoAddress
+	}
+	if query.Options == nil {
+		query.Options = &types.TokenQueryOptions{}
+	}
+	query.Options.SetDefaults()
+
+	balances, err := r.db.GetNativeBalance(*query.Address, query.Options)
+	if err != nil {
+		return err
+	}
+	*reply = balances
+	return nil
+}
+
+func (r *RPCAPIs) GetNativeBalanceAtBlock(req *http.Request, query *NativeBalanceQuery, reply **big.Int) error {
+	if query.Address == nil {
+		return ErrNoAddress
+	}
+	if query.Block == 0 {
+		return errors.New("block must be provided and not 0")
+	}
+
+	balance, err := r.db.NativeBalanceAtBlock(*query.Address, query.Block)
+	if err == database.ErrNotFound {
+		return errors.New("no native balance recorded for address at block")
+	}
+	if err != nil {
+		return err
+	}
+	*reply = balance
+	return nil
+}
+
+// GetTransactionFailures returns a page of the failed transactions sent to a registered address, most recent first,
+// optionally only those that failed with the given reason
+func (r *RPCAPIs) GetTransactionFailures(req *http.Request, query *TransactionFailuresQuery, reply *[]types.TransactionFailure) error {
+	if query.Address == nil {
+		return ErrNoAddress
+	}
+	if query.Options == nil {
+		query.Options = &types.PageOptions{}
+	}
+	query.Options.SetDefaults()
+
+	failures, err := r.db.GetTransactionFailures(*query.Address, query.Reason, query.Options)
+	if err != nil {
+		return err
+	}
+	*reply = failures
+	return nil
+}
+
 func (r *RPCAPIs) AddAddress(req *http.Request, args *AddressWithOptionalBlock, reply *NullArgs) error {
 	if args.Address == nil {
 		return ErrNoAddress
@@ -334,7 +870,11 @@ func (r *RPCAPIs) AddABI(req *http.Request, args *AddressWithData, reply *NullAr
 	if _, err := types.NewABIStructureFromJSON(args.Data); err != nil {
 		return err
 	}
-	return r.contractTemplateManager.AddContractABI(*args.Address, args.Data)
+	if err := r.contractTemplateManager.AddContractABI(*args.Address, args.Data); err != nil {
+		return err
+	}
+	r.invalidateRegistries()
+	return nil
 }
 
 func (r *RPCAPIs) GetABI(req *http.Request, address *types.Address, reply *string) error {
@@ -355,7 +895,11 @@ func (r *RPCAPIs) AddStorageABI(req *http.Request, args *AddressWithData, reply
 	if err := json.Unmarshal([]byte(args.Data), &storageAbi); err != nil {
 		return errors.New("invalid JSON: " + err.Error())
 	}
-	return r.contractTemplateManager.AddStorageLayout(*args.Address, args.Data)
+	if err := r.contractTemplateManager.AddStorageLayout(*args.Address, args.Data); err != nil {
+		return err
+	}
+	r.invalidateRegistries()
+	return nil
 }
 
 func (r *RPCAPIs) GetStorageABI(req *http.Request, address *types.Address, reply *string) error {
@@ -377,14 +921,22 @@ func (r *RPCAPIs) AddTemplate(req *http.Request, args *TemplateArgs, reply *Null
 	if err := json.Unmarshal([]byte(args.StorageLayout), &storageAbi); err != nil {
 		return errors.New("invalid JSON: " + err.Error())
 	}
-	return r.db.AddTemplate(args.Name, args.Abi, args.StorageLayout)
+	if err := r.db.AddTemplate(args.Name, args.Abi, args.StorageLayout); err != nil {
+		return err
+	}
+	r.invalidateRegistries()
+	return nil
 }
 
 func (r *RPCAPIs) AssignTemplate(req *http.Request, args *AddressWithData, reply *NullArgs) error {
 	if args.Address == nil {
 		return ErrNoAddress
 	}
-	return r.db.AssignTemplate(*args.Address, args.Data)
+	if err := r.db.AssignTemplate(*args.Address, args.Data); err != nil {
+		return err
+	}
+	r.invalidateRegistries()
+	return nil
 }
 
 func (r *RPCAPIs) GetTemplates(req *http.Request, args *NullArgs, result *[]string) error {
@@ -1,6 +1,8 @@
 package rpc
 
 import (
+	"encoding/json"
+	"fmt"
 	"math/big"
 	"net/http"
 	"testing"
@@ -112,20 +114,20 @@ func TestAPIParsing(t *testing.T) {
 	assert.Nil(t, err)
 	// Test GetTransaction parse transaction data.
 	parsedTx1 := &types.ParsedTransaction{}
-	err = apis.GetTransaction(dummyReq, &tx1.Hash, parsedTx1)
+	err = apis.GetTransaction(dummyReq, &TransactionArgs{Hash: &tx1.Hash}, parsedTx1)
 	assert.Nil(t, err)
 	assert.Equal(t, "constructor(uint256 _initVal)", parsedTx1.Sig)
 	assert.Equal(t, big.NewInt(42), parsedTx1.ParsedData["_initVal"])
 
 	parsedTx2 := &types.ParsedTransaction{}
-	err = apis.GetTransaction(dummyReq, &tx2.Hash, parsedTx2)
+	err = apis.GetTransaction(dummyReq, &TransactionArgs{Hash: &tx2.Hash}, parsedTx2)
 	assert.Nil(t, err)
 	assert.Equal(t, "set(uint256 _x)", parsedTx2.Sig)
 	assert.Equal(t, big.NewInt(999), parsedTx2.ParsedData["_x"])
 	assert.Equal(t, "0x60fe47b1", parsedTx2.Func4Bytes.String())
 
 	parsedTx3 := &types.ParsedTransaction{}
-	err = apis.GetTransaction(dummyReq, &tx3.Hash, parsedTx3)
+	err = apis.GetTransaction(dummyReq, &TransactionArgs{Hash: &tx3.Hash}, parsedTx3)
 	assert.Nil(t, err)
 	assert.Equal(t, "event valueSet(uint256 _value)", parsedTx3.ParsedEvents[0].Sig)
 	assert.Equal(t, big.NewInt(1000), parsedTx3.ParsedEvents[0].ParsedData["_value"])
@@ -141,6 +143,83 @@ func TestAPIParsing(t *testing.T) {
 	assert.Equal(t, big.NewInt(1000), eventsResp.Events[0].ParsedData["_value"])
 }
 
+func TestAPIParsing_UndecodableEventLeftRaw(t *testing.T) {
+	const transferABI = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"_from","type":"address"},{"indexed":true,"name":"_to","type":"address"},{"indexed":false,"name":"_value","type":"uint256"}],"name":"Transfer","type":"event"}]`
+	db := memory.NewMemoryDB()
+	apis := NewRPCAPIs(db, NewDefaultContractManager(db))
+	assert.Nil(t, apis.AddAddress(dummyReq, &AddressWithOptionalBlock{Address: &addr}, nil))
+	assert.Nil(t, apis.AddABI(dummyReq, &AddressWithData{&addr, transferABI}, nil))
+
+	// the event has fewer topics than the ABI has indexed arguments
+	tx := &types.Transaction{
+		Hash:        types.NewHash("0x07"),
+		BlockNumber: 1,
+		From:        types.NewAddress("0x0000000000000000000000000000000000000009"),
+		To:          addr,
+		Data:        types.NewHexData("0x12345678"),
+		Events: []*types.Event{{
+			Address:     addr,
+			BlockNumber: 1,
+			Topics: []types.Hash{
+				types.NewHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"),
+				types.NewHash("0x000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d"),
+			},
+			Data: types.NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8"),
+		}},
+	}
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx}))
+	assert.Nil(t, db.IndexBlocks([]types.Address{addr}, []*types.BlockWithTransactions{{Number: 1, Transactions: []*types.Transaction{tx}}}))
+
+	parsedTx := &types.ParsedTransaction{}
+	assert.Nil(t, apis.GetTransaction(dummyReq, &TransactionArgs{Hash: &tx.Hash}, parsedTx))
+	assert.Equal(t, "", parsedTx.ParsedEvents[0].Sig)
+	assert.Equal(t, tx.Events[0], parsedTx.ParsedEvents[0].RawEvent)
+
+	eventsResp := &EventsResp{}
+	assert.Nil(t, apis.GetAllEventsFromAddress(dummyReq, &AddressWithOptions{Address: &addr}, eventsResp))
+	assert.Len(t, eventsResp.Events, 1)
+	assert.Equal(t, "", eventsResp.Events[0].Sig)
+	assert.Nil(t, eventsResp.Events[0].ParsedData)
+}
+
+func TestObservedMappingKeys(t *testing.T) {
+	db := memory.NewMemoryDB()
+	apis := NewRPCAPIs(db, NewDefaultContractManager(db))
+	assert.Nil(t, apis.AddAddress(dummyReq, &AddressWithOptionalBlock{Address: &addr}, nil))
+
+	// each block has a call to the contract from a different sender
+	indexCall := func(blockNumber uint64, from types.Address) {
+		tx := &types.Transaction{
+			Hash:        types.NewHash(fmt.Sprintf("0x%x", blockNumber)),
+			BlockNumber: blockNumber,
+			From:        from,
+			To:          addr,
+			Data:        types.NewHexData("0x12345678"),
+		}
+		assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx}))
+		assert.Nil(t, db.IndexBlocks([]types.Address{addr}, []*types.BlockWithTransactions{{Number: blockNumber, Transactions: []*types.Transaction{tx}}}))
+	}
+	sender1 := "0x0000000000000000000000000000000000000000000000000000000000000011"
+	sender2 := "0x0000000000000000000000000000000000000000000000000000000000000022"
+
+	indexCall(1, types.NewAddress("0x0000000000000000000000000000000000000011"))
+	keys, err := apis.observedMappingKeys(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{sender1}, keys)
+
+	// the cached keys are kept while the contract hasn't been filtered further
+	assert.Nil(t, apis.mappingKeys.Set(addr, &observedKeys{keys: []string{"cached"}, toBlock: 1}))
+	keys, err = apis.observedMappingKeys(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"cached"}, keys)
+
+	// and only the blocks filtered since are read to extend them
+	indexCall(2, types.NewAddress("0x0000000000000000000000000000000000000022"))
+	keys, err = apis.observedMappingKeys(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{sender2, "cached"}, keys)
+}
+
 func TestAddAddressWithFrom(t *testing.T) {
 	db := memory.NewMemoryDB()
 	apis := NewRPCAPIs(db, NewDefaultContractManager(db))
@@ -158,3 +237,421 @@ func TestAddAddressWithFrom(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, from-1, lastFiltered)
 }
+
+func TestStartTokenBackfill(t *testing.T) {
+	db := memory.NewMemoryDB()
+	apis := NewRPCAPIs(db, NewDefaultContractManager(db))
+
+	var job types.BackfillJob
+	err := apis.StartTokenBackfill(dummyReq, &AddressWithOptionalBlock{Address: &addr}, &job)
+	assert.EqualError(t, err, "address is not registered")
+	err = apis.GetTokenBackfill(dummyReq, &addr, &job)
+	assert.EqualError(t, err, "no token backfill started for address")
+
+	// the backfill starts from the block the contract was deployed in
+	from := uint64(100)
+	assert.Nil(t, apis.AddAddress(dummyReq, &AddressWithOptionalBlock{Address: &addr, BlockNumber: &from}, nil))
+	creationTx := &types.Transaction{Hash: types.NewHash("0x1"), BlockNumber: 5, CreatedContract: addr}
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{creationTx}))
+	assert.Nil(t, db.SetContractCreationTransaction(map[types.Hash][]types.Address{creationTx.Hash: {addr}}))
+
+	err = apis.StartTokenBackfill(dummyReq, &AddressWithOptionalBlock{Address: &addr}, &job)
+	assert.Nil(t, err)
+	expected := types.BackfillJob{Contract: addr, FromBlock: 5, ToBlock: 99, NextBlock: 5, Status: types.BackfillRunning}
+	assert.Equal(t, expected, job)
+
+	err = apis.StartTokenBackfill(dummyReq, &AddressWithOptionalBlock{Address: &addr}, &job)
+	assert.EqualError(t, err, "token backfill already running")
+
+	err = apis.GetTokenBackfill(dummyReq, &addr, &job)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, job)
+
+	// a completed backfill can be started again, from a given block
+	expected.Status = types.BackfillCompleted
+	assert.Nil(t, db.RecordBackfillJob(&expected))
+	restartFrom := uint64(50)
+	err = apis.StartTokenBackfill(dummyReq, &AddressWithOptionalBlock{Address: &addr, BlockNumber: &restartFrom}, &job)
+	assert.Nil(t, err)
+	assert.Equal(t, types.BackfillJob{Contract: addr, FromBlock: 50, ToBlock: 99, NextBlock: 50, Status: types.BackfillRunning}, job)
+
+	var jobs []*types.BackfillJob
+	assert.Nil(t, apis.GetTokenBackfills(dummyReq, &NullArgs{}, &jobs))
+	assert.Equal(t, []*types.BackfillJob{&job}, jobs)
+}
+
+func TestAPIParsing_EventsFromTemplateRegistry(t *testing.T) {
+	const libraryABI = `[
+		{"anonymous":false,"inputs":[{"indexed":true,"name":"_from","type":"address"},{"indexed":false,"name":"_value","type":"uint256"}],"name":"Logged","type":"event"},
+		{"anonymous":true,"inputs":[{"indexed":true,"name":"_id","type":"uint256"},{"indexed":false,"name":"_flag","type":"bool"}],"name":"Marked","type":"event"}
+	]`
+	library := types.NewAddress("0x0000000000000000000000000000000000000099")
+
+	db := memory.NewMemoryDB()
+	apis := NewRPCAPIs(db, NewDefaultContractManager(db))
+	assert.Nil(t, apis.AddAddress(dummyReq, &AddressWithOptionalBlock{Address: &addr}, nil))
+	assert.Nil(t, apis.AddABI(dummyReq, &AddressWithData{&addr, validABI}, nil))
+	assert.Nil(t, db.AddTemplate("library", libraryABI, ""))
+
+	tx := &types.Transaction{
+		Hash:        types.NewHash("0x8ac2d8d6eb3aabcb1cd4fa3ec0a4c35f9ea15e0d4ea9e4c3e8cd1a0e0e0e1b01"),
+		BlockNumber: 1,
+		From:        types.NewAddress("0x0000000000000000000000000000000000000009"),
+		To:          addr,
+		Data:        types.NewHexData("0x60fe47b100000000000000000000000000000000000000000000000000000000000003e7"),
+		Events: []*types.Event{
+			{
+				// emitted by the registered contract, but declared in the library
+				Address: addr,
+				Topics: []types.Hash{
+					types.NewHash("0xb6c29e41a72c8b61ca9e08df7aa344673c7102904fc2df6b83d526acf33d812d"), // Logged(address,uint256)
+					types.NewHash("0x000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d"),
+				},
+				Data: types.NewHexData("0x0000000000000000000000000000000000000000000000000000000000000007"),
+			},
+			{
+				// anonymous event emitted by an unregistered library
+				Address: library,
+				Topics:  []types.Hash{types.NewHash("0x05")},
+				Data:    types.NewHexData("0x0000000000000000000000000000000000000000000000000000000000000001"),
+			},
+			{
+				// unknown event
+				Address: library,
+				Topics:  []types.Hash{types.NewHash("0x01"), types.NewHash("0x02")},
+			},
+		},
+	}
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx}))
+
+	parsedTx := &types.ParsedTransaction{}
+	assert.Nil(t, apis.GetTransaction(dummyReq, &TransactionArgs{Hash: &tx.Hash}, parsedTx))
+	assert.Equal(t, "set(uint256 _x)", parsedTx.Sig)
+
+	assert.Equal(t, "event Logged(address _from,uint256 _value)", parsedTx.ParsedEvents[0].Sig)
+	assert.Equal(t, "0xed9d02e382b34818e88b88a309c7fe71e65f419d", parsedTx.ParsedEvents[0].ParsedData["_from"])
+	assert.Equal(t, big.NewInt(7), parsedTx.ParsedEvents[0].ParsedData["_value"])
+
+	assert.Equal(t, "event Marked(uint256 _id,bool _flag) anonymous", parsedTx.ParsedEvents[1].Sig)
+	assert.Equal(t, big.NewInt(5), parsedTx.ParsedEvents[1].ParsedData["_id"])
+	assert.Equal(t, true, parsedTx.ParsedEvents[1].ParsedData["_flag"])
+
+	assert.Equal(t, "", parsedTx.ParsedEvents[2].Sig)
+	assert.Nil(t, parsedTx.ParsedEvents[2].ParsedData)
+}
+
+func TestAPIParsing_TemplateRegistryRebuiltOnChange(t *testing.T) {
+	const libraryABI = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"_from","type":"address"},{"indexed":false,"name":"_value","type":"uint256"}],"name":"Logged","type":"event"}]`
+	library := types.NewAddress("0x0000000000000000000000000000000000000099")
+
+	db := memory.NewMemoryDB()
+	apis := NewRPCAPIs(db, NewDefaultContractManager(db))
+	tx := &types.Transaction{
+		Hash:        types.NewHash("0x8ac2d8d6eb3aabcb1cd4fa3ec0a4c35f9ea15e0d4ea9e4c3e8cd1a0e0e0e1b02"),
+		BlockNumber: 1,
+		From:        types.NewAddress("0x0000000000000000000000000000000000000009"),
+		To:          library,
+		Events: []*types.Event{
+			{
+				Address: library,
+				Topics: []types.Hash{
+					types.NewHash("0xb6c29e41a72c8b61ca9e08df7aa344673c7102904fc2df6b83d526acf33d812d"), // Logged(address,uint256)
+					types.NewHash("0x000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d"),
+				},
+				Data: types.NewHexData("0x0000000000000000000000000000000000000000000000000000000000000007"),
+			},
+			{
+				// same signature, but without the indexed argument
+				Address: library,
+				Topics:  []types.Hash{types.NewHash("0xb6c29e41a72c8b61ca9e08df7aa344673c7102904fc2df6b83d526acf33d812d")},
+				Data:    types.NewHexData("0x0000000000000000000000000000000000000000000000000000000000000007"),
+			},
+		},
+	}
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx}))
+
+	parsedTx := &types.ParsedTransaction{}
+	assert.Nil(t, apis.GetTransaction(dummyReq, &TransactionArgs{Hash: &tx.Hash}, parsedTx))
+	assert.Equal(t, "", parsedTx.ParsedEvents[0].Sig)
+
+	// the registry is rebuilt once a template declaring the event is added
+	assert.Nil(t, apis.AddTemplate(dummyReq, &TemplateArgs{Name: "library", Abi: libraryABI, StorageLayout: "{}"}, nil))
+	parsedTx = &types.ParsedTransaction{}
+	assert.Nil(t, apis.GetTransaction(dummyReq, &TransactionArgs{Hash: &tx.Hash}, parsedTx))
+	assert.Equal(t, "event Logged(address _from,uint256 _value)", parsedTx.ParsedEvents[0].Sig)
+	assert.Equal(t, big.NewInt(7), parsedTx.ParsedEvents[0].ParsedData["_value"])
+
+	// while an event the definition can't decode is left raw
+	assert.Equal(t, "", parsedTx.ParsedEvents[1].Sig)
+	assert.Equal(t, tx.Events[1], parsedTx.ParsedEvents[1].RawEvent)
+}
+
+func TestGetTransactionCallTree(t *testing.T) {
+	caller := types.NewAddress("0x0000000000000000000000000000000000000003")
+	db := memory.NewMemoryDB()
+	apis := NewRPCAPIs(db, NewDefaultContractManager(db))
+	assert.Nil(t, apis.AddAddress(dummyReq, &AddressWithOptionalBlock{Address: &addr}, nil))
+	assert.Nil(t, apis.AddABI(dummyReq, &AddressWithData{&addr, validABI}, nil))
+
+	tx := &types.Transaction{
+		Hash:        types.NewHash("0x03"),
+		BlockNumber: 1,
+		From:        types.NewAddress("0x0000000000000000000000000000000000000009"),
+		To:          caller,
+		InternalCalls: []*types.InternalCall{
+			{From: caller, To: addr, Type: "CALL", Input: types.NewHexData("0x60fe47b100000000000000000000000000000000000000000000000000000000000003e7"), Depth: 1, Parent: -1, Path: []int{0}},
+			{From: addr, To: caller, Type: "CALL", Input: types.NewHexData("0x12345678"), Depth: 2, Parent: 0, Path: []int{0, 0}, Error: "execution reverted", RevertReason: "not allowed"},
+			{From: caller, To: addr, Type: "STATICCALL", Input: types.NewHexData("0x6d4ce63c"), Output: types.NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e7"), Depth: 1, Parent: -1, Path: []int{1}},
+		},
+	}
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx}))
+
+	var tree []*types.ParsedCall
+	assert.Nil(t, apis.GetTransactionCallTree(dummyReq, &tx.Hash, &tree))
+
+	assert.Len(t, tree, 2)
+	assert.Equal(t, "set(uint256 _x)", tree[0].Sig)
+	assert.Equal(t, big.NewInt(999), tree[0].ParsedData["_x"])
+	assert.Len(t, tree[0].Calls, 1)
+	// the caller has no ABI, so its call is left undecoded
+	assert.Equal(t, "", tree[0].Calls[0].Sig)
+	assert.Equal(t, "not allowed", tree[0].Calls[0].RawCall.RevertReason)
+	assert.Equal(t, "get()", tree[1].Sig)
+	assert.Equal(t, big.NewInt(999), tree[1].ParsedOutput["0"])
+	assert.Empty(t, tree[1].Calls)
+}
+
+func TestGetTransaction_DecodeCalls(t *testing.T) {
+	caller := types.NewAddress("0x0000000000000000000000000000000000000003")
+	unregistered := types.NewAddress("0x0000000000000000000000000000000000000004")
+	db := memory.NewMemoryDB()
+	apis := NewRPCAPIs(db, NewDefaultContractManager(db))
+	assert.Nil(t, apis.AddAddress(dummyReq, &AddressWithOptionalBlock{Address: &addr}, nil))
+	assert.Nil(t, apis.AddABI(dummyReq, &AddressWithData{&addr, validABI}, nil))
+
+	tx := &types.Transaction{
+		Hash:        types.NewHash("0x05"),
+		BlockNumber: 1,
+		From:        types.NewAddress("0x0000000000000000000000000000000000000009"),
+		To:          caller,
+		InternalCalls: []*types.InternalCall{
+			{From: caller, To: addr, Type: "CALL", Input: types.NewHexData("0x60fe47b100000000000000000000000000000000000000000000000000000000000003e7"), Depth: 1, Parent: -1, Path: []int{0}},
+			// the called contract has no ABI, but a stored template knows the function
+			{From: addr, To: unregistered, Type: "STATICCALL", Input: types.NewHexData("0x6d4ce63c"), Output: types.NewHexData("0x000000000000000000000000000000000000000000000000000000000000002a"), Depth: 2, Parent: 0, Path: []int{0, 0}},
+			{From: caller, To: unregistered, Type: "CALL", Input: types.NewHexData("0x12345678"), Depth: 1, Parent: -1, Path: []int{1}},
+		},
+	}
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx}))
+
+	parsedTx := &types.ParsedTransaction{}
+	assert.Nil(t, apis.GetTransaction(dummyReq, &TransactionArgs{Hash: &tx.Hash}, parsedTx))
+	assert.Nil(t, parsedTx.ParsedCalls)
+
+	parsedTx = &types.ParsedTransaction{}
+	assert.Nil(t, apis.GetTransaction(dummyReq, &TransactionArgs{Hash: &tx.Hash, DecodeCalls: true}, parsedTx))
+	assert.Len(t, parsedTx.ParsedCalls, 2)
+	assert.Equal(t, "set(uint256 _x)", parsedTx.ParsedCalls[0].Sig)
+	assert.Equal(t, big.NewInt(999), parsedTx.ParsedCalls[0].ParsedData["_x"])
+	assert.Len(t, parsedTx.ParsedCalls[0].Calls, 1)
+	assert.Equal(t, "get()", parsedTx.ParsedCalls[0].Calls[0].Sig)
+	assert.Equal(t, big.NewInt(42), parsedTx.ParsedCalls[0].Calls[0].ParsedOutput["0"])
+	// no template knows the function, so the call is left undecoded
+	assert.Equal(t, "", parsedTx.ParsedCalls[1].Sig)
+	assert.EqualValues(t, "12345678", parsedTx.ParsedCalls[1].Func4Bytes)
+}
+
+func TestGetTransactionCallTree_SharesTemplateRegistries(t *testing.T) {
+	unregistered := types.NewAddress("0x0000000000000000000000000000000000000004")
+	db := memory.NewMemoryDB()
+	apis := NewRPCAPIs(db, NewDefaultContractManager(db))
+
+	tx := &types.Transaction{
+		Hash:        types.NewHash("0x06"),
+		BlockNumber: 1,
+		From:        types.NewAddress("0x0000000000000000000000000000000000000009"),
+		To:          unregistered,
+		InternalCalls: []*types.InternalCall{
+			{From: unregistered, To: unregistered, Type: "STATICCALL", Input: types.NewHexData("0x6d4ce63c"), Output: types.NewHexData("0x000000000000000000000000000000000000000000000000000000000000002a"), Depth: 1, Parent: -1, Path: []int{0}},
+		},
+	}
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx}))
+
+	var tree []*types.ParsedCall
+	assert.Nil(t, apis.GetTransactionCallTree(dummyReq, &tx.Hash, &tree))
+	assert.Equal(t, "", tree[0].Sig)
+
+	// the registries are rebuilt together once a template declaring the function is added
+	assert.Nil(t, apis.AddTemplate(dummyReq, &TemplateArgs{Name: "simple", Abi: validABI, StorageLayout: "{}"}, nil))
+	assert.Nil(t, apis.GetTransactionCallTree(dummyReq, &tx.Hash, &tree))
+	assert.Equal(t, "get()", tree[0].Sig)
+	registries := apis.registries
+	assert.NotNil(t, registries)
+
+	// and reused by later lookups of either kind
+	_, err := apis.eventRegistry()
+	assert.Nil(t, err)
+	assert.Nil(t, apis.GetTransactionCallTree(dummyReq, &tx.Hash, &tree))
+	assert.True(t, registries == apis.registries)
+}
+
+func TestTransactionArgs_UnmarshalJSON(t *testing.T) {
+	hash := types.NewHash("0x1a6f4292bac138df9a7854a07c93fd14ca7de53265e8fe01b6c986f97d6c1ee7")
+
+	var args TransactionArgs
+	assert.Nil(t, json.Unmarshal([]byte(`"0x1a6f4292bac138df9a7854a07c93fd14ca7de53265e8fe01b6c986f97d6c1ee7"`), &args))
+	assert.Equal(t, TransactionArgs{Hash: &hash}, args)
+
+	args = TransactionArgs{}
+	assert.Nil(t, json.Unmarshal([]byte(`{"hash": "0x1a6f4292bac138df9a7854a07c93fd14ca7de53265e8fe01b6c986f97d6c1ee7", "decodeCalls": true}`), &args))
+	assert.Equal(t, TransactionArgs{Hash: &hash, DecodeCalls: true}, args)
+}
+
+func TestGetTransaction_RevertError(t *testing.T) {
+	db := memory.NewMemoryDB()
+	apis := NewRPCAPIs(db, NewDefaultContractManager(db))
+
+	// Error("not owner"), sent to an address without an ABI
+	tx := &types.Transaction{
+		Hash:        types.NewHash("0x04"),
+		BlockNumber: 1,
+		From:        types.NewAddress("0x0000000000000000000000000000000000000009"),
+		To:          types.NewAddress("0x0000000000000000000000000000000000000003"),
+		Error:       "execution reverted",
+		RevertData: types.NewHexData("0x08c379a0" +
+			"0000000000000000000000000000000000000000000000000000000000000020" +
+			"0000000000000000000000000000000000000000000000000000000000000009" +
+			"6e6f74206f776e65720000000000000000000000000000000000000000000000"),
+	}
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx}))
+
+	parsedTx := &types.ParsedTransaction{}
+	assert.Nil(t, apis.GetTransaction(dummyReq, &TransactionArgs{Hash: &tx.Hash}, parsedTx))
+	assert.Equal(t, "Error(string)", parsedTx.RevertError.Sig)
+	assert.Equal(t, "not owner", parsedTx.RevertError.Reason)
+}
+
+func TestGetTransactionFailures(t *testing.T) {
+	db := memory.NewMemoryDB()
+	apis := NewRPCAPIs(db, NewDefaultContractManager(db))
+	assert.Nil(t, db.RecordTransactionFailures([]types.TransactionFailure{
+		{Address: addr, TransactionHash: types.NewHash("0x01"), BlockNumber: 3, Reason: "not owner"},
+		{Address: addr, TransactionHash: types.NewHash("0x02"), BlockNumber: 5, Error: "out of gas"},
+		{Address: addr, TransactionHash: types.NewHash("0x03"), BlockNumber: 7, Reason: "not owner"},
+	}))
+
+	var failures []types.TransactionFailure
+	err := apis.GetTransactionFailures(dummyReq, &TransactionFailuresQuery{}, &failures)
+	assert.Equal(t, ErrNoAddress, err)
+
+	assert.Nil(t, apis.GetTransactionFailures(dummyReq, &TransactionFailuresQuery{Address: &addr}, &failures))
+	assert.Len(t, failures, 3)
+	assert.Equal(t, uint64(7), failures[0].BlockNumber)
+
+	assert.Nil(t, apis.GetTransactionFailures(dummyReq, &TransactionFailuresQuery{Address: &addr, Reason: "not owner"}, &failures))
+	assert.Len(t, failures, 2)
+	assert.Equal(t, types.NewHash("0x03"), failures[0].TransactionHash)
+	assert.Equal(t, types.NewHash("0x01"), failures[1].TransactionHash)
+}
+
+func TestAPIParsing_ProxyImplementation(t *testing.T) {
+	const proxyABI = `[
+		{"inputs":[{"name":"newImplementation","type":"address"}],"name":"upgradeTo","outputs":[],"stateMutability":"nonpayable","type":"function"}
+	]`
+	proxyAddress := types.NewAddress("0x0000000000000000000000000000000000000002")
+
+	db := memory.NewMemoryDB()
+	apis := NewRPCAPIs(db, NewDefaultContractManager(db))
+	assert.Nil(t, apis.AddAddress(dummyReq, &AddressWithOptionalBlock{Address: &addr}, nil))
+	assert.Nil(t, apis.AddAddress(dummyReq, &AddressWithOptionalBlock{Address: &proxyAddress}, nil))
+	assert.Nil(t, apis.AddABI(dummyReq, &AddressWithData{&addr, validABI}, nil))
+	assert.Nil(t, apis.AddABI(dummyReq, &AddressWithData{&proxyAddress, proxyABI}, nil))
+	assert.Nil(t, db.RecordProxyImplementation(proxyAddress, &types.ProxyImplementation{Implementation: addr, Standard: "eip1967", BlockNumber: 2}))
+
+	setTx := func(hash string, blockNumber uint64) *types.Transaction {
+		return &types.Transaction{
+			Hash:        types.NewHash(hash),
+			BlockNumber: blockNumber,
+			From:        types.NewAddress("0x0000000000000000000000000000000000000009"),
+			To:          proxyAddress,
+			Data:        types.NewHexData("0x60fe47b100000000000000000000000000000000000000000000000000000000000003e7"),
+			Events: []*types.Event{
+				{
+					Data:        types.NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e7"),
+					Address:     proxyAddress,
+					BlockNumber: blockNumber,
+					Topics:      []types.Hash{types.NewHash("0xefe5cb8d23d632b5d2cdd9f0a151c4b1a84ccb7afa1c57331009aa922d5e4f36")},
+				},
+			},
+		}
+	}
+	// before the implementation was set, and after
+	beforeTx := setTx("0x01", 1)
+	afterTx := setTx("0x02", 2)
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{beforeTx, afterTx}))
+
+	parsedTx := &types.ParsedTransaction{}
+	assert.Nil(t, apis.GetTransaction(dummyReq, &TransactionArgs{Hash: &beforeTx.Hash}, parsedTx))
+	assert.Equal(t, "", parsedTx.Sig)
+
+	parsedTx = &types.ParsedTransaction{}
+	assert.Nil(t, apis.GetTransaction(dummyReq, &TransactionArgs{Hash: &afterTx.Hash}, parsedTx))
+	assert.Equal(t, "set(uint256 _x)", parsedTx.Sig)
+	assert.Equal(t, big.NewInt(999), parsedTx.ParsedData["_x"])
+	assert.Equal(t, "event valueSet(uint256 _value)", parsedTx.ParsedEvents[0].Sig)
+
+	var implementations []*types.ProxyImplementation
+	assert.Nil(t, apis.GetProxyImplementations(dummyReq, &proxyAddress, &implementations))
+	assert.Equal(t, []*types.ProxyImplementation{{Implementation: addr, Standard: "eip1967", BlockNumber: 2}}, implementations)
+}
+
+func TestGetNativeTransfers(t *testing.T) {
+	db := memory.NewMemoryDB()
+	apis := NewRPCAPIs(db, NewDefaultContractManager(db))
+
+	other := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+	deposit := types.NativeTransfer{From: other, To: addr, Value: "10", Type: "CALL", BlockNumber: 1, Index: 0}
+	withdrawal := types.NativeTransfer{From: addr, To: other, Value: "5", Type: "CALL", BlockNumber: 2, Index: 0}
+	assert.Nil(t, db.RecordNativeTransfers([]types.NativeTransfer{deposit, withdrawal}))
+
+	var resp NativeTransfersResp
+	err := apis.GetNativeTransfers(dummyReq, &NativeTransfersQuery{Direction: types.TransferDirectionIn}, &resp)
+	assert.Equal(t, ErrNoAddress, err)
+	err = apis.GetNativeTransfers(dummyReq, &NativeTransfersQuery{Address: &addr, Direction: "sideways"}, &resp)
+	assert.EqualError(t, err, `direction must be "in" or "out"`)
+
+	// a full page gives the cursor to continue from
+	query := &NativeTransfersQuery{Address: &addr, Options: &types.TokenTransferQueryOptions{PageSize: 1}}
+	assert.Nil(t, apis.GetNativeTransfers(dummyReq, query, &resp))
+	assert.Equal(t, NativeTransfersResp{Transfers: []types.NativeTransfer{deposit}, Next: "1-0"}, resp)
+	query.Options.After = resp.Next
+	assert.Nil(t, apis.GetNativeTransfers(dummyReq, query, &resp))
+	assert.Equal(t, NativeTransfersResp{Transfers: []types.NativeTransfer{withdrawal}, Next: "2-0"}, resp)
+
+	assert.Nil(t, apis.GetNativeTransfers(dummyReq, &NativeTransfersQuery{Address: &addr, Direction: types.TransferDirectionOut}, &resp))
+	assert.Equal(t, NativeTransfersResp{Transfers: []types.NativeTransfer{withdrawal}}, resp)
+
+	// larger pages are reduced to the maximum page size
+	query = &NativeTransfersQuery{Address: &addr, Options: &types.TokenTransferQueryOptions{PageSize: 1000000}}
+	assert.Nil(t, apis.GetNativeTransfers(dummyReq, query, &resp))
+	assert.Equal(t, types.MaxTransferPageSize, query.Options.PageSize)
+	assert.Equal(t, NativeTransfersResp{Transfers: []types.NativeTransfer{deposit, withdrawal}}, resp)
+}
+
+func TestGetNativeBalance(t *testing.T) {
+	db := memory.NewMemoryDB()
+	apis := NewRPCAPIs(db, NewDefaultContractManager(db))
+	assert.Nil(t, db.RecordNativeBalance(addr, 3, big.NewInt(100)))
+	assert.Nil(t, db.RecordNativeBalance(addr, 7, big.NewInt(60)))
+
+	var balance *big.Int
+	err := apis.GetNativeBalanceAtBlock(dummyReq, &NativeBalanceQuery{Address: &addr}, &balance)
+	assert.EqualError(t, err, "block must be provided and not 0")
+	err = apis.GetNativeBalanceAtBlock(dummyReq, &NativeBalanceQuery{Address: &addr, Block: 2}, &balance)
+	assert.EqualError(t, err, "no native balance recorded for address at block")
+	assert.Nil(t, apis.GetNativeBalanceAtBlock(dummyReq, &NativeBalanceQuery{Address: &addr, Block: 5}, &balance))
+	assert.Equal(t, big.NewInt(100), balance)
+
+	var balances map[uint64]*big.Int
+	assert.Nil(t, apis.GetNativeBalance(dummyReq, &NativeBalanceQuery{Address: &addr}, &balances))
+	assert.Equal(t, map[uint64]*big.Int{3: big.NewInt(100), 7: big.NewInt(60)}, balances)
+}
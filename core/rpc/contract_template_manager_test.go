@@ -13,6 +13,7 @@ func TestDefaultContractManager_AddContractABI_ExistingTemplate(t *testing.T) {
 	address := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
 
 	db := memory.NewMemoryDB()
+	_ = db.AddAddresses([]types.Address{address})
 	_ = db.AddTemplate("sample template", "sample abi", "sample layout")
 	_ = db.AssignTemplate(address, "sample template")
 
@@ -37,6 +38,7 @@ func TestDefaultContractManager_AddContractABI_ExistingTemplate(t *testing.T) {
 func TestDefaultContractManager_AddContractABI_NoExistingTemplate(t *testing.T) {
 	address := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
 	db := memory.NewMemoryDB()
+	_ = db.AddAddresses([]types.Address{address})
 	contractManager := NewDefaultContractManager(db)
 
 	err := contractManager.AddContractABI(address, "new sample abi")
@@ -59,6 +61,7 @@ func TestDefaultContractManager_AddStorageLayout_ExistingTemplate(t *testing.T)
 	address := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
 
 	db := memory.NewMemoryDB()
+	_ = db.AddAddresses([]types.Address{address})
 	_ = db.AddTemplate("sample template", "sample abi", "sample layout")
 	_ = db.AssignTemplate(address, "sample template")
 
@@ -83,6 +86,7 @@ func TestDefaultContractManager_AddStorageLayout_ExistingTemplate(t *testing.T)
 func TestDefaultContractManager_AddStorageLayout_NoExistingTemplate(t *testing.T) {
 	address := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
 	db := memory.NewMemoryDB()
+	_ = db.AddAddresses([]types.Address{address})
 	contractManager := NewDefaultContractManager(db)
 
 	err := contractManager.AddStorageLayout(address, "new sample layout")
@@ -67,7 +67,7 @@ func SetupRpcServer(db database.Database) *RPCService {
 	}
 	config := types.ReportingConfig{Server: serverConfig}
 
-	return NewRPCService(db, config, errorChan)
+	return NewRPCService(db, config, nil, errorChan)
 }
 
 //TODO: error case
@@ -117,7 +117,7 @@ func TestRPCAPIs_GetBlock_BlockNotExist(t *testing.T) {
 	var errorMessage string
 	_ = json.Unmarshal(rpcResponse.Error, &errorMessage)
 
-	assert.Equal(t, "block does not exist", errorMessage)
+	assert.Equal(t, database.ErrNotFound.Error(), errorMessage)
 	assert.Equal(t, "null", string(rpcResponse.Result))
 }
 
@@ -171,7 +171,7 @@ func TestRPCAPIs_GetContractCreationTransaction_AddressNotIndexed(t *testing.T)
 	var errorMessage string
 	_ = json.Unmarshal(rpcResponse.Error, &errorMessage)
 
-	assert.Equal(t, "address is not registered", errorMessage)
+	assert.Equal(t, database.ErrNotFound.Error(), errorMessage)
 	assert.Equal(t, "null", string(rpcResponse.Result))
 }
 
@@ -224,6 +224,23 @@ func TestRPCAPIs_GetAllTransactionsInternalToAddress(t *testing.T) {
 	assert.Equal(t, result.Options, expectedOptions)
 }
 
+func TestRPCAPIs_GetAllTransactionsToAddress_InvalidValueRange(t *testing.T) {
+	msg := rpcMessage{
+		Version: "2.0",
+		ID:      "67",
+		Method:  "reporting.GetAllTransactionsToAddress",
+		Params:  json.RawMessage(`[{"address": "0x0000000000000000000000000000000000000001", "options": {"beginValue": "-5"}}]`),
+	}
+
+	rpcResponse, err := doRequest(msg)
+	assert.Nil(t, err)
+
+	var errorMessage string
+	_ = json.Unmarshal(rpcResponse.Error, &errorMessage)
+	assert.Equal(t, "beginValue must be between 0 and 2^256-1", errorMessage)
+	assert.Equal(t, "null", string(rpcResponse.Result))
+}
+
 func TestNewRPCAPIs_AddAddress_WithEmptyAddress(t *testing.T) {
 	msg := rpcMessage{
 		Version: "2.0",
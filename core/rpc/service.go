@@ -11,6 +11,8 @@ import (
 	"github.com/gorilla/rpc/v2/json"
 	"github.com/rs/cors"
 
+	"quorumengineering/quorum-report/core/filter/token"
+	"quorumengineering/quorum-report/core/subscription"
 	"quorumengineering/quorum-report/database"
 	"quorumengineering/quorum-report/log"
 	"quorumengineering/quorum-report/types"
@@ -20,12 +22,17 @@ const (
 	ReadTimeout  = 30 * time.Second
 	WriteTimeout = 30 * time.Second
 	IdleTimeout  = 120 * time.Second
+
+	// SubscriptionPath is the path WebSocket subscriptions are served on
+	SubscriptionPath = "/subscribe"
 )
 
 type RPCService struct {
-	cors        []string
-	httpAddress string
-	db          database.Database
+	cors           []string
+	httpAddress    string
+	db             database.Database
+	hub            *subscription.Hub
+	tokenStandards []string
 
 	httpServer *http.Server
 
@@ -33,11 +40,13 @@ type RPCService struct {
 	shutdownWg             sync.WaitGroup
 }
 
-func NewRPCService(db database.Database, config types.ReportingConfig, backendErrorChan chan error) *RPCService {
+func NewRPCService(db database.Database, config types.ReportingConfig, hub *subscription.Hub, backendErrorChan chan error) *RPCService {
 	return &RPCService{
-		cors:        config.Server.RPCCorsList,
-		httpAddress: config.Server.RPCAddr,
-		db:          db,
+		cors:           config.Server.RPCCorsList,
+		httpAddress:    config.Server.RPCAddr,
+		db:             db,
+		hub:            hub,
+		tokenStandards: token.EventStandards(config.Tokens),
 
 		httpServerErrorChannel: backendErrorChan,
 	}
@@ -54,11 +63,21 @@ func (r *RPCService) Start() error {
 	if err := jsonrpcServer.RegisterService(NewTokenRPCAPIs(r.db), "token"); err != nil {
 		return err
 	}
+	// each token standard processed from its events is served under its own namespace
+	for _, standard := range r.tokenStandards {
+		if err := jsonrpcServer.RegisterService(NewTokenStandardRPCAPIs(r.db, standard), standard); err != nil {
+			return err
+		}
+	}
 
-	serverWithCors := cors.New(cors.Options{AllowedOrigins: r.cors}).Handler(jsonrpcServer)
+	mux := http.NewServeMux()
+	mux.Handle("/", cors.New(cors.Options{AllowedOrigins: r.cors}).Handler(jsonrpcServer))
+	if r.hub != nil {
+		mux.Handle(SubscriptionPath, subscription.NewWebSocketHandler(r.hub, r.cors))
+	}
 	r.httpServer = &http.Server{
 		Addr:    r.httpAddress,
-		Handler: serverWithCors,
+		Handler: mux,
 
 		ReadTimeout:  ReadTimeout,
 		WriteTimeout: WriteTimeout,
@@ -75,6 +94,9 @@ func (r *RPCService) Start() error {
 	}()
 
 	log.Info("JSON-RPC HTTP endpoint opened", "url", fmt.Sprintf("http://%s", r.httpServer.Addr))
+	if r.hub != nil {
+		log.Info("Subscription WebSocket endpoint opened", "url", fmt.Sprintf("ws://%s%s", r.httpServer.Addr, SubscriptionPath))
+	}
 	return nil
 }
 
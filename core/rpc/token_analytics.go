@@ -0,0 +1,100 @@
+package rpc
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+
+	"quorumengineering/quorum-report/types"
+)
+
+type holderBalance struct {
+	holder  types.Address
+	balance *big.Int
+}
+
+// rankHolders orders the holders by balance, largest first, with equal balances ordered
+// by address
+func rankHolders(balances map[types.Address]*big.Int) []holderBalance {
+	ranked := make([]holderBalance, 0, len(balances))
+	for holder, balance := range balances {
+		ranked = append(ranked, holderBalance{holder, balance})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if cmp := ranked[i].balance.Cmp(ranked[j].balance); cmp != 0 {
+			return cmp > 0
+		}
+		return ranked[i].holder < ranked[j].holder
+	})
+	return ranked
+}
+
+func sumBalances(ranked []holderBalance) *big.Int {
+	total := new(big.Int)
+	for _, h := range ranked {
+		total.Add(total, h.balance)
+	}
+	return total
+}
+
+// percentage returns the amount as a percentage of the total, which is zero if the total is
+func percentage(amount *big.Int, total *big.Int) float64 {
+	if total.Sign() == 0 {
+		return 0
+	}
+	result, _ := new(big.Rat).SetFrac(new(big.Int).Mul(amount, big.NewInt(100)), total).Float64()
+	return result
+}
+
+// giniCoefficient calculates the Gini coefficient of the ranked balances as
+// 2 * sum(i * x_i) / (n * sum(x_i)) - (n + 1) / n, with the balances x_i in ascending order
+func giniCoefficient(ranked []holderBalance) float64 {
+	n := int64(len(ranked))
+	total := sumBalances(ranked)
+	if n == 0 || total.Sign() == 0 {
+		return 0
+	}
+
+	weighted := new(big.Int)
+	for i, h := range ranked {
+		weighted.Add(weighted, new(big.Int).Mul(big.NewInt(n-int64(i)), h.balance))
+	}
+	gini := new(big.Rat).SetFrac(new(big.Int).Mul(weighted, big.NewInt(2)), new(big.Int).Mul(total, big.NewInt(n)))
+	gini.Sub(gini, big.NewRat(n+1, n))
+	result, _ := gini.Float64()
+	return result
+}
+
+// holderCountHistory returns the number of holders with a non-zero balance at the beginning
+// block, keyed by the beginning block, and at each later block it changed in, from the holders
+// at the beginning block and the balance changes after it ordered by block
+func holderCountHistory(holders map[types.Address]*big.Int, changes []types.ERC20BalanceChange, beginBlock uint64) (map[uint64]uint64, error) {
+	holding := make(map[types.Address]bool, len(holders))
+	for holder := range holders {
+		holding[holder] = true
+	}
+	lastCount := uint64(len(holding))
+	counts := map[uint64]uint64{beginBlock: lastCount}
+	for i, change := range changes {
+		amount, success := new(big.Int).SetString(change.Amount, 10)
+		if !success {
+			return nil, errors.New("could not parse token value")
+		}
+		if amount.Sign() == 0 {
+			delete(holding, change.Holder)
+		} else {
+			holding[change.Holder] = true
+		}
+
+		// count once all the changes in the block have been applied
+		if i+1 < len(changes) && changes[i+1].BlockNumber == change.BlockNumber {
+			continue
+		}
+		count := uint64(len(holding))
+		if count != lastCount {
+			counts[change.BlockNumber] = count
+		}
+		lastCount = count
+	}
+	return counts, nil
+}
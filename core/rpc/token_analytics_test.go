@@ -0,0 +1,174 @@
+package rpc
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/types"
+)
+
+var (
+	holderA = types.NewAddress("0x000000000000000000000000000000000000000a")
+	holderB = types.NewAddress("0x000000000000000000000000000000000000000b")
+	holderC = types.NewAddress("0x000000000000000000000000000000000000000c")
+)
+
+func TestRankHolders(t *testing.T) {
+	ranked := rankHolders(map[types.Address]*big.Int{
+		holderC: big.NewInt(10),
+		holderA: big.NewInt(5),
+		holderB: big.NewInt(10),
+	})
+
+	assert.Equal(t, []holderBalance{
+		{holderB, big.NewInt(10)},
+		{holderC, big.NewInt(10)},
+		{holderA, big.NewInt(5)},
+	}, ranked)
+}
+
+func TestGiniCoefficient(t *testing.T) {
+	assert.Equal(t, float64(0), giniCoefficient(nil))
+	assert.Equal(t, float64(0), giniCoefficient([]holderBalance{{holderA, big.NewInt(7)}}))
+	assert.Equal(t, float64(0), giniCoefficient([]holderBalance{{holderA, big.NewInt(5)}, {holderB, big.NewInt(5)}}))
+	assert.Equal(t, 0.25, giniCoefficient([]holderBalance{{holderA, big.NewInt(3)}, {holderB, big.NewInt(1)}}))
+	assert.InDelta(t, 0.8, giniCoefficient([]holderBalance{
+		{holderA, big.NewInt(1000)}, {holderB, big.NewInt(0)}, {holderC, big.NewInt(0)},
+		{types.NewAddress("0x0d"), big.NewInt(0)}, {types.NewAddress("0x0e"), big.NewInt(0)},
+	}), 1e-9)
+}
+
+func TestHolderCountHistory(t *testing.T) {
+	changes := []types.ERC20BalanceChange{
+		{Holder: holderA, BlockNumber: 1, Amount: "100"},
+		{Holder: holderA, BlockNumber: 2, Amount: "60"},
+		{Holder: holderB, BlockNumber: 2, Amount: "40"},
+		{Holder: holderA, BlockNumber: 4, Amount: "0"},
+		{Holder: holderC, BlockNumber: 4, Amount: "60"},
+		{Holder: holderB, BlockNumber: 6, Amount: "0"},
+	}
+
+	counts, err := holderCountHistory(nil, changes, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]uint64{0: 0, 1: 1, 2: 2, 6: 1}, counts)
+
+	// the holders at the beginning block are counted before the changes after it
+	holders := map[types.Address]*big.Int{holderA: big.NewInt(60), holderB: big.NewInt(40)}
+	counts, err = holderCountHistory(holders, changes[3:], 3)
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]uint64{3: 2, 6: 1}, counts)
+
+	_, err = holderCountHistory(nil, []types.ERC20BalanceChange{{Holder: holderA, BlockNumber: 1, Amount: "a"}}, 0)
+	assert.EqualError(t, err, "could not parse token value")
+}
+
+func TestTokenRPCAPIs_GetTopHolders(t *testing.T) {
+	db := memory.NewMemoryDB()
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holderA, 1, big.NewInt(600)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holderB, 1, big.NewInt(300)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holderC, 1, big.NewInt(100)))
+	apis := NewTokenRPCAPIs(db)
+
+	// without a recorded supply, the balances make up the supply
+	var reply TopHoldersResp
+	err := apis.GetTopHolders(dummyReq, &TokenHoldersQuery{Contract: &addr, Block: 1, Count: 2}, &reply)
+	assert.Nil(t, err)
+	assert.Equal(t, TopHoldersResp{
+		Holders: []TokenHolderShare{
+			{Rank: 1, Holder: holderA, Balance: "600", Percentage: 60},
+			{Rank: 2, Holder: holderB, Balance: "300", Percentage: 30},
+		},
+		HolderCount: 3,
+		TotalSupply: "1000",
+	}, reply)
+
+	assert.Nil(t, db.RecordTotalSupply(addr, 1, big.NewInt(2000)))
+	err = apis.GetTopHolders(dummyReq, &TokenHoldersQuery{Contract: &addr, Block: 1, Count: 1}, &reply)
+	assert.Nil(t, err)
+	assert.Equal(t, []TokenHolderShare{{Rank: 1, Holder: holderA, Balance: "600", Percentage: 30}}, reply.Holders)
+	assert.Equal(t, "2000", reply.TotalSupply)
+
+	err = apis.GetTopHolders(dummyReq, &TokenHoldersQuery{Contract: &addr, Block: 1, Count: 1001}, &reply)
+	assert.EqualError(t, err, "count must be between 1 and 1000")
+}
+
+func TestTokenRPCAPIs_GetHolderConcentration(t *testing.T) {
+	db := memory.NewMemoryDB()
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holderA, 1, big.NewInt(300)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holderB, 1, big.NewInt(100)))
+	apis := NewTokenRPCAPIs(db)
+
+	var reply TokenConcentrationResp
+	err := apis.GetHolderConcentration(dummyReq, &TokenConcentrationQuery{Contract: &addr, Block: 1}, &reply)
+	assert.Nil(t, err)
+	assert.Equal(t, TokenConcentrationResp{
+		HolderCount: 2,
+		TotalSupply: "400",
+		Gini:        0.25,
+		TopShares:   map[int]float64{1: 75, 10: 100, 100: 100},
+	}, reply)
+
+	err = apis.GetHolderConcentration(dummyReq, &TokenConcentrationQuery{Contract: &addr, Block: 1, TopN: []int{0}}, &reply)
+	assert.EqualError(t, err, "number of top holders must be at least 1")
+}
+
+func TestTokenRPCAPIs_GetHolderCountHistory(t *testing.T) {
+	db := memory.NewMemoryDB()
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holderA, 1, big.NewInt(300)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holderB, 2, big.NewInt(100)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holderA, 5, big.NewInt(0)))
+	apis := NewTokenRPCAPIs(db)
+
+	var reply map[uint64]uint64
+	err := apis.GetHolderCountHistory(dummyReq, &ERC20TokenQuery{Contract: &addr}, &reply)
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]uint64{0: 0, 1: 1, 2: 2, 5: 1}, reply)
+
+	options := &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(3), EndBlockNumber: big.NewInt(4)}
+	err = apis.GetHolderCountHistory(dummyReq, &ERC20TokenQuery{Contract: &addr, Options: options}, &reply)
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]uint64{3: 2}, reply)
+
+	// the change at the beginning block is part of the count at it
+	options = &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(5)}
+	err = apis.GetHolderCountHistory(dummyReq, &ERC20TokenQuery{Contract: &addr, Options: options}, &reply)
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]uint64{5: 1}, reply)
+
+	options = &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(1), EndBlockNumber: big.NewInt(maxHolderHistoryBlocks + 1)}
+	err = apis.GetHolderCountHistory(dummyReq, &ERC20TokenQuery{Contract: &addr, Options: options}, &reply)
+	assert.EqualError(t, err, "block range must be at most 100000 blocks")
+
+	options = &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(4), EndBlockNumber: big.NewInt(3)}
+	err = apis.GetHolderCountHistory(dummyReq, &ERC20TokenQuery{Contract: &addr, Options: options}, &reply)
+	assert.EqualError(t, err, "end block must not be before the begin block")
+}
+
+func TestTokenRPCAPIs_HolderLimit(t *testing.T) {
+	defer func(limit uint64) { maxHolderAnalyticsHolders = limit }(maxHolderAnalyticsHolders)
+	maxHolderAnalyticsHolders = 2
+
+	db := memory.NewMemoryDB()
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holderA, 1, big.NewInt(300)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holderB, 1, big.NewInt(100)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holderC, 2, big.NewInt(100)))
+	apis := NewTokenRPCAPIs(db)
+
+	var concentration TokenConcentrationResp
+	err := apis.GetHolderConcentration(dummyReq, &TokenConcentrationQuery{Contract: &addr, Block: 1}, &concentration)
+	assert.Nil(t, err)
+	err = apis.GetHolderConcentration(dummyReq, &TokenConcentrationQuery{Contract: &addr, Block: 2}, &concentration)
+	assert.EqualError(t, err, "token has 3 holders, more than the 2 that can be analysed")
+
+	var top TopHoldersResp
+	err = apis.GetTopHolders(dummyReq, &TokenHoldersQuery{Contract: &addr, Block: 2}, &top)
+	assert.EqualError(t, err, "token has 3 holders, more than the 2 that can be analysed")
+
+	var counts map[uint64]uint64
+	options := &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(2)}
+	err = apis.GetHolderCountHistory(dummyReq, &ERC20TokenQuery{Contract: &addr, Options: options}, &counts)
+	assert.EqualError(t, err, "token has 3 holders, more than the 2 that can be analysed")
+}
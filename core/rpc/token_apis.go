@@ -2,8 +2,11 @@ package rpc
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"math/big"
 	"net/http"
+	"sort"
 
 	"quorumengineering/quorum-report/database"
 	"quorumengineering/quorum-report/types"
@@ -59,6 +62,309 @@ func (r *TokenRPCAPIs) GetERC20TokenHoldersAtBlock(req *http.Request, query *ERC
 	return nil
 }
 
+// GetPortfolio returns every ERC20 token an account has a non-zero balance of and a page of
+// the ERC721 tokens it holds at a block, across all token contracts, ordered by contract.
+// There is at most one ERC20 balance for each tracked contract, so they are all returned with
+// the first page.
+func (r *TokenRPCAPIs) GetPortfolio(req *http.Request, query *PortfolioQuery, reply *PortfolioResp) error {
+	if query.Holder == nil {
+		return errors.New("no token holder provided")
+	}
+	if query.Block == 0 {
+		return errors.New("block must be provided and not 0")
+	}
+	if query.Options == nil {
+		query.Options = &types.AccountTokenQueryOptions{}
+	}
+	query.Options.SetDefaults()
+
+	erc20 := make([]PortfolioERC20Balance, 0)
+	if query.Options.After == "" {
+		balances, err := r.db.AllERC20BalancesForAccountAtBlock(*query.Holder, query.Block)
+		if err != nil {
+			return err
+		}
+		for contract, balance := range balances {
+			erc20 = append(erc20, PortfolioERC20Balance{Contract: contract, Balance: balance.String()})
+		}
+		sort.Slice(erc20, func(i, j int) bool { return erc20[i].Contract < erc20[j].Contract })
+	}
+
+	erc721, err := r.db.AllERC721TokensForAccountAtBlock(*query.Holder, query.Block, query.Options)
+	if err != nil {
+		return err
+	}
+
+	next := ""
+	if len(erc721) == query.Options.PageSize {
+		next = types.ERC721TokenCursor(erc721[len(erc721)-1])
+	}
+	*reply = PortfolioResp{Holder: *query.Holder, Block: query.Block, ERC20: erc20, ERC721: erc721, Next: next}
+	return nil
+}
+
+// GetTopHolders returns the holders of an ERC20 token with the largest balances at a block,
+// largest first, with the percentage of the total supply they hold
+func (r *TokenRPCAPIs) GetTopHolders(req *http.Request, query *TokenHoldersQuery, reply *TopHoldersResp) error {
+	if query.Contract == nil {
+		return errors.New("no token contract provided")
+	}
+	if query.Block == 0 {
+		return errors.New("block must be provided and not 0")
+	}
+	if query.Count < 0 || query.Count > 1000 {
+		return errors.New("count must be between 1 and 1000")
+	}
+	if query.Count == 0 {
+		query.Count = 10
+	}
+
+	ranked, supply, err := r.holderBalancesAtBlock(*query.Contract, query.Block)
+	if err != nil {
+		return err
+	}
+
+	top := ranked
+	if len(top) > query.Count {
+		top = top[:query.Count]
+	}
+	holders := make([]TokenHolderShare, 0, len(top))
+	for i, h := range top {
+		holders = append(holders, TokenHolderShare{
+			Rank:       i + 1,
+			Holder:     h.holder,
+			Balance:    h.balance.String(),
+			Percentage: percentage(h.balance, supply),
+		})
+	}
+
+	*reply = TopHoldersResp{Holders: holders, HolderCount: len(ranked), TotalSupply: supply.String()}
+	return nil
+}
+
+// GetHolderCountHistory returns the number of holders with a non-zero balance of an ERC20
+// token at the start of the range, keyed by the starting block, and at each block in the
+// range it changed in. The range is at most maxHolderHistoryBlocks blocks, and defaults to
+// that many blocks from the starting block.
+func (r *TokenRPCAPIs) GetHolderCountHistory(req *http.Request, query *ERC20TokenQuery, reply *map[uint64]uint64) error {
+	if query.Contract == nil {
+		return errors.New("no token contract provided")
+	}
+	if query.Options == nil {
+		query.Options = &types.TokenQueryOptions{}
+	}
+	query.Options.SetDefaults()
+
+	beginBlock := query.Options.BeginBlockNumber.Uint64()
+	endBlock := uint64(math.MaxInt64)
+	if beginBlock < endBlock-maxHolderHistoryBlocks {
+		endBlock = beginBlock + maxHolderHistoryBlocks - 1
+	}
+	if query.Options.EndBlockNumber.Sign() >= 0 {
+		endBlock = query.Options.EndBlockNumber.Uint64()
+		if endBlock < beginBlock {
+			return errors.New("end block must not be before the begin block")
+		}
+		if endBlock-beginBlock >= maxHolderHistoryBlocks {
+			return fmt.Errorf("block range must be at most %d blocks", maxHolderHistoryBlocks)
+		}
+	}
+
+	holders, err := r.holderBalances(*query.Contract, beginBlock)
+	if err != nil {
+		return err
+	}
+	changes, err := r.db.ERC20BalanceChanges(*query.Contract, beginBlock+1, endBlock)
+	if err != nil {
+		return err
+	}
+
+	counts, err := holderCountHistory(holders, changes, beginBlock)
+	if err != nil {
+		return err
+	}
+	*reply = counts
+	return nil
+}
+
+// GetHolderConcentration returns how concentrated the supply of an ERC20 token is among
+// its holders at a block
+func (r *TokenRPCAPIs) GetHolderConcentration(req *http.Request, query *TokenConcentrationQuery, reply *TokenConcentrationResp) error {
+	if query.Contract == nil {
+		return errors.New("no token contract provided")
+	}
+	if query.Block == 0 {
+		return errors.New("block must be provided and not 0")
+	}
+	if len(query.TopN) == 0 {
+		query.TopN = []int{1, 10, 100}
+	}
+	for _, n := range query.TopN {
+		if n < 1 {
+			return errors.New("number of top holders must be at least 1")
+		}
+	}
+
+	ranked, supply, err := r.holderBalancesAtBlock(*query.Contract, query.Block)
+	if err != nil {
+		return err
+	}
+
+	topShares := make(map[int]float64, len(query.TopN))
+	for _, n := range query.TopN {
+		top := ranked
+		if len(top) > n {
+			top = top[:n]
+		}
+		topShares[n] = percentage(sumBalances(top), supply)
+	}
+
+	*reply = TokenConcentrationResp{
+		HolderCount: len(ranked),
+		TotalSupply: supply.String(),
+		Gini:        giniCoefficient(ranked),
+		TopShares:   topShares,
+	}
+	return nil
+}
+
+// holderBalancesAtBlock returns the holders of an ERC20 token ranked by balance, and the
+// total supply at the block, which is the sum of the balances if it was not recorded
+func (r *TokenRPCAPIs) holderBalancesAtBlock(contract types.Address, block uint64) ([]holderBalance, *big.Int, error) {
+	balances, err := r.holderBalances(contract, block)
+	if err != nil {
+		return nil, nil, err
+	}
+	ranked := rankHolders(balances)
+
+	blockNumber := new(big.Int).SetUint64(block)
+	options := &types.TokenQueryOptions{BeginBlockNumber: blockNumber, EndBlockNumber: blockNumber}
+	options.SetDefaults()
+	supplies, err := r.db.GetTotalSupply(contract, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if supply, ok := supplies[block]; ok {
+		return ranked, supply, nil
+	}
+	return ranked, sumBalances(ranked), nil
+}
+
+// holderBalances returns the non-zero balance of every holder of an ERC20 token at the block,
+// counting them first so that tokens with too many holders to hold in memory are refused
+func (r *TokenRPCAPIs) holderBalances(contract types.Address, block uint64) (map[types.Address]*big.Int, error) {
+	count, err := r.db.ERC20HolderCountAtBlock(contract, block)
+	if err != nil {
+		return nil, err
+	}
+	if count > maxHolderAnalyticsHolders {
+		return nil, fmt.Errorf("token has %d holders, more than the %d that can be analysed", count, maxHolderAnalyticsHolders)
+	}
+	return r.db.ERC20HolderBalancesAtBlock(contract, block)
+}
+
+func (r *TokenRPCAPIs) GetERC20Allowance(req *http.Request, query *ERC20AllowanceQuery, reply **big.Int) error {
+	if query.Contract == nil {
+		return errors.New("no token contract provided")
+	}
+	if query.Owner == nil {
+		return errors.New("no token owner provided")
+	}
+	if query.Spender == nil {
+		return errors.New("no spender provided")
+	}
+	if query.Block == 0 {
+		return errors.New("no block given")
+	}
+
+	result, err := r.db.ERC20AllowanceAtBlock(*query.Contract, *query.Owner, *query.Spender, query.Block)
+	if err != nil {
+		return err
+	}
+
+	*reply = result
+	return nil
+}
+
+func (r *TokenRPCAPIs) GetERC20ApprovalsForOwner(req *http.Request, query *ERC20AllowanceQuery, reply *[]types.ERC20Allowance) error {
+	if query.Contract == nil {
+		return errors.New("no token contract provided")
+	}
+	if query.Owner == nil {
+		return errors.New("no token owner provided")
+	}
+	if query.Block == 0 {
+		return errors.New("no block given")
+	}
+	if query.Options == nil {
+		query.Options = &types.TokenQueryOptions{}
+	}
+	query.Options.SetDefaults()
+
+	results, err := r.db.ERC20ApprovalsForOwnerAtBlock(*query.Contract, *query.Owner, query.Block, query.Options)
+	if err != nil {
+		return err
+	}
+
+	*reply = results
+	return nil
+}
+
+func (r *TokenRPCAPIs) GetTokenInfo(req *http.Request, contract *types.Address, reply **types.TokenInfo) error {
+	if contract == nil {
+		return errors.New("no token contract provided")
+	}
+
+	info, err := r.db.GetTokenInfo(*contract)
+	if err != nil {
+		return err
+	}
+
+	*reply = info
+	return nil
+}
+
+// GetTotalSupplyHistory returns the total supply of a token at each block it changed. If
+// formatting is requested, the amounts are scaled by the decimals of the token, which must
+// have been recorded.
+func (r *TokenRPCAPIs) GetTotalSupplyHistory(req *http.Request, query *TokenSupplyQuery, reply *map[uint64]string) error {
+	if query.Contract == nil {
+		return errors.New("no token contract provided")
+	}
+	if query.Options == nil {
+		query.Options = &types.TokenQueryOptions{}
+	}
+	query.Options.SetDefaults()
+
+	var decimals uint8
+	if query.Format {
+		info, err := r.db.GetTokenInfo(*query.Contract)
+		if err != nil && err != database.ErrNotFound {
+			return err
+		}
+		if info == nil || info.Decimals == nil {
+			return errors.New("token decimals are not known")
+		}
+		decimals = *info.Decimals
+	}
+
+	supplies, err := r.db.GetTotalSupply(*query.Contract, query.Options)
+	if err != nil {
+		return err
+	}
+
+	result := make(map[uint64]string, len(supplies))
+	for block, supply := range supplies {
+		if query.Format {
+			result[block] = types.FormatTokenAmount(supply, decimals)
+		} else {
+			result[block] = supply.String()
+		}
+	}
+	*reply = result
+	return nil
+}
+
 func (r *TokenRPCAPIs) GetHolderForERC721TokenAtBlock(req *http.Request, query *ERC721TokenQuery, reply *types.Address) error {
 	if query.Contract == nil {
 		return errors.New("no token contract provided")
@@ -144,3 +450,122 @@ func (r *TokenRPCAPIs) AllERC721HoldersAtBlock(req *http.Request, query *ERC721T
 	*reply = results
 	return nil
 }
+
+func (r *TokenRPCAPIs) GetERC721TokenProvenance(req *http.Request, query *ERC721TokenQuery, reply *[]types.TokenTransfer) error {
+	if query.Contract == nil {
+		return errors.New("no token contract provided")
+	}
+	if query.TokenId == nil {
+		return errors.New("no token ID provided")
+	}
+	if query.Options == nil {
+		query.Options = &types.TokenQueryOptions{}
+	}
+	query.Options.SetDefaults()
+
+	results, err := r.db.ERC721TokenTransfers(*query.Contract, query.TokenId, query.Options)
+	if err != nil {
+		return err
+	}
+
+	*reply = results
+	return nil
+}
+
+func (r *TokenRPCAPIs) GetTransfers(req *http.Request, query *TokenTransfersQuery, reply *TokenTransfersResp) error {
+	switch query.Direction {
+	case "", types.TransferDirectionIn, types.TransferDirectionOut:
+	default:
+		return errors.New(`direction must be "in" or "out"`)
+	}
+	if query.Direction != "" && query.Counterparty == nil {
+		return errors.New("no counterparty provided for direction")
+	}
+	if query.Options == nil {
+		query.Options = &types.TokenTransferQueryOptions{}
+	}
+	query.Options.SetDefaults()
+
+	transfers, err := r.db.GetTokenTransfers(query.Contract, query.Counterparty, query.Direction, query.Options)
+	if err != nil {
+		return err
+	}
+
+	next := ""
+	if len(transfers) == query.Options.PageSize {
+		next = types.TransferCursor(transfers[len(transfers)-1])
+	}
+	*reply = TokenTransfersResp{Transfers: transfers, Next: next}
+	return nil
+}
+
+func (r *TokenRPCAPIs) GetERC1155BalanceAtBlock(req *http.Request, query *ERC1155TokenQuery, reply **big.Int) error {
+	if query.Contract == nil {
+		return errors.New("no token contract provided")
+	}
+	if query.Holder == nil {
+		return errors.New("no token holder provided")
+	}
+	if query.TokenId == nil {
+		return errors.New("no token ID provided")
+	}
+	if query.Block == 0 {
+		return errors.New("no block given")
+	}
+
+	result, err := r.db.ERC1155BalanceAtBlock(*query.Contract, *query.Holder, query.Block, query.TokenId)
+	if err != nil {
+		return err
+	}
+
+	*reply = result
+	return nil
+}
+
+func (r *TokenRPCAPIs) ERC1155TokensForAccountAtBlock(req *http.Request, query *ERC1155TokenQuery, reply *[]types.ERC1155Token) error {
+	if query.Contract == nil {
+		return errors.New("no token contract provided")
+	}
+	if query.Holder == nil {
+		return errors.New("no token holder provided")
+	}
+	if query.Block == 0 {
+		return errors.New("no block given")
+	}
+	if query.Options == nil {
+		query.Options = &types.TokenQueryOptions{}
+	}
+	query.Options.SetDefaults()
+
+	results, err := r.db.ERC1155TokensForAccountAtBlock(*query.Contract, *query.Holder, query.Block, query.Options)
+	if err != nil {
+		return err
+	}
+
+	*reply = results
+	return nil
+}
+
+func (r *TokenRPCAPIs) ERC1155HoldersAtBlock(req *http.Request, query *ERC1155TokenQuery, reply *[]types.Address) error {
+	if query.Contract == nil {
+		return errors.New("no token contract provided")
+	}
+	if query.TokenId == nil {
+		return errors.New("no token ID provided")
+	}
+	if query.Block == 0 {
+		return errors.New("no block given")
+	}
+	if query.Options == nil {
+		query.Options = &types.TokenQueryOptions{}
+	}
+	query.Options.SetDefaults()
+
+	results, err := r.db.ERC1155HoldersAtBlock(*query.Contract, query.Block, query.TokenId, query.Options)
+	if err != nil {
+		return err
+	}
+
+	*reply = results
+	return nil
+}
@@ -0,0 +1,64 @@
+package rpc
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/types"
+)
+
+func TestTokenRPCAPIs_GetPortfolio(t *testing.T) {
+	other := types.NewAddress("0x0000000000000000000000000000000000000002")
+	db := memory.NewMemoryDB()
+	assert.Nil(t, db.RecordNewERC20Balance(other, holderA, 1, big.NewInt(5)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holderA, 1, big.NewInt(300)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holderB, 1, big.NewInt(100)))
+	assert.Nil(t, db.RecordERC721Token(other, holderA, 1, big.NewInt(3)))
+	apis := NewTokenRPCAPIs(db)
+
+	var reply PortfolioResp
+	err := apis.GetPortfolio(dummyReq, &PortfolioQuery{Holder: &holderA, Block: 1}, &reply)
+	assert.Nil(t, err)
+	assert.Equal(t, PortfolioResp{
+		Holder: holderA,
+		Block:  1,
+		ERC20: []PortfolioERC20Balance{
+			{Contract: addr, Balance: "300"},
+			{Contract: other, Balance: "5"},
+		},
+		ERC721: []types.ERC721Token{{Contract: other, Holder: holderA, Token: "3", HeldFrom: 1}},
+	}, reply)
+
+	err = apis.GetPortfolio(dummyReq, &PortfolioQuery{Holder: &holderA}, &reply)
+	assert.EqualError(t, err, "block must be provided and not 0")
+}
+
+func TestTokenRPCAPIs_GetPortfolio_Pages(t *testing.T) {
+	db := memory.NewMemoryDB()
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holderA, 1, big.NewInt(300)))
+	for _, tokenId := range []int64{3, 1, 2} {
+		assert.Nil(t, db.RecordERC721Token(addr, holderA, 1, big.NewInt(tokenId)))
+	}
+	apis := NewTokenRPCAPIs(db)
+
+	// the ERC20 balances are returned with the first page
+	var reply PortfolioResp
+	err := apis.GetPortfolio(dummyReq, &PortfolioQuery{Holder: &holderA, Block: 1, Options: &types.AccountTokenQueryOptions{PageSize: 2}}, &reply)
+	assert.Nil(t, err)
+	assert.Equal(t, []PortfolioERC20Balance{{Contract: addr, Balance: "300"}}, reply.ERC20)
+	assert.Len(t, reply.ERC721, 2)
+	assert.Equal(t, "2", reply.ERC721[1].Token)
+	assert.Equal(t, addr.String()+"-2", reply.Next)
+
+	err = apis.GetPortfolio(dummyReq, &PortfolioQuery{Holder: &holderA, Block: 1, Options: &types.AccountTokenQueryOptions{After: reply.Next, PageSize: 2}}, &reply)
+	assert.Nil(t, err)
+	assert.Empty(t, reply.ERC20)
+	assert.Equal(t, []types.ERC721Token{{Contract: addr, Holder: holderA, Token: "3", HeldFrom: 1}}, reply.ERC721)
+	assert.Empty(t, reply.Next)
+
+	err = apis.GetPortfolio(dummyReq, &PortfolioQuery{Holder: &holderA, Block: 1, Options: &types.AccountTokenQueryOptions{After: "3"}}, &reply)
+	assert.EqualError(t, err, `invalid "after" cursor`)
+}
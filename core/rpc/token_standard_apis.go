@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/types"
+)
+
+// TokenStandardRPCAPIs serves the balances of a token standard that is processed from its
+// events, under the RPC namespace of the standard
+type TokenStandardRPCAPIs struct {
+	db       database.TokenDB
+	standard string
+}
+
+func NewTokenStandardRPCAPIs(db database.TokenDB, standard string) *TokenStandardRPCAPIs {
+	return &TokenStandardRPCAPIs{db, standard}
+}
+
+func (r *TokenStandardRPCAPIs) GetBalanceAtBlock(req *http.Request, query *TokenBalanceQuery, reply **big.Int) error {
+	if query.Contract == nil {
+		return errors.New("no token contract provided")
+	}
+	if query.Holder == nil {
+		return errors.New("no token holder provided")
+	}
+	if query.Block == 0 {
+		return errors.New("no block given")
+	}
+
+	balance, err := r.db.TokenBalanceAtBlock(r.standard, *query.Contract, *query.Holder, query.Block)
+	if err != nil {
+		return err
+	}
+
+	*reply = balance
+	return nil
+}
+
+func (r *TokenStandardRPCAPIs) GetHoldersAtBlock(req *http.Request, query *TokenBalanceQuery, reply *[]types.Address) error {
+	if query.Contract == nil {
+		return errors.New("no token contract provided")
+	}
+	if query.Block == 0 {
+		return errors.New("no block given")
+	}
+	if query.Options == nil {
+		query.Options = &types.TokenQueryOptions{}
+	}
+	query.Options.SetDefaults()
+
+	holders, err := r.db.TokenHoldersAtBlock(r.standard, *query.Contract, query.Block, query.Options)
+	if err != nil {
+		return err
+	}
+
+	*reply = holders
+	return nil
+}
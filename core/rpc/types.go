@@ -1,6 +1,8 @@
 package rpc
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"math/big"
 
@@ -9,6 +11,25 @@ import (
 
 var ErrNoAddress = errors.New("address not provided")
 
+const (
+	// mappingKeySourcePageSize and maxMappingKeySources bound the total number of transactions
+	// and events read to find mapping keys for storage parsing in each call, and maxMappingKeys
+	// the number of keys found that are tried
+	mappingKeySourcePageSize = 1000
+	maxMappingKeySources     = 10000
+	maxMappingKeys           = 1000
+
+	// mappingKeyCacheSize is the number of contracts whose observed mapping keys are cached
+	mappingKeyCacheSize = 100
+
+	// maxHolderHistoryBlocks is the most blocks the holder count history is given for in each call
+	maxHolderHistoryBlocks = 100000
+)
+
+// maxHolderAnalyticsHolders is the most holders a token can have at a block for its holders
+// to be ranked or measured
+var maxHolderAnalyticsHolders uint64 = 100000
+
 //Inputs
 
 type NullArgs struct{}
@@ -39,6 +60,12 @@ type AddressWithBlockRange struct {
 	Options *types.PageOptions
 }
 
+type StorageHistoryArgs struct {
+	Address     *types.Address
+	Options     *types.PageOptions
+	MappingKeys []string
+}
+
 type ERC20TokenQuery struct {
 	Contract *types.Address
 	Holder   *types.Address
@@ -54,6 +81,97 @@ type ERC721TokenQuery struct {
 	Options  *types.TokenQueryOptions
 }
 
+type ERC20AllowanceQuery struct {
+	Contract *types.Address
+	Owner    *types.Address
+	Spender  *types.Address
+	Block    uint64
+	Options  *types.TokenQueryOptions
+}
+
+type TokenBalanceQuery struct {
+	Contract *types.Address
+	Holder   *types.Address
+	Block    uint64
+	Options  *types.TokenQueryOptions
+}
+
+type TokenSupplyQuery struct {
+	Contract *types.Address
+	Format   bool
+	Options  *types.TokenQueryOptions
+}
+
+type PortfolioQuery struct {
+	Holder  *types.Address
+	Block   uint64
+	Options *types.AccountTokenQueryOptions
+}
+
+type TokenHoldersQuery struct {
+	Contract *types.Address
+	Block    uint64
+	// Count is the number of top holders to return, which defaults to 10
+	Count int
+}
+
+type TokenConcentrationQuery struct {
+	Contract *types.Address
+	Block    uint64
+	// TopN are the numbers of top holders to give the share of the supply held by, which
+	// default to 1, 10 and 100
+	TopN []int
+}
+
+type ERC1155TokenQuery struct {
+	Contract *types.Address
+	Holder   *types.Address
+	TokenId  *big.Int
+	Block    uint64
+	Options  *types.TokenQueryOptions
+}
+
+type TokenTransfersQuery struct {
+	Contract     *types.Address
+	Counterparty *types.Address
+	Direction    string
+	Options      *types.TokenTransferQueryOptions
+}
+
+// TransactionArgs is the hash of a transaction, given either on its own or in an object
+// along with how the transaction should be parsed
+type TransactionArgs struct {
+	Hash        *types.Hash
+	DecodeCalls bool
+}
+
+func (args *TransactionArgs) UnmarshalJSON(input []byte) error {
+	if trimmed := bytes.TrimSpace(input); len(trimmed) > 0 && trimmed[0] == '"' {
+		args.Hash = new(types.Hash)
+		return args.Hash.UnmarshalJSON(trimmed)
+	}
+	type plainArgs TransactionArgs
+	return json.Unmarshal(input, (*plainArgs)(args))
+}
+
+type NativeTransfersQuery struct {
+	Address   *types.Address
+	Direction string
+	Options   *types.TokenTransferQueryOptions
+}
+
+type NativeBalanceQuery struct {
+	Address *types.Address
+	Block   uint64
+	Options *types.TokenQueryOptions
+}
+
+type TransactionFailuresQuery struct {
+	Address *types.Address
+	Reason  string
+	Options *types.PageOptions
+}
+
 //Outputs
 
 type TransactionsResp struct {
@@ -68,6 +186,56 @@ type EventsResp struct {
 	Options *types.QueryOptions  `json:"options"`
 }
 
+type TokenTransfersResp struct {
+	Transfers []types.TokenTransfer `json:"transfers"`
+	// Next is the cursor to continue from, which is empty once there are no more transfers
+	Next string `json:"next"`
+}
+
+type NativeTransfersResp struct {
+	Transfers []types.NativeTransfer `json:"transfers"`
+	// Next is the cursor to continue from, which is empty once there are no more transfers
+	Next string `json:"next"`
+}
+
+type PortfolioERC20Balance struct {
+	Contract types.Address `json:"contract"`
+	Balance  string        `json:"balance"`
+}
+
+type PortfolioResp struct {
+	Holder types.Address           `json:"holder"`
+	Block  uint64                  `json:"block"`
+	ERC20  []PortfolioERC20Balance `json:"erc20"`
+	ERC721 []types.ERC721Token     `json:"erc721"`
+	// Next is the cursor to continue from, which is empty once there are no more ERC721 tokens
+	Next string `json:"next"`
+}
+
+type TokenHolderShare struct {
+	Rank    int           `json:"rank"`
+	Holder  types.Address `json:"holder"`
+	Balance string        `json:"balance"`
+	// Percentage is the percentage of the total supply held
+	Percentage float64 `json:"percentage"`
+}
+
+type TopHoldersResp struct {
+	Holders     []TokenHolderShare `json:"holders"`
+	HolderCount int                `json:"holderCount"`
+	TotalSupply string             `json:"totalSupply"`
+}
+
+type TokenConcentrationResp struct {
+	HolderCount int    `json:"holderCount"`
+	TotalSupply string `json:"totalSupply"`
+	// Gini is the Gini coefficient of the balances of the holders, from 0 when all hold the
+	// same balance to almost 1 when one holder holds every token
+	Gini float64 `json:"gini"`
+	// TopShares is the percentage of the total supply held by each number of top holders
+	TopShares map[int]float64 `json:"topShares"`
+}
+
 type RangeQueryResult struct {
 	Ranges []types.RangeResult `json:"ranges"`
 }
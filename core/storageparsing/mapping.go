@@ -0,0 +1,232 @@
+package storageparsing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+
+	"quorumengineering/quorum-report/types"
+)
+
+var (
+	stringKeyPrefix = "t_string"
+	bytesKeyPrefix  = "t_bytes_"
+
+	errKeyNotApplicable = errors.New("key does not fit the mapping key type")
+)
+
+// ParseMapping reconstructs the entries of a mapping from the candidate keys given to the
+// parser, as the keys of a mapping are not stored. An entry is only included if any of the
+// storage its value occupies is non-zero. The result is keyed by the formatted key, and
+// stops growing once the parser's lookup budget is spent.
+// The keys of string and bytes mappings are hashed as they are, so only the keys provided
+// by the user are tried for them, as the words seen on chain are the ABI encoding of a value.
+func (p *Parser) ParseMapping(entry types.SolidityStorageEntry, namedType types.SolidityTypeEntry) (map[string]interface{}, error) {
+	mappingSlot := p.ResolveSlot(bigN(entry.Slot))
+	slotBytes, _ := hex.DecodeString(string(mappingSlot))
+
+	candidates := p.mappingKeys
+	if !isDynamicKey(namedType.Key) {
+		candidates = append(append([]string{}, p.mappingKeys...), p.observedKeys...)
+	}
+
+	results := make(map[string]interface{})
+	for _, candidate := range candidates {
+		encodedKey, formattedKey, err := encodeMappingKey(namedType.Key, candidate)
+		if err != nil {
+			continue
+		}
+		if _, seen := results[formattedKey]; seen {
+			continue
+		}
+		if !p.mappingLookups.take() {
+			break
+		}
+
+		// the value of key k in a mapping at slot p is stored at keccak256(h(k) . p)
+		hasher := sha3.NewLegacyKeccak256()
+		hasher.Write(encodedKey)
+		hasher.Write(slotBytes)
+		valueSlot := types.NewHash(hex.EncodeToString(hasher.Sum(nil)))
+
+		valueTemplate := types.SolidityStorageDocument{
+			Storage: types.SolidityStorageEntries{{Label: formattedKey, Type: namedType.Value}},
+			Types:   p.template.Types,
+		}
+		tracker := &trackingStorageManager{StorageManager: p.storageManager}
+		valueParser := p.newChildParser(tracker, valueTemplate, valueSlot)
+		out, err := valueParser.ParseRawStorage()
+		if err != nil {
+			return nil, err
+		}
+		if tracker.nonEmpty && len(out) == 1 {
+			results[formattedKey] = out[0].Value
+		}
+	}
+	return results, nil
+}
+
+// isDynamicKey checks if the key type is a string or bytes, whose keys are hashed unpadded
+func isDynamicKey(keyType string) bool {
+	return strings.HasPrefix(keyType, stringKeyPrefix) || strings.HasPrefix(keyType, bytesKeyPrefix)
+}
+
+// encodeMappingKey converts a candidate key into the form hashed to find its value slot,
+// as well as the key formatted for display. Candidates are either 32 byte words observed
+// on chain, which must be a valid encoding of the key type, or user provided values. The
+// keys of string and bytes mappings are always user provided values.
+func encodeMappingKey(keyType string, candidate string) ([]byte, string, error) {
+	switch {
+	case strings.HasPrefix(keyType, stringKeyPrefix):
+		return []byte(candidate), candidate, nil
+
+	case strings.HasPrefix(keyType, bytesKeyPrefix):
+		decoded, err := hex.DecodeString(strings.TrimPrefix(candidate, "0x"))
+		if err != nil {
+			return nil, "", errKeyNotApplicable
+		}
+		return decoded, "0x" + hex.EncodeToString(decoded), nil
+
+	case strings.HasPrefix(keyType, addressPrefix) || strings.HasPrefix(keyType, contractPrefix):
+		value, err := decodeKeyWord(candidate)
+		if err != nil || value.BitLen() > 160 {
+			return nil, "", errKeyNotApplicable
+		}
+		word := leftPadWord(value.Bytes())
+		return word, "0x" + hex.EncodeToString(word[12:]), nil
+
+	case strings.HasPrefix(keyType, boolPrefix):
+		if candidate == "true" || candidate == "false" {
+			return leftPadWord(boolBytes(candidate == "true")), candidate, nil
+		}
+		value, err := decodeKeyWord(candidate)
+		if err != nil || value.BitLen() > 1 {
+			return nil, "", errKeyNotApplicable
+		}
+		return leftPadWord(value.Bytes()), strconv.FormatBool(value.Sign() == 1), nil
+
+	case strings.HasPrefix(keyType, uintPrefix) || strings.HasPrefix(keyType, enumPrefix):
+		bits := 8
+		if strings.HasPrefix(keyType, uintPrefix) {
+			bits, _ = strconv.Atoi(strings.TrimPrefix(keyType, uintPrefix))
+		}
+		value, err := decodeKeyNumber(candidate)
+		if err != nil || value.Sign() < 0 || value.BitLen() > bits {
+			return nil, "", errKeyNotApplicable
+		}
+		return leftPadWord(value.Bytes()), value.String(), nil
+
+	case strings.HasPrefix(keyType, intPrefix):
+		bits, _ := strconv.Atoi(strings.TrimPrefix(keyType, intPrefix))
+		value, err := decodeKeyNumber(candidate)
+		if err != nil {
+			return nil, "", errKeyNotApplicable
+		}
+		// 32 byte words are the two's complement encoding of the value
+		if strings.HasPrefix(candidate, "0x") && len(candidate) == 66 {
+			value = (&Parser{}).ParseInt(leftPadWord(value.Bytes()))
+		}
+		limit := new(big.Int).Lsh(BigOne, uint(bits-1))
+		if value.Cmp(limit) >= 0 || value.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, "", errKeyNotApplicable
+		}
+		encoded := new(big.Int).Set(value)
+		if encoded.Sign() < 0 {
+			encoded.Add(encoded, new(big.Int).Lsh(BigOne, 256))
+		}
+		return leftPadWord(encoded.Bytes()), value.String(), nil
+
+	case strings.HasPrefix(keyType, bytesPrefix):
+		size, err := strconv.Atoi(strings.TrimPrefix(keyType, bytesPrefix))
+		if err != nil {
+			return nil, "", errKeyNotApplicable
+		}
+		decoded, err := hex.DecodeString(strings.TrimPrefix(candidate, "0x"))
+		if err != nil {
+			return nil, "", errKeyNotApplicable
+		}
+		// fixed size bytes are left aligned, so any bytes past the size must be zero
+		if len(decoded) > size {
+			if !bytes.Equal(decoded[size:], make([]byte, len(decoded)-size)) {
+				return nil, "", errKeyNotApplicable
+			}
+			decoded = decoded[:size]
+		}
+		word := make([]byte, 32)
+		copy(word, decoded)
+		return word, "0x" + hex.EncodeToString(word[:size]), nil
+	}
+	return nil, "", errKeyNotApplicable
+}
+
+// decodeKeyWord parses a hex encoded key
+func decodeKeyWord(candidate string) (*big.Int, error) {
+	value, ok := new(big.Int).SetString(strings.TrimPrefix(candidate, "0x"), 16)
+	if !ok || !strings.HasPrefix(candidate, "0x") {
+		return nil, errKeyNotApplicable
+	}
+	return value, nil
+}
+
+// decodeKeyNumber parses a decimal or hex encoded key
+func decodeKeyNumber(candidate string) (*big.Int, error) {
+	if strings.HasPrefix(candidate, "0x") {
+		return decodeKeyWord(candidate)
+	}
+	value, ok := new(big.Int).SetString(candidate, 10)
+	if !ok {
+		return nil, errKeyNotApplicable
+	}
+	return value, nil
+}
+
+func leftPadWord(value []byte) []byte {
+	word := make([]byte, 32)
+	copy(word[32-len(value):], value)
+	return word
+}
+
+func boolBytes(value bool) []byte {
+	if value {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// lookupBudget is the number of mapping entries a parse may still look up
+type lookupBudget struct {
+	remaining int
+	exceeded  bool
+}
+
+// take uses up a lookup, returning false if none remain. A nil budget is unlimited.
+func (b *lookupBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	if b.remaining <= 0 {
+		b.exceeded = true
+		return false
+	}
+	b.remaining--
+	return true
+}
+
+// trackingStorageManager records whether any storage read through it was non-zero
+type trackingStorageManager struct {
+	StorageManager
+	nonEmpty bool
+}
+
+func (tsm *trackingStorageManager) Get(hash types.Hash) []byte {
+	value := tsm.StorageManager.Get(hash)
+	if !tsm.nonEmpty && !bytes.Equal(value, make([]byte, len(value))) {
+		tsm.nonEmpty = true
+	}
+	return value
+}
@@ -0,0 +1,77 @@
+package storageparsing
+
+import (
+	"encoding/hex"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// CandidateMappingKeys gathers the 32 byte words a contract has been given, which are the
+// likely keys of its mappings: the senders and call arguments of transactions and internal
+// calls to the contract, and the arguments of the events it emitted. Words are returned
+// in the order first seen.
+func CandidateMappingKeys(address types.Address, txs []*types.Transaction, events []*types.Event) []string {
+	collector := &keyCollector{seen: make(map[string]bool)}
+
+	for _, tx := range txs {
+		if tx.To == address {
+			collector.addAddress(tx.From)
+			data := tx.Data
+			if len(tx.PrivateData) > 0 {
+				data = tx.PrivateData
+			}
+			collector.addCallData(data.AsBytes())
+		}
+		for _, call := range tx.InternalCalls {
+			if call.To == address {
+				collector.addAddress(call.From)
+				collector.addCallData(call.Input.AsBytes())
+			}
+		}
+	}
+
+	for _, event := range events {
+		if event.Address != address {
+			continue
+		}
+		if len(event.Topics) > 1 {
+			for _, topic := range event.Topics[1:] {
+				collector.add("0x" + string(topic))
+			}
+		}
+		collector.addWords(event.Data.AsBytes())
+	}
+
+	return collector.keys
+}
+
+type keyCollector struct {
+	keys []string
+	seen map[string]bool
+}
+
+func (c *keyCollector) add(word string) {
+	if !c.seen[word] {
+		c.seen[word] = true
+		c.keys = append(c.keys, word)
+	}
+}
+
+func (c *keyCollector) addAddress(address types.Address) {
+	if !address.IsEmpty() {
+		c.add("0x" + string(types.NewHash(string(address))))
+	}
+}
+
+// addCallData adds the arguments of a call, skipping the function selector
+func (c *keyCollector) addCallData(data []byte) {
+	if len(data) > 4 {
+		c.addWords(data[4:])
+	}
+}
+
+func (c *keyCollector) addWords(data []byte) {
+	for i := 0; i+32 <= len(data); i += 32 {
+		c.add("0x" + hex.EncodeToString(data[i:i+32]))
+	}
+}
@@ -0,0 +1,213 @@
+package storageparsing
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/sha3"
+
+	"quorumengineering/quorum-report/types"
+)
+
+const mappingLayout = `{
+	"storage": [
+		{"label": "balances", "offset": 0, "slot": "0", "type": "t_mapping(t_address,t_uint256)"},
+		{"label": "allowed", "offset": 0, "slot": "1", "type": "t_mapping(t_address,t_mapping(t_address,t_bool))"},
+		{"label": "names", "offset": 0, "slot": "2", "type": "t_mapping(t_string_memory_ptr,t_struct(Funder)1_storage)"},
+		{"label": "signed", "offset": 0, "slot": "3", "type": "t_mapping(t_int256,t_bytes4)"}
+	],
+	"types": {
+		"t_address": {"encoding": "inplace", "label": "address", "numberOfBytes": "20"},
+		"t_bool": {"encoding": "inplace", "label": "bool", "numberOfBytes": "1"},
+		"t_bytes4": {"encoding": "inplace", "label": "bytes4", "numberOfBytes": "4"},
+		"t_int256": {"encoding": "inplace", "label": "int256", "numberOfBytes": "32"},
+		"t_uint256": {"encoding": "inplace", "label": "uint256", "numberOfBytes": "32"},
+		"t_string_memory_ptr": {"encoding": "bytes", "label": "string", "numberOfBytes": "32"},
+		"t_string_storage": {"encoding": "bytes", "label": "string", "numberOfBytes": "32"},
+		"t_mapping(t_address,t_uint256)": {"encoding": "mapping", "key": "t_address", "label": "mapping(address => uint256)", "numberOfBytes": "32", "value": "t_uint256"},
+		"t_mapping(t_address,t_bool)": {"encoding": "mapping", "key": "t_address", "label": "mapping(address => bool)", "numberOfBytes": "32", "value": "t_bool"},
+		"t_mapping(t_address,t_mapping(t_address,t_bool))": {"encoding": "mapping", "key": "t_address", "label": "mapping(address => mapping(address => bool))", "numberOfBytes": "32", "value": "t_mapping(t_address,t_bool)"},
+		"t_mapping(t_string_memory_ptr,t_struct(Funder)1_storage)": {"encoding": "mapping", "key": "t_string_memory_ptr", "label": "mapping(string => struct Funder)", "numberOfBytes": "32", "value": "t_struct(Funder)1_storage"},
+		"t_mapping(t_int256,t_bytes4)": {"encoding": "mapping", "key": "t_int256", "label": "mapping(int256 => bytes4)", "numberOfBytes": "32", "value": "t_bytes4"},
+		"t_struct(Funder)1_storage": {"encoding": "inplace", "label": "struct Funder", "numberOfBytes": "64", "members": [
+			{"label": "addr", "offset": 0, "slot": "0", "type": "t_string_storage"},
+			{"label": "amount", "offset": 0, "slot": "1", "type": "t_uint256"}
+		]}
+	}
+}`
+
+const (
+	holder0 = "0xed9d02e382b34818e88b88a309c7fe71e65f419d"
+	holder1 = "0xca843569e3427144cead5e4d5999a3d0ccf92b8e"
+)
+
+// valueSlot calculates keccak256(key . slot)
+func valueSlot(key []byte, slot types.Hash) types.Hash {
+	slotBytes, _ := hex.DecodeString(string(slot))
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write(key)
+	hasher.Write(slotBytes)
+	return types.NewHash(hex.EncodeToString(hasher.Sum(nil)))
+}
+
+func word(hexString string) []byte {
+	decoded, _ := hex.DecodeString(string(types.NewHash(hexString)))
+	return decoded
+}
+
+func TestParser_ParseMapping(t *testing.T) {
+	var layout types.SolidityStorageDocument
+	assert.Nil(t, json.Unmarshal([]byte(mappingLayout), &layout))
+
+	// struct members follow on from the value slot
+	nameSlot := valueSlot([]byte("alice"), types.NewHash("0x2"))
+	nameSlotBytes, _ := hex.DecodeString(string(nameSlot))
+	amountSlot := types.NewHash(hex.EncodeToString(bigN(0).Add(bigN(0).SetBytes(nameSlotBytes), BigOne).Bytes()))
+
+	storage := map[types.Hash]string{
+		valueSlot(word(holder0), types.NewHash("0x0")):                           "64",
+		valueSlot(word(holder1), types.NewHash("0x0")):                           "0",
+		valueSlot(word(holder1), valueSlot(word(holder0), types.NewHash("0x1"))): "01",
+		nameSlot:   "6d7973747200000000000000000000000000000000000000000000000000000a",
+		amountSlot: "2a",
+		valueSlot(word("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"), types.NewHash("0x3")): "12345678",
+	}
+
+	keys := []string{
+		"0x000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d",
+		holder1,
+		"-1",
+		"alice",
+		"bob",
+		// not a valid address, so only tried as the other key types
+		"0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+	}
+	output, err := ParseRawStorage(storage, layout, keys, nil)
+	assert.Nil(t, err)
+
+	expected := `[
+		{"name":"balances","index":0,"type":"mapping(address => uint256)","value":{"0xed9d02e382b34818e88b88a309c7fe71e65f419d":"100"}},
+		{"name":"allowed","index":0,"type":"mapping(address => mapping(address => bool))","value":{"0xed9d02e382b34818e88b88a309c7fe71e65f419d":{"0xca843569e3427144cead5e4d5999a3d0ccf92b8e":true}}},
+		{"name":"names","index":0,"type":"mapping(string => struct Funder)","value":{"alice":[{"name":"addr","index":0,"type":"string","value":"mystr"},{"name":"amount","index":0,"type":"uint256","value":"42"}]}},
+		{"name":"signed","index":0,"type":"mapping(int256 => bytes4)","value":{"-1":"0x12345678"}}
+	]`
+	encoded, _ := json.Marshal(output)
+	assert.JSONEq(t, expected, string(encoded))
+}
+
+func TestParser_ParseMappingLookupLimit(t *testing.T) {
+	defer func(limit int) { maxMappingLookups = limit }(maxMappingLookups)
+	maxMappingLookups = 1
+
+	var layout types.SolidityStorageDocument
+	assert.Nil(t, json.Unmarshal([]byte(mappingLayout), &layout))
+	storage := map[types.Hash]string{
+		valueSlot(word(holder0), types.NewHash("0x0")):                           "64",
+		valueSlot(word(holder1), valueSlot(word(holder0), types.NewHash("0x1"))): "01",
+	}
+
+	// only the first key is looked up in the first mapping, leaving the others empty
+	output, err := ParseRawStorage(storage, layout, []string{holder0, holder1}, nil)
+	assert.Nil(t, err)
+	assert.Len(t, output, 4)
+	assert.Equal(t, map[string]interface{}{"0xed9d02e382b34818e88b88a309c7fe71e65f419d": "100"}, output[0].Value)
+	assert.Empty(t, output[1].Value)
+}
+
+func TestParser_ParseMappingObservedKeys(t *testing.T) {
+	var layout types.SolidityStorageDocument
+	assert.Nil(t, json.Unmarshal([]byte(mappingLayout), &layout))
+
+	observed := "0x000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d"
+	storage := map[types.Hash]string{
+		valueSlot(word(holder0), types.NewHash("0x0")): "64",
+		// only found if the observed word were tried as the text of a string key
+		valueSlot([]byte(observed), types.NewHash("0x2")): "2a",
+	}
+
+	output, err := ParseRawStorage(storage, layout, nil, []string{observed})
+	assert.Nil(t, err)
+	assert.Len(t, output, 4)
+	assert.Equal(t, map[string]interface{}{"0xed9d02e382b34818e88b88a309c7fe71e65f419d": "100"}, output[0].Value)
+	assert.Empty(t, output[2].Value)
+}
+
+func TestEncodeMappingKey(t *testing.T) {
+	testMatrix := []struct {
+		keyType      string
+		candidate    string
+		expectedKey  string
+		expectedWord string
+		applicable   bool
+	}{
+		{"t_address", holder0, holder0, "000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d", true},
+		{"t_address", "0x01000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d", "", "", false},
+		{"t_address", "alice", "", "", false},
+		{"t_uint8", "255", "255", "00000000000000000000000000000000000000000000000000000000000000ff", true},
+		{"t_uint8", "256", "", "", false},
+		{"t_uint256", "0x10", "16", "0000000000000000000000000000000000000000000000000000000000000010", true},
+		{"t_uint256", "-1", "", "", false},
+		{"t_int8", "-128", "-128", "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff80", true},
+		{"t_int8", "128", "", "", false},
+		{"t_int256", "0xfffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffe", "-2", "fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffe", true},
+		{"t_bool", "true", "true", "0000000000000000000000000000000000000000000000000000000000000001", true},
+		{"t_bool", "0x0000000000000000000000000000000000000000000000000000000000000002", "", "", false},
+		{"t_bytes2", "0x1234", "0x1234", "1234000000000000000000000000000000000000000000000000000000000000", true},
+		{"t_bytes2", "0x1234000000000000000000000000000000000000000000000000000000000000", "0x1234", "1234000000000000000000000000000000000000000000000000000000000000", true},
+		{"t_bytes2", "0x123456", "", "", false},
+		{"t_string_memory_ptr", "alice", "alice", "616c696365", true},
+		{"t_bytes_memory_ptr", "0x0102", "0x0102", "0102", true},
+	}
+
+	for idx, test := range testMatrix {
+		encoded, formatted, err := encodeMappingKey(test.keyType, test.candidate)
+		if !test.applicable {
+			assert.Equal(t, errKeyNotApplicable, err, "Test index %d failed", idx)
+			continue
+		}
+		assert.Nil(t, err, "Test index %d failed", idx)
+		assert.Equal(t, test.expectedKey, formatted, "Test index %d failed", idx)
+		assert.Equal(t, test.expectedWord, hex.EncodeToString(encoded), "Test index %d failed", idx)
+	}
+}
+
+func TestCandidateMappingKeys(t *testing.T) {
+	contract := types.NewAddress("0x0000000000000000000000000000000000000001")
+	txs := []*types.Transaction{
+		{
+			From: types.NewAddress(holder0),
+			To:   contract,
+			// transfer(holder1, 100)
+			Data: types.NewHexData("0xa9059cbb000000000000000000000000ca843569e3427144cead5e4d5999a3d0ccf92b8e0000000000000000000000000000000000000000000000000000000000000064"),
+		},
+		{
+			From: types.NewAddress(holder1),
+			To:   types.NewAddress("0x0000000000000000000000000000000000000002"),
+			Data: types.NewHexData("0x12345678"),
+			InternalCalls: []*types.InternalCall{
+				{From: types.NewAddress("0x0000000000000000000000000000000000000002"), To: contract, Input: types.NewHexData("0x12345678")},
+			},
+		},
+	}
+	events := []*types.Event{
+		{
+			Address: contract,
+			Topics:  []types.Hash{types.NewHash("0x01"), types.NewHash(holder0)},
+			Data:    types.NewHexData("0x0000000000000000000000000000000000000000000000000000000000000064"),
+		},
+		{
+			Address: types.NewAddress("0x0000000000000000000000000000000000000002"),
+			Topics:  []types.Hash{types.NewHash("0x01"), types.NewHash("0x05")},
+		},
+	}
+
+	keys := CandidateMappingKeys(contract, txs, events)
+	assert.Equal(t, []string{
+		"0x000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d",
+		"0x000000000000000000000000ca843569e3427144cead5e4d5999a3d0ccf92b8e",
+		"0x0000000000000000000000000000000000000000000000000000000000000064",
+		"0x0000000000000000000000000000000000000000000000000000000000000002",
+	}, keys)
+}
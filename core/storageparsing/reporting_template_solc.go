@@ -1,11 +1,28 @@
 package storageparsing
 
 import (
+	"quorumengineering/quorum-report/log"
 	"quorumengineering/quorum-report/types"
 )
 
-func ParseRawStorage(rawStorage map[types.Hash]string, template types.SolidityStorageDocument) ([]*types.StorageItem, error) {
+// maxMappingLookups bounds the number of mapping entries looked up when parsing the storage
+// of a contract, as each candidate key is tried in every mapping, and again at each level of
+// a nested mapping
+var maxMappingLookups = 100000
+
+// ParseRawStorage parses the storage of a contract using its storage layout. Mappings are
+// reconstructed using the given candidate keys, see Parser.ParseMapping, and are left
+// incomplete once maxMappingLookups entries have been looked up. The mapping keys are
+// provided by the user, and the observed keys are the 32 byte words seen on chain.
+func ParseRawStorage(rawStorage map[types.Hash]string, template types.SolidityStorageDocument, mappingKeys []string, observedKeys []string) ([]*types.StorageItem, error) {
 	initialStorageManager := NewDefaultStorageHandler(rawStorage)
 	parser := NewParser(initialStorageManager, template, types.NewHash(""))
-	return parser.ParseRawStorage()
+	parser.mappingKeys = mappingKeys
+	parser.observedKeys = observedKeys
+	parser.mappingLookups = &lookupBudget{remaining: maxMappingLookups}
+	result, err := parser.ParseRawStorage()
+	if err == nil && parser.mappingLookups.exceeded {
+		log.Warn("Mapping lookup limit reached, so mappings are incomplete", "limit", maxMappingLookups, "keys", len(mappingKeys)+len(observedKeys))
+	}
+	return result, err
 }
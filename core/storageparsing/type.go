@@ -21,8 +21,9 @@ var (
 	bytesStoragePrefix = "t_bytes_storage"
 	stringPrefix       = "t_string_storage"
 
-	arrayPrefix  = "t_array"
-	structPrefix = "t_struct"
+	arrayPrefix   = "t_array"
+	structPrefix  = "t_struct"
+	mappingPrefix = "t_mapping"
 )
 
 type Parser struct {
@@ -30,6 +31,14 @@ type Parser struct {
 	template       types.SolidityStorageDocument
 
 	slotOffset types.Hash
+
+	// mappingKeys are the candidate keys tried when reconstructing mappings
+	mappingKeys []string
+	// observedKeys are the 32 byte words seen on chain, which are also tried as the keys of
+	// mappings with value type keys
+	observedKeys []string
+	// mappingLookups bounds the mapping entries looked up, shared with child parsers
+	mappingLookups *lookupBudget
 }
 
 func NewParser(sm StorageManager, template types.SolidityStorageDocument, slotOffset types.Hash) *Parser {
@@ -44,6 +53,16 @@ func NewParser(sm StorageManager, template types.SolidityStorageDocument, slotOf
 	return parser
 }
 
+// newChildParser creates a parser for a nested part of the storage, such as the elements of
+// an array or members of a struct, sharing the candidate mapping keys and lookup budget
+func (p *Parser) newChildParser(sm StorageManager, template types.SolidityStorageDocument, slotOffset types.Hash) *Parser {
+	child := NewParser(sm, template, slotOffset)
+	child.mappingKeys = p.mappingKeys
+	child.observedKeys = p.observedKeys
+	child.mappingLookups = p.mappingLookups
+	return child
+}
+
 func (p *Parser) ParseRawStorage() ([]*types.StorageItem, error) {
 	parsedStorage := []*types.StorageItem{}
 
@@ -127,6 +146,13 @@ func (p *Parser) parseSingle(storageItem types.SolidityStorageEntry) (interface{
 			return nil, err
 		}
 		result = res
+
+	case strings.HasPrefix(storageItem.Type, mappingPrefix):
+		res, err := p.ParseMapping(storageItem, namedType)
+		if err != nil {
+			return nil, err
+		}
+		result = res
 	}
 
 	return result, nil
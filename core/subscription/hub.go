@@ -0,0 +1,230 @@
+package subscription
+
+import (
+	"strconv"
+	"sync"
+
+	"quorumengineering/quorum-report/core/proxy"
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// notificationBufferSize is the number of notifications a subscription can have pending
+// before it is dropped
+const notificationBufferSize = 1024
+
+type Database interface {
+	proxy.Database
+}
+
+// Subscription receives notifications matching its criteria until it is unsubscribed,
+// dropped for falling behind, or the hub is stopped.
+type Subscription struct {
+	ID       string
+	criteria Criteria
+	topic    types.Hash
+
+	notifications chan *Notification
+	err           error
+}
+
+// Notifications returns the channel notifications are delivered on. It is closed when the
+// subscription ends.
+func (s *Subscription) Notifications() <-chan *Notification {
+	return s.notifications
+}
+
+// Err returns the reason the subscription was ended by the hub, if any. It must only be
+// called after the notifications channel has been closed.
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// Hub tracks subscriptions and publishes newly indexed transactions and events to them.
+type Hub struct {
+	db Database
+
+	mux           sync.Mutex
+	subscriptions map[string]*Subscription
+	idCounter     uint64
+	stopped       bool
+	shutdownChan  chan struct{}
+}
+
+func NewHub(db Database) *Hub {
+	return &Hub{
+		db:            db,
+		subscriptions: make(map[string]*Subscription),
+		shutdownChan:  make(chan struct{}),
+	}
+}
+
+func (h *Hub) Subscribe(criteria Criteria) (*Subscription, error) {
+	if err := criteria.validate(); err != nil {
+		return nil, err
+	}
+	topic, err := criteria.eventTopic()
+	if err != nil {
+		return nil, err
+	}
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.idCounter++
+	sub := &Subscription{
+		ID:            "0x" + strconv.FormatUint(h.idCounter, 16),
+		criteria:      criteria,
+		topic:         topic,
+		notifications: make(chan *Notification, notificationBufferSize),
+	}
+	if h.stopped {
+		close(sub.notifications)
+		return sub, nil
+	}
+	h.subscriptions[sub.ID] = sub
+	log.Debug("Subscription added", "id", sub.ID)
+	return sub, nil
+}
+
+func (h *Hub) Unsubscribe(id string) error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	sub, ok := h.subscriptions[id]
+	if !ok {
+		return ErrSubscriptionNotFound
+	}
+	h.remove(sub, nil)
+	return nil
+}
+
+// Done returns a channel that is closed when the hub is stopped
+func (h *Hub) Done() <-chan struct{} {
+	return h.shutdownChan
+}
+
+// Stop ends all subscriptions. Any subsequent subscriptions are closed immediately.
+func (h *Hub) Stop() {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if h.stopped {
+		return
+	}
+	h.stopped = true
+	for _, sub := range h.subscriptions {
+		h.remove(sub, nil)
+	}
+	close(h.shutdownChan)
+	log.Info("Subscription hub stopped")
+}
+
+// delivery is a notification waiting to be sent to a subscription
+type delivery struct {
+	sub          *Subscription
+	notification *Notification
+}
+
+// Publish notifies subscribers of the transactions and events in the given blocks that
+// relate to the given addresses, which have just been indexed. The notifications are built
+// for the subscriptions at the time of the call, without holding the lock, as decoding events
+// reads their ABIs from the database.
+func (h *Hub) Publish(addresses []types.Address, blocks []*types.BlockWithTransactions) {
+	h.mux.Lock()
+	subscriptions := make([]*Subscription, 0, len(h.subscriptions))
+	for _, sub := range h.subscriptions {
+		subscriptions = append(subscriptions, sub)
+	}
+	h.mux.Unlock()
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	indexed := make(map[types.Address]bool)
+	for _, address := range addresses {
+		indexed[address] = true
+	}
+	resolver := proxy.NewResolver(h.db)
+
+	var deliveries []delivery
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			for _, sub := range subscriptions {
+				if sub.criteria.matchesTransaction(tx) && indexed[*sub.criteria.Address] {
+					deliveries = append(deliveries, delivery{sub, &Notification{
+						Type:            NotificationTypeTransaction,
+						BlockNumber:     block.Number,
+						TransactionHash: tx.Hash,
+					}})
+				}
+			}
+
+			for _, event := range tx.Events {
+				if !indexed[event.Address] {
+					continue
+				}
+				var parsedEvent *types.ParsedEvent
+				for _, sub := range subscriptions {
+					if !sub.criteria.matchesEvent(event, sub.topic) {
+						continue
+					}
+					if parsedEvent == nil {
+						parsedEvent = h.parseEvent(resolver, event, block.Number)
+					}
+					deliveries = append(deliveries, delivery{sub, &Notification{
+						Type:            NotificationTypeEvent,
+						BlockNumber:     block.Number,
+						TransactionHash: tx.Hash,
+						Event:           parsedEvent,
+					}})
+				}
+			}
+		}
+	}
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	for _, d := range deliveries {
+		// the subscription may have ended while the notifications were built
+		if h.subscriptions[d.sub.ID] != d.sub {
+			continue
+		}
+		h.send(d.sub, d.notification)
+	}
+}
+
+// parseEvent decodes the event using the ABI of its contract at the given block.
+// The raw event is still delivered if it cannot be decoded.
+func (h *Hub) parseEvent(resolver *proxy.Resolver, event *types.Event, blockNumber uint64) *types.ParsedEvent {
+	parsedEvent := &types.ParsedEvent{RawEvent: event}
+	contractABI, err := resolver.ABI(event.Address, blockNumber)
+	if err != nil {
+		log.Warn("Fetching contract ABI for subscription failed", "address", event.Address.Hex(), "err", err)
+	}
+	if contractABI != "" {
+		if err := parsedEvent.ParseEvent(contractABI); err != nil {
+			log.Warn("Parsing event for subscription failed", "address", event.Address.Hex(), "err", err)
+		}
+	}
+	return parsedEvent
+}
+
+// send delivers a notification without blocking, dropping subscriptions that are full.
+// The caller must hold the write lock.
+func (h *Hub) send(sub *Subscription, notification *Notification) {
+	select {
+	case sub.notifications <- notification:
+	default:
+		log.Warn("Dropping slow subscription", "id", sub.ID)
+		h.remove(sub, ErrSubscriptionSlowReader)
+	}
+}
+
+// remove ends a subscription. The caller must hold the write lock.
+func (h *Hub) remove(sub *Subscription, err error) {
+	if _, ok := h.subscriptions[sub.ID]; !ok {
+		return
+	}
+	delete(h.subscriptions, sub.ID)
+	sub.err = err
+	close(sub.notifications)
+	log.Debug("Subscription removed", "id", sub.ID)
+}
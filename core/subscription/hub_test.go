@@ -0,0 +1,242 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/types"
+)
+
+const testAbi = `[{"anonymous":false,"inputs":[{"indexed":false,"internalType":"uint256","name":"_value","type":"uint256"}],"name":"valueSet","type":"event"}]`
+
+var (
+	addr        = types.NewAddress("0x0000000000000000000000000000000000000001")
+	otherAddr   = types.NewAddress("0x0000000000000000000000000000000000000002")
+	holder      = "0x000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d"
+	valueSetSig = "0xefe5cb8d23d632b5d2cdd9f0a151c4b1a84ccb7afa1c57331009aa922d5e4f36"
+
+	valueSetEvent = &types.Event{
+		Address: addr,
+		Topics:  []types.Hash{types.NewHash(valueSetSig)},
+		Data:    "000000000000000000000000000000000000000000000000000000000000002a",
+	}
+	erc20Transfer = &types.Event{
+		Address: otherAddr,
+		Topics:  []types.Hash{transferTopicHash, types.NewHash(holder), types.NewHash(holder)},
+		Data:    "0000000000000000000000000000000000000000000000000000000000000001",
+	}
+	erc721Transfer = &types.Event{
+		Address: otherAddr,
+		Topics:  []types.Hash{transferTopicHash, types.NewHash(holder), types.NewHash(holder), types.NewHash("0x1")},
+	}
+
+	testBlock = &types.BlockWithTransactions{
+		Number: 5,
+		Transactions: []*types.Transaction{
+			{Hash: types.NewHash("0x1"), To: addr, Events: []*types.Event{valueSetEvent}},
+			{Hash: types.NewHash("0x2"), To: otherAddr, InternalCalls: []*types.InternalCall{{To: addr}}},
+			{Hash: types.NewHash("0x3"), To: otherAddr, Events: []*types.Event{erc20Transfer, erc721Transfer}},
+		},
+	}
+)
+
+type fakeDB struct{}
+
+func (f *fakeDB) GetContractABI(address types.Address) (string, error) {
+	if address == addr {
+		return testAbi, nil
+	}
+	return "", nil
+}
+
+func (f *fakeDB) GetStorageLayout(address types.Address) (string, error) {
+	return "", nil
+}
+
+func (f *fakeDB) GetProxyImplementations(address types.Address) ([]*types.ProxyImplementation, error) {
+	return nil, nil
+}
+
+// blockingDB waits to be released each time an ABI is read, signalling that it is waiting
+type blockingDB struct {
+	fakeDB
+	reading chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingDB) GetContractABI(address types.Address) (string, error) {
+	b.reading <- struct{}{}
+	<-b.release
+	return b.fakeDB.GetContractABI(address)
+}
+
+func drain(sub *Subscription) []*Notification {
+	notifications := make([]*Notification, 0)
+	for {
+		select {
+		case notification := <-sub.Notifications():
+			if notification == nil {
+				return notifications
+			}
+			notifications = append(notifications, notification)
+		default:
+			return notifications
+		}
+	}
+}
+
+func TestHub_SubscribeValidation(t *testing.T) {
+	hub := NewHub(&fakeDB{})
+
+	_, err := hub.Subscribe(Criteria{})
+	assert.Equal(t, ErrNoCriteria, err)
+
+	_, err = hub.Subscribe(Criteria{TokenTransfers: "erc1155"})
+	assert.Equal(t, ErrInvalidTokenTransfers, err)
+
+	_, err = hub.Subscribe(Criteria{EventSignature: "0x1234"})
+	assert.Equal(t, ErrInvalidEventSignature, err)
+
+	assert.Equal(t, ErrSubscriptionNotFound, hub.Unsubscribe("0x1"))
+}
+
+func TestHub_PublishAddress(t *testing.T) {
+	hub := NewHub(&fakeDB{})
+	sub, err := hub.Subscribe(Criteria{Address: &addr})
+	assert.Nil(t, err)
+
+	hub.Publish([]types.Address{addr, otherAddr}, []*types.BlockWithTransactions{testBlock})
+
+	notifications := drain(sub)
+	assert.Len(t, notifications, 3)
+	assert.Equal(t, NotificationTypeTransaction, notifications[0].Type)
+	assert.Equal(t, types.NewHash("0x1"), notifications[0].TransactionHash)
+	assert.Equal(t, NotificationTypeEvent, notifications[1].Type)
+	assert.EqualValues(t, 5, notifications[1].BlockNumber)
+	assert.Equal(t, "event valueSet(uint256 _value)", notifications[1].Event.Sig)
+	assert.EqualValues(t, 42, notifications[1].Event.ParsedData["_value"].(interface{ Int64() int64 }).Int64())
+	assert.Equal(t, NotificationTypeTransaction, notifications[2].Type)
+	assert.Equal(t, types.NewHash("0x2"), notifications[2].TransactionHash)
+}
+
+func TestHub_PublishOnlyIndexedAddresses(t *testing.T) {
+	hub := NewHub(&fakeDB{})
+	sub, err := hub.Subscribe(Criteria{Address: &addr})
+	assert.Nil(t, err)
+
+	hub.Publish([]types.Address{otherAddr}, []*types.BlockWithTransactions{testBlock})
+
+	assert.Len(t, drain(sub), 0)
+}
+
+func TestHub_PublishEventSignature(t *testing.T) {
+	hub := NewHub(&fakeDB{})
+	byTopic, err := hub.Subscribe(Criteria{EventSignature: valueSetSig})
+	assert.Nil(t, err)
+	bySignature, err := hub.Subscribe(Criteria{EventSignature: "valueSet(uint256)"})
+	assert.Nil(t, err)
+	byOtherAddress, err := hub.Subscribe(Criteria{Address: &otherAddr, EventSignature: "valueSet(uint256)"})
+	assert.Nil(t, err)
+
+	hub.Publish([]types.Address{addr, otherAddr}, []*types.BlockWithTransactions{testBlock})
+
+	for _, sub := range []*Subscription{byTopic, bySignature} {
+		notifications := drain(sub)
+		assert.Len(t, notifications, 1)
+		assert.Equal(t, valueSetEvent, notifications[0].Event.RawEvent)
+	}
+	assert.Len(t, drain(byOtherAddress), 0)
+}
+
+func TestHub_PublishTokenTransfers(t *testing.T) {
+	hub := NewHub(&fakeDB{})
+	erc20, err := hub.Subscribe(Criteria{TokenTransfers: ERC20Transfers})
+	assert.Nil(t, err)
+	erc721, err := hub.Subscribe(Criteria{TokenTransfers: ERC721Transfers})
+	assert.Nil(t, err)
+
+	hub.Publish([]types.Address{addr, otherAddr}, []*types.BlockWithTransactions{testBlock})
+
+	notifications := drain(erc20)
+	assert.Len(t, notifications, 1)
+	assert.Equal(t, erc20Transfer, notifications[0].Event.RawEvent)
+	assert.Equal(t, types.NewHash("0x3"), notifications[0].TransactionHash)
+
+	notifications = drain(erc721)
+	assert.Len(t, notifications, 1)
+	assert.Equal(t, erc721Transfer, notifications[0].Event.RawEvent)
+}
+
+func TestHub_PublishDecodesEventsWithoutLock(t *testing.T) {
+	db := &blockingDB{reading: make(chan struct{}), release: make(chan struct{})}
+	hub := NewHub(db)
+	ended, err := hub.Subscribe(Criteria{Address: &addr})
+	assert.Nil(t, err)
+
+	published := make(chan struct{})
+	go func() {
+		hub.Publish([]types.Address{addr}, []*types.BlockWithTransactions{testBlock})
+		close(published)
+	}()
+	<-db.reading
+
+	// subscriptions can be managed while the event is decoded
+	managed := make(chan struct{})
+	var added *Subscription
+	go func() {
+		added, err = hub.Subscribe(Criteria{Address: &addr})
+		assert.Nil(t, err)
+		assert.Nil(t, hub.Unsubscribe(ended.ID))
+		close(managed)
+	}()
+	select {
+	case <-managed:
+	case <-time.After(time.Second):
+		t.Fatal("subscriptions are locked while the event is decoded")
+	}
+	close(db.release)
+	<-published
+
+	// neither the ended subscription nor the one added during the publish is notified
+	_, open := <-ended.Notifications()
+	assert.False(t, open)
+	assert.Len(t, drain(added), 0)
+}
+
+func TestHub_DropsSlowSubscription(t *testing.T) {
+	hub := NewHub(&fakeDB{})
+	sub, err := hub.Subscribe(Criteria{Address: &addr})
+	assert.Nil(t, err)
+
+	blocks := make([]*types.BlockWithTransactions, notificationBufferSize)
+	for i := range blocks {
+		blocks[i] = testBlock
+	}
+	hub.Publish([]types.Address{addr}, blocks)
+
+	assert.Len(t, drain(sub), notificationBufferSize)
+	_, open := <-sub.Notifications()
+	assert.False(t, open)
+	assert.Equal(t, ErrSubscriptionSlowReader, sub.Err())
+	assert.Equal(t, ErrSubscriptionNotFound, hub.Unsubscribe(sub.ID))
+}
+
+func TestHub_Stop(t *testing.T) {
+	hub := NewHub(&fakeDB{})
+	sub, err := hub.Subscribe(Criteria{Address: &addr})
+	assert.Nil(t, err)
+
+	hub.Stop()
+
+	_, open := <-sub.Notifications()
+	assert.False(t, open)
+	assert.Nil(t, sub.Err())
+	<-hub.Done()
+
+	sub, err = hub.Subscribe(Criteria{Address: &addr})
+	assert.Nil(t, err)
+	_, open = <-sub.Notifications()
+	assert.False(t, open)
+}
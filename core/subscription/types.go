@@ -0,0 +1,109 @@
+package subscription
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+
+	"quorumengineering/quorum-report/types"
+)
+
+const (
+	ERC20Transfers  = "erc20"
+	ERC721Transfers = "erc721"
+
+	NotificationTypeTransaction = "transaction"
+	NotificationTypeEvent       = "event"
+)
+
+var (
+	ErrNoCriteria             = errors.New("at least one of address, eventSignature or tokenTransfers must be given")
+	ErrInvalidTokenTransfers  = errors.New(`tokenTransfers must be "erc20" or "erc721"`)
+	ErrInvalidEventSignature  = errors.New("eventSignature must be a topic hash or an event signature")
+	ErrSubscriptionNotFound   = errors.New("subscription not found")
+	ErrSubscriptionSlowReader = errors.New("subscription dropped as the client is not keeping up")
+
+	// transferTopicHash is the topic hash shared by the ERC20 and ERC721 Transfer events
+	transferTopicHash = types.NewHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+)
+
+// Criteria selects the data a subscription receives. All given fields must match.
+// Transaction hashes are only delivered to subscriptions that filter on an address alone.
+type Criteria struct {
+	// Address is a registered contract to receive events and transactions for
+	Address *types.Address `json:"address,omitempty"`
+	// EventSignature is either the event topic hash, or the canonical event signature
+	// such as "Transfer(address,address,uint256)"
+	EventSignature string `json:"eventSignature,omitempty"`
+	// TokenTransfers is either "erc20" or "erc721"
+	TokenTransfers string `json:"tokenTransfers,omitempty"`
+}
+
+func (c Criteria) validate() error {
+	if c.Address == nil && c.EventSignature == "" && c.TokenTransfers == "" {
+		return ErrNoCriteria
+	}
+	if c.TokenTransfers != "" && c.TokenTransfers != ERC20Transfers && c.TokenTransfers != ERC721Transfers {
+		return ErrInvalidTokenTransfers
+	}
+	return nil
+}
+
+// eventTopic returns the topic hash of the event signature in the criteria
+func (c Criteria) eventTopic() (types.Hash, error) {
+	if c.EventSignature == "" {
+		return "", nil
+	}
+	if strings.Contains(c.EventSignature, "(") {
+		d := sha3.NewLegacyKeccak256()
+		d.Write([]byte(c.EventSignature))
+		return types.NewHash(hex.EncodeToString(d.Sum(nil))), nil
+	}
+	topic := strings.ToLower(strings.TrimPrefix(c.EventSignature, "0x"))
+	if _, err := hex.DecodeString(topic); err != nil || len(topic) != 64 {
+		return "", ErrInvalidEventSignature
+	}
+	return types.NewHash(topic), nil
+}
+
+func (c Criteria) matchesTransaction(tx *types.Transaction) bool {
+	if c.Address == nil || c.EventSignature != "" || c.TokenTransfers != "" {
+		return false
+	}
+	if tx.To == *c.Address || tx.CreatedContract == *c.Address {
+		return true
+	}
+	for _, call := range tx.InternalCalls {
+		if call.To == *c.Address {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Criteria) matchesEvent(event *types.Event, topic types.Hash) bool {
+	if c.Address != nil && event.Address != *c.Address {
+		return false
+	}
+	if c.EventSignature != "" && (len(event.Topics) == 0 || event.Topics[0] != topic) {
+		return false
+	}
+	switch c.TokenTransfers {
+	case ERC20Transfers:
+		// ERC20 and ERC721 transfers share a signature, but the ERC721 token ID is indexed
+		return len(event.Topics) == 3 && event.Topics[0] == transferTopicHash
+	case ERC721Transfers:
+		return len(event.Topics) == 4 && event.Topics[0] == transferTopicHash
+	}
+	return true
+}
+
+// Notification is sent to a subscriber for each matching transaction or event
+type Notification struct {
+	Type            string             `json:"type"`
+	BlockNumber     uint64             `json:"blockNumber"`
+	TransactionHash types.Hash         `json:"transactionHash"`
+	Event           *types.ParsedEvent `json:"event,omitempty"`
+}
@@ -0,0 +1,238 @@
+package subscription
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"quorumengineering/quorum-report/log"
+)
+
+const (
+	writeWait  = 10 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+
+	errCodeInvalidRequest = -32600
+	errCodeMethodNotFound = -32601
+	errCodeInvalidParams  = -32602
+)
+
+type message struct {
+	Version string          `json:"jsonrpc,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Error   *msgError       `json:"error,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+}
+
+type msgError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type subMessage struct {
+	ID     string        `json:"subscription"`
+	Result *Notification `json:"result,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// WebSocketHandler serves subscriptions over WebSocket connections. Clients send JSON-RPC
+// "subscribe" and "unsubscribe" requests and receive "subscription" notifications.
+type WebSocketHandler struct {
+	hub      *Hub
+	upgrader websocket.Upgrader
+}
+
+// NewWebSocketHandler creates a handler for the hub. If allowed origins are given,
+// connections are only accepted from those origins, "*" allowing any.
+func NewWebSocketHandler(hub *Hub, allowedOrigins []string) *WebSocketHandler {
+	upgrader := websocket.Upgrader{}
+	if len(allowedOrigins) > 0 {
+		upgrader.CheckOrigin = func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			for _, allowed := range allowedOrigins {
+				if allowed == "*" || allowed == origin {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return &WebSocketHandler{hub: hub, upgrader: upgrader}
+}
+
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Warn("WebSocket upgrade failed", "err", err)
+		return
+	}
+	newConnection(h.hub, conn).serve()
+}
+
+// connection holds the subscriptions made over a single WebSocket connection
+type connection struct {
+	hub  *Hub
+	conn *websocket.Conn
+
+	outgoing      chan interface{}
+	closeChan     chan struct{}
+	subscriptions map[string]*Subscription
+	subMux        sync.Mutex
+	forwardWg     sync.WaitGroup
+}
+
+func newConnection(hub *Hub, conn *websocket.Conn) *connection {
+	return &connection{
+		hub:           hub,
+		conn:          conn,
+		outgoing:      make(chan interface{}),
+		closeChan:     make(chan struct{}),
+		subscriptions: make(map[string]*Subscription),
+	}
+}
+
+// serve reads requests until the connection closes, then ends all its subscriptions
+func (c *connection) serve() {
+	log.Debug("Subscription connection opened", "remote", c.conn.RemoteAddr())
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		c.writeLoop()
+	}()
+
+	// the deadlines set by the HTTP server still apply after the upgrade, so replace them
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	go func() {
+		select {
+		case <-c.hub.Done():
+			_ = c.conn.Close()
+		case <-c.closeChan:
+		}
+	}()
+
+	for {
+		var req message
+		if err := c.conn.ReadJSON(&req); err != nil {
+			switch err.(type) {
+			case *json.SyntaxError, *json.UnmarshalTypeError:
+				c.reply(nil, nil, &msgError{Code: errCodeInvalidRequest, Message: err.Error()})
+				continue
+			}
+			break
+		}
+		c.handle(&req)
+	}
+
+	c.subMux.Lock()
+	for id := range c.subscriptions {
+		_ = c.hub.Unsubscribe(id)
+	}
+	c.subMux.Unlock()
+	c.forwardWg.Wait()
+	close(c.closeChan)
+	<-writerDone
+	_ = c.conn.Close()
+	log.Debug("Subscription connection closed", "remote", c.conn.RemoteAddr())
+}
+
+func (c *connection) handle(req *message) {
+	switch req.Method {
+	case "subscribe":
+		var params []Criteria
+		if err := json.Unmarshal(req.Params, &params); err != nil || len(params) != 1 {
+			c.reply(req.ID, nil, &msgError{Code: errCodeInvalidParams, Message: "expected a single criteria object"})
+			return
+		}
+		sub, err := c.hub.Subscribe(params[0])
+		if err != nil {
+			c.reply(req.ID, nil, &msgError{Code: errCodeInvalidParams, Message: err.Error()})
+			return
+		}
+		c.subMux.Lock()
+		c.subscriptions[sub.ID] = sub
+		c.subMux.Unlock()
+		// reply before forwarding so the client knows the ID before any notification
+		c.reply(req.ID, sub.ID, nil)
+		c.forwardWg.Add(1)
+		go c.forward(sub)
+	case "unsubscribe":
+		var params []string
+		if err := json.Unmarshal(req.Params, &params); err != nil || len(params) != 1 {
+			c.reply(req.ID, nil, &msgError{Code: errCodeInvalidParams, Message: "expected a single subscription ID"})
+			return
+		}
+		c.subMux.Lock()
+		_, ok := c.subscriptions[params[0]]
+		c.subMux.Unlock()
+		if !ok {
+			c.reply(req.ID, nil, &msgError{Code: errCodeInvalidParams, Message: ErrSubscriptionNotFound.Error()})
+			return
+		}
+		// the subscription may have already been ended by the hub
+		_ = c.hub.Unsubscribe(params[0])
+		c.reply(req.ID, true, nil)
+	default:
+		c.reply(req.ID, nil, &msgError{Code: errCodeMethodNotFound, Message: "method not found: " + req.Method})
+	}
+}
+
+// forward sends the notifications of a subscription to the client until it ends
+func (c *connection) forward(sub *Subscription) {
+	defer c.forwardWg.Done()
+	for notification := range sub.Notifications() {
+		c.send(&message{Version: "2.0", Method: "subscription"}, subMessage{ID: sub.ID, Result: notification})
+	}
+	c.subMux.Lock()
+	delete(c.subscriptions, sub.ID)
+	c.subMux.Unlock()
+	if err := sub.Err(); err != nil {
+		c.send(&message{Version: "2.0", Method: "subscription"}, subMessage{ID: sub.ID, Error: err.Error()})
+	}
+}
+
+func (c *connection) reply(id json.RawMessage, result interface{}, err *msgError) {
+	c.outgoing <- &message{Version: "2.0", ID: id, Result: result, Error: err}
+}
+
+// send queues a notification, wrapping the subscription message as its parameters
+func (c *connection) send(msg *message, params subMessage) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		log.Warn("Encoding subscription notification failed", "id", params.ID, "err", err)
+		return
+	}
+	msg.Params = raw
+	c.outgoing <- msg
+}
+
+// writeLoop is the only writer to the connection, so that writes are never concurrent
+func (c *connection) writeLoop() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg := <-c.outgoing:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteJSON(msg); err != nil {
+				log.Debug("Writing to subscription connection failed", "err", err)
+				_ = c.conn.Close()
+			}
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				_ = c.conn.Close()
+			}
+		case <-c.closeChan:
+			return
+		}
+	}
+}
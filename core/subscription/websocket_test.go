@@ -0,0 +1,116 @@
+package subscription
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/types"
+)
+
+type testMessage struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params struct {
+		Subscription string        `json:"subscription"`
+		Result       *Notification `json:"result"`
+	} `json:"params"`
+	Error  *msgError       `json:"error"`
+	Result json.RawMessage `json:"result"`
+}
+
+func dial(t *testing.T, hub *Hub) (*websocket.Conn, func()) {
+	server := httptest.NewServer(NewWebSocketHandler(hub, nil))
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	assert.Nil(t, err)
+	return conn, func() {
+		_ = conn.Close()
+		server.Close()
+	}
+}
+
+func request(t *testing.T, conn *websocket.Conn, req string) testMessage {
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte(req)))
+	return read(t, conn)
+}
+
+func read(t *testing.T, conn *websocket.Conn) testMessage {
+	var msg testMessage
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	assert.Nil(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+func TestWebSocketHandler_Subscribe(t *testing.T) {
+	hub := NewHub(&fakeDB{})
+	conn, closeFn := dial(t, hub)
+	defer closeFn()
+
+	resp := request(t, conn, `{"jsonrpc":"2.0","id":1,"method":"subscribe","params":[{"address":"0x0000000000000000000000000000000000000001"}]}`)
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, `1`, string(resp.ID))
+	var subID string
+	assert.Nil(t, json.Unmarshal(resp.Result, &subID))
+
+	hub.Publish([]types.Address{addr}, []*types.BlockWithTransactions{testBlock})
+
+	notification := read(t, conn)
+	assert.Equal(t, "subscription", notification.Method)
+	assert.Equal(t, subID, notification.Params.Subscription)
+	assert.Equal(t, NotificationTypeTransaction, notification.Params.Result.Type)
+	assert.Equal(t, types.NewHash("0x1"), notification.Params.Result.TransactionHash)
+
+	notification = read(t, conn)
+	assert.Equal(t, NotificationTypeEvent, notification.Params.Result.Type)
+	assert.Equal(t, "event valueSet(uint256 _value)", notification.Params.Result.Event.Sig)
+	assert.Equal(t, addr, notification.Params.Result.Event.RawEvent.Address)
+
+	notification = read(t, conn)
+	assert.Equal(t, types.NewHash("0x2"), notification.Params.Result.TransactionHash)
+
+	resp = request(t, conn, `{"jsonrpc":"2.0","id":2,"method":"unsubscribe","params":["`+subID+`"]}`)
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, "true", string(resp.Result))
+
+	resp = request(t, conn, `{"jsonrpc":"2.0","id":3,"method":"unsubscribe","params":["`+subID+`"]}`)
+	assert.Equal(t, ErrSubscriptionNotFound.Error(), resp.Error.Message)
+}
+
+func TestWebSocketHandler_InvalidRequests(t *testing.T) {
+	hub := NewHub(&fakeDB{})
+	conn, closeFn := dial(t, hub)
+	defer closeFn()
+
+	resp := request(t, conn, `{"jsonrpc":"2.0","id":1,"method":"subscribe","params":[{}]}`)
+	assert.Equal(t, errCodeInvalidParams, resp.Error.Code)
+	assert.Equal(t, ErrNoCriteria.Error(), resp.Error.Message)
+
+	resp = request(t, conn, `{"jsonrpc":"2.0","id":2,"method":"subscribe","params":{}}`)
+	assert.Equal(t, errCodeInvalidParams, resp.Error.Code)
+
+	resp = request(t, conn, `{"jsonrpc":"2.0","id":3,"method":"eth_subscribe","params":[]}`)
+	assert.Equal(t, errCodeMethodNotFound, resp.Error.Code)
+
+	resp = request(t, conn, `not json`)
+	assert.Equal(t, errCodeInvalidRequest, resp.Error.Code)
+}
+
+func TestWebSocketHandler_HubStopClosesConnection(t *testing.T) {
+	hub := NewHub(&fakeDB{})
+	conn, closeFn := dial(t, hub)
+	defer closeFn()
+
+	resp := request(t, conn, `{"jsonrpc":"2.0","id":1,"method":"subscribe","params":[{"tokenTransfers":"erc20"}]}`)
+	assert.Nil(t, resp.Error)
+
+	hub.Stop()
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := conn.ReadMessage()
+	assert.NotNil(t, err)
+}
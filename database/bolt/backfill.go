@@ -0,0 +1,47 @@
+package bolt
+
+import (
+	"encoding/json"
+
+	bbolt "go.etcd.io/bbolt"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/types"
+)
+
+// BackfillDB
+func (bdb *BoltDB) RecordBackfillJob(job *types.BackfillJob) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		return putJSON(tx.Bucket(BackfillBucket), addressKey(job.Contract), job)
+	})
+}
+
+func (bdb *BoltDB) GetBackfillJob(contract types.Address) (*types.BackfillJob, error) {
+	var job types.BackfillJob
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		entry := tx.Bucket(BackfillBucket).Get(addressKey(contract))
+		if entry == nil {
+			return database.ErrNotFound
+		}
+		return json.Unmarshal(entry, &job)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (bdb *BoltDB) GetBackfillJobs() ([]*types.BackfillJob, error) {
+	jobs := make([]*types.BackfillJob, 0)
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(BackfillBucket).ForEach(func(k, v []byte) error {
+			var job types.BackfillJob
+			if err := json.Unmarshal(v, &job); err != nil {
+				return err
+			}
+			jobs = append(jobs, &job)
+			return nil
+		})
+	})
+	return jobs, err
+}
@@ -0,0 +1,458 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	bbolt "go.etcd.io/bbolt"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// BoltDB is an embedded on-disk database, for environments where running Elasticsearch is not possible.
+type BoltDB struct {
+	db *bbolt.DB
+}
+
+func New(path string) (*BoltDB, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, bucket := range AllBuckets {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		meta := tx.Bucket(MetaBucket)
+		if meta.Get(lastPersistedKey) == nil {
+			return meta.Put(lastPersistedKey, uint64Key(0))
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltDB{db: db}, nil
+}
+
+// AddressDB
+func (bdb *BoltDB) AddAddresses(addresses []types.Address) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		for _, address := range addresses {
+			if err := addContract(tx, address, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (bdb *BoltDB) AddAddressFrom(address types.Address, from uint64) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		return addContract(tx, address, from-1)
+	})
+}
+
+func (bdb *BoltDB) DeleteAddress(address types.Address) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket(ContractBucket).Get(addressKey(address)) == nil {
+			return errors.New("address does not exist")
+		}
+
+		prefix := addressKey(address)
+		for _, bucket := range [][]byte{EventBucket, StorageBucket, ERC20TokenBucket, ERC20AllowanceBucket, ERC721TokenBucket, ERC1155TokenBucket, TokenBalanceBucket, TokenTransferBucket, TokenInfoBucket, TotalSupplyBucket, ProxyBucket, BackfillBucket, NativeBalanceBucket, FailureBucket} {
+			if err := deleteMatching(tx.Bucket(bucket), prefix, func(k, v []byte) bool { return true }); err != nil {
+				return err
+			}
+		}
+		log.Debug("Deleted contract events, storage, token, proxy, backfill, native balance and failure data", "contract", address.String())
+
+		// delete template if specialised
+		if err := tx.Bucket(TemplateBucket).Delete([]byte(address.String())); err != nil {
+			return err
+		}
+		return tx.Bucket(ContractBucket).Delete(addressKey(address))
+	})
+}
+
+func (bdb *BoltDB) GetAddresses() ([]types.Address, error) {
+	addresses := make([]types.Address, 0)
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(ContractBucket).ForEach(func(k, v []byte) error {
+			addresses = append(addresses, types.Address(k))
+			return nil
+		})
+	})
+	return addresses, err
+}
+
+func (bdb *BoltDB) GetContractTemplate(address types.Address) (string, error) {
+	var contract *Contract
+	err := bdb.db.View(func(tx *bbolt.Tx) (err error) {
+		contract, err = getContract(tx, address)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return contract.TemplateName, nil
+}
+
+// TemplateDB
+func (bdb *BoltDB) GetContractABI(address types.Address) (string, error) {
+	template, err := bdb.getTemplateForContract(address)
+	if err != nil || template == nil {
+		return "", err
+	}
+	return template.ABI, nil
+}
+
+func (bdb *BoltDB) GetStorageLayout(address types.Address) (string, error) {
+	template, err := bdb.getTemplateForContract(address)
+	if err != nil || template == nil {
+		return "", err
+	}
+	return template.StorageABI, nil
+}
+
+func (bdb *BoltDB) AddTemplate(name string, abi string, layout string) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		return putJSON(tx.Bucket(TemplateBucket), []byte(name), Template{
+			TemplateName: name,
+			ABI:          abi,
+			StorageABI:   layout,
+		})
+	})
+}
+
+func (bdb *BoltDB) AssignTemplate(address types.Address, name string) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		contract, err := getContract(tx, address)
+		if err != nil {
+			return err
+		}
+		contract.TemplateName = name
+		return putJSON(tx.Bucket(ContractBucket), addressKey(address), contract)
+	})
+}
+
+func (bdb *BoltDB) GetTemplates() ([]string, error) {
+	templates := make([]string, 0)
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(TemplateBucket).ForEach(func(k, v []byte) error {
+			templates = append(templates, string(k))
+			return nil
+		})
+	})
+	return templates, err
+}
+
+func (bdb *BoltDB) GetTemplateDetails(templateName string) (*types.Template, error) {
+	var template Template
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return getJSON(tx.Bucket(TemplateBucket), []byte(templateName), &template)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &types.Template{
+		TemplateName:  templateName,
+		ABI:           template.ABI,
+		StorageLayout: template.StorageABI,
+	}, nil
+}
+
+// BlockDB
+func (bdb *BoltDB) WriteBlocks(blocks []*types.Block) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		blockBucket := tx.Bucket(BlockBucket)
+		meta := tx.Bucket(MetaBucket)
+		for _, block := range blocks {
+			if block == nil {
+				return errors.New("block is nil")
+			}
+			if err := putJSON(blockBucket, uint64Key(block.Number), block); err != nil {
+				return err
+			}
+
+			// update last persisted block number
+			lastPersisted := binary.BigEndian.Uint64(meta.Get(lastPersistedKey))
+			if block.Number == lastPersisted+1 {
+				blockNumber := block.Number
+				for blockBucket.Get(uint64Key(blockNumber+1)) != nil {
+					blockNumber++
+				}
+				if err := meta.Put(lastPersistedKey, uint64Key(blockNumber)); err != nil {
+					return err
+				}
+			}
+			log.Debug("Block stored", "number", block.Number, "hash", block.Hash.String())
+		}
+		return nil
+	})
+}
+
+func (bdb *BoltDB) ReadBlock(blockNumber uint64) (*types.Block, error) {
+	var block types.Block
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return getJSON(tx.Bucket(BlockBucket), uint64Key(blockNumber), &block)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+func (bdb *BoltDB) GetLastPersistedBlockNumber() (uint64, error) {
+	var lastPersisted uint64
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		lastPersisted = binary.BigEndian.Uint64(tx.Bucket(MetaBucket).Get(lastPersistedKey))
+		return nil
+	})
+	return lastPersisted, err
+}
+
+func (bdb *BoltDB) RollbackBlocks(reorg *types.ChainReorg) error {
+	ancestor := reorg.CommonAncestor
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		// find all orphaned blocks and their transactions
+		orphanedTxs := make(map[string]bool)
+		blockBucket := tx.Bucket(BlockBucket)
+		err := deleteMatching(blockBucket, nil, func(k, v []byte) bool {
+			if binary.BigEndian.Uint64(k) <= ancestor {
+				return false
+			}
+			var block types.Block
+			if err := json.Unmarshal(v, &block); err == nil {
+				for _, txHash := range block.Transactions {
+					orphanedTxs[string(txHash)] = true
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		for txHash := range orphanedTxs {
+			if err := tx.Bucket(TransactionBucket).Delete([]byte(txHash)); err != nil {
+				return err
+			}
+		}
+
+		meta := tx.Bucket(MetaBucket)
+		if binary.BigEndian.Uint64(meta.Get(lastPersistedKey)) > ancestor {
+			if err := meta.Put(lastPersistedKey, uint64Key(ancestor)); err != nil {
+				return err
+			}
+		}
+
+		// remove all index entries above the common ancestor
+		isOrphaned := func(k, v []byte) bool { return blockNumberOfKey(k) > ancestor }
+		for _, bucket := range [][]byte{TxToBucket, TxInternalToBucket, EventBucket, StorageBucket, TokenTransferBucket, ProxyBucket, NativeTransferBucket, NativeBalanceBucket, FailureBucket} {
+			if err := deleteMatching(tx.Bucket(bucket), nil, isOrphaned); err != nil {
+				return err
+			}
+		}
+
+		contractBucket := tx.Bucket(ContractBucket)
+		contracts := make(map[string]*Contract)
+		err = contractBucket.ForEach(func(k, v []byte) error {
+			var contract Contract
+			if err := json.Unmarshal(v, &contract); err != nil {
+				return err
+			}
+			if contract.LastFiltered > ancestor || orphanedTxs[contract.CreationTransaction] {
+				if contract.LastFiltered > ancestor {
+					contract.LastFiltered = ancestor
+				}
+				if orphanedTxs[contract.CreationTransaction] {
+					contract.CreationTransaction = ""
+				}
+				contracts[string(k)] = &contract
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for address, contract := range contracts {
+			if err := putJSON(contractBucket, []byte(address), contract); err != nil {
+				return err
+			}
+		}
+
+		if err := rollbackTokens(tx, nil, ancestor+1, math.MaxUint64); err != nil {
+			return err
+		}
+
+		reorgBucket := tx.Bucket(ReorgBucket)
+		seq, err := reorgBucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		log.Debug("Rolled back blocks", "common ancestor", ancestor, "orphaned txs", len(orphanedTxs))
+		return putJSON(reorgBucket, uint64Key(seq), reorg)
+	})
+}
+
+func (bdb *BoltDB) GetChainReorgs() ([]*types.ChainReorg, error) {
+	reorgs := make([]*types.ChainReorg, 0)
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(ReorgBucket).ForEach(func(k, v []byte) error {
+			var reorg types.ChainReorg
+			if err := json.Unmarshal(v, &reorg); err != nil {
+				return err
+			}
+			reorgs = append(reorgs, &reorg)
+			return nil
+		})
+	})
+	return reorgs, err
+}
+
+// TransactionDB
+func (bdb *BoltDB) WriteTransactions(transactions []*types.Transaction) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		for _, transaction := range transactions {
+			if transaction == nil {
+				return errors.New("transaction is nil")
+			}
+			if err := putJSON(tx.Bucket(TransactionBucket), []byte(transaction.Hash), transaction); err != nil {
+				return err
+			}
+
+			// index the recipients of the transaction and its internal calls
+			entry := TxIndexEntry{Hash: transaction.Hash, Timestamp: transaction.Timestamp, Value: transaction.Value}
+			position := compositeKey(uint64Key(transaction.BlockNumber), uint64Key(transaction.Index))
+			if transaction.To != "" {
+				if err := putJSON(tx.Bucket(TxToBucket), compositeKey(addressKey(transaction.To), position), entry); err != nil {
+					return err
+				}
+			}
+			for _, internalCall := range transaction.InternalCalls {
+				if err := putJSON(tx.Bucket(TxInternalToBucket), compositeKey(addressKey(internalCall.To), position), entry); err != nil {
+					return err
+				}
+			}
+			log.Debug("Transaction stored", "hash", transaction.Hash.Hex())
+		}
+		return nil
+	})
+}
+
+func (bdb *BoltDB) ReadTransaction(hash types.Hash) (*types.Transaction, error) {
+	var transaction types.Transaction
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return getJSON(tx.Bucket(TransactionBucket), []byte(hash), &transaction)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (bdb *BoltDB) Stop() {
+	if err := bdb.db.Close(); err != nil {
+		log.Error("Error closing database", "err", err)
+	}
+}
+
+// Internal functions
+
+func addContract(tx *bbolt.Tx, address types.Address, lastFiltered uint64) error {
+	contractBucket := tx.Bucket(ContractBucket)
+	// only create if the contract does not exist
+	if contractBucket.Get(addressKey(address)) != nil {
+		return nil
+	}
+	return putJSON(contractBucket, addressKey(address), Contract{
+		Address:      address,
+		TemplateName: address.String(),
+		LastFiltered: lastFiltered,
+	})
+}
+
+func getContract(tx *bbolt.Tx, address types.Address) (*Contract, error) {
+	var contract Contract
+	if err := getJSON(tx.Bucket(ContractBucket), addressKey(address), &contract); err != nil {
+		return nil, err
+	}
+	return &contract, nil
+}
+
+func (bdb *BoltDB) getTemplateForContract(address types.Address) (*Template, error) {
+	var template *Template
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		contract, err := getContract(tx, address)
+		if err != nil {
+			return err
+		}
+		template = &Template{}
+		return getJSON(tx.Bucket(TemplateBucket), []byte(contract.TemplateName), template)
+	})
+	if err == database.ErrNotFound {
+		return nil, nil
+	}
+	return template, err
+}
+
+func putJSON(bucket *bbolt.Bucket, key []byte, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, encoded)
+}
+
+func getJSON(bucket *bbolt.Bucket, key []byte, value interface{}) error {
+	encoded := bucket.Get(key)
+	if encoded == nil {
+		return database.ErrNotFound
+	}
+	return json.Unmarshal(encoded, value)
+}
+
+// deleteMatching removes all entries with the given key prefix that match the given filter.
+// Keys are collected first, since deleting while iterating a cursor skips entries.
+func deleteMatching(bucket *bbolt.Bucket, prefix []byte, matches func(k, v []byte) bool) error {
+	var toDelete [][]byte
+	err := forEachWithPrefix(bucket, prefix, func(k, v []byte) error {
+		if matches(k, v) {
+			toDelete = append(toDelete, append([]byte{}, k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range toDelete {
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func forEachWithPrefix(bucket *bbolt.Bucket, prefix []byte, fn func(k, v []byte) error) error {
+	c := bucket.Cursor()
+	k, v := c.First()
+	if len(prefix) > 0 {
+		k, v = c.Seek(prefix)
+	}
+	for ; k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package bolt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/database/conformance"
+	"quorumengineering/quorum-report/types"
+)
+
+var (
+	addr = types.NewAddress("0x0000000000000000000000000000000000000001")
+
+	tx = &types.Transaction{
+		Hash:            types.NewHash("0x5c83fa5955aff33c61813105851777bcd2adc85deb9af6286ba42c05cd768de0"),
+		BlockNumber:     1,
+		BlockHash:       types.NewHash("0x4b603921305ebaa48d863b9f577059a63c653cd8e952372622923708fb657806"),
+		Index:           0,
+		From:            types.NewAddress("0x0000000000000000000000000000000000000010"),
+		To:              addr,
+		CreatedContract: types.NewAddress(""),
+		Timestamp:       100,
+	}
+	block = &types.Block{
+		Hash:         types.NewHash("0x4b603921305ebaa48d863b9f577059a63c653cd8e952372622923708fb657806"),
+		ParentHash:   types.NewHash(""),
+		StateRoot:    types.NewHash(""),
+		TxRoot:       types.NewHash(""),
+		ReceiptRoot:  types.NewHash(""),
+		Number:       1,
+		Timestamp:    100,
+		Transactions: []types.Hash{tx.Hash},
+	}
+)
+
+func newTestDB(t *testing.T) (*BoltDB, func()) {
+	dir, err := ioutil.TempDir("", "boltdb")
+	assert.Nil(t, err)
+	db, err := New(filepath.Join(dir, "test.db"))
+	assert.Nil(t, err)
+	return db, func() {
+		db.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestBoltDB_Conformance(t *testing.T) {
+	conformance.RunTests(t, func(t *testing.T) (database.Database, func()) {
+		return newTestDB(t)
+	})
+}
+
+func TestBoltDB_Persistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boltdb")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.db")
+
+	db, err := New(path)
+	assert.Nil(t, err)
+	assert.Nil(t, db.AddAddresses([]types.Address{addr}))
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx}))
+	assert.Nil(t, db.WriteBlocks([]*types.Block{block}))
+	db.Stop()
+
+	db, err = New(path)
+	assert.Nil(t, err)
+	defer db.Stop()
+
+	lastPersisted, err := db.GetLastPersistedBlockNumber()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, lastPersisted)
+	addresses, err := db.GetAddresses()
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{addr}, addresses)
+	retrievedBlock, err := db.ReadBlock(1)
+	assert.Nil(t, err)
+	assert.Equal(t, block, retrievedBlock)
+	retrievedTx, err := db.ReadTransaction(tx.Hash)
+	assert.Nil(t, err)
+	assert.Equal(t, tx, retrievedTx)
+}
@@ -0,0 +1,53 @@
+package bolt
+
+import (
+	"encoding/json"
+
+	bbolt "go.etcd.io/bbolt"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// FailureDB
+func (bdb *BoltDB) RecordTransactionFailures(failures []types.TransactionFailure) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		failureBucket := tx.Bucket(FailureBucket)
+		for _, failure := range failures {
+			key := compositeKey(addressKey(failure.Address), uint64Key(failure.BlockNumber), uint64Key(failure.Index))
+			if err := putJSON(failureBucket, key, failure); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (bdb *BoltDB) GetTransactionFailures(address types.Address, reason string, options *types.PageOptions) ([]types.TransactionFailure, error) {
+	var found []types.TransactionFailure
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return forEachWithPrefix(tx.Bucket(FailureBucket), addressKey(address), func(k, v []byte) error {
+			if !inRange(blockNumberOfKey(k), options.BeginBlockNumber, options.EndBlockNumber) {
+				return nil
+			}
+			var failure types.TransactionFailure
+			if err := json.Unmarshal(v, &failure); err != nil {
+				return err
+			}
+			if reason == "" || failure.Reason == reason {
+				found = append(found, failure)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// failures are keyed by block number and index, so the most recent are last
+	results := make([]types.TransactionFailure, 0, len(found))
+	for i := len(found) - 1; i >= 0; i-- {
+		results = append(results, found[i])
+	}
+	start, end := pageBounds(len(results), options.PageSize, options.PageNumber)
+	return results[start:end], nil
+}
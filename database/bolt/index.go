@@ -0,0 +1,393 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"sort"
+
+	bbolt "go.etcd.io/bbolt"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// IndexDB
+func (bdb *BoltDB) IndexBlocks(addresses []types.Address, blocks []*types.BlockWithTransactions) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		contractBucket := tx.Bucket(ContractBucket)
+		eventBucket := tx.Bucket(EventBucket)
+		for _, address := range addresses {
+			contract, err := getContract(tx, address)
+			if err == database.ErrNotFound {
+				// tried to index a deleted address, do nothing
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			for _, block := range blocks {
+				// skip blocks that have been filtered for this address already
+				if block.Number <= contract.LastFiltered {
+					continue
+				}
+				for _, transaction := range block.Transactions {
+					for _, event := range transaction.Events {
+						if event.Address != address {
+							continue
+						}
+						key := compositeKey(addressKey(address), uint64Key(event.BlockNumber), uint64Key(event.TransactionIndex), uint64Key(event.Index))
+						if err := putJSON(eventBucket, key, event); err != nil {
+							return err
+						}
+						log.Debug("Indexed emitted event", "tx", event.TransactionHash.Hex(), "address", event.Address.Hex())
+					}
+				}
+				contract.LastFiltered = block.Number
+			}
+			if err := putJSON(contractBucket, addressKey(address), contract); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (bdb *BoltDB) IndexStorage(rawStorage map[types.Address]*types.AccountState, blockNumber uint64) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		storageBucket := tx.Bucket(StorageBucket)
+		storageRootBucket := tx.Bucket(StorageRootBucket)
+		for address, dumpAccount := range rawStorage {
+			root := []byte(dumpAccount.Root)
+			if err := storageBucket.Put(compositeKey(addressKey(address), uint64Key(blockNumber)), root); err != nil {
+				return err
+			}
+			// storage is shared between all blocks with the same storage root
+			if storageRootBucket.Get(root) == nil {
+				if err := putJSON(storageRootBucket, root, dumpAccount.Storage); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (bdb *BoltDB) SetContractCreationTransaction(creationTxns map[types.Hash][]types.Address) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		for txHash, addresses := range creationTxns {
+			for _, createdAddress := range addresses {
+				contract, err := getContract(tx, createdAddress)
+				if err == database.ErrNotFound {
+					// tried to index a deleted address, do nothing
+					log.Debug("Ignored deleted address contract creation", "tx", txHash.Hex(), "contract", createdAddress)
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				contract.CreationTransaction = string(txHash)
+				if err := putJSON(tx.Bucket(ContractBucket), addressKey(createdAddress), contract); err != nil {
+					return err
+				}
+				log.Debug("Indexed address of contract creation", "tx", txHash.Hex(), "contract", createdAddress)
+			}
+		}
+		return nil
+	})
+}
+
+func (bdb *BoltDB) GetContractCreationTransaction(address types.Address) (types.Hash, error) {
+	var contract *Contract
+	err := bdb.db.View(func(tx *bbolt.Tx) (err error) {
+		contract, err = getContract(tx, address)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return types.Hash(contract.CreationTransaction), nil
+}
+
+func (bdb *BoltDB) GetAllTransactionsToAddress(address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
+	hashes, err := bdb.getTransactionsFromIndex(TxToBucket, address, options)
+	if err != nil {
+		return nil, err
+	}
+	return paginateHashes(hashes, options.PageSize, options.PageNumber), nil
+}
+
+func (bdb *BoltDB) GetTransactionsToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+	hashes, err := bdb.getTransactionsFromIndex(TxToBucket, address, options)
+	return uint64(len(hashes)), err
+}
+
+func (bdb *BoltDB) GetAllTransactionsInternalToAddress(address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
+	hashes, err := bdb.getTransactionsFromIndex(TxInternalToBucket, address, options)
+	if err != nil {
+		return nil, err
+	}
+	return paginateHashes(hashes, options.PageSize, options.PageNumber), nil
+}
+
+func (bdb *BoltDB) GetTransactionsInternalToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+	hashes, err := bdb.getTransactionsFromIndex(TxInternalToBucket, address, options)
+	return uint64(len(hashes)), err
+}
+
+func (bdb *BoltDB) GetAllEventsFromAddress(address types.Address, options *types.QueryOptions) ([]*types.Event, error) {
+	events, err := bdb.getEvents(address, options)
+	if err != nil {
+		return nil, err
+	}
+	start, end := pageBounds(len(events), options.PageSize, options.PageNumber)
+	return events[start:end], nil
+}
+
+func (bdb *BoltDB) GetEventsFromAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+	events, err := bdb.getEvents(address, options)
+	return uint64(len(events)), err
+}
+
+func (bdb *BoltDB) GetStorage(address types.Address, blockNumber uint64) (*types.StorageResult, error) {
+	result := &types.StorageResult{
+		Storage:     make(map[types.Hash]string),
+		StorageRoot: types.NewHash(""),
+		BlockNumber: blockNumber,
+	}
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		// find the latest storage at or before the given block
+		prefix := addressKey(address)
+		c := tx.Bucket(StorageBucket).Cursor()
+		k, v := c.Seek(compositeKey(prefix, uint64Key(blockNumber+1)))
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		// there are no results, probably trying to query before the contract exists
+		if k == nil || !bytes.HasPrefix(k, prefix) {
+			return nil
+		}
+
+		result.StorageRoot = types.Hash(v)
+		return getJSON(tx.Bucket(StorageRootBucket), v, &result.Storage)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (bdb *BoltDB) GetStorageTotal(address types.Address, options *types.PageOptions) (uint64, error) {
+	results, err := bdb.getStorageWithOptions(address, options, false)
+	return uint64(len(results)), err
+}
+
+func (bdb *BoltDB) GetStorageWithOptions(address types.Address, options *types.PageOptions) ([]*types.StorageResult, error) {
+	results, err := bdb.getStorageWithOptions(address, options, true)
+	if err != nil {
+		return nil, err
+	}
+	start, end := pageBounds(len(results), options.PageSize, options.PageNumber)
+	return results[start:end], nil
+}
+
+func (bdb *BoltDB) GetStorageRanges(contract types.Address, options *types.PageOptions) ([]types.RangeResult, error) {
+	end := options.EndBlockNumber
+	if big.NewInt(-1).Cmp(end) == 0 {
+		endUint64, err := bdb.GetLastFiltered(contract)
+		if err != nil {
+			return nil, err
+		}
+		end = new(big.Int).SetUint64(endUint64)
+	}
+
+	startUint64 := options.BeginBlockNumber.Uint64()
+	endUint64 := end.Uint64()
+
+	results, err := bdb.getStorageWithOptions(contract, &types.PageOptions{
+		BeginBlockNumber: options.BeginBlockNumber,
+		EndBlockNumber:   end,
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// split the results into ranges of at most 1000 results, starting from the most recent block
+	var ranges []types.RangeResult
+	for len(results) > 1000 {
+		foundEndBlockNumber := results[999].BlockNumber
+		ranges = append(ranges, types.RangeResult{
+			Start:       foundEndBlockNumber,
+			End:         endUint64,
+			ResultCount: 1000,
+		})
+		endUint64 = foundEndBlockNumber - 1
+		results = results[1000:]
+	}
+	ranges = append(ranges, types.RangeResult{
+		Start:       startUint64,
+		End:         endUint64,
+		ResultCount: len(results),
+	})
+	return ranges, nil
+}
+
+func (bdb *BoltDB) GetLastFiltered(address types.Address) (uint64, error) {
+	var contract *Contract
+	err := bdb.db.View(func(tx *bbolt.Tx) (err error) {
+		contract, err = getContract(tx, address)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return contract.LastFiltered, nil
+}
+
+// Internal functions
+
+// getTransactionsFromIndex returns all transactions in the given index for the address,
+// sorted by block number descending and transaction index ascending
+func (bdb *BoltDB) getTransactionsFromIndex(index []byte, address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
+	type indexed struct {
+		blockNumber uint64
+		entry       TxIndexEntry
+	}
+	var results []indexed
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return forEachWithPrefix(tx.Bucket(index), addressKey(address), func(k, v []byte) error {
+			blockNumber := blockNumberOfKey(k)
+			if !inRange(blockNumber, options.BeginBlockNumber, options.EndBlockNumber) {
+				return nil
+			}
+			var entry TxIndexEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			if !inRange(entry.Timestamp, options.BeginTimestamp, options.EndTimestamp) {
+				return nil
+			}
+			if options.HasValueRange() {
+				if entry.Value == nil {
+					var transaction types.Transaction
+					if err := getJSON(tx.Bucket(TransactionBucket), []byte(entry.Hash), &transaction); err != nil {
+						return err
+					}
+					entry.Value = transaction.Value
+				}
+				if !options.ValueInRange(entry.Value) {
+					return nil
+				}
+			}
+			results = append(results, indexed{blockNumber, entry})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// keys are ordered by block number and transaction index ascending
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].blockNumber > results[j].blockNumber
+	})
+	hashes := make([]types.Hash, len(results))
+	for i, result := range results {
+		hashes[i] = result.entry.Hash
+	}
+	return hashes, nil
+}
+
+// getEvents returns all events emitted by the address, sorted by block number descending
+func (bdb *BoltDB) getEvents(address types.Address, options *types.QueryOptions) ([]*types.Event, error) {
+	events := make([]*types.Event, 0)
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return forEachWithPrefix(tx.Bucket(EventBucket), addressKey(address), func(k, v []byte) error {
+			if !inRange(blockNumberOfKey(k), options.BeginBlockNumber, options.EndBlockNumber) {
+				return nil
+			}
+			var event types.Event
+			if err := json.Unmarshal(v, &event); err != nil {
+				return err
+			}
+			if inRange(event.Timestamp, options.BeginTimestamp, options.EndTimestamp) {
+				events = append(events, &event)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].BlockNumber > events[j].BlockNumber
+	})
+	return events, nil
+}
+
+// getStorageWithOptions returns all storage of the address in the block range, sorted by
+// block number descending. The storage itself is only fetched if requested.
+func (bdb *BoltDB) getStorageWithOptions(address types.Address, options *types.PageOptions, withStorage bool) ([]*types.StorageResult, error) {
+	var results []*types.StorageResult
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		storageRootBucket := tx.Bucket(StorageRootBucket)
+		return forEachWithPrefix(tx.Bucket(StorageBucket), addressKey(address), func(k, v []byte) error {
+			blockNumber := blockNumberOfKey(k)
+			if !inRange(blockNumber, options.BeginBlockNumber, options.EndBlockNumber) {
+				return nil
+			}
+			result := &types.StorageResult{
+				StorageRoot: types.Hash(v),
+				BlockNumber: blockNumber,
+			}
+			if withStorage {
+				if err := getJSON(storageRootBucket, v, &result.Storage); err != nil {
+					return err
+				}
+			}
+			results = append(results, result)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+		results[i], results[j] = results[j], results[i]
+	}
+	return results, nil
+}
+
+// inRange checks if the value is within the given inclusive range, where an end of -1 is unbounded
+func inRange(value uint64, begin *big.Int, end *big.Int) bool {
+	if begin != nil && value < begin.Uint64() {
+		return false
+	}
+	if end != nil && end.Cmp(big.NewInt(-1)) != 0 && value > end.Uint64() {
+		return false
+	}
+	return true
+}
+
+func pageBounds(total int, pageSize int, pageNumber int) (int, int) {
+	start := pageSize * pageNumber
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+func paginateHashes(hashes []types.Hash, pageSize int, pageNumber int) []types.Hash {
+	start, end := pageBounds(len(hashes), pageSize, pageNumber)
+	return hashes[start:end]
+}
@@ -0,0 +1,150 @@
+package bolt
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sort"
+
+	bbolt "go.etcd.io/bbolt"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// NativeDB
+func (bdb *BoltDB) RecordNativeTransfers(transfers []types.NativeTransfer) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		transferBucket := tx.Bucket(NativeTransferBucket)
+		for _, transfer := range transfers {
+			// each transfer is stored under both the sender and recipient, which share a key
+			// if they are the same account
+			for _, address := range []types.Address{transfer.From, transfer.To} {
+				key := compositeKey(addressKey(address), uint64Key(transfer.BlockNumber), uint64Key(transfer.Index))
+				if err := putJSON(transferBucket, key, transfer); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (bdb *BoltDB) GetNativeTransfers(address types.Address, direction string, options *types.TokenTransferQueryOptions) ([]types.NativeTransfer, error) {
+	afterBlock, afterIndex, hasCursor, err := options.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	transfers := make([]types.NativeTransfer, 0)
+	err = bdb.db.View(func(tx *bbolt.Tx) error {
+		// transfers are keyed by block number and index, so are already in order
+		return forEachWithPrefix(tx.Bucket(NativeTransferBucket), addressKey(address), func(k, v []byte) error {
+			if len(transfers) >= options.PageSize || !inRange(blockNumberOfKey(k), options.BeginBlockNumber, options.EndBlockNumber) {
+				return nil
+			}
+			var transfer types.NativeTransfer
+			if err := json.Unmarshal(v, &transfer); err != nil {
+				return err
+			}
+			isIn := transfer.To == address && direction != types.TransferDirectionOut
+			isOut := transfer.From == address && direction != types.TransferDirectionIn
+			if (!isIn && !isOut) || !inRange(transfer.Timestamp, options.BeginTimestamp, options.EndTimestamp) {
+				return nil
+			}
+			if hasCursor && (transfer.BlockNumber < afterBlock || (transfer.BlockNumber == afterBlock && transfer.Index <= afterIndex)) {
+				return nil
+			}
+			transfers = append(transfers, transfer)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
+func (bdb *BoltDB) RecordNativeBalance(address types.Address, block uint64, balance *big.Int) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		record := types.NativeBalance{
+			Address:     address,
+			Balance:     balance.String(),
+			BlockNumber: block,
+		}
+		return putJSON(tx.Bucket(NativeBalanceBucket), compositeKey(addressKey(address), uint64Key(block)), record)
+	})
+}
+
+func (bdb *BoltDB) NativeBalanceAtBlock(address types.Address, block uint64) (*big.Int, error) {
+	var record types.NativeBalance
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		_, entry, err := getLatestEntry(tx.Bucket(NativeBalanceBucket), addressKey(address), block)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(entry, &record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	balance, success := new(big.Int).SetString(record.Balance, 10)
+	if !success {
+		return nil, errors.New("could not parse native balance")
+	}
+	return balance, nil
+}
+
+func (bdb *BoltDB) GetNativeBalance(address types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	beginBlock := options.BeginBlockNumber.Uint64()
+
+	// get all the balances in the block range, as well as the last balance before the
+	// starting block if there was no balance recorded on the starting block
+	var balances []types.NativeBalance
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		balanceBucket := tx.Bucket(NativeBalanceBucket)
+		prefix := addressKey(address)
+		if beginBlock > 0 {
+			_, entry, err := getLatestEntry(balanceBucket, prefix, beginBlock-1)
+			if err == nil && balanceBucket.Get(compositeKey(prefix, uint64Key(beginBlock))) == nil {
+				var record types.NativeBalance
+				if err := json.Unmarshal(entry, &record); err != nil {
+					return err
+				}
+				balances = append(balances, record)
+			}
+		}
+		return forEachWithPrefix(balanceBucket, prefix, func(k, v []byte) error {
+			if !inRange(blockNumberOfKey(k), options.BeginBlockNumber, options.EndBlockNumber) {
+				return nil
+			}
+			var record types.NativeBalance
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			balances = append(balances, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(balances, func(i, j int) bool {
+		return balances[i].BlockNumber > balances[j].BlockNumber
+	})
+	start, end := pageBounds(len(balances), options.PageSize, options.PageNumber)
+
+	balanceMap := make(map[uint64]*big.Int)
+	for _, record := range balances[start:end] {
+		balance, success := new(big.Int).SetString(record.Balance, 10)
+		if !success {
+			return nil, errors.New("could not parse native balance")
+		}
+		if record.BlockNumber < beginBlock {
+			balanceMap[beginBlock] = balance
+		} else {
+			balanceMap[record.BlockNumber] = balance
+		}
+	}
+	return balanceMap, nil
+}
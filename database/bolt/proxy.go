@@ -0,0 +1,32 @@
+package bolt
+
+import (
+	"encoding/json"
+
+	bbolt "go.etcd.io/bbolt"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// ProxyDB
+func (bdb *BoltDB) RecordProxyImplementation(proxy types.Address, implementation *types.ProxyImplementation) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		key := compositeKey(addressKey(proxy), uint64Key(implementation.BlockNumber))
+		return putJSON(tx.Bucket(ProxyBucket), key, implementation)
+	})
+}
+
+func (bdb *BoltDB) GetProxyImplementations(proxy types.Address) ([]*types.ProxyImplementation, error) {
+	implementations := make([]*types.ProxyImplementation, 0)
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return forEachWithPrefix(tx.Bucket(ProxyBucket), addressKey(proxy), func(k, v []byte) error {
+			var implementation types.ProxyImplementation
+			if err := json.Unmarshal(v, &implementation); err != nil {
+				return err
+			}
+			implementations = append(implementations, &implementation)
+			return nil
+		})
+	})
+	return implementations, err
+}
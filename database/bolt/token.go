@@ -0,0 +1,1078 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sort"
+
+	bbolt "go.etcd.io/bbolt"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/types"
+)
+
+// Token DB
+func (bdb *BoltDB) RecordNewERC20Balance(contract types.Address, holder types.Address, block uint64, amount *big.Int) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		erc20Bucket := tx.Bucket(ERC20TokenBucket)
+		prefix := compositeKey(addressKey(contract), addressKey(holder))
+
+		// find old entry
+		existingKey, existingEntry, err := getLatestEntry(erc20Bucket, prefix, block-1)
+		if err != nil && err != database.ErrNotFound {
+			return err
+		}
+
+		// add new entry
+		tokenInfo := ERC20TokenHolder{
+			Contract:    contract,
+			Holder:      holder,
+			BlockNumber: block,
+			Amount:      amount.String(),
+		}
+		if err := putJSON(erc20Bucket, compositeKey(prefix, uint64Key(block)), tokenInfo); err != nil {
+			return err
+		}
+
+		if existingKey == nil {
+			return nil
+		}
+
+		// update the older entry
+		var existing ERC20TokenHolder
+		if err := json.Unmarshal(existingEntry, &existing); err != nil {
+			return err
+		}
+		heldUntil := block - 1
+		existing.HeldUntil = &heldUntil
+		return putJSON(erc20Bucket, existingKey, existing)
+	})
+}
+
+func (bdb *BoltDB) GetERC20Balance(contract types.Address, holder types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	beginBlock := options.BeginBlockNumber.Uint64()
+
+	// get all the balances in the block range, as well as the last balance before the
+	// starting block if there was no balance update on the starting block
+	var balances []ERC20TokenHolder
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		prefix := compositeKey(addressKey(contract), addressKey(holder))
+		return forEachWithPrefix(tx.Bucket(ERC20TokenBucket), prefix, func(k, v []byte) error {
+			var balance ERC20TokenHolder
+			if err := json.Unmarshal(v, &balance); err != nil {
+				return err
+			}
+			heldAtBegin := balance.BlockNumber < beginBlock && (balance.HeldUntil == nil || *balance.HeldUntil >= beginBlock)
+			if heldAtBegin || inRange(balance.BlockNumber, options.BeginBlockNumber, options.EndBlockNumber) {
+				balances = append(balances, balance)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(balances, func(i, j int) bool {
+		return balances[i].BlockNumber > balances[j].BlockNumber
+	})
+	start, end := pageBounds(len(balances), options.PageSize, options.PageNumber)
+
+	balanceMap := make(map[uint64]*big.Int)
+	for _, balance := range balances[start:end] {
+		tokenAmount, success := new(big.Int).SetString(balance.Amount, 10)
+		if !success {
+			return nil, errors.New("could not parse token value")
+		}
+		if balance.BlockNumber < beginBlock {
+			balanceMap[beginBlock] = tokenAmount
+		} else {
+			balanceMap[balance.BlockNumber] = tokenAmount
+		}
+	}
+	return balanceMap, nil
+}
+
+func (bdb *BoltDB) GetAllTokenHolders(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	holders := make(map[types.Address]bool)
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return forEachWithPrefix(tx.Bucket(ERC20TokenBucket), addressKey(contract), func(k, v []byte) error {
+			var balance ERC20TokenHolder
+			if err := json.Unmarshal(v, &balance); err != nil {
+				return err
+			}
+			if balance.BlockNumber <= block && (balance.HeldUntil == nil || *balance.HeldUntil >= block) {
+				holders[balance.Holder] = true
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	delete(holders, types.NewAddress(""))
+	return pageHolders(holders, options), nil
+}
+
+func (bdb *BoltDB) ERC20HolderBalancesAtBlock(contract types.Address, block uint64) (map[types.Address]*big.Int, error) {
+	balances := make(map[types.Address]*big.Int)
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return forEachWithPrefix(tx.Bucket(ERC20TokenBucket), addressKey(contract), func(k, v []byte) error {
+			var balance ERC20TokenHolder
+			if err := json.Unmarshal(v, &balance); err != nil {
+				return err
+			}
+			if balance.Holder.IsEmpty() || balance.BlockNumber > block || (balance.HeldUntil != nil && *balance.HeldUntil < block) {
+				return nil
+			}
+			amount, success := new(big.Int).SetString(balance.Amount, 10)
+			if !success {
+				return errors.New("could not parse token value")
+			}
+			if amount.Sign() != 0 {
+				balances[balance.Holder] = amount
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+func (bdb *BoltDB) ERC20HolderCountAtBlock(contract types.Address, block uint64) (uint64, error) {
+	var count uint64
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return forEachWithPrefix(tx.Bucket(ERC20TokenBucket), addressKey(contract), func(k, v []byte) error {
+			var balance ERC20TokenHolder
+			if err := json.Unmarshal(v, &balance); err != nil {
+				return err
+			}
+			if !balance.Holder.IsEmpty() && balance.BlockNumber <= block && (balance.HeldUntil == nil || *balance.HeldUntil >= block) && balance.Amount != "0" {
+				count++
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (bdb *BoltDB) AllERC20BalancesForAccountAtBlock(holder types.Address, block uint64) (map[types.Address]*big.Int, error) {
+	balances := make(map[types.Address]*big.Int)
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(ERC20TokenBucket).ForEach(func(k, v []byte) error {
+			var balance ERC20TokenHolder
+			if err := json.Unmarshal(v, &balance); err != nil {
+				return err
+			}
+			if balance.Holder != holder || balance.BlockNumber > block || (balance.HeldUntil != nil && *balance.HeldUntil < block) {
+				return nil
+			}
+			amount, success := new(big.Int).SetString(balance.Amount, 10)
+			if !success {
+				return errors.New("could not parse token value")
+			}
+			if amount.Sign() != 0 {
+				balances[balance.Contract] = amount
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+func (bdb *BoltDB) ERC20BalanceChanges(contract types.Address, fromBlock uint64, toBlock uint64) ([]types.ERC20BalanceChange, error) {
+	changes := make([]types.ERC20BalanceChange, 0)
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return forEachWithPrefix(tx.Bucket(ERC20TokenBucket), addressKey(contract), func(k, v []byte) error {
+			var balance ERC20TokenHolder
+			if err := json.Unmarshal(v, &balance); err != nil {
+				return err
+			}
+			if !balance.Holder.IsEmpty() && balance.BlockNumber >= fromBlock && balance.BlockNumber <= toBlock {
+				changes = append(changes, types.ERC20BalanceChange{Holder: balance.Holder, BlockNumber: balance.BlockNumber, Amount: balance.Amount})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the bucket is ordered by holder and then block
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].BlockNumber < changes[j].BlockNumber
+	})
+	return changes, nil
+}
+
+func (bdb *BoltDB) RecordERC20Allowance(contract types.Address, owner types.Address, spender types.Address, block uint64, amount *big.Int) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		allowanceBucket := tx.Bucket(ERC20AllowanceBucket)
+		prefix := compositeKey(addressKey(contract), addressKey(owner), addressKey(spender))
+
+		// find old entry
+		existingKey, existingEntry, err := getLatestEntry(allowanceBucket, prefix, block-1)
+		if err != nil && err != database.ErrNotFound {
+			return err
+		}
+
+		// add new entry
+		allowance := types.ERC20Allowance{
+			Contract:     contract,
+			Owner:        owner,
+			Spender:      spender,
+			Amount:       amount.String(),
+			ApprovedFrom: block,
+		}
+		if err := putJSON(allowanceBucket, compositeKey(prefix, uint64Key(block)), allowance); err != nil {
+			return err
+		}
+
+		if existingKey == nil {
+			return nil
+		}
+
+		// update the older entry
+		var existing types.ERC20Allowance
+		if err := json.Unmarshal(existingEntry, &existing); err != nil {
+			return err
+		}
+		approvedUntil := block - 1
+		existing.ApprovedUntil = &approvedUntil
+		return putJSON(allowanceBucket, existingKey, existing)
+	})
+}
+
+func (bdb *BoltDB) ERC20AllowanceAtBlock(contract types.Address, owner types.Address, spender types.Address, block uint64) (*big.Int, error) {
+	var allowance types.ERC20Allowance
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		prefix := compositeKey(addressKey(contract), addressKey(owner), addressKey(spender))
+		_, entry, err := getLatestEntry(tx.Bucket(ERC20AllowanceBucket), prefix, block)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(entry, &allowance)
+	})
+	if err == database.ErrNotFound {
+		return big.NewInt(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	amount, success := new(big.Int).SetString(allowance.Amount, 10)
+	if !success {
+		return nil, errors.New("could not parse allowance value")
+	}
+	return amount, nil
+}
+
+func (bdb *BoltDB) ERC20ApprovalsForOwnerAtBlock(contract types.Address, owner types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC20Allowance, error) {
+	after := ""
+	if options.After != "" {
+		after = string(types.NewAddress(options.After))
+	}
+
+	// entries are keyed by spender, so are already in order
+	allowances := make([]types.ERC20Allowance, 0)
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return forEachWithPrefix(tx.Bucket(ERC20AllowanceBucket), compositeKey(addressKey(contract), addressKey(owner)), func(k, v []byte) error {
+			if len(allowances) == options.PageSize {
+				return nil
+			}
+			var allowance types.ERC20Allowance
+			if err := json.Unmarshal(v, &allowance); err != nil {
+				return err
+			}
+			if string(allowance.Spender) <= after || allowance.Amount == "0" || allowance.ApprovedFrom > block {
+				return nil
+			}
+			if allowance.ApprovedUntil == nil || *allowance.ApprovedUntil >= block {
+				allowances = append(allowances, allowance)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allowances, nil
+}
+
+func (bdb *BoltDB) RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		erc721Bucket := tx.Bucket(ERC721TokenBucket)
+		prefix := compositeKey(addressKey(contract), tokenIdKey(tokenId))
+
+		// find old entry
+		existingKey, existingEntry, err := getLatestEntry(erc721Bucket, prefix, block-1)
+		if err != nil && err != database.ErrNotFound {
+			return err
+		}
+
+		// add new entry
+		tokenHolderInfo := types.ERC721Token{
+			Contract:  contract,
+			Holder:    holder,
+			Token:     tokenId.String(),
+			HeldFrom:  block,
+			HeldUntil: nil,
+		}
+		if err := putJSON(erc721Bucket, compositeKey(prefix, uint64Key(block)), tokenHolderInfo); err != nil {
+			return err
+		}
+
+		if existingKey == nil {
+			return nil
+		}
+
+		// update the older entry
+		var existing types.ERC721Token
+		if err := json.Unmarshal(existingEntry, &existing); err != nil {
+			return err
+		}
+		heldUntil := block - 1
+		existing.HeldUntil = &heldUntil
+		return putJSON(erc721Bucket, existingKey, existing)
+	})
+}
+
+func (bdb *BoltDB) ERC721TokenByTokenID(contract types.Address, block uint64, tokenId *big.Int) (*types.ERC721Token, error) {
+	var token types.ERC721Token
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		prefix := compositeKey(addressKey(contract), tokenIdKey(tokenId))
+		_, entry, err := getLatestEntry(tx.Bucket(ERC721TokenBucket), prefix, block)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(entry, &token)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (bdb *BoltDB) ERC721TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
+	return bdb.erc721TokensAtBlock(contract, &holder, block, options)
+}
+
+func (bdb *BoltDB) AllERC721TokensAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
+	return bdb.erc721TokensAtBlock(contract, nil, block, options)
+}
+
+func (bdb *BoltDB) AllHoldersAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	tokens, err := bdb.getERC721TokensAtBlock(contract, nil, block, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	holders := make(map[types.Address]bool)
+	for _, token := range tokens {
+		holders[token.Holder] = true
+	}
+	return pageHolders(holders, options), nil
+}
+
+// AllERC721TokensForAccountAtBlock scans the ERC721 tokens after the cursor, as the bucket is
+// keyed by contract and token ID, which already orders the result
+func (bdb *BoltDB) AllERC721TokensForAccountAtBlock(holder types.Address, block uint64, options *types.AccountTokenQueryOptions) ([]types.ERC721Token, error) {
+	afterContract, afterId, hasCursor, err := options.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]types.ERC721Token, 0)
+	err = bdb.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(ERC721TokenBucket).Cursor()
+		k, v := c.First()
+		if hasCursor {
+			// skip every record of the token the page starts after
+			after := compositeKey(addressKey(afterContract), tokenIdKey(afterId))
+			for k, v = c.Seek(after); k != nil && bytes.HasPrefix(k, after); k, v = c.Next() {
+			}
+		}
+		for ; k != nil && len(tokens) < options.PageSize; k, v = c.Next() {
+			var token types.ERC721Token
+			if err := json.Unmarshal(v, &token); err != nil {
+				return err
+			}
+			if token.Holder == holder && token.HeldFrom <= block && (token.HeldUntil == nil || *token.HeldUntil >= block) {
+				tokens = append(tokens, token)
+			}
+		}
+		return nil
+	})
+	return tokens, err
+}
+
+func (bdb *BoltDB) RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		erc1155Bucket := tx.Bucket(ERC1155TokenBucket)
+		prefix := compositeKey(addressKey(contract), addressKey(holder), tokenIdKey(tokenId))
+
+		// find old entry
+		existingKey, existingEntry, err := getLatestEntry(erc1155Bucket, prefix, block-1)
+		if err != nil && err != database.ErrNotFound {
+			return err
+		}
+
+		// add new entry
+		tokenInfo := types.ERC1155Token{
+			Contract: contract,
+			Holder:   holder,
+			Token:    tokenId.String(),
+			Amount:   amount.String(),
+			HeldFrom: block,
+		}
+		if err := putJSON(erc1155Bucket, compositeKey(prefix, uint64Key(block)), tokenInfo); err != nil {
+			return err
+		}
+
+		if existingKey == nil {
+			return nil
+		}
+
+		// update the older entry
+		var existing types.ERC1155Token
+		if err := json.Unmarshal(existingEntry, &existing); err != nil {
+			return err
+		}
+		heldUntil := block - 1
+		existing.HeldUntil = &heldUntil
+		return putJSON(erc1155Bucket, existingKey, existing)
+	})
+}
+
+func (bdb *BoltDB) ERC1155BalanceAtBlock(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) (*big.Int, error) {
+	var token types.ERC1155Token
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		prefix := compositeKey(addressKey(contract), addressKey(holder), tokenIdKey(tokenId))
+		_, entry, err := getLatestEntry(tx.Bucket(ERC1155TokenBucket), prefix, block)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(entry, &token)
+	})
+	if err == database.ErrNotFound {
+		return big.NewInt(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	amount, success := new(big.Int).SetString(token.Amount, 10)
+	if !success {
+		return nil, errors.New("could not parse token value")
+	}
+	return amount, nil
+}
+
+func (bdb *BoltDB) ERC1155TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC1155Token, error) {
+	startTokenId := big.NewInt(-1)
+	if options.After != "" {
+		parsed, success := new(big.Int).SetString(options.After, 10)
+		if !success {
+			return nil, errors.New(`could not parse "after" token ID`)
+		}
+		startTokenId = parsed
+	}
+
+	tokens := make([]types.ERC1155Token, 0)
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		prefix := compositeKey(addressKey(contract), addressKey(holder))
+		c := tx.Bucket(ERC1155TokenBucket).Cursor()
+		k, v := c.Seek(compositeKey(prefix, tokenIdKey(new(big.Int).Add(startTokenId, big.NewInt(1)))))
+		for ; k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var token types.ERC1155Token
+			if err := json.Unmarshal(v, &token); err != nil {
+				return err
+			}
+			if heldAtBlock(token, block) {
+				tokens = append(tokens, token)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	start, end := pageBounds(len(tokens), options.PageSize, options.PageNumber)
+	return tokens[start:end], nil
+}
+
+func (bdb *BoltDB) ERC1155HoldersAtBlock(contract types.Address, block uint64, tokenId *big.Int, options *types.TokenQueryOptions) ([]types.Address, error) {
+	holders := make(map[types.Address]bool)
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return forEachWithPrefix(tx.Bucket(ERC1155TokenBucket), addressKey(contract), func(k, v []byte) error {
+			var token types.ERC1155Token
+			if err := json.Unmarshal(v, &token); err != nil {
+				return err
+			}
+			if token.Token == tokenId.String() && heldAtBlock(token, block) {
+				holders[token.Holder] = true
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pageHolders(holders, options), nil
+}
+
+func (bdb *BoltDB) RecordTokenBalance(standard string, contract types.Address, holder types.Address, block uint64, amount *big.Int) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		balanceBucket := tx.Bucket(TokenBalanceBucket)
+		prefix := compositeKey(addressKey(contract), standardKey(standard), addressKey(holder))
+
+		// find old entry
+		existingKey, existingEntry, err := getLatestEntry(balanceBucket, prefix, block-1)
+		if err != nil && err != database.ErrNotFound {
+			return err
+		}
+
+		// add new entry
+		balance := types.TokenBalance{
+			Standard: standard,
+			Contract: contract,
+			Holder:   holder,
+			Amount:   amount.String(),
+			HeldFrom: block,
+		}
+		if err := putJSON(balanceBucket, compositeKey(prefix, uint64Key(block)), balance); err != nil {
+			return err
+		}
+
+		if existingKey == nil {
+			return nil
+		}
+
+		// update the older entry
+		var existing types.TokenBalance
+		if err := json.Unmarshal(existingEntry, &existing); err != nil {
+			return err
+		}
+		heldUntil := block - 1
+		existing.HeldUntil = &heldUntil
+		return putJSON(balanceBucket, existingKey, existing)
+	})
+}
+
+func (bdb *BoltDB) TokenBalanceAtBlock(standard string, contract types.Address, holder types.Address, block uint64) (*big.Int, error) {
+	var balance types.TokenBalance
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		prefix := compositeKey(addressKey(contract), standardKey(standard), addressKey(holder))
+		_, entry, err := getLatestEntry(tx.Bucket(TokenBalanceBucket), prefix, block)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(entry, &balance)
+	})
+	if err == database.ErrNotFound {
+		return big.NewInt(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	amount, success := new(big.Int).SetString(balance.Amount, 10)
+	if !success {
+		return nil, errors.New("could not parse token value")
+	}
+	return amount, nil
+}
+
+func (bdb *BoltDB) TokenHoldersAtBlock(standard string, contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	holders := make(map[types.Address]bool)
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		prefix := compositeKey(addressKey(contract), standardKey(standard))
+		return forEachWithPrefix(tx.Bucket(TokenBalanceBucket), prefix, func(k, v []byte) error {
+			var balance types.TokenBalance
+			if err := json.Unmarshal(v, &balance); err != nil {
+				return err
+			}
+			if balance.Amount == "0" || balance.HeldFrom > block {
+				return nil
+			}
+			if balance.HeldUntil == nil || *balance.HeldUntil >= block {
+				holders[balance.Holder] = true
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pageHolders(holders, options), nil
+}
+
+func (bdb *BoltDB) RecordTokenTransfers(transfers []types.TokenTransfer) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		transferBucket := tx.Bucket(TokenTransferBucket)
+		for _, transfer := range transfers {
+			key := compositeKey(addressKey(transfer.Contract), uint64Key(transfer.BlockNumber), uint64Key(transfer.LogIndex))
+			if err := putJSON(transferBucket, key, transfer); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (bdb *BoltDB) ERC721TokenTransfers(contract types.Address, tokenId *big.Int, options *types.TokenQueryOptions) ([]types.TokenTransfer, error) {
+	transfers := make([]types.TokenTransfer, 0)
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		// transfers are keyed by block number and log index, so are already in order
+		return forEachWithPrefix(tx.Bucket(TokenTransferBucket), addressKey(contract), func(k, v []byte) error {
+			if !inRange(blockNumberOfKey(k), options.BeginBlockNumber, options.EndBlockNumber) {
+				return nil
+			}
+			var transfer types.TokenTransfer
+			if err := json.Unmarshal(v, &transfer); err != nil {
+				return err
+			}
+			if transfer.TokenId == tokenId.String() {
+				transfers = append(transfers, transfer)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	start, end := pageBounds(len(transfers), options.PageSize, options.PageNumber)
+	return transfers[start:end], nil
+}
+
+func (bdb *BoltDB) GetTokenTransfers(contract *types.Address, counterparty *types.Address, direction string, options *types.TokenTransferQueryOptions) ([]types.TokenTransfer, error) {
+	afterBlock, afterLogIndex, hasCursor, err := options.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	var prefix []byte
+	if contract != nil {
+		prefix = addressKey(*contract)
+	}
+	transfers := make([]types.TokenTransfer, 0)
+	err = bdb.db.View(func(tx *bbolt.Tx) error {
+		return forEachWithPrefix(tx.Bucket(TokenTransferBucket), prefix, func(k, v []byte) error {
+			var transfer types.TokenTransfer
+			if err := json.Unmarshal(v, &transfer); err != nil {
+				return err
+			}
+			if !transferMatches(transfer, contract, counterparty, direction, options) {
+				return nil
+			}
+			if hasCursor && (transfer.BlockNumber < afterBlock || (transfer.BlockNumber == afterBlock && transfer.LogIndex <= afterLogIndex)) {
+				return nil
+			}
+			transfers = append(transfers, transfer)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// transfers of different contracts are interleaved
+	sort.Slice(transfers, func(i, j int) bool {
+		if transfers[i].BlockNumber != transfers[j].BlockNumber {
+			return transfers[i].BlockNumber < transfers[j].BlockNumber
+		}
+		return transfers[i].LogIndex < transfers[j].LogIndex
+	})
+	if len(transfers) > options.PageSize {
+		transfers = transfers[:options.PageSize]
+	}
+	return transfers, nil
+}
+
+func (bdb *BoltDB) RecordTokenInfo(info *types.TokenInfo) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		return putJSON(tx.Bucket(TokenInfoBucket), addressKey(info.Contract), info)
+	})
+}
+
+func (bdb *BoltDB) GetTokenInfo(contract types.Address) (*types.TokenInfo, error) {
+	var info types.TokenInfo
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		entry := tx.Bucket(TokenInfoBucket).Get(addressKey(contract))
+		if entry == nil {
+			return database.ErrNotFound
+		}
+		return json.Unmarshal(entry, &info)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (bdb *BoltDB) RecordTotalSupply(contract types.Address, block uint64, supply *big.Int) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		supplyBucket := tx.Bucket(TotalSupplyBucket)
+		prefix := addressKey(contract)
+
+		// find old entry
+		existingKey, existingEntry, err := getLatestEntry(supplyBucket, prefix, block-1)
+		if err != nil && err != database.ErrNotFound {
+			return err
+		}
+
+		// add new entry
+		totalSupply := types.TokenSupply{
+			Contract:    contract,
+			TotalSupply: supply.String(),
+			BlockNumber: block,
+		}
+		if err := putJSON(supplyBucket, compositeKey(prefix, uint64Key(block)), totalSupply); err != nil {
+			return err
+		}
+
+		if existingKey == nil {
+			return nil
+		}
+
+		// update the older entry
+		var existing types.TokenSupply
+		if err := json.Unmarshal(existingEntry, &existing); err != nil {
+			return err
+		}
+		supplyUntil := block - 1
+		existing.SupplyUntil = &supplyUntil
+		return putJSON(supplyBucket, existingKey, existing)
+	})
+}
+
+func (bdb *BoltDB) GetTotalSupply(contract types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	beginBlock := options.BeginBlockNumber.Uint64()
+
+	// get all the supplies in the block range, as well as the last supply before the
+	// starting block if there was no change on the starting block
+	var supplies []types.TokenSupply
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		return forEachWithPrefix(tx.Bucket(TotalSupplyBucket), addressKey(contract), func(k, v []byte) error {
+			var supply types.TokenSupply
+			if err := json.Unmarshal(v, &supply); err != nil {
+				return err
+			}
+			atBegin := supply.BlockNumber < beginBlock && (supply.SupplyUntil == nil || *supply.SupplyUntil >= beginBlock)
+			if atBegin || inRange(supply.BlockNumber, options.BeginBlockNumber, options.EndBlockNumber) {
+				supplies = append(supplies, supply)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(supplies, func(i, j int) bool {
+		return supplies[i].BlockNumber > supplies[j].BlockNumber
+	})
+	start, end := pageBounds(len(supplies), options.PageSize, options.PageNumber)
+
+	supplyMap := make(map[uint64]*big.Int)
+	for _, supply := range supplies[start:end] {
+		totalSupply, success := new(big.Int).SetString(supply.TotalSupply, 10)
+		if !success {
+			return nil, errors.New("could not parse total supply")
+		}
+		if supply.BlockNumber < beginBlock {
+			supplyMap[beginBlock] = totalSupply
+		} else {
+			supplyMap[supply.BlockNumber] = totalSupply
+		}
+	}
+	return supplyMap, nil
+}
+
+// Internal functions
+
+// heldAtBlock checks if the ERC1155 balance is non-zero and current at the given block
+func heldAtBlock(token types.ERC1155Token, block uint64) bool {
+	if token.Amount == "0" || token.HeldFrom > block {
+		return false
+	}
+	return token.HeldUntil == nil || *token.HeldUntil >= block
+}
+
+// erc721TokensAtBlock returns the tokens held at the given block, ordered by token ID,
+// starting after the token ID in the options
+func (bdb *BoltDB) erc721TokensAtBlock(contract types.Address, holder *types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
+	startTokenId := big.NewInt(-1)
+	if options.After != "" {
+		parsed, success := new(big.Int).SetString(options.After, 10)
+		if !success {
+			return nil, errors.New(`could not parse "after" token ID`)
+		}
+		startTokenId = parsed
+	}
+
+	tokens, err := bdb.getERC721TokensAtBlock(contract, holder, block, new(big.Int).Add(startTokenId, big.NewInt(1)))
+	if err != nil {
+		return nil, err
+	}
+	start, end := pageBounds(len(tokens), options.PageSize, options.PageNumber)
+	return tokens[start:end], nil
+}
+
+func (bdb *BoltDB) getERC721TokensAtBlock(contract types.Address, holder *types.Address, block uint64, startTokenId *big.Int) ([]types.ERC721Token, error) {
+	tokens := make([]types.ERC721Token, 0)
+	err := bdb.db.View(func(tx *bbolt.Tx) error {
+		prefix := addressKey(contract)
+		c := tx.Bucket(ERC721TokenBucket).Cursor()
+		k, v := c.Seek(prefix)
+		if startTokenId != nil {
+			k, v = c.Seek(compositeKey(prefix, tokenIdKey(startTokenId)))
+		}
+		for ; k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var token types.ERC721Token
+			if err := json.Unmarshal(v, &token); err != nil {
+				return err
+			}
+			if token.HeldFrom > block || (token.HeldUntil != nil && *token.HeldUntil < block) {
+				continue
+			}
+			if holder == nil || *holder == token.Holder {
+				tokens = append(tokens, token)
+			}
+		}
+		return nil
+	})
+	return tokens, err
+}
+
+// getLatestEntry finds the entry with the highest block number at or before the given
+// block, for keys made of the prefix followed by a block number
+func getLatestEntry(bucket *bbolt.Bucket, prefix []byte, block uint64) ([]byte, []byte, error) {
+	c := bucket.Cursor()
+	k, v := c.Seek(compositeKey(prefix, uint64Key(block+1)))
+	if k == nil {
+		k, v = c.Last()
+	} else {
+		k, v = c.Prev()
+	}
+	if k == nil || !bytes.HasPrefix(k, prefix) || len(k) != len(prefix)+8 {
+		return nil, nil, database.ErrNotFound
+	}
+	return k, v, nil
+}
+
+func (bdb *BoltDB) RollbackTokenRange(contract types.Address, fromBlock uint64, toBlock uint64) error {
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		isRemoved := func(k, v []byte) bool { return blockNumberOfKey(k) >= fromBlock && blockNumberOfKey(k) <= toBlock }
+		if err := deleteMatching(tx.Bucket(TokenTransferBucket), addressKey(contract), isRemoved); err != nil {
+			return err
+		}
+		return rollbackTokens(tx, addressKey(contract), fromBlock, toBlock)
+	})
+}
+
+// rollbackTokens removes the token records with the given key prefix from blocks in the
+// range, and re-opens the records they superseded
+func rollbackTokens(tx *bbolt.Tx, prefix []byte, fromBlock uint64, toBlock uint64) error {
+	removed := func(block uint64) bool { return block >= fromBlock && block <= toBlock }
+	superseded := func(until *uint64) bool { return until != nil && *until+1 >= fromBlock && *until < toBlock }
+
+	erc20Bucket := tx.Bucket(ERC20TokenBucket)
+	reopened := make(map[string]ERC20TokenHolder)
+	err := deleteMatching(erc20Bucket, prefix, func(k, v []byte) bool {
+		var balance ERC20TokenHolder
+		if err := json.Unmarshal(v, &balance); err != nil {
+			return false
+		}
+		if removed(balance.BlockNumber) {
+			return true
+		}
+		if superseded(balance.HeldUntil) {
+			balance.HeldUntil = nil
+			reopened[string(k)] = balance
+		}
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	for k, balance := range reopened {
+		if err := putJSON(erc20Bucket, []byte(k), balance); err != nil {
+			return err
+		}
+	}
+
+	allowanceBucket := tx.Bucket(ERC20AllowanceBucket)
+	reopenedAllowances := make(map[string]types.ERC20Allowance)
+	err = deleteMatching(allowanceBucket, prefix, func(k, v []byte) bool {
+		var allowance types.ERC20Allowance
+		if err := json.Unmarshal(v, &allowance); err != nil {
+			return false
+		}
+		if removed(allowance.ApprovedFrom) {
+			return true
+		}
+		if superseded(allowance.ApprovedUntil) {
+			allowance.ApprovedUntil = nil
+			reopenedAllowances[string(k)] = allowance
+		}
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	for k, allowance := range reopenedAllowances {
+		if err := putJSON(allowanceBucket, []byte(k), allowance); err != nil {
+			return err
+		}
+	}
+
+	supplyBucket := tx.Bucket(TotalSupplyBucket)
+	reopenedSupplies := make(map[string]types.TokenSupply)
+	err = deleteMatching(supplyBucket, prefix, func(k, v []byte) bool {
+		var supply types.TokenSupply
+		if err := json.Unmarshal(v, &supply); err != nil {
+			return false
+		}
+		if removed(supply.BlockNumber) {
+			return true
+		}
+		if superseded(supply.SupplyUntil) {
+			supply.SupplyUntil = nil
+			reopenedSupplies[string(k)] = supply
+		}
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	for k, supply := range reopenedSupplies {
+		if err := putJSON(supplyBucket, []byte(k), supply); err != nil {
+			return err
+		}
+	}
+
+	erc721Bucket := tx.Bucket(ERC721TokenBucket)
+	reopenedTokens := make(map[string]types.ERC721Token)
+	err = deleteMatching(erc721Bucket, prefix, func(k, v []byte) bool {
+		var token types.ERC721Token
+		if err := json.Unmarshal(v, &token); err != nil {
+			return false
+		}
+		if removed(token.HeldFrom) {
+			return true
+		}
+		if superseded(token.HeldUntil) {
+			token.HeldUntil = nil
+			reopenedTokens[string(k)] = token
+		}
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	for k, token := range reopenedTokens {
+		if err := putJSON(erc721Bucket, []byte(k), token); err != nil {
+			return err
+		}
+	}
+
+	erc1155Bucket := tx.Bucket(ERC1155TokenBucket)
+	reopenedBalances := make(map[string]types.ERC1155Token)
+	err = deleteMatching(erc1155Bucket, prefix, func(k, v []byte) bool {
+		var token types.ERC1155Token
+		if err := json.Unmarshal(v, &token); err != nil {
+			return false
+		}
+		if removed(token.HeldFrom) {
+			return true
+		}
+		if superseded(token.HeldUntil) {
+			token.HeldUntil = nil
+			reopenedBalances[string(k)] = token
+		}
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	for k, token := range reopenedBalances {
+		if err := putJSON(erc1155Bucket, []byte(k), token); err != nil {
+			return err
+		}
+	}
+
+	balanceBucket := tx.Bucket(TokenBalanceBucket)
+	reopenedTokenBalances := make(map[string]types.TokenBalance)
+	err = deleteMatching(balanceBucket, prefix, func(k, v []byte) bool {
+		var balance types.TokenBalance
+		if err := json.Unmarshal(v, &balance); err != nil {
+			return false
+		}
+		if removed(balance.HeldFrom) {
+			return true
+		}
+		if superseded(balance.HeldUntil) {
+			balance.HeldUntil = nil
+			reopenedTokenBalances[string(k)] = balance
+		}
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	for k, balance := range reopenedTokenBalances {
+		if err := putJSON(balanceBucket, []byte(k), balance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transferMatches checks if the transfer is of the given contract, is to or from the counterparty in the
+// given direction, and is within the block and time ranges of the options, ignoring any filter not given
+func transferMatches(transfer types.TokenTransfer, contract *types.Address, counterparty *types.Address, direction string, options *types.TokenTransferQueryOptions) bool {
+	if contract != nil && transfer.Contract != *contract {
+		return false
+	}
+	if counterparty != nil {
+		isIn := transfer.To == *counterparty && direction != types.TransferDirectionOut
+		isOut := transfer.From == *counterparty && direction != types.TransferDirectionIn
+		if !isIn && !isOut {
+			return false
+		}
+	}
+	return inRange(transfer.BlockNumber, options.BeginBlockNumber, options.EndBlockNumber) &&
+		inRange(transfer.Timestamp, options.BeginTimestamp, options.EndTimestamp)
+}
+
+// pageHolders sorts the holders by address, returning at most a page of holders after
+// the holder given in the options
+func pageHolders(holders map[types.Address]bool, options *types.TokenQueryOptions) []types.Address {
+	after := ""
+	if options.After != "" {
+		after = string(types.NewAddress(options.After))
+	}
+
+	sorted := make([]types.Address, 0, len(holders))
+	for holder := range holders {
+		if string(holder) > after {
+			sorted = append(sorted, holder)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	if len(sorted) > options.PageSize {
+		sorted = sorted[:options.PageSize]
+	}
+	return sorted
+}
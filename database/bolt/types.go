@@ -0,0 +1,115 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// buckets
+var (
+	MetaBucket           = []byte("meta")
+	ContractBucket       = []byte("contract")
+	TemplateBucket       = []byte("template")
+	BlockBucket          = []byte("block")
+	TransactionBucket    = []byte("transaction")
+	TxToBucket           = []byte("txTo")
+	TxInternalToBucket   = []byte("txInternalTo")
+	EventBucket          = []byte("event")
+	StorageBucket        = []byte("storage")
+	StorageRootBucket    = []byte("storageRoot")
+	ERC20TokenBucket     = []byte("erc20token")
+	ERC20AllowanceBucket = []byte("erc20allowance")
+	ERC721TokenBucket    = []byte("erc721token")
+	ERC1155TokenBucket   = []byte("erc1155token")
+	TokenBalanceBucket   = []byte("tokenBalance")
+	TokenTransferBucket  = []byte("tokenTransfer")
+	TokenInfoBucket      = []byte("tokenInfo")
+	TotalSupplyBucket    = []byte("totalSupply")
+	ReorgBucket          = []byte("reorg")
+	ProxyBucket          = []byte("proxy")
+	BackfillBucket       = []byte("backfill")
+	NativeTransferBucket = []byte("nativeTransfer")
+	NativeBalanceBucket  = []byte("nativeBalance")
+	FailureBucket        = []byte("failure")
+
+	AllBuckets = [][]byte{MetaBucket, ContractBucket, TemplateBucket, BlockBucket, TransactionBucket, TxToBucket, TxInternalToBucket, EventBucket, StorageBucket, StorageRootBucket, ERC20TokenBucket, ERC20AllowanceBucket, ERC721TokenBucket, ERC1155TokenBucket, TokenBalanceBucket, TokenTransferBucket, TokenInfoBucket, TotalSupplyBucket, ReorgBucket, ProxyBucket, BackfillBucket, NativeTransferBucket, NativeBalanceBucket, FailureBucket}
+)
+
+var (
+	lastPersistedKey = []byte("lastPersisted")
+	// index keys start with the address, followed by the block number
+	addressKeyLength    = len(types.NewAddress(""))
+	blockNumberKeyStart = addressKeyLength
+)
+
+// Contract is stored as is, since a JSON round trip of an empty hash does not preserve the empty value
+type Contract struct {
+	Address             types.Address `json:"address"`
+	TemplateName        string        `json:"templateName"`
+	CreationTransaction string        `json:"creationTx"`
+	LastFiltered        uint64        `json:"lastFiltered"`
+}
+
+type Template struct {
+	TemplateName string `json:"templateName"`
+	ABI          string `json:"abi"`
+	StorageABI   string `json:"storageAbi"`
+}
+
+// TxIndexEntry is the value of the transaction recipient indexes, which are keyed by
+// address, block number and transaction index. Entries written before values were indexed
+// have no value.
+type TxIndexEntry struct {
+	Hash      types.Hash    `json:"hash"`
+	Timestamp uint64        `json:"timestamp"`
+	Value     *types.BigInt `json:"value,omitempty"`
+}
+
+type ERC20TokenHolder struct {
+	Contract    types.Address `json:"contract"`
+	Holder      types.Address `json:"holder"`
+	BlockNumber uint64        `json:"blockNumber"`
+	Amount      string        `json:"amount"`
+	HeldUntil   *uint64       `json:"heldUntil"`
+}
+
+// keys
+
+func uint64Key(number uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, number)
+	return key
+}
+
+func compositeKey(parts ...[]byte) []byte {
+	var key []byte
+	for _, part := range parts {
+		key = append(key, part...)
+	}
+	return key
+}
+
+func addressKey(address types.Address) []byte {
+	return []byte(address)
+}
+
+// standardKey terminates the name of a token standard, so that no standard's keys are a prefix
+// of another's
+func standardKey(standard string) []byte {
+	return append([]byte(standard), 0)
+}
+
+// tokenIdKey left pads the token ID to 32 bytes, so that keys sort by token ID
+func tokenIdKey(tokenId *big.Int) []byte {
+	key := make([]byte, 32)
+	tokenBytes := tokenId.Bytes()
+	copy(key[32-len(tokenBytes):], tokenBytes)
+	return key
+}
+
+// blockNumberOfKey returns the block number that directly follows the address in an index key
+func blockNumberOfKey(key []byte) uint64 {
+	return binary.BigEndian.Uint64(key[blockNumberKeyStart : blockNumberKeyStart+8])
+}
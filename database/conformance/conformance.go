@@ -0,0 +1,1583 @@
+// Package conformance contains a test suite that every database.Database implementation
+// is expected to pass, so that all backends, and wrappers around them, behave identically.
+//
+// The expected behaviour is that of the Elasticsearch backend.
+package conformance
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/types"
+)
+
+// Setup creates a new, empty database for a single test, along with a function that
+// releases all of its resources once the test has finished.
+type Setup func(t *testing.T) (database.Database, func())
+
+// RunTests runs the whole conformance suite against the databases created by setup. Each
+// test is run as a subtest against its own database.
+func RunTests(t *testing.T, setup Setup) {
+	tests := []struct {
+		name string
+		test func(*testing.T, database.Database)
+	}{
+		{"Addresses", testAddresses},
+		{"DeleteAddress", testDeleteAddress},
+		{"Templates", testTemplates},
+		{"BlocksAndTransactions", testBlocksAndTransactions},
+		{"TransactionIndexes", testTransactionIndexes},
+		{"Events", testEvents},
+		{"ContractCreationTransaction", testContractCreationTransaction},
+		{"Storage", testStorage},
+		{"ERC20Balance", testERC20Balance},
+		{"ERC20Allowances", testERC20Allowances},
+		{"ERC721Tokens", testERC721Tokens},
+		{"ERC1155Tokens", testERC1155Tokens},
+		{"TokenPortfolio", testTokenPortfolio},
+		{"TokenBalances", testTokenBalances},
+		{"TokenTransfers", testTokenTransfers},
+		{"GetTokenTransfers", testGetTokenTransfers},
+		{"TokenInfo", testTokenInfo},
+		{"TotalSupply", testTotalSupply},
+		{"RewriteTokenBlock", testRewriteTokenBlock},
+		{"RollbackBlocks", testRollbackBlocks},
+		{"RollbackTokenRange", testRollbackTokenRange},
+		{"ProxyImplementations", testProxyImplementations},
+		{"BackfillJobs", testBackfillJobs},
+		{"NativeTransfers", testNativeTransfers},
+		{"NativeBalances", testNativeBalances},
+		{"TransactionFailures", testTransactionFailures},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			db, cleanup := setup(t)
+			defer cleanup()
+			tc.test(t, db)
+		})
+	}
+}
+
+// writeTestChain registers addr and writes the test chain of two blocks, indexing both
+func writeTestChain(t *testing.T, db database.Database) {
+	assert.Nil(t, db.AddAddresses([]types.Address{addr}))
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx1, tx2, tx3, tx4, tx5}))
+	assert.Nil(t, db.WriteBlocks([]*types.Block{block1, block2}))
+	assert.Nil(t, db.IndexBlocks([]types.Address{addr}, []*types.BlockWithTransactions{
+		{Hash: block1.Hash, Number: 1, Transactions: []*types.Transaction{tx1, tx2, tx3}},
+		{Hash: block2.Hash, Number: 2, Transactions: []*types.Transaction{tx4, tx5}},
+	}))
+}
+
+func testAddresses(t *testing.T, db database.Database) {
+	addresses, err := db.GetAddresses()
+	assert.Nil(t, err)
+	assert.Empty(t, addresses)
+
+	assert.Nil(t, db.AddAddresses([]types.Address{addr}))
+	assert.Nil(t, db.AddAddressFrom(uselessAddress, 10))
+	// adding an existing address does not reset or duplicate it
+	assert.Nil(t, db.AddAddressFrom(addr, 100))
+	assert.Nil(t, db.AddAddresses([]types.Address{addr, uselessAddress}))
+
+	addresses, err = db.GetAddresses()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []types.Address{addr, uselessAddress}, addresses)
+
+	lastFiltered, err := db.GetLastFiltered(addr)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, lastFiltered)
+	lastFiltered, err = db.GetLastFiltered(uselessAddress)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 9, lastFiltered)
+	_, err = db.GetLastFiltered(unknownAddress)
+	assert.Equal(t, database.ErrNotFound, err)
+
+	// contracts use a template named after themselves until one is assigned
+	templateName, err := db.GetContractTemplate(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, addr.String(), templateName)
+	_, err = db.GetContractTemplate(unknownAddress)
+	assert.Equal(t, database.ErrNotFound, err)
+}
+
+func testDeleteAddress(t *testing.T, db database.Database) {
+	writeTestChain(t, db)
+	assert.Nil(t, db.AddAddresses([]types.Address{uselessAddress}))
+	assert.Nil(t, db.AddTemplate(addr.String(), "abi", "layout"))
+	assert.Nil(t, db.AssignTemplate(addr, addr.String()))
+	assert.Nil(t, db.SetContractCreationTransaction(map[types.Hash][]types.Address{tx1.Hash: {addr}}))
+	assert.Nil(t, db.IndexStorage(map[types.Address]*types.AccountState{
+		addr: {Root: types.NewHash("0x1234"), Storage: map[types.Hash]string{types.NewHash("0x1"): "0x2a"}},
+	}, 2))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holder0, 1, big.NewInt(1000)))
+	assert.Nil(t, db.RecordERC20Allowance(addr, holder0, holder1, 1, big.NewInt(100)))
+	assert.Nil(t, db.RecordERC721Token(addr, holder0, 1, big.NewInt(1)))
+	assert.Nil(t, db.RecordERC1155Balance(addr, holder0, 1, big.NewInt(1), big.NewInt(10)))
+	assert.Nil(t, db.RecordTokenBalance("erc777", addr, holder0, 1, big.NewInt(10)))
+	assert.Nil(t, db.RecordTokenTransfers([]types.TokenTransfer{tokenTransfer(1, 0, zeroAddress, holder0, "1")}))
+	assert.Nil(t, db.RecordTokenInfo(&types.TokenInfo{Contract: addr, Standard: types.TokenStandardERC721, Name: "Token"}))
+	assert.Nil(t, db.RecordTotalSupply(addr, 1, big.NewInt(1)))
+	// cache the creation transaction in wrappers
+	_, _ = db.GetContractCreationTransaction(addr)
+
+	assert.Nil(t, db.DeleteAddress(addr))
+
+	addresses, err := db.GetAddresses()
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{uselessAddress}, addresses)
+
+	_, err = db.GetContractTemplate(addr)
+	assert.Equal(t, database.ErrNotFound, err)
+	_, err = db.GetLastFiltered(addr)
+	assert.Equal(t, database.ErrNotFound, err)
+	_, err = db.GetContractCreationTransaction(addr)
+	assert.Equal(t, database.ErrNotFound, err)
+	// the specialised template of the contract is removed
+	_, err = db.GetTemplateDetails(addr.String())
+	assert.Equal(t, database.ErrNotFound, err)
+
+	// all data derived from the contract is removed
+	total, err := db.GetEventsFromAddressTotal(addr, queryOptions())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, total)
+	total, err = db.GetStorageTotal(addr, pageOptions())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, total)
+	storage, err := db.GetStorage(addr, 2)
+	assert.Nil(t, err)
+	assert.Empty(t, storage.Storage)
+	holders, err := db.GetAllTokenHolders(addr, 1, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, holders)
+	allowance, err := db.ERC20AllowanceAtBlock(addr, holder0, holder1, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(0), allowance)
+	_, err = db.ERC721TokenByTokenID(addr, 1, big.NewInt(1))
+	assert.Equal(t, database.ErrNotFound, err)
+	balance, err := db.ERC1155BalanceAtBlock(addr, holder0, 1, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(0), balance)
+	balance, err = db.TokenBalanceAtBlock("erc777", addr, holder0, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(0), balance)
+	transfers, err := db.ERC721TokenTransfers(addr, big.NewInt(1), tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, transfers)
+	_, err = db.GetTokenInfo(addr)
+	assert.Equal(t, database.ErrNotFound, err)
+	supplies, err := db.GetTotalSupply(addr, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, supplies)
+
+	// the chain data itself is kept
+	txs, err := db.GetAllTransactionsToAddress(addr, queryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{tx4.Hash, tx5.Hash, tx3.Hash}, txs)
+	retrievedTx, err := db.ReadTransaction(tx3.Hash)
+	assert.Nil(t, err)
+	assert.Equal(t, tx3, retrievedTx)
+
+	// the address can be registered again from scratch
+	assert.Nil(t, db.AddAddresses([]types.Address{addr}))
+	lastFiltered, err := db.GetLastFiltered(addr)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, lastFiltered)
+}
+
+func testTemplates(t *testing.T, db database.Database) {
+	templates, err := db.GetTemplates()
+	assert.Nil(t, err)
+	assert.Empty(t, templates)
+
+	assert.Nil(t, db.AddAddresses([]types.Address{addr}))
+	assert.Nil(t, db.AddTemplate("SimpleStorage", "abi", "layout"))
+	assert.Nil(t, db.AddTemplate("Other", "other abi", "other layout"))
+	assert.Nil(t, db.AssignTemplate(addr, "SimpleStorage"))
+	assert.Equal(t, database.ErrNotFound, db.AssignTemplate(unknownAddress, "SimpleStorage"))
+
+	templateName, err := db.GetContractTemplate(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, "SimpleStorage", templateName)
+	abi, err := db.GetContractABI(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, "abi", abi)
+	layout, err := db.GetStorageLayout(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, "layout", layout)
+
+	// unknown contracts have no ABI or storage layout
+	abi, err = db.GetContractABI(uselessAddress)
+	assert.Nil(t, err)
+	assert.Equal(t, "", abi)
+	layout, err = db.GetStorageLayout(uselessAddress)
+	assert.Nil(t, err)
+	assert.Equal(t, "", layout)
+
+	templates, err = db.GetTemplates()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"SimpleStorage", "Other"}, templates)
+
+	details, err := db.GetTemplateDetails("SimpleStorage")
+	assert.Nil(t, err)
+	assert.Equal(t, &types.Template{TemplateName: "SimpleStorage", ABI: "abi", StorageLayout: "layout"}, details)
+	_, err = db.GetTemplateDetails("unknown")
+	assert.Equal(t, database.ErrNotFound, err)
+
+	// adding a template with an existing name replaces it
+	assert.Nil(t, db.AddTemplate("SimpleStorage", "new abi", "new layout"))
+	abi, err = db.GetContractABI(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, "new abi", abi)
+	templates, err = db.GetTemplates()
+	assert.Nil(t, err)
+	assert.Len(t, templates, 2)
+}
+
+func testBlocksAndTransactions(t *testing.T, db database.Database) {
+	lastPersisted, err := db.GetLastPersistedBlockNumber()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, lastPersisted)
+
+	// out of order blocks do not move the last persisted block
+	assert.Nil(t, db.WriteBlocks([]*types.Block{block2}))
+	lastPersisted, err = db.GetLastPersistedBlockNumber()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, lastPersisted)
+
+	assert.Nil(t, db.WriteBlocks([]*types.Block{block1}))
+	lastPersisted, err = db.GetLastPersistedBlockNumber()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, lastPersisted)
+
+	retrievedBlock, err := db.ReadBlock(1)
+	assert.Nil(t, err)
+	assert.Equal(t, block1, retrievedBlock)
+	retrievedBlock, err = db.ReadBlock(2)
+	assert.Nil(t, err)
+	assert.Equal(t, block2, retrievedBlock)
+	_, err = db.ReadBlock(3)
+	assert.Equal(t, database.ErrNotFound, err)
+
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx2, tx3}))
+	retrievedTx, err := db.ReadTransaction(tx2.Hash)
+	assert.Nil(t, err)
+	assert.Equal(t, tx2, retrievedTx)
+	retrievedTx, err = db.ReadTransaction(tx3.Hash)
+	assert.Nil(t, err)
+	assert.Equal(t, tx3, retrievedTx)
+	_, err = db.ReadTransaction(tx4.Hash)
+	assert.Equal(t, database.ErrNotFound, err)
+
+	// dynamic fee transactions keep their fee caps and the price they paid
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx4}))
+	retrievedTx, err = db.ReadTransaction(tx4.Hash)
+	assert.Nil(t, err)
+	assert.Equal(t, tx4, retrievedTx)
+}
+
+func testTransactionIndexes(t *testing.T, db database.Database) {
+	writeTestChain(t, db)
+
+	// sorted by block number descending, then transaction index ascending
+	txs, err := db.GetAllTransactionsToAddress(addr, queryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{tx4.Hash, tx5.Hash, tx3.Hash}, txs)
+	total, err := db.GetTransactionsToAddressTotal(addr, queryOptions())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, total)
+
+	// block range
+	options := queryOptions()
+	options.EndBlockNumber = big.NewInt(1)
+	txs, err = db.GetAllTransactionsToAddress(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{tx3.Hash}, txs)
+	total, err = db.GetTransactionsToAddressTotal(addr, options)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, total)
+
+	// timestamp range
+	options = queryOptions()
+	options.BeginTimestamp = big.NewInt(150)
+	txs, err = db.GetAllTransactionsToAddress(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{tx4.Hash, tx5.Hash}, txs)
+	total, err = db.GetTransactionsToAddressTotal(addr, options)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, total)
+
+	// value range, including values that do not fit in a uint64
+	options = queryOptions()
+	options.BeginValue = amount("1000")
+	txs, err = db.GetAllTransactionsToAddress(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{tx4.Hash, tx3.Hash}, txs)
+	total, err = db.GetTransactionsToAddressTotal(addr, options)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, total)
+	options.EndValue = amount("999999999999999999999")
+	txs, err = db.GetAllTransactionsToAddress(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{tx3.Hash}, txs)
+
+	// pagination
+	options = queryOptions()
+	options.PageSize = 2
+	options.PageNumber = 1
+	txs, err = db.GetAllTransactionsToAddress(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{tx3.Hash}, txs)
+	// the total ignores pagination
+	total, err = db.GetTransactionsToAddressTotal(addr, options)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, total)
+	options.PageNumber = 2
+	txs, err = db.GetAllTransactionsToAddress(addr, options)
+	assert.Nil(t, err)
+	assert.Empty(t, txs)
+
+	internalTxs, err := db.GetAllTransactionsInternalToAddress(addr, queryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{tx2.Hash}, internalTxs)
+	total, err = db.GetTransactionsInternalToAddressTotal(addr, queryOptions())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, total)
+
+	options = queryOptions()
+	options.BeginBlockNumber = big.NewInt(2)
+	internalTxs, err = db.GetAllTransactionsInternalToAddress(addr, options)
+	assert.Nil(t, err)
+	assert.Empty(t, internalTxs)
+	total, err = db.GetTransactionsInternalToAddressTotal(addr, options)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, total)
+
+	// transactions are found for addresses that are not registered
+	txs, err = db.GetAllTransactionsToAddress(uselessAddress, queryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{tx2.Hash}, txs)
+	txs, err = db.GetAllTransactionsToAddress(unknownAddress, queryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, txs)
+	total, err = db.GetTransactionsToAddressTotal(unknownAddress, queryOptions())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, total)
+}
+
+func testEvents(t *testing.T, db database.Database) {
+	writeTestChain(t, db)
+
+	lastFiltered, err := db.GetLastFiltered(addr)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, lastFiltered)
+
+	// sorted by block number descending, then log index ascending
+	events, err := db.GetAllEventsFromAddress(addr, queryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []*types.Event{event2, event3, event1}, events)
+	total, err := db.GetEventsFromAddressTotal(addr, queryOptions())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, total)
+
+	// blocks that have already been filtered are not indexed again
+	assert.Nil(t, db.IndexBlocks([]types.Address{addr}, []*types.BlockWithTransactions{
+		{Hash: block1.Hash, Number: 1, Transactions: []*types.Transaction{tx1, tx2, tx3}},
+	}))
+	total, err = db.GetEventsFromAddressTotal(addr, queryOptions())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, total)
+
+	// block range
+	options := queryOptions()
+	options.BeginBlockNumber = big.NewInt(2)
+	options.EndBlockNumber = big.NewInt(2)
+	events, err = db.GetAllEventsFromAddress(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []*types.Event{event2, event3}, events)
+	total, err = db.GetEventsFromAddressTotal(addr, options)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, total)
+
+	// timestamp range
+	options = queryOptions()
+	options.EndTimestamp = big.NewInt(150)
+	events, err = db.GetAllEventsFromAddress(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []*types.Event{event1}, events)
+	total, err = db.GetEventsFromAddressTotal(addr, options)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, total)
+
+	// pagination
+	options = queryOptions()
+	options.PageSize = 1
+	options.PageNumber = 1
+	events, err = db.GetAllEventsFromAddress(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []*types.Event{event3}, events)
+
+	// events are only indexed for registered addresses
+	events, err = db.GetAllEventsFromAddress(unknownAddress, queryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, events)
+	total, err = db.GetEventsFromAddressTotal(unknownAddress, queryOptions())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, total)
+}
+
+func testContractCreationTransaction(t *testing.T, db database.Database) {
+	assert.Nil(t, db.AddAddresses([]types.Address{addr, uselessAddress}))
+
+	creationTx, err := db.GetContractCreationTransaction(addr)
+	assert.Nil(t, err)
+	assert.True(t, creationTx.IsEmpty())
+
+	// unregistered addresses are ignored
+	assert.Nil(t, db.SetContractCreationTransaction(map[types.Hash][]types.Address{
+		tx1.Hash: {unknownAddress, addr},
+		tx2.Hash: {uselessAddress},
+	}))
+
+	creationTx, err = db.GetContractCreationTransaction(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, tx1.Hash, creationTx)
+	creationTx, err = db.GetContractCreationTransaction(uselessAddress)
+	assert.Nil(t, err)
+	assert.Equal(t, tx2.Hash, creationTx)
+	_, err = db.GetContractCreationTransaction(unknownAddress)
+	assert.Equal(t, database.ErrNotFound, err)
+}
+
+func testStorage(t *testing.T, db database.Database) {
+	assert.Nil(t, db.AddAddresses([]types.Address{addr}))
+
+	storage1 := map[types.Hash]string{types.NewHash("0x1"): "0x2a"}
+	root1 := types.NewHash("0x1234")
+	storage2 := map[types.Hash]string{types.NewHash("0x1"): "0x2b"}
+	root2 := types.NewHash("0x5678")
+	assert.Nil(t, db.IndexStorage(map[types.Address]*types.AccountState{addr: {Root: root1, Storage: storage1}}, 5))
+	assert.Nil(t, db.IndexStorage(map[types.Address]*types.AccountState{addr: {Root: root2, Storage: storage2}}, 8))
+	// storage is shared between blocks with the same root
+	assert.Nil(t, db.IndexStorage(map[types.Address]*types.AccountState{addr: {Root: root1, Storage: storage1}}, 10))
+
+	// the latest storage at or before the block is returned
+	result, err := db.GetStorage(addr, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, &types.StorageResult{Storage: storage1, StorageRoot: root1, BlockNumber: 5}, result)
+	result, err = db.GetStorage(addr, 9)
+	assert.Nil(t, err)
+	assert.Equal(t, &types.StorageResult{Storage: storage2, StorageRoot: root2, BlockNumber: 9}, result)
+	result, err = db.GetStorage(addr, 20)
+	assert.Nil(t, err)
+	assert.Equal(t, &types.StorageResult{Storage: storage1, StorageRoot: root1, BlockNumber: 20}, result)
+
+	// there is no storage before the first indexed block, or for unknown contracts
+	result, err = db.GetStorage(addr, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, types.NewHash(""), result.StorageRoot)
+	assert.Empty(t, result.Storage)
+	assert.EqualValues(t, 4, result.BlockNumber)
+	result, err = db.GetStorage(unknownAddress, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, types.NewHash(""), result.StorageRoot)
+	assert.Empty(t, result.Storage)
+
+	// sorted by block number descending
+	results, err := db.GetStorageWithOptions(addr, pageOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []*types.StorageResult{
+		{Storage: storage1, StorageRoot: root1, BlockNumber: 10},
+		{Storage: storage2, StorageRoot: root2, BlockNumber: 8},
+		{Storage: storage1, StorageRoot: root1, BlockNumber: 5},
+	}, results)
+	total, err := db.GetStorageTotal(addr, pageOptions())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, total)
+
+	// block range
+	options := pageOptions()
+	options.BeginBlockNumber = big.NewInt(6)
+	options.EndBlockNumber = big.NewInt(9)
+	results, err = db.GetStorageWithOptions(addr, options)
+	assert.Nil(t, err)
+	assert.Len(t, results, 1)
+	assert.EqualValues(t, 8, results[0].BlockNumber)
+	total, err = db.GetStorageTotal(addr, options)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, total)
+
+	// pagination
+	options = pageOptions()
+	options.PageSize = 2
+	options.PageNumber = 1
+	results, err = db.GetStorageWithOptions(addr, options)
+	assert.Nil(t, err)
+	assert.Len(t, results, 1)
+	assert.EqualValues(t, 5, results[0].BlockNumber)
+	total, err = db.GetStorageTotal(addr, options)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, total)
+
+	options = pageOptions()
+	options.EndBlockNumber = big.NewInt(10)
+	ranges, err := db.GetStorageRanges(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.RangeResult{{Start: 0, End: 10, ResultCount: 3}}, ranges)
+
+	options.EndBlockNumber = big.NewInt(7)
+	ranges, err = db.GetStorageRanges(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.RangeResult{{Start: 0, End: 7, ResultCount: 1}}, ranges)
+}
+
+func testERC20Balance(t *testing.T, db database.Database) {
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holder0, 1, big.NewInt(1000)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holder0, 3, big.NewInt(900)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holder1, 3, big.NewInt(100)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holder0, 6, big.NewInt(0)))
+	// transfers from the zero address are mints, which do not make it a holder
+	assert.Nil(t, db.RecordNewERC20Balance(addr, zeroAddress, 7, big.NewInt(0)))
+
+	// the balance at the start of the range is included
+	options := tokenQueryOptions()
+	options.BeginBlockNumber = big.NewInt(2)
+	options.EndBlockNumber = big.NewInt(5)
+	balances, err := db.GetERC20Balance(addr, holder0, options)
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{2: big.NewInt(1000), 3: big.NewInt(900)}, balances)
+
+	options = tokenQueryOptions()
+	balances, err = db.GetERC20Balance(addr, holder0, options)
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(1000), 3: big.NewInt(900), 6: big.NewInt(0)}, balances)
+
+	// pagination, most recent balances first
+	options.PageSize = 2
+	balances, err = db.GetERC20Balance(addr, holder0, options)
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{3: big.NewInt(900), 6: big.NewInt(0)}, balances)
+	options.PageNumber = 1
+	balances, err = db.GetERC20Balance(addr, holder0, options)
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(1000)}, balances)
+
+	balances, err = db.GetERC20Balance(addr, unknownAddress, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, balances)
+
+	// holders are sorted by address
+	holders, err := db.GetAllTokenHolders(addr, 3, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder1, holder0}, holders)
+	holders, err = db.GetAllTokenHolders(addr, 2, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder0}, holders)
+	holders, err = db.GetAllTokenHolders(addr, 0, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, holders)
+	holders, err = db.GetAllTokenHolders(addr, 7, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder1, holder0}, holders)
+
+	options = tokenQueryOptions()
+	options.After = holder1.String()
+	holders, err = db.GetAllTokenHolders(addr, 3, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder0}, holders)
+
+	options = tokenQueryOptions()
+	options.PageSize = 1
+	holders, err = db.GetAllTokenHolders(addr, 3, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder1}, holders)
+
+	holders, err = db.GetAllTokenHolders(unknownAddress, 3, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, holders)
+
+	// only non-zero balances are listed
+	holderBalances, err := db.ERC20HolderBalancesAtBlock(addr, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, map[types.Address]*big.Int{holder0: big.NewInt(900), holder1: big.NewInt(100)}, holderBalances)
+	holderBalances, err = db.ERC20HolderBalancesAtBlock(addr, 7)
+	assert.Nil(t, err)
+	assert.Equal(t, map[types.Address]*big.Int{holder1: big.NewInt(100)}, holderBalances)
+	holderBalances, err = db.ERC20HolderBalancesAtBlock(addr, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, holderBalances)
+
+	holderCount, err := db.ERC20HolderCountAtBlock(addr, 3)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, holderCount)
+	holderCount, err = db.ERC20HolderCountAtBlock(addr, 7)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, holderCount)
+	holderCount, err = db.ERC20HolderCountAtBlock(addr, 0)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, holderCount)
+	holderCount, err = db.ERC20HolderCountAtBlock(unknownAddress, 3)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, holderCount)
+
+	// changes are sorted by block and then holder
+	changes, err := db.ERC20BalanceChanges(addr, 0, 6)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.ERC20BalanceChange{
+		{Holder: holder0, BlockNumber: 1, Amount: "1000"},
+		{Holder: holder1, BlockNumber: 3, Amount: "100"},
+		{Holder: holder0, BlockNumber: 3, Amount: "900"},
+		{Holder: holder0, BlockNumber: 6, Amount: "0"},
+	}, changes)
+	changes, err = db.ERC20BalanceChanges(addr, 0, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.ERC20BalanceChange{{Holder: holder0, BlockNumber: 1, Amount: "1000"}}, changes)
+	changes, err = db.ERC20BalanceChanges(addr, 3, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.ERC20BalanceChange{
+		{Holder: holder1, BlockNumber: 3, Amount: "100"},
+		{Holder: holder0, BlockNumber: 3, Amount: "900"},
+	}, changes)
+	changes, err = db.ERC20BalanceChanges(unknownAddress, 0, 6)
+	assert.Nil(t, err)
+	assert.Empty(t, changes)
+}
+
+func testERC20Allowances(t *testing.T, db database.Database) {
+	assert.Nil(t, db.RecordERC20Allowance(addr, holder0, holder1, 1, big.NewInt(100)))
+	assert.Nil(t, db.RecordERC20Allowance(addr, holder0, unknownAddress, 1, big.NewInt(50)))
+	assert.Nil(t, db.RecordERC20Allowance(addr, holder0, holder1, 3, big.NewInt(40)))
+	assert.Nil(t, db.RecordERC20Allowance(addr, holder0, unknownAddress, 4, big.NewInt(0)))
+
+	allowance, err := db.ERC20AllowanceAtBlock(addr, holder0, holder1, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(0), allowance)
+	allowance, err = db.ERC20AllowanceAtBlock(addr, holder0, holder1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(100), allowance)
+	allowance, err = db.ERC20AllowanceAtBlock(addr, holder0, holder1, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(40), allowance)
+	allowance, err = db.ERC20AllowanceAtBlock(addr, holder1, holder0, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(0), allowance)
+
+	// approvals are sorted by spender
+	approvals, err := db.ERC20ApprovalsForOwnerAtBlock(addr, holder0, 3, tokenQueryOptions())
+	assert.Nil(t, err)
+	approvedUntil := uint64(3)
+	assert.Equal(t, []types.ERC20Allowance{
+		{Contract: addr, Owner: holder0, Spender: unknownAddress, Amount: "50", ApprovedFrom: 1, ApprovedUntil: &approvedUntil},
+		{Contract: addr, Owner: holder0, Spender: holder1, Amount: "40", ApprovedFrom: 3},
+	}, approvals)
+
+	options := tokenQueryOptions()
+	options.After = unknownAddress.String()
+	approvals, err = db.ERC20ApprovalsForOwnerAtBlock(addr, holder0, 3, options)
+	assert.Nil(t, err)
+	assert.Len(t, approvals, 1)
+	assert.Equal(t, holder1, approvals[0].Spender)
+
+	// revoked approvals are not listed
+	approvals, err = db.ERC20ApprovalsForOwnerAtBlock(addr, holder0, 4, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Len(t, approvals, 1)
+	assert.Equal(t, holder1, approvals[0].Spender)
+
+	approvals, err = db.ERC20ApprovalsForOwnerAtBlock(addr, holder1, 4, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, approvals)
+}
+
+func testERC721Tokens(t *testing.T, db database.Database) {
+	assert.Nil(t, db.RecordERC721Token(addr, holder0, 1, big.NewInt(256)))
+	assert.Nil(t, db.RecordERC721Token(addr, holder0, 2, big.NewInt(1)))
+	assert.Nil(t, db.RecordERC721Token(addr, holder1, 4, big.NewInt(256)))
+	assert.Nil(t, db.RecordERC721Token(addr, zeroAddress, 5, big.NewInt(1)))
+
+	token, err := db.ERC721TokenByTokenID(addr, 3, big.NewInt(256))
+	assert.Nil(t, err)
+	heldUntil := uint64(3)
+	assert.Equal(t, &types.ERC721Token{Contract: addr, Holder: holder0, Token: "256", HeldFrom: 1, HeldUntil: &heldUntil}, token)
+	token, err = db.ERC721TokenByTokenID(addr, 4, big.NewInt(256))
+	assert.Nil(t, err)
+	assert.Equal(t, &types.ERC721Token{Contract: addr, Holder: holder1, Token: "256", HeldFrom: 4}, token)
+	_, err = db.ERC721TokenByTokenID(addr, 0, big.NewInt(256))
+	assert.Equal(t, database.ErrNotFound, err)
+	_, err = db.ERC721TokenByTokenID(addr, 3, big.NewInt(2))
+	assert.Equal(t, database.ErrNotFound, err)
+
+	// tokens are sorted by token ID
+	tokens, err := db.ERC721TokensForAccountAtBlock(addr, holder0, 3, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 2)
+	assert.Equal(t, "1", tokens[0].Token)
+	assert.Equal(t, "256", tokens[1].Token)
+
+	// tokens that have been transferred away are not held anymore
+	tokens, err = db.ERC721TokensForAccountAtBlock(addr, holder0, 4, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 1)
+	assert.Equal(t, "1", tokens[0].Token)
+
+	tokens, err = db.ERC721TokensForAccountAtBlock(addr, unknownAddress, 4, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, tokens)
+
+	tokens, err = db.AllERC721TokensAtBlock(addr, 4, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 2)
+	assert.Equal(t, "1", tokens[0].Token)
+	assert.Equal(t, holder0, tokens[0].Holder)
+	assert.Equal(t, "256", tokens[1].Token)
+	assert.Equal(t, holder1, tokens[1].Holder)
+
+	options := tokenQueryOptions()
+	options.After = "1"
+	tokens, err = db.AllERC721TokensAtBlock(addr, 4, options)
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 1)
+	assert.Equal(t, "256", tokens[0].Token)
+
+	options = tokenQueryOptions()
+	options.PageSize = 1
+	options.PageNumber = 1
+	tokens, err = db.AllERC721TokensAtBlock(addr, 4, options)
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 1)
+	assert.Equal(t, "256", tokens[0].Token)
+
+	// holders are sorted by address
+	holders, err := db.AllHoldersAtBlock(addr, 4, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder1, holder0}, holders)
+
+	options = tokenQueryOptions()
+	options.After = holder1.String()
+	holders, err = db.AllHoldersAtBlock(addr, 4, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder0}, holders)
+
+	// burnt tokens are held by the zero address
+	holders, err = db.AllHoldersAtBlock(addr, 5, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{zeroAddress, holder1}, holders)
+}
+
+func testTokenPortfolio(t *testing.T, db database.Database) {
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holder0, 1, big.NewInt(1000)))
+	assert.Nil(t, db.RecordNewERC20Balance(uselessAddress, holder0, 2, big.NewInt(50)))
+	assert.Nil(t, db.RecordNewERC20Balance(uselessAddress, holder1, 2, big.NewInt(70)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holder0, 3, big.NewInt(0)))
+	assert.Nil(t, db.RecordERC721Token(uselessAddress, holder0, 1, big.NewInt(7)))
+	assert.Nil(t, db.RecordERC721Token(addr, holder0, 1, big.NewInt(256)))
+	assert.Nil(t, db.RecordERC721Token(addr, holder0, 2, big.NewInt(1)))
+	assert.Nil(t, db.RecordERC721Token(addr, holder1, 3, big.NewInt(256)))
+
+	balances, err := db.AllERC20BalancesForAccountAtBlock(holder0, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, map[types.Address]*big.Int{addr: big.NewInt(1000), uselessAddress: big.NewInt(50)}, balances)
+
+	// only non-zero balances are listed
+	balances, err = db.AllERC20BalancesForAccountAtBlock(holder0, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, map[types.Address]*big.Int{uselessAddress: big.NewInt(50)}, balances)
+	balances, err = db.AllERC20BalancesForAccountAtBlock(unknownAddress, 3)
+	assert.Nil(t, err)
+	assert.Empty(t, balances)
+
+	accountTokenOptions := func(after string, pageSize int) *types.AccountTokenQueryOptions {
+		options := &types.AccountTokenQueryOptions{After: after, PageSize: pageSize}
+		options.SetDefaults()
+		return options
+	}
+
+	// tokens are sorted by contract and then token ID
+	tokens, err := db.AllERC721TokensForAccountAtBlock(holder0, 2, accountTokenOptions("", 0))
+	assert.Nil(t, err)
+	heldUntil := uint64(2)
+	assert.Equal(t, []types.ERC721Token{
+		{Contract: addr, Holder: holder0, Token: "1", HeldFrom: 2},
+		{Contract: addr, Holder: holder0, Token: "256", HeldFrom: 1, HeldUntil: &heldUntil},
+		{Contract: uselessAddress, Holder: holder0, Token: "7", HeldFrom: 1},
+	}, tokens)
+
+	// each page continues after the last token of the previous page
+	tokens, err = db.AllERC721TokensForAccountAtBlock(holder0, 2, accountTokenOptions("", 2))
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 2)
+	tokens, err = db.AllERC721TokensForAccountAtBlock(holder0, 2, accountTokenOptions(types.ERC721TokenCursor(tokens[1]), 2))
+	assert.Nil(t, err)
+	assert.Equal(t, []types.ERC721Token{{Contract: uselessAddress, Holder: holder0, Token: "7", HeldFrom: 1}}, tokens)
+	tokens, err = db.AllERC721TokensForAccountAtBlock(holder0, 2, accountTokenOptions(types.ERC721TokenCursor(tokens[0]), 2))
+	assert.Nil(t, err)
+	assert.Empty(t, tokens)
+
+	// tokens that have been transferred away are not held anymore
+	tokens, err = db.AllERC721TokensForAccountAtBlock(holder0, 3, accountTokenOptions("", 0))
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 2)
+	assert.Equal(t, "1", tokens[0].Token)
+	assert.Equal(t, "7", tokens[1].Token)
+
+	tokens, err = db.AllERC721TokensForAccountAtBlock(unknownAddress, 3, accountTokenOptions("", 0))
+	assert.Nil(t, err)
+	assert.Empty(t, tokens)
+}
+
+func testERC1155Tokens(t *testing.T, db database.Database) {
+	assert.Nil(t, db.RecordERC1155Balance(addr, holder0, 1, big.NewInt(256), big.NewInt(10)))
+	assert.Nil(t, db.RecordERC1155Balance(addr, holder0, 1, big.NewInt(1), big.NewInt(5)))
+	assert.Nil(t, db.RecordERC1155Balance(addr, holder0, 3, big.NewInt(256), big.NewInt(4)))
+	assert.Nil(t, db.RecordERC1155Balance(addr, holder1, 3, big.NewInt(256), big.NewInt(6)))
+	assert.Nil(t, db.RecordERC1155Balance(addr, holder0, 4, big.NewInt(1), big.NewInt(0)))
+
+	balance, err := db.ERC1155BalanceAtBlock(addr, holder0, 2, big.NewInt(256))
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(10), balance)
+	balance, err = db.ERC1155BalanceAtBlock(addr, holder0, 3, big.NewInt(256))
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(4), balance)
+	balance, err = db.ERC1155BalanceAtBlock(addr, holder1, 2, big.NewInt(256))
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(0), balance)
+	balance, err = db.ERC1155BalanceAtBlock(addr, holder0, 4, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(0), balance)
+
+	// tokens are sorted by token ID
+	tokens, err := db.ERC1155TokensForAccountAtBlock(addr, holder0, 3, tokenQueryOptions())
+	assert.Nil(t, err)
+	heldUntil := uint64(3)
+	assert.Equal(t, []types.ERC1155Token{
+		{Contract: addr, Holder: holder0, Token: "1", Amount: "5", HeldFrom: 1, HeldUntil: &heldUntil},
+		{Contract: addr, Holder: holder0, Token: "256", Amount: "4", HeldFrom: 3},
+	}, tokens)
+
+	options := tokenQueryOptions()
+	options.After = "1"
+	tokens, err = db.ERC1155TokensForAccountAtBlock(addr, holder0, 3, options)
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 1)
+	assert.Equal(t, "256", tokens[0].Token)
+
+	// tokens with no balance left are not held anymore
+	tokens, err = db.ERC1155TokensForAccountAtBlock(addr, holder0, 4, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 1)
+	assert.Equal(t, "256", tokens[0].Token)
+
+	tokens, err = db.ERC1155TokensForAccountAtBlock(addr, unknownAddress, 4, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, tokens)
+
+	// holders are sorted by address
+	holders, err := db.ERC1155HoldersAtBlock(addr, 3, big.NewInt(256), tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder1, holder0}, holders)
+
+	options = tokenQueryOptions()
+	options.After = holder1.String()
+	holders, err = db.ERC1155HoldersAtBlock(addr, 3, big.NewInt(256), options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder0}, holders)
+
+	holders, err = db.ERC1155HoldersAtBlock(addr, 4, big.NewInt(1), tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, holders)
+}
+
+func testTokenBalances(t *testing.T, db database.Database) {
+	assert.Nil(t, db.RecordTokenBalance("erc777", addr, holder0, 1, big.NewInt(10)))
+	assert.Nil(t, db.RecordTokenBalance("erc777", addr, holder0, 3, big.NewInt(4)))
+	assert.Nil(t, db.RecordTokenBalance("erc777", addr, holder1, 3, big.NewInt(6)))
+	assert.Nil(t, db.RecordTokenBalance("erc777", addr, holder1, 4, big.NewInt(0)))
+	// the same contract may be tracked by several standards, which are kept apart
+	assert.Nil(t, db.RecordTokenBalance("erc7770", addr, holder0, 2, big.NewInt(99)))
+
+	balance, err := db.TokenBalanceAtBlock("erc777", addr, holder0, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(10), balance)
+	balance, err = db.TokenBalanceAtBlock("erc777", addr, holder0, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(4), balance)
+	balance, err = db.TokenBalanceAtBlock("erc777", addr, holder1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(0), balance)
+	balance, err = db.TokenBalanceAtBlock("erc7770", addr, holder0, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(99), balance)
+	balance, err = db.TokenBalanceAtBlock("other", addr, holder0, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(0), balance)
+
+	// holders are sorted by address
+	holders, err := db.TokenHoldersAtBlock("erc777", addr, 3, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder1, holder0}, holders)
+
+	options := tokenQueryOptions()
+	options.After = holder1.String()
+	holders, err = db.TokenHoldersAtBlock("erc777", addr, 3, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder0}, holders)
+
+	// holders with no balance left are not listed
+	holders, err = db.TokenHoldersAtBlock("erc777", addr, 4, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder0}, holders)
+
+	holders, err = db.TokenHoldersAtBlock("erc7770", addr, 1, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, holders)
+}
+
+func testTokenTransfers(t *testing.T, db database.Database) {
+	// the token moves twice in block 2, and the other token's transfer is interleaved
+	assert.Nil(t, db.RecordTokenTransfers([]types.TokenTransfer{
+		tokenTransfer(1, 3, zeroAddress, holder0, "1"),
+		tokenTransfer(2, 5, holder1, holder0, "1"),
+		tokenTransfer(2, 4, holder0, holder1, "1"),
+		tokenTransfer(2, 6, zeroAddress, holder1, "256"),
+	}))
+	// recording a transfer again replaces it
+	assert.Nil(t, db.RecordTokenTransfers([]types.TokenTransfer{tokenTransfer(1, 3, zeroAddress, holder0, "1")}))
+
+	transfers, err := db.ERC721TokenTransfers(addr, big.NewInt(1), tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TokenTransfer{
+		tokenTransfer(1, 3, zeroAddress, holder0, "1"),
+		tokenTransfer(2, 4, holder0, holder1, "1"),
+		tokenTransfer(2, 5, holder1, holder0, "1"),
+	}, transfers)
+
+	options := tokenQueryOptions()
+	options.BeginBlockNumber = big.NewInt(2)
+	options.PageSize = 1
+	options.PageNumber = 1
+	transfers, err = db.ERC721TokenTransfers(addr, big.NewInt(1), options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TokenTransfer{tokenTransfer(2, 5, holder1, holder0, "1")}, transfers)
+
+	transfers, err = db.ERC721TokenTransfers(addr, big.NewInt(2), tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, transfers)
+}
+
+func testGetTokenTransfers(t *testing.T, db database.Database) {
+	erc20Transfer := func(block uint64, logIndex uint64, from types.Address, to types.Address, amount string) types.TokenTransfer {
+		transfer := tokenTransfer(block, logIndex, from, to, "")
+		transfer.Contract = uselessAddress
+		transfer.Amount = amount
+		return transfer
+	}
+	mint := tokenTransfer(1, 3, zeroAddress, holder0, "1")
+	erc20Mint := erc20Transfer(1, 4, zeroAddress, holder1, "1000")
+	erc20Payment := erc20Transfer(2, 0, holder1, holder0, "100")
+	sale := tokenTransfer(2, 1, holder0, holder1, "1")
+	erc20Refund := erc20Transfer(3, 2, holder0, holder1, "10")
+	assert.Nil(t, db.RecordTokenTransfers([]types.TokenTransfer{mint, sale}))
+	assert.Nil(t, db.RecordTokenTransfers([]types.TokenTransfer{erc20Mint, erc20Payment, erc20Refund}))
+
+	transferOptions := func() *types.TokenTransferQueryOptions {
+		options := &types.TokenTransferQueryOptions{}
+		options.SetDefaults()
+		return options
+	}
+
+	// transfers of all contracts are in the order they took place
+	transfers, err := db.GetTokenTransfers(nil, nil, "", transferOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TokenTransfer{mint, erc20Mint, erc20Payment, sale, erc20Refund}, transfers)
+
+	transfers, err = db.GetTokenTransfers(&uselessAddress, nil, "", transferOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TokenTransfer{erc20Mint, erc20Payment, erc20Refund}, transfers)
+
+	transfers, err = db.GetTokenTransfers(nil, &holder0, "", transferOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TokenTransfer{mint, erc20Payment, sale, erc20Refund}, transfers)
+	transfers, err = db.GetTokenTransfers(nil, &holder0, types.TransferDirectionIn, transferOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TokenTransfer{mint, erc20Payment}, transfers)
+	transfers, err = db.GetTokenTransfers(&uselessAddress, &holder0, types.TransferDirectionOut, transferOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TokenTransfer{erc20Refund}, transfers)
+
+	options := transferOptions()
+	options.BeginBlockNumber = big.NewInt(2)
+	options.EndBlockNumber = big.NewInt(2)
+	transfers, err = db.GetTokenTransfers(nil, nil, "", options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TokenTransfer{erc20Payment, sale}, transfers)
+
+	// the fixture timestamps are 1000 times the block number
+	options = transferOptions()
+	options.BeginTimestamp = big.NewInt(2500)
+	transfers, err = db.GetTokenTransfers(nil, nil, "", options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TokenTransfer{erc20Refund}, transfers)
+
+	// each page continues after the last transfer of the previous page
+	options = transferOptions()
+	options.PageSize = 2
+	transfers, err = db.GetTokenTransfers(nil, nil, "", options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TokenTransfer{mint, erc20Mint}, transfers)
+	options.After = types.TransferCursor(transfers[1])
+	transfers, err = db.GetTokenTransfers(nil, nil, "", options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TokenTransfer{erc20Payment, sale}, transfers)
+	options.After = types.TransferCursor(transfers[1])
+	transfers, err = db.GetTokenTransfers(nil, nil, "", options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TokenTransfer{erc20Refund}, transfers)
+
+	options.After = "not a cursor"
+	_, err = db.GetTokenTransfers(nil, nil, "", options)
+	assert.NotNil(t, err)
+}
+
+func testTokenInfo(t *testing.T, db database.Database) {
+	_, err := db.GetTokenInfo(addr)
+	assert.Equal(t, database.ErrNotFound, err)
+
+	decimals := uint8(18)
+	info := &types.TokenInfo{Contract: addr, Standard: types.TokenStandardERC20, Name: "Token", Symbol: "TKN", Decimals: &decimals}
+	assert.Nil(t, db.RecordTokenInfo(info))
+	retrieved, err := db.GetTokenInfo(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, info, retrieved)
+
+	// tokens that do not declare decimals are kept without
+	info = &types.TokenInfo{Contract: addr, Standard: types.TokenStandardERC721, Name: "NFT"}
+	assert.Nil(t, db.RecordTokenInfo(info))
+	retrieved, err = db.GetTokenInfo(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, info, retrieved)
+}
+
+func testTotalSupply(t *testing.T, db database.Database) {
+	assert.Nil(t, db.RecordTotalSupply(addr, 1, big.NewInt(1000)))
+	assert.Nil(t, db.RecordTotalSupply(addr, 3, big.NewInt(1500)))
+	assert.Nil(t, db.RecordTotalSupply(addr, 5, big.NewInt(500)))
+	assert.Nil(t, db.RecordTotalSupply(uselessAddress, 2, big.NewInt(1)))
+
+	supplies, err := db.GetTotalSupply(addr, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(1000), 3: big.NewInt(1500), 5: big.NewInt(500)}, supplies)
+
+	// the supply at the start of the range is keyed by the starting block
+	options := tokenQueryOptions()
+	options.BeginBlockNumber = big.NewInt(2)
+	options.EndBlockNumber = big.NewInt(4)
+	supplies, err = db.GetTotalSupply(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{2: big.NewInt(1000), 3: big.NewInt(1500)}, supplies)
+
+	options = tokenQueryOptions()
+	options.BeginBlockNumber = big.NewInt(6)
+	supplies, err = db.GetTotalSupply(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{6: big.NewInt(500)}, supplies)
+
+	supplies, err = db.GetTotalSupply(unknownAddress, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, supplies)
+}
+
+// testRewriteTokenBlock checks that recording a token value again at the same block replaces
+// the first one, so a block that is processed again after a failure is not counted twice.
+func testRewriteTokenBlock(t *testing.T, db database.Database) {
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holder0, 1, big.NewInt(10)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holder0, 2, big.NewInt(5)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holder0, 2, big.NewInt(0)))
+	assert.Nil(t, db.RecordERC20Allowance(addr, holder0, holder1, 1, big.NewInt(10)))
+	assert.Nil(t, db.RecordERC20Allowance(addr, holder0, holder1, 2, big.NewInt(5)))
+	assert.Nil(t, db.RecordERC20Allowance(addr, holder0, holder1, 2, big.NewInt(3)))
+	assert.Nil(t, db.RecordTotalSupply(addr, 1, big.NewInt(10)))
+	assert.Nil(t, db.RecordTotalSupply(addr, 2, big.NewInt(5)))
+	assert.Nil(t, db.RecordTotalSupply(addr, 2, big.NewInt(7)))
+	assert.Nil(t, db.RecordERC1155Balance(addr, holder0, 1, big.NewInt(1), big.NewInt(10)))
+	assert.Nil(t, db.RecordERC1155Balance(addr, holder0, 2, big.NewInt(1), big.NewInt(5)))
+	assert.Nil(t, db.RecordERC1155Balance(addr, holder0, 2, big.NewInt(1), big.NewInt(0)))
+	assert.Nil(t, db.RecordTokenBalance("erc777", addr, holder0, 1, big.NewInt(10)))
+	assert.Nil(t, db.RecordTokenBalance("erc777", addr, holder0, 2, big.NewInt(5)))
+	assert.Nil(t, db.RecordTokenBalance("erc777", addr, holder0, 2, big.NewInt(0)))
+	assert.Nil(t, db.RecordERC721Token(addr, holder0, 1, big.NewInt(1)))
+	assert.Nil(t, db.RecordERC721Token(addr, holder0, 2, big.NewInt(1)))
+	assert.Nil(t, db.RecordERC721Token(addr, holder1, 2, big.NewInt(1)))
+
+	balances, err := db.GetERC20Balance(addr, holder0, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(10), 2: big.NewInt(0)}, balances)
+	count, err := db.ERC20HolderCountAtBlock(addr, 2)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, count)
+
+	allowance, err := db.ERC20AllowanceAtBlock(addr, holder0, holder1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(3), allowance)
+
+	supplies, err := db.GetTotalSupply(addr, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(10), 2: big.NewInt(7)}, supplies)
+
+	balance, err := db.ERC1155BalanceAtBlock(addr, holder0, 2, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(0), balance)
+	tokens, err := db.ERC1155TokensForAccountAtBlock(addr, holder0, 2, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, tokens)
+
+	balance, err = db.TokenBalanceAtBlock("erc777", addr, holder0, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(0), balance)
+	holders, err := db.TokenHoldersAtBlock("erc777", addr, 2, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, holders)
+
+	token, err := db.ERC721TokenByTokenID(addr, 2, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, holder1, token.Holder)
+	erc721Tokens, err := db.ERC721TokensForAccountAtBlock(addr, holder0, 2, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, erc721Tokens)
+}
+
+func testRollbackBlocks(t *testing.T, db database.Database) {
+	writeTestChain(t, db)
+	assert.Nil(t, db.SetContractCreationTransaction(map[types.Hash][]types.Address{tx4.Hash: {addr}}))
+	assert.Nil(t, db.IndexStorage(map[types.Address]*types.AccountState{
+		addr: {Root: types.NewHash("0x1234"), Storage: map[types.Hash]string{types.NewHash("0x1"): "0x2a"}},
+	}, 1))
+	assert.Nil(t, db.IndexStorage(map[types.Address]*types.AccountState{
+		addr: {Root: types.NewHash("0x5678"), Storage: map[types.Hash]string{types.NewHash("0x1"): "0x2b"}},
+	}, 2))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holder0, 1, big.NewInt(1000)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holder0, 2, big.NewInt(900)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holder1, 2, big.NewInt(100)))
+	assert.Nil(t, db.RecordERC20Allowance(addr, holder0, holder1, 1, big.NewInt(100)))
+	assert.Nil(t, db.RecordERC20Allowance(addr, holder0, holder1, 2, big.NewInt(0)))
+	assert.Nil(t, db.RecordTotalSupply(addr, 1, big.NewInt(1000)))
+	assert.Nil(t, db.RecordTotalSupply(addr, 2, big.NewInt(1500)))
+	assert.Nil(t, db.RecordERC721Token(addr, holder0, 1, big.NewInt(1)))
+	assert.Nil(t, db.RecordERC721Token(addr, holder1, 2, big.NewInt(1)))
+	assert.Nil(t, db.RecordERC1155Balance(addr, holder0, 1, big.NewInt(1), big.NewInt(10)))
+	assert.Nil(t, db.RecordERC1155Balance(addr, holder0, 2, big.NewInt(1), big.NewInt(4)))
+	assert.Nil(t, db.RecordTokenBalance("erc777", addr, holder0, 1, big.NewInt(10)))
+	assert.Nil(t, db.RecordTokenBalance("erc777", addr, holder0, 2, big.NewInt(0)))
+	assert.Nil(t, db.RecordTokenTransfers([]types.TokenTransfer{
+		tokenTransfer(1, 0, zeroAddress, holder0, "1"),
+		tokenTransfer(2, 0, holder0, holder1, "1"),
+	}))
+	assert.Nil(t, db.RecordNativeTransfers([]types.NativeTransfer{
+		nativeTransfer(1, 0, holder0, addr, "10"),
+		nativeTransfer(2, 0, addr, holder1, "5"),
+	}))
+	assert.Nil(t, db.RecordNativeBalance(addr, 1, big.NewInt(10)))
+	assert.Nil(t, db.RecordNativeBalance(addr, 2, big.NewInt(5)))
+
+	reorgs, err := db.GetChainReorgs()
+	assert.Nil(t, err)
+	assert.Empty(t, reorgs)
+
+	reorg := &types.ChainReorg{CommonAncestor: 1, NewHead: 3, NewHeadHash: types.NewHash("0x1"), DetectedAt: 1000}
+	assert.Nil(t, db.RollbackBlocks(reorg))
+
+	lastPersisted, err := db.GetLastPersistedBlockNumber()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, lastPersisted)
+	lastFiltered, err := db.GetLastFiltered(addr)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, lastFiltered)
+	creationTx, err := db.GetContractCreationTransaction(addr)
+	assert.Nil(t, err)
+	assert.True(t, creationTx.IsEmpty())
+
+	// blocks and transactions after the common ancestor are removed
+	_, err = db.ReadBlock(2)
+	assert.Equal(t, database.ErrNotFound, err)
+	_, err = db.ReadTransaction(tx4.Hash)
+	assert.Equal(t, database.ErrNotFound, err)
+	retrievedBlock, err := db.ReadBlock(1)
+	assert.Nil(t, err)
+	assert.Equal(t, block1, retrievedBlock)
+
+	// along with the data derived from them
+	txs, err := db.GetAllTransactionsToAddress(addr, queryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{tx3.Hash}, txs)
+	events, err := db.GetAllEventsFromAddress(addr, queryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []*types.Event{event1}, events)
+	total, err := db.GetStorageTotal(addr, pageOptions())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, total)
+
+	// superseded token records are held again
+	balances, err := db.GetERC20Balance(addr, holder0, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(1000)}, balances)
+	holders, err := db.GetAllTokenHolders(addr, 5, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder0}, holders)
+	approvals, err := db.ERC20ApprovalsForOwnerAtBlock(addr, holder0, 5, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.ERC20Allowance{{Contract: addr, Owner: holder0, Spender: holder1, Amount: "100", ApprovedFrom: 1}}, approvals)
+	token, err := db.ERC721TokenByTokenID(addr, 5, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, &types.ERC721Token{Contract: addr, Holder: holder0, Token: "1", HeldFrom: 1}, token)
+	erc1155Tokens, err := db.ERC1155TokensForAccountAtBlock(addr, holder0, 5, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.ERC1155Token{{Contract: addr, Holder: holder0, Token: "1", Amount: "10", HeldFrom: 1}}, erc1155Tokens)
+	tokenHolders, err := db.TokenHoldersAtBlock("erc777", addr, 5, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder0}, tokenHolders)
+	transfers, err := db.ERC721TokenTransfers(addr, big.NewInt(1), tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TokenTransfer{tokenTransfer(1, 0, zeroAddress, holder0, "1")}, transfers)
+	supplies, err := db.GetTotalSupply(addr, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(1000)}, supplies)
+	nativeTransfers, err := db.GetNativeTransfers(addr, "", nativeTransferOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.NativeTransfer{nativeTransfer(1, 0, holder0, addr, "10")}, nativeTransfers)
+	nativeBalance, err := db.NativeBalanceAtBlock(addr, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(10), nativeBalance)
+
+	reorgs, err = db.GetChainReorgs()
+	assert.Nil(t, err)
+	assert.Equal(t, []*types.ChainReorg{reorg}, reorgs)
+
+	// the new chain can be written after the rollback
+	assert.Nil(t, db.WriteBlocks([]*types.Block{block2}))
+	lastPersisted, err = db.GetLastPersistedBlockNumber()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, lastPersisted)
+}
+
+func testRollbackTokenRange(t *testing.T, db database.Database) {
+	assert.Nil(t, db.AddAddresses([]types.Address{addr, uselessAddress}))
+	for _, contract := range []types.Address{addr, uselessAddress} {
+		assert.Nil(t, db.RecordNewERC20Balance(contract, holder0, 1, big.NewInt(1000)))
+		assert.Nil(t, db.RecordNewERC20Balance(contract, holder0, 3, big.NewInt(900)))
+		assert.Nil(t, db.RecordNewERC20Balance(contract, holder0, 5, big.NewInt(800)))
+		assert.Nil(t, db.RecordERC20Allowance(contract, holder0, holder1, 1, big.NewInt(100)))
+		assert.Nil(t, db.RecordERC20Allowance(contract, holder0, holder1, 3, big.NewInt(0)))
+		assert.Nil(t, db.RecordTotalSupply(contract, 1, big.NewInt(1000)))
+		assert.Nil(t, db.RecordTotalSupply(contract, 3, big.NewInt(1500)))
+		assert.Nil(t, db.RecordTotalSupply(contract, 5, big.NewInt(2000)))
+		assert.Nil(t, db.RecordERC721Token(contract, holder0, 1, big.NewInt(1)))
+		assert.Nil(t, db.RecordERC721Token(contract, holder1, 3, big.NewInt(1)))
+		assert.Nil(t, db.RecordERC1155Balance(contract, holder0, 1, big.NewInt(1), big.NewInt(10)))
+		assert.Nil(t, db.RecordERC1155Balance(contract, holder0, 3, big.NewInt(1), big.NewInt(4)))
+		assert.Nil(t, db.RecordTokenBalance("erc777", contract, holder0, 1, big.NewInt(10)))
+		assert.Nil(t, db.RecordTokenBalance("erc777", contract, holder0, 3, big.NewInt(0)))
+	}
+	transfer := tokenTransfer(3, 0, holder0, holder1, "1")
+	transfer.Contract = uselessAddress
+	assert.Nil(t, db.RecordTokenTransfers([]types.TokenTransfer{
+		tokenTransfer(1, 0, zeroAddress, holder0, "1"),
+		tokenTransfer(3, 0, holder0, holder1, "1"),
+		tokenTransfer(5, 0, holder1, holder0, "1"),
+		transfer,
+	}))
+	assert.Nil(t, db.RecordTokenInfo(&types.TokenInfo{Contract: addr, Standard: "erc20", Name: "Token"}))
+
+	assert.Nil(t, db.RollbackTokenRange(addr, 2, 4))
+
+	// the token records of the contract in the range are removed, and the records they superseded held again
+	balances, err := db.GetERC20Balance(addr, holder0, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(1000), 5: big.NewInt(800)}, balances)
+	approvals, err := db.ERC20ApprovalsForOwnerAtBlock(addr, holder0, 5, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.ERC20Allowance{{Contract: addr, Owner: holder0, Spender: holder1, Amount: "100", ApprovedFrom: 1}}, approvals)
+	token, err := db.ERC721TokenByTokenID(addr, 5, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, &types.ERC721Token{Contract: addr, Holder: holder0, Token: "1", HeldFrom: 1}, token)
+	erc1155Tokens, err := db.ERC1155TokensForAccountAtBlock(addr, holder0, 5, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.ERC1155Token{{Contract: addr, Holder: holder0, Token: "1", Amount: "10", HeldFrom: 1}}, erc1155Tokens)
+	tokenHolders, err := db.TokenHoldersAtBlock("erc777", addr, 5, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder0}, tokenHolders)
+	transfers, err := db.ERC721TokenTransfers(addr, big.NewInt(1), tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []types.TokenTransfer{tokenTransfer(1, 0, zeroAddress, holder0, "1"), tokenTransfer(5, 0, holder1, holder0, "1")}, transfers)
+	supplies, err := db.GetTotalSupply(addr, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(1000), 5: big.NewInt(2000)}, supplies)
+
+	// token metadata is kept
+	info, err := db.GetTokenInfo(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, "Token", info.Name)
+
+	// while the records of other contracts are untouched
+	balances, err = db.GetERC20Balance(uselessAddress, holder0, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(1000), 3: big.NewInt(900), 5: big.NewInt(800)}, balances)
+	token, err = db.ERC721TokenByTokenID(uselessAddress, 5, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, holder1, token.Holder)
+	tokenHolders, err = db.TokenHoldersAtBlock("erc777", uselessAddress, 5, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, tokenHolders)
+	transfers, err = db.ERC721TokenTransfers(uselessAddress, big.NewInt(1), tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TokenTransfer{transfer}, transfers)
+	supplies, err = db.GetTotalSupply(uselessAddress, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(1000), 3: big.NewInt(1500), 5: big.NewInt(2000)}, supplies)
+}
+
+func testProxyImplementations(t *testing.T, db database.Database) {
+	assert.Nil(t, db.AddAddresses([]types.Address{addr}))
+
+	implementations, err := db.GetProxyImplementations(addr)
+	assert.Nil(t, err)
+	assert.Empty(t, implementations)
+
+	first := &types.ProxyImplementation{Implementation: holder0, Standard: "eip1967", BlockNumber: 5}
+	second := &types.ProxyImplementation{Implementation: holder1, Standard: "eip1967", BlockNumber: 2}
+	assert.Nil(t, db.RecordProxyImplementation(addr, first))
+	assert.Nil(t, db.RecordProxyImplementation(addr, second))
+	assert.Nil(t, db.RecordProxyImplementation(uselessAddress, first))
+
+	// implementations are returned in block order
+	implementations, err = db.GetProxyImplementations(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, []*types.ProxyImplementation{second, first}, implementations)
+
+	// recording an implementation at the same block replaces it
+	replacement := &types.ProxyImplementation{Implementation: uselessAddress, Standard: "eip1822", BlockNumber: 5}
+	assert.Nil(t, db.RecordProxyImplementation(addr, replacement))
+	implementations, err = db.GetProxyImplementations(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, []*types.ProxyImplementation{second, replacement}, implementations)
+
+	// implementations after the common ancestor are rolled back
+	assert.Nil(t, db.RollbackBlocks(&types.ChainReorg{CommonAncestor: 4, NewHead: 6, NewHeadHash: types.NewHash("0x1"), DetectedAt: 1000}))
+	implementations, err = db.GetProxyImplementations(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, []*types.ProxyImplementation{second}, implementations)
+
+	// and removed with the address
+	assert.Nil(t, db.DeleteAddress(addr))
+	implementations, err = db.GetProxyImplementations(addr)
+	assert.Nil(t, err)
+	assert.Empty(t, implementations)
+}
+
+func testBackfillJobs(t *testing.T, db database.Database) {
+	assert.Nil(t, db.AddAddresses([]types.Address{addr, uselessAddress}))
+
+	jobs, err := db.GetBackfillJobs()
+	assert.Nil(t, err)
+	assert.Empty(t, jobs)
+	_, err = db.GetBackfillJob(addr)
+	assert.Equal(t, database.ErrNotFound, err)
+
+	running := &types.BackfillJob{Contract: uselessAddress, FromBlock: 1, ToBlock: 20, NextBlock: 1, Status: types.BackfillRunning}
+	started := &types.BackfillJob{Contract: addr, FromBlock: 5, ToBlock: 10, NextBlock: 5, Status: types.BackfillRunning}
+	assert.Nil(t, db.RecordBackfillJob(running))
+	assert.Nil(t, db.RecordBackfillJob(started))
+
+	// recording the job of a contract again replaces it
+	completed := &types.BackfillJob{Contract: addr, FromBlock: 5, ToBlock: 10, NextBlock: 11, Status: types.BackfillCompleted}
+	assert.Nil(t, db.RecordBackfillJob(completed))
+	job, err := db.GetBackfillJob(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, completed, job)
+
+	// jobs are returned in contract order
+	jobs, err = db.GetBackfillJobs()
+	assert.Nil(t, err)
+	assert.Equal(t, []*types.BackfillJob{completed, running}, jobs)
+
+	// and removed with the address
+	assert.Nil(t, db.DeleteAddress(addr))
+	_, err = db.GetBackfillJob(addr)
+	assert.Equal(t, database.ErrNotFound, err)
+	jobs, err = db.GetBackfillJobs()
+	assert.Nil(t, err)
+	assert.Equal(t, []*types.BackfillJob{running}, jobs)
+}
+
+func nativeTransferOptions() *types.TokenTransferQueryOptions {
+	options := &types.TokenTransferQueryOptions{}
+	options.SetDefaults()
+	return options
+}
+
+func testNativeTransfers(t *testing.T, db database.Database) {
+	deposit := nativeTransfer(1, 0, holder0, addr, "1000")
+	payment := nativeTransfer(1, 1, addr, holder1, "100")
+	other := nativeTransfer(2, 0, holder0, holder1, "5")
+	selfTransfer := nativeTransfer(2, 1, addr, addr, "1")
+	refund := nativeTransfer(3, 0, holder1, addr, "10")
+	assert.Nil(t, db.RecordNativeTransfers([]types.NativeTransfer{deposit, payment, other}))
+	assert.Nil(t, db.RecordNativeTransfers([]types.NativeTransfer{selfTransfer, refund}))
+
+	// recording a transfer again replaces it
+	refund.Value = "20"
+	assert.Nil(t, db.RecordNativeTransfers([]types.NativeTransfer{refund}))
+
+	transfers, err := db.GetNativeTransfers(addr, "", nativeTransferOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.NativeTransfer{deposit, payment, selfTransfer, refund}, transfers)
+	transfers, err = db.GetNativeTransfers(addr, types.TransferDirectionIn, nativeTransferOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.NativeTransfer{deposit, selfTransfer, refund}, transfers)
+	transfers, err = db.GetNativeTransfers(addr, types.TransferDirectionOut, nativeTransferOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.NativeTransfer{payment, selfTransfer}, transfers)
+	transfers, err = db.GetNativeTransfers(uselessAddress, "", nativeTransferOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, transfers)
+
+	options := nativeTransferOptions()
+	options.BeginBlockNumber = big.NewInt(2)
+	options.EndBlockNumber = big.NewInt(2)
+	transfers, err = db.GetNativeTransfers(holder1, "", options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.NativeTransfer{other}, transfers)
+
+	// the fixture timestamps are 1000 times the block number
+	options = nativeTransferOptions()
+	options.BeginTimestamp = big.NewInt(2500)
+	transfers, err = db.GetNativeTransfers(addr, "", options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.NativeTransfer{refund}, transfers)
+
+	// each page continues after the last transfer of the previous page
+	options = nativeTransferOptions()
+	options.PageSize = 2
+	transfers, err = db.GetNativeTransfers(addr, "", options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.NativeTransfer{deposit, payment}, transfers)
+	options.After = types.NativeTransferCursor(transfers[1])
+	transfers, err = db.GetNativeTransfers(addr, "", options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.NativeTransfer{selfTransfer, refund}, transfers)
+
+	options.After = "not a cursor"
+	_, err = db.GetNativeTransfers(addr, "", options)
+	assert.NotNil(t, err)
+}
+
+func testNativeBalances(t *testing.T, db database.Database) {
+	assert.Nil(t, db.AddAddresses([]types.Address{addr, uselessAddress}))
+
+	_, err := db.NativeBalanceAtBlock(addr, 10)
+	assert.Equal(t, database.ErrNotFound, err)
+
+	assert.Nil(t, db.RecordNativeBalance(addr, 2, big.NewInt(1000)))
+	assert.Nil(t, db.RecordNativeBalance(addr, 5, big.NewInt(900)))
+	assert.Nil(t, db.RecordNativeBalance(addr, 8, big.NewInt(1)))
+	assert.Nil(t, db.RecordNativeBalance(uselessAddress, 3, big.NewInt(7)))
+	// recording the balance at the same block again replaces it
+	assert.Nil(t, db.RecordNativeBalance(addr, 8, big.NewInt(800)))
+
+	_, err = db.NativeBalanceAtBlock(addr, 1)
+	assert.Equal(t, database.ErrNotFound, err)
+	balance, err := db.NativeBalanceAtBlock(addr, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(1000), balance)
+	balance, err = db.NativeBalanceAtBlock(addr, 8)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(800), balance)
+
+	balances, err := db.GetNativeBalance(addr, tokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{2: big.NewInt(1000), 5: big.NewInt(900), 8: big.NewInt(800)}, balances)
+
+	// the balance at the start of the range is keyed by the starting block
+	options := tokenQueryOptions()
+	options.BeginBlockNumber = big.NewInt(4)
+	options.EndBlockNumber = big.NewInt(6)
+	balances, err = db.GetNativeBalance(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{4: big.NewInt(1000), 5: big.NewInt(900)}, balances)
+	options.BeginBlockNumber = big.NewInt(5)
+	balances, err = db.GetNativeBalance(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{5: big.NewInt(900)}, balances)
+
+	// balances are removed with the address
+	assert.Nil(t, db.DeleteAddress(addr))
+	_, err = db.NativeBalanceAtBlock(addr, 10)
+	assert.Equal(t, database.ErrNotFound, err)
+	balance, err = db.NativeBalanceAtBlock(uselessAddress, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(7), balance)
+}
+
+func testTransactionFailures(t *testing.T, db database.Database) {
+	assert.Nil(t, db.AddAddresses([]types.Address{addr, uselessAddress}))
+
+	failure := func(address types.Address, block uint64, index uint64, reason string) types.TransactionFailure {
+		return types.TransactionFailure{
+			Address:         address,
+			TransactionHash: types.NewHash(fmt.Sprintf("0x%x%x", block, index)),
+			BlockNumber:     block,
+			Index:           index,
+			Timestamp:       block * 100,
+			Error:           "execution reverted",
+			ErrorSig:        "Error(string)",
+			Reason:          reason,
+			RevertData:      tx5.RevertData,
+		}
+	}
+	first := failure(addr, 2, 0, "not owner")
+	second := failure(addr, 2, 1, "paused")
+	third := failure(addr, 5, 0, "not owner")
+	assert.Nil(t, db.RecordTransactionFailures([]types.TransactionFailure{first, failure(addr, 2, 1, ""), third}))
+	assert.Nil(t, db.RecordTransactionFailures([]types.TransactionFailure{failure(uselessAddress, 3, 0, "not owner")}))
+	// recording the failure of the same transaction again replaces it
+	assert.Nil(t, db.RecordTransactionFailures([]types.TransactionFailure{second}))
+
+	failures, err := db.GetTransactionFailures(addr, "", pageOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TransactionFailure{third, second, first}, failures)
+
+	failures, err = db.GetTransactionFailures(addr, "not owner", pageOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TransactionFailure{third, first}, failures)
+
+	options := pageOptions()
+	options.BeginBlockNumber = big.NewInt(3)
+	failures, err = db.GetTransactionFailures(addr, "", options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TransactionFailure{third}, failures)
+
+	options = pageOptions()
+	options.PageSize = 2
+	options.PageNumber = 1
+	failures, err = db.GetTransactionFailures(addr, "", options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TransactionFailure{first}, failures)
+
+	// failures after the common ancestor are rolled back
+	assert.Nil(t, db.RollbackBlocks(&types.ChainReorg{CommonAncestor: 4, NewHead: 6, NewHeadHash: types.NewHash("0x1"), DetectedAt: 1000}))
+	failures, err = db.GetTransactionFailures(addr, "", pageOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TransactionFailure{second, first}, failures)
+
+	// and removed with the address
+	assert.Nil(t, db.DeleteAddress(addr))
+	failures, err = db.GetTransactionFailures(addr, "", pageOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, failures)
+	failures, err = db.GetTransactionFailures(uselessAddress, "", pageOptions())
+	assert.Nil(t, err)
+	assert.Len(t, failures, 1)
+}
@@ -0,0 +1,246 @@
+package conformance
+
+import (
+	"fmt"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// All fixtures set every field explicitly, including zero values, so that they compare equal
+// after a round trip through backends that serialise the data.
+var (
+	addr           = types.NewAddress("0x0000000000000000000000000000000000000001")
+	uselessAddress = types.NewAddress("0x0000000000000000000000000000000000000002")
+	unknownAddress = types.NewAddress("0x0000000000000000000000000000000000000003")
+	zeroAddress    = types.NewAddress("")
+
+	holder0 = types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+	holder1 = types.NewAddress("0xca843569e3427144cead5e4d5999a3d0ccf92b8e")
+
+	block1Hash = types.NewHash("0x4b603921305ebaa48d863b9f577059a63c653cd8e952372622923708fb657806")
+	block2Hash = types.NewHash("0x5cde17410e3bb729f745870e166a767bcf07287c0f80bbcb38303eba8dbe5053")
+
+	event1 = &types.Event{
+		Index:            0,
+		Address:          addr,
+		Topics:           []types.Hash{types.NewHash("0xe1fffcc4923d04b559f4d29a8bfc6cda04eb5b0d3c460751c2402c5c5cc9109c")},
+		Data:             "",
+		BlockNumber:      1,
+		BlockHash:        block1Hash,
+		TransactionHash:  types.NewHash("0xb2d58900a820afddd1d926845e7655d445885524b9af1cc946b45949be74cc08"),
+		TransactionIndex: 2,
+		Timestamp:        100,
+	}
+	event2 = &types.Event{
+		Index:            0,
+		Address:          addr,
+		Topics:           []types.Hash{types.NewHash("0xe1fffcc4923d04b559f4d29a8bfc6cda04eb5b0d3c460751c2402c5c5cc9109c")},
+		Data:             "",
+		BlockNumber:      2,
+		BlockHash:        block2Hash,
+		TransactionHash:  types.NewHash("0x5c83fa5955aff33c61813105851777bcd2adc85deb9af6286ba42c05cd768de0"),
+		TransactionIndex: 0,
+		Timestamp:        200,
+	}
+	event3 = &types.Event{
+		Index:            1,
+		Address:          addr,
+		Topics:           []types.Hash{types.NewHash("0x7fcf532c15f0a6db0bd6d0e038bea71d30d808c7d98cb3bf7268a95bf5081b65")},
+		Data:             "",
+		BlockNumber:      2,
+		BlockHash:        block2Hash,
+		TransactionHash:  types.NewHash("0x8ac2d8d6eb3aabcb1cd4fa3ec0a4c35f9ea15e0d4ea9e4c3e8cd1a0e0e0e1b01"),
+		TransactionIndex: 1,
+		Timestamp:        200,
+	}
+
+	tx1 = &types.Transaction{
+		Hash:              types.NewHash("0x1a6f4292bac138df9a7854a07c93fd14ca7de53265e8fe01b6c986f97d6c1ee7"),
+		BlockNumber:       1,
+		BlockHash:         block1Hash,
+		Index:             0,
+		From:              types.NewAddress("0x0000000000000000000000000000000000000009"),
+		To:                zeroAddress,
+		Value:             amount("0"),
+		GasPrice:          amount("20000000000000000000"),
+		CreatedContract:   addr,
+		Type:              types.LegacyTxType,
+		EffectiveGasPrice: amount("20000000000000000000"),
+		Timestamp:         100,
+	}
+	tx2 = &types.Transaction{
+		Hash:              types.NewHash("0xbc77a72b3409ba3e098cb45bac1b7727b59dae9a05f37a0dbc61007949c8cede"),
+		BlockNumber:       1,
+		BlockHash:         block1Hash,
+		Index:             1,
+		From:              types.NewAddress("0x0000000000000000000000000000000000000009"),
+		To:                uselessAddress,
+		Value:             amount("0"),
+		GasPrice:          amount("20000000000000000000"),
+		CreatedContract:   zeroAddress,
+		Type:              types.LegacyTxType,
+		ChainID:           amount("10"),
+		EffectiveGasPrice: amount("20000000000000000000"),
+		Timestamp:         100,
+		InternalCalls: []*types.InternalCall{
+			{
+				From:   uselessAddress,
+				To:     addr,
+				Value:  amount("0"),
+				Type:   "CALL",
+				Depth:  1,
+				Parent: -1,
+				Path:   []int{0},
+			},
+			{
+				From:         addr,
+				To:           unknownAddress,
+				Value:        amount("5"),
+				Input:        types.NewHexData("0x12345678"),
+				Output:       types.NewHexData("0x08c379a0000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000000096e6f74206f776e65720000000000000000000000000000000000000000000000"),
+				Type:         "CALL",
+				Depth:        2,
+				Parent:       0,
+				Path:         []int{0, 0},
+				Error:        "execution reverted",
+				RevertReason: "not owner",
+			},
+		},
+	}
+	tx3 = &types.Transaction{
+		Hash:            types.NewHash("0xb2d58900a820afddd1d926845e7655d445885524b9af1cc946b45949be74cc08"),
+		BlockNumber:     1,
+		BlockHash:       block1Hash,
+		Index:           2,
+		From:            types.NewAddress("0x0000000000000000000000000000000000000010"),
+		To:              addr,
+		Value:           amount("1000"),
+		GasPrice:        amount("20000000000000000000"),
+		CreatedContract: zeroAddress,
+		Type:            types.AccessListTxType,
+		ChainID:         amount("10"),
+		AccessList: []types.AccessTuple{
+			{Address: addr, StorageKeys: []types.Hash{types.NewHash("0x0000000000000000000000000000000000000000000000000000000000000001")}},
+		},
+		EffectiveGasPrice: amount("20000000000000000000"),
+		Timestamp:         100,
+		Events:            []*types.Event{event1},
+	}
+	tx4 = &types.Transaction{
+		Hash:                 types.NewHash("0x5c83fa5955aff33c61813105851777bcd2adc85deb9af6286ba42c05cd768de0"),
+		BlockNumber:          2,
+		BlockHash:            block2Hash,
+		Index:                0,
+		From:                 types.NewAddress("0x0000000000000000000000000000000000000010"),
+		To:                   addr,
+		Value:                amount("1000000000000000000000"),
+		GasPrice:             amount("20000000000000000000"),
+		CreatedContract:      zeroAddress,
+		Type:                 types.DynamicFeeTxType,
+		ChainID:              amount("10"),
+		AccessList:           []types.AccessTuple{{Address: uselessAddress, StorageKeys: []types.Hash{}}},
+		MaxFeePerGas:         amount("30000000000000000000"),
+		MaxPriorityFeePerGas: amount("2000000000"),
+		EffectiveGasPrice:    amount("20000000000000000000"),
+		Timestamp:            200,
+		Events:               []*types.Event{event2},
+	}
+	tx5 = &types.Transaction{
+		Hash:                 types.NewHash("0x8ac2d8d6eb3aabcb1cd4fa3ec0a4c35f9ea15e0d4ea9e4c3e8cd1a0e0e0e1b01"),
+		BlockNumber:          2,
+		BlockHash:            block2Hash,
+		Index:                1,
+		From:                 types.NewAddress("0x0000000000000000000000000000000000000010"),
+		To:                   addr,
+		Value:                amount("5"),
+		GasPrice:             amount("20000000000000000000"),
+		CreatedContract:      zeroAddress,
+		Type:                 types.DynamicFeeTxType,
+		ChainID:              amount("10"),
+		MaxFeePerGas:         amount("20000000000000000000"),
+		MaxPriorityFeePerGas: amount("2000000000"),
+		EffectiveGasPrice:    amount("20000000000000000000"),
+		Timestamp:            200,
+		Events:               []*types.Event{event3},
+		Error:                "execution reverted",
+		// Error("not owner")
+		RevertData: types.NewHexData("0x08c379a0" +
+			"0000000000000000000000000000000000000000000000000000000000000020" +
+			"0000000000000000000000000000000000000000000000000000000000000009" +
+			"6e6f74206f776e65720000000000000000000000000000000000000000000000"),
+	}
+
+	block1 = &types.Block{
+		Hash:         block1Hash,
+		ParentHash:   types.NewHash(""),
+		StateRoot:    types.NewHash(""),
+		TxRoot:       types.NewHash(""),
+		ReceiptRoot:  types.NewHash(""),
+		Number:       1,
+		Timestamp:    100,
+		Transactions: []types.Hash{tx1.Hash, tx2.Hash, tx3.Hash},
+	}
+	block2 = &types.Block{
+		Hash:         block2Hash,
+		ParentHash:   block1Hash,
+		StateRoot:    types.NewHash(""),
+		TxRoot:       types.NewHash(""),
+		ReceiptRoot:  types.NewHash(""),
+		Number:       2,
+		Timestamp:    200,
+		Transactions: []types.Hash{tx4.Hash, tx5.Hash},
+	}
+)
+
+// amount parses a decimal amount, which may not fit in a uint64
+func amount(value string) *types.BigInt {
+	parsed, err := types.ParseBigInt(value)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+func queryOptions() *types.QueryOptions {
+	options := &types.QueryOptions{}
+	options.SetDefaults()
+	return options
+}
+
+func pageOptions() *types.PageOptions {
+	options := &types.PageOptions{}
+	options.SetDefaults()
+	return options
+}
+
+func tokenQueryOptions() *types.TokenQueryOptions {
+	options := &types.TokenQueryOptions{}
+	options.SetDefaults()
+	return options
+}
+
+func tokenTransfer(block uint64, logIndex uint64, from types.Address, to types.Address, tokenId string) types.TokenTransfer {
+	return types.TokenTransfer{
+		Contract:        addr,
+		From:            from,
+		To:              to,
+		TokenId:         tokenId,
+		BlockNumber:     block,
+		TransactionHash: types.NewHash(fmt.Sprintf("0x%x", block*100+logIndex)),
+		LogIndex:        logIndex,
+		Timestamp:       block * 1000,
+	}
+}
+
+func nativeTransfer(block uint64, index uint64, from types.Address, to types.Address, value string) types.NativeTransfer {
+	return types.NativeTransfer{
+		From:            from,
+		To:              to,
+		Value:           value,
+		Type:            "CALL",
+		BlockNumber:     block,
+		TransactionHash: types.NewHash(fmt.Sprintf("0x%x", block*100+index)),
+		Index:           index,
+		Timestamp:       block * 1000,
+	}
+}
@@ -0,0 +1,60 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// BackfillDB
+func (es *ElasticsearchDB) RecordBackfillJob(job *types.BackfillJob) error {
+	req := esapi.IndexRequest{
+		Index:      BackfillIndex,
+		DocumentID: job.Contract.String(),
+		Body:       esutil.NewJSONReader(job),
+		Refresh:    "true",
+	}
+	_, err := es.apiClient.DoRequest(req)
+	return err
+}
+
+func (es *ElasticsearchDB) GetBackfillJob(contract types.Address) (*types.BackfillJob, error) {
+	fetchReq := esapi.GetRequest{
+		Index:      BackfillIndex,
+		DocumentID: contract.String(),
+	}
+
+	body, err := es.apiClient.DoRequest(fetchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobResult BackfillJobQueryResult
+	if err = json.Unmarshal(body, &jobResult); err != nil {
+		return nil, err
+	}
+	return &jobResult.Source, nil
+}
+
+func (es *ElasticsearchDB) GetBackfillJobs() ([]*types.BackfillJob, error) {
+	results, err := es.apiClient.ScrollAllResults(BackfillIndex, QueryAllBackfillJobsTemplate)
+	if err != nil {
+		return nil, errors.New("error fetching backfill jobs: " + err.Error())
+	}
+	jobs := make([]*types.BackfillJob, len(results))
+	for i, result := range results {
+		marshalled, _ := json.Marshal(result)
+		var jobResult BackfillJobQueryResult
+		if err := json.Unmarshal(marshalled, &jobResult); err != nil {
+			return nil, err
+		}
+		jobs[i] = &jobResult.Source
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Contract < jobs[j].Contract })
+	return jobs, nil
+}
@@ -592,3 +592,188 @@ func TestElasticsearchDB_GetLastPersistedBlockNumber_DeletingContractData_WithEr
 	assert.EqualValues(t, 0, lastNum)
 	assert.Len(t, db.deleteQueue, 1)
 }
+
+// expectRollbackProgress sets up the lowering of lastPersisted and lastFiltered that starts
+// every rollback to common ancestor 9
+func expectRollbackProgress(mockedClient *elasticsearch_mocks.MockAPIClient) []*gomock.Call {
+	lastPersistedRequest := esapi.GetRequest{
+		Index:      MetaIndex,
+		DocumentID: "lastPersisted",
+	}
+	updateLastPersistedRequest := esapi.IndexRequest{
+		Index:      MetaIndex,
+		DocumentID: "lastPersisted",
+		Body:       strings.NewReader(`{"lastPersisted": 9}`),
+	}
+	lastFilteredRequest := esapi.UpdateByQueryRequest{
+		Index: []string{ContractIndex},
+		Body:  strings.NewReader(fmt.Sprintf(RollbackLastFilteredQueryTemplate, 9, 9)),
+	}
+
+	return []*gomock.Call{
+		mockedClient.EXPECT().
+			DoRequest(NewGetRequestMatcher(lastPersistedRequest)).
+			Return([]byte(`{"_source": {"lastPersisted": 11}}`), nil),
+		mockedClient.EXPECT().DoRequest(NewIndexRequestMatcher(updateLastPersistedRequest)),
+		mockedClient.EXPECT().DoRequest(NewUpdateByQueryRequestMatcher(lastFilteredRequest)),
+	}
+}
+
+func TestElasticsearchDB_RollbackBlocks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedClient := elasticsearch_mocks.NewMockAPIClient(ctrl)
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	db, _ := New(mockedClient)
+
+	reorg := &types.ChainReorg{
+		CommonAncestor: 9,
+		NewHead:        11,
+		NewHeadHash:    types.NewHash("0x4b603921305ebaa48d863b9f577059a63c653cd8e952372622923708fb657806"),
+		DetectedAt:     1000,
+	}
+	orphanedTx := map[string]interface{}{"_source": map[string]interface{}{"hash": "0xabcd"}}
+
+	calls := append(expectRollbackProgress(mockedClient),
+		mockedClient.EXPECT().
+			ScrollAllResults(TransactionIndex, fmt.Sprintf(QueryOrphanedTransactionsTemplate, 9)).
+			Return([]interface{}{orphanedTx}, nil),
+		mockedClient.EXPECT().DoRequest(NewUpdateByQueryRequestMatcher(esapi.UpdateByQueryRequest{
+			Index: []string{ContractIndex},
+			Body:  strings.NewReader(fmt.Sprintf(ClearCreationTxQueryTemplate, `"0xabcd"`)),
+		})),
+	)
+
+	// every index holding block data must be rolled back
+	deletions := []struct {
+		index string
+		field string
+	}{
+		{BlockIndex, "number"},
+		{TransactionIndex, "blockNumber"},
+		{EventIndex, "blockNumber"},
+		{StorageIndex, "blockNumber"},
+		{ERC20TokenIndex, "blockNumber"},
+		{ERC721TokenIndex, "heldFrom"},
+		{ERC1155TokenIndex, "heldFrom"},
+		{TokenBalanceIndex, "heldFrom"},
+		{TokenTransferIndex, "blockNumber"},
+		{ERC20AllowanceIndex, "approvedFrom"},
+		{TotalSupplyIndex, "blockNumber"},
+		{ProxyIndex, "blockNumber"},
+		{NativeTransferIndex, "blockNumber"},
+		{NativeBalanceIndex, "blockNumber"},
+		{FailureIndex, "blockNumber"},
+	}
+	for _, deletion := range deletions {
+		calls = append(calls, mockedClient.EXPECT().DoRequest(NewDeleteByQueryRequestMatcher(esapi.DeleteByQueryRequest{
+			Index: []string{deletion.index},
+			Body:  strings.NewReader(fmt.Sprintf(DeleteAboveBlockQueryTemplate, deletion.field, 9)),
+		})))
+	}
+
+	calls = append(calls,
+		mockedClient.EXPECT().DoRequest(NewUpdateByQueryRequestMatcher(esapi.UpdateByQueryRequest{
+			Index: []string{ERC20TokenIndex, ERC721TokenIndex, ERC1155TokenIndex, TokenBalanceIndex},
+			Body:  strings.NewReader(fmt.Sprintf(ReopenHeldUntilQueryTemplate, 9)),
+		})),
+		mockedClient.EXPECT().DoRequest(NewUpdateByQueryRequestMatcher(esapi.UpdateByQueryRequest{
+			Index: []string{ERC20AllowanceIndex},
+			Body:  strings.NewReader(fmt.Sprintf(ReopenUntilQueryTemplate, "approvedUntil", "approvedUntil", 9)),
+		})),
+		mockedClient.EXPECT().DoRequest(NewUpdateByQueryRequestMatcher(esapi.UpdateByQueryRequest{
+			Index: []string{TotalSupplyIndex},
+			Body:  strings.NewReader(fmt.Sprintf(ReopenUntilQueryTemplate, "supplyUntil", "supplyUntil", 9)),
+		})),
+		mockedClient.EXPECT().DoRequest(NewIndexRequestMatcher(esapi.IndexRequest{
+			Index:      ReorgIndex,
+			DocumentID: "11-0x4b603921305ebaa48d863b9f577059a63c653cd8e952372622923708fb657806",
+			Body:       strings.NewReader(`{"commonAncestor":9,"newHead":11,"newHeadHash":"0x4b603921305ebaa48d863b9f577059a63c653cd8e952372622923708fb657806","detectedAt":1000}` + "\n"),
+		})),
+	)
+	gomock.InOrder(calls...)
+
+	err := db.RollbackBlocks(reorg)
+
+	assert.Nil(t, err)
+}
+
+func TestElasticsearchDB_RollbackBlocks_ErrorFetchingOrphanedTransactions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedClient := elasticsearch_mocks.NewMockAPIClient(ctrl)
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	gomock.InOrder(append(expectRollbackProgress(mockedClient),
+		mockedClient.EXPECT().
+			ScrollAllResults(TransactionIndex, fmt.Sprintf(QueryOrphanedTransactionsTemplate, 9)).
+			Return(nil, errors.New("test error")),
+	)...)
+
+	db, _ := New(mockedClient)
+
+	err := db.RollbackBlocks(&types.ChainReorg{CommonAncestor: 9, NewHead: 11})
+
+	assert.EqualError(t, err, "error fetching orphaned transactions: test error")
+}
+
+func TestElasticsearchDB_RollbackBlocks_ErrorDeletingBlocks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedClient := elasticsearch_mocks.NewMockAPIClient(ctrl)
+
+	deleteBlocksRequest := esapi.DeleteByQueryRequest{
+		Index: []string{BlockIndex},
+		Body:  strings.NewReader(`{ "query": { "range": { "number": { "gt": 9 } } } }`),
+	}
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	gomock.InOrder(append(expectRollbackProgress(mockedClient),
+		mockedClient.EXPECT().
+			ScrollAllResults(TransactionIndex, fmt.Sprintf(QueryOrphanedTransactionsTemplate, 9)).
+			Return(make([]interface{}, 0), nil),
+		mockedClient.EXPECT().
+			DoRequest(NewDeleteByQueryRequestMatcher(deleteBlocksRequest)).
+			Return(nil, errors.New("test error")),
+	)...)
+
+	db, _ := New(mockedClient)
+
+	err := db.RollbackBlocks(&types.ChainReorg{CommonAncestor: 9, NewHead: 11})
+
+	assert.EqualError(t, err, "test error")
+}
+
+func TestElasticsearchDB_GetChainReorgs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sampleReturnValue := `{"_source": {"commonAncestor": 9, "newHead": 11, "newHeadHash": "0x4b603921305ebaa48d863b9f577059a63c653cd8e952372622923708fb657806", "detectedAt": 1000}}`
+	var asInterface map[string]interface{}
+	_ = json.Unmarshal([]byte(sampleReturnValue), &asInterface)
+
+	mockedClient := elasticsearch_mocks.NewMockAPIClient(ctrl)
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().
+		ScrollAllResults(ReorgIndex, QueryAllChainReorgsTemplate).
+		Return([]interface{}{asInterface}, nil)
+
+	db, _ := New(mockedClient)
+
+	reorgs, err := db.GetChainReorgs()
+
+	expected := []*types.ChainReorg{
+		{
+			CommonAncestor: 9,
+			NewHead:        11,
+			NewHeadHash:    types.NewHash("0x4b603921305ebaa48d863b9f577059a63c653cd8e952372622923708fb657806"),
+			DetectedAt:     1000,
+		},
+	}
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, expected, reorgs)
+}
@@ -0,0 +1,91 @@
+package elasticsearch
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/database/conformance"
+	"quorumengineering/quorum-report/types"
+)
+
+// conformanceDB adapts the Elasticsearch database to the synchronous use of the conformance
+// suite. Bulk writes are made visible to searches straight away, and deletions are serviced
+// immediately instead of waiting for the block monitor to poll the last persisted block.
+type conformanceDB struct {
+	*ElasticsearchDB
+	client *elasticsearch7.Client
+}
+
+func (db *conformanceDB) refresh() {
+	_, _ = db.client.Indices.Refresh()
+}
+
+func (db *conformanceDB) WriteBlocks(blocks []*types.Block) error {
+	defer db.refresh()
+	return db.ElasticsearchDB.WriteBlocks(blocks)
+}
+
+func (db *conformanceDB) WriteTransactions(transactions []*types.Transaction) error {
+	defer db.refresh()
+	return db.ElasticsearchDB.WriteTransactions(transactions)
+}
+
+func (db *conformanceDB) IndexBlocks(addresses []types.Address, blocks []*types.BlockWithTransactions) error {
+	defer db.refresh()
+	return db.ElasticsearchDB.IndexBlocks(addresses, blocks)
+}
+
+func (db *conformanceDB) IndexStorage(rawStorage map[types.Address]*types.AccountState, blockNumber uint64) error {
+	defer db.refresh()
+	return db.ElasticsearchDB.IndexStorage(rawStorage, blockNumber)
+}
+
+func (db *conformanceDB) DeleteAddress(address types.Address) error {
+	done := make(chan error)
+	go func() {
+		done <- db.ElasticsearchDB.DeleteAddress(address)
+	}()
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(10 * time.Millisecond):
+			if _, err := db.GetLastPersistedBlockNumber(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// TestElasticsearchDB_Conformance runs the conformance suite against the Elasticsearch
+// instance given by the ELASTICSEARCH_TEST_URL environment variable. All reporting indices
+// in the instance are deleted before and after each test.
+func TestElasticsearchDB_Conformance(t *testing.T) {
+	url := os.Getenv("ELASTICSEARCH_TEST_URL")
+	if url == "" {
+		t.Skip("ELASTICSEARCH_TEST_URL not set")
+	}
+
+	conformance.RunTests(t, func(t *testing.T) (database.Database, func()) {
+		client, err := NewClient(elasticsearch7.Config{Addresses: []string{url}})
+		assert.Nil(t, err)
+		deleteIndices := func() {
+			_, _ = client.Indices.Delete(AllIndexes, client.Indices.Delete.WithIgnoreUnavailable(true))
+		}
+		deleteIndices()
+
+		apiClient, err := NewAPIClient(client)
+		assert.Nil(t, err)
+		db, err := New(apiClient)
+		assert.Nil(t, err)
+		return &conformanceDB{ElasticsearchDB: db, client: client}, func() {
+			db.Stop()
+			deleteIndices()
+		}
+	})
+}
@@ -4,19 +4,38 @@ import "errors"
 
 // indices
 const (
-	MetaIndex        = "meta"
-	ContractIndex    = "contract"
-	TemplateIndex    = "template"
-	BlockIndex       = "block"
-	StorageIndex     = "storage"
-	TransactionIndex = "transaction"
-	EventIndex       = "event"
-	ERC20TokenIndex  = "erc20token"
-	ERC721TokenIndex = "erc721token"
+	MetaIndex           = "meta"
+	ContractIndex       = "contract"
+	TemplateIndex       = "template"
+	BlockIndex          = "block"
+	StorageIndex        = "storage"
+	TransactionIndex    = "transaction"
+	EventIndex          = "event"
+	ERC20TokenIndex     = "erc20token"
+	ERC721TokenIndex    = "erc721token"
+	ERC1155TokenIndex   = "erc1155token"
+	TokenBalanceIndex   = "tokenbalance"
+	ERC20AllowanceIndex = "erc20allowance"
+	TokenTransferIndex  = "tokentransfer"
+	TokenInfoIndex      = "tokeninfo"
+	TotalSupplyIndex    = "totalsupply"
+	ReorgIndex          = "reorg"
+	ProxyIndex          = "proxy"
+	BackfillIndex       = "backfill"
+	NativeTransferIndex = "nativetransfer"
+	NativeBalanceIndex  = "nativebalance"
+	MigrationIndex      = "migration"
+	FailureIndex        = "failure"
 )
 
+// TransactionMapping stores transaction amounts as keywords, since they may not fit in a long
+const TransactionMapping = `{"mappings":{"properties": {"internalCalls": {"type": "nested", "properties": {"value": {"type": "keyword"}}}, "value": {"type": "keyword"}, "gasPrice": {"type": "keyword"}, "valueKey": {"type": "keyword"}, "gasPriceKey": {"type": "keyword"}, "chainId": {"type": "keyword"}, "maxFeePerGas": {"type": "keyword"}, "maxPriorityFeePerGas": {"type": "keyword"}, "effectiveGasPrice": {"type": "keyword"}}}}`
+
+// FailureMapping stores the fields failures are filtered by as keywords, so that they match exactly
+const FailureMapping = `{"mappings":{"properties": {"address": {"type": "keyword"}, "reason": {"type": "keyword"}, "errorSig": {"type": "keyword"}, "error": {"type": "keyword"}}}}`
+
 var (
-	AllIndexes = []string{MetaIndex, ContractIndex, TemplateIndex, BlockIndex, StorageIndex, TransactionIndex, EventIndex, ERC20TokenIndex, ERC721TokenIndex}
+	AllIndexes = []string{MetaIndex, ContractIndex, TemplateIndex, BlockIndex, StorageIndex, TransactionIndex, EventIndex, ERC20TokenIndex, ERC20AllowanceIndex, ERC721TokenIndex, ERC1155TokenIndex, TokenBalanceIndex, TokenTransferIndex, TokenInfoIndex, TotalSupplyIndex, ReorgIndex, ProxyIndex, BackfillIndex, NativeTransferIndex, NativeBalanceIndex, MigrationIndex, FailureIndex}
 	// errors
 	ErrCouldNotResolveResp     = errors.New("could not resolve response body")
 	ErrIndexNotFound           = errors.New("index not found")
@@ -51,10 +51,9 @@ func NewWithDeps(client APIClient, dataDeleter DeletionCoordinator) (*Elasticsea
 }
 
 func (es *ElasticsearchDB) init() error {
-	mapping := `{"mappings":{"properties": {"internalCalls": {"type": "nested" }}}}`
 	createRequest := esapi.IndicesCreateRequest{
 		Index: TransactionIndex,
-		Body:  strings.NewReader(mapping),
+		Body:  strings.NewReader(TransactionMapping),
 	}
 
 	//TODO: check error scenarios
@@ -67,6 +66,19 @@ func (es *ElasticsearchDB) init() error {
 	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: MetaIndex})
 	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: ERC20TokenIndex})
 	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: ERC721TokenIndex})
+	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: ERC1155TokenIndex})
+	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: TokenBalanceIndex})
+	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: TokenTransferIndex})
+	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: ERC20AllowanceIndex})
+	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: TokenInfoIndex})
+	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: TotalSupplyIndex})
+	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: ReorgIndex})
+	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: ProxyIndex})
+	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: BackfillIndex})
+	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: NativeTransferIndex})
+	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: NativeBalanceIndex})
+	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: MigrationIndex})
+	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: FailureIndex, Body: strings.NewReader(FailureMapping)})
 
 	req := esapi.IndexRequest{
 		Index:      MetaIndex,
@@ -77,7 +89,7 @@ func (es *ElasticsearchDB) init() error {
 	}
 	es.apiClient.DoRequest(req)
 
-	return nil
+	return es.migrate()
 }
 
 //AddressDB
@@ -382,12 +394,160 @@ func (es *ElasticsearchDB) GetLastPersistedBlockNumber() (uint64, error) {
 	return lastPersisted.Source.LastPersisted, nil
 }
 
+// RollbackBlocks removes all data above the common ancestor of a chain reorg. The rollback
+// takes many requests, so every step is safe to repeat: if one fails, retrying the rollback
+// for the same reorg finishes the job.
+func (es *ElasticsearchDB) RollbackBlocks(reorg *types.ChainReorg) error {
+	ancestor := reorg.CommonAncestor
+
+	// lower the progress markers first, so that no orphaned data is treated as processed
+	// while the rollback is incomplete
+	lastPersisted, err := es.GetLastPersistedBlockNumber()
+	if err != nil {
+		return err
+	}
+	if lastPersisted > ancestor {
+		lastPersistedReq := esapi.IndexRequest{
+			Index:      MetaIndex,
+			DocumentID: "lastPersisted",
+			Body:       strings.NewReader(fmt.Sprintf(`{"lastPersisted": %d}`, ancestor)),
+			Refresh:    "true",
+		}
+		if _, err := es.apiClient.DoRequest(lastPersistedReq); err != nil {
+			return err
+		}
+	}
+
+	lastFilteredReq := esapi.UpdateByQueryRequest{
+		Index:             []string{ContractIndex},
+		Body:              strings.NewReader(fmt.Sprintf(RollbackLastFilteredQueryTemplate, ancestor, ancestor)),
+		Refresh:           &RequestParameterTrue,
+		WaitForCompletion: &RequestParameterTrue,
+	}
+	if _, err := es.apiClient.DoRequest(lastFilteredReq); err != nil {
+		return err
+	}
+
+	// find the orphaned transactions before they are deleted, to unset any contract creations
+	results, err := es.apiClient.ScrollAllResults(TransactionIndex, fmt.Sprintf(QueryOrphanedTransactionsTemplate, ancestor))
+	if err != nil {
+		return errors.New("error fetching orphaned transactions: " + err.Error())
+	}
+	orphanedTxs := make([]string, 0, len(results))
+	for _, result := range results {
+		data := result.(map[string]interface{})["_source"].(map[string]interface{})
+		orphanedTxs = append(orphanedTxs, strconv.Quote(data["hash"].(string)))
+	}
+	if len(orphanedTxs) > 0 {
+		clearCreationReq := esapi.UpdateByQueryRequest{
+			Index:             []string{ContractIndex},
+			Body:              strings.NewReader(fmt.Sprintf(ClearCreationTxQueryTemplate, strings.Join(orphanedTxs, ","))),
+			Refresh:           &RequestParameterTrue,
+			WaitForCompletion: &RequestParameterTrue,
+		}
+		if _, err := es.apiClient.DoRequest(clearCreationReq); err != nil {
+			return err
+		}
+	}
+
+	deletions := []struct {
+		index string
+		field string
+	}{
+		{BlockIndex, "number"},
+		{TransactionIndex, "blockNumber"},
+		{EventIndex, "blockNumber"},
+		{StorageIndex, "blockNumber"},
+		{ERC20TokenIndex, "blockNumber"},
+		{ERC721TokenIndex, "heldFrom"},
+		{ERC1155TokenIndex, "heldFrom"},
+		{TokenBalanceIndex, "heldFrom"},
+		{TokenTransferIndex, "blockNumber"},
+		{ERC20AllowanceIndex, "approvedFrom"},
+		{TotalSupplyIndex, "blockNumber"},
+		{ProxyIndex, "blockNumber"},
+		{NativeTransferIndex, "blockNumber"},
+		{NativeBalanceIndex, "blockNumber"},
+		{FailureIndex, "blockNumber"},
+	}
+	for _, deletion := range deletions {
+		log.Debug("Rolling back orphaned data", "index", deletion.index, "common ancestor", ancestor)
+		deleteReq := esapi.DeleteByQueryRequest{
+			Index:             []string{deletion.index},
+			Body:              strings.NewReader(fmt.Sprintf(DeleteAboveBlockQueryTemplate, deletion.field, ancestor)),
+			Refresh:           &RequestParameterTrue,
+			WaitForCompletion: &RequestParameterTrue,
+		}
+		if _, err := es.apiClient.DoRequest(deleteReq); err != nil {
+			return err
+		}
+	}
+
+	// re-open the token records that were superseded by orphaned blocks
+	reopenReq := esapi.UpdateByQueryRequest{
+		Index:             []string{ERC20TokenIndex, ERC721TokenIndex, ERC1155TokenIndex, TokenBalanceIndex},
+		Body:              strings.NewReader(fmt.Sprintf(ReopenHeldUntilQueryTemplate, ancestor)),
+		Refresh:           &RequestParameterTrue,
+		WaitForCompletion: &RequestParameterTrue,
+	}
+	if _, err := es.apiClient.DoRequest(reopenReq); err != nil {
+		return err
+	}
+
+	reopenUntil := []struct {
+		index string
+		field string
+	}{
+		{ERC20AllowanceIndex, "approvedUntil"},
+		{TotalSupplyIndex, "supplyUntil"},
+	}
+	for _, reopen := range reopenUntil {
+		reopenUntilReq := esapi.UpdateByQueryRequest{
+			Index:             []string{reopen.index},
+			Body:              strings.NewReader(fmt.Sprintf(ReopenUntilQueryTemplate, reopen.field, reopen.field, ancestor)),
+			Refresh:           &RequestParameterTrue,
+			WaitForCompletion: &RequestParameterTrue,
+		}
+		if _, err := es.apiClient.DoRequest(reopenUntilReq); err != nil {
+			return err
+		}
+	}
+
+	// the reorg is recorded once everything has been rolled back
+	reorgReq := esapi.IndexRequest{
+		Index:      ReorgIndex,
+		DocumentID: fmt.Sprintf("%d-%s", reorg.NewHead, reorg.NewHeadHash.String()),
+		Body:       esutil.NewJSONReader(reorg),
+		Refresh:    "true",
+	}
+	_, err = es.apiClient.DoRequest(reorgReq)
+	return err
+}
+
+func (es *ElasticsearchDB) GetChainReorgs() ([]*types.ChainReorg, error) {
+	results, err := es.apiClient.ScrollAllResults(ReorgIndex, QueryAllChainReorgsTemplate)
+	if err != nil {
+		return nil, errors.New("error fetching chain reorgs: " + err.Error())
+	}
+	converted := make([]*types.ChainReorg, len(results))
+	for i, result := range results {
+		data := result.(map[string]interface{})["_source"].(map[string]interface{})
+		converted[i] = &types.ChainReorg{
+			CommonAncestor: uint64(data["commonAncestor"].(float64)),
+			NewHead:        uint64(data["newHead"].(float64)),
+			NewHeadHash:    types.NewHash(data["newHeadHash"].(string)),
+			DetectedAt:     uint64(data["detectedAt"].(float64)),
+		}
+	}
+	return converted, nil
+}
+
 // TransactionDB
 func (es *ElasticsearchDB) WriteTransaction(transaction *types.Transaction) error {
 	req := esapi.IndexRequest{
 		Index:      TransactionIndex,
 		DocumentID: transaction.Hash.String(),
-		Body:       esutil.NewJSONReader(transaction),
+		Body:       esutil.NewJSONReader(sortableTransaction(transaction)),
 		Refresh:    "true",
 	}
 
@@ -416,7 +576,7 @@ func (es *ElasticsearchDB) WriteTransactions(transactions []*types.Transaction)
 			esutil.BulkIndexerItem{
 				Action:     "create",
 				DocumentID: transaction.Hash.String(),
-				Body:       esutil.NewJSONReader(transaction),
+				Body:       esutil.NewJSONReader(sortableTransaction(transaction)),
 				OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, item2 esutil.BulkIndexerResponseItem) {
 					wg.Done()
 				},
@@ -772,7 +932,7 @@ func (es *ElasticsearchDB) GetLastFiltered(address types.Address) (uint64, error
 
 func (es *ElasticsearchDB) checkIsInitialized() (bool, error) {
 	fetchReq := esapi.CatIndicesRequest{
-		Index: []string{MetaIndex, ContractIndex, BlockIndex, StorageIndex, TransactionIndex, EventIndex, ERC20TokenIndex, ERC721TokenIndex},
+		Index: []string{MetaIndex, ContractIndex, BlockIndex, StorageIndex, TransactionIndex, EventIndex, ERC20TokenIndex, ERC20AllowanceIndex, ERC721TokenIndex, ERC1155TokenIndex, TokenBalanceIndex, TokenTransferIndex, TokenInfoIndex, TotalSupplyIndex, ReorgIndex, ProxyIndex, BackfillIndex, NativeTransferIndex, NativeBalanceIndex, MigrationIndex, FailureIndex},
 	}
 
 	if _, err := es.apiClient.DoRequest(fetchReq); err != nil {
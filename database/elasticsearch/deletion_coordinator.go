@@ -36,10 +36,11 @@ func (coordinator *DefaultDeletionCoordinator) Delete(contract types.Address) er
 	deleteByAddressQuery := fmt.Sprintf(DeleteQueryAddress, contract.String())
 	deleteByContractQuery := fmt.Sprintf(DeleteQueryContract, contract.String())
 
-	// delete ERC20 & ERC721 tokens
-	log.Debug("Deleting ERC20/ERC721 token data", "contract", contract.String())
+	// delete ERC20, ERC721, ERC1155 & other token balances, ERC20 allowances, token transfers, token metadata and supply,
+	// proxy implementations and token backfill jobs
+	log.Debug("Deleting ERC20/ERC721/ERC1155 token, allowance, transfer, metadata, supply, proxy and backfill data", "contract", contract.String())
 	erc20Req := esapi.DeleteByQueryRequest{
-		Index:             []string{ERC20TokenIndex, ERC721TokenIndex},
+		Index:             []string{ERC20TokenIndex, ERC20AllowanceIndex, ERC721TokenIndex, ERC1155TokenIndex, TokenBalanceIndex, TokenTransferIndex, TokenInfoIndex, TotalSupplyIndex, ProxyIndex, BackfillIndex},
 		Body:              strings.NewReader(deleteByContractQuery),
 		Refresh:           &RequestParameterTrue,
 		WaitForCompletion: &RequestParameterTrue,
@@ -48,12 +49,12 @@ func (coordinator *DefaultDeletionCoordinator) Delete(contract types.Address) er
 	if err != nil {
 		return err
 	}
-	log.Debug("Deleted ERC20/ERC721 token data", "contract", contract.String())
+	log.Debug("Deleted ERC20/ERC721/ERC1155 token, allowance, transfer, metadata, supply, proxy and backfill data", "contract", contract.String())
 
-	//delete event
-	log.Debug("Deleting contract events", "contract", contract.String())
+	//delete events, native balances and transaction failures
+	log.Debug("Deleting contract events, native balances and failures", "contract", contract.String())
 	eventReq := esapi.DeleteByQueryRequest{
-		Index:             []string{EventIndex},
+		Index:             []string{EventIndex, NativeBalanceIndex, FailureIndex},
 		Body:              strings.NewReader(deleteByAddressQuery),
 		Refresh:           &RequestParameterTrue,
 		WaitForCompletion: &RequestParameterTrue,
@@ -62,7 +63,7 @@ func (coordinator *DefaultDeletionCoordinator) Delete(contract types.Address) er
 	if err != nil {
 		return err
 	}
-	log.Debug("Deleted contract events", "contract", contract.String())
+	log.Debug("Deleted contract events, native balances and failures", "contract", contract.String())
 
 	log.Debug("Deleting contract storage", "contract", contract.String())
 	storageDeleteReq := esapi.DeleteByQueryRequest{
@@ -23,12 +23,12 @@ func TestDefaultDeletionCoordinator_Delete(t *testing.T) {
 	addressToDelete := types.NewAddress("1")
 
 	ercDelete := esapi.DeleteByQueryRequest{
-		Index: []string{ERC20TokenIndex, ERC721TokenIndex},
+		Index: []string{ERC20TokenIndex, ERC20AllowanceIndex, ERC721TokenIndex, ERC1155TokenIndex, TokenBalanceIndex, TokenTransferIndex, TokenInfoIndex, TotalSupplyIndex, ProxyIndex, BackfillIndex},
 		Body:  strings.NewReader(`{ "query": { "match": { "contract": "0x0000000000000000000000000000000000000001" } } }`),
 	}
 	mockedClient.EXPECT().DoRequest(NewDeleteByQueryRequestMatcher(ercDelete)).Return(nil, nil)
 	eventDelete := esapi.DeleteByQueryRequest{
-		Index: []string{EventIndex},
+		Index: []string{EventIndex, NativeBalanceIndex, FailureIndex},
 		Body:  strings.NewReader(`{ "query": { "match": { "address": "0x0000000000000000000000000000000000000001" } } }`),
 	}
 	mockedClient.EXPECT().DoRequest(NewDeleteByQueryRequestMatcher(eventDelete)).Return(nil, nil)
@@ -0,0 +1,61 @@
+package elasticsearch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/mitchellh/mapstructure"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// FailureDB
+func (es *ElasticsearchDB) RecordTransactionFailures(failures []types.TransactionFailure) error {
+	for _, failure := range failures {
+		req := esapi.IndexRequest{
+			Index:      FailureIndex,
+			DocumentID: fmt.Sprintf("%s-%d-%d", failure.Address.String(), failure.BlockNumber, failure.Index),
+			Body:       esutil.NewJSONReader(failure),
+			Refresh:    "true",
+		}
+		if _, err := es.apiClient.DoRequest(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (es *ElasticsearchDB) GetTransactionFailures(address types.Address, reason string, options *types.PageOptions) ([]types.TransactionFailure, error) {
+	from := options.PageSize * options.PageNumber
+	if from+options.PageSize > 1000 {
+		return nil, ErrPaginationLimitExceeded
+	}
+
+	searchReq := esapi.SearchRequest{
+		Index: []string{FailureIndex},
+		Body:  strings.NewReader(QueryTransactionFailures(address, reason, options)),
+		From:  &from,
+		Size:  &options.PageSize,
+		Sort:  []string{"blockNumber:desc", "index:desc"},
+	}
+
+	results, err := es.doSearchRequest(searchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	failures := make([]types.TransactionFailure, 0, len(results.Hits.Hits))
+	for _, result := range results.Hits.Hits {
+		var failure types.TransactionFailure
+		if err := mapstructure.Decode(result.Source, &failure); err != nil {
+			return nil, err
+		}
+		failure.Address = types.NewAddress(string(failure.Address))
+		failure.TransactionHash = types.NewHash(string(failure.TransactionHash))
+		failure.RevertData = types.NewHexData(string(failure.RevertData))
+		failures = append(failures, failure)
+	}
+	return failures, nil
+}
@@ -0,0 +1,105 @@
+package elasticsearch
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	elasticsearchmocks "quorumengineering/quorum-report/database/elasticsearch/mocks"
+	"quorumengineering/quorum-report/types"
+)
+
+var testFailure = types.TransactionFailure{
+	Address:         types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17"),
+	TransactionHash: types.NewHash("0xf4f803b8d6c6b38e0b15d6cfe80fd1dcea4270ad24e93385fca36512bb9c2c59"),
+	BlockNumber:     13,
+	Index:           2,
+	Timestamp:       1300,
+	Error:           "execution reverted",
+	ErrorSig:        "Error(string)",
+	Reason:          "not owner",
+	RevertData:      types.NewHexData("0x08c379a0"),
+}
+
+func TestElasticsearchDB_RecordTransactionFailures(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	req := esapi.IndexRequest{
+		Index:      FailureIndex,
+		DocumentID: "0x1349f3e1b8d71effb47b840594ff27da7e603d17-13-2",
+		Body:       esutil.NewJSONReader(testFailure),
+		Refresh:    "true",
+	}
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().DoRequest(NewIndexRequestMatcher(req))
+
+	db, _ := New(mockedClient)
+	err := db.RecordTransactionFailures([]types.TransactionFailure{testFailure})
+
+	assert.Nil(t, err)
+}
+
+func TestElasticsearchDB_GetTransactionFailures(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	options := &types.PageOptions{PageNumber: 1}
+	options.SetDefaults()
+
+	expectedQuery := `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "address": "0x1349f3e1b8d71effb47b840594ff27da7e603d17"} },
+				{ "term": { "reason": "not owner" } },
+				{ "range": { "blockNumber": { "gte": 0 } } }
+			]
+		}
+	}
+}
+`
+	from, size := 10, 10
+	req := esapi.SearchRequest{
+		Index: []string{FailureIndex},
+		Body:  strings.NewReader(expectedQuery),
+		From:  &from,
+		Size:  &size,
+	}
+
+	resultJson := `{"hits": {"hits": [{"_source": {"address": "0x1349f3e1b8d71effb47b840594ff27da7e603d17", "transactionHash": "0xf4f803b8d6c6b38e0b15d6cfe80fd1dcea4270ad24e93385fca36512bb9c2c59", "blockNumber": 13, "index": 2, "timestamp": 1300, "error": "execution reverted", "errorSig": "Error(string)", "reason": "not owner", "revertData": "0x08c379a0"}}]}}`
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().DoRequest(NewSearchRequestMatcher(req)).Return([]byte(resultJson), nil)
+
+	db, _ := New(mockedClient)
+	failures, err := db.GetTransactionFailures(testFailure.Address, "not owner", options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TransactionFailure{testFailure}, failures)
+}
+
+func TestElasticsearchDB_GetTransactionFailures_PaginationLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	options := &types.PageOptions{PageSize: 100, PageNumber: 10}
+	options.SetDefaults()
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+
+	db, _ := New(mockedClient)
+	failures, err := db.GetTransactionFailures(testFailure.Address, "", options)
+
+	assert.Equal(t, ErrPaginationLimitExceeded, err)
+	assert.Nil(t, failures)
+}
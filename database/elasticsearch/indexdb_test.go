@@ -276,6 +276,82 @@ func TestElasticsearchDB_GetAllTransactionsToAddress_MultipleResults(t *testing.
 	assert.Nil(t, err, "unexpected error")
 }
 
+func TestElasticsearchDB_GetAllTransactionsToAddress_ValueRange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	addr := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+
+	result := `{"hits": {"hits": [
+  {
+    "_source": {
+      "hash": "0xd838a0eaccb60b0f0c65e55dd8cc36aea9576b8cdf0c947b0a974814d536e891",
+      "to": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34"
+    }
+  }
+]}}`
+
+	from := 0
+	size := 10
+	endValue, _ := types.ParseBigInt("100000000000000000000")
+	options := &types.QueryOptions{BeginValue: types.NewBigIntFromUint64(1000), EndValue: endValue}
+	options.SetDefaults()
+
+	query := `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "to": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34" } },
+{ "range": { "blockNumber": { "gte": 0 } } },
+{ "range": { "timestamp": { "gte": 0 } } },
+{ "range": { "valueKey": { "gte": "` + strings.Repeat("0", 74) + `1000", "lte": "` + strings.Repeat("0", 57) + `100000000000000000000" } } }
+			]
+		}
+	}
+}
+`
+	expectedRequest := esapi.SearchRequest{
+		Index: []string{TransactionIndex},
+		Body:  strings.NewReader(query),
+		From:  &from,
+		Size:  &size,
+		Sort:  []string{"blockNumber:desc", "index:asc"},
+	}
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().DoRequest(NewSearchRequestMatcher(expectedRequest)).Return([]byte(result), nil)
+
+	db, _ := New(mockedClient)
+	txns, err := db.GetAllTransactionsToAddress(addr, options)
+
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, 1, len(txns), "wrong number of returned transactions")
+	assert.Equal(t, "0xd838a0eaccb60b0f0c65e55dd8cc36aea9576b8cdf0c947b0a974814d536e891", txns[0].String(), "wrong txn hash returned")
+}
+
+func TestCreateValueRangeQuery(t *testing.T) {
+	options := &types.QueryOptions{}
+	options.SetDefaults()
+	assert.Equal(t, "", createValueRangeQuery(options))
+
+	options = &types.QueryOptions{BeginValue: types.NewBigIntFromUint64(5)}
+	options.SetDefaults()
+	assert.Equal(t, `,
+{ "range": { "valueKey": { "gte": "`+strings.Repeat("0", 77)+`5" } } }`, createValueRangeQuery(options))
+
+	// the largest uint256 fills the whole key
+	maxValue, _ := types.ParseBigInt("0x" + strings.Repeat("f", 64))
+	assert.Nil(t, (&types.QueryOptions{EndValue: maxValue}).ValidateValueRange())
+	options = &types.QueryOptions{EndValue: maxValue}
+	options.SetDefaults()
+	assert.Equal(t, `,
+{ "range": { "valueKey": { "gte": "`+strings.Repeat("0", 78)+`", "lte": "`+maxValue.String()+`" } } }`, createValueRangeQuery(options))
+	assert.Len(t, maxValue.String(), 78)
+}
+
 func TestElasticsearchDB_GetAllEventsByAddress_WithError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
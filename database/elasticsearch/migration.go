@@ -0,0 +1,296 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// transactionMigrationIndex holds the transactions while the transaction index is recreated
+const transactionMigrationIndex = "transaction-migration"
+
+// reindexPollInterval is how often a copy between indices is checked for completion
+var reindexPollInterval = 5 * time.Second
+
+// migrateTransactionsScript converts the amounts of transactions stored as numbers to decimal
+// strings, and adds their sortable keys
+const migrateTransactionsScript = `
+String amount(def value) {
+	return value == null ? '0' : value.toString();
+}
+String amountKey(String amount) {
+	StringBuilder key = new StringBuilder();
+	for (int i = amount.length(); i < 78; i++) {
+		key.append('0');
+	}
+	return key.append(amount).toString();
+}
+ctx._source.value = amount(ctx._source.value);
+ctx._source.gasPrice = amount(ctx._source.gasPrice);
+ctx._source.valueKey = amountKey(ctx._source.value);
+ctx._source.gasPriceKey = amountKey(ctx._source.gasPrice);
+if (ctx._source.internalCalls != null) {
+	for (def call : ctx._source.internalCalls) {
+		call.value = amount(call.value);
+	}
+}`
+
+// migrations are run in order when the database is initialised. Each is recorded in the
+// migration index once it has completed, so that it is never run again.
+var migrations = []struct {
+	id  string
+	run func(es *ElasticsearchDB) error
+}{
+	{"transaction-amounts", (*ElasticsearchDB).migrateTransactionAmounts},
+}
+
+func (es *ElasticsearchDB) migrate() error {
+	for _, migration := range migrations {
+		_, err := es.apiClient.DoRequest(esapi.GetRequest{Index: MigrationIndex, DocumentID: migration.id})
+		if err == nil {
+			continue
+		}
+		if err != database.ErrNotFound {
+			return err
+		}
+
+		log.Info("Applying database migration", "migration", migration.id)
+		if err := migration.run(es); err != nil {
+			return err
+		}
+		req := esapi.IndexRequest{
+			Index:      MigrationIndex,
+			DocumentID: migration.id,
+			Body:       strings.NewReader(`{"applied": true}`),
+			Refresh:    "true",
+		}
+		if _, err := es.apiClient.DoRequest(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateTransactionAmounts recreates the transaction index with amounts mapped as keywords,
+// since a field cannot change type in place. The transactions are copied out to a temporary
+// index, converting their amounts on the way, and copied back once the transaction index has
+// been recreated. If interrupted, the migration resumes from the temporary index, after any
+// copy still running in Elasticsearch has finished, so that it is not written to while it is
+// deleted or copied from.
+func (es *ElasticsearchDB) migrateTransactionAmounts() error {
+	for _, index := range []string{transactionMigrationIndex, TransactionIndex} {
+		if err := es.awaitInterruptedReindex(index); err != nil {
+			return err
+		}
+	}
+
+	valueType, err := es.valueMappingType(TransactionIndex)
+	if err != nil && err != ErrIndexNotFound {
+		return err
+	}
+	converted := valueType == "keyword" || err == ErrIndexNotFound
+	if !converted {
+		// any previous copy was interrupted before the transaction index was recreated
+		if err := es.deleteIndex(transactionMigrationIndex); err != nil {
+			return err
+		}
+		if err := es.createIndex(transactionMigrationIndex, TransactionMapping); err != nil {
+			return err
+		}
+		if err := es.reindex(TransactionIndex, transactionMigrationIndex, migrateTransactionsScript); err != nil {
+			return err
+		}
+	} else if _, err := es.valueMappingType(transactionMigrationIndex); err != nil {
+		if err == ErrIndexNotFound {
+			// the index is already up to date and there is nothing to resume
+			return nil
+		}
+		return err
+	}
+
+	// the temporary index holds all transactions, so any copied back before an interruption
+	// are dropped with the transaction index
+	if err := es.deleteIndex(TransactionIndex); err != nil {
+		return err
+	}
+	if err := es.createIndex(TransactionIndex, TransactionMapping); err != nil {
+		return err
+	}
+	if err := es.reindex(transactionMigrationIndex, TransactionIndex, ""); err != nil {
+		return err
+	}
+	return es.deleteIndex(transactionMigrationIndex)
+}
+
+// valueMappingType returns the mapped type of the value field of the given index, which is
+// empty if the index has no documents with a value yet
+func (es *ElasticsearchDB) valueMappingType(index string) (string, error) {
+	body, err := es.apiClient.DoRequest(esapi.IndicesGetMappingRequest{Index: []string{index}})
+	if err != nil {
+		return "", err
+	}
+	var mappings map[string]struct {
+		Mappings struct {
+			Properties map[string]struct {
+				Type string `json:"type"`
+			} `json:"properties"`
+		} `json:"mappings"`
+	}
+	if err := json.Unmarshal(body, &mappings); err != nil {
+		return "", err
+	}
+	return mappings[index].Mappings.Properties["value"].Type, nil
+}
+
+func (es *ElasticsearchDB) createIndex(index string, mapping string) error {
+	_, err := es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: index, Body: strings.NewReader(mapping)})
+	return err
+}
+
+func (es *ElasticsearchDB) deleteIndex(index string) error {
+	_, err := es.apiClient.DoRequest(esapi.IndicesDeleteRequest{Index: []string{index}})
+	if err == ErrIndexNotFound {
+		return nil
+	}
+	return err
+}
+
+// reindex copies all documents from one index to another, transforming them with the
+// given painless script if one is given. The copy runs as a task in Elasticsearch, which is
+// polled until it completes. It carries on if the process stops, so the task is recorded in
+// the migration index until then.
+func (es *ElasticsearchDB) reindex(source string, dest string, script string) error {
+	body := map[string]interface{}{
+		"source": map[string]string{"index": source},
+		"dest":   map[string]string{"index": dest},
+	}
+	if script != "" {
+		body["script"] = map[string]string{"lang": "painless", "source": script}
+	}
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	refresh, waitForCompletion := true, false
+	req := esapi.ReindexRequest{
+		Body:              strings.NewReader(string(encoded)),
+		Refresh:           &refresh,
+		WaitForCompletion: &waitForCompletion,
+	}
+	resp, err := es.apiClient.DoRequest(req)
+	if err != nil {
+		return err
+	}
+	var started struct {
+		Task string `json:"task"`
+	}
+	if err := json.Unmarshal(resp, &started); err != nil {
+		return err
+	}
+
+	recordReq := esapi.IndexRequest{
+		Index:      MigrationIndex,
+		DocumentID: reindexTaskID(dest),
+		Body:       esutil.NewJSONReader(map[string]string{"task": started.Task}),
+		Refresh:    "true",
+	}
+	if _, err := es.apiClient.DoRequest(recordReq); err != nil {
+		return err
+	}
+
+	result, err := es.awaitTask(started.Task)
+	if err != nil {
+		return err
+	}
+	if result.Error != nil {
+		return fmt.Errorf("could not copy %s to %s: %s", source, dest, result.Error.Reason)
+	}
+	if len(result.Response.Failures) > 0 {
+		return fmt.Errorf("could not copy %d documents from %s to %s", len(result.Response.Failures), source, dest)
+	}
+	_, err = es.apiClient.DoRequest(esapi.DeleteRequest{Index: MigrationIndex, DocumentID: reindexTaskID(dest), Refresh: "true"})
+	return err
+}
+
+// awaitInterruptedReindex waits for a copy into the index that was started before the process
+// stopped. Its result is not needed, as the copy is made again.
+func (es *ElasticsearchDB) awaitInterruptedReindex(dest string) error {
+	body, err := es.apiClient.DoRequest(esapi.GetRequest{Index: MigrationIndex, DocumentID: reindexTaskID(dest)})
+	if err == database.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var recorded struct {
+		Source struct {
+			Task string `json:"task"`
+		} `json:"_source"`
+	}
+	if err := json.Unmarshal(body, &recorded); err != nil {
+		return err
+	}
+
+	log.Info("Waiting for an interrupted copy to finish", "index", dest, "task", recorded.Source.Task)
+	// the task is only missing if its result was never stored, in which case it is not running
+	if _, err := es.awaitTask(recorded.Source.Task); err != nil && err != database.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// reindexTask is the status of a copy between indices, as returned by the task API
+type reindexTask struct {
+	Completed bool `json:"completed"`
+	Response  struct {
+		Failures []interface{} `json:"failures"`
+	} `json:"response"`
+	Error *struct {
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// awaitTask polls the task until it has completed
+func (es *ElasticsearchDB) awaitTask(task string) (*reindexTask, error) {
+	for {
+		body, err := es.apiClient.DoRequest(esapi.TasksGetRequest{TaskID: task})
+		if err != nil {
+			return nil, err
+		}
+		var status reindexTask
+		if err := json.Unmarshal(body, &status); err != nil {
+			return nil, err
+		}
+		if status.Completed {
+			return &status, nil
+		}
+		time.Sleep(reindexPollInterval)
+	}
+}
+
+func reindexTaskID(dest string) string {
+	return "reindex-" + dest
+}
+
+// amountKey pads an amount with leading zeros to the 78 digits of the largest uint256, so
+// that amounts compare as keywords in the same order as they do as numbers
+func amountKey(amount *types.BigInt) string {
+	return fmt.Sprintf("%078s", amount.String())
+}
+
+func sortableTransaction(transaction *types.Transaction) SortableTransaction {
+	return SortableTransaction{
+		Transaction: *transaction,
+		ValueKey:    amountKey(transaction.Value),
+		GasPriceKey: amountKey(transaction.GasPrice),
+	}
+}
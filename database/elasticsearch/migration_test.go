@@ -0,0 +1,205 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database"
+	elasticsearch_mocks "quorumengineering/quorum-report/database/elasticsearch/mocks"
+	"quorumengineering/quorum-report/types"
+)
+
+// recordMigrationRequests answers the requests made by the transaction amounts migration,
+// describing each request in the order they were made. The copies between indices are run
+// as tasks that complete on their second poll, and the given tasks were recorded by a
+// previous run.
+func recordMigrationRequests(mockedClient *elasticsearch_mocks.MockAPIClient, mappings map[string]string, tasks map[string]string) *[]string {
+	var requests []string
+	polls := make(map[string]int)
+	mockedClient.EXPECT().DoRequest(gomock.Any()).DoAndReturn(func(req esapi.Request) ([]byte, error) {
+		switch r := req.(type) {
+		case esapi.IndicesGetMappingRequest:
+			requests = append(requests, "mapping "+r.Index[0])
+			if mapping, ok := mappings[r.Index[0]]; ok {
+				return []byte(mapping), nil
+			}
+			return nil, ErrIndexNotFound
+		case esapi.IndicesDeleteRequest:
+			requests = append(requests, "delete "+r.Index[0])
+			if _, ok := mappings[r.Index[0]]; !ok {
+				return nil, ErrIndexNotFound
+			}
+		case esapi.IndicesCreateRequest:
+			body, _ := ioutil.ReadAll(r.Body)
+			requests = append(requests, "create "+r.Index+" "+string(body))
+		case esapi.ReindexRequest:
+			var body struct {
+				Source struct{ Index string }
+				Dest   struct{ Index string }
+				Script struct{ Source string }
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			request := "reindex " + body.Source.Index + " " + body.Dest.Index
+			if body.Script.Source == migrateTransactionsScript {
+				request += " converting amounts"
+			}
+			requests = append(requests, request)
+			return []byte(`{"task": "node:` + body.Dest.Index + `"}`), nil
+		case esapi.TasksGetRequest:
+			requests = append(requests, "poll "+r.TaskID)
+			polls[r.TaskID]++
+			if polls[r.TaskID] < 2 {
+				return []byte(`{"completed": false}`), nil
+			}
+			return []byte(`{"completed": true, "response": {"failures": []}}`), nil
+		case esapi.IndexRequest:
+			var body struct{ Task string }
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			requests = append(requests, "record "+r.DocumentID+" "+body.Task)
+		case esapi.GetRequest:
+			requests = append(requests, "get "+r.DocumentID)
+			if task, ok := tasks[r.DocumentID]; ok {
+				return []byte(`{"_source": {"task": "` + task + `"}}`), nil
+			}
+			return nil, database.ErrNotFound
+		case esapi.DeleteRequest:
+			requests = append(requests, "clear "+r.DocumentID)
+		}
+		return nil, nil
+	}).AnyTimes()
+	return &requests
+}
+
+func TestElasticsearchDB_MigrateTransactionAmounts(t *testing.T) {
+	defer func(interval time.Duration) { reindexPollInterval = interval }(reindexPollInterval)
+	reindexPollInterval = 0
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedClient := elasticsearch_mocks.NewMockAPIClient(ctrl)
+	requests := recordMigrationRequests(mockedClient, map[string]string{
+		TransactionIndex: `{"transaction": {"mappings": {"properties": {"value": {"type": "long"}}}}}`,
+	}, nil)
+
+	db := &ElasticsearchDB{apiClient: mockedClient}
+	err := db.migrateTransactionAmounts()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"get reindex-transaction-migration",
+		"get reindex-transaction",
+		"mapping transaction",
+		"delete transaction-migration",
+		"create transaction-migration " + TransactionMapping,
+		"reindex transaction transaction-migration converting amounts",
+		"record reindex-transaction-migration node:transaction-migration",
+		"poll node:transaction-migration",
+		"poll node:transaction-migration",
+		"clear reindex-transaction-migration",
+		"delete transaction",
+		"create transaction " + TransactionMapping,
+		"reindex transaction-migration transaction",
+		"record reindex-transaction node:transaction",
+		"poll node:transaction",
+		"poll node:transaction",
+		"clear reindex-transaction",
+		"delete transaction-migration",
+	}, *requests)
+}
+
+func TestElasticsearchDB_MigrateTransactionAmounts_AlreadyMigrated(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedClient := elasticsearch_mocks.NewMockAPIClient(ctrl)
+	requests := recordMigrationRequests(mockedClient, map[string]string{
+		TransactionIndex: `{"transaction": {"mappings": {"properties": {"value": {"type": "keyword"}}}}}`,
+	}, nil)
+
+	db := &ElasticsearchDB{apiClient: mockedClient}
+	err := db.migrateTransactionAmounts()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"get reindex-transaction-migration",
+		"get reindex-transaction",
+		"mapping transaction",
+		"mapping transaction-migration",
+	}, *requests)
+}
+
+func TestElasticsearchDB_MigrateTransactionAmounts_ResumesCopyBack(t *testing.T) {
+	defer func(interval time.Duration) { reindexPollInterval = interval }(reindexPollInterval)
+	reindexPollInterval = 0
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the copy back into the transaction index was still running when the process stopped
+	mockedClient := elasticsearch_mocks.NewMockAPIClient(ctrl)
+	requests := recordMigrationRequests(mockedClient, map[string]string{
+		TransactionIndex:          `{"transaction": {"mappings": {"properties": {"value": {"type": "keyword"}}}}}`,
+		transactionMigrationIndex: `{"transaction-migration": {"mappings": {"properties": {"value": {"type": "keyword"}}}}}`,
+	}, map[string]string{"reindex-transaction": "node:interrupted"})
+
+	db := &ElasticsearchDB{apiClient: mockedClient}
+	err := db.migrateTransactionAmounts()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"get reindex-transaction-migration",
+		"get reindex-transaction",
+		"poll node:interrupted",
+		"poll node:interrupted",
+		"mapping transaction",
+		"mapping transaction-migration",
+		"delete transaction",
+		"create transaction " + TransactionMapping,
+		"reindex transaction-migration transaction",
+		"record reindex-transaction node:transaction",
+		"poll node:transaction",
+		"poll node:transaction",
+		"clear reindex-transaction",
+		"delete transaction-migration",
+	}, *requests)
+}
+
+func TestElasticsearchDB_MigrateTransactionAmounts_CopyFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedClient := elasticsearch_mocks.NewMockAPIClient(ctrl)
+	mockedClient.EXPECT().DoRequest(gomock.Any()).DoAndReturn(func(req esapi.Request) ([]byte, error) {
+		switch req.(type) {
+		case esapi.GetRequest:
+			return nil, database.ErrNotFound
+		case esapi.IndicesGetMappingRequest:
+			return []byte(`{"transaction": {"mappings": {"properties": {"value": {"type": "long"}}}}}`), nil
+		case esapi.ReindexRequest:
+			return []byte(`{"task": "node:1"}`), nil
+		case esapi.TasksGetRequest:
+			return []byte(`{"completed": true, "response": {"failures": [{}, {}]}}`), nil
+		}
+		return nil, nil
+	}).AnyTimes()
+
+	db := &ElasticsearchDB{apiClient: mockedClient}
+	err := db.migrateTransactionAmounts()
+
+	assert.EqualError(t, err, "could not copy 2 documents from transaction to transaction-migration")
+}
+
+func TestAmountKey(t *testing.T) {
+	small := amountKey(types.NewBigIntFromUint64(9))
+	large, _ := types.ParseBigInt("0x3635c9adc5dea00000")
+
+	assert.Equal(t, strings.Repeat("0", 77)+"9", small)
+	assert.Len(t, amountKey(large), 78)
+	assert.True(t, small < amountKey(large))
+}
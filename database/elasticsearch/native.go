@@ -0,0 +1,165 @@
+package elasticsearch
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/mitchellh/mapstructure"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/types"
+)
+
+// NativeDB
+func (es *ElasticsearchDB) RecordNativeTransfers(transfers []types.NativeTransfer) error {
+	for _, transfer := range transfers {
+		req := esapi.IndexRequest{
+			Index:      NativeTransferIndex,
+			DocumentID: fmt.Sprintf("%d-%d", transfer.BlockNumber, transfer.Index),
+			Body:       esutil.NewJSONReader(transfer),
+			Refresh:    "true",
+		}
+		if _, err := es.apiClient.DoRequest(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (es *ElasticsearchDB) GetNativeTransfers(address types.Address, direction string, options *types.TokenTransferQueryOptions) ([]types.NativeTransfer, error) {
+	afterBlock, afterIndex, hasCursor, err := options.Cursor()
+	if err != nil {
+		return nil, err
+	}
+	if options.PageSize > 1000 {
+		return nil, ErrPaginationLimitExceeded
+	}
+
+	searchReq := esapi.SearchRequest{
+		Index: []string{NativeTransferIndex},
+		Body:  strings.NewReader(QueryNativeTransfers(address, direction, options, afterBlock, afterIndex, hasCursor)),
+		Size:  &options.PageSize,
+	}
+
+	results, err := es.doSearchRequest(searchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	transfers := make([]types.NativeTransfer, 0, len(results.Hits.Hits))
+	for _, result := range results.Hits.Hits {
+		var transfer types.NativeTransfer
+		if err := mapstructure.Decode(result.Source, &transfer); err != nil {
+			return nil, err
+		}
+		transfer.From = types.NewAddress(string(transfer.From))
+		transfer.To = types.NewAddress(string(transfer.To))
+		transfer.TransactionHash = types.NewHash(string(transfer.TransactionHash))
+		transfers = append(transfers, transfer)
+	}
+	return transfers, nil
+}
+
+func (es *ElasticsearchDB) RecordNativeBalance(address types.Address, block uint64, balance *big.Int) error {
+	record := types.NativeBalance{
+		Address:     address,
+		Balance:     balance.String(),
+		BlockNumber: block,
+	}
+	req := esapi.IndexRequest{
+		Index:      NativeBalanceIndex,
+		DocumentID: fmt.Sprintf("%s-%d", address.String(), block),
+		Body:       esutil.NewJSONReader(record),
+		Refresh:    "true",
+	}
+	_, err := es.apiClient.DoRequest(req)
+	return err
+}
+
+func (es *ElasticsearchDB) NativeBalanceAtBlock(address types.Address, block uint64) (*big.Int, error) {
+	record, err := es.nativeBalanceEntryAtBlock(address, block)
+	if err != nil {
+		return nil, err
+	}
+	balance, success := new(big.Int).SetString(record.Balance, 10)
+	if !success {
+		return nil, errors.New("could not parse native balance")
+	}
+	return balance, nil
+}
+
+func (es *ElasticsearchDB) GetNativeBalance(address types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	from := options.PageSize * options.PageNumber
+	if from+options.PageSize > 1000 {
+		return nil, ErrPaginationLimitExceeded
+	}
+
+	// no balance is recorded between the latest balance at the starting block and the
+	// starting block, so the range is widened to include it
+	beginBlock := options.BeginBlockNumber.Uint64()
+	rangeBegin := options.BeginBlockNumber
+	atBegin, err := es.nativeBalanceEntryAtBlock(address, beginBlock)
+	if err != nil && err != database.ErrNotFound {
+		return nil, err
+	}
+	if atBegin != nil {
+		rangeBegin = new(big.Int).SetUint64(atBegin.BlockNumber)
+	}
+
+	req := esapi.SearchRequest{
+		Index: []string{NativeBalanceIndex},
+		Body:  strings.NewReader(QueryNativeBalanceAtBlockRange(address, rangeBegin, options.EndBlockNumber)),
+		From:  &from,
+		Size:  &options.PageSize,
+		Sort:  []string{"blockNumber:desc"},
+	}
+	results, err := es.doSearchRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	balanceMap := make(map[uint64]*big.Int)
+	for _, result := range results.Hits.Hits {
+		blockNumber := uint64(result.Source["blockNumber"].(float64))
+		balance, success := new(big.Int).SetString(result.Source["balance"].(string), 10)
+		if !success {
+			return nil, errors.New("could not parse native balance")
+		}
+
+		if blockNumber < beginBlock {
+			balanceMap[beginBlock] = balance
+		} else {
+			balanceMap[blockNumber] = balance
+		}
+	}
+	return balanceMap, nil
+}
+
+// nativeBalanceEntryAtBlock finds the balance entry with the highest block number at or
+// before the given block
+func (es *ElasticsearchDB) nativeBalanceEntryAtBlock(address types.Address, block uint64) (*types.NativeBalance, error) {
+	pageSize := 1
+	searchReq := esapi.SearchRequest{
+		Index: []string{NativeBalanceIndex},
+		Body:  strings.NewReader(fmt.Sprintf(QueryNativeBalanceAtBlock, address.String(), block)),
+		Size:  &pageSize,
+	}
+
+	results, err := es.doSearchRequest(searchReq)
+	if err != nil {
+		return nil, err
+	}
+	if len(results.Hits.Hits) == 0 {
+		return nil, database.ErrNotFound
+	}
+
+	var record types.NativeBalance
+	if err = mapstructure.Decode(results.Hits.Hits[0].Source, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
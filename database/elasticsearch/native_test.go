@@ -0,0 +1,163 @@
+package elasticsearch
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database"
+	elasticsearchmocks "quorumengineering/quorum-report/database/elasticsearch/mocks"
+	"quorumengineering/quorum-report/types"
+)
+
+func TestElasticsearchDB_GetNativeTransfers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	address := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+	options := &types.TokenTransferQueryOptions{After: "12-3"}
+	options.SetDefaults()
+
+	expectedQuery := `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "range": { "blockNumber": { "gte": 0 } } },
+				{ "range": { "timestamp": { "gte": 0 } } },
+				{ "bool": { "should": [ { "match": { "from": "0x1349f3e1b8d71effb47b840594ff27da7e603d17" } } ], "minimum_should_match": 1 } }
+			]
+		}
+	},
+	"search_after": [ 12, 3 ],
+	"sort": [
+		{ "blockNumber": "asc" },
+		{ "index": "asc" }
+	]
+}
+`
+	size := 10
+	req := esapi.SearchRequest{
+		Index: []string{NativeTransferIndex},
+		Body:  strings.NewReader(expectedQuery),
+		Size:  &size,
+	}
+
+	resultJson := `{"hits": {"hits": [{"_source": {"from": "0x1349f3e1b8d71effb47b840594ff27da7e603d17", "to": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34", "value": "1000", "type": "CALL", "blockNumber": 13, "transactionHash": "0xf4f803b8d6c6b38e0b15d6cfe80fd1dcea4270ad24e93385fca36512bb9c2c59", "index": 2, "timestamp": 1300}}]}}`
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().DoRequest(NewSearchRequestMatcher(req)).Return([]byte(resultJson), nil)
+
+	db, _ := New(mockedClient)
+	transfers, err := db.GetNativeTransfers(address, types.TransferDirectionOut, options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []types.NativeTransfer{
+		{
+			From:            address,
+			To:              types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34"),
+			Value:           "1000",
+			Type:            "CALL",
+			BlockNumber:     13,
+			TransactionHash: types.NewHash("0xf4f803b8d6c6b38e0b15d6cfe80fd1dcea4270ad24e93385fca36512bb9c2c59"),
+			Index:           2,
+			Timestamp:       1300,
+		},
+	}, transfers)
+}
+
+func TestElasticsearchDB_NativeBalanceAtBlock_NotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	address := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+	size := 1
+	req := esapi.SearchRequest{
+		Index: []string{NativeBalanceIndex},
+		Body: strings.NewReader(`
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "address": "0x1349f3e1b8d71effb47b840594ff27da7e603d17"} },
+				{ "range": { "blockNumber": { "lte": 5 } } }
+			]
+		}
+	},
+	"sort": [
+		{
+			"blockNumber": {
+				"order": "desc",
+				"unmapped_type": "long"
+			}
+		}
+	]
+}
+`),
+		Size: &size,
+	}
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().DoRequest(NewSearchRequestMatcher(req)).Return([]byte(`{"hits": {"hits": []}}`), nil)
+
+	db, _ := New(mockedClient)
+	balance, err := db.NativeBalanceAtBlock(address, 5)
+
+	assert.Equal(t, database.ErrNotFound, err)
+	assert.Nil(t, balance)
+}
+
+func TestElasticsearchDB_GetNativeBalance_WidensRangeToEarlierBalance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	address := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+	options := &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(4), EndBlockNumber: big.NewInt(6), PageSize: 10}
+
+	atBeginSize := 1
+	atBeginReq := esapi.SearchRequest{
+		Index: []string{NativeBalanceIndex},
+		Size:  &atBeginSize,
+	}
+	rangeSize := 10
+	rangeFrom := 0
+	rangeReq := esapi.SearchRequest{
+		Index: []string{NativeBalanceIndex},
+		Body: strings.NewReader(`
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "address": "0x1349f3e1b8d71effb47b840594ff27da7e603d17"} },
+				{ "range": { "blockNumber": { "gte": 2, "lte": 6 } } }
+			]
+		}
+	}
+}
+`),
+		From: &rangeFrom,
+		Size: &rangeSize,
+		Sort: []string{"blockNumber:desc"},
+	}
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	gomock.InOrder(
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(atBeginReq)).
+			Return([]byte(`{"hits": {"hits": [{"_source": {"address": "0x1349f3e1b8d71effb47b840594ff27da7e603d17", "balance": "1000", "blockNumber": 2}}]}}`), nil),
+		mockedClient.EXPECT().DoRequest(NewSearchRequestMatcher(rangeReq)).
+			Return([]byte(`{"hits": {"hits": [{"_source": {"balance": "900", "blockNumber": 5}}, {"_source": {"balance": "1000", "blockNumber": 2}}]}}`), nil),
+	)
+
+	db, _ := New(mockedClient)
+	balances, err := db.GetNativeBalance(address, options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{4: big.NewInt(1000), 5: big.NewInt(900)}, balances)
+}
@@ -0,0 +1,44 @@
+package elasticsearch
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// ProxyDB
+func (es *ElasticsearchDB) RecordProxyImplementation(proxy types.Address, implementation *types.ProxyImplementation) error {
+	req := esapi.IndexRequest{
+		Index:      ProxyIndex,
+		DocumentID: fmt.Sprintf("%s-%d", proxy.String(), implementation.BlockNumber),
+		Body:       esutil.NewJSONReader(ProxyImplementation{Contract: proxy, ProxyImplementation: *implementation}),
+		Refresh:    "true",
+	}
+	_, err := es.apiClient.DoRequest(req)
+	return err
+}
+
+func (es *ElasticsearchDB) GetProxyImplementations(proxy types.Address) ([]*types.ProxyImplementation, error) {
+	results, err := es.apiClient.ScrollAllResults(ProxyIndex, fmt.Sprintf(QueryProxyImplementationsTemplate, proxy.String()))
+	if err != nil {
+		return nil, errors.New("error fetching proxy implementations: " + err.Error())
+	}
+	implementations := make([]*types.ProxyImplementation, len(results))
+	for i, result := range results {
+		data := result.(map[string]interface{})["_source"].(map[string]interface{})
+		implementations[i] = &types.ProxyImplementation{
+			Implementation: types.NewAddress(data["implementation"].(string)),
+			Standard:       data["standard"].(string),
+			BlockNumber:    uint64(data["blockNumber"].(float64)),
+		}
+	}
+	sort.Slice(implementations, func(i, j int) bool {
+		return implementations[i].BlockNumber < implementations[j].BlockNumber
+	})
+	return implementations, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/big"
 	"strconv"
+	"strings"
 
 	"quorumengineering/quorum-report/types"
 )
@@ -35,7 +36,7 @@ func QueryByToAddressWithOptionsTemplate(options *types.QueryOptions) string {
 			"must": [
 				{ "match": { "to": "%s" } },
 ` + createRangeQuery("blockNumber", options.BeginBlockNumber, options.EndBlockNumber) + `,
-` + createRangeQuery("timestamp", options.BeginTimestamp, options.EndTimestamp) + `
+` + createRangeQuery("timestamp", options.BeginTimestamp, options.EndTimestamp) + createValueRangeQuery(options) + `
 			]
 		}
 	}
@@ -113,7 +114,7 @@ func QueryInternalTransactionsWithOptionsTemplate(options *types.QueryOptions) s
 					}
 				},
 ` + createRangeQuery("blockNumber", options.BeginBlockNumber, options.EndBlockNumber) + `,
-` + createRangeQuery("timestamp", options.BeginTimestamp, options.EndTimestamp) + `
+` + createRangeQuery("timestamp", options.BeginTimestamp, options.EndTimestamp) + createValueRangeQuery(options) + `
 			]
 		}
 	}
@@ -226,6 +227,168 @@ func QueryERC20TokenHoldersAtBlock() string {
 `
 }
 
+func QueryERC20HolderBalancesAtBlock(after string) string {
+	afterQuery := ""
+	if after != "" {
+		afterQuery = fmt.Sprintf(`,
+				{ "range": { "holder.keyword": { "gt": "%s" } } }`, after)
+	}
+
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s"} },
+				{ "range": { "blockNumber": { "lte": %d } } }` + afterQuery + `
+			],
+			"must_not": [
+				{ "term": { "amount.keyword": "0" } },
+				{ "term": { "holder.keyword": "0x0000000000000000000000000000000000000000" } }
+			],
+			"filter": [{
+				"bool": {
+					"should": [
+						{ "range": { "heldUntil": { "gte": %d } } },
+						{ "bool": { "must_not": { "exists": { "field": "heldUntil" } } } }
+					]
+				}
+			}]
+		}
+	},
+	"sort": [
+		{ "holder.keyword": "asc" }
+	]
+}
+`
+}
+
+// QueryERC20HolderCountAtBlock matches the same balances as QueryERC20HolderBalancesAtBlock, for counting
+const QueryERC20HolderCountAtBlock = `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s"} },
+				{ "range": { "blockNumber": { "lte": %d } } }
+			],
+			"must_not": [
+				{ "term": { "amount.keyword": "0" } },
+				{ "term": { "holder.keyword": "0x0000000000000000000000000000000000000000" } }
+			],
+			"filter": [{
+				"bool": {
+					"should": [
+						{ "range": { "heldUntil": { "gte": %d } } },
+						{ "bool": { "must_not": { "exists": { "field": "heldUntil" } } } }
+					]
+				}
+			}]
+		}
+	}
+}
+`
+
+func QueryERC20BalanceChanges(afterBlock uint64, afterHolder string) string {
+	searchAfter := ""
+	if afterHolder != "" {
+		searchAfter = fmt.Sprintf(`,
+	"search_after": [%d, "%s"]`, afterBlock, afterHolder)
+	}
+
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s"} },
+				{ "range": { "blockNumber": { "gte": %d, "lte": %d } } }
+			],
+			"must_not": [
+				{ "term": { "holder.keyword": "0x0000000000000000000000000000000000000000" } }
+			]
+		}
+	},
+	"sort": [
+		{ "blockNumber": "asc" },
+		{ "holder.keyword": "asc" }
+	]` + searchAfter + `
+}
+`
+}
+
+func QueryAllERC20BalancesForAccountAtBlock(after string) string {
+	afterQuery := ""
+	if after != "" {
+		afterQuery = fmt.Sprintf(`,
+				{ "range": { "contract.keyword": { "gt": "%s" } } }`, after)
+	}
+
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "holder": "%s"} },
+				{ "range": { "blockNumber": { "lte": %d } } }` + afterQuery + `
+			],
+			"must_not": [
+				{ "term": { "amount.keyword": "0" } }
+			],
+			"filter": [{
+				"bool": {
+					"should": [
+						{ "range": { "heldUntil": { "gte": %d } } },
+						{ "bool": { "must_not": { "exists": { "field": "heldUntil" } } } }
+					]
+				}
+			}]
+		}
+	},
+	"sort": [
+		{ "contract.keyword": "asc" }
+	]
+}
+`
+}
+
+func QueryAllERC721TokensForAccountAtBlock(searchAfter string) string {
+	searchAfterQuery := ""
+	if searchAfter != "" {
+		searchAfterQuery = `,
+	"search_after": ` + searchAfter
+	}
+
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "holder": "%s"} },
+				{ "range": { "heldFrom": { "lte": %d } } }
+			],
+			"filter": [{
+				"bool": {
+					"should": [
+						{ "range": { "heldUntil": { "gte": %d } } },
+						{ "bool": { "must_not": { "exists": { "field": "heldUntil" } } } }
+					]
+				}
+			}]
+		}
+	},
+	"sort": [
+		{ "contract.keyword": "asc" },
+		{ "first": "asc" },
+		{ "second": "asc" },
+		{ "third": "asc" },
+		{ "fourth": "asc" },
+		{ "fifth": "asc" }
+	]` + searchAfterQuery + `
+}
+`
+}
+
 func createRangeQuery(name string, start *big.Int, end *big.Int) string {
 	if end.Cmp(big.NewInt(-1)) == 0 {
 		return fmt.Sprintf(`{ "range": { "%s": { "gte": %s } } }`, name, start.String())
@@ -233,6 +396,21 @@ func createRangeQuery(name string, start *big.Int, end *big.Int) string {
 	return fmt.Sprintf(`{ "range": { "%s": { "gte": %s, "lte": %s } } }`, name, start.String(), end.String())
 }
 
+// createValueRangeQuery appends a range on the sortable value of transactions, if the options
+// bound the value. Unlike block and time ranges, the bounds are compared as keywords.
+func createValueRangeQuery(options *types.QueryOptions) string {
+	if !options.HasValueRange() {
+		return ""
+	}
+	beginKey := amountKey(options.BeginValue)
+	if options.EndValue == nil || options.EndValue.Cmp(big.NewInt(-1)) == 0 {
+		return fmt.Sprintf(`,
+{ "range": { "valueKey": { "gte": "%s" } } }`, beginKey)
+	}
+	return fmt.Sprintf(`,
+{ "range": { "valueKey": { "gte": "%s", "lte": "%s" } } }`, beginKey, amountKey(options.EndValue))
+}
+
 func QueryERC721TokenAtBlock() string {
 	return `
 {
@@ -342,6 +520,443 @@ func QueryERC721AllHoldersAtBlock() string {
 `
 }
 
+func QueryERC721TokenTransfers(options *types.TokenQueryOptions) string {
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s"} },
+				{ "match": { "tokenId": "%s"} },
+				` + createRangeQuery("blockNumber", options.BeginBlockNumber, options.EndBlockNumber) + `
+			]
+		}
+	}
+}
+`
+}
+
+// QueryTokenTransfers builds a query for the transfers matching the given filters, in the
+// order they took place, starting after the transfer at the given block and log index
+func QueryTokenTransfers(contract *types.Address, counterparty *types.Address, direction string, options *types.TokenTransferQueryOptions, afterBlock uint64, afterLogIndex uint64, hasCursor bool) string {
+	filters := []string{
+		createRangeQuery("blockNumber", options.BeginBlockNumber, options.EndBlockNumber),
+		createRangeQuery("timestamp", options.BeginTimestamp, options.EndTimestamp),
+	}
+	if contract != nil {
+		filters = append(filters, fmt.Sprintf(`{ "match": { "contract": "%s" } }`, contract.String()))
+	}
+	if counterparty != nil {
+		var sides []string
+		if direction != types.TransferDirectionOut {
+			sides = append(sides, fmt.Sprintf(`{ "match": { "to": "%s" } }`, counterparty.String()))
+		}
+		if direction != types.TransferDirectionIn {
+			sides = append(sides, fmt.Sprintf(`{ "match": { "from": "%s" } }`, counterparty.String()))
+		}
+		filters = append(filters, fmt.Sprintf(`{ "bool": { "should": [ %s ], "minimum_should_match": 1 } }`, strings.Join(sides, ", ")))
+	}
+
+	searchAfter := ""
+	if hasCursor {
+		searchAfter = fmt.Sprintf(`"search_after": [ %d, %d ],`, afterBlock, afterLogIndex)
+	}
+
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				` + strings.Join(filters, ",\n\t\t\t\t") + `
+			]
+		}
+	},
+	` + searchAfter + `
+	"sort": [
+		{ "blockNumber": "asc" },
+		{ "logIndex": "asc" }
+	]
+}
+`
+}
+
+// QueryNativeTransfers builds a query for the native transfers to or from the address, in the
+// order they took place, starting after the transfer at the given block and index
+func QueryNativeTransfers(address types.Address, direction string, options *types.TokenTransferQueryOptions, afterBlock uint64, afterIndex uint64, hasCursor bool) string {
+	var sides []string
+	if direction != types.TransferDirectionOut {
+		sides = append(sides, fmt.Sprintf(`{ "match": { "to": "%s" } }`, address.String()))
+	}
+	if direction != types.TransferDirectionIn {
+		sides = append(sides, fmt.Sprintf(`{ "match": { "from": "%s" } }`, address.String()))
+	}
+	filters := []string{
+		createRangeQuery("blockNumber", options.BeginBlockNumber, options.EndBlockNumber),
+		createRangeQuery("timestamp", options.BeginTimestamp, options.EndTimestamp),
+		fmt.Sprintf(`{ "bool": { "should": [ %s ], "minimum_should_match": 1 } }`, strings.Join(sides, ", ")),
+	}
+
+	searchAfter := ""
+	if hasCursor {
+		searchAfter = fmt.Sprintf(`"search_after": [ %d, %d ],`, afterBlock, afterIndex)
+	}
+
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				` + strings.Join(filters, ",\n\t\t\t\t") + `
+			]
+		}
+	},
+	` + searchAfter + `
+	"sort": [
+		{ "blockNumber": "asc" },
+		{ "index": "asc" }
+	]
+}
+`
+}
+
+// QueryNativeBalanceAtBlock gets the latest balance of the address recorded at or before the block
+const QueryNativeBalanceAtBlock = `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "address": "%s"} },
+				{ "range": { "blockNumber": { "lte": %d } } }
+			]
+		}
+	},
+	"sort": [
+		{
+			"blockNumber": {
+				"order": "desc",
+				"unmapped_type": "long"
+			}
+		}
+	]
+}
+`
+
+// QueryNativeBalanceAtBlockRange gets all the balances of the address in a block range
+func QueryNativeBalanceAtBlockRange(address types.Address, begin *big.Int, end *big.Int) string {
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "address": "` + address.String() + `"} },
+				` + createRangeQuery("blockNumber", begin, end) + `
+			]
+		}
+	}
+}
+`
+}
+
+// QueryTransactionFailures gets the failures of transactions sent to the address in a block
+// range, optionally only those with the given reason
+func QueryTransactionFailures(address types.Address, reason string, options *types.PageOptions) string {
+	reasonQuery := ""
+	if reason != "" {
+		reasonQuery = `
+				{ "term": { "reason": ` + strconv.Quote(reason) + ` } },`
+	}
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "address": "` + address.String() + `"} },` + reasonQuery + `
+				` + createRangeQuery("blockNumber", options.BeginBlockNumber, options.EndBlockNumber) + `
+			]
+		}
+	}
+}
+`
+}
+
+func QueryERC20AllowanceAtBlock() string {
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s"} },
+				{ "match": { "owner": "%s"} },
+				{ "match": { "spender": "%s"} },
+				{ "range": { "approvedFrom": { "lte": %d } } }
+			]
+		}
+	},
+	"sort": [
+		{
+			"approvedFrom": {
+				"order": "desc",
+				"unmapped_type": "long"
+			}
+		}
+	]
+}
+`
+}
+
+func QueryERC20ApprovalsForOwnerAtBlock(after string) string {
+	afterQuery := ""
+	if after != "" {
+		afterQuery = fmt.Sprintf(`,
+				{ "range": { "spender.keyword": { "gt": "%s" } } }`, after)
+	}
+
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s"} },
+				{ "match": { "owner": "%s"} },
+				{ "range": { "approvedFrom": { "lte": %d } } }` + afterQuery + `
+			],
+			"must_not": [
+				{ "term": { "amount.keyword": "0" } }
+			],
+			"filter": [{
+				"bool": {
+					"should": [
+						{ "range": { "approvedUntil": { "gte": %d } } },
+						{ "bool": { "must_not": { "exists": { "field": "approvedUntil" } } } }
+					]
+				}
+			}]
+		}
+	},
+	"sort": [
+		{ "spender.keyword": "asc" }
+	]
+}
+`
+}
+
+func QueryTotalSupplyAtBlock() string {
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s"} },
+				{ "range": { "blockNumber": { "lte": %d } } }
+			]
+		}
+	},
+	"sort": [
+		{
+			"blockNumber": {
+				"order": "desc",
+				"unmapped_type": "long"
+			}
+		}
+	]
+}
+`
+}
+
+// QueryTotalSupplyAtBlockRange gets all the supplies between a block range, as well as the
+// last supply before the starting block if there was no change on the starting block
+func QueryTotalSupplyAtBlockRange(options *types.TokenQueryOptions) string {
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s"} }
+			],
+			"filter": [{
+				"bool": {
+					"should": [
+						` + createRangeQuery("blockNumber", options.BeginBlockNumber, options.EndBlockNumber) + `,
+						{
+							"bool": {
+								"must": [ { "range": { "blockNumber": { "lt": ` + options.BeginBlockNumber.String() + ` } } } ],
+								"filter": [{
+									"bool": {
+										"should": [
+											{ "range": { "supplyUntil": { "gte": ` + options.BeginBlockNumber.String() + ` } } },
+											{ "bool": { "must_not": { "exists": { "field": "supplyUntil" } } } }
+										]
+									}
+								}]
+							}
+						}
+					]
+				}
+			}]
+		}
+	}
+}
+`
+}
+
+func QueryERC1155BalanceAtBlock() string {
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s"} },
+				{ "match": { "holder": "%s"} },
+				{ "match": { "token": "%s"} },
+				{ "range": { "heldFrom": { "lte": %d } } }
+			]
+		}
+	},
+	"sort": [
+		{
+			"heldFrom": {
+				"order": "desc",
+				"unmapped_type": "long"
+			}
+		}
+	]
+}
+`
+}
+
+func QueryERC1155TokensForAccountAtBlock(start *big.Int) string {
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s"} },
+				{ "match": { "holder": "%s"} },
+				{ "range": { "heldFrom": { "lte": %d } } },
+` + createTokenRangeQuery(start) + `
+			],
+			"must_not": [
+				{ "term": { "amount.keyword": "0" } }
+			],
+			"filter": [{
+                "bool": {
+                    "should": [
+						{ "range": { "heldUntil": { "gte": %d } } }, 
+						{ "bool": { "must_not": { "exists": { "field": "heldUntil" } } } }
+					]
+                }
+            }]
+		}
+	}
+}
+`
+}
+
+func QueryERC1155HoldersAtBlock() string {
+	return `
+{
+	"_source": ["token", "holder"],
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s"} },
+				{ "match": { "token": "%s"} },
+				{ "range": { "heldFrom": { "lte": %d } } }
+			],
+			"must_not": [
+				{ "term": { "amount.keyword": "0" } }
+			],
+			"filter": [{
+                "bool": {
+                    "should": [
+						{ "range": { "heldUntil": { "gte": %d } } }, 
+						{ "bool": { "must_not": { "exists": { "field": "heldUntil" } } } }
+					]
+                }
+            }]
+		}
+	},
+	"size": 0,
+	"aggs" : {
+		"result_buckets": {
+			"composite" : {
+				"size": %d,
+				%s
+				"sources" : [
+					{ "holder": { "terms" : { "field": "holder.keyword" } } }
+				]
+		  	}
+		}
+	}
+}
+`
+}
+
+func QueryTokenBalanceAtBlock() string {
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "standard": "%s"} },
+				{ "match": { "contract": "%s"} },
+				{ "match": { "holder": "%s"} },
+				{ "range": { "heldFrom": { "lte": %d } } }
+			]
+		}
+	},
+	"sort": [
+		{
+			"heldFrom": {
+				"order": "desc",
+				"unmapped_type": "long"
+			}
+		}
+	]
+}
+`
+}
+
+func QueryTokenHoldersAtBlock() string {
+	return `
+{
+	"_source": ["holder"],
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "standard": "%s"} },
+				{ "match": { "contract": "%s"} },
+				{ "range": { "heldFrom": { "lte": %d } } }
+			],
+			"must_not": [
+				{ "term": { "amount.keyword": "0" } }
+			],
+			"filter": [{
+                "bool": {
+                    "should": [
+						{ "range": { "heldUntil": { "gte": %d } } }, 
+						{ "bool": { "must_not": { "exists": { "field": "heldUntil" } } } }
+					]
+                }
+            }]
+		}
+	},
+	"size": 0,
+	"aggs" : {
+		"result_buckets": {
+			"composite" : {
+				"size": %d,
+				%s
+				"sources" : [
+					{ "holder": { "terms" : { "field": "holder.keyword" } } }
+				]
+		  	}
+		}
+	}
+}
+`
+}
+
 func createTokenRangeQuery(start *big.Int) string {
 	next := new(big.Int).Add(start, big.NewInt(1))
 
@@ -361,3 +976,117 @@ func createTokenRangeQuery(start *big.Int) string {
 		fmt.Sprintf(`{ "range": { "%s": { "gte": %d } } }`, "fifth", startFifth),
 	)
 }
+
+const QueryAllChainReorgsTemplate = `
+{
+	"query": {
+		"match_all": {}
+	}
+}
+`
+
+// QueryOrphanedTransactionsTemplate fetches the hashes of all transactions above
+// the given common ancestor block
+const QueryOrphanedTransactionsTemplate = `
+{
+	"_source": ["hash"],
+	"query": {
+		"range": { "blockNumber": { "gt": %d } }
+	}
+}
+`
+
+// DeleteAboveBlockQueryTemplate matches all documents with the given field above the
+// common ancestor block
+const DeleteAboveBlockQueryTemplate = `{ "query": { "range": { "%s": { "gt": %d } } } }`
+
+// ReopenHeldUntilQueryTemplate clears the "heldUntil" of token records that were
+// superseded at or after the common ancestor block
+const ReopenHeldUntilQueryTemplate = `
+{
+	"script": { "source": "ctx._source.heldUntil = null", "lang": "painless" },
+	"query": {
+		"range": { "heldUntil": { "gte": %d } }
+	}
+}
+`
+
+// ReopenUntilQueryTemplate clears the given "until" field of records that were superseded
+// in blocks after the common ancestor
+const ReopenUntilQueryTemplate = `
+{
+	"script": { "source": "ctx._source.%s = null", "lang": "painless" },
+	"query": {
+		"range": { "%s": { "gte": %d } }
+	}
+}
+`
+
+// DeleteContractInRangeQueryTemplate matches the documents of the given contract with the
+// given field in the block range
+const DeleteContractInRangeQueryTemplate = `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s" } },
+				{ "range": { "%s": { "gte": %d, "lte": %d } } }
+			]
+		}
+	}
+}
+`
+
+// ReopenContractUntilQueryTemplate clears the given "until" field of the records of the given
+// contract that were superseded in the block range
+const ReopenContractUntilQueryTemplate = `
+{
+	"script": { "source": "ctx._source.%s = null", "lang": "painless" },
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s" } },
+				{ "range": { "%s": { "gte": %d, "lt": %d } } }
+			]
+		}
+	}
+}
+`
+
+// RollbackLastFilteredQueryTemplate clamps the "lastFiltered" of all contracts to the
+// common ancestor block
+const RollbackLastFilteredQueryTemplate = `
+{
+	"script": { "source": "ctx._source.lastFiltered = params.ancestor", "lang": "painless", "params": { "ancestor": %d } },
+	"query": {
+		"range": { "lastFiltered": { "gt": %d } }
+	}
+}
+`
+
+// ClearCreationTxQueryTemplate clears the creation transaction of all contracts created
+// by one of the given orphaned transactions
+const ClearCreationTxQueryTemplate = `
+{
+	"script": { "source": "ctx._source.creationTx = ''", "lang": "painless" },
+	"query": {
+		"terms": { "creationTx": [%s] }
+	}
+}
+`
+
+const QueryProxyImplementationsTemplate = `
+{
+	"query": {
+		"match": { "contract": "%s" }
+	}
+}
+`
+
+const QueryAllBackfillJobsTemplate = `
+{
+	"query": {
+		"match_all": {}
+	}
+}
+`
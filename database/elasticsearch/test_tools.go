@@ -59,6 +59,28 @@ func (rm *SearchRequestMatcher) String() string {
 	return fmt.Sprintf("SearchRequestMatcher{%s/%d/%d/%s/%s}", rm.req.Index, rm.req.From, rm.req.Size, rm.req.Sort, rm.body)
 }
 
+type CountRequestMatcher struct {
+	req  esapi.CountRequest
+	body string
+}
+
+func NewCountRequestMatcher(req esapi.CountRequest) *CountRequestMatcher {
+	body, _ := ioutil.ReadAll(req.Body)
+	return &CountRequestMatcher{req: req, body: string(body)}
+}
+
+func (rm *CountRequestMatcher) Matches(x interface{}) bool {
+	if val, ok := x.(esapi.CountRequest); ok {
+		actualBody, _ := ioutil.ReadAll(val.Body)
+		return val.Index[0] == rm.req.Index[0] && string(actualBody) == rm.body
+	}
+	return false
+}
+
+func (rm *CountRequestMatcher) String() string {
+	return fmt.Sprintf("CountRequestMatcher{%s/%s}", rm.req.Index, rm.body)
+}
+
 type DeleteRequestMatcher struct {
 	req esapi.DeleteRequest
 }
@@ -92,7 +114,7 @@ func (rm *DeleteByQueryRequestMatcher) Matches(x interface{}) bool {
 		actualBody, _ := ioutil.ReadAll(val.Body)
 		a := string(expectedBody)
 		b := string(actualBody)
-		return len(rm.req.Index) == len(val.Index) && a == b
+		return assert.ObjectsAreEqual(rm.req.Index, val.Index) && a == b
 	}
 	return false
 }
@@ -101,6 +123,28 @@ func (rm *DeleteByQueryRequestMatcher) String() string {
 	return fmt.Sprintf("DeleteByQueryRequestMatcher{%s}", rm.req.Index)
 }
 
+type UpdateByQueryRequestMatcher struct {
+	req  esapi.UpdateByQueryRequest
+	body string
+}
+
+func NewUpdateByQueryRequestMatcher(req esapi.UpdateByQueryRequest) *UpdateByQueryRequestMatcher {
+	body, _ := ioutil.ReadAll(req.Body)
+	return &UpdateByQueryRequestMatcher{req: req, body: string(body)}
+}
+
+func (rm *UpdateByQueryRequestMatcher) Matches(x interface{}) bool {
+	if val, ok := x.(esapi.UpdateByQueryRequest); ok {
+		actualBody, _ := ioutil.ReadAll(val.Body)
+		return assert.ObjectsAreEqual(rm.req.Index, val.Index) && string(actualBody) == rm.body
+	}
+	return false
+}
+
+func (rm *UpdateByQueryRequestMatcher) String() string {
+	return fmt.Sprintf("UpdateByQueryRequestMatcher{%s/%s}", rm.req.Index, rm.body)
+}
+
 type UpdateRequestMatcher struct {
 	req esapi.UpdateRequest
 }
@@ -1,6 +1,7 @@
 package elasticsearch
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -36,7 +37,6 @@ func (es *ElasticsearchDB) RecordNewERC20Balance(contract types.Address, holder
 		DocumentID: fmt.Sprintf("%s-%s-%d", contract.String(), holder.String(), block),
 		Body:       esutil.NewJSONReader(tokenInfo),
 		Refresh:    "true",
-		OpType:     "create",
 	}
 
 	if _, err := es.apiClient.DoRequest(req); err != nil {
@@ -165,6 +165,121 @@ func (es *ElasticsearchDB) GetAllTokenHolders(contract types.Address, block uint
 	return convertedResults, nil
 }
 
+func (es *ElasticsearchDB) ERC20HolderBalancesAtBlock(contract types.Address, block uint64) (map[types.Address]*big.Int, error) {
+	pageSize := 1000
+	balances := make(map[types.Address]*big.Int)
+	after := ""
+	for {
+		formattedQuery := fmt.Sprintf(QueryERC20HolderBalancesAtBlock(after), contract.String(), block, block)
+		searchReq := esapi.SearchRequest{
+			Index: []string{ERC20TokenIndex},
+			Body:  strings.NewReader(formattedQuery),
+			Size:  &pageSize,
+		}
+		results, err := es.doSearchRequest(searchReq)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, result := range results.Hits.Hits {
+			var balance ERC20TokenHolder
+			if err := mapstructure.Decode(result.Source, &balance); err != nil {
+				return nil, err
+			}
+			amount, success := new(big.Int).SetString(balance.Amount, 10)
+			if !success {
+				return nil, errors.New("could not parse token value")
+			}
+			balances[types.NewAddress(string(balance.Holder))] = amount
+			after = string(balance.Holder)
+		}
+		if len(results.Hits.Hits) < pageSize {
+			return balances, nil
+		}
+	}
+}
+
+func (es *ElasticsearchDB) ERC20HolderCountAtBlock(contract types.Address, block uint64) (uint64, error) {
+	req := esapi.CountRequest{
+		Index: []string{ERC20TokenIndex},
+		Body:  strings.NewReader(fmt.Sprintf(QueryERC20HolderCountAtBlock, contract.String(), block, block)),
+	}
+	results, err := es.doCountRequest(req)
+	if err != nil {
+		return 0, err
+	}
+	return results.Count, nil
+}
+
+func (es *ElasticsearchDB) AllERC20BalancesForAccountAtBlock(holder types.Address, block uint64) (map[types.Address]*big.Int, error) {
+	pageSize := 1000
+	balances := make(map[types.Address]*big.Int)
+	after := ""
+	for {
+		formattedQuery := fmt.Sprintf(QueryAllERC20BalancesForAccountAtBlock(after), holder.String(), block, block)
+		searchReq := esapi.SearchRequest{
+			Index: []string{ERC20TokenIndex},
+			Body:  strings.NewReader(formattedQuery),
+			Size:  &pageSize,
+		}
+		results, err := es.doSearchRequest(searchReq)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, result := range results.Hits.Hits {
+			var balance ERC20TokenHolder
+			if err := mapstructure.Decode(result.Source, &balance); err != nil {
+				return nil, err
+			}
+			amount, success := new(big.Int).SetString(balance.Amount, 10)
+			if !success {
+				return nil, errors.New("could not parse token value")
+			}
+			balances[types.NewAddress(string(balance.Contract))] = amount
+			after = string(balance.Contract)
+		}
+		if len(results.Hits.Hits) < pageSize {
+			return balances, nil
+		}
+	}
+}
+
+func (es *ElasticsearchDB) ERC20BalanceChanges(contract types.Address, fromBlock uint64, toBlock uint64) ([]types.ERC20BalanceChange, error) {
+	pageSize := 1000
+	changes := make([]types.ERC20BalanceChange, 0)
+	var afterBlock uint64
+	afterHolder := ""
+	for {
+		formattedQuery := fmt.Sprintf(QueryERC20BalanceChanges(afterBlock, afterHolder), contract.String(), fromBlock, toBlock)
+		searchReq := esapi.SearchRequest{
+			Index: []string{ERC20TokenIndex},
+			Body:  strings.NewReader(formattedQuery),
+			Size:  &pageSize,
+		}
+		results, err := es.doSearchRequest(searchReq)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, result := range results.Hits.Hits {
+			var balance ERC20TokenHolder
+			if err := mapstructure.Decode(result.Source, &balance); err != nil {
+				return nil, err
+			}
+			changes = append(changes, types.ERC20BalanceChange{
+				Holder:      types.NewAddress(string(balance.Holder)),
+				BlockNumber: balance.BlockNumber,
+				Amount:      balance.Amount,
+			})
+			afterBlock, afterHolder = balance.BlockNumber, string(balance.Holder)
+		}
+		if len(results.Hits.Hits) < pageSize {
+			return changes, nil
+		}
+	}
+}
+
 func (es *ElasticsearchDB) RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error {
 	//find old entry
 	existingTokenEntry, errExisting := es.ERC721TokenByTokenID(contract, block-1, tokenId)
@@ -196,7 +311,6 @@ func (es *ElasticsearchDB) RecordERC721Token(contract types.Address, holder type
 		DocumentID: fmt.Sprintf("%s-%s-%d", contract.String(), tokenId.String(), block),
 		Body:       esutil.NewJSONReader(tokenHolderInfo),
 		Refresh:    "true",
-		OpType:     "create", //This will only create if the contract does not exist
 	}
 
 	if _, err := es.apiClient.DoRequest(req); err != nil {
@@ -374,3 +488,755 @@ func (es *ElasticsearchDB) AllHoldersAtBlock(contract types.Address, block uint6
 	}
 	return convertedResults, nil
 }
+
+func (es *ElasticsearchDB) RecordERC20Allowance(contract types.Address, owner types.Address, spender types.Address, block uint64, amount *big.Int) error {
+	//find old entry
+	existingEntry, errExisting := es.erc20AllowanceEntryAtBlock(contract, owner, spender, block-1)
+	if errExisting != nil && errExisting != database.ErrNotFound {
+		return errExisting
+	}
+
+	//add new entry
+	allowance := types.ERC20Allowance{
+		Contract:     contract,
+		Owner:        owner,
+		Spender:      spender,
+		Amount:       amount.String(),
+		ApprovedFrom: block,
+	}
+
+	req := esapi.IndexRequest{
+		Index:      ERC20AllowanceIndex,
+		DocumentID: fmt.Sprintf("%s-%s-%s-%d", contract.String(), owner.String(), spender.String(), block),
+		Body:       esutil.NewJSONReader(allowance),
+		Refresh:    "true",
+	}
+
+	if _, err := es.apiClient.DoRequest(req); err != nil {
+		return err
+	}
+
+	if errExisting == database.ErrNotFound {
+		return nil
+	}
+
+	//update the older entry
+	query := map[string]interface{}{
+		"doc": map[string]interface{}{
+			"approvedUntil": block - 1,
+		},
+	}
+
+	updateRequest := esapi.UpdateRequest{
+		Index:      ERC20AllowanceIndex,
+		DocumentID: fmt.Sprintf("%s-%s-%s-%d", contract.String(), owner.String(), spender.String(), existingEntry.ApprovedFrom),
+		Body:       esutil.NewJSONReader(query),
+		Refresh:    "true",
+	}
+
+	_, err := es.apiClient.DoRequest(updateRequest)
+	return err
+}
+
+func (es *ElasticsearchDB) ERC20AllowanceAtBlock(contract types.Address, owner types.Address, spender types.Address, block uint64) (*big.Int, error) {
+	entry, err := es.erc20AllowanceEntryAtBlock(contract, owner, spender, block)
+	if err == database.ErrNotFound {
+		return big.NewInt(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	amount, success := new(big.Int).SetString(entry.Amount, 10)
+	if !success {
+		return nil, errors.New("could not parse allowance value")
+	}
+	return amount, nil
+}
+
+func (es *ElasticsearchDB) ERC20ApprovalsForOwnerAtBlock(contract types.Address, owner types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC20Allowance, error) {
+	if options.PageSize > 1000 {
+		return nil, ErrPaginationLimitExceeded
+	}
+
+	after := ""
+	if options.After != "" {
+		afterAddress := types.NewAddress(options.After)
+		after = afterAddress.String()
+	}
+
+	formattedQuery := fmt.Sprintf(QueryERC20ApprovalsForOwnerAtBlock(after), contract.String(), owner.String(), block, block)
+
+	searchReq := esapi.SearchRequest{
+		Index: []string{ERC20AllowanceIndex},
+		Body:  strings.NewReader(formattedQuery),
+		Size:  &options.PageSize,
+	}
+
+	results, err := es.doSearchRequest(searchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	convertedResults := make([]types.ERC20Allowance, 0, len(results.Hits.Hits))
+	for _, result := range results.Hits.Hits {
+		allowance := new(types.ERC20Allowance)
+		if err := mapstructure.Decode(result.Source, allowance); err != nil {
+			return nil, err
+		}
+		allowance.Contract = types.NewAddress(string(allowance.Contract))
+		allowance.Owner = types.NewAddress(string(allowance.Owner))
+		allowance.Spender = types.NewAddress(string(allowance.Spender))
+		convertedResults = append(convertedResults, *allowance)
+	}
+	return convertedResults, nil
+}
+
+func (es *ElasticsearchDB) AllERC721TokensForAccountAtBlock(holder types.Address, block uint64, options *types.AccountTokenQueryOptions) ([]types.ERC721Token, error) {
+	afterContract, afterId, hasCursor, err := options.Cursor()
+	if err != nil {
+		return nil, err
+	}
+	// the page continues after the sort values of the token given by the cursor
+	searchAfter := ""
+	if hasCursor {
+		searchAfter = fmt.Sprintf(`["%s", %s]`, afterContract.String(), strings.Join(tokenIdSortValues(afterId.String()), ", "))
+	}
+
+	formattedQuery := fmt.Sprintf(QueryAllERC721TokensForAccountAtBlock(searchAfter), holder.String(), block, block)
+	searchReq := esapi.SearchRequest{
+		Index: []string{ERC721TokenIndex},
+		Body:  strings.NewReader(formattedQuery),
+		Size:  &options.PageSize,
+	}
+	results, err := es.doSearchRequest(searchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]types.ERC721Token, 0, len(results.Hits.Hits))
+	for _, result := range results.Hits.Hits {
+		token := new(types.ERC721Token)
+		if err := mapstructure.Decode(result.Source, token); err != nil {
+			return nil, err
+		}
+		token.Holder = types.NewAddress(string(token.Holder))
+		token.Contract = types.NewAddress(string(token.Contract))
+		tokens = append(tokens, *token)
+	}
+	return tokens, nil
+}
+
+// tokenIdSortValues splits a token ID into the five parts it is sorted by, as stored with
+// each ERC721 token
+func tokenIdSortValues(tokenId string) []string {
+	id, _ := new(big.Int).SetString(tokenId, 10)
+	paddedTokenId := fmt.Sprintf("%085d", id)
+	values := make([]string, 0, 5)
+	for i := 0; i < 85; i += 17 {
+		part, _ := strconv.ParseUint(paddedTokenId[i:i+17], 10, 64)
+		values = append(values, strconv.FormatUint(part, 10))
+	}
+	return values
+}
+
+func (es *ElasticsearchDB) RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error {
+	//find old entry
+	existingTokenEntry, errExisting := es.erc1155EntryAtBlock(contract, holder, block-1, tokenId)
+	if errExisting != nil && errExisting != database.ErrNotFound {
+		return errExisting
+	}
+
+	paddedTokenId := fmt.Sprintf("%085d", tokenId)
+	first, _ := strconv.ParseUint(paddedTokenId[0:17], 10, 64)
+	second, _ := strconv.ParseUint(paddedTokenId[17:34], 10, 64)
+	third, _ := strconv.ParseUint(paddedTokenId[34:51], 10, 64)
+	fourth, _ := strconv.ParseUint(paddedTokenId[51:68], 10, 64)
+	fifth, _ := strconv.ParseUint(paddedTokenId[68:85], 10, 64)
+
+	//add new entry
+	tokenInfo := SortableERC1155Token{
+		types.ERC1155Token{
+			Contract: contract,
+			Holder:   holder,
+			Token:    tokenId.String(),
+			Amount:   amount.String(),
+			HeldFrom: block,
+		},
+		first, second, third, fourth, fifth,
+	}
+
+	req := esapi.IndexRequest{
+		Index:      ERC1155TokenIndex,
+		DocumentID: fmt.Sprintf("%s-%s-%s-%d", contract.String(), holder.String(), tokenId.String(), block),
+		Body:       esutil.NewJSONReader(tokenInfo),
+		Refresh:    "true",
+	}
+
+	if _, err := es.apiClient.DoRequest(req); err != nil {
+		return err
+	}
+
+	/////
+
+	if errExisting == database.ErrNotFound {
+		return nil
+	}
+
+	//update the older entry
+	query := map[string]interface{}{
+		"doc": map[string]interface{}{
+			"heldUntil": block - 1,
+		},
+	}
+
+	updateRequest := esapi.UpdateRequest{
+		Index:      ERC1155TokenIndex,
+		DocumentID: fmt.Sprintf("%s-%s-%s-%d", contract.String(), holder.String(), tokenId.String(), existingTokenEntry.HeldFrom),
+		Body:       esutil.NewJSONReader(query),
+		Refresh:    "true",
+	}
+
+	_, err := es.apiClient.DoRequest(updateRequest)
+	return err
+}
+
+func (es *ElasticsearchDB) ERC1155BalanceAtBlock(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) (*big.Int, error) {
+	entry, err := es.erc1155EntryAtBlock(contract, holder, block, tokenId)
+	if err == database.ErrNotFound {
+		return big.NewInt(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	amount, success := new(big.Int).SetString(entry.Amount, 10)
+	if !success {
+		return nil, errors.New("could not parse token value")
+	}
+	return amount, nil
+}
+
+func (es *ElasticsearchDB) ERC1155TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC1155Token, error) {
+	startTokenId := big.NewInt(-1)
+	if options.After != "" {
+		parsed, success := new(big.Int).SetString(options.After, 10)
+		if !success {
+			return nil, errors.New(`could not parse "after" token ID`)
+		}
+		startTokenId = parsed
+	}
+
+	formattedQuery := fmt.Sprintf(QueryERC1155TokensForAccountAtBlock(startTokenId), contract.String(), holder.String(), block, block)
+
+	from := options.PageSize * options.PageNumber
+	if from+options.PageSize > 1000 {
+		return nil, ErrPaginationLimitExceeded
+	}
+
+	searchReq := esapi.SearchRequest{
+		Index: []string{ERC1155TokenIndex},
+		Body:  strings.NewReader(formattedQuery),
+		From:  &from,
+		Size:  &options.PageSize,
+		Sort:  []string{"first:asc", "second:asc", "third:asc", "fourth:asc", "fifth:asc"},
+	}
+
+	results, err := es.doSearchRequest(searchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	convertedResults := make([]types.ERC1155Token, 0, len(results.Hits.Hits))
+	for _, result := range results.Hits.Hits {
+		tokenResult := new(types.ERC1155Token)
+		if err := mapstructure.Decode(result.Source, tokenResult); err != nil {
+			return nil, err
+		}
+		tokenResult.Holder = types.NewAddress(string(tokenResult.Holder))
+		tokenResult.Contract = types.NewAddress(string(tokenResult.Contract))
+		convertedResults = append(convertedResults, *tokenResult)
+	}
+	return convertedResults, nil
+}
+
+func (es *ElasticsearchDB) ERC1155HoldersAtBlock(contract types.Address, block uint64, tokenId *big.Int, options *types.TokenQueryOptions) ([]types.Address, error) {
+	if options.PageSize > 1000 {
+		return nil, ErrPaginationLimitExceeded
+	}
+
+	afterQuery := ""
+	if options.After != "" {
+		afterQuery = fmt.Sprintf(`"after": { "holder": "%s"},`, options.After)
+	}
+
+	formattedQuery := fmt.Sprintf(QueryERC1155HoldersAtBlock(), contract.String(), tokenId.String(), block, block, options.PageSize, afterQuery)
+
+	searchReq := esapi.SearchRequest{
+		Index: []string{ERC1155TokenIndex},
+		Body:  strings.NewReader(formattedQuery),
+	}
+
+	results, err := es.doSearchRequest(searchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	var aggResult ERC721HolderAggregateResult
+	rawAggResult := results.Aggregations.Results
+	if err := mapstructure.Decode(rawAggResult, &aggResult); err != nil {
+		return nil, err
+	}
+
+	convertedResults := make([]types.Address, 0, len(aggResult.Buckets))
+	for _, result := range aggResult.Buckets {
+		convertedResults = append(convertedResults, types.NewAddress(result.Key.Holder))
+	}
+	return convertedResults, nil
+}
+
+func (es *ElasticsearchDB) RecordTokenBalance(standard string, contract types.Address, holder types.Address, block uint64, amount *big.Int) error {
+	//find old entry
+	existingEntry, errExisting := es.tokenBalanceEntryAtBlock(standard, contract, holder, block-1)
+	if errExisting != nil && errExisting != database.ErrNotFound {
+		return errExisting
+	}
+
+	//add new entry
+	balance := types.TokenBalance{
+		Standard: standard,
+		Contract: contract,
+		Holder:   holder,
+		Amount:   amount.String(),
+		HeldFrom: block,
+	}
+
+	req := esapi.IndexRequest{
+		Index:      TokenBalanceIndex,
+		DocumentID: fmt.Sprintf("%s-%s-%s-%d", standard, contract.String(), holder.String(), block),
+		Body:       esutil.NewJSONReader(balance),
+		Refresh:    "true",
+	}
+
+	if _, err := es.apiClient.DoRequest(req); err != nil {
+		return err
+	}
+
+	if errExisting == database.ErrNotFound {
+		return nil
+	}
+
+	//update the older entry
+	query := map[string]interface{}{
+		"doc": map[string]interface{}{
+			"heldUntil": block - 1,
+		},
+	}
+
+	updateRequest := esapi.UpdateRequest{
+		Index:      TokenBalanceIndex,
+		DocumentID: fmt.Sprintf("%s-%s-%s-%d", standard, contract.String(), holder.String(), existingEntry.HeldFrom),
+		Body:       esutil.NewJSONReader(query),
+		Refresh:    "true",
+	}
+
+	_, err := es.apiClient.DoRequest(updateRequest)
+	return err
+}
+
+func (es *ElasticsearchDB) TokenBalanceAtBlock(standard string, contract types.Address, holder types.Address, block uint64) (*big.Int, error) {
+	entry, err := es.tokenBalanceEntryAtBlock(standard, contract, holder, block)
+	if err == database.ErrNotFound {
+		return big.NewInt(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	amount, success := new(big.Int).SetString(entry.Amount, 10)
+	if !success {
+		return nil, errors.New("could not parse token value")
+	}
+	return amount, nil
+}
+
+func (es *ElasticsearchDB) TokenHoldersAtBlock(standard string, contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	if options.PageSize > 1000 {
+		return nil, ErrPaginationLimitExceeded
+	}
+
+	afterQuery := ""
+	if options.After != "" {
+		afterQuery = fmt.Sprintf(`"after": { "holder": "%s"},`, options.After)
+	}
+
+	formattedQuery := fmt.Sprintf(QueryTokenHoldersAtBlock(), standard, contract.String(), block, block, options.PageSize, afterQuery)
+
+	searchReq := esapi.SearchRequest{
+		Index: []string{TokenBalanceIndex},
+		Body:  strings.NewReader(formattedQuery),
+	}
+
+	results, err := es.doSearchRequest(searchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	var aggResult ERC721HolderAggregateResult
+	rawAggResult := results.Aggregations.Results
+	if err := mapstructure.Decode(rawAggResult, &aggResult); err != nil {
+		return nil, err
+	}
+
+	convertedResults := make([]types.Address, 0, len(aggResult.Buckets))
+	for _, result := range aggResult.Buckets {
+		convertedResults = append(convertedResults, types.NewAddress(result.Key.Holder))
+	}
+	return convertedResults, nil
+}
+
+func (es *ElasticsearchDB) RecordTokenTransfers(transfers []types.TokenTransfer) error {
+	for _, transfer := range transfers {
+		req := esapi.IndexRequest{
+			Index:      TokenTransferIndex,
+			DocumentID: fmt.Sprintf("%s-%d-%d", transfer.Contract.String(), transfer.BlockNumber, transfer.LogIndex),
+			Body:       esutil.NewJSONReader(transfer),
+			Refresh:    "true",
+		}
+		if _, err := es.apiClient.DoRequest(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (es *ElasticsearchDB) ERC721TokenTransfers(contract types.Address, tokenId *big.Int, options *types.TokenQueryOptions) ([]types.TokenTransfer, error) {
+	formattedQuery := fmt.Sprintf(QueryERC721TokenTransfers(options), contract.String(), tokenId.String())
+
+	from := options.PageSize * options.PageNumber
+	if from+options.PageSize > 1000 {
+		return nil, ErrPaginationLimitExceeded
+	}
+
+	searchReq := esapi.SearchRequest{
+		Index: []string{TokenTransferIndex},
+		Body:  strings.NewReader(formattedQuery),
+		From:  &from,
+		Size:  &options.PageSize,
+		Sort:  []string{"blockNumber:asc", "logIndex:asc"},
+	}
+
+	results, err := es.doSearchRequest(searchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeTokenTransfers(results.Hits.Hits)
+}
+
+func (es *ElasticsearchDB) GetTokenTransfers(contract *types.Address, counterparty *types.Address, direction string, options *types.TokenTransferQueryOptions) ([]types.TokenTransfer, error) {
+	afterBlock, afterLogIndex, hasCursor, err := options.Cursor()
+	if err != nil {
+		return nil, err
+	}
+	if options.PageSize > 1000 {
+		return nil, ErrPaginationLimitExceeded
+	}
+
+	searchReq := esapi.SearchRequest{
+		Index: []string{TokenTransferIndex},
+		Body:  strings.NewReader(QueryTokenTransfers(contract, counterparty, direction, options, afterBlock, afterLogIndex, hasCursor)),
+		Size:  &options.PageSize,
+	}
+
+	results, err := es.doSearchRequest(searchReq)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTokenTransfers(results.Hits.Hits)
+}
+
+// erc1155EntryAtBlock finds the balance entry with the highest starting block at or before
+// the given block
+func (es *ElasticsearchDB) erc1155EntryAtBlock(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) (*types.ERC1155Token, error) {
+	formattedQuery := fmt.Sprintf(QueryERC1155BalanceAtBlock(), contract.String(), holder.String(), tokenId.String(), block)
+
+	pageSize := 1
+	searchReq := esapi.SearchRequest{
+		Index: []string{ERC1155TokenIndex},
+		Body:  strings.NewReader(formattedQuery),
+		Size:  &pageSize,
+	}
+
+	results, err := es.doSearchRequest(searchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results.Hits.Hits) == 0 {
+		return nil, database.ErrNotFound
+	}
+
+	var tokenResult types.ERC1155Token
+	if err = mapstructure.Decode(results.Hits.Hits[0].Source, &tokenResult); err != nil {
+		return nil, err
+	}
+	return &tokenResult, nil
+}
+
+// tokenBalanceEntryAtBlock finds the balance entry with the highest starting block at or
+// before the given block
+func (es *ElasticsearchDB) tokenBalanceEntryAtBlock(standard string, contract types.Address, holder types.Address, block uint64) (*types.TokenBalance, error) {
+	formattedQuery := fmt.Sprintf(QueryTokenBalanceAtBlock(), standard, contract.String(), holder.String(), block)
+
+	pageSize := 1
+	searchReq := esapi.SearchRequest{
+		Index: []string{TokenBalanceIndex},
+		Body:  strings.NewReader(formattedQuery),
+		Size:  &pageSize,
+	}
+
+	results, err := es.doSearchRequest(searchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results.Hits.Hits) == 0 {
+		return nil, database.ErrNotFound
+	}
+
+	var balanceResult types.TokenBalance
+	if err = mapstructure.Decode(results.Hits.Hits[0].Source, &balanceResult); err != nil {
+		return nil, err
+	}
+	return &balanceResult, nil
+}
+
+func (es *ElasticsearchDB) RecordTokenInfo(info *types.TokenInfo) error {
+	req := esapi.IndexRequest{
+		Index:      TokenInfoIndex,
+		DocumentID: info.Contract.String(),
+		Body:       esutil.NewJSONReader(info),
+		Refresh:    "true",
+	}
+	_, err := es.apiClient.DoRequest(req)
+	return err
+}
+
+func (es *ElasticsearchDB) GetTokenInfo(contract types.Address) (*types.TokenInfo, error) {
+	fetchReq := esapi.GetRequest{
+		Index:      TokenInfoIndex,
+		DocumentID: contract.String(),
+	}
+
+	body, err := es.apiClient.DoRequest(fetchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	var infoResult TokenInfoQueryResult
+	if err = json.Unmarshal(body, &infoResult); err != nil {
+		return nil, err
+	}
+	return &infoResult.Source, nil
+}
+
+func (es *ElasticsearchDB) RecordTotalSupply(contract types.Address, block uint64, supply *big.Int) error {
+	//find old entry
+	existingEntry, errExisting := es.totalSupplyEntryAtBlock(contract, block-1)
+	if errExisting != nil && errExisting != database.ErrNotFound {
+		return errExisting
+	}
+
+	//add new entry
+	totalSupply := types.TokenSupply{
+		Contract:    contract,
+		TotalSupply: supply.String(),
+		BlockNumber: block,
+	}
+
+	req := esapi.IndexRequest{
+		Index:      TotalSupplyIndex,
+		DocumentID: fmt.Sprintf("%s-%d", contract.String(), block),
+		Body:       esutil.NewJSONReader(totalSupply),
+		Refresh:    "true",
+	}
+
+	if _, err := es.apiClient.DoRequest(req); err != nil {
+		return err
+	}
+
+	if errExisting == database.ErrNotFound {
+		return nil
+	}
+
+	//update the older entry
+	query := map[string]interface{}{
+		"doc": map[string]interface{}{
+			"supplyUntil": block - 1,
+		},
+	}
+
+	updateRequest := esapi.UpdateRequest{
+		Index:      TotalSupplyIndex,
+		DocumentID: fmt.Sprintf("%s-%d", contract.String(), existingEntry.BlockNumber),
+		Body:       esutil.NewJSONReader(query),
+		Refresh:    "true",
+	}
+
+	_, err := es.apiClient.DoRequest(updateRequest)
+	return err
+}
+
+func (es *ElasticsearchDB) GetTotalSupply(contract types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	queryString := fmt.Sprintf(QueryTotalSupplyAtBlockRange(options), contract.String())
+
+	from := options.PageSize * options.PageNumber
+	if from+options.PageSize > 1000 {
+		return nil, ErrPaginationLimitExceeded
+	}
+	req := esapi.SearchRequest{
+		Index: []string{TotalSupplyIndex},
+		Body:  strings.NewReader(queryString),
+		From:  &from,
+		Size:  &options.PageSize,
+		Sort:  []string{"blockNumber:desc"},
+	}
+	results, err := es.doSearchRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	supplyMap := make(map[uint64]*big.Int)
+	for _, result := range results.Hits.Hits {
+		blockNumber := uint64(result.Source["blockNumber"].(float64))
+		supply, success := new(big.Int).SetString(result.Source["totalSupply"].(string), 10)
+		if !success {
+			return nil, errors.New("could not parse total supply")
+		}
+
+		if blockNumber < options.BeginBlockNumber.Uint64() {
+			supplyMap[options.BeginBlockNumber.Uint64()] = supply
+		} else {
+			supplyMap[blockNumber] = supply
+		}
+	}
+	return supplyMap, nil
+}
+
+func (es *ElasticsearchDB) RollbackTokenRange(contract types.Address, fromBlock uint64, toBlock uint64) error {
+	deletions := []struct {
+		index string
+		field string
+	}{
+		{ERC20TokenIndex, "blockNumber"},
+		{ERC721TokenIndex, "heldFrom"},
+		{ERC1155TokenIndex, "heldFrom"},
+		{TokenBalanceIndex, "heldFrom"},
+		{TokenTransferIndex, "blockNumber"},
+		{ERC20AllowanceIndex, "approvedFrom"},
+		{TotalSupplyIndex, "blockNumber"},
+	}
+	for _, deletion := range deletions {
+		deleteReq := esapi.DeleteByQueryRequest{
+			Index:             []string{deletion.index},
+			Body:              strings.NewReader(fmt.Sprintf(DeleteContractInRangeQueryTemplate, contract.String(), deletion.field, fromBlock, toBlock)),
+			Refresh:           &RequestParameterTrue,
+			WaitForCompletion: &RequestParameterTrue,
+		}
+		if _, err := es.apiClient.DoRequest(deleteReq); err != nil {
+			return err
+		}
+	}
+
+	// re-open the token records that were superseded by the removed records
+	reopenUntil := []struct {
+		indices []string
+		field   string
+	}{
+		{[]string{ERC20TokenIndex, ERC721TokenIndex, ERC1155TokenIndex, TokenBalanceIndex}, "heldUntil"},
+		{[]string{ERC20AllowanceIndex}, "approvedUntil"},
+		{[]string{TotalSupplyIndex}, "supplyUntil"},
+	}
+	for _, reopen := range reopenUntil {
+		reopenReq := esapi.UpdateByQueryRequest{
+			Index:             reopen.indices,
+			Body:              strings.NewReader(fmt.Sprintf(ReopenContractUntilQueryTemplate, reopen.field, contract.String(), reopen.field, fromBlock-1, toBlock)),
+			Refresh:           &RequestParameterTrue,
+			WaitForCompletion: &RequestParameterTrue,
+		}
+		if _, err := es.apiClient.DoRequest(reopenReq); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// totalSupplyEntryAtBlock finds the supply entry with the highest block number at or before
+// the given block
+func (es *ElasticsearchDB) totalSupplyEntryAtBlock(contract types.Address, block uint64) (*types.TokenSupply, error) {
+	formattedQuery := fmt.Sprintf(QueryTotalSupplyAtBlock(), contract.String(), block)
+
+	pageSize := 1
+	searchReq := esapi.SearchRequest{
+		Index: []string{TotalSupplyIndex},
+		Body:  strings.NewReader(formattedQuery),
+		Size:  &pageSize,
+	}
+
+	results, err := es.doSearchRequest(searchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results.Hits.Hits) == 0 {
+		return nil, database.ErrNotFound
+	}
+
+	var supply types.TokenSupply
+	if err = mapstructure.Decode(results.Hits.Hits[0].Source, &supply); err != nil {
+		return nil, err
+	}
+	return &supply, nil
+}
+
+// erc20AllowanceEntryAtBlock finds the allowance entry with the highest starting block at
+// or before the given block
+func (es *ElasticsearchDB) erc20AllowanceEntryAtBlock(contract types.Address, owner types.Address, spender types.Address, block uint64) (*types.ERC20Allowance, error) {
+	formattedQuery := fmt.Sprintf(QueryERC20AllowanceAtBlock(), contract.String(), owner.String(), spender.String(), block)
+
+	pageSize := 1
+	searchReq := esapi.SearchRequest{
+		Index: []string{ERC20AllowanceIndex},
+		Body:  strings.NewReader(formattedQuery),
+		Size:  &pageSize,
+	}
+
+	results, err := es.doSearchRequest(searchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results.Hits.Hits) == 0 {
+		return nil, database.ErrNotFound
+	}
+
+	var allowance types.ERC20Allowance
+	if err = mapstructure.Decode(results.Hits.Hits[0].Source, &allowance); err != nil {
+		return nil, err
+	}
+	return &allowance, nil
+}
+
+func decodeTokenTransfers(hits []IndividualResult) ([]types.TokenTransfer, error) {
+	convertedResults := make([]types.TokenTransfer, 0, len(hits))
+	for _, result := range hits {
+		transfer := new(types.TokenTransfer)
+		if err := mapstructure.Decode(result.Source, transfer); err != nil {
+			return nil, err
+		}
+		transfer.Contract = types.NewAddress(string(transfer.Contract))
+		transfer.From = types.NewAddress(string(transfer.From))
+		transfer.To = types.NewAddress(string(transfer.To))
+		transfer.TransactionHash = types.NewHash(string(transfer.TransactionHash))
+		convertedResults = append(convertedResults, *transfer)
+	}
+	return convertedResults, nil
+}
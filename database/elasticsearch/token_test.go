@@ -1,6 +1,7 @@
 package elasticsearch
 
 import (
+	"errors"
 	"math/big"
 	"strings"
 	"testing"
@@ -69,7 +70,8 @@ func TestElasticsearchDB_RecordNewERC20Balance_NoPrevious(t *testing.T) {
 	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
 	mockedClient.EXPECT().DoRequest(NewSearchRequestMatcher(req)).Return([]byte(searchResult), nil)
 	mockedClient.EXPECT().DoRequest(NewIndexRequestMatcher(ex)).Do(func(input esapi.IndexRequest) {
-		assert.Equal(t, "create", input.OpType)
+		// an entry already recorded at the block is replaced
+		assert.Empty(t, input.OpType)
 	})
 
 	db, _ := New(mockedClient)
@@ -147,7 +149,8 @@ func TestElasticsearchDB_RecordNewERC20Balance_WithPrevious(t *testing.T) {
 	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
 	mockedClient.EXPECT().DoRequest(NewSearchRequestMatcher(req)).Return([]byte(searchResult), nil)
 	mockedClient.EXPECT().DoRequest(NewIndexRequestMatcher(ex)).Do(func(input esapi.IndexRequest) {
-		assert.Equal(t, "create", input.OpType)
+		// an entry already recorded at the block is replaced
+		assert.Empty(t, input.OpType)
 	})
 	mockedClient.EXPECT().DoRequest(NewUpdateRequestMatcher(oldTokenUpdateReq)).Return(nil, nil)
 
@@ -505,3 +508,383 @@ func TestElasticsearchDB_ERC721TokenByTokenID(t *testing.T) {
 	assert.Nil(t, err)
 	assert.EqualValues(t, expected, *result)
 }
+
+func TestElasticsearchDB_GetTokenTransfers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	contract := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	counterparty := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+	options := &types.TokenTransferQueryOptions{After: "12-3"}
+	options.SetDefaults()
+
+	expectedQuery := `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "range": { "blockNumber": { "gte": 0 } } },
+				{ "range": { "timestamp": { "gte": 0 } } },
+				{ "match": { "contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34" } },
+				{ "bool": { "should": [ { "match": { "to": "0x1349f3e1b8d71effb47b840594ff27da7e603d17" } } ], "minimum_should_match": 1 } }
+			]
+		}
+	},
+	"search_after": [ 12, 3 ],
+	"sort": [
+		{ "blockNumber": "asc" },
+		{ "logIndex": "asc" }
+	]
+}
+`
+	size := 10
+	req := esapi.SearchRequest{
+		Index: []string{TokenTransferIndex},
+		Body:  strings.NewReader(expectedQuery),
+		Size:  &size,
+	}
+
+	resultJson := `{"hits": {"hits": [{"_source": {"contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34", "from": "0x0000000000000000000000000000000000000000", "to": "0x1349f3e1b8d71effb47b840594ff27da7e603d17", "amount": "1000", "blockNumber": 13, "transactionHash": "0xf4f803b8d6c6b38e0b15d6cfe80fd1dcea4270ad24e93385fca36512bb9c2c59", "logIndex": 0, "timestamp": 1300}}]}}`
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().DoRequest(NewSearchRequestMatcher(req)).Return([]byte(resultJson), nil)
+
+	db, _ := New(mockedClient)
+	transfers, err := db.GetTokenTransfers(&contract, &counterparty, types.TransferDirectionIn, options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TokenTransfer{
+		{
+			Contract:        contract,
+			From:            types.NewAddress(""),
+			To:              counterparty,
+			Amount:          "1000",
+			BlockNumber:     13,
+			TransactionHash: types.NewHash("0xf4f803b8d6c6b38e0b15d6cfe80fd1dcea4270ad24e93385fca36512bb9c2c59"),
+			LogIndex:        0,
+			Timestamp:       1300,
+		},
+	}, transfers)
+}
+
+func TestElasticsearchDB_ERC20HolderBalancesAtBlock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	contract := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+
+	expectedQuery := `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34"} },
+				{ "range": { "blockNumber": { "lte": 5 } } }
+			],
+			"must_not": [
+				{ "term": { "amount.keyword": "0" } },
+				{ "term": { "holder.keyword": "0x0000000000000000000000000000000000000000" } }
+			],
+			"filter": [{
+				"bool": {
+					"should": [
+						{ "range": { "heldUntil": { "gte": 5 } } },
+						{ "bool": { "must_not": { "exists": { "field": "heldUntil" } } } }
+					]
+				}
+			}]
+		}
+	},
+	"sort": [
+		{ "holder.keyword": "asc" }
+	]
+}
+`
+	size := 1000
+	req := esapi.SearchRequest{
+		Index: []string{ERC20TokenIndex},
+		Body:  strings.NewReader(expectedQuery),
+		Size:  &size,
+	}
+
+	resultJson := `{"hits": {"hits": [
+		{"_source": {"contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34", "holder": "0x1349f3e1b8d71effb47b840594ff27da7e603d17", "amount": "1000", "blockNumber": 3, "heldUntil": null}},
+		{"_source": {"contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34", "holder": "0xed9d02e382b34818e88b88a309c7fe71e65f419d", "amount": "250", "blockNumber": 4, "heldUntil": null}}
+	]}}`
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().DoRequest(NewSearchRequestMatcher(req)).Return([]byte(resultJson), nil)
+
+	db, _ := New(mockedClient)
+	balances, err := db.ERC20HolderBalancesAtBlock(contract, 5)
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[types.Address]*big.Int{
+		types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17"): big.NewInt(1000),
+		types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d"): big.NewInt(250),
+	}, balances)
+}
+
+func TestElasticsearchDB_ERC20HolderCountAtBlock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	contract := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+
+	expectedQuery := `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34"} },
+				{ "range": { "blockNumber": { "lte": 5 } } }
+			],
+			"must_not": [
+				{ "term": { "amount.keyword": "0" } },
+				{ "term": { "holder.keyword": "0x0000000000000000000000000000000000000000" } }
+			],
+			"filter": [{
+				"bool": {
+					"should": [
+						{ "range": { "heldUntil": { "gte": 5 } } },
+						{ "bool": { "must_not": { "exists": { "field": "heldUntil" } } } }
+					]
+				}
+			}]
+		}
+	}
+}
+`
+	req := esapi.CountRequest{
+		Index: []string{ERC20TokenIndex},
+		Body:  strings.NewReader(expectedQuery),
+	}
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().DoRequest(NewCountRequestMatcher(req)).Return([]byte(`{"count": 42}`), nil)
+
+	db, _ := New(mockedClient)
+	count, err := db.ERC20HolderCountAtBlock(contract, 5)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 42, count)
+}
+
+func TestElasticsearchDB_ERC20BalanceChanges(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	contract := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+
+	expectedQuery := `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34"} },
+				{ "range": { "blockNumber": { "gte": 2, "lte": 5 } } }
+			],
+			"must_not": [
+				{ "term": { "holder.keyword": "0x0000000000000000000000000000000000000000" } }
+			]
+		}
+	},
+	"sort": [
+		{ "blockNumber": "asc" },
+		{ "holder.keyword": "asc" }
+	]
+}
+`
+	size := 1000
+	req := esapi.SearchRequest{
+		Index: []string{ERC20TokenIndex},
+		Body:  strings.NewReader(expectedQuery),
+		Size:  &size,
+	}
+
+	resultJson := `{"hits": {"hits": [
+		{"_source": {"contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34", "holder": "0x1349f3e1b8d71effb47b840594ff27da7e603d17", "amount": "1000", "blockNumber": 3, "heldUntil": 3}},
+		{"_source": {"contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34", "holder": "0x1349f3e1b8d71effb47b840594ff27da7e603d17", "amount": "0", "blockNumber": 4, "heldUntil": null}}
+	]}}`
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().DoRequest(NewSearchRequestMatcher(req)).Return([]byte(resultJson), nil)
+
+	db, _ := New(mockedClient)
+	changes, err := db.ERC20BalanceChanges(contract, 2, 5)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []types.ERC20BalanceChange{
+		{Holder: types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17"), BlockNumber: 3, Amount: "1000"},
+		{Holder: types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17"), BlockNumber: 4, Amount: "0"},
+	}, changes)
+}
+
+func TestElasticsearchDB_AllERC721TokensForAccountAtBlock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	holder := types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+	options := &types.AccountTokenQueryOptions{After: "0x1111111111111111111111111111111111111111-5", PageSize: 2}
+
+	expectedQuery := `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "holder": "0xed9d02e382b34818e88b88a309c7fe71e65f419d"} },
+				{ "range": { "heldFrom": { "lte": 5 } } }
+			],
+			"filter": [{
+				"bool": {
+					"should": [
+						{ "range": { "heldUntil": { "gte": 5 } } },
+						{ "bool": { "must_not": { "exists": { "field": "heldUntil" } } } }
+					]
+				}
+			}]
+		}
+	},
+	"sort": [
+		{ "contract.keyword": "asc" },
+		{ "first": "asc" },
+		{ "second": "asc" },
+		{ "third": "asc" },
+		{ "fourth": "asc" },
+		{ "fifth": "asc" }
+	],
+	"search_after": ["0x1111111111111111111111111111111111111111", 0, 0, 0, 0, 5]
+}
+`
+	size := 2
+	req := esapi.SearchRequest{
+		Index: []string{ERC721TokenIndex},
+		Body:  strings.NewReader(expectedQuery),
+		Size:  &size,
+	}
+
+	resultJson := `{"hits": {"hits": [
+		{"_source": {"contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34", "holder": "0xed9d02e382b34818e88b88a309c7fe71e65f419d", "token": "12", "heldFrom": 3, "heldUntil": null}},
+		{"_source": {"contract": "0x1349f3e1b8d71effb47b840594ff27da7e603d17", "holder": "0xed9d02e382b34818e88b88a309c7fe71e65f419d", "token": "4", "heldFrom": 2, "heldUntil": null}}
+	]}}`
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().DoRequest(NewSearchRequestMatcher(req)).Return([]byte(resultJson), nil)
+
+	db, _ := New(mockedClient)
+	tokens, err := db.AllERC721TokensForAccountAtBlock(holder, 5, options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []types.ERC721Token{
+		{Contract: types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34"), Holder: holder, Token: "12", HeldFrom: 3},
+		{Contract: types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17"), Holder: holder, Token: "4", HeldFrom: 2},
+	}, tokens)
+}
+
+func TestTokenIdSortValues(t *testing.T) {
+	assert.Equal(t, []string{"0", "0", "0", "0", "12"}, tokenIdSortValues("12"))
+	assert.Equal(t, []string{"0", "0", "0", "1", "2"}, tokenIdSortValues("100000000000000002"))
+}
+
+func TestElasticsearchDB_ERC20ApprovalsForOwnerAtBlock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	contract := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	owner := types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+	options := &types.TokenQueryOptions{After: "0x0000000000000000000000000000000000000003"}
+	options.SetDefaults()
+
+	expectedQuery := `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34"} },
+				{ "match": { "owner": "0xed9d02e382b34818e88b88a309c7fe71e65f419d"} },
+				{ "range": { "approvedFrom": { "lte": 5 } } },
+				{ "range": { "spender.keyword": { "gt": "0x0000000000000000000000000000000000000003" } } }
+			],
+			"must_not": [
+				{ "term": { "amount.keyword": "0" } }
+			],
+			"filter": [{
+				"bool": {
+					"should": [
+						{ "range": { "approvedUntil": { "gte": 5 } } },
+						{ "bool": { "must_not": { "exists": { "field": "approvedUntil" } } } }
+					]
+				}
+			}]
+		}
+	},
+	"sort": [
+		{ "spender.keyword": "asc" }
+	]
+}
+`
+	size := 10
+	req := esapi.SearchRequest{
+		Index: []string{ERC20AllowanceIndex},
+		Body:  strings.NewReader(expectedQuery),
+		Size:  &size,
+	}
+
+	resultJson := `{"hits": {"hits": [{"_source": {"contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34", "owner": "0xed9d02e382b34818e88b88a309c7fe71e65f419d", "spender": "0x1349f3e1b8d71effb47b840594ff27da7e603d17", "amount": "1000", "approvedFrom": 3, "approvedUntil": null}}]}}`
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().DoRequest(NewSearchRequestMatcher(req)).Return([]byte(resultJson), nil)
+
+	db, _ := New(mockedClient)
+	approvals, err := db.ERC20ApprovalsForOwnerAtBlock(contract, owner, 5, options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []types.ERC20Allowance{
+		{
+			Contract:     contract,
+			Owner:        owner,
+			Spender:      types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17"),
+			Amount:       "1000",
+			ApprovedFrom: 3,
+		},
+	}, approvals)
+}
+
+func TestElasticsearchDB_RollbackTokenRange_ErrorDeletingBalances(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	expectedQuery := `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34" } },
+				{ "range": { "blockNumber": { "gte": 10, "lte": 19 } } }
+			]
+		}
+	}
+}
+`
+	deleteBalancesRequest := esapi.DeleteByQueryRequest{
+		Index: []string{ERC20TokenIndex},
+		Body:  strings.NewReader(expectedQuery),
+	}
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().
+		DoRequest(NewDeleteByQueryRequestMatcher(deleteBalancesRequest)).
+		Return(nil, errors.New("test error"))
+
+	db, _ := New(mockedClient)
+	err := db.RollbackTokenRange(types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34"), 10, 19)
+
+	assert.EqualError(t, err, "test error")
+}
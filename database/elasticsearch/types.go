@@ -48,6 +48,31 @@ type SortableERC721Token struct {
 	Fifth  uint64 `json:"fifth"`
 }
 
+type SortableERC1155Token struct {
+	types.ERC1155Token
+
+	//Allows the token to be sortable by splitting it into component parts
+	First  uint64 `json:"first"`
+	Second uint64 `json:"second"`
+	Third  uint64 `json:"third"`
+	Fourth uint64 `json:"fourth"`
+	Fifth  uint64 `json:"fifth"`
+}
+
+// SortableTransaction is a transaction as stored in Elasticsearch. Its amounts are stored as
+// decimal strings, and again as fixed-width keys so that they can be range queried.
+type SortableTransaction struct {
+	types.Transaction
+
+	ValueKey    string `json:"valueKey"`
+	GasPriceKey string `json:"gasPriceKey"`
+}
+
+type ProxyImplementation struct {
+	Contract types.Address `json:"contract"`
+	types.ProxyImplementation
+}
+
 //
 
 type ContractQueryResult struct {
@@ -74,6 +99,14 @@ type StorageQueryResult struct {
 	Source Storage `json:"_source"`
 }
 
+type TokenInfoQueryResult struct {
+	Source types.TokenInfo `json:"_source"`
+}
+
+type BackfillJobQueryResult struct {
+	Source types.BackfillJob `json:"_source"`
+}
+
 type LastPersistedResult struct {
 	Source struct {
 		LastPersisted uint64 `json:"lastPersisted"`
@@ -2,8 +2,10 @@ package factory
 
 import (
 	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/database/bolt"
 	"quorumengineering/quorum-report/database/elasticsearch"
 	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/database/postgres"
 	"quorumengineering/quorum-report/log"
 	"quorumengineering/quorum-report/types"
 )
@@ -23,6 +25,22 @@ func (dbFactory *Factory) Database(config *types.DatabaseConfig) (database.Datab
 		log.Info("Created database connection", "type", "elasticsearch")
 		return NewDatabaseWithCache(db, config.CacheSize)
 	}
+	if config != nil && config.Bolt != nil {
+		db, err := dbFactory.NewBoltDatabase(config.Bolt)
+		if err != nil {
+			return nil, err
+		}
+		log.Info("Created database connection", "type", "bolt", "path", config.Bolt.Path)
+		return NewDatabaseWithCache(db, config.CacheSize)
+	}
+	if config != nil && config.Postgres != nil {
+		db, err := dbFactory.NewPostgresDatabase(config.Postgres)
+		if err != nil {
+			return nil, err
+		}
+		log.Info("Created database connection", "type", "postgres")
+		return NewDatabaseWithCache(db, config.CacheSize)
+	}
 	log.Info("Created database connection", "type", "memory")
 	return dbFactory.NewInMemoryDatabase(), nil
 }
@@ -46,3 +64,11 @@ func (dbFactory *Factory) NewElasticsearchDatabase(config *types.ElasticsearchCo
 	}
 	return elasticsearch.New(apiClient)
 }
+
+func (dbFactory *Factory) NewBoltDatabase(config *types.BoltConfig) (*bolt.BoltDB, error) {
+	return bolt.New(config.Path)
+}
+
+func (dbFactory *Factory) NewPostgresDatabase(config *types.PostgresConfig) (*postgres.PostgresDB, error) {
+	return postgres.New(config.URL)
+}
@@ -87,6 +87,7 @@ func (cachingDB *DatabaseWithCache) DeleteAddress(address types.Address) error {
 		return err
 	}
 	delete(cachingDB.addressCache, address)
+	cachingDB.contractCreationCache.Remove(address)
 	return nil
 }
 
@@ -158,6 +159,24 @@ func (cachingDB *DatabaseWithCache) GetLastPersistedBlockNumber() (uint64, error
 	return cachingDB.db.GetLastPersistedBlockNumber()
 }
 
+func (cachingDB *DatabaseWithCache) RollbackBlocks(reorg *types.ChainReorg) error {
+	cachingDB.blockMux.Lock()
+	defer cachingDB.blockMux.Unlock()
+	if err := cachingDB.db.RollbackBlocks(reorg); err != nil {
+		return err
+	}
+	// any cached entry may belong to an orphaned block
+	cachingDB.blockCache.Purge()
+	cachingDB.transactionCache.Purge()
+	cachingDB.storageCache.Purge()
+	cachingDB.contractCreationCache.Purge()
+	return nil
+}
+
+func (cachingDB *DatabaseWithCache) GetChainReorgs() ([]*types.ChainReorg, error) {
+	return cachingDB.db.GetChainReorgs()
+}
+
 func (cachingDB *DatabaseWithCache) WriteTransactions(txns []*types.Transaction) error {
 	err := cachingDB.db.WriteTransactions(txns)
 	if err != nil {
@@ -201,7 +220,8 @@ func (cachingDB *DatabaseWithCache) GetContractCreationTransaction(address types
 	if err != nil {
 		return "", err
 	}
-	if hash.IsEmpty() {
+	// the creation transaction may not have been indexed yet, so only cache known hashes
+	if !hash.IsEmpty() {
 		cachingDB.contractCreationCache.Set(address, hash)
 	}
 	return hash, nil
@@ -263,6 +283,22 @@ func (cachingDB *DatabaseWithCache) GetAllTokenHolders(contract types.Address, b
 	return cachingDB.db.GetAllTokenHolders(contract, block, options)
 }
 
+func (cachingDB *DatabaseWithCache) ERC20HolderBalancesAtBlock(contract types.Address, block uint64) (map[types.Address]*big.Int, error) {
+	return cachingDB.db.ERC20HolderBalancesAtBlock(contract, block)
+}
+
+func (cachingDB *DatabaseWithCache) ERC20HolderCountAtBlock(contract types.Address, block uint64) (uint64, error) {
+	return cachingDB.db.ERC20HolderCountAtBlock(contract, block)
+}
+
+func (cachingDB *DatabaseWithCache) AllERC20BalancesForAccountAtBlock(holder types.Address, block uint64) (map[types.Address]*big.Int, error) {
+	return cachingDB.db.AllERC20BalancesForAccountAtBlock(holder, block)
+}
+
+func (cachingDB *DatabaseWithCache) ERC20BalanceChanges(contract types.Address, fromBlock uint64, toBlock uint64) ([]types.ERC20BalanceChange, error) {
+	return cachingDB.db.ERC20BalanceChanges(contract, fromBlock, toBlock)
+}
+
 func (cachingDB *DatabaseWithCache) RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error {
 	return cachingDB.db.RecordERC721Token(contract, holder, block, tokenId)
 }
@@ -283,6 +319,130 @@ func (cachingDB *DatabaseWithCache) AllHoldersAtBlock(contract types.Address, bl
 	return cachingDB.db.AllHoldersAtBlock(contract, block, options)
 }
 
+func (cachingDB *DatabaseWithCache) AllERC721TokensForAccountAtBlock(holder types.Address, block uint64, options *types.AccountTokenQueryOptions) ([]types.ERC721Token, error) {
+	return cachingDB.db.AllERC721TokensForAccountAtBlock(holder, block, options)
+}
+
+func (cachingDB *DatabaseWithCache) RecordTokenTransfers(transfers []types.TokenTransfer) error {
+	return cachingDB.db.RecordTokenTransfers(transfers)
+}
+
+func (cachingDB *DatabaseWithCache) ERC721TokenTransfers(contract types.Address, tokenId *big.Int, options *types.TokenQueryOptions) ([]types.TokenTransfer, error) {
+	return cachingDB.db.ERC721TokenTransfers(contract, tokenId, options)
+}
+
+func (cachingDB *DatabaseWithCache) GetTokenTransfers(contract *types.Address, counterparty *types.Address, direction string, options *types.TokenTransferQueryOptions) ([]types.TokenTransfer, error) {
+	return cachingDB.db.GetTokenTransfers(contract, counterparty, direction, options)
+}
+
+func (cachingDB *DatabaseWithCache) RecordERC20Allowance(contract types.Address, owner types.Address, spender types.Address, block uint64, amount *big.Int) error {
+	return cachingDB.db.RecordERC20Allowance(contract, owner, spender, block, amount)
+}
+
+func (cachingDB *DatabaseWithCache) ERC20AllowanceAtBlock(contract types.Address, owner types.Address, spender types.Address, block uint64) (*big.Int, error) {
+	return cachingDB.db.ERC20AllowanceAtBlock(contract, owner, spender, block)
+}
+
+func (cachingDB *DatabaseWithCache) ERC20ApprovalsForOwnerAtBlock(contract types.Address, owner types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC20Allowance, error) {
+	return cachingDB.db.ERC20ApprovalsForOwnerAtBlock(contract, owner, block, options)
+}
+
+func (cachingDB *DatabaseWithCache) RecordTokenInfo(info *types.TokenInfo) error {
+	return cachingDB.db.RecordTokenInfo(info)
+}
+
+func (cachingDB *DatabaseWithCache) GetTokenInfo(contract types.Address) (*types.TokenInfo, error) {
+	return cachingDB.db.GetTokenInfo(contract)
+}
+
+func (cachingDB *DatabaseWithCache) RecordTotalSupply(contract types.Address, block uint64, supply *big.Int) error {
+	return cachingDB.db.RecordTotalSupply(contract, block, supply)
+}
+
+func (cachingDB *DatabaseWithCache) GetTotalSupply(contract types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	return cachingDB.db.GetTotalSupply(contract, options)
+}
+
+func (cachingDB *DatabaseWithCache) RollbackTokenRange(contract types.Address, fromBlock uint64, toBlock uint64) error {
+	return cachingDB.db.RollbackTokenRange(contract, fromBlock, toBlock)
+}
+
+func (cachingDB *DatabaseWithCache) RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error {
+	return cachingDB.db.RecordERC1155Balance(contract, holder, block, tokenId, amount)
+}
+
+func (cachingDB *DatabaseWithCache) ERC1155BalanceAtBlock(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) (*big.Int, error) {
+	return cachingDB.db.ERC1155BalanceAtBlock(contract, holder, block, tokenId)
+}
+
+func (cachingDB *DatabaseWithCache) ERC1155TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC1155Token, error) {
+	return cachingDB.db.ERC1155TokensForAccountAtBlock(contract, holder, block, options)
+}
+
+func (cachingDB *DatabaseWithCache) ERC1155HoldersAtBlock(contract types.Address, block uint64, tokenId *big.Int, options *types.TokenQueryOptions) ([]types.Address, error) {
+	return cachingDB.db.ERC1155HoldersAtBlock(contract, block, tokenId, options)
+}
+
+func (cachingDB *DatabaseWithCache) RecordTokenBalance(standard string, contract types.Address, holder types.Address, block uint64, amount *big.Int) error {
+	return cachingDB.db.RecordTokenBalance(standard, contract, holder, block, amount)
+}
+
+func (cachingDB *DatabaseWithCache) TokenBalanceAtBlock(standard string, contract types.Address, holder types.Address, block uint64) (*big.Int, error) {
+	return cachingDB.db.TokenBalanceAtBlock(standard, contract, holder, block)
+}
+
+func (cachingDB *DatabaseWithCache) TokenHoldersAtBlock(standard string, contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	return cachingDB.db.TokenHoldersAtBlock(standard, contract, block, options)
+}
+
+func (cachingDB *DatabaseWithCache) RecordProxyImplementation(proxy types.Address, implementation *types.ProxyImplementation) error {
+	return cachingDB.db.RecordProxyImplementation(proxy, implementation)
+}
+
+func (cachingDB *DatabaseWithCache) GetProxyImplementations(proxy types.Address) ([]*types.ProxyImplementation, error) {
+	return cachingDB.db.GetProxyImplementations(proxy)
+}
+
+func (cachingDB *DatabaseWithCache) RecordBackfillJob(job *types.BackfillJob) error {
+	return cachingDB.db.RecordBackfillJob(job)
+}
+
+func (cachingDB *DatabaseWithCache) GetBackfillJob(contract types.Address) (*types.BackfillJob, error) {
+	return cachingDB.db.GetBackfillJob(contract)
+}
+
+func (cachingDB *DatabaseWithCache) GetBackfillJobs() ([]*types.BackfillJob, error) {
+	return cachingDB.db.GetBackfillJobs()
+}
+
+func (cachingDB *DatabaseWithCache) RecordNativeTransfers(transfers []types.NativeTransfer) error {
+	return cachingDB.db.RecordNativeTransfers(transfers)
+}
+
+func (cachingDB *DatabaseWithCache) GetNativeTransfers(address types.Address, direction string, options *types.TokenTransferQueryOptions) ([]types.NativeTransfer, error) {
+	return cachingDB.db.GetNativeTransfers(address, direction, options)
+}
+
+func (cachingDB *DatabaseWithCache) RecordNativeBalance(address types.Address, block uint64, balance *big.Int) error {
+	return cachingDB.db.RecordNativeBalance(address, block, balance)
+}
+
+func (cachingDB *DatabaseWithCache) NativeBalanceAtBlock(address types.Address, block uint64) (*big.Int, error) {
+	return cachingDB.db.NativeBalanceAtBlock(address, block)
+}
+
+func (cachingDB *DatabaseWithCache) GetNativeBalance(address types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	return cachingDB.db.GetNativeBalance(address, options)
+}
+
+func (cachingDB *DatabaseWithCache) RecordTransactionFailures(failures []types.TransactionFailure) error {
+	return cachingDB.db.RecordTransactionFailures(failures)
+}
+
+func (cachingDB *DatabaseWithCache) GetTransactionFailures(address types.Address, reason string, options *types.PageOptions) ([]types.TransactionFailure, error) {
+	return cachingDB.db.GetTransactionFailures(address, reason, options)
+}
+
 func (cachingDB *DatabaseWithCache) Stop() {
 	cachingDB.db.Stop()
 }
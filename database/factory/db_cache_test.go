@@ -0,0 +1,19 @@
+package factory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/database/conformance"
+	"quorumengineering/quorum-report/database/memory"
+)
+
+func TestDatabaseWithCache_Conformance(t *testing.T) {
+	conformance.RunTests(t, func(t *testing.T) (database.Database, func()) {
+		db, err := NewDatabaseWithCache(memory.NewMemoryDB(), 10)
+		assert.Nil(t, err)
+		return db, db.Stop
+	})
+}
@@ -12,6 +12,10 @@ type Database interface {
 	TransactionDB
 	IndexDB
 	TokenDB
+	ProxyDB
+	BackfillDB
+	NativeDB
+	FailureDB
 	Stop()
 }
 
@@ -39,6 +43,11 @@ type BlockDB interface {
 	WriteBlocks([]*types.Block) error
 	ReadBlock(uint64) (*types.Block, error)
 	GetLastPersistedBlockNumber() (uint64, error)
+
+	// RollbackBlocks removes all blocks above the common ancestor of the reorg, along with the transactions,
+	// indexes, storage, token, native currency and failure records derived from them, and records the reorg
+	RollbackBlocks(*types.ChainReorg) error
+	GetChainReorgs() ([]*types.ChainReorg, error)
 }
 
 // TransactionDB stores all transactions change a contract's state.
@@ -78,10 +87,108 @@ type TokenDB interface {
 	RecordNewERC20Balance(contract types.Address, holder types.Address, block uint64, amount *big.Int) error
 	GetERC20Balance(contract types.Address, holder types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error)
 	GetAllTokenHolders(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error)
+	// ERC20HolderBalancesAtBlock returns the non-zero balance of every holder of the token at the block
+	ERC20HolderBalancesAtBlock(contract types.Address, block uint64) (map[types.Address]*big.Int, error)
+	// ERC20HolderCountAtBlock returns the number of holders with a non-zero balance of the token at the block
+	ERC20HolderCountAtBlock(contract types.Address, block uint64) (uint64, error)
+	// AllERC20BalancesForAccountAtBlock returns the non-zero balance the holder has of every ERC20 token at the block
+	AllERC20BalancesForAccountAtBlock(holder types.Address, block uint64) (map[types.Address]*big.Int, error)
+	// ERC20BalanceChanges returns every balance recorded for a holder of the token from fromBlock to toBlock
+	// inclusive, ordered by block and then holder
+	ERC20BalanceChanges(contract types.Address, fromBlock uint64, toBlock uint64) ([]types.ERC20BalanceChange, error)
+
+	RecordERC20Allowance(contract types.Address, owner types.Address, spender types.Address, block uint64, amount *big.Int) error
+	// ERC20AllowanceAtBlock returns zero if the spender has never been approved by the owner
+	ERC20AllowanceAtBlock(contract types.Address, owner types.Address, spender types.Address, block uint64) (*big.Int, error)
+	// ERC20ApprovalsForOwnerAtBlock returns the non-zero allowances the owner has given, ordered by spender
+	ERC20ApprovalsForOwnerAtBlock(contract types.Address, owner types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC20Allowance, error)
 
 	RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error
 	ERC721TokenByTokenID(contract types.Address, block uint64, tokenId *big.Int) (*types.ERC721Token, error)
 	ERC721TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error)
 	AllERC721TokensAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error)
 	AllHoldersAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error)
+	// AllERC721TokensForAccountAtBlock returns a page of the ERC721 tokens the holder holds at the block, ordered by
+	// contract and then token ID, starting after the token given by the options' cursor
+	AllERC721TokensForAccountAtBlock(holder types.Address, block uint64, options *types.AccountTokenQueryOptions) ([]types.ERC721Token, error)
+	// RecordTokenTransfers stores each transfer, replacing any already stored for the same contract, block and log index
+	RecordTokenTransfers(transfers []types.TokenTransfer) error
+	// ERC721TokenTransfers returns the transfers of the token in the block range of the options, in the order they took place
+	ERC721TokenTransfers(contract types.Address, tokenId *big.Int, options *types.TokenQueryOptions) ([]types.TokenTransfer, error)
+	// GetTokenTransfers returns a page of transfers in the order they took place, optionally only those of a single
+	// contract, or to or from the counterparty in the given direction, which is either of both directions if empty
+	GetTokenTransfers(contract *types.Address, counterparty *types.Address, direction string, options *types.TokenTransferQueryOptions) ([]types.TokenTransfer, error)
+
+	RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error
+	// ERC1155BalanceAtBlock returns zero if the holder has never held the token
+	ERC1155BalanceAtBlock(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) (*big.Int, error)
+	// ERC1155TokensForAccountAtBlock returns the tokens the holder has a non-zero balance of, ordered by token ID
+	ERC1155TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC1155Token, error)
+	// ERC1155HoldersAtBlock returns the holders with a non-zero balance of the token, ordered by address
+	ERC1155HoldersAtBlock(contract types.Address, block uint64, tokenId *big.Int, options *types.TokenQueryOptions) ([]types.Address, error)
+
+	// RecordTokenBalance stores the balance of a token of a configurable standard, which are each kept apart
+	RecordTokenBalance(standard string, contract types.Address, holder types.Address, block uint64, amount *big.Int) error
+	// TokenBalanceAtBlock returns zero if the holder has never held the token
+	TokenBalanceAtBlock(standard string, contract types.Address, holder types.Address, block uint64) (*big.Int, error)
+	// TokenHoldersAtBlock returns the holders with a non-zero balance of the token, ordered by address
+	TokenHoldersAtBlock(standard string, contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error)
+
+	// RecordTokenInfo stores the metadata of a token, replacing any already stored for the contract
+	RecordTokenInfo(info *types.TokenInfo) error
+	GetTokenInfo(contract types.Address) (*types.TokenInfo, error)
+	RecordTotalSupply(contract types.Address, block uint64, supply *big.Int) error
+	// GetTotalSupply returns the total supply of the token at each block it changed in the block range of the
+	// options, with the supply at the start of the range keyed by the starting block
+	GetTotalSupply(contract types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error)
+
+	// RollbackTokenRange removes the token records of the contract from the blocks in the range, inclusive, and
+	// re-opens the records they superseded, as RollbackBlocks does for every contract after the ancestor. Records
+	// from blocks after the range are kept. Token metadata is kept.
+	RollbackTokenRange(contract types.Address, fromBlock uint64, toBlock uint64) error
+}
+
+// ProxyDB stores the implementation history of registered proxy contracts
+type ProxyDB interface {
+	// RecordProxyImplementation records that the proxy delegates to the implementation from
+	// the given block, replacing any record at the same block
+	RecordProxyImplementation(proxy types.Address, implementation *types.ProxyImplementation) error
+	// GetProxyImplementations returns all recorded implementations of the proxy, in block order
+	GetProxyImplementations(proxy types.Address) ([]*types.ProxyImplementation, error)
+}
+
+// BackfillDB stores the progress of token backfill jobs, so they can be resumed after a restart
+type BackfillDB interface {
+	// RecordBackfillJob stores the job, replacing any already stored for the contract
+	RecordBackfillJob(job *types.BackfillJob) error
+	// GetBackfillJob returns ErrNotFound if no job has been started for the contract
+	GetBackfillJob(contract types.Address) (*types.BackfillJob, error)
+	// GetBackfillJobs returns the jobs of every contract, ordered by contract
+	GetBackfillJobs() ([]*types.BackfillJob, error)
+}
+
+// NativeDB stores the native currency transfers of all blocks, and balance snapshots of registered addresses
+type NativeDB interface {
+	// RecordNativeTransfers stores each transfer, replacing any already stored for the same block and index
+	RecordNativeTransfers(transfers []types.NativeTransfer) error
+	// GetNativeTransfers returns a page of transfers to or from the address in the given direction, which is
+	// either of both directions if empty, in the order they took place
+	GetNativeTransfers(address types.Address, direction string, options *types.TokenTransferQueryOptions) ([]types.NativeTransfer, error)
+
+	// RecordNativeBalance stores the balance of the address from the block, replacing any recorded at the same block
+	RecordNativeBalance(address types.Address, block uint64, balance *big.Int) error
+	// NativeBalanceAtBlock returns ErrNotFound if no balance of the address was recorded at or before the block
+	NativeBalanceAtBlock(address types.Address, block uint64) (*big.Int, error)
+	// GetNativeBalance returns the balance of the address at each block it was recorded in the block range of
+	// the options, with the balance at the start of the range keyed by the starting block
+	GetNativeBalance(address types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error)
+}
+
+// FailureDB stores the decoded failures of transactions sent to registered addresses
+type FailureDB interface {
+	// RecordTransactionFailures stores each failure, replacing any already stored for the same address and transaction
+	RecordTransactionFailures(failures []types.TransactionFailure) error
+	// GetTransactionFailures returns a page of the failures of transactions sent to the address in the block range
+	// of the options, optionally only those with the given reason, most recent first
+	GetTransactionFailures(address types.Address, reason string, options *types.PageOptions) ([]types.TransactionFailure, error)
 }
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/database/conformance"
+)
+
+func TestMemoryDB_Conformance(t *testing.T) {
+	conformance.RunTests(t, func(t *testing.T) (database.Database, func()) {
+		return NewMemoryDB(), func() {}
+	})
+}
@@ -2,6 +2,7 @@ package memory
 
 import (
 	"errors"
+	"math"
 	"math/big"
 	"sort"
 	"sync"
@@ -22,13 +23,27 @@ type MemoryDB struct {
 	blockDB                  map[uint64]*types.Block
 	txDB                     map[types.Hash]*types.Transaction
 	lastPersistedBlockNumber uint64
+	chainReorgs              []*types.ChainReorg
 	// index data
-	txIndexDB        map[types.Address]*TxIndexer
-	eventIndexDB     map[types.Address][]*types.Event
-	storageIndexDB   map[types.Address]*StorageIndexer
-	lastFiltered     map[types.Address]uint64
-	erc20BalancesDB  []ERC20TokenHolder
-	erc721BalancesDB []types.ERC721Token
+	txsToDB           map[types.Address][]types.Hash
+	txsInternalToDB   map[types.Address][]types.Hash
+	txIndexDB         map[types.Address]*TxIndexer
+	eventIndexDB      map[types.Address][]*types.Event
+	storageIndexDB    map[types.Address]*StorageIndexer
+	lastFiltered      map[types.Address]uint64
+	erc20BalancesDB   []ERC20TokenHolder
+	erc20AllowancesDB []types.ERC20Allowance
+	erc721BalancesDB  []types.ERC721Token
+	erc1155BalancesDB []types.ERC1155Token
+	tokenBalancesDB   []types.TokenBalance
+	tokenTransfersDB  []types.TokenTransfer
+	tokenInfoDB       map[types.Address]types.TokenInfo
+	totalSupplyDB     []types.TokenSupply
+	proxyDB           map[types.Address][]*types.ProxyImplementation
+	backfillDB        map[types.Address]types.BackfillJob
+	nativeTransfersDB []types.NativeTransfer
+	nativeBalancesDB  []types.NativeBalance
+	failuresDB        []types.TransactionFailure
 	// mutex lock
 	mux sync.RWMutex
 }
@@ -41,18 +56,21 @@ func NewMemoryDB() *MemoryDB {
 		storageLayoutDB:          make(map[string]string),
 		blockDB:                  make(map[uint64]*types.Block),
 		txDB:                     make(map[types.Hash]*types.Transaction),
+		txsToDB:                  make(map[types.Address][]types.Hash),
+		txsInternalToDB:          make(map[types.Address][]types.Hash),
 		txIndexDB:                make(map[types.Address]*TxIndexer),
 		eventIndexDB:             make(map[types.Address][]*types.Event),
 		storageIndexDB:           make(map[types.Address]*StorageIndexer),
 		lastPersistedBlockNumber: 0,
 		lastFiltered:             make(map[types.Address]uint64),
+		tokenInfoDB:              make(map[types.Address]types.TokenInfo),
+		proxyDB:                  make(map[types.Address][]*types.ProxyImplementation),
+		backfillDB:               make(map[types.Address]types.BackfillJob),
 	}
 }
 
 type TxIndexer struct {
 	contractCreationTx types.Hash
-	txsTo              []types.Hash
-	txsInternalTo      []types.Hash
 }
 
 type ERC20TokenHolder struct {
@@ -66,8 +84,6 @@ type ERC20TokenHolder struct {
 func NewTxIndexer() *TxIndexer {
 	return &TxIndexer{
 		contractCreationTx: "",
-		txsTo:              []types.Hash{},
-		txsInternalTo:      []types.Hash{},
 	}
 }
 
@@ -158,19 +174,22 @@ func (db *MemoryDB) GetAddresses() ([]types.Address, error) {
 func (db *MemoryDB) GetContractTemplate(address types.Address) (string, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
-	return db.templateDB[address], nil
+	if !db.addressIsRegistered(address) {
+		return "", database.ErrNotFound
+	}
+	return db.templateName(address), nil
 }
 
 func (db *MemoryDB) GetContractABI(address types.Address) (string, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
-	return db.abiDB[db.templateDB[address]], nil
+	return db.abiDB[db.templateName(address)], nil
 }
 
 func (db *MemoryDB) GetStorageLayout(address types.Address) (string, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
-	return db.storageLayoutDB[db.templateDB[address]], nil
+	return db.storageLayoutDB[db.templateName(address)], nil
 }
 
 func (db *MemoryDB) AddTemplate(name string, abi string, layout string) error {
@@ -184,6 +203,9 @@ func (db *MemoryDB) AddTemplate(name string, abi string, layout string) error {
 func (db *MemoryDB) AssignTemplate(address types.Address, name string) error {
 	db.mux.Lock()
 	defer db.mux.Unlock()
+	if !db.addressIsRegistered(address) {
+		return database.ErrNotFound
+	}
 	db.templateDB[address] = name
 	return nil
 }
@@ -254,7 +276,7 @@ func (db *MemoryDB) ReadBlock(blockNumber uint64) (*types.Block, error) {
 	if block, ok := db.blockDB[blockNumber]; ok {
 		return block, nil
 	}
-	return nil, errors.New("block does not exist")
+	return nil, database.ErrNotFound
 }
 
 func (db *MemoryDB) GetLastPersistedBlockNumber() (uint64, error) {
@@ -263,6 +285,228 @@ func (db *MemoryDB) GetLastPersistedBlockNumber() (uint64, error) {
 	return db.lastPersistedBlockNumber, nil
 }
 
+func (db *MemoryDB) RollbackBlocks(reorg *types.ChainReorg) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	ancestor := reorg.CommonAncestor
+	for blockNumber := range db.blockDB {
+		if blockNumber > ancestor {
+			delete(db.blockDB, blockNumber)
+		}
+	}
+	if db.lastPersistedBlockNumber > ancestor {
+		db.lastPersistedBlockNumber = ancestor
+	}
+
+	orphanedTxs := make(map[types.Hash]bool)
+	for hash, tx := range db.txDB {
+		if tx.BlockNumber > ancestor {
+			orphanedTxs[hash] = true
+			delete(db.txDB, hash)
+		}
+	}
+
+	for address, hashes := range db.txsToDB {
+		db.txsToDB[address] = removeHashes(hashes, orphanedTxs)
+	}
+	for address, hashes := range db.txsInternalToDB {
+		db.txsInternalToDB[address] = removeHashes(hashes, orphanedTxs)
+	}
+
+	// roll back indexes of all registered addresses
+	for _, indexer := range db.txIndexDB {
+		if orphanedTxs[indexer.contractCreationTx] {
+			indexer.contractCreationTx = ""
+		}
+	}
+	for address, events := range db.eventIndexDB {
+		remaining := make([]*types.Event, 0, len(events))
+		for _, event := range events {
+			if event.BlockNumber <= ancestor {
+				remaining = append(remaining, event)
+			}
+		}
+		db.eventIndexDB[address] = remaining
+	}
+	for _, storageIndexer := range db.storageIndexDB {
+		for blockNumber := range storageIndexer.root {
+			if blockNumber > ancestor {
+				delete(storageIndexer.root, blockNumber)
+			}
+		}
+	}
+	for address, lastFiltered := range db.lastFiltered {
+		if lastFiltered > ancestor {
+			db.lastFiltered[address] = ancestor
+		}
+	}
+
+	// remove token records from orphaned blocks, and re-open the records they superseded
+	db.rollbackTokens(nil, ancestor+1, math.MaxUint64)
+
+	for proxy, implementations := range db.proxyDB {
+		remaining := make([]*types.ProxyImplementation, 0, len(implementations))
+		for _, implementation := range implementations {
+			if implementation.BlockNumber <= ancestor {
+				remaining = append(remaining, implementation)
+			}
+		}
+		db.proxyDB[proxy] = remaining
+	}
+
+	nativeTransfers := make([]types.NativeTransfer, 0, len(db.nativeTransfersDB))
+	for _, transfer := range db.nativeTransfersDB {
+		if transfer.BlockNumber <= ancestor {
+			nativeTransfers = append(nativeTransfers, transfer)
+		}
+	}
+	db.nativeTransfersDB = nativeTransfers
+	nativeBalances := make([]types.NativeBalance, 0, len(db.nativeBalancesDB))
+	for _, balance := range db.nativeBalancesDB {
+		if balance.BlockNumber <= ancestor {
+			nativeBalances = append(nativeBalances, balance)
+		}
+	}
+	db.nativeBalancesDB = nativeBalances
+	failures := make([]types.TransactionFailure, 0, len(db.failuresDB))
+	for _, failure := range db.failuresDB {
+		if failure.BlockNumber <= ancestor {
+			failures = append(failures, failure)
+		}
+	}
+	db.failuresDB = failures
+
+	db.chainReorgs = append(db.chainReorgs, reorg)
+	log.Debug("Rolled back blocks", "common ancestor", ancestor, "orphaned txs", len(orphanedTxs))
+	return nil
+}
+
+func (db *MemoryDB) RollbackTokenRange(contract types.Address, fromBlock uint64, toBlock uint64) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	db.rollbackTokens(&contract, fromBlock, toBlock)
+	return nil
+}
+
+// rollbackTokens removes the token records from blocks in the range, and re-opens the
+// records they superseded, of only the given contract if one is given
+func (db *MemoryDB) rollbackTokens(contract *types.Address, fromBlock uint64, toBlock uint64) {
+	inScope := func(address types.Address) bool { return contract == nil || address == *contract }
+	removed := func(block uint64) bool { return block >= fromBlock && block <= toBlock }
+	superseded := func(until *uint64) bool { return until != nil && *until+1 >= fromBlock && *until < toBlock }
+
+	erc20Balances := make([]ERC20TokenHolder, 0, len(db.erc20BalancesDB))
+	for _, balance := range db.erc20BalancesDB {
+		if !inScope(balance.Contract) {
+			erc20Balances = append(erc20Balances, balance)
+			continue
+		}
+		if removed(balance.BlockNumber) {
+			continue
+		}
+		if superseded(balance.HeldUntil) {
+			balance.HeldUntil = nil
+		}
+		erc20Balances = append(erc20Balances, balance)
+	}
+	db.erc20BalancesDB = erc20Balances
+
+	erc20Allowances := make([]types.ERC20Allowance, 0, len(db.erc20AllowancesDB))
+	for _, allowance := range db.erc20AllowancesDB {
+		if !inScope(allowance.Contract) {
+			erc20Allowances = append(erc20Allowances, allowance)
+			continue
+		}
+		if removed(allowance.ApprovedFrom) {
+			continue
+		}
+		if superseded(allowance.ApprovedUntil) {
+			allowance.ApprovedUntil = nil
+		}
+		erc20Allowances = append(erc20Allowances, allowance)
+	}
+	db.erc20AllowancesDB = erc20Allowances
+
+	erc721Tokens := make([]types.ERC721Token, 0, len(db.erc721BalancesDB))
+	for _, token := range db.erc721BalancesDB {
+		if !inScope(token.Contract) {
+			erc721Tokens = append(erc721Tokens, token)
+			continue
+		}
+		if removed(token.HeldFrom) {
+			continue
+		}
+		if superseded(token.HeldUntil) {
+			token.HeldUntil = nil
+		}
+		erc721Tokens = append(erc721Tokens, token)
+	}
+	db.erc721BalancesDB = erc721Tokens
+
+	erc1155Tokens := make([]types.ERC1155Token, 0, len(db.erc1155BalancesDB))
+	for _, token := range db.erc1155BalancesDB {
+		if !inScope(token.Contract) {
+			erc1155Tokens = append(erc1155Tokens, token)
+			continue
+		}
+		if removed(token.HeldFrom) {
+			continue
+		}
+		if superseded(token.HeldUntil) {
+			token.HeldUntil = nil
+		}
+		erc1155Tokens = append(erc1155Tokens, token)
+	}
+	db.erc1155BalancesDB = erc1155Tokens
+
+	tokenBalances := make([]types.TokenBalance, 0, len(db.tokenBalancesDB))
+	for _, balance := range db.tokenBalancesDB {
+		if !inScope(balance.Contract) {
+			tokenBalances = append(tokenBalances, balance)
+			continue
+		}
+		if removed(balance.HeldFrom) {
+			continue
+		}
+		if superseded(balance.HeldUntil) {
+			balance.HeldUntil = nil
+		}
+		tokenBalances = append(tokenBalances, balance)
+	}
+	db.tokenBalancesDB = tokenBalances
+
+	tokenTransfers := make([]types.TokenTransfer, 0, len(db.tokenTransfersDB))
+	for _, transfer := range db.tokenTransfersDB {
+		if !inScope(transfer.Contract) || !removed(transfer.BlockNumber) {
+			tokenTransfers = append(tokenTransfers, transfer)
+		}
+	}
+	db.tokenTransfersDB = tokenTransfers
+
+	totalSupplies := make([]types.TokenSupply, 0, len(db.totalSupplyDB))
+	for _, supply := range db.totalSupplyDB {
+		if !inScope(supply.Contract) {
+			totalSupplies = append(totalSupplies, supply)
+			continue
+		}
+		if removed(supply.BlockNumber) {
+			continue
+		}
+		if superseded(supply.SupplyUntil) {
+			supply.SupplyUntil = nil
+		}
+		totalSupplies = append(totalSupplies, supply)
+	}
+	db.totalSupplyDB = totalSupplies
+}
+
+func (db *MemoryDB) GetChainReorgs() ([]*types.ChainReorg, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	return db.chainReorgs, nil
+}
+
 func (db *MemoryDB) WriteTransactions(transactions []*types.Transaction) error {
 	db.mux.Lock()
 	defer db.mux.Unlock()
@@ -271,6 +515,13 @@ func (db *MemoryDB) WriteTransactions(transactions []*types.Transaction) error {
 		if tx == nil {
 			return errors.New("transaction is nil")
 		}
+		// transactions are found by their recipients whether or not they are registered
+		if _, ok := db.txDB[tx.Hash]; !ok {
+			db.txsToDB[tx.To] = append(db.txsToDB[tx.To], tx.Hash)
+			for _, internalCall := range tx.InternalCalls {
+				db.txsInternalToDB[internalCall.To] = append(db.txsInternalToDB[internalCall.To], tx.Hash)
+			}
+		}
 		db.txDB[tx.Hash] = tx
 		log.Debug("Transaction stored", "hash", tx.Hash.Hex())
 	}
@@ -283,13 +534,17 @@ func (db *MemoryDB) ReadTransaction(hash types.Hash) (*types.Transaction, error)
 	if tx, ok := db.txDB[hash]; ok {
 		return tx, nil
 	}
-	return nil, errors.New("transaction does not exist")
+	return nil, database.ErrNotFound
 }
 
 func (db *MemoryDB) IndexStorage(rawStorage map[types.Address]*types.AccountState, blockNumber uint64) error {
 	db.mux.Lock()
 	defer db.mux.Unlock()
 	for address, dumpAccount := range rawStorage {
+		if _, ok := db.storageIndexDB[address]; !ok {
+			// tried to index a deleted address, do nothing
+			continue
+		}
 		db.storageIndexDB[address].root[blockNumber] = dumpAccount.Root.String()
 		if _, ok := db.storageIndexDB[address].storage[dumpAccount.Root.String()]; !ok {
 			db.storageIndexDB[address].storage[dumpAccount.Root.String()] = dumpAccount.Storage
@@ -314,7 +569,7 @@ func (db *MemoryDB) SetContractCreationTransaction(creationTxns map[types.Hash][
 			if _, ok := db.txIndexDB[createdAddress]; !ok {
 				//tried to index a deleted address, do nothing
 				log.Debug("Ignored deleted address contract creation", "tx", txHash.Hex(), "contract", createdAddress)
-				return nil
+				continue
 			}
 			db.txIndexDB[createdAddress].contractCreationTx = txHash
 			log.Debug("Indexed address of contract creation", "tx", txHash.Hex(), "contract", createdAddress)
@@ -327,7 +582,7 @@ func (db *MemoryDB) GetContractCreationTransaction(address types.Address) (types
 	db.mux.RLock()
 	defer db.mux.RUnlock()
 	if !db.addressIsRegistered(address) {
-		return "", errors.New("address is not registered")
+		return "", database.ErrNotFound
 	}
 	return db.txIndexDB[address].contractCreationTx, nil
 }
@@ -335,93 +590,56 @@ func (db *MemoryDB) GetContractCreationTransaction(address types.Address) (types
 func (db *MemoryDB) GetAllTransactionsToAddress(address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
-	if !db.addressIsRegistered(address) {
-		return nil, errors.New("address is not registered")
-	}
-	var txs []types.Hash
-	txIndex := len(db.txIndexDB[address].txsTo) - 1
-
-	// reverse the order to get descending order
-	for txIndex >= 0 {
-		txs = append(txs, db.txIndexDB[address].txsTo[txIndex])
-		txIndex--
-	}
-	return txs, nil
+	options = queryOptions(options)
+	txs := db.transactionsInRange(db.txsToDB[address], options)
+	return paginateHashes(txs, options.PageSize, options.PageNumber), nil
 }
 
 func (db *MemoryDB) GetTransactionsToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
-	if !db.addressIsRegistered(address) {
-		return 0, errors.New("address is not registered")
-	}
-	return uint64(len(db.txIndexDB[address].txsTo)), nil
+	return uint64(len(db.transactionsInRange(db.txsToDB[address], queryOptions(options)))), nil
 }
 
 func (db *MemoryDB) GetAllTransactionsInternalToAddress(address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
-	if !db.addressIsRegistered(address) {
-		return nil, errors.New("address is not registered")
-	}
-	var txs []types.Hash
-	txIndex := len(db.txIndexDB[address].txsInternalTo) - 1
-
-	// reverse the order to get descending order
-	for txIndex >= 0 {
-		txs = append(txs, db.txIndexDB[address].txsInternalTo[txIndex])
-		txIndex--
-	}
-
-	return txs, nil
+	options = queryOptions(options)
+	txs := db.transactionsInRange(db.txsInternalToDB[address], options)
+	return paginateHashes(txs, options.PageSize, options.PageNumber), nil
 }
 
 func (db *MemoryDB) GetTransactionsInternalToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
-	if !db.addressIsRegistered(address) {
-		return 0, errors.New("address is not registered")
-	}
-	return uint64(len(db.txIndexDB[address].txsInternalTo)), nil
+	return uint64(len(db.transactionsInRange(db.txsInternalToDB[address], queryOptions(options)))), nil
 }
 
 func (db *MemoryDB) GetAllEventsFromAddress(address types.Address, options *types.QueryOptions) ([]*types.Event, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
-	if !db.addressIsRegistered(address) {
-		return nil, errors.New("address is not registered")
-	}
-	events := db.eventIndexDB[address]
-	sort.SliceStable(events, func(i, j int) bool {
-		return events[i].BlockNumber > events[j].BlockNumber
-	})
-	return events, nil
+	options = queryOptions(options)
+	events := db.eventsMatching(address, options)
+	start, end := pageBounds(len(events), options.PageSize, options.PageNumber)
+	return events[start:end], nil
 }
 
 func (db *MemoryDB) GetEventsFromAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
-	if !db.addressIsRegistered(address) {
-		return 0, errors.New("address is not registered")
-	}
-	return uint64(len(db.eventIndexDB[address])), nil
+	return uint64(len(db.eventsMatching(address, queryOptions(options)))), nil
 }
 
 func (db *MemoryDB) GetStorageWithOptions(address types.Address, options *types.PageOptions) ([]*types.StorageResult, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
-	if !db.addressIsRegistered(address) {
-		return nil, errors.New("address is not registered")
-	}
+	options = pageOptions(options)
 	var convertedList []*types.StorageResult
 
-	fromBlockNum := options.BeginBlockNumber.Uint64()
-	endBlockNum := options.EndBlockNumber.Int64()
-
 	storageIndexer, ok := db.storageIndexDB[address]
 	if ok {
 		for blkNum, storageRoot := range storageIndexer.root {
-			if blkNum >= fromBlockNum && (blkNum <= uint64(endBlockNum) || endBlockNum == -1) {
+			if inRange(blkNum, options.BeginBlockNumber, options.EndBlockNumber) {
 				convertedList = append(convertedList, &types.StorageResult{
 					Storage:     storageIndexer.storage[storageRoot],
 					StorageRoot: types.NewHash(storageRoot),
@@ -434,23 +652,20 @@ func (db *MemoryDB) GetStorageWithOptions(address types.Address, options *types.
 	sort.SliceStable(convertedList, func(i, j int) bool {
 		return convertedList[i].BlockNumber > convertedList[j].BlockNumber
 	})
-	return convertedList, nil
+	start, end := pageBounds(len(convertedList), options.PageSize, options.PageNumber)
+	return convertedList[start:end], nil
 }
 
 func (db *MemoryDB) GetStorageTotal(address types.Address, options *types.PageOptions) (uint64, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
-	if !db.addressIsRegistered(address) {
-		return 0, errors.New("address is not registered")
-	}
-	fromBlockNum := options.BeginBlockNumber.Uint64()
-	endBlockNum := options.EndBlockNumber.Int64()
-	toBlockNum := options.EndBlockNumber.Uint64()
+	options = pageOptions(options)
 	var total uint64
-	blockNum := fromBlockNum
-	for v := range db.storageIndexDB[address].root {
-		if v >= fromBlockNum && (endBlockNum == -1 || blockNum <= toBlockNum) {
-			total++
+	if storageIndexer, ok := db.storageIndexDB[address]; ok {
+		for v := range storageIndexer.root {
+			if inRange(v, options.BeginBlockNumber, options.EndBlockNumber) {
+				total++
+			}
 		}
 	}
 	return total, nil
@@ -460,13 +675,12 @@ func (db *MemoryDB) GetStorageRanges(contract types.Address, options *types.Page
 	db.mux.RLock()
 	defer db.mux.RUnlock()
 	if !db.addressIsRegistered(contract) {
-		return nil, errors.New("address is not registered")
+		return nil, database.ErrNotFound
 	}
 
 	end := options.EndBlockNumber
 	if big.NewInt(-1).Cmp(end) == 0 {
-		endUint64, _ := db.GetLastFiltered(contract)
-		end = new(big.Int).SetUint64(endUint64)
+		end = new(big.Int).SetUint64(db.lastFiltered[contract])
 	}
 
 	startUint64 := options.BeginBlockNumber.Uint64()
@@ -518,27 +732,41 @@ func (db *MemoryDB) GetStorageRanges(contract types.Address, options *types.Page
 func (db *MemoryDB) GetStorage(address types.Address, blockNumber uint64) (*types.StorageResult, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
-	if !db.addressIsRegistered(address) {
-		return nil, errors.New("address is not registered")
+	result := &types.StorageResult{
+		Storage:     make(map[types.Hash]string),
+		StorageRoot: types.NewHash(""),
+		BlockNumber: blockNumber,
 	}
-	storageRoot, ok := db.storageIndexDB[address].root[blockNumber]
+	storageIndexer, ok := db.storageIndexDB[address]
 	if !ok {
-		return &types.StorageResult{
-			Storage:     make(map[types.Hash]string),
-			StorageRoot: types.NewHash(""),
-			BlockNumber: blockNumber,
-		}, nil
-	}
-	return &types.StorageResult{
-		Storage:     db.storageIndexDB[address].storage[storageRoot],
-		StorageRoot: types.NewHash(storageRoot),
-		BlockNumber: blockNumber,
-	}, nil
+		return result, nil
+	}
+
+	// find the latest storage at or before the given block
+	found := false
+	var latest uint64
+	for blkNum := range storageIndexer.root {
+		if blkNum <= blockNumber && (!found || blkNum > latest) {
+			latest = blkNum
+			found = true
+		}
+	}
+	// there are no results, probably trying to query before the contract exists
+	if !found {
+		return result, nil
+	}
+	storageRoot := storageIndexer.root[latest]
+	result.Storage = storageIndexer.storage[storageRoot]
+	result.StorageRoot = types.NewHash(storageRoot)
+	return result, nil
 }
 
 func (db *MemoryDB) GetLastFiltered(address types.Address) (uint64, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
+	if !db.addressIsRegistered(address) {
+		return 0, database.ErrNotFound
+	}
 	return db.lastFiltered[address], nil
 }
 
@@ -578,18 +806,9 @@ func (db *MemoryDB) indexBlock(addresses []types.Address, block *types.BlockWith
 	return nil
 }
 
+// indexTransaction indexes the events of the transaction. Transactions sent to an address,
+// directly or internally, are indexed by their recipients when they are written.
 func (db *MemoryDB) indexTransaction(filteredAddresses map[types.Address]bool, tx *types.Transaction) {
-	if filteredAddresses[tx.To] {
-		db.txIndexDB[tx.To].txsTo = append(db.txIndexDB[tx.To].txsTo, tx.Hash)
-		log.Debug("Indexed tx recipient", "tx", tx.Hash.Hex(), "recipient", tx.To.Hex())
-	}
-
-	for _, internalCall := range tx.InternalCalls {
-		if filteredAddresses[internalCall.To] {
-			db.txIndexDB[internalCall.To].txsInternalTo = append(db.txIndexDB[internalCall.To].txsInternalTo, tx.Hash)
-			log.Debug("Indexed transactions internal calls", "tx", tx.Hash.Hex(), "internal-recipient", internalCall.To.Hex())
-		}
-	}
 	// Index events emitted by the given address
 	for _, event := range tx.Events {
 		addr := event.Address
@@ -600,44 +819,282 @@ func (db *MemoryDB) indexTransaction(filteredAddresses map[types.Address]bool, t
 	}
 }
 
+// templateName returns the template assigned to the address, which defaults to the
+// address itself
+func (db *MemoryDB) templateName(address types.Address) string {
+	if name, ok := db.templateDB[address]; ok {
+		return name
+	}
+	return address.String()
+}
+
 func (db *MemoryDB) removeAllIndices(address types.Address) error {
 	delete(db.txIndexDB, address)
 	delete(db.eventIndexDB, address)
 	delete(db.storageIndexDB, address)
-	db.lastFiltered[address] = 0
+	delete(db.lastFiltered, address)
+
+	erc20Balances := make([]ERC20TokenHolder, 0, len(db.erc20BalancesDB))
+	for _, balance := range db.erc20BalancesDB {
+		if balance.Contract != address {
+			erc20Balances = append(erc20Balances, balance)
+		}
+	}
+	db.erc20BalancesDB = erc20Balances
+	erc20Allowances := make([]types.ERC20Allowance, 0, len(db.erc20AllowancesDB))
+	for _, allowance := range db.erc20AllowancesDB {
+		if allowance.Contract != address {
+			erc20Allowances = append(erc20Allowances, allowance)
+		}
+	}
+	db.erc20AllowancesDB = erc20Allowances
+	erc721Tokens := make([]types.ERC721Token, 0, len(db.erc721BalancesDB))
+	for _, token := range db.erc721BalancesDB {
+		if token.Contract != address {
+			erc721Tokens = append(erc721Tokens, token)
+		}
+	}
+	db.erc721BalancesDB = erc721Tokens
+	erc1155Tokens := make([]types.ERC1155Token, 0, len(db.erc1155BalancesDB))
+	for _, token := range db.erc1155BalancesDB {
+		if token.Contract != address {
+			erc1155Tokens = append(erc1155Tokens, token)
+		}
+	}
+	db.erc1155BalancesDB = erc1155Tokens
+	tokenBalances := make([]types.TokenBalance, 0, len(db.tokenBalancesDB))
+	for _, balance := range db.tokenBalancesDB {
+		if balance.Contract != address {
+			tokenBalances = append(tokenBalances, balance)
+		}
+	}
+	db.tokenBalancesDB = tokenBalances
+	tokenTransfers := make([]types.TokenTransfer, 0, len(db.tokenTransfersDB))
+	for _, transfer := range db.tokenTransfersDB {
+		if transfer.Contract != address {
+			tokenTransfers = append(tokenTransfers, transfer)
+		}
+	}
+	db.tokenTransfersDB = tokenTransfers
+	delete(db.tokenInfoDB, address)
+	totalSupplies := make([]types.TokenSupply, 0, len(db.totalSupplyDB))
+	for _, supply := range db.totalSupplyDB {
+		if supply.Contract != address {
+			totalSupplies = append(totalSupplies, supply)
+		}
+	}
+	db.totalSupplyDB = totalSupplies
+	delete(db.proxyDB, address)
+	delete(db.backfillDB, address)
+	nativeBalances := make([]types.NativeBalance, 0, len(db.nativeBalancesDB))
+	for _, balance := range db.nativeBalancesDB {
+		if balance.Address != address {
+			nativeBalances = append(nativeBalances, balance)
+		}
+	}
+	db.nativeBalancesDB = nativeBalances
+	failures := make([]types.TransactionFailure, 0, len(db.failuresDB))
+	for _, failure := range db.failuresDB {
+		if failure.Address != address {
+			failures = append(failures, failure)
+		}
+	}
+	db.failuresDB = failures
+
+	// delete template if specialised
+	delete(db.templateDB, address)
+	delete(db.abiDB, address.String())
+	delete(db.storageLayoutDB, address.String())
 	return nil
 }
 
-func (db *MemoryDB) getERC20EntryAtBlock(contract types.Address, holder types.Address, block uint64) (*ERC20TokenHolder, error) {
-	db.mux.RLock()
-	defer db.mux.RUnlock()
-	var tmpItem *ERC20TokenHolder
-	found := false
+// transactionsInRange returns the indexed transactions that are within the ranges of the
+// options, sorted by block number descending and transaction index ascending
+func (db *MemoryDB) transactionsInRange(hashes []types.Hash, options *types.QueryOptions) []types.Hash {
+	var txs []*types.Transaction
+	for _, hash := range hashes {
+		tx, ok := db.txDB[hash]
+		if ok && inRange(tx.BlockNumber, options.BeginBlockNumber, options.EndBlockNumber) &&
+			inRange(tx.Timestamp, options.BeginTimestamp, options.EndTimestamp) && options.ValueInRange(tx.Value) {
+			txs = append(txs, tx)
+		}
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].BlockNumber != txs[j].BlockNumber {
+			return txs[i].BlockNumber > txs[j].BlockNumber
+		}
+		return txs[i].Index < txs[j].Index
+	})
+	result := make([]types.Hash, len(txs))
+	for i, tx := range txs {
+		result[i] = tx.Hash
+	}
+	return result
+}
+
+// eventsMatching returns the indexed events of the address within the ranges of the
+// options, sorted by block number descending and log index ascending
+func (db *MemoryDB) eventsMatching(address types.Address, options *types.QueryOptions) []*types.Event {
+	events := make([]*types.Event, 0)
+	for _, event := range db.eventIndexDB[address] {
+		if inRange(event.BlockNumber, options.BeginBlockNumber, options.EndBlockNumber) &&
+			inRange(event.Timestamp, options.BeginTimestamp, options.EndTimestamp) {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].BlockNumber != events[j].BlockNumber {
+			return events[i].BlockNumber > events[j].BlockNumber
+		}
+		return events[i].Index < events[j].Index
+	})
+	return events
+}
+
+func removeHashes(hashes []types.Hash, toRemove map[types.Hash]bool) []types.Hash {
+	remaining := make([]types.Hash, 0, len(hashes))
+	for _, hash := range hashes {
+		if !toRemove[hash] {
+			remaining = append(remaining, hash)
+		}
+	}
+	return remaining
+}
+
+// queryOptions returns a copy of the options with defaults set for any missing values
+func queryOptions(options *types.QueryOptions) *types.QueryOptions {
+	withDefaults := types.QueryOptions{}
+	if options != nil {
+		withDefaults = *options
+	}
+	withDefaults.SetDefaults()
+	return &withDefaults
+}
+
+// pageOptions returns a copy of the options with defaults set for any missing values
+func pageOptions(options *types.PageOptions) *types.PageOptions {
+	withDefaults := types.PageOptions{}
+	if options != nil {
+		withDefaults = *options
+	}
+	withDefaults.SetDefaults()
+	return &withDefaults
+}
+
+// tokenQueryOptions returns a copy of the options with defaults set for any missing values
+func tokenQueryOptions(options *types.TokenQueryOptions) *types.TokenQueryOptions {
+	withDefaults := types.TokenQueryOptions{}
+	if options != nil {
+		withDefaults = *options
+	}
+	withDefaults.SetDefaults()
+	return &withDefaults
+}
+
+// inRange checks if the value is within the given inclusive range, where an end of -1 is unbounded
+func inRange(value uint64, begin *big.Int, end *big.Int) bool {
+	if begin != nil && value < begin.Uint64() {
+		return false
+	}
+	if end != nil && end.Cmp(big.NewInt(-1)) != 0 && value > end.Uint64() {
+		return false
+	}
+	return true
+}
+
+// transferMatches checks if the transfer is of the given contract, is to or from the counterparty in the
+// given direction, and is within the block and time ranges of the options, ignoring any filter not given
+func transferMatches(transfer types.TokenTransfer, contract *types.Address, counterparty *types.Address, direction string, options *types.TokenTransferQueryOptions) bool {
+	if contract != nil && transfer.Contract != *contract {
+		return false
+	}
+	if counterparty != nil {
+		isIn := transfer.To == *counterparty && direction != types.TransferDirectionOut
+		isOut := transfer.From == *counterparty && direction != types.TransferDirectionIn
+		if !isIn && !isOut {
+			return false
+		}
+	}
+	return inRange(transfer.BlockNumber, options.BeginBlockNumber, options.EndBlockNumber) &&
+		inRange(transfer.Timestamp, options.BeginTimestamp, options.EndTimestamp)
+}
+
+func pageBounds(total int, pageSize int, pageNumber int) (int, int) {
+	start := pageSize * pageNumber
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+func paginateHashes(hashes []types.Hash, pageSize int, pageNumber int) []types.Hash {
+	start, end := pageBounds(len(hashes), pageSize, pageNumber)
+	return hashes[start:end]
+}
+
+// pageHolders sorts the holders by address, returning at most a page of holders after
+// the holder given in the options
+func pageHolders(holders map[types.Address]bool, options *types.TokenQueryOptions) []types.Address {
+	after := ""
+	if options.After != "" {
+		after = string(types.NewAddress(options.After))
+	}
+
+	sorted := make([]types.Address, 0, len(holders))
+	for holder := range holders {
+		if string(holder) > after {
+			sorted = append(sorted, holder)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	if len(sorted) > options.PageSize {
+		sorted = sorted[:options.PageSize]
+	}
+	return sorted
+}
+
+// getERC20EntryAtBlock finds the index of the balance entry with the highest block number
+// at or before the given block
+func (db *MemoryDB) getERC20EntryAtBlock(contract types.Address, holder types.Address, block uint64) (int, error) {
+	tmpItem := -1
 	for i, item := range db.erc20BalancesDB {
 		if item.BlockNumber <= block && item.Contract == contract && item.Holder == holder {
-			if !found {
-				tmpItem = &db.erc20BalancesDB[i]
-				found = true
-			} else {
-				if item.BlockNumber > tmpItem.BlockNumber {
-					tmpItem = &db.erc20BalancesDB[i]
-				}
+			if tmpItem == -1 || item.BlockNumber > db.erc20BalancesDB[tmpItem].BlockNumber {
+				tmpItem = i
 			}
 		}
 	}
-	if !found {
-		return nil, database.ErrNotFound
+	if tmpItem == -1 {
+		return -1, database.ErrNotFound
 	}
 	return tmpItem, nil
 }
 
 func (db *MemoryDB) RecordNewERC20Balance(contract types.Address, holder types.Address, block uint64, amount *big.Int) error {
-	existingTokenEntry, errExisting := db.getERC20EntryAtBlock(contract, holder, block-1)
 	db.mux.Lock()
 	defer db.mux.Unlock()
+
+	// replace the entry if the block was already recorded
+	if current, err := db.getERC20EntryAtBlock(contract, holder, block); err == nil && db.erc20BalancesDB[current].BlockNumber == block {
+		db.erc20BalancesDB[current].Amount = amount.String()
+		return nil
+	}
+
+	// update the older entry
+	existingTokenEntry, errExisting := db.getERC20EntryAtBlock(contract, holder, block-1)
 	if errExisting != nil && errExisting != database.ErrNotFound {
 		return errExisting
 	}
+	if errExisting == nil {
+		blk := block - 1
+		db.erc20BalancesDB[existingTokenEntry].HeldUntil = &blk
+	}
 
 	//add new entry
 	tokenInfo := ERC20TokenHolder{
@@ -647,54 +1104,45 @@ func (db *MemoryDB) RecordNewERC20Balance(contract types.Address, holder types.A
 		Amount:      amount.String(),
 	}
 	db.erc20BalancesDB = append(db.erc20BalancesDB, tokenInfo)
-	/////
-	if errExisting == database.ErrNotFound {
-		return nil
-	}
-	blk := block - 1
-	existingTokenEntry.HeldUntil = &blk
-
 	return nil
 }
 
 func (db *MemoryDB) GetERC20Balance(contract types.Address, holder types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
-	balanceMap := make(map[uint64]*big.Int)
-	frmBlkNum := options.BeginBlockNumber.Uint64()
-	endBlkNum := options.EndBlockNumber.Int64()
-	var maxEntry ERC20TokenHolder
-	maxEntryFound := false
+	options = tokenQueryOptions(options)
+	beginBlock := options.BeginBlockNumber.Uint64()
+
+	// get all the balances in the block range, as well as the last balance before the
+	// starting block if there was no balance update on the starting block
+	var balances []ERC20TokenHolder
 	for _, b := range db.erc20BalancesDB {
-		if contract == b.Contract && holder == b.Holder {
-			if b.BlockNumber >= frmBlkNum && (b.BlockNumber <= uint64(endBlkNum) || endBlkNum == -1) {
-				tokAmt, success := new(big.Int).SetString(b.Amount, 10)
-				if !success {
-					return nil, errors.New("could not parse token value")
-				}
-				balanceMap[b.BlockNumber] = tokAmt
-			}
-			if _, ok := balanceMap[frmBlkNum]; !ok && b.BlockNumber < frmBlkNum {
-				if !maxEntryFound {
-					maxEntry = b
-					maxEntryFound = true
-				} else {
-					if maxEntry.BlockNumber < b.BlockNumber {
-						maxEntry = b
-					}
-				}
-			}
+		if contract != b.Contract || holder != b.Holder {
+			continue
+		}
+		heldAtBegin := b.BlockNumber < beginBlock && (b.HeldUntil == nil || *b.HeldUntil >= beginBlock)
+		if heldAtBegin || inRange(b.BlockNumber, options.BeginBlockNumber, options.EndBlockNumber) {
+			balances = append(balances, b)
 		}
 	}
 
-	if _, ok := balanceMap[frmBlkNum]; !ok && maxEntryFound {
-		tokAmt, success := new(big.Int).SetString(maxEntry.Amount, 10)
+	sort.SliceStable(balances, func(i, j int) bool {
+		return balances[i].BlockNumber > balances[j].BlockNumber
+	})
+	start, end := pageBounds(len(balances), options.PageSize, options.PageNumber)
+
+	balanceMap := make(map[uint64]*big.Int)
+	for _, b := range balances[start:end] {
+		tokAmt, success := new(big.Int).SetString(b.Amount, 10)
 		if !success {
 			return nil, errors.New("could not parse token value")
 		}
-		balanceMap[frmBlkNum] = tokAmt
+		if b.BlockNumber < beginBlock {
+			balanceMap[beginBlock] = tokAmt
+		} else {
+			balanceMap[b.BlockNumber] = tokAmt
+		}
 	}
-
 	return balanceMap, nil
 }
 
@@ -703,77 +1151,482 @@ func (db *MemoryDB) GetAllTokenHolders(contract types.Address, block uint64, opt
 	defer db.mux.RUnlock()
 	var holderMap = make(map[types.Address]bool)
 	for _, k := range db.erc20BalancesDB {
-		if k.Contract == contract && k.BlockNumber <= block && k.Holder != "0000000000000000000000000000000000000000" {
+		if k.Contract == contract && k.BlockNumber <= block && (k.HeldUntil == nil || *k.HeldUntil >= block) {
 			holderMap[k.Holder] = true
 		}
 	}
-	holderArr := make([]types.Address, 0, len(holderMap))
-	for holdr := range holderMap {
-		holderArr = append(holderArr, holdr)
-	}
-	return holderArr, nil
+	delete(holderMap, types.NewAddress(""))
+	return pageHolders(holderMap, tokenQueryOptions(options)), nil
 }
 
-func (db *MemoryDB) RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error {
-	//find old entry
-	existingTokenEntry, errExisting := db.ERC721TokenByTokenID(contract, block-1, tokenId)
-	db.mux.Lock()
-	defer db.mux.Unlock()
-	if errExisting != nil && errExisting != database.ErrNotFound {
-		return errExisting
+func (db *MemoryDB) ERC20HolderBalancesAtBlock(contract types.Address, block uint64) (map[types.Address]*big.Int, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	balances := make(map[types.Address]*big.Int)
+	for _, b := range db.erc20BalancesDB {
+		if b.Contract != contract || b.Holder.IsEmpty() || b.BlockNumber > block || (b.HeldUntil != nil && *b.HeldUntil < block) {
+			continue
+		}
+		amount, success := new(big.Int).SetString(b.Amount, 10)
+		if !success {
+			return nil, errors.New("could not parse token value")
+		}
+		if amount.Sign() != 0 {
+			balances[b.Holder] = amount
+		}
 	}
+	return balances, nil
+}
 
-	//add new entry
-	tokenHolderInfo :=
-		types.ERC721Token{
-			Contract:  contract,
+func (db *MemoryDB) ERC20HolderCountAtBlock(contract types.Address, block uint64) (uint64, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	var count uint64
+	for _, b := range db.erc20BalancesDB {
+		if b.Contract == contract && !b.Holder.IsEmpty() && b.BlockNumber <= block && (b.HeldUntil == nil || *b.HeldUntil >= block) && b.Amount != "0" {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (db *MemoryDB) AllERC20BalancesForAccountAtBlock(holder types.Address, block uint64) (map[types.Address]*big.Int, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	balances := make(map[types.Address]*big.Int)
+	for _, b := range db.erc20BalancesDB {
+		if b.Holder != holder || b.BlockNumber > block || (b.HeldUntil != nil && *b.HeldUntil < block) {
+			continue
+		}
+		amount, success := new(big.Int).SetString(b.Amount, 10)
+		if !success {
+			return nil, errors.New("could not parse token value")
+		}
+		if amount.Sign() != 0 {
+			balances[b.Contract] = amount
+		}
+	}
+	return balances, nil
+}
+
+func (db *MemoryDB) ERC20BalanceChanges(contract types.Address, fromBlock uint64, toBlock uint64) ([]types.ERC20BalanceChange, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	changes := make([]types.ERC20BalanceChange, 0)
+	for _, b := range db.erc20BalancesDB {
+		if b.Contract == contract && !b.Holder.IsEmpty() && b.BlockNumber >= fromBlock && b.BlockNumber <= toBlock {
+			changes = append(changes, types.ERC20BalanceChange{Holder: b.Holder, BlockNumber: b.BlockNumber, Amount: b.Amount})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].BlockNumber != changes[j].BlockNumber {
+			return changes[i].BlockNumber < changes[j].BlockNumber
+		}
+		return changes[i].Holder < changes[j].Holder
+	})
+	return changes, nil
+}
+
+func (db *MemoryDB) RecordERC20Allowance(contract types.Address, owner types.Address, spender types.Address, block uint64, amount *big.Int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	// replace the entry if the block was already recorded
+	if current, err := db.erc20AllowanceAtBlock(contract, owner, spender, block); err == nil && db.erc20AllowancesDB[current].ApprovedFrom == block {
+		db.erc20AllowancesDB[current].Amount = amount.String()
+		return nil
+	}
+
+	// update the older entry
+	existing, errExisting := db.erc20AllowanceAtBlock(contract, owner, spender, block-1)
+	if errExisting != nil && errExisting != database.ErrNotFound {
+		return errExisting
+	}
+	if errExisting == nil {
+		blk := block - 1
+		db.erc20AllowancesDB[existing].ApprovedUntil = &blk
+	}
+
+	// add new entry
+	allowance := types.ERC20Allowance{
+		Contract:     contract,
+		Owner:        owner,
+		Spender:      spender,
+		Amount:       amount.String(),
+		ApprovedFrom: block,
+	}
+	db.erc20AllowancesDB = append(db.erc20AllowancesDB, allowance)
+	return nil
+}
+
+func (db *MemoryDB) ERC20AllowanceAtBlock(contract types.Address, owner types.Address, spender types.Address, block uint64) (*big.Int, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	entry, err := db.erc20AllowanceAtBlock(contract, owner, spender, block)
+	if err == database.ErrNotFound {
+		return big.NewInt(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	amount, success := new(big.Int).SetString(db.erc20AllowancesDB[entry].Amount, 10)
+	if !success {
+		return nil, errors.New("could not parse allowance value")
+	}
+	return amount, nil
+}
+
+func (db *MemoryDB) ERC20ApprovalsForOwnerAtBlock(contract types.Address, owner types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC20Allowance, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	options = tokenQueryOptions(options)
+	after := ""
+	if options.After != "" {
+		after = string(types.NewAddress(options.After))
+	}
+
+	var found []types.ERC20Allowance
+	for _, allowance := range db.erc20AllowancesDB {
+		if allowance.Contract != contract || allowance.Owner != owner || allowance.Amount == "0" || string(allowance.Spender) <= after {
+			continue
+		}
+		if allowance.ApprovedFrom > block || (allowance.ApprovedUntil != nil && *allowance.ApprovedUntil < block) {
+			continue
+		}
+		found = append(found, allowance)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Spender < found[j].Spender
+	})
+	if len(found) > options.PageSize {
+		found = found[:options.PageSize]
+	}
+	return found, nil
+}
+
+// erc20AllowanceAtBlock finds the index of the allowance entry with the highest starting
+// block at or before the given block
+func (db *MemoryDB) erc20AllowanceAtBlock(contract types.Address, owner types.Address, spender types.Address, block uint64) (int, error) {
+	tmpItem := -1
+	for i, item := range db.erc20AllowancesDB {
+		if item.Contract == contract && item.Owner == owner && item.Spender == spender && item.ApprovedFrom <= block {
+			if tmpItem == -1 || item.ApprovedFrom > db.erc20AllowancesDB[tmpItem].ApprovedFrom {
+				tmpItem = i
+			}
+		}
+	}
+	if tmpItem == -1 {
+		return -1, database.ErrNotFound
+	}
+	return tmpItem, nil
+}
+
+func (db *MemoryDB) RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	// replace the entry if the block was already recorded
+	if current, err := db.erc721TokenByTokenID(contract, block, tokenId); err == nil && db.erc721BalancesDB[current].HeldFrom == block {
+		db.erc721BalancesDB[current].Holder = holder
+		return nil
+	}
+
+	// update the older entry
+	existingTokenEntry, errExisting := db.erc721TokenByTokenID(contract, block-1, tokenId)
+	if errExisting != nil && errExisting != database.ErrNotFound {
+		return errExisting
+	}
+	if errExisting == nil {
+		blk := block - 1
+		db.erc721BalancesDB[existingTokenEntry].HeldUntil = &blk
+	}
+
+	//add new entry
+	tokenHolderInfo :=
+		types.ERC721Token{
+			Contract:  contract,
 			Holder:    holder,
 			Token:     tokenId.String(),
 			HeldFrom:  block,
 			HeldUntil: nil,
 		}
 	db.erc721BalancesDB = append(db.erc721BalancesDB, tokenHolderInfo)
-	/////
-	if errExisting == database.ErrNotFound {
-		return nil
+	return nil
+}
+
+func (db *MemoryDB) ERC721TokenByTokenID(contract types.Address, block uint64, tokenId *big.Int) (*types.ERC721Token, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	tmpItem, err := db.erc721TokenByTokenID(contract, block, tokenId)
+	if err != nil {
+		return nil, err
+	}
+	token := db.erc721BalancesDB[tmpItem]
+	return &token, nil
+}
+
+func (db *MemoryDB) ERC721TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
+	return db.erc721TokensAtBlock(contract, &holder, block, options)
+}
+
+func (db *MemoryDB) AllERC721TokensAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
+	return db.erc721TokensAtBlock(contract, nil, block, options)
+}
+
+func (db *MemoryDB) AllHoldersAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	res, err := db.getERC721TokensAtBlock(contract, nil, block, big.NewInt(0))
+	if err != nil {
+		return nil, err
 	}
 
-	blk := block - 1
-	existingTokenEntry.HeldUntil = &blk
+	var hldrMap = make(map[types.Address]bool)
+	for _, k := range res {
+		hldrMap[k.Holder] = true
+	}
+	return pageHolders(hldrMap, tokenQueryOptions(options)), nil
+}
+
+func (db *MemoryDB) AllERC721TokensForAccountAtBlock(holder types.Address, block uint64, options *types.AccountTokenQueryOptions) ([]types.ERC721Token, error) {
+	afterContract, afterId, hasCursor, err := options.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	type tokenWithId struct {
+		token types.ERC721Token
+		id    *big.Int
+	}
+	var found []tokenWithId
+	for _, k := range db.erc721BalancesDB {
+		if k.Holder != holder || k.HeldFrom > block || (k.HeldUntil != nil && *k.HeldUntil < block) {
+			continue
+		}
+		ercTokenId, success := new(big.Int).SetString(k.Token, 10)
+		if !success {
+			return nil, errors.New(`could not parse "erc721" token ID`)
+		}
+		if hasCursor && (k.Contract < afterContract || (k.Contract == afterContract && ercTokenId.Cmp(afterId) <= 0)) {
+			continue
+		}
+		found = append(found, tokenWithId{k, ercTokenId})
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].token.Contract != found[j].token.Contract {
+			return found[i].token.Contract < found[j].token.Contract
+		}
+		return found[i].id.Cmp(found[j].id) < 0
+	})
+	if len(found) > options.PageSize {
+		found = found[:options.PageSize]
+	}
+	result := make([]types.ERC721Token, len(found))
+	for i, item := range found {
+		result[i] = item.token
+	}
+	return result, nil
+}
+
+func (db *MemoryDB) RecordTokenTransfers(transfers []types.TokenTransfer) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	for _, transfer := range transfers {
+		replaced := false
+		for i, existing := range db.tokenTransfersDB {
+			if existing.Contract == transfer.Contract && existing.BlockNumber == transfer.BlockNumber && existing.LogIndex == transfer.LogIndex {
+				db.tokenTransfersDB[i] = transfer
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			db.tokenTransfersDB = append(db.tokenTransfersDB, transfer)
+		}
+	}
 	return nil
 }
 
-func (db *MemoryDB) ERC721TokenByTokenID(contract types.Address, block uint64, tokenId *big.Int) (*types.ERC721Token, error) {
+func (db *MemoryDB) ERC721TokenTransfers(contract types.Address, tokenId *big.Int, options *types.TokenQueryOptions) ([]types.TokenTransfer, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
-	var tmpItem int
-	found := false
+	options = tokenQueryOptions(options)
+
+	var found []types.TokenTransfer
+	for _, transfer := range db.tokenTransfersDB {
+		if transfer.Contract == contract && transfer.TokenId == tokenId.String() && inRange(transfer.BlockNumber, options.BeginBlockNumber, options.EndBlockNumber) {
+			found = append(found, transfer)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].BlockNumber != found[j].BlockNumber {
+			return found[i].BlockNumber < found[j].BlockNumber
+		}
+		return found[i].LogIndex < found[j].LogIndex
+	})
+	start, end := pageBounds(len(found), options.PageSize, options.PageNumber)
+	return found[start:end], nil
+}
+
+func (db *MemoryDB) GetTokenTransfers(contract *types.Address, counterparty *types.Address, direction string, options *types.TokenTransferQueryOptions) ([]types.TokenTransfer, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	afterBlock, afterLogIndex, hasCursor, err := options.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	var found []types.TokenTransfer
+	for _, transfer := range db.tokenTransfersDB {
+		if !transferMatches(transfer, contract, counterparty, direction, options) {
+			continue
+		}
+		if hasCursor && (transfer.BlockNumber < afterBlock || (transfer.BlockNumber == afterBlock && transfer.LogIndex <= afterLogIndex)) {
+			continue
+		}
+		found = append(found, transfer)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].BlockNumber != found[j].BlockNumber {
+			return found[i].BlockNumber < found[j].BlockNumber
+		}
+		return found[i].LogIndex < found[j].LogIndex
+	})
+	if len(found) > options.PageSize {
+		found = found[:options.PageSize]
+	}
+	return found, nil
+}
+
+// erc721TokenByTokenID finds the index of the token entry with the highest starting block
+// at or before the given block
+func (db *MemoryDB) erc721TokenByTokenID(contract types.Address, block uint64, tokenId *big.Int) (int, error) {
+	tmpItem := -1
 	for i, item := range db.erc721BalancesDB {
 		if item.Contract == contract && item.HeldFrom <= block && item.Token == tokenId.String() {
-			if !found {
+			if tmpItem == -1 || item.HeldFrom > db.erc721BalancesDB[tmpItem].HeldFrom {
 				tmpItem = i
-				found = true
-			} else {
-				if item.HeldFrom > db.erc721BalancesDB[tmpItem].HeldFrom {
-					tmpItem = i
-				}
 			}
+		}
+	}
+	if tmpItem == -1 {
+		return -1, database.ErrNotFound
+	}
+	return tmpItem, nil
+}
 
+// erc721TokensAtBlock returns the tokens held at the given block, ordered by token ID,
+// starting after the token ID in the options
+func (db *MemoryDB) erc721TokensAtBlock(contract types.Address, holder *types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	options = tokenQueryOptions(options)
+	startTokenId := big.NewInt(-1)
+	if options.After != "" {
+		parsed, success := new(big.Int).SetString(options.After, 10)
+		if !success {
+			return nil, errors.New(`could not parse "after" token ID`)
 		}
+		startTokenId = parsed
 	}
-	if !found {
-		return nil, database.ErrNotFound
+
+	result, err := db.getERC721TokensAtBlock(contract, holder, block, new(big.Int).Add(startTokenId, big.NewInt(1)))
+	if err != nil {
+		return nil, err
 	}
-	return &db.erc721BalancesDB[tmpItem], nil
+	start, end := pageBounds(len(result), options.PageSize, options.PageNumber)
+	return result[start:end], nil
 }
 
-func (db *MemoryDB) ERC721TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
-	return db.erc721TokensAtBlock(contract, &holder, block, options)
+func (db *MemoryDB) getERC721TokensAtBlock(contract types.Address, holder *types.Address, block uint64, startTokenId *big.Int) ([]types.ERC721Token, error) {
+	type tokenWithId struct {
+		token types.ERC721Token
+		id    *big.Int
+	}
+	var found []tokenWithId
+	for _, k := range db.erc721BalancesDB {
+		if k.Contract != contract || (holder != nil && *holder != k.Holder) {
+			continue
+		}
+		if k.HeldFrom > block || (k.HeldUntil != nil && *k.HeldUntil < block) {
+			continue
+		}
+		ercTokenId, success := new(big.Int).SetString(k.Token, 10)
+		if !success {
+			return nil, errors.New(`could not parse "erc721" token ID`)
+		}
+		if ercTokenId.Cmp(startTokenId) >= 0 {
+			found = append(found, tokenWithId{k, ercTokenId})
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].id.Cmp(found[j].id) < 0
+	})
+	result := make([]types.ERC721Token, len(found))
+	for i, item := range found {
+		result[i] = item.token
+	}
+	return result, nil
 }
 
-func (db *MemoryDB) erc721TokensAtBlock(contract types.Address, holder *types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
+func (db *MemoryDB) RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	// replace the entry if the block was already recorded
+	if current, err := db.erc1155EntryAtBlock(contract, holder, block, tokenId); err == nil && db.erc1155BalancesDB[current].HeldFrom == block {
+		db.erc1155BalancesDB[current].Amount = amount.String()
+		return nil
+	}
+
+	// update the older entry
+	existingTokenEntry, errExisting := db.erc1155EntryAtBlock(contract, holder, block-1, tokenId)
+	if errExisting != nil && errExisting != database.ErrNotFound {
+		return errExisting
+	}
+	if errExisting == nil {
+		blk := block - 1
+		db.erc1155BalancesDB[existingTokenEntry].HeldUntil = &blk
+	}
+
+	//add new entry
+	tokenInfo := types.ERC1155Token{
+		Contract: contract,
+		Holder:   holder,
+		Token:    tokenId.String(),
+		Amount:   amount.String(),
+		HeldFrom: block,
+	}
+	db.erc1155BalancesDB = append(db.erc1155BalancesDB, tokenInfo)
+	return nil
+}
+
+func (db *MemoryDB) ERC1155BalanceAtBlock(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) (*big.Int, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	entry, err := db.erc1155EntryAtBlock(contract, holder, block, tokenId)
+	if err == database.ErrNotFound {
+		return big.NewInt(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	amount, success := new(big.Int).SetString(db.erc1155BalancesDB[entry].Amount, 10)
+	if !success {
+		return nil, errors.New("could not parse token value")
+	}
+	return amount, nil
+}
+
+func (db *MemoryDB) ERC1155TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC1155Token, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
+	options = tokenQueryOptions(options)
 	startTokenId := big.NewInt(-1)
 	if options.After != "" {
 		parsed, success := new(big.Int).SetString(options.After, 10)
@@ -782,54 +1635,499 @@ func (db *MemoryDB) erc721TokensAtBlock(contract types.Address, holder *types.Ad
 		}
 		startTokenId = parsed
 	}
-	tokenId := new(big.Int).Add(startTokenId, big.NewInt(1))
 
-	var tmpItem types.ERC721Token
-	found := false
-	result := make([]types.ERC721Token, 0, 0)
-	for _, k := range db.erc721BalancesDB {
-		if k.Contract == contract && (holder == nil || *holder == k.Holder) && k.HeldFrom <= block {
-			ercTokenId, success := new(big.Int).SetString(k.Token, 10)
-			if !success {
-				return nil, errors.New(`could not parse "erc721" token ID`)
+	type tokenWithId struct {
+		token types.ERC1155Token
+		id    *big.Int
+	}
+	var found []tokenWithId
+	for _, k := range db.erc1155HeldAtBlock(contract, block) {
+		if k.Holder != holder {
+			continue
+		}
+		tokenId, success := new(big.Int).SetString(k.Token, 10)
+		if !success {
+			return nil, errors.New(`could not parse "erc1155" token ID`)
+		}
+		if tokenId.Cmp(startTokenId) > 0 {
+			found = append(found, tokenWithId{k, tokenId})
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].id.Cmp(found[j].id) < 0
+	})
+	start, end := pageBounds(len(found), options.PageSize, options.PageNumber)
+	result := make([]types.ERC1155Token, 0, end-start)
+	for _, item := range found[start:end] {
+		result = append(result, item.token)
+	}
+	return result, nil
+}
+
+func (db *MemoryDB) ERC1155HoldersAtBlock(contract types.Address, block uint64, tokenId *big.Int, options *types.TokenQueryOptions) ([]types.Address, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	holders := make(map[types.Address]bool)
+	for _, k := range db.erc1155HeldAtBlock(contract, block) {
+		if k.Token == tokenId.String() {
+			holders[k.Holder] = true
+		}
+	}
+	return pageHolders(holders, tokenQueryOptions(options)), nil
+}
+
+// erc1155EntryAtBlock finds the index of the balance entry with the highest starting block
+// at or before the given block
+func (db *MemoryDB) erc1155EntryAtBlock(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) (int, error) {
+	tmpItem := -1
+	for i, item := range db.erc1155BalancesDB {
+		if item.Contract == contract && item.Holder == holder && item.HeldFrom <= block && item.Token == tokenId.String() {
+			if tmpItem == -1 || item.HeldFrom > db.erc1155BalancesDB[tmpItem].HeldFrom {
+				tmpItem = i
 			}
-			if ercTokenId.Cmp(tokenId) >= 0 {
-				if !found {
-					tmpItem = k
-					result = append(result, tmpItem)
-					found = true
-				} else {
-					if k.HeldFrom > tmpItem.HeldFrom {
-						tmpItem = k
-						result = append(result, tmpItem)
-					}
-				}
+		}
+	}
+	if tmpItem == -1 {
+		return -1, database.ErrNotFound
+	}
+	return tmpItem, nil
+}
+
+// erc1155HeldAtBlock returns the non-zero balances of the contract at the given block
+func (db *MemoryDB) erc1155HeldAtBlock(contract types.Address, block uint64) []types.ERC1155Token {
+	var held []types.ERC1155Token
+	for _, k := range db.erc1155BalancesDB {
+		if k.Contract != contract || k.Amount == "0" {
+			continue
+		}
+		if k.HeldFrom > block || (k.HeldUntil != nil && *k.HeldUntil < block) {
+			continue
+		}
+		held = append(held, k)
+	}
+	return held
+}
+
+func (db *MemoryDB) RecordTokenBalance(standard string, contract types.Address, holder types.Address, block uint64, amount *big.Int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	// replace the entry if the block was already recorded
+	if current, err := db.tokenBalanceEntryAtBlock(standard, contract, holder, block); err == nil && db.tokenBalancesDB[current].HeldFrom == block {
+		db.tokenBalancesDB[current].Amount = amount.String()
+		return nil
+	}
+
+	// update the older entry
+	existingEntry, errExisting := db.tokenBalanceEntryAtBlock(standard, contract, holder, block-1)
+	if errExisting != nil && errExisting != database.ErrNotFound {
+		return errExisting
+	}
+	if errExisting == nil {
+		blk := block - 1
+		db.tokenBalancesDB[existingEntry].HeldUntil = &blk
+	}
+
+	//add new entry
+	balance := types.TokenBalance{
+		Standard: standard,
+		Contract: contract,
+		Holder:   holder,
+		Amount:   amount.String(),
+		HeldFrom: block,
+	}
+	db.tokenBalancesDB = append(db.tokenBalancesDB, balance)
+	return nil
+}
+
+func (db *MemoryDB) TokenBalanceAtBlock(standard string, contract types.Address, holder types.Address, block uint64) (*big.Int, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	entry, err := db.tokenBalanceEntryAtBlock(standard, contract, holder, block)
+	if err == database.ErrNotFound {
+		return big.NewInt(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	amount, success := new(big.Int).SetString(db.tokenBalancesDB[entry].Amount, 10)
+	if !success {
+		return nil, errors.New("could not parse token value")
+	}
+	return amount, nil
+}
+
+func (db *MemoryDB) TokenHoldersAtBlock(standard string, contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	holders := make(map[types.Address]bool)
+	for _, k := range db.tokenBalancesDB {
+		if k.Standard != standard || k.Contract != contract || k.Amount == "0" {
+			continue
+		}
+		if k.HeldFrom > block || (k.HeldUntil != nil && *k.HeldUntil < block) {
+			continue
+		}
+		holders[k.Holder] = true
+	}
+	return pageHolders(holders, tokenQueryOptions(options)), nil
+}
+
+// tokenBalanceEntryAtBlock finds the index of the balance entry with the highest starting
+// block at or before the given block
+func (db *MemoryDB) tokenBalanceEntryAtBlock(standard string, contract types.Address, holder types.Address, block uint64) (int, error) {
+	tmpItem := -1
+	for i, item := range db.tokenBalancesDB {
+		if item.Standard == standard && item.Contract == contract && item.Holder == holder && item.HeldFrom <= block {
+			if tmpItem == -1 || item.HeldFrom > db.tokenBalancesDB[tmpItem].HeldFrom {
+				tmpItem = i
 			}
+		}
+	}
+	if tmpItem == -1 {
+		return -1, database.ErrNotFound
+	}
+	return tmpItem, nil
+}
+
+func (db *MemoryDB) RecordTokenInfo(info *types.TokenInfo) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	db.tokenInfoDB[info.Contract] = *info
+	return nil
+}
+
+func (db *MemoryDB) GetTokenInfo(contract types.Address) (*types.TokenInfo, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	info, ok := db.tokenInfoDB[contract]
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	return &info, nil
+}
 
+func (db *MemoryDB) RecordTotalSupply(contract types.Address, block uint64, supply *big.Int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	// replace the entry if the block was already recorded
+	for i, item := range db.totalSupplyDB {
+		if item.Contract == contract && item.BlockNumber == block {
+			db.totalSupplyDB[i].TotalSupply = supply.String()
+			return nil
 		}
+	}
 
+	// update the older entry
+	existing := -1
+	for i, item := range db.totalSupplyDB {
+		if item.Contract == contract && item.BlockNumber < block {
+			if existing == -1 || item.BlockNumber > db.totalSupplyDB[existing].BlockNumber {
+				existing = i
+			}
+		}
+	}
+	if existing != -1 {
+		blk := block - 1
+		db.totalSupplyDB[existing].SupplyUntil = &blk
 	}
 
-	return result, nil
+	//add new entry
+	db.totalSupplyDB = append(db.totalSupplyDB, types.TokenSupply{
+		Contract:    contract,
+		TotalSupply: supply.String(),
+		BlockNumber: block,
+	})
+	return nil
 }
 
-func (db *MemoryDB) AllERC721TokensAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
-	return db.erc721TokensAtBlock(contract, nil, block, options)
+func (db *MemoryDB) GetTotalSupply(contract types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	options = tokenQueryOptions(options)
+	beginBlock := options.BeginBlockNumber.Uint64()
+
+	// get all the supplies in the block range, as well as the last supply before the
+	// starting block if there was no change on the starting block
+	var supplies []types.TokenSupply
+	for _, s := range db.totalSupplyDB {
+		if contract != s.Contract {
+			continue
+		}
+		atBegin := s.BlockNumber < beginBlock && (s.SupplyUntil == nil || *s.SupplyUntil >= beginBlock)
+		if atBegin || inRange(s.BlockNumber, options.BeginBlockNumber, options.EndBlockNumber) {
+			supplies = append(supplies, s)
+		}
+	}
+
+	sort.SliceStable(supplies, func(i, j int) bool {
+		return supplies[i].BlockNumber > supplies[j].BlockNumber
+	})
+	start, end := pageBounds(len(supplies), options.PageSize, options.PageNumber)
+
+	supplyMap := make(map[uint64]*big.Int)
+	for _, s := range supplies[start:end] {
+		supply, success := new(big.Int).SetString(s.TotalSupply, 10)
+		if !success {
+			return nil, errors.New("could not parse total supply")
+		}
+		if s.BlockNumber < beginBlock {
+			supplyMap[beginBlock] = supply
+		} else {
+			supplyMap[s.BlockNumber] = supply
+		}
+	}
+	return supplyMap, nil
 }
 
-func (db *MemoryDB) AllHoldersAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
-	res, err := db.erc721TokensAtBlock(contract, nil, block, options)
+// ProxyDB
+func (db *MemoryDB) RecordProxyImplementation(proxy types.Address, implementation *types.ProxyImplementation) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	record := *implementation
+	implementations := db.proxyDB[proxy]
+	for i, existing := range implementations {
+		if existing.BlockNumber == record.BlockNumber {
+			implementations[i] = &record
+			return nil
+		}
+	}
+	implementations = append(implementations, &record)
+	sort.Slice(implementations, func(i, j int) bool {
+		return implementations[i].BlockNumber < implementations[j].BlockNumber
+	})
+	db.proxyDB[proxy] = implementations
+	return nil
+}
+
+func (db *MemoryDB) GetProxyImplementations(proxy types.Address) ([]*types.ProxyImplementation, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	implementations := make([]*types.ProxyImplementation, len(db.proxyDB[proxy]))
+	for i, implementation := range db.proxyDB[proxy] {
+		record := *implementation
+		implementations[i] = &record
+	}
+	return implementations, nil
+}
+
+// BackfillDB
+func (db *MemoryDB) RecordBackfillJob(job *types.BackfillJob) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	db.backfillDB[job.Contract] = *job
+	return nil
+}
+
+func (db *MemoryDB) GetBackfillJob(contract types.Address) (*types.BackfillJob, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	job, ok := db.backfillDB[contract]
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	return &job, nil
+}
+
+func (db *MemoryDB) GetBackfillJobs() ([]*types.BackfillJob, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	jobs := make([]*types.BackfillJob, 0, len(db.backfillDB))
+	for _, job := range db.backfillDB {
+		job := job
+		jobs = append(jobs, &job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Contract < jobs[j].Contract })
+	return jobs, nil
+}
+
+// NativeDB
+func (db *MemoryDB) RecordNativeTransfers(transfers []types.NativeTransfer) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	for _, transfer := range transfers {
+		replaced := false
+		for i, existing := range db.nativeTransfersDB {
+			if existing.BlockNumber == transfer.BlockNumber && existing.Index == transfer.Index {
+				db.nativeTransfersDB[i] = transfer
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			db.nativeTransfersDB = append(db.nativeTransfersDB, transfer)
+		}
+	}
+	return nil
+}
+
+func (db *MemoryDB) GetNativeTransfers(address types.Address, direction string, options *types.TokenTransferQueryOptions) ([]types.NativeTransfer, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	afterBlock, afterIndex, hasCursor, err := options.Cursor()
 	if err != nil {
 		return nil, err
 	}
 
-	var hldrMap = make(map[types.Address]bool)
-	for _, k := range res {
-		hldrMap[k.Holder] = true
+	var found []types.NativeTransfer
+	for _, transfer := range db.nativeTransfersDB {
+		isIn := transfer.To == address && direction != types.TransferDirectionOut
+		isOut := transfer.From == address && direction != types.TransferDirectionIn
+		if !isIn && !isOut {
+			continue
+		}
+		if !inRange(transfer.BlockNumber, options.BeginBlockNumber, options.EndBlockNumber) || !inRange(transfer.Timestamp, options.BeginTimestamp, options.EndTimestamp) {
+			continue
+		}
+		if hasCursor && (transfer.BlockNumber < afterBlock || (transfer.BlockNumber == afterBlock && transfer.Index <= afterIndex)) {
+			continue
+		}
+		found = append(found, transfer)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].BlockNumber != found[j].BlockNumber {
+			return found[i].BlockNumber < found[j].BlockNumber
+		}
+		return found[i].Index < found[j].Index
+	})
+	if len(found) > options.PageSize {
+		found = found[:options.PageSize]
+	}
+	return found, nil
+}
+
+func (db *MemoryDB) RecordNativeBalance(address types.Address, block uint64, balance *big.Int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	record := types.NativeBalance{Address: address, Balance: balance.String(), BlockNumber: block}
+	for i, existing := range db.nativeBalancesDB {
+		if existing.Address == address && existing.BlockNumber == block {
+			db.nativeBalancesDB[i] = record
+			return nil
+		}
+	}
+	db.nativeBalancesDB = append(db.nativeBalancesDB, record)
+	return nil
+}
+
+func (db *MemoryDB) NativeBalanceAtBlock(address types.Address, block uint64) (*big.Int, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	latest := -1
+	for i, item := range db.nativeBalancesDB {
+		if item.Address == address && item.BlockNumber <= block {
+			if latest == -1 || item.BlockNumber > db.nativeBalancesDB[latest].BlockNumber {
+				latest = i
+			}
+		}
+	}
+	if latest == -1 {
+		return nil, database.ErrNotFound
+	}
+	balance, success := new(big.Int).SetString(db.nativeBalancesDB[latest].Balance, 10)
+	if !success {
+		return nil, errors.New("could not parse native balance")
+	}
+	return balance, nil
+}
+
+func (db *MemoryDB) GetNativeBalance(address types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	options = tokenQueryOptions(options)
+	beginBlock := options.BeginBlockNumber.Uint64()
+
+	// get all the balances in the block range, as well as the last balance before the
+	// starting block if there was no balance recorded on the starting block
+	var balances []types.NativeBalance
+	latestBefore := -1
+	recordedAtBegin := false
+	for i, b := range db.nativeBalancesDB {
+		if address != b.Address {
+			continue
+		}
+		if b.BlockNumber < beginBlock {
+			if latestBefore == -1 || b.BlockNumber > db.nativeBalancesDB[latestBefore].BlockNumber {
+				latestBefore = i
+			}
+			continue
+		}
+		if inRange(b.BlockNumber, options.BeginBlockNumber, options.EndBlockNumber) {
+			balances = append(balances, b)
+			recordedAtBegin = recordedAtBegin || b.BlockNumber == beginBlock
+		}
+	}
+	if latestBefore != -1 && !recordedAtBegin {
+		balances = append(balances, db.nativeBalancesDB[latestBefore])
 	}
-	holders := make([]types.Address, 0, len(hldrMap))
-	for k := range hldrMap {
-		holders = append(holders, k)
+
+	sort.SliceStable(balances, func(i, j int) bool {
+		return balances[i].BlockNumber > balances[j].BlockNumber
+	})
+	start, end := pageBounds(len(balances), options.PageSize, options.PageNumber)
+
+	balanceMap := make(map[uint64]*big.Int)
+	for _, b := range balances[start:end] {
+		balance, success := new(big.Int).SetString(b.Balance, 10)
+		if !success {
+			return nil, errors.New("could not parse native balance")
+		}
+		if b.BlockNumber < beginBlock {
+			balanceMap[beginBlock] = balance
+		} else {
+			balanceMap[b.BlockNumber] = balance
+		}
+	}
+	return balanceMap, nil
+}
+
+// FailureDB
+func (db *MemoryDB) RecordTransactionFailures(failures []types.TransactionFailure) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	for _, failure := range failures {
+		replaced := false
+		for i, existing := range db.failuresDB {
+			if existing.Address == failure.Address && existing.TransactionHash == failure.TransactionHash {
+				db.failuresDB[i] = failure
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			db.failuresDB = append(db.failuresDB, failure)
+		}
+	}
+	return nil
+}
+
+func (db *MemoryDB) GetTransactionFailures(address types.Address, reason string, options *types.PageOptions) ([]types.TransactionFailure, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	options = pageOptions(options)
+
+	found := []types.TransactionFailure{}
+	for _, failure := range db.failuresDB {
+		if failure.Address != address || (reason != "" && failure.Reason != reason) {
+			continue
+		}
+		if inRange(failure.BlockNumber, options.BeginBlockNumber, options.EndBlockNumber) {
+			found = append(found, failure)
+		}
 	}
-	return holders, nil
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].BlockNumber != found[j].BlockNumber {
+			return found[i].BlockNumber > found[j].BlockNumber
+		}
+		return found[i].Index > found[j].Index
+	})
+	start, end := pageBounds(len(found), options.PageSize, options.PageNumber)
+	return found[start:end], nil
 }
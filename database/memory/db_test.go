@@ -25,7 +25,7 @@ var (
 		BlockNumber:     1,
 		From:            types.NewAddress("0x0000000000000000000000000000000000000009"),
 		To:              "",
-		Value:           666,
+		Value:           types.NewBigIntFromUint64(666),
 		CreatedContract: addr,
 	}
 	tx2 = &types.Transaction{
@@ -33,7 +33,7 @@ var (
 		BlockNumber: 1,
 		From:        types.NewAddress("0x0000000000000000000000000000000000000009"),
 		To:          uselessAddress,
-		Value:       666,
+		Value:       types.NewBigIntFromUint64(666),
 		InternalCalls: []*types.InternalCall{
 			{
 				To: addr,
@@ -45,7 +45,7 @@ var (
 		BlockNumber: 1,
 		From:        types.NewAddress("0x0000000000000000000000000000000000000010"),
 		To:          addr,
-		Value:       666,
+		Value:       types.NewBigIntFromUint64(666),
 		Events: []*types.Event{
 			{}, // dummy event
 			{Address: addr},
@@ -141,12 +141,14 @@ func TestMemoryDB(t *testing.T) {
 	testGetTransactionsInternalToAddressTotal(t, db, addr, 1)
 	testGetAllEventsByAddress(t, db, addr, 1)
 	testGetStorage(t, db, addr, 1, 2)
+	testGetStorage(t, db, addr, 2, 2)
 	testGetStorageTotal(t, db, addr, &types.PageOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(1)}, 1)
 	testGetStorageTotal(t, db, addr, &types.PageOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(-1)}, 1)
 	testGetStorageWithOptions(t, db, addr, &types.PageOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(1)}, 1)
 	// 6. Delete address and check last filtered
 	testDeleteAddress(t, db, addr, false)
-	testGetLastFiltered(t, db, addr, 0)
+	_, err := db.GetLastFiltered(addr)
+	assert.Equal(t, database.ErrNotFound, err)
 }
 
 func testAddAddresses(t *testing.T, db database.Database, addresses []types.Address, expectedErr bool) {
@@ -355,8 +357,8 @@ func testGetStorage(t *testing.T, db database.Database, address types.Address, b
 	assert.Nil(t, err)
 	assert.Len(t, storage.Storage, expected)
 
-	//test on a block number we don't have storage for
-	storageUnknown, err := db.GetStorage(address, blockNumber+1)
+	//test on a block number before there was any storage
+	storageUnknown, err := db.GetStorage(address, 0)
 	assert.Nil(t, err)
 	assert.Len(t, storageUnknown.Storage, 0)
 	assert.EqualValues(t, types.NewHash(""), storageUnknown.StorageRoot)
@@ -430,7 +432,7 @@ func TestMemoryDB_ContractCreationTransactions_DeletedAddress(t *testing.T) {
 	assert.Nil(t, err)
 
 	actualTxHash, err := db.GetContractCreationTransaction(sampleAddress)
-	assert.EqualError(t, err, "address is not registered")
+	assert.Equal(t, database.ErrNotFound, err)
 	assert.EqualValues(t, "", actualTxHash)
 }
 
@@ -678,3 +680,57 @@ func TestMemorydb_erc721Balance(t *testing.T) {
 	assert.Equal(t, holder1Found, true)
 
 }
+
+func TestMemoryDB_RollbackBlocks(t *testing.T) {
+	db := NewMemoryDB()
+	holder := types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+	tx4 := &types.Transaction{
+		Hash:        types.NewHash("0x5c83fa5955aff33c61813105851777bcd2adc85deb9af6286ba42c05cd768de0"),
+		BlockNumber: 2,
+		From:        types.NewAddress("0x0000000000000000000000000000000000000010"),
+		To:          addr,
+		Events: []*types.Event{
+			{Address: addr, BlockNumber: 2},
+		},
+	}
+	block2 := &types.Block{Hash: types.NewHash("dummy2"), Number: 2, Transactions: []types.Hash{tx4.Hash}}
+
+	assert.Nil(t, db.AddAddresses([]types.Address{addr}))
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx1, tx2, tx3, tx4}))
+	assert.Nil(t, db.WriteBlocks([]*types.Block{block, block2}))
+	assert.Nil(t, db.IndexBlocks([]types.Address{addr}, []*types.BlockWithTransactions{
+		blockWithTransactions,
+		{Hash: block2.Hash, Number: 2, Transactions: []*types.Transaction{tx4}},
+	}))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holder, 1, big.NewInt(1000)))
+	assert.Nil(t, db.RecordNewERC20Balance(addr, holder, 2, big.NewInt(900)))
+
+	reorg := &types.ChainReorg{CommonAncestor: 1, NewHead: 3, NewHeadHash: types.NewHash("new")}
+	err := db.RollbackBlocks(reorg)
+	assert.Nil(t, err, "unexpected error")
+
+	lastPersisted, _ := db.GetLastPersistedBlockNumber()
+	assert.EqualValues(t, 1, lastPersisted)
+	lastFiltered, _ := db.GetLastFiltered(addr)
+	assert.EqualValues(t, 1, lastFiltered)
+
+	_, err = db.ReadBlock(2)
+	assert.Equal(t, database.ErrNotFound, err)
+	_, err = db.ReadTransaction(tx4.Hash)
+	assert.Equal(t, database.ErrNotFound, err)
+	retrievedBlock, err := db.ReadBlock(1)
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, block, retrievedBlock)
+
+	txsTo, _ := db.GetAllTransactionsToAddress(addr, &types.QueryOptions{})
+	assert.Equal(t, []types.Hash{tx3.Hash}, txsTo)
+	events, _ := db.GetAllEventsFromAddress(addr, &types.QueryOptions{})
+	assert.Len(t, events, 1)
+
+	balances, err := db.GetERC20Balance(addr, holder, &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(5)})
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(1000)}, balances)
+
+	reorgs, _ := db.GetChainReorgs()
+	assert.Equal(t, []*types.ChainReorg{reorg}, reorgs)
+}
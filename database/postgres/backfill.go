@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"quorumengineering/quorum-report/types"
+)
+
+// BackfillDB
+func (pg *PostgresDB) RecordBackfillJob(job *types.BackfillJob) error {
+	_, err := pg.db.Exec(`
+INSERT INTO backfill_job (contract, from_block, to_block, next_block, status) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (contract) DO UPDATE SET from_block = EXCLUDED.from_block, to_block = EXCLUDED.to_block, next_block = EXCLUDED.next_block, status = EXCLUDED.status`,
+		job.Contract, job.FromBlock, job.ToBlock, job.NextBlock, job.Status)
+	return err
+}
+
+func (pg *PostgresDB) GetBackfillJob(contract types.Address) (*types.BackfillJob, error) {
+	job := types.BackfillJob{Contract: contract}
+	err := pg.db.QueryRow(`SELECT from_block, to_block, next_block, status FROM backfill_job WHERE contract = $1`, contract).
+		Scan(&job.FromBlock, &job.ToBlock, &job.NextBlock, &job.Status)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &job, nil
+}
+
+func (pg *PostgresDB) GetBackfillJobs() ([]*types.BackfillJob, error) {
+	rows, err := pg.db.Query(`SELECT contract, from_block, to_block, next_block, status FROM backfill_job ORDER BY contract`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]*types.BackfillJob, 0)
+	for rows.Next() {
+		var job types.BackfillJob
+		if err := rows.Scan(&job.Contract, &job.FromBlock, &job.ToBlock, &job.NextBlock, &job.Status); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, &job)
+	}
+	return jobs, rows.Err()
+}
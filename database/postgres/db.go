@@ -0,0 +1,509 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/lib/pq"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// PostgresDB stores all data in a PostgreSQL database, so it can be joined with other
+// relational data.
+type PostgresDB struct {
+	db *sql.DB
+}
+
+func New(url string) (*PostgresDB, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	pg, err := NewWithDB(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return pg, nil
+}
+
+// NewWithDB uses an already opened connection, applying any missing migrations to it
+func NewWithDB(db *sql.DB) (*PostgresDB, error) {
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+	return &PostgresDB{db: db}, nil
+}
+
+// AddressDB
+func (pg *PostgresDB) AddAddresses(addresses []types.Address) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		for _, address := range addresses {
+			if err := addContract(tx, address, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (pg *PostgresDB) AddAddressFrom(address types.Address, from uint64) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		return addContract(tx, address, from-1)
+	})
+}
+
+func (pg *PostgresDB) DeleteAddress(address types.Address) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		result, err := tx.Exec(`DELETE FROM contract WHERE address = $1`, address)
+		if err != nil {
+			return err
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return errors.New("address does not exist")
+		}
+
+		for _, table := range []string{"event", "storage"} {
+			if _, err := tx.Exec(`DELETE FROM `+table+` WHERE address = $1`, address); err != nil {
+				return err
+			}
+		}
+		for _, table := range []string{"erc20_balance", "erc20_allowance", "erc721_token", "erc1155_balance", "token_balance", "token_transfer", "token_info", "total_supply"} {
+			if _, err := tx.Exec(`DELETE FROM `+table+` WHERE contract = $1`, address); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(`DELETE FROM proxy_implementation WHERE proxy = $1`, address); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM backfill_job WHERE contract = $1`, address); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM native_balance WHERE address = $1`, address); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM transaction_failure WHERE address = $1`, address); err != nil {
+			return err
+		}
+		log.Debug("Deleted contract events, storage, token, proxy, backfill, native balance and failure data", "contract", address.String())
+
+		// delete template if specialised
+		_, err = tx.Exec(`DELETE FROM template WHERE name = $1`, address.String())
+		return err
+	})
+}
+
+func (pg *PostgresDB) GetAddresses() ([]types.Address, error) {
+	rows, err := pg.db.Query(`SELECT address FROM contract ORDER BY address`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	addresses := make([]types.Address, 0)
+	for rows.Next() {
+		var address types.Address
+		if err := rows.Scan(&address); err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, rows.Err()
+}
+
+func (pg *PostgresDB) GetContractTemplate(address types.Address) (string, error) {
+	var templateName string
+	err := pg.db.QueryRow(`SELECT template_name FROM contract WHERE address = $1`, address).Scan(&templateName)
+	return templateName, notFound(err)
+}
+
+// TemplateDB
+func (pg *PostgresDB) GetContractABI(address types.Address) (string, error) {
+	template, err := pg.getTemplateForContract(address)
+	if err != nil || template == nil {
+		return "", err
+	}
+	return template.ABI, nil
+}
+
+func (pg *PostgresDB) GetStorageLayout(address types.Address) (string, error) {
+	template, err := pg.getTemplateForContract(address)
+	if err != nil || template == nil {
+		return "", err
+	}
+	return template.StorageLayout, nil
+}
+
+func (pg *PostgresDB) AddTemplate(name string, abi string, layout string) error {
+	_, err := pg.db.Exec(`
+INSERT INTO template (name, abi, storage_layout) VALUES ($1, $2, $3)
+ON CONFLICT (name) DO UPDATE SET abi = EXCLUDED.abi, storage_layout = EXCLUDED.storage_layout`,
+		name, abi, layout)
+	return err
+}
+
+func (pg *PostgresDB) AssignTemplate(address types.Address, name string) error {
+	result, err := pg.db.Exec(`UPDATE contract SET template_name = $2 WHERE address = $1`, address, name)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return database.ErrNotFound
+	}
+	return nil
+}
+
+func (pg *PostgresDB) GetTemplates() ([]string, error) {
+	rows, err := pg.db.Query(`SELECT name FROM template ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		templates = append(templates, name)
+	}
+	return templates, rows.Err()
+}
+
+func (pg *PostgresDB) GetTemplateDetails(templateName string) (*types.Template, error) {
+	template := &types.Template{TemplateName: templateName}
+	err := pg.db.QueryRow(`SELECT abi, storage_layout FROM template WHERE name = $1`, templateName).
+		Scan(&template.ABI, &template.StorageLayout)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return template, nil
+}
+
+// BlockDB
+func (pg *PostgresDB) WriteBlocks(blocks []*types.Block) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(`
+INSERT INTO block (number, hash, parent_hash, state_root, tx_root, receipt_root, gas_limit, gas_used, timestamp, extra_data, transactions)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (number) DO UPDATE SET
+	hash = EXCLUDED.hash, parent_hash = EXCLUDED.parent_hash, state_root = EXCLUDED.state_root,
+	tx_root = EXCLUDED.tx_root, receipt_root = EXCLUDED.receipt_root, gas_limit = EXCLUDED.gas_limit,
+	gas_used = EXCLUDED.gas_used, timestamp = EXCLUDED.timestamp, extra_data = EXCLUDED.extra_data,
+	transactions = EXCLUDED.transactions`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, block := range blocks {
+			if block == nil {
+				return errors.New("block is nil")
+			}
+			_, err := stmt.Exec(block.Number, block.Hash, block.ParentHash, block.StateRoot, block.TxRoot, block.ReceiptRoot,
+				numeric(block.GasLimit), numeric(block.GasUsed), block.Timestamp, block.ExtraData, pq.Array(hashesToStrings(block.Transactions)))
+			if err != nil {
+				return err
+			}
+			log.Debug("Block stored", "number", block.Number, "hash", block.Hash.String())
+		}
+
+		// move the last persisted block number to the end of the contiguous run of blocks
+		// following it, if the next block has been written
+		_, err = tx.Exec(`
+UPDATE meta SET value = (
+	SELECT MIN(b.number) FROM block b
+	WHERE b.number > meta.value AND NOT EXISTS (SELECT 1 FROM block n WHERE n.number = b.number + 1)
+)
+WHERE key = 'lastPersisted' AND EXISTS (SELECT 1 FROM block WHERE number = meta.value + 1)`)
+		return err
+	})
+}
+
+func (pg *PostgresDB) ReadBlock(blockNumber uint64) (*types.Block, error) {
+	var block types.Block
+	var transactions []string
+	err := pg.db.QueryRow(`
+SELECT number, hash, parent_hash, state_root, tx_root, receipt_root, gas_limit, gas_used, timestamp, extra_data, transactions
+FROM block WHERE number = $1`, blockNumber).
+		Scan(&block.Number, &block.Hash, &block.ParentHash, &block.StateRoot, &block.TxRoot, &block.ReceiptRoot,
+			&block.GasLimit, &block.GasUsed, &block.Timestamp, &block.ExtraData, pq.Array(&transactions))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	block.Transactions = stringsToHashes(transactions)
+	return &block, nil
+}
+
+func (pg *PostgresDB) GetLastPersistedBlockNumber() (uint64, error) {
+	var lastPersisted uint64
+	err := pg.db.QueryRow(`SELECT value FROM meta WHERE key = 'lastPersisted'`).Scan(&lastPersisted)
+	return lastPersisted, err
+}
+
+func (pg *PostgresDB) RollbackBlocks(reorg *types.ChainReorg) error {
+	ancestor := reorg.CommonAncestor
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		// contracts created in an orphaned transaction have their creation transaction cleared
+		statements := []string{
+			`UPDATE contract SET creation_tx = '' WHERE creation_tx IN (SELECT hash FROM transaction WHERE block_number > $1)`,
+			`UPDATE contract SET last_filtered = $1 WHERE last_filtered > $1`,
+			`UPDATE meta SET value = $1 WHERE key = 'lastPersisted' AND value > $1`,
+			`DELETE FROM transaction WHERE block_number > $1`,
+			`DELETE FROM block WHERE number > $1`,
+			`DELETE FROM event WHERE block_number > $1`,
+			`DELETE FROM storage WHERE block_number > $1`,
+			`DELETE FROM proxy_implementation WHERE block_number > $1`,
+			`DELETE FROM native_transfer WHERE block_number > $1`,
+			`DELETE FROM native_balance WHERE block_number > $1`,
+			`DELETE FROM transaction_failure WHERE block_number > $1`,
+		}
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement, ancestor); err != nil {
+				return err
+			}
+		}
+
+		if err := rollbackTokens(tx, nil, ancestor+1, math.MaxInt64); err != nil {
+			return err
+		}
+
+		log.Debug("Rolled back blocks", "common ancestor", ancestor)
+		_, err := tx.Exec(`INSERT INTO chain_reorg (common_ancestor, new_head, new_head_hash, detected_at) VALUES ($1, $2, $3, $4)`,
+			reorg.CommonAncestor, reorg.NewHead, reorg.NewHeadHash, reorg.DetectedAt)
+		return err
+	})
+}
+
+func (pg *PostgresDB) GetChainReorgs() ([]*types.ChainReorg, error) {
+	rows, err := pg.db.Query(`SELECT common_ancestor, new_head, new_head_hash, detected_at FROM chain_reorg ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reorgs := make([]*types.ChainReorg, 0)
+	for rows.Next() {
+		var reorg types.ChainReorg
+		if err := rows.Scan(&reorg.CommonAncestor, &reorg.NewHead, &reorg.NewHeadHash, &reorg.DetectedAt); err != nil {
+			return nil, err
+		}
+		reorgs = append(reorgs, &reorg)
+	}
+	return reorgs, rows.Err()
+}
+
+// TransactionDB
+func (pg *PostgresDB) WriteTransactions(transactions []*types.Transaction) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(`
+INSERT INTO transaction (hash, status, block_number, block_hash, tx_index, nonce, from_address, to_address, value, gas, gas_price,
+	gas_used, cumulative_gas_used, created_contract, data, private_data, is_private, timestamp, events, internal_calls,
+	tx_type, chain_id, access_list, max_fee_per_gas, max_priority_fee_per_gas, effective_gas_price, error, revert_data)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20,
+	$21, $22, $23, $24, $25, $26, $27, $28)
+ON CONFLICT (hash) DO UPDATE SET
+	status = EXCLUDED.status, block_number = EXCLUDED.block_number, block_hash = EXCLUDED.block_hash,
+	tx_index = EXCLUDED.tx_index, nonce = EXCLUDED.nonce, from_address = EXCLUDED.from_address,
+	to_address = EXCLUDED.to_address, value = EXCLUDED.value, gas = EXCLUDED.gas, gas_price = EXCLUDED.gas_price,
+	gas_used = EXCLUDED.gas_used, cumulative_gas_used = EXCLUDED.cumulative_gas_used,
+	created_contract = EXCLUDED.created_contract, data = EXCLUDED.data, private_data = EXCLUDED.private_data,
+	is_private = EXCLUDED.is_private, timestamp = EXCLUDED.timestamp, events = EXCLUDED.events,
+	internal_calls = EXCLUDED.internal_calls, tx_type = EXCLUDED.tx_type, chain_id = EXCLUDED.chain_id,
+	access_list = EXCLUDED.access_list, max_fee_per_gas = EXCLUDED.max_fee_per_gas,
+	max_priority_fee_per_gas = EXCLUDED.max_priority_fee_per_gas, effective_gas_price = EXCLUDED.effective_gas_price,
+	error = EXCLUDED.error, revert_data = EXCLUDED.revert_data`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, transaction := range transactions {
+			if transaction == nil {
+				return errors.New("transaction is nil")
+			}
+			events, err := json.Marshal(transaction.Events)
+			if err != nil {
+				return err
+			}
+			internalCalls, err := json.Marshal(transaction.InternalCalls)
+			if err != nil {
+				return err
+			}
+			accessList, err := json.Marshal(transaction.AccessList)
+			if err != nil {
+				return err
+			}
+			_, err = stmt.Exec(transaction.Hash, transaction.Status, transaction.BlockNumber, transaction.BlockHash,
+				transaction.Index, numeric(transaction.Nonce), transaction.From, transaction.To, transaction.Value.String(),
+				numeric(transaction.Gas), transaction.GasPrice.String(), numeric(transaction.GasUsed),
+				numeric(transaction.CumulativeGasUsed), transaction.CreatedContract, transaction.Data,
+				transaction.PrivateData, transaction.IsPrivate, transaction.Timestamp, string(events), string(internalCalls),
+				transaction.Type, nullableAmount(transaction.ChainID), string(accessList), nullableAmount(transaction.MaxFeePerGas),
+				nullableAmount(transaction.MaxPriorityFeePerGas), nullableAmount(transaction.EffectiveGasPrice),
+				transaction.Error, transaction.RevertData)
+			if err != nil {
+				return err
+			}
+			log.Debug("Transaction stored", "hash", transaction.Hash.Hex())
+		}
+		return nil
+	})
+}
+
+func (pg *PostgresDB) ReadTransaction(hash types.Hash) (*types.Transaction, error) {
+	var transaction types.Transaction
+	var value, gasPrice string
+	var chainID, maxFeePerGas, maxPriorityFeePerGas, effectiveGasPrice sql.NullString
+	var events, internalCalls, accessList []byte
+	err := pg.db.QueryRow(`
+SELECT hash, status, block_number, block_hash, tx_index, nonce, from_address, to_address, value, gas, gas_price,
+	gas_used, cumulative_gas_used, created_contract, data, private_data, is_private, timestamp, events, internal_calls,
+	tx_type, chain_id, access_list, max_fee_per_gas, max_priority_fee_per_gas, effective_gas_price, error, revert_data
+FROM transaction WHERE hash = $1`, hash).
+		Scan(&transaction.Hash, &transaction.Status, &transaction.BlockNumber, &transaction.BlockHash, &transaction.Index,
+			&transaction.Nonce, &transaction.From, &transaction.To, &value, &transaction.Gas,
+			&gasPrice, &transaction.GasUsed, &transaction.CumulativeGasUsed, &transaction.CreatedContract,
+			&transaction.Data, &transaction.PrivateData, &transaction.IsPrivate, &transaction.Timestamp, &events, &internalCalls,
+			&transaction.Type, &chainID, &accessList, &maxFeePerGas, &maxPriorityFeePerGas, &effectiveGasPrice,
+			&transaction.Error, &transaction.RevertData)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if transaction.Value, err = types.ParseBigInt(value); err != nil {
+		return nil, err
+	}
+	if transaction.GasPrice, err = types.ParseBigInt(gasPrice); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(events, &transaction.Events); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(internalCalls, &transaction.InternalCalls); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(accessList, &transaction.AccessList); err != nil {
+		return nil, err
+	}
+	if transaction.ChainID, err = parseNullableAmount(chainID); err != nil {
+		return nil, err
+	}
+	if transaction.MaxFeePerGas, err = parseNullableAmount(maxFeePerGas); err != nil {
+		return nil, err
+	}
+	if transaction.MaxPriorityFeePerGas, err = parseNullableAmount(maxPriorityFeePerGas); err != nil {
+		return nil, err
+	}
+	if transaction.EffectiveGasPrice, err = parseNullableAmount(effectiveGasPrice); err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (pg *PostgresDB) Stop() {
+	if err := pg.db.Close(); err != nil {
+		log.Error("Error closing database", "err", err)
+	}
+}
+
+// Internal functions
+
+// inTransaction runs the given function in a database transaction, committing it if the
+// function succeeds and rolling it back otherwise
+func (pg *PostgresDB) inTransaction(fn func(tx *sql.Tx) error) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func addContract(tx *sql.Tx, address types.Address, lastFiltered uint64) error {
+	// only create if the contract does not exist
+	_, err := tx.Exec(`
+INSERT INTO contract (address, template_name, last_filtered) VALUES ($1, $2, $3)
+ON CONFLICT (address) DO NOTHING`, address, address.String(), lastFiltered)
+	return err
+}
+
+func (pg *PostgresDB) getTemplateForContract(address types.Address) (*types.Template, error) {
+	var template types.Template
+	err := pg.db.QueryRow(`
+SELECT t.name, t.abi, t.storage_layout FROM contract c JOIN template t ON t.name = c.template_name
+WHERE c.address = $1`, address).Scan(&template.TemplateName, &template.ABI, &template.StorageLayout)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// notFound converts a missing row into the database not found error
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return database.ErrNotFound
+	}
+	return err
+}
+
+// numeric formats a number for a NUMERIC column, since the SQL driver does not accept
+// unsigned integers that do not fit in a signed 64 bit integer
+func numeric(number uint64) string {
+	return strconv.FormatUint(number, 10)
+}
+
+// nullableAmount formats an optional amount for a nullable NUMERIC column
+func nullableAmount(amount *types.BigInt) sql.NullString {
+	if amount == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: amount.String(), Valid: true}
+}
+
+func parseNullableAmount(column sql.NullString) (*types.BigInt, error) {
+	if !column.Valid {
+		return nil, nil
+	}
+	return types.ParseBigInt(column.String)
+}
+
+func hashesToStrings(hashes []types.Hash) []string {
+	converted := make([]string, len(hashes))
+	for i, hash := range hashes {
+		converted[i] = string(hash)
+	}
+	return converted
+}
+
+func stringsToHashes(strings []string) []types.Hash {
+	converted := make([]types.Hash, len(strings))
+	for i, hash := range strings {
+		converted[i] = types.Hash(hash)
+	}
+	return converted
+}
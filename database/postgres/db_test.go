@@ -0,0 +1,327 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/database/conformance"
+	"quorumengineering/quorum-report/types"
+)
+
+var (
+	addr = types.NewAddress("0x0000000000000000000000000000000000000001")
+
+	tx = &types.Transaction{
+		Hash:            types.NewHash("0x5c83fa5955aff33c61813105851777bcd2adc85deb9af6286ba42c05cd768de0"),
+		BlockNumber:     1,
+		BlockHash:       types.NewHash("0x4b603921305ebaa48d863b9f577059a63c653cd8e952372622923708fb657806"),
+		Index:           0,
+		From:            types.NewAddress("0x0000000000000000000000000000000000000010"),
+		To:              addr,
+		CreatedContract: types.NewAddress(""),
+		Timestamp:       100,
+	}
+	block = &types.Block{
+		Hash:         types.NewHash("0x4b603921305ebaa48d863b9f577059a63c653cd8e952372622923708fb657806"),
+		ParentHash:   types.NewHash(""),
+		StateRoot:    types.NewHash(""),
+		TxRoot:       types.NewHash(""),
+		ReceiptRoot:  types.NewHash(""),
+		Number:       1,
+		Timestamp:    100,
+		Transactions: []types.Hash{tx.Hash},
+	}
+)
+
+// newTestDB creates a database in a new schema of the Postgres instance given by the
+// POSTGRES_TEST_URL environment variable, skipping the test if it is not set
+func newTestDB(t *testing.T) (*PostgresDB, func()) {
+	url := os.Getenv("POSTGRES_TEST_URL")
+	if url == "" {
+		t.Skip("POSTGRES_TEST_URL not set")
+	}
+
+	admin, err := sql.Open("postgres", url)
+	assert.Nil(t, err)
+	schema := fmt.Sprintf("reporting_test_%d", time.Now().UnixNano())
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	assert.Nil(t, err)
+
+	separator := "?"
+	if strings.Contains(url, "?") {
+		separator = "&"
+	}
+	db, err := New(url + separator + "search_path=" + schema)
+	assert.Nil(t, err)
+	return db, func() {
+		db.Stop()
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	}
+}
+
+// newMockDB creates a database on a mocked connection, which matches statements exactly
+// apart from whitespace
+func newMockDB(t *testing.T) (*PostgresDB, sqlmock.Sqlmock) {
+	conn, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.Nil(t, err)
+	return &PostgresDB{db: conn}, mock
+}
+
+// expectMigrationsFrom sets up the migration of a database at the given version, up to the
+// point where the missing migrations are applied
+func expectMigrationsFrom(mock sqlmock.Sqlmock, version int) {
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec(`LOCK TABLE schema_migrations IN EXCLUSIVE MODE`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(version))
+}
+
+func TestPostgresDB_NewWithDB_AppliesMigrations(t *testing.T) {
+	conn, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.Nil(t, err)
+
+	expectMigrationsFrom(mock, 0)
+	for i, migration := range migrations {
+		mock.ExpectExec(migration).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO schema_migrations (version) VALUES ($1)`).
+			WithArgs(i + 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	_, err = NewWithDB(conn)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_NewWithDB_OnlyAppliesMissingMigrations(t *testing.T) {
+	conn, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.Nil(t, err)
+
+	expectMigrationsFrom(mock, len(migrations)-1)
+	mock.ExpectExec(migrations[len(migrations)-1]).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO schema_migrations (version) VALUES ($1)`).
+		WithArgs(len(migrations)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, err = NewWithDB(conn)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_NewWithDB_UpToDate(t *testing.T) {
+	conn, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.Nil(t, err)
+
+	expectMigrationsFrom(mock, len(migrations))
+	mock.ExpectCommit()
+
+	_, err = NewWithDB(conn)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_NewWithDB_MigrationError(t *testing.T) {
+	conn, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.Nil(t, err)
+
+	expectMigrationsFrom(mock, 0)
+	mock.ExpectExec(migrations[0]).WillReturnError(errors.New("test error"))
+	mock.ExpectRollback()
+
+	_, err = NewWithDB(conn)
+
+	assert.EqualError(t, err, "test error")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_AddAddresses(t *testing.T) {
+	db, mock := newMockDB(t)
+	other := types.NewAddress("0x0000000000000000000000000000000000000002")
+
+	mock.ExpectBegin()
+	for _, address := range []types.Address{addr, other} {
+		mock.ExpectExec(`INSERT INTO contract (address, template_name, last_filtered) VALUES ($1, $2, $3) ON CONFLICT (address) DO NOTHING`).
+			WithArgs(address, address.String(), 0).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	err := db.AddAddresses([]types.Address{addr, other})
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_DeleteAddress_NotRegistered(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM contract WHERE address = $1`).
+		WithArgs(addr).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err := db.DeleteAddress(addr)
+
+	assert.EqualError(t, err, "address does not exist")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_GetContractTemplate_NotRegistered(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	mock.ExpectQuery(`SELECT template_name FROM contract WHERE address = $1`).
+		WithArgs(addr).
+		WillReturnRows(sqlmock.NewRows([]string{"template_name"}))
+
+	_, err := db.GetContractTemplate(addr)
+
+	assert.Equal(t, database.ErrNotFound, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_AssignTemplate_NotRegistered(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	mock.ExpectExec(`UPDATE contract SET template_name = $2 WHERE address = $1`).
+		WithArgs(addr, "SimpleStorage").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := db.AssignTemplate(addr, "SimpleStorage")
+
+	assert.Equal(t, database.ErrNotFound, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_ReadBlock(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	mock.ExpectQuery(`
+SELECT number, hash, parent_hash, state_root, tx_root, receipt_root, gas_limit, gas_used, timestamp, extra_data, transactions
+FROM block WHERE number = $1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"number", "hash", "parent_hash", "state_root", "tx_root", "receipt_root", "gas_limit", "gas_used", "timestamp", "extra_data", "transactions"}).
+			AddRow(1, string(block.Hash), string(block.ParentHash), string(block.StateRoot), string(block.TxRoot), string(block.ReceiptRoot),
+				"0", "0", 100, "", "{"+string(tx.Hash)+"}"))
+
+	retrievedBlock, err := db.ReadBlock(1)
+
+	assert.Nil(t, err)
+	assert.Equal(t, block, retrievedBlock)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_ReadBlock_NotFound(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	mock.ExpectQuery(`
+SELECT number, hash, parent_hash, state_root, tx_root, receipt_root, gas_limit, gas_used, timestamp, extra_data, transactions
+FROM block WHERE number = $1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"number"}))
+
+	_, err := db.ReadBlock(2)
+
+	assert.Equal(t, database.ErrNotFound, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_RollbackBlocks(t *testing.T) {
+	db, mock := newMockDB(t)
+	reorg := &types.ChainReorg{CommonAncestor: 9, NewHead: 11, NewHeadHash: block.Hash, DetectedAt: 1000}
+
+	mock.ExpectBegin()
+	// every table holding block data is rolled back
+	for _, statement := range []string{
+		`UPDATE contract SET creation_tx = '' WHERE creation_tx IN (SELECT hash FROM transaction WHERE block_number > $1)`,
+		`UPDATE contract SET last_filtered = $1 WHERE last_filtered > $1`,
+		`UPDATE meta SET value = $1 WHERE key = 'lastPersisted' AND value > $1`,
+		`DELETE FROM transaction WHERE block_number > $1`,
+		`DELETE FROM block WHERE number > $1`,
+		`DELETE FROM event WHERE block_number > $1`,
+		`DELETE FROM storage WHERE block_number > $1`,
+		`DELETE FROM proxy_implementation WHERE block_number > $1`,
+		`DELETE FROM native_transfer WHERE block_number > $1`,
+		`DELETE FROM native_balance WHERE block_number > $1`,
+		`DELETE FROM transaction_failure WHERE block_number > $1`,
+	} {
+		mock.ExpectExec(statement).WithArgs(9).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	// the token records are removed from every block after the ancestor
+	for _, statement := range tokenRollbackStatements("") {
+		mock.ExpectExec(statement).WithArgs(10, math.MaxInt64).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec(`INSERT INTO chain_reorg (common_ancestor, new_head, new_head_hash, detected_at) VALUES ($1, $2, $3, $4)`).
+		WithArgs(9, 11, block.Hash, 1000).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := db.RollbackBlocks(reorg)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_RollbackBlocks_Error(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE contract SET creation_tx = '' WHERE creation_tx IN (SELECT hash FROM transaction WHERE block_number > $1)`).
+		WithArgs(9).
+		WillReturnError(errors.New("test error"))
+	mock.ExpectRollback()
+
+	err := db.RollbackBlocks(&types.ChainReorg{CommonAncestor: 9, NewHead: 11})
+
+	assert.EqualError(t, err, "test error")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_Conformance(t *testing.T) {
+	conformance.RunTests(t, func(t *testing.T) (database.Database, func()) {
+		return newTestDB(t)
+	})
+}
+
+func TestPostgresDB_MigrationsAppliedOnce(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+	assert.Nil(t, db.AddAddresses([]types.Address{addr}))
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx}))
+	assert.Nil(t, db.WriteBlocks([]*types.Block{block}))
+
+	// re-running the migrations keeps existing data
+	assert.Nil(t, migrate(db.db))
+
+	var version int
+	assert.Nil(t, db.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version))
+	assert.Equal(t, len(migrations), version)
+
+	lastPersisted, err := db.GetLastPersistedBlockNumber()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, lastPersisted)
+	addresses, err := db.GetAddresses()
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{addr}, addresses)
+	retrievedTx, err := db.ReadTransaction(tx.Hash)
+	assert.Nil(t, err)
+	assert.Equal(t, tx, retrievedTx)
+}
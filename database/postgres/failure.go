@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// FailureDB
+func (pg *PostgresDB) RecordTransactionFailures(failures []types.TransactionFailure) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		for _, failure := range failures {
+			_, err := tx.Exec(`
+INSERT INTO transaction_failure (address, block_number, tx_index, transaction_hash, timestamp, error, error_sig, reason, revert_data)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (address, block_number, tx_index) DO UPDATE SET transaction_hash = EXCLUDED.transaction_hash,
+	timestamp = EXCLUDED.timestamp, error = EXCLUDED.error, error_sig = EXCLUDED.error_sig, reason = EXCLUDED.reason,
+	revert_data = EXCLUDED.revert_data`,
+				failure.Address, failure.BlockNumber, failure.Index, failure.TransactionHash, failure.Timestamp,
+				failure.Error, failure.ErrorSig, failure.Reason, failure.RevertData)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (pg *PostgresDB) GetTransactionFailures(address types.Address, reason string, options *types.PageOptions) ([]types.TransactionFailure, error) {
+	beginBlock, endBlock := rangeBounds(options.BeginBlockNumber, options.EndBlockNumber)
+	rows, err := pg.db.Query(`
+SELECT address, block_number, tx_index, transaction_hash, timestamp, error, error_sig, reason, revert_data
+FROM transaction_failure
+WHERE address = $1 AND ($2::TEXT = '' OR reason = $2) AND block_number BETWEEN $3 AND $4
+ORDER BY block_number DESC, tx_index DESC LIMIT $5 OFFSET $6`,
+		address, reason, beginBlock, endBlock, options.PageSize, options.PageSize*options.PageNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	failures := make([]types.TransactionFailure, 0)
+	for rows.Next() {
+		var failure types.TransactionFailure
+		if err := rows.Scan(&failure.Address, &failure.BlockNumber, &failure.Index, &failure.TransactionHash,
+			&failure.Timestamp, &failure.Error, &failure.ErrorSig, &failure.Reason, &failure.RevertData); err != nil {
+			return nil, err
+		}
+		failures = append(failures, failure)
+	}
+	return failures, rows.Err()
+}
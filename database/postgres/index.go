@@ -0,0 +1,379 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"math/big"
+
+	"github.com/lib/pq"
+
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// IndexDB
+func (pg *PostgresDB) IndexBlocks(addresses []types.Address, blocks []*types.BlockWithTransactions) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(`
+INSERT INTO event (address, block_number, tx_index, log_index, block_hash, tx_hash, topics, data, timestamp)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (address, block_number, tx_index, log_index) DO UPDATE SET
+	block_hash = EXCLUDED.block_hash, tx_hash = EXCLUDED.tx_hash, topics = EXCLUDED.topics,
+	data = EXCLUDED.data, timestamp = EXCLUDED.timestamp`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, address := range addresses {
+			var lastFiltered uint64
+			err := tx.QueryRow(`SELECT last_filtered FROM contract WHERE address = $1 FOR UPDATE`, address).Scan(&lastFiltered)
+			if err == sql.ErrNoRows {
+				// tried to index a deleted address, do nothing
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			newLastFiltered := lastFiltered
+			for _, block := range blocks {
+				// skip blocks that have been filtered for this address already
+				if block.Number <= lastFiltered {
+					continue
+				}
+				for _, transaction := range block.Transactions {
+					for _, event := range transaction.Events {
+						if event.Address != address {
+							continue
+						}
+						_, err := stmt.Exec(event.Address, event.BlockNumber, event.TransactionIndex, event.Index, event.BlockHash,
+							event.TransactionHash, pq.Array(hashesToStrings(event.Topics)), event.Data, event.Timestamp)
+						if err != nil {
+							return err
+						}
+						log.Debug("Indexed emitted event", "tx", event.TransactionHash.Hex(), "address", event.Address.Hex())
+					}
+				}
+				newLastFiltered = block.Number
+			}
+			if _, err := tx.Exec(`UPDATE contract SET last_filtered = $2 WHERE address = $1`, address, newLastFiltered); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (pg *PostgresDB) IndexStorage(rawStorage map[types.Address]*types.AccountState, blockNumber uint64) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		for address, dumpAccount := range rawStorage {
+			storage, err := json.Marshal(dumpAccount.Storage)
+			if err != nil {
+				return err
+			}
+			// storage is shared between all blocks with the same storage root
+			_, err = tx.Exec(`INSERT INTO storage_root (root, storage) VALUES ($1, $2) ON CONFLICT (root) DO NOTHING`,
+				dumpAccount.Root, string(storage))
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(`
+INSERT INTO storage (address, block_number, root) VALUES ($1, $2, $3)
+ON CONFLICT (address, block_number) DO UPDATE SET root = EXCLUDED.root`, address, blockNumber, dumpAccount.Root)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (pg *PostgresDB) SetContractCreationTransaction(creationTxns map[types.Hash][]types.Address) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		for txHash, addresses := range creationTxns {
+			for _, createdAddress := range addresses {
+				result, err := tx.Exec(`UPDATE contract SET creation_tx = $2 WHERE address = $1`, createdAddress, txHash)
+				if err != nil {
+					return err
+				}
+				updated, err := result.RowsAffected()
+				if err != nil {
+					return err
+				}
+				if updated == 0 {
+					// tried to index a deleted address, do nothing
+					log.Debug("Ignored deleted address contract creation", "tx", txHash.Hex(), "contract", createdAddress)
+					continue
+				}
+				log.Debug("Indexed address of contract creation", "tx", txHash.Hex(), "contract", createdAddress)
+			}
+		}
+		return nil
+	})
+}
+
+func (pg *PostgresDB) GetContractCreationTransaction(address types.Address) (types.Hash, error) {
+	var creationTx types.Hash
+	err := pg.db.QueryRow(`SELECT creation_tx FROM contract WHERE address = $1`, address).Scan(&creationTx)
+	return creationTx, notFound(err)
+}
+
+func (pg *PostgresDB) GetAllTransactionsToAddress(address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
+	return pg.getTransactionHashes(`to_address = $1`, address, options)
+}
+
+func (pg *PostgresDB) GetTransactionsToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+	return pg.countTransactions(`to_address = $1`, address, options)
+}
+
+func (pg *PostgresDB) GetAllTransactionsInternalToAddress(address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
+	call, err := internalCallTo(address)
+	if err != nil {
+		return nil, err
+	}
+	return pg.getTransactionHashes(`internal_calls @> $1::jsonb`, call, options)
+}
+
+func (pg *PostgresDB) GetTransactionsInternalToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+	call, err := internalCallTo(address)
+	if err != nil {
+		return 0, err
+	}
+	return pg.countTransactions(`internal_calls @> $1::jsonb`, call, options)
+}
+
+func (pg *PostgresDB) GetAllEventsFromAddress(address types.Address, options *types.QueryOptions) ([]*types.Event, error) {
+	beginBlock, endBlock := rangeBounds(options.BeginBlockNumber, options.EndBlockNumber)
+	beginTime, endTime := rangeBounds(options.BeginTimestamp, options.EndTimestamp)
+	rows, err := pg.db.Query(`
+SELECT address, block_number, tx_index, log_index, block_hash, tx_hash, topics, data, timestamp FROM event
+WHERE address = $1 AND block_number BETWEEN $2 AND $3 AND timestamp BETWEEN $4 AND $5
+ORDER BY block_number DESC, tx_index, log_index LIMIT $6 OFFSET $7`,
+		address, beginBlock, endBlock, beginTime, endTime, options.PageSize, options.PageSize*options.PageNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]*types.Event, 0)
+	for rows.Next() {
+		var event types.Event
+		var topics []string
+		err := rows.Scan(&event.Address, &event.BlockNumber, &event.TransactionIndex, &event.Index, &event.BlockHash,
+			&event.TransactionHash, pq.Array(&topics), &event.Data, &event.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		event.Topics = stringsToHashes(topics)
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+func (pg *PostgresDB) GetEventsFromAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+	beginBlock, endBlock := rangeBounds(options.BeginBlockNumber, options.EndBlockNumber)
+	beginTime, endTime := rangeBounds(options.BeginTimestamp, options.EndTimestamp)
+	var total uint64
+	err := pg.db.QueryRow(`
+SELECT COUNT(*) FROM event WHERE address = $1 AND block_number BETWEEN $2 AND $3 AND timestamp BETWEEN $4 AND $5`,
+		address, beginBlock, endBlock, beginTime, endTime).Scan(&total)
+	return total, err
+}
+
+func (pg *PostgresDB) GetStorage(address types.Address, blockNumber uint64) (*types.StorageResult, error) {
+	result := &types.StorageResult{
+		Storage:     make(map[types.Hash]string),
+		StorageRoot: types.NewHash(""),
+		BlockNumber: blockNumber,
+	}
+
+	// find the latest storage at or before the given block
+	var root types.Hash
+	var storage []byte
+	err := pg.db.QueryRow(`
+SELECT s.root, r.storage FROM storage s JOIN storage_root r ON r.root = s.root
+WHERE s.address = $1 AND s.block_number <= $2 ORDER BY s.block_number DESC LIMIT 1`, address, blockNumber).Scan(&root, &storage)
+	if err == sql.ErrNoRows {
+		// there are no results, probably trying to query before the contract exists
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.StorageRoot = root
+	if err := json.Unmarshal(storage, &result.Storage); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (pg *PostgresDB) GetStorageTotal(address types.Address, options *types.PageOptions) (uint64, error) {
+	beginBlock, endBlock := rangeBounds(options.BeginBlockNumber, options.EndBlockNumber)
+	var total uint64
+	err := pg.db.QueryRow(`SELECT COUNT(*) FROM storage WHERE address = $1 AND block_number BETWEEN $2 AND $3`,
+		address, beginBlock, endBlock).Scan(&total)
+	return total, err
+}
+
+func (pg *PostgresDB) GetStorageWithOptions(address types.Address, options *types.PageOptions) ([]*types.StorageResult, error) {
+	beginBlock, endBlock := rangeBounds(options.BeginBlockNumber, options.EndBlockNumber)
+	rows, err := pg.db.Query(`
+SELECT s.block_number, s.root, r.storage FROM storage s JOIN storage_root r ON r.root = s.root
+WHERE s.address = $1 AND s.block_number BETWEEN $2 AND $3
+ORDER BY s.block_number DESC LIMIT $4 OFFSET $5`,
+		address, beginBlock, endBlock, options.PageSize, options.PageSize*options.PageNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*types.StorageResult
+	for rows.Next() {
+		var result types.StorageResult
+		var storage []byte
+		if err := rows.Scan(&result.BlockNumber, &result.StorageRoot, &storage); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(storage, &result.Storage); err != nil {
+			return nil, err
+		}
+		results = append(results, &result)
+	}
+	return results, rows.Err()
+}
+
+func (pg *PostgresDB) GetStorageRanges(contract types.Address, options *types.PageOptions) ([]types.RangeResult, error) {
+	end := options.EndBlockNumber
+	if big.NewInt(-1).Cmp(end) == 0 {
+		endUint64, err := pg.GetLastFiltered(contract)
+		if err != nil {
+			return nil, err
+		}
+		end = new(big.Int).SetUint64(endUint64)
+	}
+
+	startUint64 := options.BeginBlockNumber.Uint64()
+	endUint64 := end.Uint64()
+
+	rows, err := pg.db.Query(`
+SELECT block_number FROM storage WHERE address = $1 AND block_number BETWEEN $2 AND $3 ORDER BY block_number DESC`,
+		contract, startUint64, endUint64)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blockNumbers []uint64
+	for rows.Next() {
+		var blockNumber uint64
+		if err := rows.Scan(&blockNumber); err != nil {
+			return nil, err
+		}
+		blockNumbers = append(blockNumbers, blockNumber)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// split the results into ranges of at most 1000 results, starting from the most recent block
+	var ranges []types.RangeResult
+	for len(blockNumbers) > 1000 {
+		foundEndBlockNumber := blockNumbers[999]
+		ranges = append(ranges, types.RangeResult{
+			Start:       foundEndBlockNumber,
+			End:         endUint64,
+			ResultCount: 1000,
+		})
+		endUint64 = foundEndBlockNumber - 1
+		blockNumbers = blockNumbers[1000:]
+	}
+	ranges = append(ranges, types.RangeResult{
+		Start:       startUint64,
+		End:         endUint64,
+		ResultCount: len(blockNumbers),
+	})
+	return ranges, nil
+}
+
+func (pg *PostgresDB) GetLastFiltered(address types.Address) (uint64, error) {
+	var lastFiltered uint64
+	err := pg.db.QueryRow(`SELECT last_filtered FROM contract WHERE address = $1`, address).Scan(&lastFiltered)
+	return lastFiltered, notFound(err)
+}
+
+// Internal functions
+
+// getTransactionHashes returns a page of the transactions matching the given condition on
+// the first query parameter, sorted by block number descending and transaction index ascending
+func (pg *PostgresDB) getTransactionHashes(condition string, param interface{}, options *types.QueryOptions) ([]types.Hash, error) {
+	beginBlock, endBlock := rangeBounds(options.BeginBlockNumber, options.EndBlockNumber)
+	beginTime, endTime := rangeBounds(options.BeginTimestamp, options.EndTimestamp)
+	beginValue, endValue := valueBounds(options.BeginValue, options.EndValue)
+	rows, err := pg.db.Query(`
+SELECT hash FROM transaction
+WHERE `+condition+` AND block_number BETWEEN $2 AND $3 AND timestamp BETWEEN $4 AND $5
+	AND value >= $6::NUMERIC AND ($7::NUMERIC IS NULL OR value <= $7::NUMERIC)
+ORDER BY block_number DESC, tx_index LIMIT $8 OFFSET $9`,
+		param, beginBlock, endBlock, beginTime, endTime, beginValue, endValue, options.PageSize, options.PageSize*options.PageNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := make([]types.Hash, 0)
+	for rows.Next() {
+		var hash types.Hash
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+func (pg *PostgresDB) countTransactions(condition string, param interface{}, options *types.QueryOptions) (uint64, error) {
+	beginBlock, endBlock := rangeBounds(options.BeginBlockNumber, options.EndBlockNumber)
+	beginTime, endTime := rangeBounds(options.BeginTimestamp, options.EndTimestamp)
+	beginValue, endValue := valueBounds(options.BeginValue, options.EndValue)
+	var total uint64
+	err := pg.db.QueryRow(`
+SELECT COUNT(*) FROM transaction WHERE `+condition+` AND block_number BETWEEN $2 AND $3 AND timestamp BETWEEN $4 AND $5
+	AND value >= $6::NUMERIC AND ($7::NUMERIC IS NULL OR value <= $7::NUMERIC)`,
+		param, beginBlock, endBlock, beginTime, endTime, beginValue, endValue).Scan(&total)
+	return total, err
+}
+
+// internalCallTo builds a JSON containment filter matching transactions with an internal
+// call to the given address
+func internalCallTo(address types.Address) (string, error) {
+	filter, err := json.Marshal([]map[string]types.Address{{"to": address}})
+	return string(filter), err
+}
+
+// rangeBounds converts an inclusive range, where an end of -1 is unbounded, to bounds
+// usable in a BETWEEN clause
+func rangeBounds(begin *big.Int, end *big.Int) (int64, int64) {
+	var beginInt64 int64
+	if begin != nil {
+		beginInt64 = begin.Int64()
+	}
+	endInt64 := int64(math.MaxInt64)
+	if end != nil && end.Cmp(big.NewInt(-1)) != 0 {
+		endInt64 = end.Int64()
+	}
+	return beginInt64, endInt64
+}
+
+// valueBounds converts an inclusive range of wei values, where an end of -1 is unbounded, to
+// NUMERIC parameters, with an unbounded end given as NULL
+func valueBounds(begin *types.BigInt, end *types.BigInt) (string, sql.NullString) {
+	beginValue := begin.String()
+	var endValue sql.NullString
+	if end != nil && end.Cmp(big.NewInt(-1)) != 0 {
+		endValue = sql.NullString{String: end.String(), Valid: true}
+	}
+	return beginValue, endValue
+}
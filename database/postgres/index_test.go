@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/types"
+)
+
+func TestPostgresDB_GetAllTransactionsToAddress(t *testing.T) {
+	db, mock := newMockDB(t)
+	options := &types.QueryOptions{
+		BeginBlockNumber: big.NewInt(5),
+		EndBlockNumber:   big.NewInt(-1),
+		BeginValue:       types.NewBigInt(big.NewInt(10)),
+		EndValue:         types.NewBigInt(big.NewInt(20)),
+		PageSize:         10,
+		PageNumber:       2,
+	}
+	options.SetDefaults()
+
+	mock.ExpectQuery(`
+SELECT hash FROM transaction
+WHERE to_address = $1 AND block_number BETWEEN $2 AND $3 AND timestamp BETWEEN $4 AND $5
+	AND value >= $6::NUMERIC AND ($7::NUMERIC IS NULL OR value <= $7::NUMERIC)
+ORDER BY block_number DESC, tx_index LIMIT $8 OFFSET $9`).
+		WithArgs(addr, 5, math.MaxInt64, 0, math.MaxInt64, "10", "20", 10, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow(string(tx.Hash)))
+
+	txs, err := db.GetAllTransactionsToAddress(addr, options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{tx.Hash}, txs)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_GetAllTransactionsInternalToAddress(t *testing.T) {
+	db, mock := newMockDB(t)
+	options := &types.QueryOptions{}
+	options.SetDefaults()
+
+	// unbounded ranges are given as the largest values, or NULL for values
+	mock.ExpectQuery(`
+SELECT hash FROM transaction
+WHERE internal_calls @> $1::jsonb AND block_number BETWEEN $2 AND $3 AND timestamp BETWEEN $4 AND $5
+	AND value >= $6::NUMERIC AND ($7::NUMERIC IS NULL OR value <= $7::NUMERIC)
+ORDER BY block_number DESC, tx_index LIMIT $8 OFFSET $9`).
+		WithArgs(`[{"to":"`+addr.String()+`"}]`, 0, math.MaxInt64, 0, math.MaxInt64, "0", nil, options.PageSize, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+
+	txs, err := db.GetAllTransactionsInternalToAddress(addr, options)
+
+	assert.Nil(t, err)
+	assert.Empty(t, txs)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_GetLastFiltered_NotRegistered(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	mock.ExpectQuery(`SELECT last_filtered FROM contract WHERE address = $1`).
+		WithArgs(addr).
+		WillReturnRows(sqlmock.NewRows([]string{"last_filtered"}))
+
+	_, err := db.GetLastFiltered(addr)
+
+	assert.Equal(t, database.ErrNotFound, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
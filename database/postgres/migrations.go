@@ -0,0 +1,330 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"quorumengineering/quorum-report/log"
+)
+
+// migrations are applied in order, each exactly once. Existing migrations must never be
+// changed; schema changes are made by appending a new migration.
+var migrations = []string{
+	// 1: initial schema
+	`
+CREATE TABLE meta (
+	key   TEXT PRIMARY KEY,
+	value BIGINT NOT NULL
+);
+INSERT INTO meta (key, value) VALUES ('lastPersisted', 0);
+
+CREATE TABLE template (
+	name           TEXT PRIMARY KEY,
+	abi            TEXT NOT NULL,
+	storage_layout TEXT NOT NULL
+);
+
+CREATE TABLE contract (
+	address       TEXT PRIMARY KEY,
+	template_name TEXT NOT NULL,
+	creation_tx   TEXT NOT NULL DEFAULT '',
+	last_filtered BIGINT NOT NULL
+);
+
+CREATE TABLE block (
+	number       BIGINT PRIMARY KEY,
+	hash         TEXT NOT NULL,
+	parent_hash  TEXT NOT NULL,
+	state_root   TEXT NOT NULL,
+	tx_root      TEXT NOT NULL,
+	receipt_root TEXT NOT NULL,
+	gas_limit    NUMERIC(20) NOT NULL,
+	gas_used     NUMERIC(20) NOT NULL,
+	timestamp    BIGINT NOT NULL,
+	extra_data   TEXT NOT NULL,
+	transactions TEXT[] NOT NULL
+);
+
+CREATE TABLE transaction (
+	hash                TEXT PRIMARY KEY,
+	status              BOOLEAN NOT NULL,
+	block_number        BIGINT NOT NULL,
+	block_hash          TEXT NOT NULL,
+	tx_index            BIGINT NOT NULL,
+	nonce               NUMERIC(20) NOT NULL,
+	from_address        TEXT NOT NULL,
+	to_address          TEXT NOT NULL,
+	value               NUMERIC(20) NOT NULL,
+	gas                 NUMERIC(20) NOT NULL,
+	gas_price           NUMERIC(20) NOT NULL,
+	gas_used            NUMERIC(20) NOT NULL,
+	cumulative_gas_used NUMERIC(20) NOT NULL,
+	created_contract    TEXT NOT NULL,
+	data                TEXT NOT NULL,
+	private_data        TEXT NOT NULL,
+	is_private          BOOLEAN NOT NULL,
+	timestamp           BIGINT NOT NULL,
+	events              JSONB NOT NULL,
+	internal_calls      JSONB NOT NULL
+);
+CREATE INDEX transaction_block_number_idx ON transaction (block_number);
+CREATE INDEX transaction_to_address_idx ON transaction (to_address, block_number DESC, tx_index);
+CREATE INDEX transaction_internal_calls_idx ON transaction USING GIN (internal_calls jsonb_path_ops);
+
+CREATE TABLE event (
+	address      TEXT NOT NULL,
+	block_number BIGINT NOT NULL,
+	tx_index     BIGINT NOT NULL,
+	log_index    BIGINT NOT NULL,
+	block_hash   TEXT NOT NULL,
+	tx_hash      TEXT NOT NULL,
+	topics       TEXT[] NOT NULL,
+	data         TEXT NOT NULL,
+	timestamp    BIGINT NOT NULL,
+	PRIMARY KEY (address, block_number, tx_index, log_index)
+);
+CREATE INDEX event_block_number_idx ON event (block_number);
+
+CREATE TABLE storage_root (
+	root    TEXT PRIMARY KEY,
+	storage JSONB NOT NULL
+);
+
+CREATE TABLE storage (
+	address      TEXT NOT NULL,
+	block_number BIGINT NOT NULL,
+	root         TEXT NOT NULL,
+	PRIMARY KEY (address, block_number)
+);
+CREATE INDEX storage_block_number_idx ON storage (block_number);
+
+CREATE TABLE erc20_balance (
+	contract     TEXT NOT NULL,
+	holder       TEXT NOT NULL,
+	block_number BIGINT NOT NULL,
+	amount       NUMERIC(78) NOT NULL,
+	held_until   BIGINT,
+	PRIMARY KEY (contract, holder, block_number)
+);
+CREATE INDEX erc20_balance_block_number_idx ON erc20_balance (block_number);
+
+CREATE TABLE erc721_token (
+	contract  TEXT NOT NULL,
+	token_id  NUMERIC(78) NOT NULL,
+	holder    TEXT NOT NULL,
+	held_from BIGINT NOT NULL,
+	held_until BIGINT,
+	PRIMARY KEY (contract, token_id, held_from)
+);
+CREATE INDEX erc721_token_holder_idx ON erc721_token (contract, holder);
+CREATE INDEX erc721_token_held_from_idx ON erc721_token (held_from);
+
+CREATE TABLE chain_reorg (
+	id              BIGSERIAL PRIMARY KEY,
+	common_ancestor BIGINT NOT NULL,
+	new_head        BIGINT NOT NULL,
+	new_head_hash   TEXT NOT NULL,
+	detected_at     BIGINT NOT NULL
+);
+`,
+	// 2: proxy implementations
+	`
+CREATE TABLE proxy_implementation (
+	proxy          TEXT NOT NULL,
+	block_number   BIGINT NOT NULL,
+	implementation TEXT NOT NULL,
+	standard       TEXT NOT NULL,
+	PRIMARY KEY (proxy, block_number)
+);
+CREATE INDEX proxy_implementation_block_number_idx ON proxy_implementation (block_number);
+`,
+	// 3: ERC1155 balances
+	`
+CREATE TABLE erc1155_balance (
+	contract   TEXT NOT NULL,
+	holder     TEXT NOT NULL,
+	token_id   NUMERIC(78) NOT NULL,
+	held_from  BIGINT NOT NULL,
+	amount     NUMERIC(78) NOT NULL,
+	held_until BIGINT,
+	PRIMARY KEY (contract, holder, token_id, held_from)
+);
+CREATE INDEX erc1155_balance_token_idx ON erc1155_balance (contract, token_id);
+CREATE INDEX erc1155_balance_held_from_idx ON erc1155_balance (held_from);
+`,
+	// 4: token transfers
+	`
+CREATE TABLE token_transfer (
+	contract         TEXT NOT NULL,
+	block_number     BIGINT NOT NULL,
+	log_index        BIGINT NOT NULL,
+	from_address     TEXT NOT NULL,
+	to_address       TEXT NOT NULL,
+	token_id         NUMERIC(78),
+	transaction_hash TEXT NOT NULL,
+	timestamp        BIGINT NOT NULL,
+	PRIMARY KEY (contract, block_number, log_index)
+);
+CREATE INDEX token_transfer_token_idx ON token_transfer (contract, token_id);
+CREATE INDEX token_transfer_block_number_idx ON token_transfer (block_number);
+`,
+	// 5: ERC20 token transfers
+	`
+ALTER TABLE token_transfer ADD COLUMN amount NUMERIC(78);
+CREATE INDEX token_transfer_from_address_idx ON token_transfer (from_address, block_number, log_index);
+CREATE INDEX token_transfer_to_address_idx ON token_transfer (to_address, block_number, log_index);
+`,
+	// 6: ERC20 allowances
+	`
+CREATE TABLE erc20_allowance (
+	contract       TEXT NOT NULL,
+	owner          TEXT NOT NULL,
+	spender        TEXT NOT NULL,
+	approved_from  BIGINT NOT NULL,
+	amount         NUMERIC(78) NOT NULL,
+	approved_until BIGINT,
+	PRIMARY KEY (contract, owner, spender, approved_from)
+);
+CREATE INDEX erc20_allowance_approved_from_idx ON erc20_allowance (approved_from);
+`,
+	// 7: token metadata and total supply
+	`
+CREATE TABLE token_info (
+	contract TEXT PRIMARY KEY,
+	standard TEXT NOT NULL,
+	name     TEXT NOT NULL,
+	symbol   TEXT NOT NULL,
+	decimals SMALLINT
+);
+
+CREATE TABLE total_supply (
+	contract     TEXT NOT NULL,
+	block_number BIGINT NOT NULL,
+	total_supply NUMERIC(78) NOT NULL,
+	supply_until BIGINT,
+	PRIMARY KEY (contract, block_number)
+);
+CREATE INDEX total_supply_block_number_idx ON total_supply (block_number);
+`,
+	// 8: balances of the configurable token standards
+	`
+CREATE TABLE token_balance (
+	standard   TEXT NOT NULL,
+	contract   TEXT NOT NULL,
+	holder     TEXT NOT NULL,
+	held_from  BIGINT NOT NULL,
+	amount     NUMERIC(78) NOT NULL,
+	held_until BIGINT,
+	PRIMARY KEY (contract, standard, holder, held_from)
+);
+CREATE INDEX token_balance_held_from_idx ON token_balance (held_from);
+`,
+	// 9: look up the tokens of a holder across all contracts
+	`
+CREATE INDEX erc20_balance_holder_idx ON erc20_balance (holder);
+CREATE INDEX erc721_token_holder_idx ON erc721_token (holder);
+`,
+	// 10: progress of the token backfill jobs
+	`
+CREATE TABLE backfill_job (
+	contract   TEXT PRIMARY KEY,
+	from_block BIGINT NOT NULL,
+	to_block   BIGINT NOT NULL,
+	next_block BIGINT NOT NULL,
+	status     TEXT NOT NULL
+);
+`,
+	// 11: native currency transfers and balances
+	`
+CREATE TABLE native_transfer (
+	block_number     BIGINT NOT NULL,
+	idx              BIGINT NOT NULL,
+	from_address     TEXT NOT NULL,
+	to_address       TEXT NOT NULL,
+	value            NUMERIC(78) NOT NULL,
+	type             TEXT NOT NULL,
+	transaction_hash TEXT NOT NULL,
+	timestamp        BIGINT NOT NULL,
+	PRIMARY KEY (block_number, idx)
+);
+CREATE INDEX native_transfer_from_address_idx ON native_transfer (from_address, block_number, idx);
+CREATE INDEX native_transfer_to_address_idx ON native_transfer (to_address, block_number, idx);
+
+CREATE TABLE native_balance (
+	address      TEXT NOT NULL,
+	block_number BIGINT NOT NULL,
+	balance      NUMERIC(78) NOT NULL,
+	PRIMARY KEY (address, block_number)
+);
+CREATE INDEX native_balance_block_number_idx ON native_balance (block_number);
+`,
+	// 12: arbitrary-precision transaction amounts. Internal call values already stored as
+	// JSON numbers are still readable, so internal_calls is left as is.
+	`
+ALTER TABLE transaction ALTER COLUMN value TYPE NUMERIC(78), ALTER COLUMN gas_price TYPE NUMERIC(78);
+`,
+	// 13: typed transaction fields, which are unset for transactions stored before them
+	`
+ALTER TABLE transaction
+	ADD COLUMN tx_type                  BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN chain_id                 NUMERIC(78),
+	ADD COLUMN access_list              JSONB NOT NULL DEFAULT 'null',
+	ADD COLUMN max_fee_per_gas          NUMERIC(78),
+	ADD COLUMN max_priority_fee_per_gas NUMERIC(78),
+	ADD COLUMN effective_gas_price      NUMERIC(78);
+`,
+	// 14: revert data of failed transactions, and the decoded failures of those sent to
+	// registered addresses
+	`
+ALTER TABLE transaction
+	ADD COLUMN error       TEXT NOT NULL DEFAULT '',
+	ADD COLUMN revert_data TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE transaction_failure (
+	address          TEXT NOT NULL,
+	block_number     BIGINT NOT NULL,
+	tx_index         BIGINT NOT NULL,
+	transaction_hash TEXT NOT NULL,
+	timestamp        BIGINT NOT NULL,
+	error            TEXT NOT NULL,
+	error_sig        TEXT NOT NULL,
+	reason           TEXT NOT NULL,
+	revert_data      TEXT NOT NULL,
+	PRIMARY KEY (address, block_number, tx_index)
+);
+CREATE INDEX transaction_failure_reason_idx ON transaction_failure (address, reason, block_number, tx_index);
+CREATE INDEX transaction_failure_block_number_idx ON transaction_failure (block_number);
+`,
+}
+
+// migrate brings the schema up to date, applying all migrations that have not yet been
+// applied in a single transaction. The migrations table is locked, so that multiple
+// instances starting at the same time do not apply the same migration twice.
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`LOCK TABLE schema_migrations IN EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+	var version int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+	for ; version < len(migrations); version++ {
+		log.Info("Applying database migration", "version", version+1)
+		if _, err := tx.Exec(migrations[version]); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, version+1); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
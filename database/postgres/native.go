@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"math/big"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// NativeDB
+func (pg *PostgresDB) RecordNativeTransfers(transfers []types.NativeTransfer) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		for _, transfer := range transfers {
+			_, err := tx.Exec(`
+INSERT INTO native_transfer (block_number, idx, from_address, to_address, value, type, transaction_hash, timestamp)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (block_number, idx) DO UPDATE SET from_address = EXCLUDED.from_address, to_address = EXCLUDED.to_address,
+	value = EXCLUDED.value, type = EXCLUDED.type, transaction_hash = EXCLUDED.transaction_hash, timestamp = EXCLUDED.timestamp`,
+				transfer.BlockNumber, transfer.Index, transfer.From, transfer.To, transfer.Value, transfer.Type,
+				transfer.TransactionHash, transfer.Timestamp)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (pg *PostgresDB) GetNativeTransfers(address types.Address, direction string, options *types.TokenTransferQueryOptions) ([]types.NativeTransfer, error) {
+	afterBlock, afterIndex, hasCursor, err := options.Cursor()
+	if err != nil {
+		return nil, err
+	}
+	beginBlock, endBlock := rangeBounds(options.BeginBlockNumber, options.EndBlockNumber)
+	beginTime, endTime := rangeBounds(options.BeginTimestamp, options.EndTimestamp)
+
+	// without a cursor, start before the first transfer of the first block
+	afterBlockFilter, afterIndexFilter := int64(-1), int64(-1)
+	if hasCursor {
+		afterBlockFilter, afterIndexFilter = int64(afterBlock), int64(afterIndex)
+	}
+
+	rows, err := pg.db.Query(`
+SELECT block_number, idx, from_address, to_address, value, type, transaction_hash, timestamp
+FROM native_transfer
+WHERE (($2::TEXT <> 'out' AND to_address = $1) OR ($2::TEXT <> 'in' AND from_address = $1))
+	AND block_number BETWEEN $3 AND $4 AND timestamp BETWEEN $5 AND $6
+	AND (block_number, idx) > ($7, $8)
+ORDER BY block_number, idx LIMIT $9`,
+		address, direction, beginBlock, endBlock, beginTime, endTime, afterBlockFilter, afterIndexFilter, options.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := make([]types.NativeTransfer, 0)
+	for rows.Next() {
+		var transfer types.NativeTransfer
+		if err := rows.Scan(&transfer.BlockNumber, &transfer.Index, &transfer.From, &transfer.To, &transfer.Value,
+			&transfer.Type, &transfer.TransactionHash, &transfer.Timestamp); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+	return transfers, rows.Err()
+}
+
+func (pg *PostgresDB) RecordNativeBalance(address types.Address, block uint64, balance *big.Int) error {
+	_, err := pg.db.Exec(`
+INSERT INTO native_balance (address, block_number, balance) VALUES ($1, $2, $3)
+ON CONFLICT (address, block_number) DO UPDATE SET balance = EXCLUDED.balance`,
+		address, block, balance.String())
+	return err
+}
+
+func (pg *PostgresDB) NativeBalanceAtBlock(address types.Address, block uint64) (*big.Int, error) {
+	var balance string
+	err := pg.db.QueryRow(`
+SELECT balance FROM native_balance WHERE address = $1 AND block_number <= $2
+ORDER BY block_number DESC LIMIT 1`, address, block).Scan(&balance)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	parsed, success := new(big.Int).SetString(balance, 10)
+	if !success {
+		return nil, errors.New("could not parse native balance")
+	}
+	return parsed, nil
+}
+
+func (pg *PostgresDB) GetNativeBalance(address types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	beginBlock, endBlock := rangeBounds(options.BeginBlockNumber, options.EndBlockNumber)
+
+	// get all the balances in the block range, as well as the last balance before the
+	// starting block if there was no balance recorded on the starting block
+	rows, err := pg.db.Query(`
+SELECT block_number, balance FROM native_balance
+WHERE address = $1 AND (
+	block_number BETWEEN $2 AND $3 OR (
+		block_number = (SELECT MAX(block_number) FROM native_balance WHERE address = $1 AND block_number < $2)
+		AND NOT EXISTS (SELECT 1 FROM native_balance WHERE address = $1 AND block_number = $2)
+	)
+)
+ORDER BY block_number DESC LIMIT $4 OFFSET $5`,
+		address, beginBlock, endBlock, options.PageSize, options.PageSize*options.PageNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balanceMap := make(map[uint64]*big.Int)
+	for rows.Next() {
+		var blockNumber uint64
+		var balance string
+		if err := rows.Scan(&blockNumber, &balance); err != nil {
+			return nil, err
+		}
+		parsed, success := new(big.Int).SetString(balance, 10)
+		if !success {
+			return nil, errors.New("could not parse native balance")
+		}
+		if blockNumber < uint64(beginBlock) {
+			balanceMap[uint64(beginBlock)] = parsed
+		} else {
+			balanceMap[blockNumber] = parsed
+		}
+	}
+	return balanceMap, rows.Err()
+}
@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"quorumengineering/quorum-report/types"
+)
+
+// ProxyDB
+func (pg *PostgresDB) RecordProxyImplementation(proxy types.Address, implementation *types.ProxyImplementation) error {
+	_, err := pg.db.Exec(`
+INSERT INTO proxy_implementation (proxy, block_number, implementation, standard) VALUES ($1, $2, $3, $4)
+ON CONFLICT (proxy, block_number) DO UPDATE SET implementation = EXCLUDED.implementation, standard = EXCLUDED.standard`,
+		proxy, implementation.BlockNumber, implementation.Implementation, implementation.Standard)
+	return err
+}
+
+func (pg *PostgresDB) GetProxyImplementations(proxy types.Address) ([]*types.ProxyImplementation, error) {
+	rows, err := pg.db.Query(`SELECT implementation, standard, block_number FROM proxy_implementation WHERE proxy = $1 ORDER BY block_number`, proxy)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	implementations := make([]*types.ProxyImplementation, 0)
+	for rows.Next() {
+		var implementation types.ProxyImplementation
+		if err := rows.Scan(&implementation.Implementation, &implementation.Standard, &implementation.BlockNumber); err != nil {
+			return nil, err
+		}
+		implementations = append(implementations, &implementation)
+	}
+	return implementations, rows.Err()
+}
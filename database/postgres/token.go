@@ -0,0 +1,768 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"math/big"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// Token DB
+func (pg *PostgresDB) RecordNewERC20Balance(contract types.Address, holder types.Address, block uint64, amount *big.Int) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		// update the older entry
+		_, err := tx.Exec(`
+UPDATE erc20_balance SET held_until = $3::BIGINT - 1
+WHERE contract = $1 AND holder = $2 AND block_number = (
+	SELECT MAX(block_number) FROM erc20_balance WHERE contract = $1 AND holder = $2 AND block_number < $3
+)`, contract, holder, block)
+		if err != nil {
+			return err
+		}
+
+		// add new entry
+		_, err = tx.Exec(`
+INSERT INTO erc20_balance (contract, holder, block_number, amount) VALUES ($1, $2, $3, $4)
+ON CONFLICT (contract, holder, block_number) DO UPDATE SET amount = EXCLUDED.amount, held_until = NULL`,
+			contract, holder, block, amount.String())
+		return err
+	})
+}
+
+func (pg *PostgresDB) GetERC20Balance(contract types.Address, holder types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	beginBlock, endBlock := rangeBounds(options.BeginBlockNumber, options.EndBlockNumber)
+
+	// get all the balances in the block range, as well as the last balance before the
+	// starting block if there was no balance update on the starting block
+	rows, err := pg.db.Query(`
+SELECT block_number, amount FROM erc20_balance
+WHERE contract = $1 AND holder = $2 AND (
+	(block_number < $3 AND (held_until IS NULL OR held_until >= $3)) OR block_number BETWEEN $3 AND $4
+)
+ORDER BY block_number DESC LIMIT $5 OFFSET $6`,
+		contract, holder, beginBlock, endBlock, options.PageSize, options.PageSize*options.PageNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balanceMap := make(map[uint64]*big.Int)
+	for rows.Next() {
+		var blockNumber uint64
+		var amount string
+		if err := rows.Scan(&blockNumber, &amount); err != nil {
+			return nil, err
+		}
+		tokenAmount, success := new(big.Int).SetString(amount, 10)
+		if !success {
+			return nil, errors.New("could not parse token value")
+		}
+		if blockNumber < uint64(beginBlock) {
+			balanceMap[uint64(beginBlock)] = tokenAmount
+		} else {
+			balanceMap[blockNumber] = tokenAmount
+		}
+	}
+	return balanceMap, rows.Err()
+}
+
+func (pg *PostgresDB) GetAllTokenHolders(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	return pg.getHolders(`
+SELECT DISTINCT holder FROM erc20_balance
+WHERE contract = $1 AND block_number <= $2 AND (held_until IS NULL OR held_until >= $2) AND holder > $3 AND holder <> $4
+ORDER BY holder LIMIT $5`, contract, block, options, types.NewAddress(""))
+}
+
+func (pg *PostgresDB) ERC20HolderBalancesAtBlock(contract types.Address, block uint64) (map[types.Address]*big.Int, error) {
+	rows, err := pg.db.Query(`
+SELECT holder, amount FROM erc20_balance
+WHERE contract = $1 AND block_number <= $2 AND (held_until IS NULL OR held_until >= $2) AND amount <> 0 AND holder <> $3`,
+		contract, block, types.NewAddress(""))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := make(map[types.Address]*big.Int)
+	for rows.Next() {
+		var holder types.Address
+		var amount string
+		if err := rows.Scan(&holder, &amount); err != nil {
+			return nil, err
+		}
+		tokenAmount, success := new(big.Int).SetString(amount, 10)
+		if !success {
+			return nil, errors.New("could not parse token value")
+		}
+		balances[holder] = tokenAmount
+	}
+	return balances, rows.Err()
+}
+
+func (pg *PostgresDB) ERC20HolderCountAtBlock(contract types.Address, block uint64) (uint64, error) {
+	var count uint64
+	err := pg.db.QueryRow(`
+SELECT COUNT(*) FROM erc20_balance
+WHERE contract = $1 AND block_number <= $2 AND (held_until IS NULL OR held_until >= $2) AND amount <> 0 AND holder <> $3`,
+		contract, block, types.NewAddress("")).Scan(&count)
+	return count, err
+}
+
+func (pg *PostgresDB) AllERC20BalancesForAccountAtBlock(holder types.Address, block uint64) (map[types.Address]*big.Int, error) {
+	rows, err := pg.db.Query(`
+SELECT contract, amount FROM erc20_balance
+WHERE holder = $1 AND block_number <= $2 AND (held_until IS NULL OR held_until >= $2) AND amount <> 0`,
+		holder, block)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := make(map[types.Address]*big.Int)
+	for rows.Next() {
+		var contract types.Address
+		var amount string
+		if err := rows.Scan(&contract, &amount); err != nil {
+			return nil, err
+		}
+		tokenAmount, success := new(big.Int).SetString(amount, 10)
+		if !success {
+			return nil, errors.New("could not parse token value")
+		}
+		balances[contract] = tokenAmount
+	}
+	return balances, rows.Err()
+}
+
+func (pg *PostgresDB) ERC20BalanceChanges(contract types.Address, fromBlock uint64, toBlock uint64) ([]types.ERC20BalanceChange, error) {
+	rows, err := pg.db.Query(`
+SELECT holder, block_number, amount FROM erc20_balance
+WHERE contract = $1 AND block_number BETWEEN $2 AND $3 AND holder <> $4
+ORDER BY block_number, holder`, contract, fromBlock, toBlock, types.NewAddress(""))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]types.ERC20BalanceChange, 0)
+	for rows.Next() {
+		var change types.ERC20BalanceChange
+		if err := rows.Scan(&change.Holder, &change.BlockNumber, &change.Amount); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+func (pg *PostgresDB) RecordERC20Allowance(contract types.Address, owner types.Address, spender types.Address, block uint64, amount *big.Int) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		// update the older entry
+		_, err := tx.Exec(`
+UPDATE erc20_allowance SET approved_until = $4::BIGINT - 1
+WHERE contract = $1 AND owner = $2 AND spender = $3 AND approved_from = (
+	SELECT MAX(approved_from) FROM erc20_allowance WHERE contract = $1 AND owner = $2 AND spender = $3 AND approved_from < $4
+)`, contract, owner, spender, block)
+		if err != nil {
+			return err
+		}
+
+		// add new entry
+		_, err = tx.Exec(`
+INSERT INTO erc20_allowance (contract, owner, spender, approved_from, amount) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (contract, owner, spender, approved_from) DO UPDATE SET amount = EXCLUDED.amount, approved_until = NULL`,
+			contract, owner, spender, block, amount.String())
+		return err
+	})
+}
+
+func (pg *PostgresDB) ERC20AllowanceAtBlock(contract types.Address, owner types.Address, spender types.Address, block uint64) (*big.Int, error) {
+	var amount string
+	err := pg.db.QueryRow(`
+SELECT amount FROM erc20_allowance
+WHERE contract = $1 AND owner = $2 AND spender = $3 AND approved_from <= $4 ORDER BY approved_from DESC LIMIT 1`,
+		contract, owner, spender, block).Scan(&amount)
+	if err == sql.ErrNoRows {
+		return big.NewInt(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	allowance, success := new(big.Int).SetString(amount, 10)
+	if !success {
+		return nil, errors.New("could not parse allowance value")
+	}
+	return allowance, nil
+}
+
+func (pg *PostgresDB) ERC20ApprovalsForOwnerAtBlock(contract types.Address, owner types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC20Allowance, error) {
+	after := ""
+	if options.After != "" {
+		after = string(types.NewAddress(options.After))
+	}
+
+	rows, err := pg.db.Query(`
+SELECT spender, amount, approved_from, approved_until FROM erc20_allowance
+WHERE contract = $1 AND owner = $2 AND approved_from <= $3 AND (approved_until IS NULL OR approved_until >= $3)
+	AND amount <> 0 AND spender > $4
+ORDER BY spender LIMIT $5`, contract, owner, block, after, options.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	allowances := make([]types.ERC20Allowance, 0)
+	for rows.Next() {
+		allowance := types.ERC20Allowance{Contract: contract, Owner: owner}
+		var approvedUntil sql.NullInt64
+		if err := rows.Scan(&allowance.Spender, &allowance.Amount, &allowance.ApprovedFrom, &approvedUntil); err != nil {
+			return nil, err
+		}
+		allowance.ApprovedUntil = toUint64Ptr(approvedUntil)
+		allowances = append(allowances, allowance)
+	}
+	return allowances, rows.Err()
+}
+
+func (pg *PostgresDB) RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		// update the older entry
+		_, err := tx.Exec(`
+UPDATE erc721_token SET held_until = $3::BIGINT - 1
+WHERE contract = $1 AND token_id = $2 AND held_from = (
+	SELECT MAX(held_from) FROM erc721_token WHERE contract = $1 AND token_id = $2 AND held_from < $3
+)`, contract, tokenId.String(), block)
+		if err != nil {
+			return err
+		}
+
+		// add new entry
+		_, err = tx.Exec(`
+INSERT INTO erc721_token (contract, token_id, holder, held_from) VALUES ($1, $2, $3, $4)
+ON CONFLICT (contract, token_id, held_from) DO UPDATE SET holder = EXCLUDED.holder, held_until = NULL`,
+			contract, tokenId.String(), holder, block)
+		return err
+	})
+}
+
+func (pg *PostgresDB) ERC721TokenByTokenID(contract types.Address, block uint64, tokenId *big.Int) (*types.ERC721Token, error) {
+	token := types.ERC721Token{Contract: contract}
+	var heldUntil sql.NullInt64
+	err := pg.db.QueryRow(`
+SELECT token_id, holder, held_from, held_until FROM erc721_token
+WHERE contract = $1 AND token_id = $2 AND held_from <= $3 ORDER BY held_from DESC LIMIT 1`, contract, tokenId.String(), block).
+		Scan(&token.Token, &token.Holder, &token.HeldFrom, &heldUntil)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	token.HeldUntil = toUint64Ptr(heldUntil)
+	return &token, nil
+}
+
+func (pg *PostgresDB) ERC721TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
+	return pg.erc721TokensAtBlock(contract, holder, block, options)
+}
+
+func (pg *PostgresDB) AllERC721TokensAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
+	return pg.erc721TokensAtBlock(contract, "", block, options)
+}
+
+func (pg *PostgresDB) AllHoldersAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	return pg.getHolders(`
+SELECT DISTINCT holder FROM erc721_token
+WHERE contract = $1 AND held_from <= $2 AND (held_until IS NULL OR held_until >= $2) AND holder > $3 AND holder <> $4
+ORDER BY holder LIMIT $5`, contract, block, options, "")
+}
+
+func (pg *PostgresDB) AllERC721TokensForAccountAtBlock(holder types.Address, block uint64, options *types.AccountTokenQueryOptions) ([]types.ERC721Token, error) {
+	afterContract, afterId, hasCursor, err := options.Cursor()
+	if err != nil {
+		return nil, err
+	}
+	// every token sorts after an empty contract and a negative token ID
+	afterToken := "-1"
+	if hasCursor {
+		afterToken = afterId.String()
+	}
+
+	rows, err := pg.db.Query(`
+SELECT contract, token_id, held_from, held_until FROM erc721_token
+WHERE holder = $1 AND held_from <= $2 AND (held_until IS NULL OR held_until >= $2) AND (contract, token_id) > ($3, $4::NUMERIC)
+ORDER BY contract, token_id LIMIT $5`, holder, block, afterContract, afterToken, options.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]types.ERC721Token, 0)
+	for rows.Next() {
+		token := types.ERC721Token{Holder: holder}
+		var heldUntil sql.NullInt64
+		if err := rows.Scan(&token.Contract, &token.Token, &token.HeldFrom, &heldUntil); err != nil {
+			return nil, err
+		}
+		token.HeldUntil = toUint64Ptr(heldUntil)
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (pg *PostgresDB) RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		// update the older entry
+		_, err := tx.Exec(`
+UPDATE erc1155_balance SET held_until = $4::BIGINT - 1
+WHERE contract = $1 AND holder = $2 AND token_id = $3 AND held_from = (
+	SELECT MAX(held_from) FROM erc1155_balance WHERE contract = $1 AND holder = $2 AND token_id = $3 AND held_from < $4
+)`, contract, holder, tokenId.String(), block)
+		if err != nil {
+			return err
+		}
+
+		// add new entry
+		_, err = tx.Exec(`
+INSERT INTO erc1155_balance (contract, holder, token_id, held_from, amount) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (contract, holder, token_id, held_from) DO UPDATE SET amount = EXCLUDED.amount, held_until = NULL`,
+			contract, holder, tokenId.String(), block, amount.String())
+		return err
+	})
+}
+
+func (pg *PostgresDB) ERC1155BalanceAtBlock(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) (*big.Int, error) {
+	var amount string
+	err := pg.db.QueryRow(`
+SELECT amount FROM erc1155_balance
+WHERE contract = $1 AND holder = $2 AND token_id = $3 AND held_from <= $4 ORDER BY held_from DESC LIMIT 1`,
+		contract, holder, tokenId.String(), block).Scan(&amount)
+	if err == sql.ErrNoRows {
+		return big.NewInt(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tokenAmount, success := new(big.Int).SetString(amount, 10)
+	if !success {
+		return nil, errors.New("could not parse token value")
+	}
+	return tokenAmount, nil
+}
+
+func (pg *PostgresDB) ERC1155TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC1155Token, error) {
+	startTokenId := big.NewInt(-1)
+	if options.After != "" {
+		parsed, success := new(big.Int).SetString(options.After, 10)
+		if !success {
+			return nil, errors.New(`could not parse "after" token ID`)
+		}
+		startTokenId = parsed
+	}
+
+	rows, err := pg.db.Query(`
+SELECT token_id, amount, held_from, held_until FROM erc1155_balance
+WHERE contract = $1 AND holder = $2 AND held_from <= $3 AND (held_until IS NULL OR held_until >= $3)
+	AND amount <> 0 AND token_id > $4::NUMERIC
+ORDER BY token_id LIMIT $5 OFFSET $6`,
+		contract, holder, block, startTokenId.String(), options.PageSize, options.PageSize*options.PageNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]types.ERC1155Token, 0)
+	for rows.Next() {
+		token := types.ERC1155Token{Contract: contract, Holder: holder}
+		var heldUntil sql.NullInt64
+		if err := rows.Scan(&token.Token, &token.Amount, &token.HeldFrom, &heldUntil); err != nil {
+			return nil, err
+		}
+		token.HeldUntil = toUint64Ptr(heldUntil)
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (pg *PostgresDB) ERC1155HoldersAtBlock(contract types.Address, block uint64, tokenId *big.Int, options *types.TokenQueryOptions) ([]types.Address, error) {
+	after := ""
+	if options.After != "" {
+		after = string(types.NewAddress(options.After))
+	}
+
+	rows, err := pg.db.Query(`
+SELECT holder FROM erc1155_balance
+WHERE contract = $1 AND token_id = $2 AND held_from <= $3 AND (held_until IS NULL OR held_until >= $3)
+	AND amount <> 0 AND holder > $4
+ORDER BY holder LIMIT $5`, contract, tokenId.String(), block, after, options.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holders := make([]types.Address, 0)
+	for rows.Next() {
+		var holder types.Address
+		if err := rows.Scan(&holder); err != nil {
+			return nil, err
+		}
+		holders = append(holders, holder)
+	}
+	return holders, rows.Err()
+}
+
+func (pg *PostgresDB) RecordTokenBalance(standard string, contract types.Address, holder types.Address, block uint64, amount *big.Int) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		// update the older entry
+		_, err := tx.Exec(`
+UPDATE token_balance SET held_until = $4::BIGINT - 1
+WHERE standard = $1 AND contract = $2 AND holder = $3 AND held_from = (
+	SELECT MAX(held_from) FROM token_balance WHERE standard = $1 AND contract = $2 AND holder = $3 AND held_from < $4
+)`, standard, contract, holder, block)
+		if err != nil {
+			return err
+		}
+
+		// add new entry
+		_, err = tx.Exec(`
+INSERT INTO token_balance (standard, contract, holder, held_from, amount) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (contract, standard, holder, held_from) DO UPDATE SET amount = EXCLUDED.amount, held_until = NULL`,
+			standard, contract, holder, block, amount.String())
+		return err
+	})
+}
+
+func (pg *PostgresDB) TokenBalanceAtBlock(standard string, contract types.Address, holder types.Address, block uint64) (*big.Int, error) {
+	var amount string
+	err := pg.db.QueryRow(`
+SELECT amount FROM token_balance
+WHERE standard = $1 AND contract = $2 AND holder = $3 AND held_from <= $4 ORDER BY held_from DESC LIMIT 1`,
+		standard, contract, holder, block).Scan(&amount)
+	if err == sql.ErrNoRows {
+		return big.NewInt(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tokenAmount, success := new(big.Int).SetString(amount, 10)
+	if !success {
+		return nil, errors.New("could not parse token value")
+	}
+	return tokenAmount, nil
+}
+
+func (pg *PostgresDB) TokenHoldersAtBlock(standard string, contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	after := ""
+	if options.After != "" {
+		after = string(types.NewAddress(options.After))
+	}
+
+	rows, err := pg.db.Query(`
+SELECT holder FROM token_balance
+WHERE standard = $1 AND contract = $2 AND held_from <= $3 AND (held_until IS NULL OR held_until >= $3)
+	AND amount <> 0 AND holder > $4
+ORDER BY holder LIMIT $5`, standard, contract, block, after, options.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holders := make([]types.Address, 0)
+	for rows.Next() {
+		var holder types.Address
+		if err := rows.Scan(&holder); err != nil {
+			return nil, err
+		}
+		holders = append(holders, holder)
+	}
+	return holders, rows.Err()
+}
+
+func (pg *PostgresDB) RecordTokenTransfers(transfers []types.TokenTransfer) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		for _, transfer := range transfers {
+			_, err := tx.Exec(`
+INSERT INTO token_transfer (contract, block_number, log_index, from_address, to_address, amount, token_id, transaction_hash, timestamp)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (contract, block_number, log_index) DO UPDATE SET from_address = EXCLUDED.from_address,
+	to_address = EXCLUDED.to_address, amount = EXCLUDED.amount, token_id = EXCLUDED.token_id,
+	transaction_hash = EXCLUDED.transaction_hash, timestamp = EXCLUDED.timestamp`,
+				transfer.Contract, transfer.BlockNumber, transfer.LogIndex, transfer.From, transfer.To,
+				toNullString(transfer.Amount), toNullString(transfer.TokenId), transfer.TransactionHash, transfer.Timestamp)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (pg *PostgresDB) ERC721TokenTransfers(contract types.Address, tokenId *big.Int, options *types.TokenQueryOptions) ([]types.TokenTransfer, error) {
+	beginBlock, endBlock := rangeBounds(options.BeginBlockNumber, options.EndBlockNumber)
+
+	rows, err := pg.db.Query(`
+SELECT block_number, log_index, from_address, to_address, transaction_hash, timestamp FROM token_transfer
+WHERE contract = $1 AND token_id = $2 AND block_number BETWEEN $3 AND $4
+ORDER BY block_number, log_index LIMIT $5 OFFSET $6`,
+		contract, tokenId.String(), beginBlock, endBlock, options.PageSize, options.PageSize*options.PageNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := make([]types.TokenTransfer, 0)
+	for rows.Next() {
+		transfer := types.TokenTransfer{Contract: contract, TokenId: tokenId.String()}
+		if err := rows.Scan(&transfer.BlockNumber, &transfer.LogIndex, &transfer.From, &transfer.To, &transfer.TransactionHash, &transfer.Timestamp); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+	return transfers, rows.Err()
+}
+
+func (pg *PostgresDB) GetTokenTransfers(contract *types.Address, counterparty *types.Address, direction string, options *types.TokenTransferQueryOptions) ([]types.TokenTransfer, error) {
+	afterBlock, afterLogIndex, hasCursor, err := options.Cursor()
+	if err != nil {
+		return nil, err
+	}
+	beginBlock, endBlock := rangeBounds(options.BeginBlockNumber, options.EndBlockNumber)
+	beginTime, endTime := rangeBounds(options.BeginTimestamp, options.EndTimestamp)
+
+	var contractFilter, counterpartyFilter types.Address
+	if contract != nil {
+		contractFilter = *contract
+	}
+	if counterparty != nil {
+		counterpartyFilter = *counterparty
+	}
+	// without a cursor, start before the first log of the first block
+	afterBlockFilter, afterLogIndexFilter := int64(-1), int64(-1)
+	if hasCursor {
+		afterBlockFilter, afterLogIndexFilter = int64(afterBlock), int64(afterLogIndex)
+	}
+
+	rows, err := pg.db.Query(`
+SELECT contract, block_number, log_index, from_address, to_address, amount, token_id, transaction_hash, timestamp
+FROM token_transfer
+WHERE ($1::TEXT = '' OR contract = $1)
+	AND ($2::TEXT = '' OR ($3::TEXT <> 'out' AND to_address = $2) OR ($3::TEXT <> 'in' AND from_address = $2))
+	AND block_number BETWEEN $4 AND $5 AND timestamp BETWEEN $6 AND $7
+	AND (block_number, log_index) > ($8, $9)
+ORDER BY block_number, log_index LIMIT $10`,
+		contractFilter, counterpartyFilter, direction, beginBlock, endBlock, beginTime, endTime,
+		afterBlockFilter, afterLogIndexFilter, options.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := make([]types.TokenTransfer, 0)
+	for rows.Next() {
+		var transfer types.TokenTransfer
+		var amount, tokenId sql.NullString
+		if err := rows.Scan(&transfer.Contract, &transfer.BlockNumber, &transfer.LogIndex, &transfer.From, &transfer.To,
+			&amount, &tokenId, &transfer.TransactionHash, &transfer.Timestamp); err != nil {
+			return nil, err
+		}
+		transfer.Amount = amount.String
+		transfer.TokenId = tokenId.String
+		transfers = append(transfers, transfer)
+	}
+	return transfers, rows.Err()
+}
+
+// Internal functions
+
+// erc721TokensAtBlock returns the tokens held at the given block, optionally only those
+// of a single holder, ordered by token ID and starting after the token ID in the options
+func (pg *PostgresDB) RecordTokenInfo(info *types.TokenInfo) error {
+	var decimals sql.NullInt64
+	if info.Decimals != nil {
+		decimals = sql.NullInt64{Int64: int64(*info.Decimals), Valid: true}
+	}
+	_, err := pg.db.Exec(`
+INSERT INTO token_info (contract, standard, name, symbol, decimals) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (contract) DO UPDATE SET standard = EXCLUDED.standard, name = EXCLUDED.name, symbol = EXCLUDED.symbol, decimals = EXCLUDED.decimals`,
+		info.Contract, info.Standard, info.Name, info.Symbol, decimals)
+	return err
+}
+
+func (pg *PostgresDB) GetTokenInfo(contract types.Address) (*types.TokenInfo, error) {
+	info := types.TokenInfo{Contract: contract}
+	var decimals sql.NullInt64
+	err := pg.db.QueryRow(`SELECT standard, name, symbol, decimals FROM token_info WHERE contract = $1`, contract).
+		Scan(&info.Standard, &info.Name, &info.Symbol, &decimals)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if decimals.Valid {
+		converted := uint8(decimals.Int64)
+		info.Decimals = &converted
+	}
+	return &info, nil
+}
+
+func (pg *PostgresDB) RecordTotalSupply(contract types.Address, block uint64, supply *big.Int) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		// update the older entry
+		_, err := tx.Exec(`
+UPDATE total_supply SET supply_until = $2::BIGINT - 1
+WHERE contract = $1 AND block_number = (
+	SELECT MAX(block_number) FROM total_supply WHERE contract = $1 AND block_number < $2
+)`, contract, block)
+		if err != nil {
+			return err
+		}
+
+		// add new entry
+		_, err = tx.Exec(`
+INSERT INTO total_supply (contract, block_number, total_supply) VALUES ($1, $2, $3)
+ON CONFLICT (contract, block_number) DO UPDATE SET total_supply = EXCLUDED.total_supply, supply_until = NULL`,
+			contract, block, supply.String())
+		return err
+	})
+}
+
+func (pg *PostgresDB) GetTotalSupply(contract types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	beginBlock, endBlock := rangeBounds(options.BeginBlockNumber, options.EndBlockNumber)
+
+	// get all the supplies in the block range, as well as the last supply before the
+	// starting block if there was no change on the starting block
+	rows, err := pg.db.Query(`
+SELECT block_number, total_supply FROM total_supply
+WHERE contract = $1 AND (
+	(block_number < $2 AND (supply_until IS NULL OR supply_until >= $2)) OR block_number BETWEEN $2 AND $3
+)
+ORDER BY block_number DESC LIMIT $4 OFFSET $5`,
+		contract, beginBlock, endBlock, options.PageSize, options.PageSize*options.PageNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	supplyMap := make(map[uint64]*big.Int)
+	for rows.Next() {
+		var blockNumber uint64
+		var totalSupply string
+		if err := rows.Scan(&blockNumber, &totalSupply); err != nil {
+			return nil, err
+		}
+		supply, success := new(big.Int).SetString(totalSupply, 10)
+		if !success {
+			return nil, errors.New("could not parse total supply")
+		}
+		if blockNumber < uint64(beginBlock) {
+			supplyMap[uint64(beginBlock)] = supply
+		} else {
+			supplyMap[blockNumber] = supply
+		}
+	}
+	return supplyMap, rows.Err()
+}
+
+func (pg *PostgresDB) erc721TokensAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
+	startTokenId := big.NewInt(-1)
+	if options.After != "" {
+		parsed, success := new(big.Int).SetString(options.After, 10)
+		if !success {
+			return nil, errors.New(`could not parse "after" token ID`)
+		}
+		startTokenId = parsed
+	}
+
+	rows, err := pg.db.Query(`
+SELECT token_id, holder, held_from, held_until FROM erc721_token
+WHERE contract = $1 AND ($2::TEXT = '' OR holder = $2) AND held_from <= $3 AND (held_until IS NULL OR held_until >= $3)
+	AND token_id > $4::NUMERIC
+ORDER BY token_id LIMIT $5 OFFSET $6`,
+		contract, holder, block, startTokenId.String(), options.PageSize, options.PageSize*options.PageNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]types.ERC721Token, 0)
+	for rows.Next() {
+		token := types.ERC721Token{Contract: contract}
+		var heldUntil sql.NullInt64
+		if err := rows.Scan(&token.Token, &token.Holder, &token.HeldFrom, &heldUntil); err != nil {
+			return nil, err
+		}
+		token.HeldUntil = toUint64Ptr(heldUntil)
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// getHolders runs a query for token holders at a block, starting after the holder given
+// in the options and excluding the given holder
+func (pg *PostgresDB) getHolders(query string, contract types.Address, block uint64, options *types.TokenQueryOptions, excluded types.Address) ([]types.Address, error) {
+	after := ""
+	if options.After != "" {
+		after = string(types.NewAddress(options.After))
+	}
+
+	rows, err := pg.db.Query(query, contract, block, after, excluded, options.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holders := make([]types.Address, 0)
+	for rows.Next() {
+		var holder types.Address
+		if err := rows.Scan(&holder); err != nil {
+			return nil, err
+		}
+		holders = append(holders, holder)
+	}
+	return holders, rows.Err()
+}
+
+func (pg *PostgresDB) RollbackTokenRange(contract types.Address, fromBlock uint64, toBlock uint64) error {
+	return pg.inTransaction(func(tx *sql.Tx) error {
+		return rollbackTokens(tx, &contract, fromBlock, toBlock)
+	})
+}
+
+// rollbackTokens removes the token records from blocks in the range, and re-opens the
+// records they superseded, of only the given contract if one is given
+func rollbackTokens(tx *sql.Tx, contract *types.Address, fromBlock uint64, toBlock uint64) error {
+	condition, args := "", []interface{}{fromBlock, toBlock}
+	if contract != nil {
+		condition, args = ` AND contract = $3`, append(args, *contract)
+	}
+	statements := []string{
+		`DELETE FROM erc20_balance WHERE block_number BETWEEN $1 AND $2`,
+		`UPDATE erc20_balance SET held_until = NULL WHERE held_until >= $1 - 1 AND held_until < $2`,
+		`DELETE FROM erc20_allowance WHERE approved_from BETWEEN $1 AND $2`,
+		`UPDATE erc20_allowance SET approved_until = NULL WHERE approved_until >= $1 - 1 AND approved_until < $2`,
+		`DELETE FROM erc721_token WHERE held_from BETWEEN $1 AND $2`,
+		`UPDATE erc721_token SET held_until = NULL WHERE held_until >= $1 - 1 AND held_until < $2`,
+		`DELETE FROM erc1155_balance WHERE held_from BETWEEN $1 AND $2`,
+		`UPDATE erc1155_balance SET held_until = NULL WHERE held_until >= $1 - 1 AND held_until < $2`,
+		`DELETE FROM token_balance WHERE held_from BETWEEN $1 AND $2`,
+		`UPDATE token_balance SET held_until = NULL WHERE held_until >= $1 - 1 AND held_until < $2`,
+		`DELETE FROM token_transfer WHERE block_number BETWEEN $1 AND $2`,
+		`DELETE FROM total_supply WHERE block_number BETWEEN $1 AND $2`,
+		`UPDATE total_supply SET supply_until = NULL WHERE supply_until >= $1 - 1 AND supply_until < $2`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement+condition, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toNullString stores an empty string as NULL, for optional NUMERIC columns
+func toNullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func toUint64Ptr(value sql.NullInt64) *uint64 {
+	if !value.Valid {
+		return nil
+	}
+	converted := uint64(value.Int64)
+	return &converted
+}
@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// tokenRollbackStatements lists the statements rolling back the token records, followed by the given condition
+func tokenRollbackStatements(condition string) []string {
+	statements := []string{
+		`DELETE FROM erc20_balance WHERE block_number BETWEEN $1 AND $2`,
+		`UPDATE erc20_balance SET held_until = NULL WHERE held_until >= $1 - 1 AND held_until < $2`,
+		`DELETE FROM erc20_allowance WHERE approved_from BETWEEN $1 AND $2`,
+		`UPDATE erc20_allowance SET approved_until = NULL WHERE approved_until >= $1 - 1 AND approved_until < $2`,
+		`DELETE FROM erc721_token WHERE held_from BETWEEN $1 AND $2`,
+		`UPDATE erc721_token SET held_until = NULL WHERE held_until >= $1 - 1 AND held_until < $2`,
+		`DELETE FROM erc1155_balance WHERE held_from BETWEEN $1 AND $2`,
+		`UPDATE erc1155_balance SET held_until = NULL WHERE held_until >= $1 - 1 AND held_until < $2`,
+		`DELETE FROM token_balance WHERE held_from BETWEEN $1 AND $2`,
+		`UPDATE token_balance SET held_until = NULL WHERE held_until >= $1 - 1 AND held_until < $2`,
+		`DELETE FROM token_transfer WHERE block_number BETWEEN $1 AND $2`,
+		`DELETE FROM total_supply WHERE block_number BETWEEN $1 AND $2`,
+		`UPDATE total_supply SET supply_until = NULL WHERE supply_until >= $1 - 1 AND supply_until < $2`,
+	}
+	for i := range statements {
+		statements[i] += condition
+	}
+	return statements
+}
+
+func TestPostgresDB_RollbackTokenRange(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	mock.ExpectBegin()
+	// only the records of the contract in the range are rolled back
+	for _, statement := range tokenRollbackStatements(` AND contract = $3`) {
+		mock.ExpectExec(statement).WithArgs(5, 8, addr).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	err := db.RollbackTokenRange(addr, 5, 8)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_RollbackTokenRange_Error(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM erc20_balance WHERE block_number BETWEEN $1 AND $2 AND contract = $3`).
+		WithArgs(5, 8, addr).
+		WillReturnError(errors.New("test error"))
+	mock.ExpectRollback()
+
+	err := db.RollbackTokenRange(addr, 5, 8)
+
+	assert.EqualError(t, err, "test error")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_GetTokenTransfers(t *testing.T) {
+	db, mock := newMockDB(t)
+	holder := types.NewAddress("0x0000000000000000000000000000000000000010")
+	options := &types.TokenTransferQueryOptions{After: "3-1"}
+	options.SetDefaults()
+
+	mock.ExpectQuery(`
+SELECT contract, block_number, log_index, from_address, to_address, amount, token_id, transaction_hash, timestamp
+FROM token_transfer
+WHERE ($1::TEXT = '' OR contract = $1)
+	AND ($2::TEXT = '' OR ($3::TEXT <> 'out' AND to_address = $2) OR ($3::TEXT <> 'in' AND from_address = $2))
+	AND block_number BETWEEN $4 AND $5 AND timestamp BETWEEN $6 AND $7
+	AND (block_number, log_index) > ($8, $9)
+ORDER BY block_number, log_index LIMIT $10`).
+		WithArgs("", holder, "in", 0, math.MaxInt64, 0, math.MaxInt64, 3, 1, options.PageSize).
+		WillReturnRows(sqlmock.NewRows([]string{"contract", "block_number", "log_index", "from_address", "to_address", "amount", "token_id", "transaction_hash", "timestamp"}).
+			AddRow(string(addr), 4, 0, "", string(holder), "100", nil, string(tx.Hash), 200))
+
+	transfers, err := db.GetTokenTransfers(nil, &holder, "in", options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []types.TokenTransfer{{
+		Contract:        addr,
+		BlockNumber:     4,
+		LogIndex:        0,
+		From:            "",
+		To:              holder,
+		Amount:          "100",
+		TransactionHash: tx.Hash,
+		Timestamp:       200,
+	}}, transfers)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_GetTokenTransfers_InvalidCursor(t *testing.T) {
+	db, mock := newMockDB(t)
+	options := &types.TokenTransferQueryOptions{After: "3"}
+	options.SetDefaults()
+
+	_, err := db.GetTokenTransfers(&addr, nil, "", options)
+
+	assert.EqualError(t, err, `invalid "after" cursor`)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_ERC20HolderCountAtBlock(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	mock.ExpectQuery(`
+SELECT COUNT(*) FROM erc20_balance
+WHERE contract = $1 AND block_number <= $2 AND (held_until IS NULL OR held_until >= $2) AND amount <> 0 AND holder <> $3`).
+		WithArgs(addr, 5, types.NewAddress("")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+
+	count, err := db.ERC20HolderCountAtBlock(addr, 5)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 42, count)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDB_ERC20BalanceChanges(t *testing.T) {
+	db, mock := newMockDB(t)
+	holder := types.NewAddress("0x0000000000000000000000000000000000000010")
+
+	mock.ExpectQuery(`
+SELECT holder, block_number, amount FROM erc20_balance
+WHERE contract = $1 AND block_number BETWEEN $2 AND $3 AND holder <> $4
+ORDER BY block_number, holder`).
+		WithArgs(addr, 2, 5, types.NewAddress("")).
+		WillReturnRows(sqlmock.NewRows([]string{"holder", "block_number", "amount"}).AddRow(string(holder), 3, "100"))
+
+	changes, err := db.ERC20BalanceChanges(addr, 2, 5)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []types.ERC20BalanceChange{{Holder: holder, BlockNumber: 3, Amount: "100"}}, changes)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -2,7 +2,9 @@ package types
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -10,6 +12,8 @@ type ContractABI struct {
 	Constructor ContractABIFunction
 	Functions   []ContractABIFunction
 	Events      []ContractABIEvent
+	// Errors are the custom errors of the contract, which are encoded like a function call
+	Errors []ContractABIFunction
 }
 
 type ContractABIFunction struct {
@@ -43,6 +47,19 @@ func (function ContractABIFunction) Parse(data []byte) (map[string]interface{},
 	return ParseAllData(function.Inputs, data)
 }
 
+// ParseOutput decodes the values returned by the function. Unnamed return values are keyed
+// by their position.
+func (function ContractABIFunction) ParseOutput(data []byte) (map[string]interface{}, error) {
+	outputs := make([]ContractABIArgument, len(function.Outputs))
+	for i, output := range function.Outputs {
+		outputs[i] = output
+		if output.Name == "" {
+			outputs[i].Name = strconv.Itoa(i)
+		}
+	}
+	return ParseAllData(outputs, data)
+}
+
 type ContractABIArgument struct {
 	Name       string
 	Type       string
@@ -133,6 +150,47 @@ func (arg ContractABIArgument) IsDynamic() bool {
 	return false
 }
 
+// HeadSize returns the number of bytes the argument takes up in the head of an encoding.
+// Dynamic arguments only store the offset of their contents.
+func (arg ContractABIArgument) HeadSize() (int, error) {
+	if arg.IsDynamic() {
+		return 32, nil
+	}
+	if strings.HasSuffix(arg.Type, "]") {
+		start := strings.LastIndex(arg.Type, "[")
+		numberOfElements, err := strconv.Atoi(arg.Type[start+1 : len(arg.Type)-1])
+		if err != nil {
+			return 0, errors.New("error parsing static array size: " + err.Error())
+		}
+		elementSize, err := ContractABIArgument{Type: arg.Type[:start], Components: arg.Components}.HeadSize()
+		if err != nil {
+			return 0, err
+		}
+		return numberOfElements * elementSize, nil
+	}
+	if strings.HasPrefix(arg.Type, "tuple") {
+		size := 0
+		for _, comp := range arg.Components {
+			compSize, err := comp.HeadSize()
+			if err != nil {
+				return 0, err
+			}
+			size += compSize
+		}
+		return size, nil
+	}
+	return 32, nil
+}
+
+// IsValueType returns whether the argument is an elementary type that is encoded in a
+// single word, which are the only types that are stored directly when indexed in an event
+func (arg ContractABIArgument) IsValueType() bool {
+	if strings.Contains(arg.Type, "[") || strings.HasPrefix(arg.Type, "tuple") {
+		return false
+	}
+	return arg.Type != "bytes" && arg.Type != "string"
+}
+
 type ContractABIEvent struct {
 	Type      string
 	Name      string
@@ -170,6 +228,69 @@ func (event ContractABIEvent) Parse(data []byte) (map[string]interface{}, error)
 	return ParseAllData(args, data)
 }
 
+// ParseTopics decodes the indexed arguments of the event from the given topics, which
+// must not include the event signature topic. Indexed arguments of a dynamic or compound
+// type are only stored as the hash of their encoding, so are returned as that hash.
+func (event ContractABIEvent) ParseTopics(topics []Hash) (map[string]interface{}, error) {
+	results := make(map[string]interface{})
+	next := 0
+	for _, arg := range event.Inputs {
+		if !arg.Indexed {
+			continue
+		}
+		if next >= len(topics) {
+			return nil, errors.New("not enough topics for indexed event arguments")
+		}
+		topic := topics[next]
+		next++
+
+		if !arg.IsValueType() {
+			results[arg.Name] = topic.String()
+			continue
+		}
+		data, err := hex.DecodeString(string(topic))
+		if err != nil {
+			return nil, err
+		}
+		result, _, err := ParseStaticType(arg.ContractABIArgument, data, 0)
+		if err != nil {
+			return nil, err
+		}
+		results[arg.Name] = result
+	}
+	return results, nil
+}
+
+// MatchesAnonymous returns whether the given topics and data fit the layout of the event
+// arguments, which is the only way to identify an anonymous event as it has no signature
+// topic. All indexed arguments must have a topic, and the data must be large enough to hold
+// the non-indexed arguments, being exactly that size if none of them are dynamic.
+func (event ContractABIEvent) MatchesAnonymous(topics []Hash, data []byte) bool {
+	indexedCount := 0
+	headSize := 0
+	hasDynamic := false
+	for _, arg := range event.Inputs {
+		if arg.Indexed {
+			indexedCount++
+			continue
+		}
+		size, err := arg.HeadSize()
+		if err != nil {
+			return false
+		}
+		headSize += size
+		hasDynamic = hasDynamic || arg.IsDynamic()
+	}
+
+	if indexedCount != len(topics) || len(data)%32 != 0 {
+		return false
+	}
+	if hasDynamic {
+		return len(data) >= headSize
+	}
+	return len(data) == headSize
+}
+
 type ContractABIEventArgument struct {
 	ContractABIArgument
 	Indexed bool
@@ -69,3 +69,256 @@ func TestContractABIArgument_IsDynamic(t *testing.T) {
 		assert.EqualValues(t, test.expectedDynamic, isDynamic, "Test index %d failed", idx)
 	}
 }
+
+func TestContractABIArgument_IsValueType(t *testing.T) {
+	testMatrix := []struct {
+		testType          ContractABIArgument
+		expectedValueType bool
+	}{
+		{ContractABIArgument{Type: "uint256"}, true},
+		{ContractABIArgument{Type: "int8"}, true},
+		{ContractABIArgument{Type: "bytes32"}, true},
+		{ContractABIArgument{Type: "address"}, true},
+		{ContractABIArgument{Type: "bool"}, true},
+		{ContractABIArgument{Type: "bytes"}, false},
+		{ContractABIArgument{Type: "string"}, false},
+		{ContractABIArgument{Type: "uint256[]"}, false},
+		{ContractABIArgument{Type: "uint256[5]"}, false},
+		{ContractABIArgument{Type: "tuple", Components: []ContractABIArgument{{Type: "uint256"}}}, false},
+	}
+
+	for idx, test := range testMatrix {
+		isValueType := test.testType.IsValueType()
+		assert.EqualValues(t, test.expectedValueType, isValueType, "Test index %d failed", idx)
+	}
+}
+
+func TestContractABIEvent_ParseTopics(t *testing.T) {
+	event := ContractABIEvent{
+		Name: "Indexed",
+		Inputs: []ContractABIEventArgument{
+			{ContractABIArgument{Name: "from", Type: "address"}, true},
+			{ContractABIArgument{Name: "value", Type: "uint256"}, false},
+			{ContractABIArgument{Name: "amount", Type: "int256"}, true},
+			{ContractABIArgument{Name: "flag", Type: "bool"}, true},
+			{ContractABIArgument{Name: "id", Type: "bytes4"}, true},
+			{ContractABIArgument{Name: "name", Type: "string"}, true},
+		},
+	}
+	topics := []Hash{
+		NewHash("0x000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d"),
+		NewHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffd6"),
+		NewHash("0x0000000000000000000000000000000000000000000000000000000000000001"),
+		NewHash("0x1234567800000000000000000000000000000000000000000000000000000000"),
+		NewHash("0x9c22ff5f21f0b81b113e63f7db6da94fedef11b2119b4088b89664fb9a3cb658"),
+	}
+
+	result, err := event.ParseTopics(topics)
+	assert.Nil(t, err)
+	expected := `{"from":"0xed9d02e382b34818e88b88a309c7fe71e65f419d","amount":-42,"flag":true,"id":"0x12345678","name":"0x9c22ff5f21f0b81b113e63f7db6da94fedef11b2119b4088b89664fb9a3cb658"}`
+	asJson, _ := json.Marshal(result)
+	assert.JSONEq(t, expected, string(asJson))
+
+	_, err = event.ParseTopics(topics[:4])
+	assert.EqualError(t, err, "not enough topics for indexed event arguments")
+}
+
+func TestParsedEvent_ParseEventIncludesIndexedArguments(t *testing.T) {
+	abi := `[{"anonymous":false,"inputs":[{"indexed":true,"name":"_from","type":"address"},{"indexed":true,"name":"_to","type":"address"},{"indexed":false,"name":"_value","type":"uint256"}],"name":"Transfer","type":"event"}]`
+	event := &ParsedEvent{
+		RawEvent: &Event{
+			Topics: []Hash{
+				NewHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"),
+				NewHash("0x000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d"),
+				NewHash("0x000000000000000000000000ca843569e3427144cead5e4d5999a3d0ccf92b8e"),
+			},
+			Data: NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8"),
+		},
+	}
+
+	assert.Nil(t, event.ParseEvent(abi))
+	assert.Equal(t, "event Transfer(address _from,address _to,uint256 _value)", event.Sig)
+	expected := `{"_from":"0xed9d02e382b34818e88b88a309c7fe71e65f419d","_to":"0xca843569e3427144cead5e4d5999a3d0ccf92b8e","_value":1000}`
+	asJson, _ := json.Marshal(event.ParsedData)
+	assert.JSONEq(t, expected, string(asJson))
+}
+
+func TestParsedEvent_ParseEventWithMissingTopics(t *testing.T) {
+	abi := `[{"anonymous":false,"inputs":[{"indexed":true,"name":"_from","type":"address"},{"indexed":true,"name":"_to","type":"address"},{"indexed":false,"name":"_value","type":"uint256"}],"name":"Transfer","type":"event"}]`
+	// the event has fewer topics than the definition has indexed arguments
+	event := &ParsedEvent{
+		RawEvent: &Event{
+			Topics: []Hash{
+				NewHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"),
+				NewHash("0x000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d"),
+			},
+			Data: NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8"),
+		},
+	}
+
+	assert.Nil(t, event.ParseEvent(abi))
+	assert.Equal(t, "", event.Sig)
+	assert.Nil(t, event.ParsedData)
+}
+
+func TestContractABIArgument_HeadSize(t *testing.T) {
+	testMatrix := []struct {
+		testType     ContractABIArgument
+		expectedSize int
+	}{
+		{ContractABIArgument{Type: "uint256"}, 32},
+		{ContractABIArgument{Type: "string"}, 32},
+		{ContractABIArgument{Type: "uint256[]"}, 32},
+		{ContractABIArgument{Type: "uint256[3]"}, 96},
+		{ContractABIArgument{Type: "string[3]"}, 32},
+		{ContractABIArgument{Type: "tuple", Components: []ContractABIArgument{{Type: "uint256"}, {Type: "bool"}}}, 64},
+		{ContractABIArgument{Type: "tuple[2]", Components: []ContractABIArgument{{Type: "uint256"}, {Type: "bool"}}}, 128},
+	}
+
+	for idx, test := range testMatrix {
+		size, err := test.testType.HeadSize()
+		assert.Nil(t, err)
+		assert.EqualValues(t, test.expectedSize, size, "Test index %d failed", idx)
+	}
+}
+
+func TestContractABIEvent_MatchesAnonymous(t *testing.T) {
+	staticEvent := ContractABIEvent{
+		Name:      "Static",
+		Anonymous: true,
+		Inputs: []ContractABIEventArgument{
+			{ContractABIArgument{Name: "id", Type: "uint256"}, true},
+			{ContractABIArgument{Name: "value", Type: "uint256"}, false},
+		},
+	}
+	dynamicEvent := ContractABIEvent{
+		Name:      "Dynamic",
+		Anonymous: true,
+		Inputs: []ContractABIEventArgument{
+			{ContractABIArgument{Name: "value", Type: "string"}, false},
+		},
+	}
+	oneTopic := []Hash{NewHash("0x1")}
+
+	assert.True(t, staticEvent.MatchesAnonymous(oneTopic, make([]byte, 32)))
+	assert.False(t, staticEvent.MatchesAnonymous(oneTopic, make([]byte, 64)))
+	assert.False(t, staticEvent.MatchesAnonymous([]Hash{}, make([]byte, 32)))
+	assert.True(t, dynamicEvent.MatchesAnonymous([]Hash{}, make([]byte, 96)))
+	assert.False(t, dynamicEvent.MatchesAnonymous([]Hash{}, make([]byte, 0)))
+	assert.False(t, dynamicEvent.MatchesAnonymous(oneTopic, make([]byte, 96)))
+}
+
+func TestParsedEvent_ParseAnonymousEvent(t *testing.T) {
+	abi := `[{"anonymous":true,"inputs":[{"indexed":true,"name":"_id","type":"uint256"},{"indexed":false,"name":"_value","type":"uint256"}],"name":"Marked","type":"event"}]`
+
+	event := &ParsedEvent{
+		RawEvent: &Event{
+			Topics: []Hash{NewHash("0x0000000000000000000000000000000000000000000000000000000000000005")},
+			Data:   NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8"),
+		},
+	}
+	assert.Nil(t, event.ParseEvent(abi))
+	assert.Equal(t, "event Marked(uint256 _id,uint256 _value) anonymous", event.Sig)
+	expected := `{"_id":5,"_value":1000}`
+	asJson, _ := json.Marshal(event.ParsedData)
+	assert.JSONEq(t, expected, string(asJson))
+
+	// the layout doesn't match, so the event is left undecoded
+	mismatched := &ParsedEvent{
+		RawEvent: &Event{
+			Topics: []Hash{NewHash("0x05"), NewHash("0x06")},
+			Data:   NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8"),
+		},
+	}
+	assert.Nil(t, mismatched.ParseEvent(abi))
+	assert.Equal(t, "", mismatched.Sig)
+}
+
+func TestParsedCall_ParseCall(t *testing.T) {
+	abi := `[{"inputs":[{"name":"account","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"type":"function"}]`
+	call := &ParsedCall{
+		RawCall: &InternalCall{
+			Input:  NewHexData("0x70a08231000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d"),
+			Output: NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8"),
+		},
+	}
+
+	assert.Nil(t, call.ParseCall(abi))
+	assert.Equal(t, "balanceOf(address account)", call.Sig)
+	assert.EqualValues(t, "70a08231", call.Func4Bytes)
+	asJson, _ := json.Marshal(call.ParsedData)
+	assert.JSONEq(t, `{"account":"0xed9d02e382b34818e88b88a309c7fe71e65f419d"}`, string(asJson))
+	asJson, _ = json.Marshal(call.ParsedOutput)
+	assert.JSONEq(t, `{"0":1000}`, string(asJson))
+}
+
+func TestParsedCall_ParseCallFailed(t *testing.T) {
+	abi := `[{"inputs":[{"name":"account","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"type":"function"}]`
+	call := &ParsedCall{
+		RawCall: &InternalCall{
+			Input:  NewHexData("0x70a08231000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d"),
+			Output: NewHexData("0x4e487b710000000000000000000000000000000000000000000000000000000000000011"),
+			Error:  "execution reverted",
+		},
+	}
+
+	assert.Nil(t, call.ParseCall(abi))
+	assert.Equal(t, "balanceOf(address account)", call.Sig)
+	assert.Nil(t, call.ParsedOutput)
+}
+
+func TestParsedCall_ParseCallTruncatedInput(t *testing.T) {
+	abi := `[{"inputs":[{"name":"account","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"type":"function"}]`
+	call := &ParsedCall{
+		RawCall: &InternalCall{Input: NewHexData("0x70a08231")},
+	}
+
+	assert.EqualError(t, call.ParseCall(abi), "call data does not match the function arguments")
+	assert.Equal(t, "", call.Sig)
+}
+
+func TestNewCallTree(t *testing.T) {
+	calls := []*InternalCall{
+		{Depth: 1, Parent: -1, Path: []int{0}},
+		{Depth: 2, Parent: 0, Path: []int{0, 0}},
+		{Depth: 3, Parent: 1, Path: []int{0, 0, 0}},
+		{Depth: 2, Parent: 0, Path: []int{0, 1}},
+		{Depth: 1, Parent: -1, Path: []int{1}},
+	}
+
+	tree := NewCallTree(calls)
+
+	assert.Len(t, tree, 2)
+	assert.Equal(t, calls[0], tree[0].RawCall)
+	assert.Len(t, tree[0].Calls, 2)
+	assert.Equal(t, calls[1], tree[0].Calls[0].RawCall)
+	assert.Equal(t, calls[2], tree[0].Calls[0].Calls[0].RawCall)
+	assert.Equal(t, calls[3], tree[0].Calls[1].RawCall)
+	assert.Equal(t, calls[4], tree[1].RawCall)
+	assert.Empty(t, tree[1].Calls)
+}
+
+func TestNewCallTree_LegacyCalls(t *testing.T) {
+	calls := []*InternalCall{{}, {}}
+
+	tree := NewCallTree(calls)
+
+	assert.Len(t, tree, 2)
+	assert.Empty(t, tree[0].Calls)
+}
+
+func TestInternalCall_Reverted(t *testing.T) {
+	calls := []*InternalCall{
+		{Depth: 1, Parent: -1, Error: "execution reverted"},
+		{Depth: 2, Parent: 0},
+		{Depth: 1, Parent: -1},
+		{Depth: 2, Parent: 2, Error: "out of gas"},
+		{Depth: 2, Parent: 2},
+	}
+
+	assert.True(t, calls[0].Reverted(calls))
+	assert.True(t, calls[1].Reverted(calls))
+	assert.False(t, calls[2].Reverted(calls))
+	assert.True(t, calls[3].Reverted(calls))
+	assert.False(t, calls[4].Reverted(calls))
+}
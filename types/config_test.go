@@ -91,7 +91,76 @@ func TestConfigFile(t *testing.T) {
 	err = ioutil.WriteFile(fileName, blob, 0644)
 	assert.Nil(t, err, "error writing new node info to file %s: %s", fileName, err)
 
+	config, err := ReadConfig(fileName)
+	assert.Nil(t, err, "error reading config file")
+	assert.Equal(t, ERC20BalanceOfSource, config.Tokens.ERC20BalanceSource)
+
+	tmpConfigData.Tokens.ERC20BalanceSource = "storage"
+
+	blob, err = toml.Marshal(tmpConfigData)
+	assert.Nil(t, err, "error marshalling test config file: %s", err)
+	err = ioutil.WriteFile(fileName, blob, 0644)
+	assert.Nil(t, err, "error writing new node info to file %s: %s", fileName, err)
+
+	_, err = ReadConfig(fileName)
+	assert.Error(t, err, "expected error, but got %v", err)
+
 	// test config.sample.toml is valid
 	_, err = ReadConfig("../config.sample.toml")
 	assert.Nil(t, err, "error reading sample config file")
 }
+
+func TestConfigFile_CustomTokens(t *testing.T) {
+	d, _ := ioutil.TempDir("", "test")
+	defer os.RemoveAll(d)
+	fileName := d + "/config.toml"
+
+	configData := `
+[tokens]
+standards = ["erc20", "points"]
+
+[[tokens.custom]]
+name = "points"
+events = [
+    { event = "Awarded(address indexed member, uint256 points)", to = "member", amount = "points" },
+    { event = "Moved(address indexed from, address indexed to, uint256 points)", from = "from", to = "to", amount = "points" }
+]
+`
+	err := ioutil.WriteFile(fileName, []byte(configData), 0644)
+	assert.Nil(t, err)
+
+	config, err := ReadConfig(fileName)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"erc20", "points"}, config.Tokens.Standards)
+	assert.Equal(t, []*CustomTokenConfig{
+		{
+			Name: "points",
+			Events: []*TokenEventConfig{
+				{Event: "Awarded(address indexed member, uint256 points)", To: "member", Amount: "points"},
+				{Event: "Moved(address indexed from, address indexed to, uint256 points)", From: "from", To: "to", Amount: "points"},
+			},
+		},
+	}, config.Tokens.Custom)
+}
+
+func TestValidate_CustomTokens(t *testing.T) {
+	validEvents := []*TokenEventConfig{{Event: "Awarded(address indexed member, uint256 points)", To: "member", Amount: "points"}}
+	tests := []struct {
+		name   string
+		custom []*CustomTokenConfig
+	}{
+		{"invalid name", []*CustomTokenConfig{{Name: "Points!", Events: validEvents}}},
+		{"reserved name", []*CustomTokenConfig{{Name: "token", Events: validEvents}}},
+		{"duplicate name", []*CustomTokenConfig{{Name: "points", Events: validEvents}, {Name: "points", Events: validEvents}}},
+		{"no events", []*CustomTokenConfig{{Name: "points"}}},
+		{"no amount", []*CustomTokenConfig{{Name: "points", Events: []*TokenEventConfig{{Event: "Awarded(address indexed member, uint256 points)", To: "member"}}}}},
+		{"no accounts", []*CustomTokenConfig{{Name: "points", Events: []*TokenEventConfig{{Event: "Awarded(address indexed member, uint256 points)", Amount: "points"}}}}},
+	}
+	for _, tc := range tests {
+		config := ReportingConfig{Tokens: TokenConfig{Custom: tc.custom}}
+		assert.Error(t, config.Validate(), tc.name)
+	}
+
+	config := ReportingConfig{Tokens: TokenConfig{Custom: []*CustomTokenConfig{{Name: "points_v2", Events: validEvents}}}}
+	assert.Nil(t, config.Validate())
+}
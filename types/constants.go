@@ -5,3 +5,40 @@ const (
 	InternalScope = "internal"
 	ExternalScope = "external"
 )
+
+const (
+	// ERC20BalanceOfSource reads each changed ERC20 balance by calling balanceOf on the token
+	ERC20BalanceOfSource = "balanceOf"
+	// ERC20EventsSource derives ERC20 balances from the Transfer events of the token
+	ERC20EventsSource = "events"
+)
+
+const (
+	// TransferDirectionIn selects the transfers to the counterparty of a transfer query
+	TransferDirectionIn = "in"
+	// TransferDirectionOut selects the transfers from the counterparty of a transfer query
+	TransferDirectionOut = "out"
+)
+
+const (
+	// TokenStandardERC20 marks the metadata of an ERC20 token
+	TokenStandardERC20 = "ERC20"
+	// TokenStandardERC721 marks the metadata of an ERC721 token
+	TokenStandardERC721 = "ERC721"
+)
+
+const (
+	// BackfillRunning marks a token backfill job that has blocks left to process
+	BackfillRunning = "running"
+	// BackfillCompleted marks a token backfill job that has caught up with the filtered blocks
+	BackfillCompleted = "completed"
+)
+
+const (
+	// LegacyTxType is the type of transactions from before EIP-2718
+	LegacyTxType = 0
+	// AccessListTxType is the type of EIP-2930 transactions, which carry an access list
+	AccessListTxType = 1
+	// DynamicFeeTxType is the type of EIP-1559 transactions, which carry fee caps instead of a gas price
+	DynamicFeeTxType = 2
+)
@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
 )
@@ -188,3 +189,71 @@ func (num *HexNumber) UnmarshalJSON(input []byte) error {
 func (num *HexNumber) ToUint64() uint64 {
 	return uint64(*num)
 }
+
+// BigInt is an arbitrary-precision integer, used for amounts such as wei values and gas
+// prices that do not fit in a uint64. It is decoded from a 0x-prefixed hex string, a decimal
+// string or a JSON number, and is always encoded as a decimal string.
+type BigInt big.Int
+
+func NewBigInt(value *big.Int) *BigInt {
+	return (*BigInt)(new(big.Int).Set(value))
+}
+
+func NewBigIntFromUint64(value uint64) *BigInt {
+	return (*BigInt)(new(big.Int).SetUint64(value))
+}
+
+// ParseBigInt parses a 0x-prefixed hex string or a decimal string
+func ParseBigInt(value string) (*BigInt, error) {
+	var (
+		out = new(big.Int)
+		ok  bool
+	)
+	if strings.HasPrefix(value, "0x") || strings.HasPrefix(value, "0X") {
+		_, ok = out.SetString(value[2:], 16)
+	} else {
+		_, ok = out.SetString(value, 10)
+	}
+	if !ok {
+		return nil, fmt.Errorf("invalid integer %q", value)
+	}
+	return (*BigInt)(out), nil
+}
+
+// ToBigInt returns a copy of the number as a big.Int, where a nil number is zero
+func (num *BigInt) ToBigInt() *big.Int {
+	if num == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set((*big.Int)(num))
+}
+
+func (num *BigInt) Sign() int {
+	return num.ToBigInt().Sign()
+}
+
+func (num *BigInt) Cmp(other *big.Int) int {
+	return num.ToBigInt().Cmp(other)
+}
+
+func (num *BigInt) String() string {
+	return num.ToBigInt().String()
+}
+
+func (num *BigInt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(num.String())
+}
+
+func (num *BigInt) UnmarshalJSON(input []byte) error {
+	var unwrapped string
+	if err := json.Unmarshal(input, &unwrapped); err != nil {
+		// values stored before amounts were arbitrary-precision are plain JSON numbers
+		unwrapped = string(input)
+	}
+	parsed, err := ParseBigInt(unwrapped)
+	if err != nil {
+		return err
+	}
+	(*big.Int)(num).Set((*big.Int)(parsed))
+	return nil
+}
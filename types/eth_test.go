@@ -2,6 +2,7 @@ package types
 
 import (
 	"encoding/json"
+	"math/big"
 	"strings"
 	"testing"
 
@@ -121,3 +122,76 @@ func TestHexNumber_UnmarshalJSON(t *testing.T) {
 	assert.Nil(t, err)
 	assert.EqualValues(t, 16, num)
 }
+
+func TestBigInt_MarshalJSON(t *testing.T) {
+	num, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+
+	result, err := json.Marshal(NewBigInt(num))
+
+	assert.Nil(t, err)
+	assert.Equal(t, `"123456789012345678901234567890"`, string(result))
+}
+
+func TestBigInt_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"0x3635c9adc5dea00000"`, "1000000000000000000000"},
+		{`"1000000000000000000000"`, "1000000000000000000000"},
+		{`18446744073709551615`, "18446744073709551615"},
+		{`"0x0"`, "0"},
+	}
+	for _, test := range tests {
+		var num BigInt
+		err := json.Unmarshal([]byte(test.input), &num)
+
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, num.String())
+	}
+}
+
+func TestBigInt_UnmarshalJSON_Invalid(t *testing.T) {
+	var num BigInt
+	err := json.Unmarshal([]byte(`"0xzz"`), &num)
+
+	assert.EqualError(t, err, `invalid integer "0xzz"`)
+}
+
+func TestRawTransaction_UnmarshalJSON(t *testing.T) {
+	rawTx := `{
+		"type": "0x2",
+		"chainId": "0xa",
+		"gasPrice": "0x59682f00",
+		"maxFeePerGas": "0x77359400",
+		"maxPriorityFeePerGas": "0x1dcd6500",
+		"accessList": [{
+			"address": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34",
+			"storageKeys": ["0x0000000000000000000000000000000000000000000000000000000000000001"]
+		}]
+	}`
+
+	var tx RawTransaction
+	err := json.Unmarshal([]byte(rawTx), &tx)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, DynamicFeeTxType, tx.Type)
+	assert.Equal(t, "10", tx.ChainID.String())
+	assert.Equal(t, "1500000000", tx.GasPrice.String())
+	assert.Equal(t, "2000000000", tx.MaxFeePerGas.String())
+	assert.Equal(t, "500000000", tx.MaxPriorityFeePerGas.String())
+	assert.Equal(t, []AccessTuple{{
+		Address:     NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34"),
+		StorageKeys: []Hash{NewHash("0x0000000000000000000000000000000000000000000000000000000000000001")},
+	}}, tx.AccessList)
+}
+
+func TestRawTransaction_UnmarshalJSON_Legacy(t *testing.T) {
+	var tx RawTransaction
+	err := json.Unmarshal([]byte(`{"gasPrice": "0x0"}`), &tx)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, LegacyTxType, tx.Type)
+	assert.Nil(t, tx.MaxFeePerGas)
+	assert.Nil(t, tx.AccessList)
+}
@@ -0,0 +1,56 @@
+package types
+
+import "errors"
+
+// EventRegistry indexes the events of many ABIs by their signature topic, so that events
+// can be decoded without the ABI of the contract that emitted them, such as those emitted
+// by libraries or through proxies.
+type EventRegistry struct {
+	bySignature map[string][]ContractABIEvent
+	anonymous   []ContractABIEvent
+	seen        map[string]bool
+}
+
+func NewEventRegistry() *EventRegistry {
+	return &EventRegistry{
+		bySignature: make(map[string][]ContractABIEvent),
+		anonymous:   make([]ContractABIEvent, 0),
+		seen:        make(map[string]bool),
+	}
+}
+
+// AddABI adds all the events of the ABI to the registry. Events already added from another
+// ABI, with the same argument names, are ignored.
+func (r *EventRegistry) AddABI(rawABI string) error {
+	structure, err := NewABIStructureFromJSON(rawABI)
+	if err != nil {
+		return errors.New("could not unmarshal ABI")
+	}
+	for _, ev := range structure.ToInternalABI().Events {
+		key := ev.String()
+		if ev.Anonymous {
+			key += " anonymous"
+		}
+		if r.seen[key] {
+			continue
+		}
+		r.seen[key] = true
+
+		if ev.Anonymous {
+			r.anonymous = append(r.anonymous, ev)
+		} else {
+			r.bySignature[ev.Signature()] = append(r.bySignature[ev.Signature()], ev)
+		}
+	}
+	return nil
+}
+
+// Candidates returns the events that may have produced the given topics, being the events
+// with a matching signature followed by all anonymous events
+func (r *EventRegistry) Candidates(topics []Hash) []ContractABIEvent {
+	candidates := make([]ContractABIEvent, 0)
+	if len(topics) > 0 {
+		candidates = append(candidates, r.bySignature[string(topics[0])]...)
+	}
+	return append(candidates, r.anonymous...)
+}
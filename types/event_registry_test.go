@@ -0,0 +1,54 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	erc20TransferABI  = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"_from","type":"address"},{"indexed":true,"name":"_to","type":"address"},{"indexed":false,"name":"_value","type":"uint256"}],"name":"Transfer","type":"event"}]`
+	erc721TransferABI = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"_from","type":"address"},{"indexed":true,"name":"_to","type":"address"},{"indexed":true,"name":"_tokenId","type":"uint256"}],"name":"Transfer","type":"event"}]`
+	transferTopic     = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+)
+
+func TestEventRegistry_AddABI(t *testing.T) {
+	registry := NewEventRegistry()
+
+	assert.EqualError(t, registry.AddABI("hello"), "could not unmarshal ABI")
+	assert.Nil(t, registry.AddABI(erc20TransferABI))
+	assert.Nil(t, registry.AddABI(erc20TransferABI))
+	assert.Nil(t, registry.AddABI(erc721TransferABI))
+
+	candidates := registry.Candidates([]Hash{NewHash(transferTopic)})
+	assert.Len(t, candidates, 2)
+	assert.Len(t, registry.Candidates([]Hash{NewHash("0x1")}), 0)
+	assert.Len(t, registry.Candidates([]Hash{}), 0)
+}
+
+func TestParsedEvent_ParseEventWithRegistry(t *testing.T) {
+	registry := NewEventRegistry()
+	assert.Nil(t, registry.AddABI(erc20TransferABI))
+	assert.Nil(t, registry.AddABI(erc721TransferABI))
+
+	from := NewHash("0x000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d")
+	to := NewHash("0x000000000000000000000000ca843569e3427144cead5e4d5999a3d0ccf92b8e")
+
+	// both events share a signature, so the one matching the indexed arguments is used
+	erc721Event := &ParsedEvent{
+		RawEvent: &Event{Topics: []Hash{NewHash(transferTopic), from, to, NewHash("0x0000000000000000000000000000000000000000000000000000000000000003")}},
+	}
+	assert.Nil(t, erc721Event.ParseEventWithRegistry(registry))
+	assert.Equal(t, "event Transfer(address _from,address _to,uint256 _tokenId)", erc721Event.Sig)
+	assert.EqualValues(t, 3, erc721Event.ParsedData["_tokenId"].(interface{ Int64() int64 }).Int64())
+
+	erc20Event := &ParsedEvent{
+		RawEvent: &Event{
+			Topics: []Hash{NewHash(transferTopic), from, to},
+			Data:   NewHexData("0x0000000000000000000000000000000000000000000000000000000000000064"),
+		},
+	}
+	assert.Nil(t, erc20Event.ParseEventWithRegistry(registry))
+	assert.Equal(t, "event Transfer(address _from,address _to,uint256 _value)", erc20Event.Sig)
+	assert.Equal(t, "0xca843569e3427144cead5e4d5999a3d0ccf92b8e", erc20Event.ParsedData["_to"])
+}
@@ -0,0 +1,41 @@
+package types
+
+import "errors"
+
+// FunctionRegistry indexes the functions of many ABIs by their selector, so that calls can
+// be decoded without the ABI of the contract that was called, such as calls to libraries or
+// to contracts that were never registered.
+type FunctionRegistry struct {
+	bySelector map[string][]ContractABIFunction
+	seen       map[string]bool
+}
+
+func NewFunctionRegistry() *FunctionRegistry {
+	return &FunctionRegistry{
+		bySelector: make(map[string][]ContractABIFunction),
+		seen:       make(map[string]bool),
+	}
+}
+
+// AddABI adds all the functions of the ABI to the registry. Functions already added from
+// another ABI, with the same argument names, are ignored.
+func (r *FunctionRegistry) AddABI(rawABI string) error {
+	structure, err := NewABIStructureFromJSON(rawABI)
+	if err != nil {
+		return errors.New("could not unmarshal ABI")
+	}
+	for _, function := range structure.ToInternalABI().Functions {
+		key := function.String()
+		if r.seen[key] {
+			continue
+		}
+		r.seen[key] = true
+		r.bySelector[function.Signature()] = append(r.bySelector[function.Signature()], function)
+	}
+	return nil
+}
+
+// Candidates returns the functions with the given selector, as hex without a 0x prefix
+func (r *FunctionRegistry) Candidates(selector HexData) []ContractABIFunction {
+	return append([]ContractABIFunction{}, r.bySelector[string(selector)]...)
+}
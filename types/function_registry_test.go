@@ -0,0 +1,48 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	erc20BalanceOfABI  = `[{"inputs":[{"name":"account","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"type":"function"}]`
+	erc721BalanceOfABI = `[{"inputs":[{"name":"owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"balance","type":"uint256"}],"type":"function"}]`
+)
+
+func TestFunctionRegistry_AddABI(t *testing.T) {
+	registry := NewFunctionRegistry()
+
+	assert.EqualError(t, registry.AddABI("hello"), "could not unmarshal ABI")
+	assert.Nil(t, registry.AddABI(erc20BalanceOfABI))
+	assert.Nil(t, registry.AddABI(erc20BalanceOfABI))
+	assert.Nil(t, registry.AddABI(erc721BalanceOfABI))
+
+	assert.Len(t, registry.Candidates("70a08231"), 2)
+	assert.Len(t, registry.Candidates("12345678"), 0)
+}
+
+func TestParsedCall_ParseCallWithRegistry(t *testing.T) {
+	registry := NewFunctionRegistry()
+	assert.Nil(t, registry.AddABI(erc721BalanceOfABI))
+	assert.Nil(t, registry.AddABI(erc20BalanceOfABI))
+
+	// the first function added with the selector is used
+	call := &ParsedCall{
+		RawCall: &InternalCall{
+			Input:  NewHexData("0x70a08231000000000000000000000000ed9d02e382b34818e88b88a309c7fe71e65f419d"),
+			Output: NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8"),
+		},
+	}
+	assert.Nil(t, call.ParseCallWithRegistry(registry))
+	assert.Equal(t, "balanceOf(address owner)", call.Sig)
+	asJson, _ := json.Marshal(call.ParsedOutput)
+	assert.JSONEq(t, `{"balance":1000}`, string(asJson))
+
+	unknown := &ParsedCall{RawCall: &InternalCall{Input: NewHexData("0x12345678")}}
+	assert.Nil(t, unknown.ParseCallWithRegistry(registry))
+	assert.Equal(t, "", unknown.Sig)
+	assert.EqualValues(t, "12345678", unknown.Func4Bytes)
+}
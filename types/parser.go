@@ -13,6 +13,8 @@ type ParsedTransaction struct {
 	Func4Bytes     HexData                `json:"func4Bytes"`
 	ParsedData     map[string]interface{} `json:"parsedData"`
 	ParsedEvents   []*ParsedEvent         `json:"parsedEvents"`
+	ParsedCalls    []*ParsedCall          `json:"parsedCalls,omitempty"`
+	RevertError    *RevertError           `json:"revertError"`
 	RawTransaction *Transaction           `json:"rawTransaction"`
 }
 
@@ -30,6 +32,10 @@ func (ptx *ParsedTransaction) ParseTransaction(rawABI string) error {
 
 	log.Debug("Parse transaction", "tx", ptx.RawTransaction.Hash.Hex())
 
+	if !ptx.RawTransaction.Status {
+		ptx.RevertError = DecodeRevert(ptx.RawTransaction.RevertData.AsBytes(), internalAbi.Errors)
+	}
+
 	// set defaults
 	var data []byte
 	if len(ptx.RawTransaction.PrivateData) > 0 {
@@ -88,7 +94,7 @@ type ParsedEvent struct {
 }
 
 func (pe *ParsedEvent) ParseEvent(rawABI string) error {
-	if pe.RawEvent == nil || len(pe.RawEvent.Topics) == 0 {
+	if pe.RawEvent == nil {
 		return errors.New("event is nil or invalid")
 	}
 
@@ -99,16 +105,190 @@ func (pe *ParsedEvent) ParseEvent(rawABI string) error {
 	}
 	internalAbi := structure.ToInternalABI()
 
-	log.Debug("Parse event", "event", pe.RawEvent.Topics[0].Hex())
-	for _, ev := range internalAbi.Events {
-		if "0x"+ev.Signature() == pe.RawEvent.Topics[0].String() {
-			pe.Sig = "event " + ev.String()
-			result, err := ev.Parse(pe.RawEvent.Data.AsBytes())
-			if err != nil {
-				return err
+	return pe.parseWithEvents(internalAbi.Events)
+}
+
+// ParseEventWithRegistry decodes the event using any matching event known to the registry,
+// for events whose emitting contract has no ABI or does not declare the event
+func (pe *ParsedEvent) ParseEventWithRegistry(registry *EventRegistry) error {
+	if pe.RawEvent == nil {
+		return errors.New("event is nil or invalid")
+	}
+	return pe.parseWithEvents(registry.Candidates(pe.RawEvent.Topics))
+}
+
+// parseWithEvents decodes the event using the first event definition with a matching
+// signature topic that can decode it, falling back to the first anonymous event whose
+// argument layout fits the topics and data. An event that its matching definitions can't
+// decode, such as one with fewer topics than indexed arguments, is left undecoded.
+func (pe *ParsedEvent) parseWithEvents(events []ContractABIEvent) error {
+	topics := pe.RawEvent.Topics
+	if len(topics) > 0 {
+		log.Debug("Parse event", "event", topics[0].Hex())
+		var firstErr error
+		for _, ev := range events {
+			if ev.Anonymous || "0x"+ev.Signature() != topics[0].String() {
+				continue
+			}
+			// events with the same signature may differ in which arguments are indexed
+			err := pe.decode(ev, topics[1:])
+			if err == nil {
+				return nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if firstErr != nil {
+			log.Warn("Could not decode event", "event", topics[0].Hex(), "err", firstErr)
+			return nil
+		}
+	}
+
+	data := pe.RawEvent.Data.AsBytes()
+	for _, ev := range events {
+		if ev.Anonymous && ev.MatchesAnonymous(topics, data) {
+			if err := pe.decode(ev, topics); err == nil {
+				return nil
 			}
-			pe.ParsedData = result
 		}
 	}
 	return nil
 }
+
+// decode parses the event data and the given indexed topics using the event definition.
+// The data may not have been produced by this event when matching anonymous events, so a
+// panic from reading outside of the data is returned as an error.
+func (pe *ParsedEvent) decode(ev ContractABIEvent, topics []Hash) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("event data does not match the event arguments")
+		}
+	}()
+
+	result, err := ev.Parse(pe.RawEvent.Data.AsBytes())
+	if err != nil {
+		return err
+	}
+	indexed, err := ev.ParseTopics(topics)
+	if err != nil {
+		return err
+	}
+	for name, value := range indexed {
+		result[name] = value
+	}
+	pe.Sig = "event " + ev.String()
+	if ev.Anonymous {
+		pe.Sig += " anonymous"
+	}
+	pe.ParsedData = result
+	return nil
+}
+
+// ParsedCall is an internal call decoded with the ABI of the called contract, along with the
+// calls it made in turn
+type ParsedCall struct {
+	Sig          string                 `json:"callSig"`
+	Func4Bytes   HexData                `json:"func4Bytes"`
+	ParsedData   map[string]interface{} `json:"parsedData"`
+	ParsedOutput map[string]interface{} `json:"parsedOutput"`
+	RawCall      *InternalCall          `json:"rawCall"`
+	Calls        []*ParsedCall          `json:"calls"`
+}
+
+// ParseCall decodes the arguments of the call, and its return values if it succeeded, using
+// the function of the ABI matching the call's selector
+func (pc *ParsedCall) ParseCall(rawABI string) error {
+	if pc.RawCall == nil {
+		return errors.New("call is nil or invalid")
+	}
+
+	structure, err := NewABIStructureFromJSON(rawABI)
+	if err != nil {
+		log.Error("Could not unmarshal ABI", "abi", rawABI)
+		return errors.New("could not unmarshal ABI")
+	}
+	return pc.parseWithFunctions(structure.ToInternalABI().Functions)
+}
+
+// ParseCallWithRegistry decodes the call using any matching function known to the registry,
+// for calls to contracts that have no ABI or do not declare the function
+func (pc *ParsedCall) ParseCallWithRegistry(registry *FunctionRegistry) error {
+	if pc.RawCall == nil {
+		return errors.New("call is nil or invalid")
+	}
+	input := pc.RawCall.Input.AsBytes()
+	if len(input) < 4 {
+		return nil
+	}
+	return pc.parseWithFunctions(registry.Candidates(HexData(hex.EncodeToString(input[:4]))))
+}
+
+// parseWithFunctions decodes the call using the first function with a matching selector
+// that can decode it
+func (pc *ParsedCall) parseWithFunctions(functions []ContractABIFunction) error {
+	input := pc.RawCall.Input.AsBytes()
+	if len(input) < 4 {
+		// a plain transfer of value, or a contract creation
+		return nil
+	}
+	pc.Func4Bytes = HexData(hex.EncodeToString(input[:4]))
+	var firstErr error
+	for _, method := range functions {
+		if method.Signature() != string(pc.Func4Bytes) {
+			continue
+		}
+		// different functions may share a selector, so the next is tried if one does not fit
+		err := pc.decode(method, input[4:])
+		if err == nil {
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// decode parses the call input and output using the function definition. The call may not
+// have been made with the arguments the function declares, so a panic from reading outside
+// of the data is returned as an error.
+func (pc *ParsedCall) decode(method ContractABIFunction, input []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("call data does not match the function arguments")
+		}
+	}()
+
+	parsedData, err := method.Parse(input)
+	if err != nil {
+		return err
+	}
+	var parsedOutput map[string]interface{}
+	if output := pc.RawCall.Output.AsBytes(); pc.RawCall.Error == "" && len(output) > 0 {
+		if parsedOutput, err = method.ParseOutput(output); err != nil {
+			return err
+		}
+	}
+	pc.Sig = method.String()
+	pc.ParsedData = parsedData
+	pc.ParsedOutput = parsedOutput
+	return nil
+}
+
+// NewCallTree arranges the internal calls of a transaction into the tree of calls made by
+// the transaction's callee. Calls recorded before the call tree was kept are all placed at
+// the top of the tree.
+func NewCallTree(calls []*InternalCall) []*ParsedCall {
+	nodes := make([]*ParsedCall, len(calls))
+	roots := []*ParsedCall{}
+	for i, call := range calls {
+		nodes[i] = &ParsedCall{RawCall: call, Calls: []*ParsedCall{}}
+		if call.Depth > 1 && call.Parent >= 0 && call.Parent < i {
+			nodes[call.Parent].Calls = append(nodes[call.Parent].Calls, nodes[i])
+		} else {
+			roots = append(roots, nodes[i])
+		}
+	}
+	return roots
+}
@@ -1,14 +1,20 @@
 package types
 
 import (
+	"errors"
 	"math/big"
 )
 
+// maxAmount is the largest amount of wei a transaction can have, which is the largest uint256
+var maxAmount = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
 var defaultQueryOptions = &QueryOptions{
 	BeginBlockNumber: big.NewInt(0),
 	EndBlockNumber:   big.NewInt(-1),
 	BeginTimestamp:   big.NewInt(0),
 	EndTimestamp:     big.NewInt(-1),
+	BeginValue:       NewBigInt(big.NewInt(0)),
+	EndValue:         NewBigInt(big.NewInt(-1)),
 	PageSize:         10,
 	PageNumber:       0,
 }
@@ -27,6 +33,11 @@ type QueryOptions struct {
 	BeginTimestamp *big.Int `json:"beginTimestamp"`
 	EndTimestamp   *big.Int `json:"endTimestamp"`
 
+	// BeginValue and EndValue bound the value of transactions in wei, and do not apply to
+	// events. They are decimal strings in JSON, as values may exceed the precision of a number.
+	BeginValue *BigInt `json:"beginValue"`
+	EndValue   *BigInt `json:"endValue"`
+
 	PageSize   int `json:"pageSize"`
 	PageNumber int `json:"pageNumber"`
 }
@@ -44,6 +55,12 @@ func (opts *QueryOptions) SetDefaults() {
 	if opts.EndTimestamp == nil {
 		opts.EndTimestamp = defaultQueryOptions.EndTimestamp
 	}
+	if opts.BeginValue == nil {
+		opts.BeginValue = defaultQueryOptions.BeginValue
+	}
+	if opts.EndValue == nil {
+		opts.EndValue = defaultQueryOptions.EndValue
+	}
 	if opts.PageSize == 0 {
 		opts.PageSize = defaultQueryOptions.PageSize
 	}
@@ -52,6 +69,40 @@ func (opts *QueryOptions) SetDefaults() {
 	}
 }
 
+// HasValueRange checks if the value of transactions is bounded by the options
+func (opts *QueryOptions) HasValueRange() bool {
+	return (opts.BeginValue != nil && opts.BeginValue.Sign() > 0) ||
+		(opts.EndValue != nil && opts.EndValue.Cmp(big.NewInt(-1)) != 0)
+}
+
+// ValidateValueRange checks that the value bounds are amounts a transaction can have, from zero
+// to the largest uint256, other than an end of -1 for an unbounded range
+func (opts *QueryOptions) ValidateValueRange() error {
+	if opts.BeginValue != nil && !isAmount(opts.BeginValue) {
+		return errors.New("beginValue must be between 0 and 2^256-1")
+	}
+	if opts.EndValue != nil && opts.EndValue.Cmp(big.NewInt(-1)) != 0 && !isAmount(opts.EndValue) {
+		return errors.New("endValue must be between 0 and 2^256-1, or -1 for no limit")
+	}
+	return nil
+}
+
+func isAmount(value *BigInt) bool {
+	return value.Sign() >= 0 && value.Cmp(maxAmount) <= 0
+}
+
+// ValueInRange checks if the value is within the inclusive value range of the options,
+// where an end of -1 is unbounded
+func (opts *QueryOptions) ValueInRange(value *BigInt) bool {
+	if opts.BeginValue != nil && value.Cmp(opts.BeginValue.ToBigInt()) < 0 {
+		return false
+	}
+	if opts.EndValue != nil && opts.EndValue.Cmp(big.NewInt(-1)) != 0 && value.Cmp(opts.EndValue.ToBigInt()) > 0 {
+		return false
+	}
+	return true
+}
+
 type PageOptions struct {
 	BeginBlockNumber *big.Int `json:"beginBlockNumber"`
 	EndBlockNumber   *big.Int `json:"endBlockNumber"`
@@ -0,0 +1,24 @@
+package types
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryOptions_ValidateValueRange(t *testing.T) {
+	maxValue, _ := ParseBigInt("0x" + strings.Repeat("f", 64))
+	tooLarge := NewBigInt(new(big.Int).Add(maxValue.ToBigInt(), big.NewInt(1)))
+
+	options := &QueryOptions{}
+	options.SetDefaults()
+	assert.Nil(t, options.ValidateValueRange())
+	assert.Nil(t, (&QueryOptions{BeginValue: maxValue, EndValue: maxValue}).ValidateValueRange())
+
+	assert.EqualError(t, (&QueryOptions{BeginValue: NewBigInt(big.NewInt(-1))}).ValidateValueRange(), "beginValue must be between 0 and 2^256-1")
+	assert.EqualError(t, (&QueryOptions{BeginValue: tooLarge}).ValidateValueRange(), "beginValue must be between 0 and 2^256-1")
+	assert.EqualError(t, (&QueryOptions{EndValue: NewBigInt(big.NewInt(-2))}).ValidateValueRange(), "endValue must be between 0 and 2^256-1, or -1 for no limit")
+	assert.EqualError(t, (&QueryOptions{EndValue: tooLarge}).ValidateValueRange(), "endValue must be between 0 and 2^256-1, or -1 for no limit")
+}
@@ -1,7 +1,11 @@
 package types
 
 import (
+	"errors"
+	"fmt"
 	"math/big"
+	"strconv"
+	"strings"
 )
 
 var defaultTokenQueryOptions = &TokenQueryOptions{
@@ -36,3 +40,126 @@ func (opts *TokenQueryOptions) SetDefaults() {
 		opts.PageNumber = defaultTokenQueryOptions.PageNumber
 	}
 }
+
+// MaxTransferPageSize is the most transfers returned in a page, which larger page sizes are
+// reduced to
+const MaxTransferPageSize = 1000
+
+var defaultTokenTransferQueryOptions = &TokenTransferQueryOptions{
+	BeginBlockNumber: big.NewInt(0),
+	EndBlockNumber:   big.NewInt(-1),
+	BeginTimestamp:   big.NewInt(0),
+	EndTimestamp:     big.NewInt(-1),
+
+	PageSize: 10,
+}
+
+// TokenTransferQueryOptions pages through token transfers in the order they took place.
+// Instead of page numbers, each page starts after the cursor of the last transfer of the
+// previous page, so pages stay consistent while new transfers are indexed.
+type TokenTransferQueryOptions struct {
+	BeginBlockNumber *big.Int `json:"beginBlockNumber"`
+	EndBlockNumber   *big.Int `json:"endBlockNumber"`
+
+	BeginTimestamp *big.Int `json:"beginTimestamp"`
+	EndTimestamp   *big.Int `json:"endTimestamp"`
+
+	After    string `json:"after"`
+	PageSize int    `json:"pageSize"`
+}
+
+func (opts *TokenTransferQueryOptions) SetDefaults() {
+	if opts.BeginBlockNumber == nil {
+		opts.BeginBlockNumber = defaultTokenTransferQueryOptions.BeginBlockNumber
+	}
+	if opts.EndBlockNumber == nil {
+		opts.EndBlockNumber = defaultTokenTransferQueryOptions.EndBlockNumber
+	}
+	if opts.BeginTimestamp == nil {
+		opts.BeginTimestamp = defaultTokenTransferQueryOptions.BeginTimestamp
+	}
+	if opts.EndTimestamp == nil {
+		opts.EndTimestamp = defaultTokenTransferQueryOptions.EndTimestamp
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = defaultTokenTransferQueryOptions.PageSize
+	}
+	if opts.PageSize > MaxTransferPageSize {
+		opts.PageSize = MaxTransferPageSize
+	}
+}
+
+// Cursor returns the block number and log index of the transfer the page starts after,
+// which are both zero with ok set to false if the page starts from the beginning
+func (opts *TokenTransferQueryOptions) Cursor() (blockNumber uint64, logIndex uint64, ok bool, err error) {
+	if opts.After == "" {
+		return 0, 0, false, nil
+	}
+	parts := strings.Split(opts.After, "-")
+	if len(parts) != 2 {
+		return 0, 0, false, errors.New(`invalid "after" cursor`)
+	}
+	if blockNumber, err = strconv.ParseUint(parts[0], 10, 64); err != nil {
+		return 0, 0, false, errors.New(`invalid "after" cursor`)
+	}
+	if logIndex, err = strconv.ParseUint(parts[1], 10, 64); err != nil {
+		return 0, 0, false, errors.New(`invalid "after" cursor`)
+	}
+	return blockNumber, logIndex, true, nil
+}
+
+// TransferCursor returns the cursor that starts a page after the given transfer
+func TransferCursor(transfer TokenTransfer) string {
+	return fmt.Sprintf("%d-%d", transfer.BlockNumber, transfer.LogIndex)
+}
+
+// NativeTransferCursor returns the cursor that starts a page after the given native transfer
+func NativeTransferCursor(transfer NativeTransfer) string {
+	return fmt.Sprintf("%d-%d", transfer.BlockNumber, transfer.Index)
+}
+
+// MaxAccountTokenPageSize is the most tokens of an account returned in a page, which larger
+// page sizes are reduced to
+const MaxAccountTokenPageSize = 1000
+
+// AccountTokenQueryOptions pages through the ERC721 tokens an account holds, ordered by
+// contract and then token ID. Each page starts after the cursor of the last token of the
+// previous page.
+type AccountTokenQueryOptions struct {
+	After    string `json:"after"`
+	PageSize int    `json:"pageSize"`
+}
+
+func (opts *AccountTokenQueryOptions) SetDefaults() {
+	if opts.PageSize <= 0 {
+		opts.PageSize = defaultTokenTransferQueryOptions.PageSize
+	}
+	if opts.PageSize > MaxAccountTokenPageSize {
+		opts.PageSize = MaxAccountTokenPageSize
+	}
+}
+
+// Cursor returns the contract and token ID of the token the page starts after, with ok set
+// to false if the page starts from the beginning
+func (opts *AccountTokenQueryOptions) Cursor() (contract Address, tokenId *big.Int, ok bool, err error) {
+	if opts.After == "" {
+		return "", nil, false, nil
+	}
+	parts := strings.Split(opts.After, "-")
+	if len(parts) != 2 {
+		return "", nil, false, errors.New(`invalid "after" cursor`)
+	}
+	if decoded, err := fromHex(parts[0]); err != nil || len(decoded) != 20 {
+		return "", nil, false, errors.New(`invalid "after" cursor`)
+	}
+	tokenId, success := new(big.Int).SetString(parts[1], 10)
+	if !success || tokenId.Sign() < 0 {
+		return "", nil, false, errors.New(`invalid "after" cursor`)
+	}
+	return NewAddress(parts[0]), tokenId, true, nil
+}
+
+// ERC721TokenCursor returns the cursor that starts a page after the given token
+func ERC721TokenCursor(token ERC721Token) string {
+	return fmt.Sprintf("%s-%s", token.Contract.String(), token.Token)
+}
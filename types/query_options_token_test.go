@@ -0,0 +1,55 @@
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenTransferQueryOptions_SetDefaults_PageSize(t *testing.T) {
+	options := &TokenTransferQueryOptions{}
+	options.SetDefaults()
+	assert.Equal(t, 10, options.PageSize)
+
+	options = &TokenTransferQueryOptions{PageSize: -5}
+	options.SetDefaults()
+	assert.Equal(t, 10, options.PageSize)
+
+	options = &TokenTransferQueryOptions{PageSize: MaxTransferPageSize}
+	options.SetDefaults()
+	assert.Equal(t, MaxTransferPageSize, options.PageSize)
+
+	options = &TokenTransferQueryOptions{PageSize: MaxTransferPageSize + 1}
+	options.SetDefaults()
+	assert.Equal(t, MaxTransferPageSize, options.PageSize)
+}
+
+func TestAccountTokenQueryOptions_SetDefaults_PageSize(t *testing.T) {
+	options := &AccountTokenQueryOptions{}
+	options.SetDefaults()
+	assert.Equal(t, 10, options.PageSize)
+
+	options = &AccountTokenQueryOptions{PageSize: MaxAccountTokenPageSize + 1}
+	options.SetDefaults()
+	assert.Equal(t, MaxAccountTokenPageSize, options.PageSize)
+}
+
+func TestAccountTokenQueryOptions_Cursor(t *testing.T) {
+	token := ERC721Token{Contract: NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34"), Token: "12"}
+	options := &AccountTokenQueryOptions{After: ERC721TokenCursor(token)}
+	contract, tokenId, ok, err := options.Cursor()
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, token.Contract, contract)
+	assert.Equal(t, big.NewInt(12), tokenId)
+
+	_, _, ok, err = (&AccountTokenQueryOptions{}).Cursor()
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	for _, after := range []string{"12", "0x1932c48b2bf8102ba33b4a6b545c32236e342f34", "0x1932-12", "0x1932c48b2bf8102ba33b4a6b545c32236e342f34--1"} {
+		_, _, _, err = (&AccountTokenQueryOptions{After: after}).Cursor()
+		assert.EqualError(t, err, `invalid "after" cursor`, after)
+	}
+}
@@ -0,0 +1,114 @@
+package types
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+var (
+	// errorStringSelector is the selector of Error(string), which Solidity uses to encode the
+	// reason given to require and revert
+	errorStringSelector, _ = hex.DecodeString("08c379a0")
+	// panicSelector is the selector of Panic(uint256), which Solidity uses to encode failed
+	// assertions and other runtime errors
+	panicSelector, _ = hex.DecodeString("4e487b71")
+)
+
+// panicReasons describe the panic codes defined by Solidity
+var panicReasons = map[uint64]string{
+	0x00: "generic compiler panic",
+	0x01: "assertion failed",
+	0x11: "arithmetic underflow or overflow",
+	0x12: "division or modulo by zero",
+	0x21: "invalid enum value",
+	0x22: "invalid storage byte array encoding",
+	0x31: "pop on empty array",
+	0x32: "array index out of bounds",
+	0x41: "out of memory",
+	0x51: "call to uninitialised internal function",
+}
+
+// RevertError is the decoded data that a call reverted with. The reason is the message of
+// an Error(string), the description of a Panic(uint256) code, or the name of a custom error.
+type RevertError struct {
+	Sig        string                 `json:"errorSig"`
+	Reason     string                 `json:"reason"`
+	ParsedData map[string]interface{} `json:"parsedData"`
+}
+
+// DecodeRevert decodes the data a call reverted with as an Error(string), a Panic(uint256)
+// or one of the given custom errors, returning nil if it is none of them
+func DecodeRevert(data []byte, customErrors []ContractABIFunction) *RevertError {
+	if len(data) < 4 {
+		return nil
+	}
+	selector, args := data[:4], data[4:]
+	switch {
+	case bytes.Equal(selector, errorStringSelector):
+		reason, ok := decodeString(args)
+		if !ok {
+			return nil
+		}
+		return &RevertError{Sig: "Error(string)", Reason: reason, ParsedData: map[string]interface{}{"reason": reason}}
+	case bytes.Equal(selector, panicSelector):
+		if len(args) < 32 {
+			return nil
+		}
+		code := new(big.Int).SetBytes(args[:32])
+		reason, ok := panicReasons[code.Uint64()]
+		if !ok || !code.IsUint64() {
+			reason = fmt.Sprintf("unknown panic code 0x%x", code)
+		}
+		return &RevertError{Sig: "Panic(uint256)", Reason: reason, ParsedData: map[string]interface{}{"code": code}}
+	}
+
+	for _, customError := range customErrors {
+		if customError.Signature() != hex.EncodeToString(selector) {
+			continue
+		}
+		if parsed, err := parseCustomError(customError, args); err == nil {
+			return &RevertError{Sig: customError.String(), Reason: customError.Name, ParsedData: parsed}
+		}
+	}
+	return nil
+}
+
+// DecodeRevertReason returns the reason that a call reverted with, or an empty string if
+// the data is not an Error(string) or a Panic(uint256)
+func DecodeRevertReason(data []byte) string {
+	if revertError := DecodeRevert(data, nil); revertError != nil {
+		return revertError.Reason
+	}
+	return ""
+}
+
+// parseCustomError decodes the arguments of a custom error. The data may have been produced
+// by a different error with the same selector, so a panic from reading outside of the data
+// is returned as an error.
+func parseCustomError(customError ContractABIFunction, data []byte) (parsed map[string]interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("revert data does not match the arguments of %s", customError.Name)
+		}
+	}()
+	return customError.Parse(data)
+}
+
+// decodeString decodes an ABI encoded string that is the only argument of its data
+func decodeString(data []byte) (string, bool) {
+	if len(data) < 32 {
+		return "", false
+	}
+	offset := new(big.Int).SetBytes(data[:32])
+	if !offset.IsUint64() || offset.Uint64() > uint64(len(data))-32 {
+		return "", false
+	}
+	start := offset.Uint64() + 32
+	length := new(big.Int).SetBytes(data[start-32 : start])
+	if !length.IsUint64() || length.Uint64() > uint64(len(data))-start {
+		return "", false
+	}
+	return string(data[start : start+length.Uint64()]), true
+}
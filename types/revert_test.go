@@ -0,0 +1,78 @@
+package types
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const customErrorABI = `[{"inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}],"name":"InsufficientBalance","type":"error"}]`
+
+func TestDecodeRevert_ErrorString(t *testing.T) {
+	// Error("not owner")
+	data, _ := hex.DecodeString("08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000009" +
+		"6e6f74206f776e65720000000000000000000000000000000000000000000000")
+
+	revertError := DecodeRevert(data, nil)
+
+	assert.Equal(t, &RevertError{Sig: "Error(string)", Reason: "not owner", ParsedData: map[string]interface{}{"reason": "not owner"}}, revertError)
+	assert.Equal(t, "not owner", DecodeRevertReason(data))
+}
+
+func TestDecodeRevert_Panic(t *testing.T) {
+	// Panic(0x11)
+	data, _ := hex.DecodeString("4e487b71" +
+		"0000000000000000000000000000000000000000000000000000000000000011")
+
+	revertError := DecodeRevert(data, nil)
+
+	assert.Equal(t, &RevertError{Sig: "Panic(uint256)", Reason: "arithmetic underflow or overflow", ParsedData: map[string]interface{}{"code": big.NewInt(0x11)}}, revertError)
+}
+
+func TestDecodeRevert_UnknownPanicCode(t *testing.T) {
+	data, _ := hex.DecodeString("4e487b71" +
+		"00000000000000000000000000000000000000000000000000000000000000ff")
+
+	assert.Equal(t, "unknown panic code 0xff", DecodeRevertReason(data))
+}
+
+func TestDecodeRevert_CustomError(t *testing.T) {
+	structure, _ := NewABIStructureFromJSON(customErrorABI)
+	customErrors := structure.ToInternalABI().Errors
+	// InsufficientBalance(5, 10)
+	data, _ := hex.DecodeString("cf479181" +
+		"0000000000000000000000000000000000000000000000000000000000000005" +
+		"000000000000000000000000000000000000000000000000000000000000000a")
+
+	revertError := DecodeRevert(data, customErrors)
+
+	assert.Equal(t, "InsufficientBalance(uint256 available,uint256 required)", revertError.Sig)
+	assert.Equal(t, "InsufficientBalance", revertError.Reason)
+	assert.Equal(t, big.NewInt(5), revertError.ParsedData["available"])
+	assert.Equal(t, big.NewInt(10), revertError.ParsedData["required"])
+	// without the ABI, the error is not known
+	assert.Nil(t, DecodeRevert(data, nil))
+	assert.Equal(t, "", DecodeRevertReason(data))
+}
+
+func TestDecodeRevert_CustomErrorTruncated(t *testing.T) {
+	structure, _ := NewABIStructureFromJSON(customErrorABI)
+	data, _ := hex.DecodeString("cf479181" +
+		"0000000000000000000000000000000000000000000000000000000000000005")
+
+	assert.Nil(t, DecodeRevert(data, structure.ToInternalABI().Errors))
+}
+
+func TestDecodeRevert_Truncated(t *testing.T) {
+	data, _ := hex.DecodeString("08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000009" +
+		"6e6f74")
+
+	assert.Nil(t, DecodeRevert(data, nil))
+	assert.Nil(t, DecodeRevert(nil, nil))
+}
@@ -1,5 +1,10 @@
 package types
 
+import (
+	"math/big"
+	"strings"
+)
+
 type ERC721Token struct {
 	Contract  Address `json:"contract"`
 	Holder    Address `json:"holder"`
@@ -7,3 +12,105 @@ type ERC721Token struct {
 	HeldFrom  uint64  `json:"heldFrom"`
 	HeldUntil *uint64 `json:"heldUntil"`
 }
+
+// ERC1155Token is the balance a holder has of a single token ID, from the given block until
+// it next changed
+type ERC1155Token struct {
+	Contract  Address `json:"contract"`
+	Holder    Address `json:"holder"`
+	Token     string  `json:"token"`
+	Amount    string  `json:"amount"`
+	HeldFrom  uint64  `json:"heldFrom"`
+	HeldUntil *uint64 `json:"heldUntil"`
+}
+
+// TokenBalance is the balance a holder has of a token of one of the configurable token
+// standards, from the given block until it next changed
+type TokenBalance struct {
+	Standard  string  `json:"standard"`
+	Contract  Address `json:"contract"`
+	Holder    Address `json:"holder"`
+	Amount    string  `json:"amount"`
+	HeldFrom  uint64  `json:"heldFrom"`
+	HeldUntil *uint64 `json:"heldUntil"`
+}
+
+// ERC20BalanceChange is the balance a holder of an ERC20 token has from the block it changed in
+type ERC20BalanceChange struct {
+	Holder      Address `json:"holder"`
+	BlockNumber uint64  `json:"blockNumber"`
+	Amount      string  `json:"amount"`
+}
+
+// ERC20Allowance is the amount a spender is approved to transfer on behalf of an owner, from
+// the block it was approved at until it was next approved
+type ERC20Allowance struct {
+	Contract      Address `json:"contract"`
+	Owner         Address `json:"owner"`
+	Spender       Address `json:"spender"`
+	Amount        string  `json:"amount"`
+	ApprovedFrom  uint64  `json:"approvedFrom"`
+	ApprovedUntil *uint64 `json:"approvedUntil"`
+}
+
+// TokenTransfer is a single movement of tokens between two accounts, as given by its
+// Transfer event. ERC20 transfers have an amount, and ERC721 transfers a token ID.
+type TokenTransfer struct {
+	Contract        Address `json:"contract"`
+	From            Address `json:"from"`
+	To              Address `json:"to"`
+	Amount          string  `json:"amount,omitempty"`
+	TokenId         string  `json:"tokenId,omitempty"`
+	BlockNumber     uint64  `json:"blockNumber"`
+	TransactionHash Hash    `json:"transactionHash"`
+	LogIndex        uint64  `json:"logIndex"`
+	Timestamp       uint64  `json:"timestamp"`
+}
+
+// TokenInfo is the metadata of a token contract, read once when the token is first
+// filtered. Each field is left empty if the token does not implement it.
+type TokenInfo struct {
+	Contract Address `json:"contract"`
+	Standard string  `json:"standard"`
+	Name     string  `json:"name"`
+	Symbol   string  `json:"symbol"`
+	Decimals *uint8  `json:"decimals"`
+}
+
+// TokenSupply is the total supply of a token, from the given block until it next changed
+type TokenSupply struct {
+	Contract    Address `json:"contract"`
+	TotalSupply string  `json:"totalSupply"`
+	BlockNumber uint64  `json:"blockNumber"`
+	SupplyUntil *uint64 `json:"supplyUntil"`
+}
+
+// FormatTokenAmount shifts the decimal point of a raw token amount left by the number of
+// decimals the token declares, dropping any trailing zeros of the fraction
+func FormatTokenAmount(amount *big.Int, decimals uint8) string {
+	digits := new(big.Int).Abs(amount).String()
+	if len(digits) <= int(decimals) {
+		digits = strings.Repeat("0", int(decimals)-len(digits)+1) + digits
+	}
+	whole, fraction := digits[:len(digits)-int(decimals)], strings.TrimRight(digits[len(digits)-int(decimals):], "0")
+
+	formatted := whole
+	if fraction != "" {
+		formatted += "." + fraction
+	}
+	if amount.Sign() < 0 {
+		formatted = "-" + formatted
+	}
+	return formatted
+}
+
+// BackfillJob rebuilds the token records of a contract from the events in the stored blocks,
+// from FromBlock up to ToBlock, the last block the contract has been filtered to. NextBlock is
+// the next block to be processed, from which the job is resumed after a restart.
+type BackfillJob struct {
+	Contract  Address `json:"contract"`
+	FromBlock uint64  `json:"fromBlock"`
+	ToBlock   uint64  `json:"toBlock"`
+	NextBlock uint64  `json:"nextBlock"`
+	Status    string  `json:"status"`
+}
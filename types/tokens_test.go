@@ -0,0 +1,26 @@
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatTokenAmount(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		decimals uint8
+		expected string
+	}{
+		{1234500, 4, "123.45"},
+		{1000000, 6, "1"},
+		{5, 3, "0.005"},
+		{0, 18, "0"},
+		{42, 0, "42"},
+		{-1500, 3, "-1.5"},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, FormatTokenAmount(big.NewInt(tc.amount), tc.decimals))
+	}
+}
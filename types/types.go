@@ -15,6 +15,7 @@ type RawHeader struct {
 type RawBlock struct {
 	Hash         Hash      `json:"hash"`
 	ParentHash   Hash      `json:"parentHash"`
+	Miner        Address   `json:"miner"`
 	StateRoot    Hash      `json:"stateRoot"`
 	TxRoot       Hash      `json:"transactionsRoot"`
 	ReceiptRoot  Hash      `json:"receiptsRoot"`
@@ -26,20 +27,38 @@ type RawBlock struct {
 	Transactions []Hash    `json:"transactions"`
 }
 
+// received from eth_getTransactionByHash, for the fields of typed transactions that are not
+// available over GraphQL. Nodes that predate typed transactions leave them unset.
+type RawTransaction struct {
+	Type                 HexNumber     `json:"type"`
+	ChainID              *BigInt       `json:"chainId"`
+	GasPrice             *BigInt       `json:"gasPrice"`
+	MaxFeePerGas         *BigInt       `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *BigInt       `json:"maxPriorityFeePerGas"`
+	AccessList           []AccessTuple `json:"accessList"`
+}
+
 type RawInnerCall struct {
 	Type    string
 	To      Address
 	Input   HexData
 	From    Address
-	Value   HexNumber
+	Value   *BigInt
 	Gas     HexNumber
 	GasUsed HexNumber
 	Output  HexData
 	Calls   []RawInnerCall
+	// Error is set by the tracer if the call failed, and RevertReason if the failure was a
+	// revert with a decodable reason
+	Error        string
+	RevertReason string
 }
 
 type RawOuterCall struct {
 	Calls []RawInnerCall
+	// Output is the revert data of the transaction if Error is set
+	Output HexData
+	Error  string
 }
 
 type Block struct {
@@ -79,9 +98,9 @@ type Transaction struct {
 	Nonce             uint64          `json:"nonce"`
 	From              Address         `json:"from"`
 	To                Address         `json:"to"`
-	Value             uint64          `json:"value"`
+	Value             *BigInt         `json:"value"`
 	Gas               uint64          `json:"gas"`
-	GasPrice          uint64          `json:"gasPrice"`
+	GasPrice          *BigInt         `json:"gasPrice"`
 	GasUsed           uint64          `json:"gasUsed"`
 	CumulativeGasUsed uint64          `json:"cumulativeGasUsed"`
 	CreatedContract   Address         `json:"createdContract"`
@@ -91,6 +110,27 @@ type Transaction struct {
 	Timestamp         uint64          `json:"timestamp"`
 	Events            []*Event        `json:"events"`
 	InternalCalls     []*InternalCall `json:"internalCalls"`
+
+	// fields of typed transactions (EIP-2718); the fee caps are only set for dynamic fee
+	// transactions, and the chain ID is not set for legacy transactions without replay protection
+	Type                 uint64        `json:"type"`
+	ChainID              *BigInt       `json:"chainId"`
+	AccessList           []AccessTuple `json:"accessList"`
+	MaxFeePerGas         *BigInt       `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *BigInt       `json:"maxPriorityFeePerGas"`
+	EffectiveGasPrice    *BigInt       `json:"effectiveGasPrice"`
+
+	// Error is the failure the node reports for a failed transaction, e.g. "out of gas", and
+	// RevertData is the data it reverted with, which is empty if it did not revert
+	Error      string  `json:"error"`
+	RevertData HexData `json:"revertData"`
+}
+
+// AccessTuple is an entry in the access list of an EIP-2930 or EIP-1559 transaction, naming
+// an account and the storage slots of it that are warmed before execution
+type AccessTuple struct {
+	Address     Address `json:"address"`
+	StorageKeys []Hash  `json:"storageKeys"`
 }
 
 type InternalCall struct {
@@ -98,10 +138,39 @@ type InternalCall struct {
 	To      Address `json:"to"`
 	Gas     uint64  `json:"gas"`
 	GasUsed uint64  `json:"gasUsed"`
-	Value   uint64  `json:"value"`
+	Value   *BigInt `json:"value"`
 	Input   HexData `json:"input"`
 	Output  HexData `json:"output"`
 	Type    string  `json:"type"`
+	// Depth is 1 for calls made by the transaction's callee, and 0 for calls recorded before
+	// the call tree was kept. Parent is the index of the calling internal call, or -1 for
+	// calls made by the transaction's callee. Path holds the position of the call among the
+	// calls made by its caller at each level of the tree, e.g. [0 2] for the third call made
+	// by the first call.
+	Depth        uint64 `json:"depth"`
+	Parent       int    `json:"parent"`
+	Path         []int  `json:"path"`
+	Error        string `json:"error"`
+	RevertReason string `json:"revertReason"`
+}
+
+// Reverted reports whether the effects of the call were undone, either because it failed
+// itself or because one of the calls above it in the call tree did
+func (call *InternalCall) Reverted(calls []*InternalCall) bool {
+	for call != nil {
+		if call.Error != "" {
+			return true
+		}
+		if call.Depth <= 1 || call.Parent < 0 || call.Parent >= len(calls) {
+			return false
+		}
+		parent := calls[call.Parent]
+		if parent.Depth >= call.Depth {
+			return false
+		}
+		call = parent
+	}
+	return false
 }
 
 type Event struct {
@@ -121,3 +190,57 @@ type RangeResult struct {
 	End         uint64 `json:"end"`
 	ResultCount int    `json:"resultCount"`
 }
+
+// ChainReorg describes a chain reorganisation detected by the block monitor.
+// All data above the common ancestor is removed and re-imported from the new chain.
+type ChainReorg struct {
+	CommonAncestor uint64 `json:"commonAncestor"`
+	NewHead        uint64 `json:"newHead"`
+	NewHeadHash    Hash   `json:"newHeadHash"`
+	DetectedAt     uint64 `json:"detectedAt"`
+}
+
+// ProxyImplementation is the implementation contract that a proxy contract delegates to,
+// from the given block until the next recorded implementation
+type ProxyImplementation struct {
+	Implementation Address `json:"implementation"`
+	Standard       string  `json:"standard"`
+	BlockNumber    uint64  `json:"blockNumber"`
+}
+
+// NativeTransfer is a movement of the chain's native currency, either as the value of a
+// transaction or of one of its internal calls. Its type is that of the call that moved it,
+// and its index orders it among the native transfers of its block.
+type NativeTransfer struct {
+	From            Address `json:"from"`
+	To              Address `json:"to"`
+	Value           string  `json:"value"`
+	Type            string  `json:"type"`
+	BlockNumber     uint64  `json:"blockNumber"`
+	TransactionHash Hash    `json:"transactionHash"`
+	Index           uint64  `json:"index"`
+	Timestamp       uint64  `json:"timestamp"`
+}
+
+// TransactionFailure is a failed transaction sent to a registered address, along with the
+// reason it reverted with, decoded using the ABI of the address when it was filtered. The
+// error signature and reason are empty if the revert data could not be decoded.
+type TransactionFailure struct {
+	Address         Address `json:"address"`
+	TransactionHash Hash    `json:"transactionHash"`
+	BlockNumber     uint64  `json:"blockNumber"`
+	Index           uint64  `json:"index"`
+	Timestamp       uint64  `json:"timestamp"`
+	Error           string  `json:"error"`
+	ErrorSig        string  `json:"errorSig"`
+	Reason          string  `json:"reason"`
+	RevertData      HexData `json:"revertData"`
+}
+
+// NativeBalance is the native currency balance of an account, from the given block until
+// the next recorded balance
+type NativeBalance struct {
+	Address     Address `json:"address"`
+	Balance     string  `json:"balance"`
+	BlockNumber uint64  `json:"blockNumber"`
+}